@@ -0,0 +1,272 @@
+// Package cleanup removes what interrupted jobs leave behind: abandoned
+// multipart uploads and temporary files on a destination, and temporary tables
+// in the job databases. It can also remove the empty directories a migration
+// with exclusion filters leaves on the destination.
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"terrasync/app/migrate"
+	"terrasync/app/scan"
+	"terrasync/log"
+	"terrasync/object"
+	"time"
+)
+
+// Config selects what is cleaned up
+type Config struct {
+	Destination string
+	JobsDir     string        // 任务目录所在的目录，为空不清理临时表，每个任务按摘要记录的类型打开数据库
+	OlderThan   time.Duration // 只清理早于该时间的残留，避免影响正在运行的任务
+	Concurrency int           // 列举目标端的并发数
+	DryRun      bool          // 只统计不删除
+	Now         time.Time
+
+	// EmptyDirs removes the destination directories left without any entry,
+	// deepest first so the parents they emptied are removed too
+	EmptyDirs bool
+	// Source keeps the empty directories whose source directory is empty as
+	// well, only those emptied by exclusion filters are removed. Empty removes
+	// every empty directory.
+	Source string
+}
+
+// Removed counts what was or, with DryRun, would be removed
+type Removed struct {
+	Count int64
+	Bytes int64 // 回收的空间，临时表为数据库文件缩小的字节数
+}
+
+// Result is the outcome of a cleanup
+type Result struct {
+	Uploads       Removed // 已中止的分片上传
+	UploadsKnown  bool    // 目标存储是否支持分片上传
+	TempFiles     Removed // 已删除的.terrasync.tmp文件
+	TempTables    Removed // 已删除的临时表
+	EmptyDirs     Removed // 已删除的空目录
+	Jobs          int     // 有临时表的任务数
+	Failed        int     // 删除失败的条目数
+	ReclaimedSize int64
+}
+
+// Run removes the abandoned multipart uploads and temporary files of the
+// destination and the temporary tables of the job databases older than
+// config.OlderThan
+func Run(ctx context.Context, config Config) (*Result, error) {
+	if config.Now.IsZero() {
+		config.Now = time.Now()
+	}
+	cutoff := config.Now.Add(-config.OlderThan)
+	result := &Result{}
+
+	dst, err := object.CreateStorage(config.Destination)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create destination storage: %w", err)
+	}
+	defer dst.Close()
+
+	if aborter, ok := dst.(object.UploadAborter); ok {
+		result.UploadsKnown = true
+		if err := abortUploads(aborter, cutoff, config.DryRun, result); err != nil {
+			return result, err
+		}
+	}
+	var tree *dirTree
+	if config.EmptyDirs {
+		tree = newDirTree()
+	}
+	removeTempFiles(ctx, dst, cutoff, config, tree, result)
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	if tree != nil {
+		if err := removeEmptyDirs(dst, tree, cutoff, config, result); err != nil {
+			return result, err
+		}
+	}
+	if config.JobsDir != "" {
+		if err := dropTempTables(ctx, config, cutoff, result); err != nil {
+			return result, err
+		}
+	}
+	result.ReclaimedSize = result.Uploads.Bytes + result.TempFiles.Bytes + result.TempTables.Bytes
+	return result, nil
+}
+
+func abortUploads(aborter object.UploadAborter, cutoff time.Time, dryRun bool, result *Result) error {
+	uploads, err := aborter.ListIncompleteUploads()
+	if err != nil {
+		return fmt.Errorf("failed to list multipart uploads: %w", err)
+	}
+	for _, upload := range uploads {
+		if upload.Initiated.After(cutoff) {
+			continue
+		}
+		if !dryRun {
+			if err := aborter.AbortUpload(upload); err != nil {
+				log.Warnf("Failed to abort multipart upload %s of %s: %v", upload.UploadID, upload.Key, err)
+				result.Failed++
+				continue
+			}
+			log.Infof("Aborted multipart upload %s of %s initiated %s, %d bytes", upload.UploadID, upload.Key, upload.Initiated.UTC().Format(time.RFC3339), upload.Size)
+		}
+		result.Uploads.Count++
+		result.Uploads.Bytes += upload.Size
+	}
+	return nil
+}
+
+// removeTempFiles removes the temporary files of dst, the entries listed are
+// added to tree when it is not nil
+func removeTempFiles(ctx context.Context, dst object.Storage, cutoff time.Time, config Config, tree *dirTree, result *Result) {
+	for fileInfo := range scan.ListAll(ctx, dst, scan.ListOptions{Concurrency: config.Concurrency}) {
+		tree.add(fileInfo)
+		if fileInfo.IsDir() || !strings.HasSuffix(fileInfo.Key(), migrate.TempSuffix) || fileInfo.MTime().After(cutoff) {
+			continue
+		}
+		if !config.DryRun {
+			if err := dst.Delete(fileInfo.Key()); err != nil {
+				log.Warnf("Failed to remove temporary file %s: %v", fileInfo.Key(), err)
+				result.Failed++
+				continue
+			}
+			log.Infof("Removed temporary file %s, %d bytes", fileInfo.Key(), fileInfo.Size())
+		}
+		tree.remove(fileInfo.Key())
+		result.TempFiles.Count++
+		result.TempFiles.Bytes += fileInfo.Size()
+	}
+}
+
+// dirTree counts the entries of the directories of a listing
+type dirTree struct {
+	entries map[string]int       // 目录下的条目数
+	mtimes  map[string]time.Time // 列举到的目录及其mtime
+}
+
+func newDirTree() *dirTree {
+	return &dirTree{entries: make(map[string]int), mtimes: make(map[string]time.Time)}
+}
+
+func (t *dirTree) add(fileInfo object.FileInfo) {
+	if t == nil {
+		return
+	}
+	key := strings.TrimSuffix(filepath.ToSlash(fileInfo.Key()), "/")
+	if fileInfo.IsDir() {
+		t.mtimes[key] = fileInfo.MTime()
+	}
+	t.entries[path.Dir(key)]++
+}
+
+// remove removes an entry from its directory
+func (t *dirTree) remove(key string) {
+	if t == nil {
+		return
+	}
+	t.entries[path.Dir(strings.TrimSuffix(filepath.ToSlash(key), "/"))]--
+}
+
+// removeEmptyDirs removes the empty directories of tree not modified since
+// cutoff, children before their parents
+func removeEmptyDirs(dst object.Storage, tree *dirTree, cutoff time.Time, config Config, result *Result) error {
+	var src object.Storage
+	if config.Source != "" {
+		var err error
+		if src, err = object.CreateStorage(config.Source); err != nil {
+			return fmt.Errorf("failed to create source storage: %w", err)
+		}
+		defer src.Close()
+	}
+
+	dirs := make([]string, 0, len(tree.mtimes))
+	for key := range tree.mtimes {
+		dirs = append(dirs, key)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if di, dj := strings.Count(dirs[i], "/"), strings.Count(dirs[j], "/"); di != dj {
+			return di > dj
+		}
+		return dirs[i] < dirs[j]
+	})
+	for _, key := range dirs {
+		if tree.entries[key] > 0 || tree.mtimes[key].After(cutoff) || (src != nil && emptyOnSource(src, key)) {
+			continue
+		}
+		if !config.DryRun {
+			if err := dst.Delete(key); err != nil {
+				log.Warnf("Failed to remove empty directory %s: %v", key, err)
+				result.Failed++
+				continue
+			}
+			log.Infof("Removed empty directory %s", key)
+		}
+		tree.remove(key)
+		result.EmptyDirs.Count++
+	}
+	return nil
+}
+
+// emptyOnSource reports whether the directory key of src is empty too, or
+// cannot be listed, the destination directory is then kept
+func emptyOnSource(src object.Storage, key string) bool {
+	entries, err := src.List(key)
+	if err != nil {
+		log.Warnf("Kept empty directory %s, failed to list it on the source: %v", key, err)
+		return true
+	}
+	empty := true
+	for range entries {
+		empty = false
+	}
+	return empty
+}
+
+// dropTempTables drops the temporary tables of the job databases created
+// before cutoff, whatever the database type of each job
+func dropTempTables(ctx context.Context, config Config, cutoff time.Time, result *Result) error {
+	entries, err := os.ReadDir(config.JobsDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read jobs directory: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		jobDir := filepath.Join(config.JobsDir, entry.Name())
+		// 没有摘要的目录不是扫描任务(如迁移任务)，也不会有增量扫描的临时表
+		summary, err := scan.LoadJobSummary(jobDir)
+		if err != nil {
+			continue
+		}
+		// 服务器数据库不在任务目录中，SQLite数据库文件的大小只用于统计回收的空间
+		before, statErr := os.Stat(filepath.Join(jobDir, "index.db"))
+		tables, err := scan.DropTempTables(ctx, summary.DbType, jobDir, cutoff, config.DryRun)
+		if err != nil {
+			log.Warnf("Failed to drop temporary tables of job %s: %v", entry.Name(), err)
+			result.Failed++
+			continue
+		}
+		if len(tables) == 0 {
+			continue
+		}
+		result.Jobs++
+		result.TempTables.Count += int64(len(tables))
+		if after, err := os.Stat(filepath.Join(jobDir, "index.db")); err == nil && statErr == nil && !config.DryRun {
+			result.TempTables.Bytes += max(before.Size()-after.Size(), 0)
+		}
+		if !config.DryRun {
+			log.Infof("Dropped %d temporary tables of job %s", len(tables), entry.Name())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package cleanup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"terrasync/app/scan"
+	"terrasync/log"
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// uploadStorage 记录被中止的分片上传
+type uploadStorage struct {
+	object.Storage
+	uploads []object.IncompleteUpload
+	aborted []string
+}
+
+func (s *uploadStorage) ListIncompleteUploads() ([]object.IncompleteUpload, error) {
+	return s.uploads, nil
+}
+
+func (s *uploadStorage) AbortUpload(upload object.IncompleteUpload) error {
+	s.aborted = append(s.aborted, upload.UploadID)
+	return nil
+}
+
+// TestRun 测试清理目标端的临时文件及任务数据库的临时表
+func TestRun(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+
+	dst := t.TempDir()
+	write := func(name, data string, mtime time.Time) {
+		path := filepath.Join(dst, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(data), 0644))
+		assert.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+	write("a/file.txt", "keep", old)
+	write("a/big.bin.terrasync.tmp", "0123456789", old)
+	write("b/c/x.terrasync.tmp", "abc", old)
+	write("b/running.terrasync.tmp", "in flight", now)
+
+	// 中断的增量扫描留下的临时表，表名中有创建时间，正在运行的扫描的临时表保留
+	jobs := t.TempDir()
+	jobDir := filepath.Join(jobs, "Job_1_scan")
+	createTables := func(jobDir string, names ...string) {
+		dbInstance, err := scan.InitDatabase(context.Background(), "sqlite", jobDir)
+		assert.NoError(t, err)
+		for _, name := range names {
+			assert.NoError(t, (*dbInstance).CreateTable(context.Background(), name))
+		}
+		(*dbInstance).Close()
+		assert.NoError(t, scan.SaveJobSummary(jobDir, scan.JobSummary{JobID: filepath.Base(jobDir), DbType: "sqlite"}))
+	}
+	stamp := func(created time.Time, n string) string {
+		return scan.TempTablePrefix + created.UTC().Format("20060102150405") + "_" + n
+	}
+	createTables(jobDir, stamp(old, "1"), stamp(old, "2"), stamp(now, "3"))
+	// 之前版本的表名只有UUID，任务目录在--older-than内没有修改时删除
+	legacyDir := filepath.Join(jobs, "Job_2_scan")
+	createTables(legacyDir, scan.TempTablePrefix+"0a1b2c3d_4e5f")
+	entries, err := os.ReadDir(legacyDir)
+	assert.NoError(t, err)
+	for _, entry := range entries {
+		assert.NoError(t, os.Chtimes(filepath.Join(legacyDir, entry.Name()), old, old))
+	}
+	assert.NoError(t, os.Chtimes(legacyDir, old, old))
+	// 迁移任务没有摘要，跳过
+	assert.NoError(t, os.MkdirAll(filepath.Join(jobs, "Job_3_migrate"), 0755))
+
+	config := Config{Destination: dst, JobsDir: jobs, OlderThan: 24 * time.Hour, Concurrency: 2, Now: now, DryRun: true}
+	result, err := Run(context.Background(), config)
+	assert.NoError(t, err)
+	assert.Equal(t, Removed{Count: 2, Bytes: 13}, result.TempFiles)
+	assert.Equal(t, int64(3), result.TempTables.Count)
+	assert.FileExists(t, filepath.Join(dst, "b/c/x.terrasync.tmp"))
+
+	config.DryRun = false
+	result, err = Run(context.Background(), config)
+	assert.NoError(t, err)
+	assert.Equal(t, Removed{Count: 2, Bytes: 13}, result.TempFiles)
+	assert.Equal(t, int64(3), result.TempTables.Count)
+	assert.Equal(t, 2, result.Jobs)
+	assert.False(t, result.UploadsKnown)
+	assert.NoFileExists(t, filepath.Join(dst, "a/big.bin.terrasync.tmp"))
+	assert.NoFileExists(t, filepath.Join(dst, "b/c/x.terrasync.tmp"))
+	assert.FileExists(t, filepath.Join(dst, "b/running.terrasync.tmp"))
+	assert.FileExists(t, filepath.Join(dst, "a/file.txt"))
+
+	tables, err := scan.DropTempTables(context.Background(), "sqlite", jobDir, now.Add(time.Hour), true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{stamp(now, "3")}, tables)
+	tables, err = scan.DropTempTables(context.Background(), "sqlite", legacyDir, now.Add(time.Hour), true)
+	assert.NoError(t, err)
+	assert.Empty(t, tables)
+}
+
+// TestRunEmptyDirs 测试删除目标端的空目录，指定源端时保留源端同样为空的目录
+func TestRunEmptyDirs(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+
+	src, dst := t.TempDir(), t.TempDir()
+	for _, tree := range []string{src, dst} {
+		for _, dir := range []string{"logs/2024", "staging", "a"} {
+			assert.NoError(t, os.MkdirAll(filepath.Join(tree, dir), 0755))
+		}
+		assert.NoError(t, os.WriteFile(filepath.Join(tree, "a/file.txt"), []byte("data"), 0644))
+	}
+	// 源端的日志文件被迁移的排除条件跳过
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "logs/2024/app.log"), []byte("log"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "staging/big.bin"), []byte("data"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dst, "staging/big.bin.terrasync.tmp"), []byte("tmp"), 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dst, "empty"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(src, "empty"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dst, "running"), 0755))
+	for _, name := range []string{"logs/2024", "logs", "staging/big.bin.terrasync.tmp", "staging", "empty", "a"} {
+		assert.NoError(t, os.Chtimes(filepath.Join(dst, name), old, old))
+	}
+
+	config := Config{Destination: dst, OlderThan: 24 * time.Hour, Concurrency: 2, Now: now, EmptyDirs: true, Source: src, DryRun: true}
+	result, err := Run(context.Background(), config)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), result.EmptyDirs.Count)
+	assert.DirExists(t, filepath.Join(dst, "logs/2024"))
+
+	config.DryRun = false
+	result, err = Run(context.Background(), config)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), result.EmptyDirs.Count)
+	assert.NoDirExists(t, filepath.Join(dst, "logs"))
+	assert.NoDirExists(t, filepath.Join(dst, "staging"))
+	assert.DirExists(t, filepath.Join(dst, "empty"))
+	assert.DirExists(t, filepath.Join(dst, "running"))
+	assert.FileExists(t, filepath.Join(dst, "a/file.txt"))
+
+	// 不指定源端时删除所有空目录，早于时限的除外
+	config.Source = ""
+	result, err = Run(context.Background(), config)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.EmptyDirs.Count)
+	assert.DirExists(t, filepath.Join(dst, "running"))
+	assert.NoDirExists(t, filepath.Join(dst, "empty"))
+}
+
+// TestAbortUploads 测试只中止早于时限的分片上传
+func TestAbortUploads(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	now := time.Now()
+	s := &uploadStorage{uploads: []object.IncompleteUpload{
+		{Key: "/a.bin", UploadID: "old", Initiated: now.Add(-72 * time.Hour), Size: 100},
+		{Key: "/b.bin", UploadID: "new", Initiated: now.Add(-time.Hour), Size: 50},
+	}}
+	result := &Result{}
+	assert.NoError(t, abortUploads(s, now.Add(-24*time.Hour), false, result))
+	assert.Equal(t, []string{"old"}, s.aborted)
+	assert.Equal(t, Removed{Count: 1, Bytes: 100}, result.Uploads)
+}
@@ -0,0 +1,230 @@
+// Package gc removes the artifacts past their retention from the directory of
+// the executable: the job directories, the exports written next to the
+// executable (failure ledgers, reconcile reports and acceptance lists) and the
+// rotated log files, so migration hosts don't fill their system disks.
+package gc
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"terrasync/log"
+	"time"
+)
+
+// Config gives the retention of each kind of artifact, 0 keeps them all
+type Config struct {
+	Dir      string        // 可执行文件所在目录，任务目录为其下的jobs
+	Jobs     time.Duration // 任务目录的保留时间，按目录中最新的修改时间
+	KeepJobs int           // 无论保留时间始终保留的最近任务数
+	Exports  time.Duration // failures_、reconcile_、acceptance_等导出报告的保留时间
+	Logs     time.Duration // 轮转后的日志文件的保留时间，当前日志不删除
+	DryRun   bool          // 只统计不删除
+	Now      time.Time
+}
+
+// Removed counts what was or, with DryRun, would be removed
+type Removed struct {
+	Count int64
+	Bytes int64
+}
+
+// Result is the outcome of a collection
+type Result struct {
+	Jobs          Removed // 已删除的任务目录
+	Exports       Removed // 已删除的导出文件
+	Logs          Removed // 已删除的轮转日志
+	Failed        int     // 删除失败的条目数
+	ReclaimedSize int64
+}
+
+// exportPrefixes are the prefixes of the reports migrate and verify write next
+// to the executable when no path is given, followed by their start time
+var exportPrefixes = []string{"failures_", "reconcile_", "rewrite_", "stubs_", "acceptance_", "verify_"}
+
+// artifact is a file or a job directory that may be removed
+type artifact struct {
+	path    string
+	modTime time.Time // 任务目录为其中最新的修改时间
+	size    int64
+}
+
+// Run removes the job directories, exports and rotated logs of config.Dir
+// older than their retention
+func Run(ctx context.Context, config Config) (*Result, error) {
+	if config.Now.IsZero() {
+		config.Now = time.Now()
+	}
+	result := &Result{}
+
+	if config.Jobs > 0 {
+		jobs, err := listJobs(filepath.Join(config.Dir, "jobs"))
+		if err != nil {
+			return result, err
+		}
+		// 最近的任务在前，前KeepJobs个始终保留
+		sort.Slice(jobs, func(i, j int) bool { return jobs[i].modTime.After(jobs[j].modTime) })
+		if config.KeepJobs > 0 {
+			jobs = jobs[min(config.KeepJobs, len(jobs)):]
+		}
+		remove(ctx, jobs, config.Now.Add(-config.Jobs), config.DryRun, &result.Jobs, result)
+	}
+
+	if config.Exports > 0 || config.Logs > 0 {
+		exports, logs, err := listFiles(config.Dir)
+		if err != nil {
+			return result, err
+		}
+		if config.Exports > 0 {
+			remove(ctx, exports, config.Now.Add(-config.Exports), config.DryRun, &result.Exports, result)
+		}
+		if config.Logs > 0 {
+			remove(ctx, logs, config.Now.Add(-config.Logs), config.DryRun, &result.Logs, result)
+		}
+	}
+	return result, ctx.Err()
+}
+
+// remove removes the artifacts modified before cutoff
+func remove(ctx context.Context, artifacts []artifact, cutoff time.Time, dryRun bool, removed *Removed, result *Result) {
+	for _, a := range artifacts {
+		if ctx.Err() != nil {
+			return
+		}
+		if !a.modTime.Before(cutoff) {
+			continue
+		}
+		if dryRun {
+			log.Infof("Would remove %s, last modified %s", a.path, a.modTime.Format(time.RFC3339))
+		} else if err := os.RemoveAll(a.path); err != nil {
+			log.Warnf("Failed to remove %s: %v", a.path, err)
+			result.Failed++
+			continue
+		} else {
+			log.Infof("Removed %s, last modified %s", a.path, a.modTime.Format(time.RFC3339))
+		}
+		removed.Count++
+		removed.Bytes += a.size
+		result.ReclaimedSize += a.size
+	}
+}
+
+// listJobs returns the job directories of jobsDir with their size and the
+// newest modification time of their files, a job still writing its database
+// or reports is recent
+func listJobs(jobsDir string) ([]artifact, error) {
+	entries, err := os.ReadDir(jobsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job directories: %w", err)
+	}
+	var jobs []artifact
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "Job_") {
+			continue
+		}
+		job := artifact{path: filepath.Join(jobsDir, entry.Name())}
+		err := filepath.WalkDir(job.path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if info.ModTime().After(job.modTime) {
+				job.modTime = info.ModTime()
+			}
+			if !d.IsDir() {
+				job.size += info.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			// 无法确定最后修改时间的任务保留
+			log.Warnf("Failed to read job directory %s, keeping it: %v", job.path, err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// listFiles returns the exports and the rotated logs of dir
+func listFiles(dir string) (exports, logs []artifact, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		name := entry.Name()
+		isExport, isLog := isExport(name), isRotatedLog(name)
+		if !isExport && !isLog {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		a := artifact{path: filepath.Join(dir, name), modTime: info.ModTime(), size: info.Size()}
+		if isExport {
+			exports = append(exports, a)
+		} else {
+			logs = append(logs, a)
+		}
+	}
+	return exports, logs, nil
+}
+
+// isExport reports whether name is a report of exportPrefixes, named
+// <prefix><time>.csv or .html
+func isExport(name string) bool {
+	ext := filepath.Ext(name)
+	if ext != ".csv" && ext != ".html" {
+		return false
+	}
+	for _, prefix := range exportPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRotatedLog reports whether name is a backup of terrasync.log, named
+// terrasync-<time>.log(.gz) by the log rotation
+func isRotatedLog(name string) bool {
+	return strings.HasPrefix(name, "terrasync-") &&
+		(strings.HasSuffix(name, ".log") || strings.HasSuffix(name, ".log.gz"))
+}
+
+// Schedule runs a collection every interval until ctx is cancelled, for the
+// long-running commands. Errors are logged, the next run tries again.
+func Schedule(ctx context.Context, config Config, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		config.Now = time.Time{}
+		result, err := Run(ctx, config)
+		if err != nil && ctx.Err() == nil {
+			log.Warnf("Housekeeping failed: %v", err)
+		} else if result.ReclaimedSize > 0 || result.Jobs.Count+result.Exports.Count+result.Logs.Count > 0 {
+			log.Infof("Housekeeping removed %d jobs, %d exports and %d logs, reclaimed %d bytes",
+				result.Jobs.Count, result.Exports.Count, result.Logs.Count, result.ReclaimedSize)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package gc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"terrasync/log"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// writeFile 写入文件并设置修改时间
+func writeFile(t *testing.T, path string, size int, modTime time.Time) {
+	t.Helper()
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, os.WriteFile(path, make([]byte, size), 0644))
+	assert.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+// TestRun 测试按保留时间删除任务目录、导出报告及轮转日志
+func TestRun(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	now := time.Now()
+	old := now.Add(-60 * 24 * time.Hour)
+	dir := t.TempDir()
+
+	// 任务目录按其中最新的修改时间判断
+	writeFile(t, filepath.Join(dir, "jobs", "Job_a_scan", "job.db"), 100, old)
+	writeFile(t, filepath.Join(dir, "jobs", "Job_b_scan", "job.db"), 200, old.Add(time.Hour))
+	writeFile(t, filepath.Join(dir, "jobs", "Job_c_scan", "job.db"), 300, old)
+	writeFile(t, filepath.Join(dir, "jobs", "Job_c_scan", "summary.json"), 10, now)
+	writeFile(t, filepath.Join(dir, "jobs", "other", "file"), 10, old)
+	for _, job := range []string{"Job_a_scan", "Job_b_scan", "Job_c_scan", "other"} {
+		assert.NoError(t, os.Chtimes(filepath.Join(dir, "jobs", job), old, old))
+	}
+
+	writeFile(t, filepath.Join(dir, "failures_2026-01-01_00.00.00.csv"), 10, old)
+	writeFile(t, filepath.Join(dir, "acceptance_2026-01-01_00.00.00.html"), 20, old)
+	writeFile(t, filepath.Join(dir, "verify_2026-10-01_00.00.00.csv"), 30, now)
+	writeFile(t, filepath.Join(dir, "failures.txt"), 10, old)
+	writeFile(t, filepath.Join(dir, "terrasync-2026-01-01T00-00-00.000.log.gz"), 40, old)
+	writeFile(t, filepath.Join(dir, "terrasync.log"), 50, old)
+	writeFile(t, filepath.Join(dir, "config.yaml"), 10, old)
+
+	config := Config{Dir: dir, Jobs: 30 * 24 * time.Hour, KeepJobs: 2, Exports: 30 * 24 * time.Hour, Logs: 30 * 24 * time.Hour, Now: now, DryRun: true}
+	result, err := Run(context.Background(), config)
+	assert.NoError(t, err)
+	// 最近的两个任务(c、b)始终保留，不是任务的目录不删除
+	assert.Equal(t, Removed{Count: 1, Bytes: 100}, result.Jobs)
+	assert.Equal(t, Removed{Count: 2, Bytes: 30}, result.Exports)
+	assert.Equal(t, Removed{Count: 1, Bytes: 40}, result.Logs)
+	assert.Equal(t, int64(170), result.ReclaimedSize)
+	assert.DirExists(t, filepath.Join(dir, "jobs", "Job_a_scan"), "试运行不删除")
+
+	config.DryRun = false
+	config.KeepJobs = 0
+	result, err = Run(context.Background(), config)
+	assert.NoError(t, err)
+	assert.Equal(t, Removed{Count: 2, Bytes: 300}, result.Jobs)
+	assert.Zero(t, result.Failed)
+	assert.NoDirExists(t, filepath.Join(dir, "jobs", "Job_a_scan"))
+	assert.NoDirExists(t, filepath.Join(dir, "jobs", "Job_b_scan"))
+	assert.DirExists(t, filepath.Join(dir, "jobs", "Job_c_scan"))
+	assert.DirExists(t, filepath.Join(dir, "jobs", "other"))
+	assert.NoFileExists(t, filepath.Join(dir, "failures_2026-01-01_00.00.00.csv"))
+	assert.NoFileExists(t, filepath.Join(dir, "terrasync-2026-01-01T00-00-00.000.log.gz"))
+	for _, name := range []string{"verify_2026-10-01_00.00.00.csv", "failures.txt", "terrasync.log", "config.yaml"} {
+		assert.FileExists(t, filepath.Join(dir, name))
+	}
+
+	// 保留时间为0时不删除
+	writeFile(t, filepath.Join(dir, "jobs", "Job_d_scan", "job.db"), 10, old)
+	result, err = Run(context.Background(), Config{Dir: dir, Now: now})
+	assert.NoError(t, err)
+	assert.Zero(t, result.ReclaimedSize)
+	assert.DirExists(t, filepath.Join(dir, "jobs", "Job_d_scan"))
+}
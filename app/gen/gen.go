@@ -0,0 +1,306 @@
+package gen
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"terrasync/log"
+	"time"
+)
+
+// Size distributions of generated files
+const (
+	DistFixed     = "fixed"
+	DistUniform   = "uniform"
+	DistLognormal = "lognormal"
+)
+
+// lognormalSigma gives a long tail similar to real file shares: most files are
+// small and a few are orders of magnitude larger than the mean
+const lognormalSigma = 1.5
+
+// GenConfig 测试数据生成配置
+// The same configuration and seed always produce the same tree.
+type GenConfig struct {
+	Path        string
+	Files       int     // 文件总数
+	Depth       int     // 最大目录深度
+	FilesPerDir int     // 平均每个目录的文件数
+	SizeDist    string  // 文件大小分布: fixed, uniform, lognormal
+	MeanSize    int64   // 平均文件大小
+	MaxSize     int64   // 最大文件大小
+	Symlinks    float64 // 符号链接比例
+	Sparse      float64 // 稀疏文件比例
+	WeirdNames  float64 // 特殊文件名比例(空格、Unicode、超长等)
+	Seed        int64
+	Concurrency int
+}
+
+// Result holds what was generated
+type Result struct {
+	Files    int64
+	Dirs     int64
+	Symlinks int64
+	Sparse   int64
+	Bytes    int64
+	Duration time.Duration
+}
+
+// Validate checks the configuration
+func (c *GenConfig) Validate() error {
+	if c.Path == "" {
+		return fmt.Errorf("path must be specified")
+	}
+	if c.Files <= 0 {
+		return fmt.Errorf("number of files must be positive: %d", c.Files)
+	}
+	if c.Depth <= 0 || c.FilesPerDir <= 0 || c.Concurrency <= 0 {
+		return fmt.Errorf("depth, files per dir and concurrency must be positive")
+	}
+	switch c.SizeDist {
+	case DistFixed, DistUniform, DistLognormal:
+	default:
+		return fmt.Errorf("unsupported size distribution %q, expect fixed, uniform or lognormal", c.SizeDist)
+	}
+	if c.MeanSize < 0 || c.MaxSize < c.MeanSize {
+		return fmt.Errorf("invalid sizes: mean %d, max %d", c.MeanSize, c.MaxSize)
+	}
+	for _, ratio := range []float64{c.Symlinks, c.Sparse, c.WeirdNames} {
+		if ratio < 0 || ratio > 1 {
+			return fmt.Errorf("ratios must be between 0 and 1: %v", ratio)
+		}
+	}
+	return nil
+}
+
+// entryKind is the type of a generated entry
+type entryKind int
+
+const (
+	kindFile entryKind = iota
+	kindSparse
+	kindSymlink
+)
+
+// entry is one generated file, planned before anything is written so that the
+// tree does not depend on the order in which workers run
+type entry struct {
+	path   string
+	kind   entryKind
+	size   int64
+	target string // 符号链接目标(相对路径)
+	seed   int64  // 文件内容的种子
+}
+
+// weirdNames are name fragments that commonly break migration tools.
+// Characters invalid on Windows are avoided so trees can be copied anywhere.
+var weirdNames = []string{
+	"with space", " leading space", "trailing space ", "-leading-dash", "#hash",
+	"'quote'", "semi;colon", "percent%20", "中文文件名", "日本語", "emoji😀",
+	"café", "é", "UPPER", "upper", "..dots..", strings.Repeat("long", 50),
+}
+
+// Generate creates the synthetic tree
+func Generate(ctx context.Context, cfg GenConfig) (*Result, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	rnd := rand.New(rand.NewSource(cfg.Seed))
+
+	dirs := planDirs(rnd, cfg)
+	entries := planFiles(rnd, cfg, dirs)
+
+	// dirs[0]是根目录本身，不计入
+	result := &Result{Dirs: int64(len(dirs) - 1)}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(filepath.Join(cfg.Path, dir), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+
+	pattern := contentPattern(cfg.Seed)
+	jobs := make(chan entry)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	for i := 0; i < cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range jobs {
+				if err := writeEntry(cfg.Path, e, pattern); err != nil {
+					log.Errorf("Generate %s error: %v", e.path, err)
+					errOnce.Do(func() { firstErr = err })
+					continue
+				}
+				switch e.kind {
+				case kindSymlink:
+					atomic.AddInt64(&result.Symlinks, 1)
+				case kindSparse:
+					atomic.AddInt64(&result.Sparse, 1)
+				}
+				atomic.AddInt64(&result.Files, 1)
+				atomic.AddInt64(&result.Bytes, e.size)
+			}
+		}()
+	}
+
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- e
+	}
+	close(jobs)
+	wg.Wait()
+
+	result.Duration = time.Since(start)
+	if firstErr != nil {
+		return result, fmt.Errorf("failed to generate files: %w", firstErr)
+	}
+	return result, ctx.Err()
+}
+
+// planDirs builds the directory tree, each new directory is attached to a random
+// existing directory above the maximum depth
+func planDirs(rnd *rand.Rand, cfg GenConfig) []string {
+	count := (cfg.Files + cfg.FilesPerDir - 1) / cfg.FilesPerDir
+	dirs := []string{"."}
+	depths := []int{0}
+	for i := 1; i < count; i++ {
+		parent := rnd.Intn(len(dirs))
+		for depths[parent] >= cfg.Depth {
+			parent = rnd.Intn(len(dirs))
+		}
+		name := fmt.Sprintf("d%d", i)
+		if rnd.Float64() < cfg.WeirdNames {
+			name = weirdNames[rnd.Intn(len(weirdNames))] + "-" + strconv.Itoa(i)
+		}
+		dirs = append(dirs, filepath.Join(dirs[parent], name))
+		depths = append(depths, depths[parent]+1)
+	}
+	return dirs
+}
+
+// planFiles assigns every file a directory, a name, a kind and a size
+func planFiles(rnd *rand.Rand, cfg GenConfig, dirs []string) []entry {
+	entries := make([]entry, 0, cfg.Files)
+	for i := 0; i < cfg.Files; i++ {
+		dir := dirs[rnd.Intn(len(dirs))]
+		name := fmt.Sprintf("f%d.dat", i)
+		if rnd.Float64() < cfg.WeirdNames {
+			name = weirdNames[rnd.Intn(len(weirdNames))] + "-" + strconv.Itoa(i) + ".dat"
+		}
+		e := entry{path: filepath.Join(dir, name), size: fileSize(rnd, cfg), seed: rnd.Int63()}
+
+		// 符号链接指向之前生成的普通文件
+		r := rnd.Float64()
+		switch {
+		case r < cfg.Symlinks && len(entries) > 0:
+			target := entries[rnd.Intn(len(entries))]
+			if rel, err := filepath.Rel(filepath.Dir(e.path), target.path); err == nil {
+				e.kind, e.target, e.size = kindSymlink, rel, 0
+			}
+		case r < cfg.Symlinks+cfg.Sparse:
+			e.kind = kindSparse
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// fileSize draws a size from the configured distribution
+func fileSize(rnd *rand.Rand, cfg GenConfig) int64 {
+	var size float64
+	switch cfg.SizeDist {
+	case DistFixed:
+		size = float64(cfg.MeanSize)
+	case DistUniform:
+		size = rnd.Float64() * 2 * float64(cfg.MeanSize)
+	case DistLognormal:
+		if cfg.MeanSize > 0 {
+			mu := math.Log(float64(cfg.MeanSize)) - lognormalSigma*lognormalSigma/2
+			size = math.Exp(mu + lognormalSigma*rnd.NormFloat64())
+		}
+	}
+	if size > float64(cfg.MaxSize) {
+		size = float64(cfg.MaxSize)
+	}
+	return int64(size)
+}
+
+// contentPattern is the block that file contents are cut from
+func contentPattern(seed int64) []byte {
+	pattern := make([]byte, 1<<20)
+	rand.New(rand.NewSource(seed)).Read(pattern)
+	return pattern
+}
+
+// writeEntry writes one planned file
+func writeEntry(root string, e entry, pattern []byte) error {
+	p := filepath.Join(root, e.path)
+	if e.kind == kindSymlink {
+		return os.Symlink(e.target, p)
+	}
+
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	if e.kind == kindSparse {
+		// 只写最后一个块，其余部分是空洞
+		tail := min(e.size, 4096)
+		err = f.Truncate(e.size)
+		if err == nil && tail > 0 {
+			_, err = f.WriteAt(pattern[:tail], e.size-tail)
+		}
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		return f.Close()
+	}
+
+	// 每个文件从不同的偏移开始，内容可复现且不完全相同，避免被去重
+	offset := int(e.seed % int64(len(pattern)))
+	for remaining := e.size; remaining > 0; {
+		n := min(remaining, int64(len(pattern)-offset))
+		if _, err := f.Write(pattern[offset : offset+int(n)]); err != nil {
+			_ = f.Close()
+			return err
+		}
+		remaining -= n
+		offset = 0
+	}
+	return f.Close()
+}
+
+// ParseCount parses a number with an optional decimal suffix, e.g. 500, 10K, 1M
+func ParseCount(s string) (int, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(s, "K"):
+		multiplier = 1000
+	case strings.HasSuffix(s, "M"):
+		multiplier = 1000000
+	case strings.HasSuffix(s, "G"):
+		multiplier = 1000000000
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid count %q", s)
+	}
+	return int(n * float64(multiplier)), nil
+}
@@ -0,0 +1,82 @@
+package gen
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"terrasync/log"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func init() {
+	log.Log = zap.NewNop().Sugar()
+}
+
+// listTree 返回目录树中每个条目的大小和类型
+func listTree(t *testing.T, root string) map[string]string {
+	tree := make(map[string]string)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		tree[rel] = fmt.Sprintf("%s:%d", info.Mode().Type(), info.Size())
+		return nil
+	})
+	assert.NoError(t, err)
+	return tree
+}
+
+// TestGenerateDeterministic 测试相同种子生成相同的目录树
+func TestGenerateDeterministic(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	cfg := GenConfig{
+		Files: 300, Depth: 3, FilesPerDir: 10, SizeDist: DistLognormal,
+		MeanSize: 2048, MaxSize: 64 << 10, Symlinks: 0.1, Sparse: 0.1, WeirdNames: 0.2,
+		Seed: 42, Concurrency: 4,
+	}
+
+	cfg.Path = t.TempDir()
+	first, err := Generate(context.Background(), cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(300), first.Files)
+	assert.Greater(t, first.Symlinks, int64(0))
+	assert.Greater(t, first.Sparse, int64(0))
+	firstTree := listTree(t, cfg.Path)
+
+	cfg.Path = t.TempDir()
+	second, err := Generate(context.Background(), cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, first.Bytes, second.Bytes)
+	assert.Equal(t, firstTree, listTree(t, cfg.Path))
+
+	cfg.Path, cfg.Seed = t.TempDir(), 43
+	_, err = Generate(context.Background(), cfg)
+	assert.NoError(t, err)
+	assert.NotEqual(t, firstTree, listTree(t, cfg.Path))
+}
+
+// TestParseCount 测试数量解析
+func TestParseCount(t *testing.T) {
+	cases := map[string]int{"500": 500, "10K": 10000, "1M": 1000000, "1.5k": 1500}
+	for in, want := range cases {
+		got, err := ParseCount(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	_, err := ParseCount("abc")
+	assert.Error(t, err)
+}
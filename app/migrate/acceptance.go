@@ -0,0 +1,411 @@
+package migrate
+
+import (
+	"context"
+	"embed"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"terrasync/app/scan"
+	"terrasync/i18n"
+	"terrasync/object"
+	"terrasync/security"
+	"time"
+)
+
+// Results of the acceptance checklist items
+const (
+	AcceptancePass   = "pass"
+	AcceptanceFail   = "fail"
+	AcceptanceReview = "review" // 需要人工确认，不阻止切换
+)
+
+// Sections of the acceptance checklist
+const (
+	SectionSummary  = "summary"
+	SectionCounts   = "counts"
+	SectionTransfer = "transfer"
+	SectionChecksum = "checksum"
+	SectionErrors   = "errors"
+	SectionSignOff  = "sign-off"
+)
+
+// sectionTitles are the headings of the sections in the HTML checklist
+var sectionTitles = map[string]string{
+	SectionCounts:   "Counts",
+	SectionTransfer: "Transfer",
+	SectionChecksum: "Checksum Sample",
+	SectionErrors:   "Errors",
+	SectionSignOff:  "Sign-off",
+}
+
+// maxChecklistFailures is the number of unresolved failures listed one by one,
+// the others are only counted per reason
+const maxChecklistFailures = 100
+
+// SignOffRoles are the roles signing the acceptance of a wave
+var SignOffRoles = []string{"Migration engineer", "Share owner", "Project manager"}
+
+// AcceptanceItem is one line of the acceptance checklist
+type AcceptanceItem struct {
+	Section  string
+	Item     string
+	Expected string
+	Actual   string
+	Result   string // pass, fail, review，只作记录的条目为空
+}
+
+// SampleEntry is a copied file picked for the checksum comparison
+type SampleEntry struct {
+	Source      string
+	Destination string
+	Size        int64
+}
+
+// sampler keeps a uniform random sample of the copied files (reservoir sampling)
+type sampler struct {
+	mu      sync.Mutex
+	size    int
+	seen    int64
+	entries []SampleEntry
+}
+
+// newSampler creates a sampler keeping n files, nil when n is not positive
+func newSampler(n int) *sampler {
+	if n <= 0 {
+		return nil
+	}
+	return &sampler{size: n}
+}
+
+// Offer adds a copied file to the sample
+func (s *sampler) Offer(e SampleEntry) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen++
+	if len(s.entries) < s.size {
+		s.entries = append(s.entries, e)
+	} else if i := rand.Int64N(s.seen); i < int64(s.size) {
+		s.entries[i] = e
+	}
+}
+
+// Entries returns the sample sorted by source key
+func (s *sampler) Entries() []SampleEntry {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := append([]SampleEntry(nil), s.entries...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Source < entries[j].Source })
+	return entries
+}
+
+// SampleCheck is the checksum comparison of a sampled file
+type SampleCheck struct {
+	SampleEntry
+	SourceSum      string
+	DestinationSum string
+	Err            error
+}
+
+// Match reports whether both sides were read and have the same checksum
+func (c SampleCheck) Match() bool {
+	return c.Err == nil && c.SourceSum == c.DestinationSum
+}
+
+// CheckSample reads every sampled file on both sides and compares their checksums
+func CheckSample(ctx context.Context, sample []SampleEntry, src, dst object.Storage, algorithm string) ([]SampleCheck, error) {
+	if _, err := security.NewHash(algorithm); err != nil {
+		return nil, err
+	}
+	checks := make([]SampleCheck, 0, len(sample))
+	for _, e := range sample {
+		if err := ctx.Err(); err != nil {
+			return checks, err
+		}
+		c := SampleCheck{SampleEntry: e}
+		if c.SourceSum, c.Err = sampleSum(src, e.Source, algorithm); c.Err == nil {
+			c.DestinationSum, c.Err = sampleSum(dst, e.Destination, algorithm)
+		}
+		checks = append(checks, c)
+	}
+	return checks, nil
+}
+
+// sampleSum returns the hex checksum of the data of a key
+func sampleSum(s object.Storage, key, algorithm string) (string, error) {
+	fileInfo, err := s.Head(key)
+	if err != nil {
+		return "", err
+	}
+	h, err := security.NewHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	in, err := fileInfo.Get(0, -1)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	buf := object.GetBuffer()
+	defer object.PutBuffer(buf)
+	if _, err := io.CopyBuffer(h, in, *buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// PlannedCounts are the entries the migration plan expects in a wave
+type PlannedCounts struct {
+	Files int64
+	Dirs  int64
+	Bytes int64
+}
+
+// Checklist is the acceptance checklist of a migrated wave or job, the record
+// project managers use to track the cutover readiness of a share
+type Checklist struct {
+	JobID       string
+	Wave        string // 波次编号及名称，迁移整个源端时为空
+	Source      string
+	Destination string
+	StartTime   time.Time
+	EndTime     time.Time
+	Ready       bool // 没有失败的检查项
+	Items       []AcceptanceItem
+}
+
+// NewChecklist builds the acceptance checklist of a completed migration from
+// its result, the counts planned for the wave (nil when migrating the whole
+// source), the checksum comparison of the sampled files and the failures
+// still recorded in the failures ledger
+func NewChecklist(config *MigrateConfig, result *Result, wave string, planned *PlannedCounts, checks []SampleCheck) (*Checklist, error) {
+	failures, err := readFailures(config.FailureLedger)
+	if err != nil {
+		return nil, err
+	}
+	c := &Checklist{
+		JobID:       config.JobID,
+		Wave:        wave,
+		Source:      config.Source,
+		Destination: config.Destination,
+		StartTime:   config.StartTime,
+		EndTime:     time.Now(),
+	}
+	st := result.Stats
+
+	count := func(item string, expected, actual int64, format func(int64) string) {
+		it := AcceptanceItem{Section: SectionCounts, Item: item, Actual: format(actual), Result: AcceptancePass}
+		if planned != nil {
+			it.Expected = format(expected)
+			// 扫描后源端发生变化，需要确认
+			if expected != actual {
+				it.Result = AcceptanceReview
+			}
+		}
+		c.add(it)
+	}
+	itoa := func(n int64) string { return strconv.FormatInt(n, 10) }
+	var p PlannedCounts
+	if planned != nil {
+		p = *planned
+	}
+	count("Files", p.Files, st.Files, itoa)
+	count("Directories", p.Dirs, st.Dirs, itoa)
+	count("Bytes", p.Bytes, st.Bytes, scan.FormatFileSize)
+
+	c.add(AcceptanceItem{Section: SectionTransfer, Item: "Copied", Actual: fmt.Sprintf("%d (%s)", st.Copied, scan.FormatFileSize(st.CopiedBytes))})
+	c.add(AcceptanceItem{Section: SectionTransfer, Item: "Already at destination", Actual: fmt.Sprintf("%d (%s)", st.Skipped, scan.FormatFileSize(st.SkippedBytes))})
+	if result.Resumed > 0 {
+		c.add(AcceptanceItem{Section: SectionTransfer, Item: "Completed by previous runs", Actual: itoa(result.Resumed)})
+	}
+	if result.Updated > 0 {
+		c.add(AcceptanceItem{Section: SectionTransfer, Item: "Metadata updated", Actual: itoa(result.Updated)})
+	}
+	if result.Deleted > 0 {
+		c.add(AcceptanceItem{Section: SectionTransfer, Item: "Deleted from destination", Actual: itoa(result.Deleted)})
+	}
+
+	mismatches := 0
+	for _, check := range checks {
+		if !check.Match() {
+			mismatches++
+		}
+	}
+	sampled := AcceptanceItem{Section: SectionChecksum, Item: "Files sampled", Expected: strconv.Itoa(config.AcceptanceSample), Actual: strconv.Itoa(len(checks)), Result: AcceptancePass}
+	if len(checks) == 0 && st.Copied > 0 {
+		sampled.Result = AcceptanceReview
+	}
+	c.add(sampled)
+	c.add(AcceptanceItem{Section: SectionChecksum, Item: "Checksum mismatches", Expected: "0", Actual: strconv.Itoa(mismatches), Result: passIf(mismatches == 0)})
+	for _, check := range checks {
+		actual := check.DestinationSum
+		if check.Err != nil {
+			actual = check.Err.Error()
+		}
+		c.add(AcceptanceItem{Section: SectionChecksum, Item: check.Source, Expected: check.SourceSum, Actual: actual, Result: passIf(check.Match())})
+	}
+
+	byReason := make(map[string]int64)
+	for _, f := range failures {
+		byReason[f.Reason]++
+	}
+	unresolved := int64(0)
+	for reason, n := range byReason {
+		if failureResult(reason) == AcceptanceFail {
+			unresolved += n
+		}
+	}
+	c.add(AcceptanceItem{Section: SectionErrors, Item: "Unresolved errors", Expected: "0", Actual: itoa(unresolved), Result: passIf(unresolved == 0)})
+	reasons := make([]string, 0, len(byReason))
+	for reason := range byReason {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		c.add(AcceptanceItem{Section: SectionErrors, Item: "Reason " + reason, Actual: itoa(byReason[reason]), Result: failureResult(reason)})
+	}
+	for i, f := range failures {
+		if i == maxChecklistFailures {
+			c.add(AcceptanceItem{Section: SectionErrors, Item: fmt.Sprintf("%d more, see %s", len(failures)-i, config.FailureLedger)})
+			break
+		}
+		c.add(AcceptanceItem{Section: SectionErrors, Item: f.Source, Actual: f.Reason + ": " + f.Error, Result: failureResult(f.Reason)})
+	}
+
+	c.Ready = true
+	for _, it := range c.Items {
+		if it.Result == AcceptanceFail {
+			c.Ready = false
+		}
+	}
+	status := AcceptanceItem{Section: SectionSummary, Item: "Cutover readiness", Expected: "ready", Actual: "ready", Result: AcceptancePass}
+	if !c.Ready {
+		status.Actual, status.Result = "not ready", AcceptanceFail
+	}
+	summary := []AcceptanceItem{
+		{Section: SectionSummary, Item: "Job ID", Actual: c.JobID},
+		{Section: SectionSummary, Item: "Wave", Actual: c.Wave},
+		{Section: SectionSummary, Item: "Source", Actual: c.Source},
+		{Section: SectionSummary, Item: "Destination", Actual: c.Destination},
+		{Section: SectionSummary, Item: "Start time", Actual: c.StartTime.Format(time.RFC3339)},
+		{Section: SectionSummary, Item: "End time", Actual: c.EndTime.Format(time.RFC3339)},
+		status,
+	}
+	if c.Wave == "" {
+		summary = append(summary[:1], summary[2:]...)
+	}
+	c.Items = append(summary, c.Items...)
+	for _, role := range SignOffRoles {
+		c.add(AcceptanceItem{Section: SectionSignOff, Item: role, Expected: "name, date, signature"})
+	}
+	return c, nil
+}
+
+func (c *Checklist) add(it AcceptanceItem) {
+	c.Items = append(c.Items, it)
+}
+
+// Section returns the items of a section in checklist order
+func (c *Checklist) Section(section string) []AcceptanceItem {
+	var items []AcceptanceItem
+	for _, it := range c.Items {
+		if it.Section == section {
+			items = append(items, it)
+		}
+	}
+	return items
+}
+
+// Write writes the checklist as CSV, the sign-off rows are left blank to be
+// filled in by the reviewers
+func (c *Checklist) Write(w io.Writer) error {
+	report := csv.NewWriter(w)
+	_ = report.Write([]string{"section", "item", "expected", "actual", "result"})
+	for _, it := range c.Items {
+		_ = report.Write([]string{it.Section, it.Item, it.Expected, it.Actual, it.Result})
+	}
+	report.Flush()
+	return report.Error()
+}
+
+//go:embed templates/acceptance.html
+var htmlTemplates embed.FS
+
+// htmlChecklist is the printable acceptance checklist
+var htmlChecklist = template.Must(template.New("acceptance.html").Funcs(template.FuncMap{
+	"t":     i18n.T,
+	"time":  i18n.FormatTime,
+	"list":  func(items ...string) []string { return items },
+	"title": func(section string) string { return sectionTitles[section] },
+}).ParseFS(htmlTemplates, "templates/acceptance.html"))
+
+// WriteHTML writes the checklist as a self-contained HTML page
+func (c *Checklist) WriteHTML(w io.Writer) error {
+	return htmlChecklist.Execute(w, c)
+}
+
+// failureResult returns the checklist result of a failure reason: files copied
+// with a caveat need a review, the others were not migrated
+func failureResult(reason string) string {
+	switch reason {
+	case FailureUnstable, FailureAttrs:
+		return AcceptanceReview
+	}
+	return AcceptanceFail
+}
+
+func passIf(ok bool) string {
+	if ok {
+		return AcceptancePass
+	}
+	return AcceptanceFail
+}
+
+// ledgerFailure is a failure read back from the failures ledger
+type ledgerFailure struct {
+	Source string
+	Reason string
+	Error  string
+}
+
+// readFailures reads the failures ledger, which is removed when nothing failed
+func readFailures(path string) ([]ledgerFailure, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open failures file: %w", err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read failures file: %w", err)
+	}
+	var failures []ledgerFailure
+	// 列: time,source,destination,reason,attempts,error
+	for _, r := range records[min(1, len(records)):] {
+		if len(r) < 6 {
+			continue
+		}
+		failures = append(failures, ledgerFailure{Source: r[1], Reason: r[3], Error: r[5]})
+	}
+	return failures, nil
+}
@@ -0,0 +1,113 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/log"
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// TestSampler 测试抽样数量不超过上限且每个文件只出现一次
+func TestSampler(t *testing.T) {
+	s := newSampler(3)
+	for i := 0; i < 100; i++ {
+		s.Offer(SampleEntry{Source: "/" + strconv.Itoa(i)})
+	}
+	entries := s.Entries()
+	assert.Len(t, entries, 3)
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		assert.False(t, seen[e.Source])
+		seen[e.Source] = true
+	}
+
+	var none *sampler
+	none.Offer(SampleEntry{Source: "/a"})
+	assert.Nil(t, none.Entries())
+	assert.Nil(t, newSampler(0))
+}
+
+// TestChecklist 测试迁移波次后的验收清单: 数量、校验和抽检、未解决的错误及签字栏
+func TestChecklist(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	run := strconv.FormatInt(time.Now().UnixNano(), 36)
+	srcURI, dstURI := "mem://test-acceptance-src-"+run, "mem://test-acceptance-dst-"+run
+	src, err := object.CreateStorage(srcURI)
+	assert.NoError(t, err)
+	dst, err := object.CreateStorage(dstURI)
+	assert.NoError(t, err)
+	for _, key := range []string{"/hr/a.txt", "/hr/b.txt"} {
+		assert.NoError(t, src.Put(key, strings.NewReader("data")))
+	}
+
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "wave_1.csv")
+	assert.NoError(t, os.WriteFile(manifest, []byte("path,type,size,mtime\n"+
+		"/hr/a.txt,file,4,2024-01-01T00:00:00Z\n"+
+		"/hr/b.txt,file,4,2024-01-01T00:00:00Z\n"+
+		"/hr/gone.txt,file,10,2024-01-01T00:00:00Z\n"), 0644))
+
+	config := MigrateConfig{
+		Source:           srcURI,
+		Destination:      dstURI,
+		WaveManifest:     manifest,
+		FailureLedger:    filepath.Join(dir, "failures.csv"),
+		AcceptanceSample: 10,
+		StartTime:        time.Now(),
+	}
+	config.ApplyDefaults()
+	result, err := Migrate(context.Background(), &config, src, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, []SampleEntry{
+		{Source: "/hr/a.txt", Destination: "/hr/a.txt", Size: 4},
+		{Source: "/hr/b.txt", Destination: "/hr/b.txt", Size: 4},
+	}, result.Sample)
+
+	// 目标端被修改的文件校验和不一致
+	assert.NoError(t, dst.Put("/hr/b.txt", strings.NewReader("DATA")))
+	checks, err := CheckSample(context.Background(), result.Sample, src, dst, "sha256")
+	assert.NoError(t, err)
+	assert.True(t, checks[0].Match())
+	assert.False(t, checks[1].Match())
+	_, err = CheckSample(context.Background(), result.Sample, src, dst, "crc")
+	assert.Error(t, err)
+
+	planned := &PlannedCounts{Files: 3, Bytes: 18}
+	checklist, err := NewChecklist(&config, result, "1 pilot", planned, checks)
+	assert.NoError(t, err)
+	assert.False(t, checklist.Ready)
+
+	var csv bytes.Buffer
+	assert.NoError(t, checklist.Write(&csv))
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	for _, line := range []string{
+		"section,item,expected,actual,result",
+		"summary,Wave,,1 pilot,",
+		"summary,Cutover readiness,ready,not ready,fail",
+		"counts,Files,3,2,review",
+		"counts,Directories,0,0,pass",
+		"checksum,Files sampled,10,2,pass",
+		"checksum,Checksum mismatches,0,1,fail",
+		"errors,Unresolved errors,0,1,fail",
+		"errors,Reason missing,,1,fail",
+		"sign-off,Project manager,\"name, date, signature\",,",
+	} {
+		assert.Contains(t, lines, line)
+	}
+	assert.Contains(t, csv.String(), "checksum,/hr/a.txt,")
+
+	var html bytes.Buffer
+	assert.NoError(t, checklist.WriteHTML(&html))
+	assert.Contains(t, html.String(), "/hr/gone.txt")
+	assert.Contains(t, html.String(), "Project manager")
+}
@@ -0,0 +1,140 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Transfer states of the checkpoint
+const (
+	TransferPending  = "pending"   // 等待重试的传输，继续迁移时上次中断或失败的文件
+	TransferInFlight = "in-flight" // 正在拷贝
+	TransferDone     = "done"
+	TransferFailed   = "failed"
+)
+
+// Checkpoint persists the transfer state of every file of a migration in the
+// SQLite database of its job, so an interrupted migration can be resumed
+// without copying the completed files again. A nil Checkpoint records nothing.
+type Checkpoint struct {
+	db   *sql.DB
+	done map[string]struct{} // 继续迁移时上次已完成的源key
+}
+
+// OpenCheckpoint opens or creates the checkpoint database at path. Unless
+// resume is set the states of a previous run are cleared and the migration
+// starts over; when resuming, the files in flight or failed in the previous run
+// are set back to pending so they are copied again.
+func OpenCheckpoint(ctx context.Context, path string, resume bool) (*Checkpoint, error) {
+	sqldb, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+	// 拷贝协程并发写入，串行化避免SQLITE_BUSY
+	sqldb.SetMaxOpenConns(1)
+	c := &Checkpoint{db: sqldb, done: make(map[string]struct{})}
+	if err := c.init(ctx, resume); err != nil {
+		sqldb.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Checkpoint) init(ctx context.Context, resume bool) error {
+	if _, err := c.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS transfers (
+	key TEXT PRIMARY KEY,
+	destination TEXT NOT NULL DEFAULT '',
+	state TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	error TEXT NOT NULL DEFAULT '',
+	updated DATETIME
+)`); err != nil {
+		return fmt.Errorf("failed to create checkpoint table: %w", err)
+	}
+	if !resume {
+		if _, err := c.db.ExecContext(ctx, `DELETE FROM transfers`); err != nil {
+			return fmt.Errorf("failed to clear checkpoint: %w", err)
+		}
+		return nil
+	}
+
+	if _, err := c.db.ExecContext(ctx, `UPDATE transfers SET state = ? WHERE state IN (?, ?)`,
+		TransferPending, TransferInFlight, TransferFailed); err != nil {
+		return fmt.Errorf("failed to requeue interrupted transfers: %w", err)
+	}
+	rows, err := c.db.QueryContext(ctx, `SELECT key FROM transfers WHERE state = ?`, TransferDone)
+	if err != nil {
+		return fmt.Errorf("failed to load completed transfers: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return fmt.Errorf("failed to load completed transfers: %w", err)
+		}
+		c.done[key] = struct{}{}
+	}
+	return rows.Err()
+}
+
+// Close closes the checkpoint database
+func (c *Checkpoint) Close() error {
+	if c == nil {
+		return nil
+	}
+	return c.db.Close()
+}
+
+// Completed returns the number of files completed by previous runs
+func (c *Checkpoint) Completed() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.done)
+}
+
+// Done reports whether the file at source key was completed by a previous run
+func (c *Checkpoint) Done(key string) bool {
+	if c == nil {
+		return false
+	}
+	_, ok := c.done[key]
+	return ok
+}
+
+// Start records that the copy of the file at source key to destination begins
+func (c *Checkpoint) Start(key, destination string) error {
+	if c == nil {
+		return nil
+	}
+	_, err := c.db.Exec(`INSERT INTO transfers (key, destination, state, attempts, updated) VALUES (?, ?, ?, 1, ?)
+ON CONFLICT(key) DO UPDATE SET destination = excluded.destination, state = excluded.state, attempts = attempts + 1, updated = excluded.updated`,
+		key, destination, TransferInFlight, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint of %s: %w", key, err)
+	}
+	return nil
+}
+
+// Finish records the outcome of the file at source key, it failed when err is
+// not nil and is copied again by the next resume
+func (c *Checkpoint) Finish(key, destination string, err error) error {
+	if c == nil {
+		return nil
+	}
+	state, msg := TransferDone, ""
+	if err != nil {
+		state, msg = TransferFailed, err.Error()
+	}
+	if _, err := c.db.Exec(`INSERT INTO transfers (key, destination, state, attempts, error, updated) VALUES (?, ?, ?, 1, ?, ?)
+ON CONFLICT(key) DO UPDATE SET destination = excluded.destination, state = excluded.state, error = excluded.error, updated = excluded.updated`,
+		key, destination, state, msg, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to save checkpoint of %s: %w", key, err)
+	}
+	return nil
+}
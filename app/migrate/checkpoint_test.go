@@ -0,0 +1,63 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCheckpoint 测试继续迁移时跳过已完成的文件，重试中断及失败的文件
+func TestCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "checkpoint.db")
+
+	c, err := OpenCheckpoint(ctx, path, false)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Start("/done.txt", "/dst/done.txt"))
+	assert.NoError(t, c.Finish("/done.txt", "/dst/done.txt", nil))
+	assert.NoError(t, c.Start("/failed.txt", "/dst/failed.txt"))
+	assert.NoError(t, c.Finish("/failed.txt", "/dst/failed.txt", errors.New("broken pipe")))
+	assert.NoError(t, c.Start("/interrupted.txt", "/dst/interrupted.txt"))
+	assert.NoError(t, c.Close())
+
+	tests := []struct {
+		name      string
+		resume    bool
+		completed int
+		done      map[string]bool
+	}{
+		{
+			name:      "继续迁移",
+			resume:    true,
+			completed: 1,
+			done:      map[string]bool{"/done.txt": true, "/failed.txt": false, "/interrupted.txt": false},
+		},
+		{
+			name:      "重新开始时清除之前的状态",
+			resume:    false,
+			completed: 0,
+			done:      map[string]bool{"/done.txt": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := OpenCheckpoint(ctx, path, tt.resume)
+			assert.NoError(t, err)
+			defer c.Close()
+			assert.Equal(t, tt.completed, c.Completed())
+			for key, done := range tt.done {
+				assert.Equal(t, done, c.Done(key), key)
+			}
+		})
+	}
+
+	// 未启用检查点时不记录
+	var none *Checkpoint
+	assert.False(t, none.Done("/done.txt"))
+	assert.NoError(t, none.Start("/a", "/a"))
+	assert.NoError(t, none.Finish("/a", "/a", nil))
+	assert.NoError(t, none.Close())
+}
@@ -0,0 +1,315 @@
+package migrate
+
+import (
+	"fmt"
+	"terrasync/object"
+	"terrasync/pkg/stats"
+	"time"
+)
+
+const (
+	defaultListConcurrency    = 16
+	defaultCopyConcurrency    = 5
+	defaultLargeFileStreams   = 4
+	defaultLargeFileThreshold = 64 << 20 // 64MiB
+	defaultCapacityHeadroom   = 5        // 目标端保留5%的空闲空间
+	defaultStallTimeout       = 5 * time.Minute
+	defaultTransferRetries    = 2
+	defaultChangedRetries     = 2
+	defaultWarmAfter          = 30 * 24 * time.Hour
+	defaultColdAfter          = 180 * 24 * time.Hour
+	defaultInterlockThreshold = 1000
+	defaultDuplicateWindow    = 24 * time.Hour
+)
+
+// TempSuffix is appended to the key of a file while it is copied, the file is
+// renamed when complete. Leftovers of interrupted copies are removed by cleanup.
+const TempSuffix = ".terrasync.tmp"
+
+// MigrateConfig 迁移配置选项
+// Listing/stat, small file copy and large file streaming have very different
+// optimal concurrency, so each of them is configured separately.
+type MigrateConfig struct {
+	Source      string
+	Destination string
+	Match       []string // 只迁移匹配的条目
+	Exclude     []string // 不迁移匹配的条目
+	Overwrite   bool
+	// MetadataOnly 只对已拷贝的目标重新设置所有者、权限、ACL和时间，不传输数据
+	MetadataOnly bool
+	Preserve     Preserve // 拷贝后在目标端恢复的源端元数据，零值不恢复
+
+	ListConcurrency    int   // 列举源目录及stat的并发数
+	CopyConcurrency    int   // 小文件拷贝的并发数
+	LargeFileStreams   int   // 单个大文件拷贝时的并发流数
+	LargeFileThreshold int64 // 超过该大小的文件按大文件分段拷贝
+
+	DestTemplate  string   // 按元数据生成目标key的模板，如{{.Ext}}/{{.MTime.Year}}/{{.Key}}
+	Rewrite       []string // 目标路径重写规则，格式为'regex=>replacement'
+	RewriteReport string   // 重写路径映射报告(CSV)的保存路径
+	KeyTransform  KeyTransform
+
+	Preflight        PreflightAction // 目标容量不足时的处理: abort, warn 或 off
+	CapacityHeadroom int             // 目标端需要保留的空闲百分比，<0使用默认值
+
+	FileTimeout     time.Duration // 单文件传输时限，0表示不限制
+	StallTimeout    time.Duration // 传输没有数据进展的时限，超时后取消并重新排队，<0使用默认值
+	TransferRetries int           // 超时或卡住的传输重新排队的次数，<0使用默认值
+	FailureLedger   string        // 失败记录(CSV)的保存路径
+	ChangedRetries  int           // 源文件在拷贝期间变化时重新拷贝的次数，<0使用默认值
+	MaxFileSize     int64         // 超过该大小的文件被跳过并记录，0表示不限制
+	MaxDepth        int           // 超过该深度的条目被跳过并记录，0表示不限制
+
+	TagTemperature   bool             // 按源文件的访问/修改时间给目标对象打温度标签
+	TemperatureBasis TemperatureBasis // 计算温度使用的时间: atime 或 mtime
+	WarmAfter        time.Duration    // 超过该时间未使用为warm，0使用默认值
+	ColdAfter        time.Duration    // 超过该时间未使用为cold，0使用默认值
+
+	PropagateDeletes   bool  // 删除目标端有而源端没有的条目
+	Force              bool  // 覆盖及删除可能影响的文件数超过阈值时仍继续迁移
+	InterlockThreshold int64 // 覆盖与删除同时启用时，可能影响的文件数超过该值需要--force或确认，<0使用默认值
+
+	JobID           string             // 迁移任务ID，为空时按源和目标生成，写入目标端标记
+	DuplicateRun    DuplicateRunAction // 其他任务最近写过目标端时的处理: abort, warn 或 off
+	DuplicateWindow time.Duration      // 目标端标记在该时间内更新过视为其他任务仍在写入，0使用默认值
+	Checkpoint      string             // 保存每个文件传输状态的任务数据库(SQLite)路径，为空不保存
+	Resume          bool               // 继续中断的迁移，跳过检查点中已完成的文件并重试失败的文件
+
+	Reconcile       ReconcileAction // 迁移结束后按第一级目录比较源和目标的文件数及字节数: warn, fail 或 off
+	ReconcileReport string          // 比较结果(CSV)的保存路径，为空不生成
+
+	Prewarm    bool       // 拷贝前遍历源端并读取离线存根文件以触发回迁
+	StubReport string     // 存根文件报告(CSV)的保存路径，为空不生成
+	StubPolicy StubPolicy // 拷贝时离线存根的处理: recall, skip 或 copy-stub
+
+	WaveManifest string // 只迁移该迁移波次清单(terrasync plan生成)中的条目，为空迁移整个源端
+
+	AcceptanceSample int // 随机抽取该数量的已拷贝文件供验收清单比较校验和，0不抽样
+
+	Progress stats.ProgressConfig // 周期输出的机器可读进度记录，Writer为nil时不输出
+
+	CmdLine   string
+	StartTime time.Time
+}
+
+// ApplyDefaults fills unset concurrency settings with their defaults
+func (c *MigrateConfig) ApplyDefaults() {
+	if c.ListConcurrency <= 0 {
+		c.ListConcurrency = defaultListConcurrency
+	}
+	if c.CopyConcurrency <= 0 {
+		c.CopyConcurrency = defaultCopyConcurrency
+	}
+	if c.LargeFileStreams <= 0 {
+		c.LargeFileStreams = defaultLargeFileStreams
+	}
+	if c.LargeFileThreshold <= 0 {
+		c.LargeFileThreshold = defaultLargeFileThreshold
+	}
+	if c.Preflight == "" {
+		c.Preflight = PreflightAbort
+	}
+	if c.CapacityHeadroom < 0 {
+		c.CapacityHeadroom = defaultCapacityHeadroom
+	}
+	if c.StallTimeout < 0 {
+		c.StallTimeout = defaultStallTimeout
+	}
+	if c.TransferRetries < 0 {
+		c.TransferRetries = defaultTransferRetries
+	}
+	if c.ChangedRetries < 0 {
+		c.ChangedRetries = defaultChangedRetries
+	}
+	if c.TemperatureBasis == "" {
+		c.TemperatureBasis = BasisATime
+	}
+	if c.WarmAfter <= 0 {
+		c.WarmAfter = defaultWarmAfter
+	}
+	if c.ColdAfter <= 0 {
+		c.ColdAfter = defaultColdAfter
+	}
+	if c.InterlockThreshold < 0 {
+		c.InterlockThreshold = defaultInterlockThreshold
+	}
+	if c.JobID == "" {
+		c.JobID = DefaultJobID(c.Source, c.Destination)
+	}
+	if c.DuplicateRun == "" {
+		c.DuplicateRun = DuplicateRunAbort
+	}
+	if c.DuplicateWindow <= 0 {
+		c.DuplicateWindow = defaultDuplicateWindow
+	}
+	if c.Reconcile == "" {
+		c.Reconcile = ReconcileWarn
+	}
+	if c.StubPolicy == "" {
+		c.StubPolicy = StubRecall
+	}
+}
+
+// Validate checks the configuration for conflicting settings
+func (c *MigrateConfig) Validate() error {
+	if c.Source == "" || c.Destination == "" {
+		return fmt.Errorf("source and destination must be specified")
+	}
+	if c.Source == c.Destination {
+		return fmt.Errorf("source and destination must be different: %s", c.Source)
+	}
+	for _, uri := range []string{c.Source, c.Destination} {
+		if err := object.CheckImplemented(uri); err != nil {
+			return err
+		}
+	}
+	if c.MetadataOnly && c.Overwrite {
+		return fmt.Errorf("metadata-only cannot be combined with overwrite")
+	}
+	if c.Resume && c.Checkpoint == "" {
+		return fmt.Errorf("resume requires the checkpoint of the interrupted job")
+	}
+	// tar流没有可按路径stat的条目
+	if c.WaveManifest != "" && object.StorageType(c.Source) == "stream" {
+		return fmt.Errorf("wave cannot be combined with a stream source")
+	}
+	if c.MetadataOnly && c.PropagateDeletes {
+		return fmt.Errorf("metadata-only cannot be combined with propagate-deletes")
+	}
+	// 目标key被变换时无法判断目标条目是否仍存在于源端
+	if c.PropagateDeletes && (c.DestTemplate != "" || len(c.Rewrite) > 0 || c.KeyTransform.Enabled()) {
+		return fmt.Errorf("propagate-deletes cannot be combined with dest-template, rewrite or key transformations")
+	}
+	if c.DestTemplate != "" {
+		if _, err := ParseKeyTemplate(c.DestTemplate); err != nil {
+			return err
+		}
+	}
+	if _, err := ParseRewriteRules(c.Rewrite); err != nil {
+		return err
+	}
+	if c.KeyTransform.StripComponents < 0 {
+		return fmt.Errorf("strip components must not be negative: %d", c.KeyTransform.StripComponents)
+	}
+	if _, err := ParsePreflightAction(string(c.Preflight)); err != nil {
+		return err
+	}
+	if c.CapacityHeadroom > 100 {
+		return fmt.Errorf("capacity headroom must be a percentage: %d", c.CapacityHeadroom)
+	}
+	if _, err := ParseCollisionPolicy(string(c.KeyTransform.Collision)); err != nil {
+		return err
+	}
+	if c.FileTimeout < 0 {
+		return fmt.Errorf("file timeout must not be negative: %v", c.FileTimeout)
+	}
+	if c.MaxFileSize < 0 {
+		return fmt.Errorf("max file size must not be negative: %d", c.MaxFileSize)
+	}
+	if c.MaxDepth < 0 {
+		return fmt.Errorf("max depth must not be negative: %d", c.MaxDepth)
+	}
+	if _, err := ParseTemperatureBasis(string(c.TemperatureBasis)); err != nil {
+		return err
+	}
+	if _, err := ParseDuplicateRunAction(string(c.DuplicateRun)); err != nil {
+		return err
+	}
+	if _, err := ParseReconcileAction(string(c.Reconcile)); err != nil {
+		return err
+	}
+	if _, err := ParseStubPolicy(string(c.StubPolicy)); err != nil {
+		return err
+	}
+	if c.Prewarm && c.StubPolicy == StubCopy {
+		return fmt.Errorf("prewarm cannot be combined with stub policy %s", StubCopy)
+	}
+	if c.TagTemperature && c.WarmAfter >= c.ColdAfter {
+		return fmt.Errorf("warm-after (%v) must be shorter than cold-after (%v)", c.WarmAfter, c.ColdAfter)
+	}
+	return nil
+}
+
+// Watchdog returns the watchdog bounding single file transfers, recording failures
+// in ledger and counting copies and failures in st
+func (c *MigrateConfig) Watchdog(ledger *FailureLedger, st *stats.Stats) *Watchdog {
+	return &Watchdog{
+		FileTimeout:  c.FileTimeout,
+		StallTimeout: c.StallTimeout,
+		Retries:      c.TransferRetries,
+		Ledger:       ledger,
+		Stats:        st,
+	}
+}
+
+// ChangeDetector returns the detector re-copying files that change during the copy
+func (c *MigrateConfig) ChangeDetector(ledger *FailureLedger) *ChangeDetector {
+	return &ChangeDetector{Retries: c.ChangedRetries, Ledger: ledger}
+}
+
+// Guard returns the guard skipping outliers, recording them in ledger and counting
+// them in st, nil if no limit is set
+func (c *MigrateConfig) Guard(ledger *FailureLedger, st *stats.Stats) *Guard {
+	if c.MaxFileSize == 0 && c.MaxDepth == 0 {
+		return nil
+	}
+	return &Guard{MaxFileSize: c.MaxFileSize, MaxDepth: c.MaxDepth, Ledger: ledger, Stats: st}
+}
+
+// StubHandler returns the handler applying the stub policy to offline stubs of src,
+// recording skipped stubs in ledger and counting them in st, nil if stubs are copied as they are
+func (c *MigrateConfig) StubHandler(src object.Storage, ledger *FailureLedger, st *stats.Stats) *StubHandler {
+	if c.StubPolicy == StubCopy {
+		return nil
+	}
+	return &StubHandler{Policy: c.StubPolicy, Storage: src, Ledger: ledger, Stats: st}
+}
+
+// TemperatureTagger returns the tagger of migrated objects, nil if temperature tagging is disabled
+func (c *MigrateConfig) TemperatureTagger(now time.Time) *TemperatureTagger {
+	if !c.TagTemperature {
+		return nil
+	}
+	return &TemperatureTagger{Basis: c.TemperatureBasis, Warm: c.WarmAfter, Cold: c.ColdAfter, Now: now}
+}
+
+// String returns a one-line description of the migration settings
+func (c *MigrateConfig) String() string {
+	desc := fmt.Sprintf("list concurrency: %d, copy concurrency: %d, large file streams: %d (>= %d bytes), overwrite: %t, metadata only: %t, rewrite rules: %d",
+		c.ListConcurrency, c.CopyConcurrency, c.LargeFileStreams, c.LargeFileThreshold, c.Overwrite, c.MetadataOnly, len(c.Rewrite))
+	desc += fmt.Sprintf(", changed file retries: %d, preserve: %s", c.ChangedRetries, c.Preserve)
+	desc += fmt.Sprintf(", job id: %s, duplicate run: %s (window %v)", c.JobID, c.DuplicateRun, c.DuplicateWindow)
+	if c.Resume {
+		desc += ", resume: true"
+	}
+	if c.WaveManifest != "" {
+		desc += fmt.Sprintf(", wave manifest: %s", c.WaveManifest)
+	}
+	if c.AcceptanceSample > 0 {
+		desc += fmt.Sprintf(", acceptance sample: %d", c.AcceptanceSample)
+	}
+	if c.PropagateDeletes {
+		desc += fmt.Sprintf(", propagate deletes: true, interlock threshold: %d, force: %t", c.InterlockThreshold, c.Force)
+	}
+	if c.FileTimeout > 0 || c.StallTimeout > 0 {
+		desc += fmt.Sprintf(", file timeout: %v, stall timeout: %v, transfer retries: %d", c.FileTimeout, c.StallTimeout, c.TransferRetries)
+	}
+	if c.MaxFileSize > 0 || c.MaxDepth > 0 {
+		desc += fmt.Sprintf(", max file size: %d, max depth: %d", c.MaxFileSize, c.MaxDepth)
+	}
+	desc += fmt.Sprintf(", stub policy: %s", c.StubPolicy)
+	if c.Prewarm {
+		desc += ", prewarm: true"
+	}
+	if c.TagTemperature {
+		desc += fmt.Sprintf(", temperature tags: %s (warm after %v, cold after %v)", c.TemperatureBasis, c.WarmAfter, c.ColdAfter)
+	}
+	if c.DestTemplate != "" {
+		desc += fmt.Sprintf(", dest template: %q", c.DestTemplate)
+	}
+	if t := c.KeyTransform; t.Enabled() {
+		desc += fmt.Sprintf(", dest prefix: %q, strip components: %d, flatten: %t (collision: %s)",
+			t.DestPrefix, t.StripComponents, t.Flatten, t.Collision)
+	}
+	return desc
+}
@@ -0,0 +1,456 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"terrasync/app/scan"
+	"terrasync/log"
+	"terrasync/object"
+	"terrasync/pkg/stats"
+	"time"
+)
+
+// Result is the outcome of a migration
+type Result struct {
+	Stats     stats.Snapshot
+	Failed    int64         // 记录在失败文件中的条目数
+	Rewritten int64         // 被重写规则修改的目标路径数
+	Updated   int64         // 重新设置了元数据的条目数(--metadata-only)
+	Deleted   int64         // 源端已不存在而从目标端删除的条目数(--propagate-deletes)
+	Resumed   int64         // 之前的运行已完成而跳过的文件数(--resume)
+	Sample    []SampleEntry // 随机抽取的已拷贝文件，用于验收清单的校验和比较
+}
+
+// migrator copies the entries of the source to the destination
+type migrator struct {
+	config     *MigrateConfig
+	src, dst   object.Storage
+	stats      *stats.Stats
+	ledger     *FailureLedger
+	template   *KeyTemplate
+	rewriter   *Rewriter
+	mapper     *KeyMapper
+	guard      *Guard
+	stubs      *StubHandler
+	watchdog   *Watchdog
+	detector   *ChangeDetector
+	tagger     *TemperatureTagger
+	restorer   *MetadataRestorer
+	checkpoint *Checkpoint
+	sampler    *sampler
+	ranged     bool // 源端支持按范围读取，大文件以多个并发流读取
+	updated    atomic.Int64
+	resumed    atomic.Int64
+	deleted    int64
+}
+
+// Migrate lists the source and copies every entry passing the match and exclude
+// filters to the destination with CopyConcurrency workers. Destination keys are
+// built by the key template, the rewrite rules and the key transformations, in
+// this order. Existing destination files are skipped unless config.Overwrite is
+// set, and files of at least LargeFileThreshold bytes are read with
+// LargeFileStreams concurrent ranged reads. Entries that cannot be migrated are
+// recorded in the failures ledger and don't stop the migration, the ledger file
+// is removed when nothing failed. With config.MetadataOnly no data is copied,
+// the metadata of the already copied entries is re-applied instead. With
+// config.PropagateDeletes the destination entries missing from the source are
+// deleted once everything is copied. With config.Checkpoint the state of every
+// file transfer is saved in the job database, and with config.Resume the files
+// completed by a previous run are skipped. With config.WaveManifest only the
+// entries of the wave are migrated. The metadata selected by config.Preserve
+// is restored on the copied entries.
+func Migrate(parent context.Context, config *MigrateConfig, src, dst object.Storage) (*Result, error) {
+	matchConditions, err := scan.NewConditionFilter(config.Match)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create match conditions: %w", err)
+	}
+	excludeConditions, err := scan.NewConditionFilter(config.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exclude conditions: %w", err)
+	}
+	rules, err := ParseRewriteRules(config.Rewrite)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &migrator{
+		config: config,
+		src:    src,
+		dst:    dst,
+		stats:  stats.New(),
+		mapper: NewKeyMapper(config.KeyTransform),
+		// tar流只能顺序读取
+		ranged: object.StorageType(config.Source) != "stream",
+	}
+	progress := stats.StartProgress(config.Progress, m.stats)
+	defer progress.Stop()
+	if config.DestTemplate != "" {
+		if m.template, err = ParseKeyTemplate(config.DestTemplate); err != nil {
+			return nil, err
+		}
+	}
+
+	var rewriteReport, failures io.Writer
+	if len(rules) > 0 && config.RewriteReport != "" {
+		f, err := os.Create(config.RewriteReport)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create rewrite report: %w", err)
+		}
+		defer f.Close()
+		rewriteReport = f
+	}
+	if config.FailureLedger != "" {
+		f, err := os.Create(config.FailureLedger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create failures file: %w", err)
+		}
+		defer f.Close()
+		failures = f
+	}
+	m.rewriter = NewRewriter(rules, rewriteReport)
+	m.ledger = NewFailureLedger(failures)
+
+	now := config.StartTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+	m.guard = config.Guard(m.ledger, m.stats)
+	m.stubs = config.StubHandler(src, m.ledger, m.stats)
+	m.watchdog = config.Watchdog(m.ledger, m.stats)
+	m.detector = config.ChangeDetector(m.ledger)
+	m.tagger = config.TemperatureTagger(now)
+	m.restorer = config.MetadataRestorer(dst, m.ledger)
+	// tar流无法再次读取比较
+	if object.StorageType(config.Source) != "stream" && object.StorageType(config.Destination) != "stream" {
+		m.sampler = newSampler(config.AcceptanceSample)
+	}
+	if config.Checkpoint != "" {
+		if m.checkpoint, err = OpenCheckpoint(parent, config.Checkpoint, config.Resume); err != nil {
+			return nil, err
+		}
+		defer m.checkpoint.Close()
+		if config.Resume {
+			log.Infof("Resume job %s, %d files completed by previous runs", config.JobID, m.checkpoint.Completed())
+		}
+	}
+
+	opts := scan.ListOptions{Concurrency: config.ListConcurrency, Match: matchConditions, Exclude: excludeConditions}
+	// 超过最大深度的第一层条目由guard跳过并记录，更深的条目不再列举
+	if config.MaxDepth > 0 {
+		opts.Depth = config.MaxDepth + 1
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	entries := config.listSource(ctx, src, opts, m.missingFromWave)
+	var fatalOnce sync.Once
+	var fatal error
+	var wg sync.WaitGroup
+	for i := 0; i < config.CopyConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fileInfo := range entries {
+				if err := m.migrate(ctx, fileInfo); err != nil {
+					fatalOnce.Do(func() {
+						fatal = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	// tar流目标无法列举
+	if fatal == nil && ctx.Err() == nil && config.PropagateDeletes && object.StorageType(config.Destination) != "stream" {
+		m.propagateDeletes(ctx)
+	}
+	// 删除条目同样会修改目录的mtime
+	m.restorer.Finish()
+
+	if err := m.rewriter.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write rewrite report: %w", err)
+	}
+	if err := m.ledger.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write failures file: %w", err)
+	}
+	result := &Result{
+		Stats:     m.stats.Snapshot(),
+		Failed:    m.ledger.Failed(),
+		Rewritten: m.rewriter.Rewritten(),
+		Updated:   m.updated.Load(),
+		Deleted:   m.deleted,
+		Resumed:   m.resumed.Load(),
+		Sample:    m.sampler.Entries(),
+	}
+	if result.Failed == 0 && config.FailureLedger != "" {
+		_ = os.Remove(config.FailureLedger)
+	}
+	if fatal != nil {
+		return result, fatal
+	}
+	return result, parent.Err()
+}
+
+// migrate migrates one source entry. Failures of the entry are recorded in the
+// ledger, only errors that stop the migration are returned.
+func (m *migrator) migrate(ctx context.Context, fileInfo object.FileInfo) error {
+	if ctx.Err() != nil || fileInfo.Key() == MarkerKey {
+		return nil
+	}
+	if fileInfo.IsDir() {
+		m.stats.AddDir()
+		// 模板按文件生成key，目录随文件创建
+		if m.template != nil {
+			return nil
+		}
+	} else {
+		m.stats.AddFile(fileInfo.Size())
+	}
+
+	key := fileInfo.Key()
+	var err error
+	if m.template != nil {
+		if key, err = m.template.Execute(fileInfo); err != nil {
+			m.fail(fileInfo, "", err)
+			return nil
+		}
+	}
+	if key, err = m.rewriter.Rewrite(key); err != nil {
+		m.fail(fileInfo, "", err)
+		return nil
+	}
+	key, ok, err := m.mapper.Map(key, fileInfo.IsDir())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	if key == MarkerKey {
+		log.Warnf("Skip %s: %s is reserved for the job marker", fileInfo.Key(), key)
+		m.stats.AddSkipped(fileInfo.Size())
+		return nil
+	}
+	if !m.guard.Allow(fileInfo, key) {
+		return nil
+	}
+	if !fileInfo.IsDir() && m.checkpoint.Done(fileInfo.Key()) {
+		log.Debugf("Skip %s: completed by a previous run", fileInfo.Key())
+		m.stats.AddSkipped(fileInfo.Size())
+		m.resumed.Add(1)
+		return nil
+	}
+
+	if m.config.MetadataOnly {
+		m.syncMetadata(fileInfo, key)
+		return nil
+	}
+	if fileInfo.IsDir() {
+		m.mkdir(fileInfo, key)
+		return nil
+	}
+	if !fileInfo.IsRegular() {
+		// 存储接口无法创建符号链接及特殊文件
+		log.Warnf("Skip %s: not a regular file", fileInfo.Key())
+		m.stats.AddSkipped(0)
+		return nil
+	}
+	if !m.config.Overwrite {
+		existing, err := m.dst.Head(key)
+		if err != nil {
+			m.fail(fileInfo, key, fmt.Errorf("failed to stat destination: %w", err))
+			return nil
+		}
+		if existing != nil {
+			log.Debugf("Skip %s: %s exists on the destination", fileInfo.Key(), key)
+			m.stats.AddSkipped(fileInfo.Size())
+			return nil
+		}
+	}
+	if fileInfo, ok = m.stubs.Allow(ctx, fileInfo, key); !ok {
+		return nil
+	}
+
+	m.saveCheckpoint(m.checkpoint.Start(fileInfo.Key(), key))
+	err = m.watchdog.Run(ctx, fileInfo.Key(), key, func(ctx context.Context, track func(io.Reader) io.Reader) error {
+		_, err := m.detector.Copy(ctx, m.src, fileInfo, key, func(ctx context.Context, src object.FileInfo) error {
+			return m.put(ctx, src, key, track)
+		})
+		return err
+	})
+	m.saveCheckpoint(m.checkpoint.Finish(fileInfo.Key(), key, err))
+	// 失败已由watchdog记录
+	if err != nil {
+		return nil
+	}
+	m.sampler.Offer(SampleEntry{Source: fileInfo.Key(), Destination: key, Size: fileInfo.Size()})
+	m.restorer.File(fileInfo, key)
+	if err := m.tagger.Tag(m.dst, key, fileInfo); err != nil {
+		log.Warnf("Copied %s without tags: %v", key, err)
+		m.ledger.Record(Failure{Source: fileInfo.Key(), Destination: key, Reason: FailureError, Attempts: 1, Err: err})
+	}
+	return nil
+}
+
+// put copies the data of src to key, the data is read through track
+func (m *migrator) put(ctx context.Context, src object.FileInfo, key string, track func(io.Reader) io.Reader) error {
+	if w, ok := m.dst.(object.EntryWriter); ok {
+		return w.PutEntry(key, &trackedFile{FileInfo: src, track: track})
+	}
+	var in io.ReadCloser
+	if m.ranged && m.config.LargeFileStreams > 1 && src.Size() >= m.config.LargeFileThreshold {
+		in = newRangeReader(ctx, src, m.config.LargeFileStreams)
+	} else {
+		var err error
+		if in, err = src.Get(0, -1); err != nil {
+			return err
+		}
+	}
+	defer in.Close()
+	return m.dst.Put(key, track(in))
+}
+
+// mkdir creates the destination directory of a source directory, so empty
+// directories are migrated too
+func (m *migrator) mkdir(fileInfo object.FileInfo, key string) {
+	var err error
+	if w, ok := m.dst.(object.EntryWriter); ok {
+		err = w.PutEntry(key, fileInfo)
+	} else {
+		err = m.dst.Put(strings.TrimSuffix(key, "/")+"/", nil)
+	}
+	if err != nil {
+		m.fail(fileInfo, key, fmt.Errorf("failed to create directory: %w", err))
+		return
+	}
+	m.restorer.Dir(fileInfo, key)
+}
+
+// syncMetadata re-applies the metadata of an entry to its already copied destination
+func (m *migrator) syncMetadata(fileInfo object.FileInfo, key string) {
+	changed, err := SyncMetadata(fileInfo, m.dst, key)
+	var lost *object.AttrsNotPreservedError
+	switch {
+	case errors.Is(err, ErrDestinationMissing):
+		log.Warnf("Skip %s: %v", fileInfo.Key(), err)
+		if fileInfo.IsDir() {
+			m.stats.AddSkipped(0)
+		} else {
+			m.stats.AddSkipped(fileInfo.Size())
+		}
+		return
+	case errors.As(err, &lost):
+		log.Warnf("Metadata of %s partially applied: %v", key, err)
+		m.ledger.Record(Failure{Source: fileInfo.Key(), Destination: key, Reason: FailureAttrs, Attempts: 1, Err: err})
+	case err != nil:
+		m.fail(fileInfo, key, fmt.Errorf("failed to set metadata: %w", err))
+		return
+	}
+	if len(changed) > 0 {
+		m.updated.Add(1)
+		log.Debugf("Updated %s of %s", strings.Join(changed, ", "), key)
+	}
+}
+
+// propagateDeletes deletes the destination entries that no longer exist in the
+// source. Destination keys are the source keys, Validate rejects transformations.
+// Entries are deleted after listing, children before their directories.
+func (m *migrator) propagateDeletes(ctx context.Context) {
+	var mu sync.Mutex
+	var stale []object.FileInfo
+	entries := scan.ListAll(ctx, m.dst, scan.ListOptions{Concurrency: m.config.ListConcurrency})
+	var wg sync.WaitGroup
+	for i := 0; i < m.config.CopyConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fileInfo := range entries {
+				if fileInfo.Key() == MarkerKey {
+					continue
+				}
+				srcInfo, err := m.src.Head(fileInfo.Key())
+				if err != nil {
+					log.Warnf("Keep %s: failed to stat source: %v", fileInfo.Key(), err)
+					continue
+				}
+				if srcInfo == nil {
+					mu.Lock()
+					stale = append(stale, fileInfo)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	// 逆序排列时目录中的条目排在目录之前
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Key() > stale[j].Key() })
+	for _, fileInfo := range stale {
+		if err := m.dst.Delete(fileInfo.Key()); err != nil {
+			log.Errorf("Failed to delete %s: %v", fileInfo.Key(), err)
+			m.stats.AddError()
+			m.ledger.Record(Failure{Destination: fileInfo.Key(), Reason: FailureError, Attempts: 1, Err: fmt.Errorf("failed to delete: %w", err)})
+			continue
+		}
+		log.Infof("Deleted %s, no longer in the source", fileInfo.Key())
+		m.deleted++
+	}
+}
+
+// fail records an entry that could not be migrated
+func (m *migrator) fail(fileInfo object.FileInfo, dst string, err error) {
+	log.Errorf("Failed to migrate %s: %v", fileInfo.Key(), err)
+	m.stats.AddError()
+	m.ledger.Record(Failure{Source: fileInfo.Key(), Destination: dst, Reason: FailureError, Attempts: 1, Err: err})
+	if !fileInfo.IsDir() {
+		m.saveCheckpoint(m.checkpoint.Finish(fileInfo.Key(), dst, err))
+	}
+}
+
+// saveCheckpoint logs a failure to save the transfer state, the file itself is
+// migrated and is at worst copied again by a resume
+func (m *migrator) saveCheckpoint(err error) {
+	if err != nil {
+		log.Warnf("%v", err)
+	}
+}
+
+// trackedFile reads the data of a file through the progress tracking of the
+// watchdog, for destinations writing the file themselves
+type trackedFile struct {
+	object.FileInfo
+	track func(io.Reader) io.Reader
+}
+
+func (f *trackedFile) Get(offset, limit int64) (io.ReadCloser, error) {
+	in, err := f.FileInfo.Get(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return readCloser{Reader: f.track(in), Closer: in}, nil
+}
+
+// Owner returns the owner of the file, tar streams keep it
+func (f *trackedFile) Owner() (uid, gid int, ok bool) {
+	if owner, ok := f.FileInfo.(object.OwnerProvider); ok {
+		return owner.Owner()
+	}
+	return -1, -1, false
+}
+
+// readCloser reads from Reader and closes Closer
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,256 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/log"
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// readKey returns the content of key, "" if it does not exist
+func readKey(t *testing.T, s object.Storage, key string) string {
+	fileInfo, err := s.Head(key)
+	if !assert.NoError(t, err) || fileInfo == nil {
+		return ""
+	}
+	in, err := fileInfo.Get(0, -1)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	defer in.Close()
+	data, err := io.ReadAll(in)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+// TestMigrate 测试拷贝源端条目、跳过已存在的文件、过滤及目标key变换
+func TestMigrate(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	large := make([]byte, 5*object.BufferPoolSize/2)
+	rand.New(rand.NewSource(1)).Read(large)
+
+	tests := []struct {
+		name    string
+		config  MigrateConfig
+		want    map[string]string // 目标key -> 内容
+		missing []string
+		copied  int64
+		skipped int64
+		failed  int64
+		deleted int64
+		err     string
+	}{
+		{
+			name:    "默认跳过已存在的文件",
+			config:  MigrateConfig{},
+			want:    map[string]string{"/a/1.txt": "old", "/a/b/2.log": "two", "/big.bin": string(large)},
+			copied:  2,
+			skipped: 1,
+		},
+		{
+			name:    "覆盖已存在的文件",
+			config:  MigrateConfig{Overwrite: true},
+			want:    map[string]string{"/a/1.txt": "one", "/a/b/2.log": "two"},
+			copied:  3,
+			skipped: 0,
+		},
+		{
+			name:    "排除匹配的条目",
+			config:  MigrateConfig{Overwrite: true, Exclude: []string{"name like '%.log'"}},
+			want:    map[string]string{"/a/1.txt": "one"},
+			missing: []string{"/a/b/2.log"},
+			copied:  2,
+		},
+		{
+			name:    "目标前缀",
+			config:  MigrateConfig{KeyTransform: KeyTransform{DestPrefix: "/archive"}},
+			want:    map[string]string{"/archive/a/1.txt": "one", "/archive/a/b/2.log": "two", "/a/1.txt": "old"},
+			missing: []string{"/a/b/2.log"},
+			copied:  3,
+		},
+		{
+			name:    "删除源端已不存在的条目",
+			config:  MigrateConfig{Overwrite: true, PropagateDeletes: true},
+			want:    map[string]string{"/a/1.txt": "one", "/big.bin": string(large)},
+			missing: []string{"/stale/x.txt", "/stale"},
+			copied:  3,
+			deleted: 2,
+		},
+		{
+			name:   "重写为空路径的文件记录为失败",
+			config: MigrateConfig{Overwrite: true, Rewrite: []string{`^/a/1\.txt$=>`}},
+			want:   map[string]string{"/a/1.txt": "old", "/a/b/2.log": "two"},
+			copied: 2,
+			failed: 1,
+		},
+		{
+			name:   "打平冲突时停止迁移",
+			config: MigrateConfig{Overwrite: true, Match: []string{"type==file"}, KeyTransform: KeyTransform{Flatten: true, Collision: CollisionFail}},
+			err:    "flatten collision",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 内存存储按名称共享，每次运行使用新的存储
+			run := strconv.FormatInt(time.Now().UnixNano(), 36)
+			srcURI := "mem://test-migrate-src-" + run
+			dstURI := "mem://test-migrate-dst-" + run
+			src, err := object.CreateStorage(srcURI)
+			assert.NoError(t, err)
+			dst, err := object.CreateStorage(dstURI)
+			assert.NoError(t, err)
+			for key, data := range map[string]string{"/a/1.txt": "one", "/a/b/2.log": "two", "/big.bin": string(large)} {
+				assert.NoError(t, src.Put(key, strings.NewReader(data)))
+			}
+			assert.NoError(t, src.Put("/empty/", nil))
+			assert.NoError(t, dst.Put("/a/1.txt", strings.NewReader("old")))
+			assert.NoError(t, dst.Put("/stale/x.txt", strings.NewReader("x")))
+			if tt.config.KeyTransform.Flatten {
+				assert.NoError(t, src.Put("/c/2.log", strings.NewReader("dup")))
+			}
+
+			config := tt.config
+			config.Source, config.Destination = srcURI, dstURI
+			config.LargeFileThreshold = int64(object.BufferPoolSize)
+			config.LargeFileStreams = 3
+			config.FailureLedger = filepath.Join(t.TempDir(), "failures.csv")
+			config.ApplyDefaults()
+
+			result, err := Migrate(context.Background(), &config, src, dst)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			for key, data := range tt.want {
+				assert.Equal(t, data, readKey(t, dst, key), key)
+			}
+			for _, key := range tt.missing {
+				fileInfo, err := dst.Head(key)
+				assert.NoError(t, err)
+				assert.Nil(t, fileInfo, key)
+			}
+			assert.Equal(t, tt.copied, result.Stats.Copied)
+			assert.Equal(t, tt.skipped, result.Stats.Skipped)
+			assert.Equal(t, tt.failed, result.Failed)
+			assert.Equal(t, tt.deleted, result.Deleted)
+
+			// 空目录同样被迁移，没有失败时不保留失败文件
+			if !config.KeyTransform.Enabled() {
+				empty, err := dst.Head("/empty")
+				assert.NoError(t, err)
+				assert.True(t, empty != nil && empty.IsDir())
+			}
+			_, err = os.Stat(config.FailureLedger)
+			assert.Equal(t, tt.failed == 0, os.IsNotExist(err))
+		})
+	}
+}
+
+// TestMigrateMetadataOnly 测试只重新设置已拷贝文件的元数据
+func TestMigrateMetadataOnly(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	run := strconv.FormatInt(time.Now().UnixNano(), 36)
+	srcURI, dstURI := "mem://test-migrate-meta-src-"+run, "mem://test-migrate-meta-dst-"+run
+	src, err := object.CreateStorage(srcURI)
+	assert.NoError(t, err)
+	dst, err := object.CreateStorage(dstURI)
+	assert.NoError(t, err)
+	assert.NoError(t, src.Put("/a.txt", strings.NewReader("a")))
+	assert.NoError(t, src.Put("/missing.txt", strings.NewReader("m")))
+	assert.NoError(t, dst.Put("/a.txt", strings.NewReader("a")))
+	srcInfo, err := src.Head("/a.txt")
+	assert.NoError(t, err)
+	meta, err := object.MetadataOf(srcInfo)
+	assert.NoError(t, err)
+	meta.Perm = 0600
+	assert.NoError(t, src.(object.MetadataSetter).SetMetadata("/a.txt", meta))
+
+	config := MigrateConfig{Source: srcURI, Destination: dstURI, MetadataOnly: true}
+	config.ApplyDefaults()
+	result, err := Migrate(context.Background(), &config, src, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.Updated)
+	assert.Equal(t, int64(0), result.Stats.Copied)
+	assert.Equal(t, int64(1), result.Stats.Skipped)
+	dstInfo, err := dst.Head("/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), dstInfo.Perm())
+	missing, err := dst.Head("/missing.txt")
+	assert.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+// TestMigrateResume 测试继续中断的迁移时跳过已完成的文件
+func TestMigrateResume(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	run := strconv.FormatInt(time.Now().UnixNano(), 36)
+	srcURI, dstURI := "mem://test-migrate-resume-src-"+run, "mem://test-migrate-resume-dst-"+run
+	src, err := object.CreateStorage(srcURI)
+	assert.NoError(t, err)
+	dst, err := object.CreateStorage(dstURI)
+	assert.NoError(t, err)
+	for _, key := range []string{"/a.txt", "/b.txt", "/c.txt"} {
+		assert.NoError(t, src.Put(key, strings.NewReader("new")))
+	}
+
+	config := MigrateConfig{Source: srcURI, Destination: dstURI, Overwrite: true, Checkpoint: filepath.Join(t.TempDir(), "checkpoint.db")}
+	config.ApplyDefaults()
+	result, err := Migrate(context.Background(), &config, src, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), result.Stats.Copied)
+
+	// 上次运行中/b.txt未完成
+	c, err := OpenCheckpoint(context.Background(), config.Checkpoint, true)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Start("/b.txt", "/b.txt"))
+	assert.NoError(t, c.Close())
+	for _, key := range []string{"/a.txt", "/b.txt"} {
+		assert.NoError(t, dst.Put(key, strings.NewReader("old")))
+	}
+
+	config.Resume = true
+	result, err = Migrate(context.Background(), &config, src, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.Stats.Copied)
+	assert.Equal(t, int64(2), result.Resumed)
+	assert.Equal(t, "old", readKey(t, dst, "/a.txt"))
+	assert.Equal(t, "new", readKey(t, dst, "/b.txt"))
+}
+
+// TestRangeReader 测试按范围并发读取大文件并按顺序返回数据
+func TestRangeReader(t *testing.T) {
+	s, err := object.CreateStorage("mem://test-range-reader")
+	assert.NoError(t, err)
+	data := make([]byte, 5*object.BufferPoolSize/2)
+	rand.New(rand.NewSource(2)).Read(data)
+	assert.NoError(t, s.Put("/big.bin", bytes.NewReader(data)))
+	fileInfo, err := s.Head("/big.bin")
+	assert.NoError(t, err)
+
+	for _, streams := range []int{2, 4} {
+		r := newRangeReader(context.Background(), fileInfo, streams)
+		got, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.True(t, bytes.Equal(data, got), "streams %d", streams)
+		assert.NoError(t, r.Close())
+	}
+
+	// 提前关闭时不阻塞
+	r := newRangeReader(context.Background(), fileInfo, 4)
+	_, err = r.Read(make([]byte, 10))
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+}
@@ -0,0 +1,56 @@
+package migrate
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"terrasync/log"
+	"terrasync/object"
+	"terrasync/pkg/stats"
+)
+
+// Guard skips outliers that would blow the destination quota, such as runaway
+// log files or recursively exploded directories. Skipped entries are recorded in
+// the failures ledger as FailureTooLarge or FailureTooDeep and counted as skipped
+// in Stats. A nil Guard allows everything.
+type Guard struct {
+	MaxFileSize int64 // 超过该大小的文件被跳过，0表示不限制
+	MaxDepth    int   // 超过该深度的条目被跳过，根目录下的条目深度为1，0表示不限制
+	Ledger      *FailureLedger
+	Stats       *stats.Stats
+}
+
+// Allow reports whether src is migrated to dst. A skipped directory must not be
+// descended into, its entries are not recorded separately.
+func (g *Guard) Allow(src object.FileInfo, dst string) bool {
+	if g == nil {
+		return true
+	}
+	var reason string
+	var err error
+	if depth := keyDepth(src.Key()); g.MaxDepth > 0 && depth > g.MaxDepth {
+		reason, err = FailureTooDeep, fmt.Errorf("depth %d exceeds max depth %d", depth, g.MaxDepth)
+	} else if g.MaxFileSize > 0 && !src.IsDir() && src.Size() > g.MaxFileSize {
+		reason, err = FailureTooLarge, fmt.Errorf("size %d exceeds max file size %d", src.Size(), g.MaxFileSize)
+	} else {
+		return true
+	}
+
+	log.Warnf("Skip %s: %v", src.Key(), err)
+	if src.IsDir() {
+		g.Stats.AddSkipped(0)
+	} else {
+		g.Stats.AddSkipped(src.Size())
+	}
+	g.Ledger.Record(Failure{Source: src.Key(), Destination: dst, Reason: reason, Err: err})
+	return false
+}
+
+// keyDepth returns the number of path components of key
+func keyDepth(key string) int {
+	key = strings.Trim(filepath.ToSlash(key), "/")
+	if key == "" {
+		return 0
+	}
+	return strings.Count(key, "/") + 1
+}
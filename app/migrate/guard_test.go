@@ -0,0 +1,88 @@
+package migrate
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"terrasync/object"
+	"terrasync/pkg/stats"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGuard 测试跳过超大文件和过深的条目
+func TestGuard(t *testing.T) {
+	storage, err := object.CreateStorage("mem://guard-test")
+	assert.NoError(t, err)
+	for key, size := range map[string]int{
+		"/app.log":         100,
+		"/small.txt":       10,
+		"/a/b/c/deep.txt":  1,
+		"/a/b/ok.txt":      1,
+		"/a/b/c/d/e/f.txt": 1,
+	} {
+		assert.NoError(t, storage.Put(key, strings.NewReader(strings.Repeat("x", size))))
+	}
+
+	cases := []struct {
+		name   string
+		key    string
+		allow  bool
+		reason string
+	}{
+		{name: "超大文件", key: "/app.log", allow: false, reason: FailureTooLarge},
+		{name: "普通文件", key: "/small.txt", allow: true},
+		{name: "深度刚好达到上限", key: "/a/b/ok.txt", allow: true},
+		{name: "目录深度达到上限", key: "/a/b/c", allow: true},
+		{name: "超过深度的文件", key: "/a/b/c/deep.txt", allow: false, reason: FailureTooDeep},
+		{name: "超过深度的目录", key: "/a/b/c/d", allow: false, reason: FailureTooDeep},
+	}
+
+	var report bytes.Buffer
+	config := MigrateConfig{MaxFileSize: 50, MaxDepth: 3}
+	guard := config.Guard(NewFailureLedger(&report), stats.New())
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fi, err := storage.Head(c.key)
+			assert.NoError(t, err)
+			assert.Equal(t, c.allow, guard.Allow(fi, "/dst"+c.key))
+		})
+	}
+
+	snap := guard.Stats.Snapshot()
+	assert.Equal(t, int64(3), snap.Skipped)
+	assert.Equal(t, int64(101), snap.SkippedBytes)
+
+	assert.NoError(t, guard.Ledger.Flush())
+	records, err := csv.NewReader(&report).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 4)
+	for i, c := range []struct{ key, reason string }{
+		{"/app.log", FailureTooLarge}, {"/a/b/c/deep.txt", FailureTooDeep}, {"/a/b/c/d", FailureTooDeep},
+	} {
+		assert.Equal(t, []string{c.key, "/dst" + c.key, c.reason}, records[i+1][1:4])
+	}
+}
+
+// TestGuardDisabled 测试未设置上限时不跳过
+func TestGuardDisabled(t *testing.T) {
+	config := MigrateConfig{}
+	guard := config.Guard(nil, nil)
+	assert.Nil(t, guard)
+
+	storage, err := object.CreateStorage("mem://guard-test-disabled")
+	assert.NoError(t, err)
+	assert.NoError(t, storage.Put("/a/b/c/d/e/f.txt", strings.NewReader(strings.Repeat("x", 1<<20))))
+	fi, err := storage.Head("/a/b/c/d/e/f.txt")
+	assert.NoError(t, err)
+	assert.True(t, guard.Allow(fi, "/a/b/c/d/e/f.txt"))
+
+	config = MigrateConfig{Source: "/a", Destination: "/b", MaxDepth: -1}
+	assert.Error(t, config.Validate())
+
+	// 未实现的目标存储在迁移前被拒绝
+	config = MigrateConfig{Source: "/a", Destination: "nfs://filer01/export"}
+	assert.ErrorIs(t, config.Validate(), object.ErrNotImplemented)
+}
@@ -0,0 +1,105 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"terrasync/app/scan"
+	"terrasync/audit"
+	"terrasync/object"
+)
+
+// ErrInterlock is returned when a destructive migration is refused by the safety interlock
+var ErrInterlock = errors.New("destructive migration refused by the safety interlock")
+
+// InterlockDecision is the outcome of the safety interlock
+type InterlockDecision string
+
+const (
+	// InterlockNotRequired means the migration is not destructive enough to need a confirmation
+	InterlockNotRequired InterlockDecision = "not-required"
+	// InterlockForced means the operator passed --force
+	InterlockForced InterlockDecision = "forced"
+	// InterlockConfirmed means the operator typed the confirmation
+	InterlockConfirmed InterlockDecision = "confirmed"
+	// InterlockRefused means the migration is stopped before anything is copied
+	InterlockRefused InterlockDecision = "refused"
+)
+
+// InterlockReport is the outcome of the safety interlock
+type InterlockReport struct {
+	AtRisk    int64 // 目标端可能被覆盖或删除的文件数
+	Threshold int64
+	Decision  InterlockDecision
+}
+
+func (r InterlockReport) String() string {
+	return fmt.Sprintf("%d destination files may be overwritten or deleted, threshold %d, decision: %s", r.AtRisk, r.Threshold, r.Decision)
+}
+
+// Confirm asks the operator to confirm a destructive migration. It returns an
+// error when no operator can answer, e.g. stdin is not a terminal.
+type Confirm func(report InterlockReport) (bool, error)
+
+// CheckInterlock stops a migration combining overwrite with propagated deletes
+// when more than config.InterlockThreshold files already on the destination
+// could be replaced or removed, unless config.Force is set or the operator
+// confirms. Every file on the destination is at risk: it is either overwritten
+// by a source file or deleted as missing from the source. The decision is
+// recorded in the audit log.
+func CheckInterlock(ctx context.Context, config *MigrateConfig, dst object.Storage, confirm Confirm, cmdLine string) (InterlockReport, error) {
+	report := InterlockReport{Threshold: config.InterlockThreshold, Decision: InterlockNotRequired}
+	// tar流目标没有已存在的文件
+	if !config.Overwrite || !config.PropagateDeletes || object.StorageType(config.Destination) == "stream" {
+		return report, nil
+	}
+
+	entries, errc := scan.Listing(ctx, dst, scan.ListOptions{Concurrency: config.ListConcurrency})
+	for fileInfo := range entries {
+		if !fileInfo.IsDir() && fileInfo.Key() != MarkerKey {
+			report.AtRisk++
+		}
+	}
+	// 部分目录无法列举时会低估受影响的文件数
+	if err := <-errc; err != nil {
+		return report, fmt.Errorf("failed to count files at risk on the destination: %w", err)
+	}
+	if report.AtRisk <= report.Threshold {
+		return report, nil
+	}
+
+	var reason error
+	switch {
+	case config.Force:
+		report.Decision = InterlockForced
+	case confirm == nil:
+		report.Decision = InterlockRefused
+	default:
+		ok, err := confirm(report)
+		if ok && err == nil {
+			report.Decision = InterlockConfirmed
+		} else {
+			report.Decision = InterlockRefused
+			reason = err
+		}
+	}
+
+	details := map[string]any{
+		"source":      config.Source,
+		"destination": config.Destination,
+		"at_risk":     report.AtRisk,
+		"threshold":   report.Threshold,
+	}
+	if reason != nil {
+		details["reason"] = reason.Error()
+	}
+	if err := audit.Record(audit.Event{Command: cmdLine, Action: "migrate.interlock", Decision: string(report.Decision), Details: details}); err != nil {
+		return report, err
+	}
+
+	if report.Decision == InterlockRefused {
+		return report, fmt.Errorf("%w: overwrite with propagate-deletes may affect %d files on %s (threshold %d), use --force to proceed",
+			ErrInterlock, report.AtRisk, config.Destination, report.Threshold)
+	}
+	return report, nil
+}
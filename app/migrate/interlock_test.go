@@ -0,0 +1,70 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"terrasync/log"
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// TestCheckInterlock 测试覆盖与删除同时启用且影响的文件过多时需要--force或确认
+func TestCheckInterlock(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	dst, err := object.CreateStorage("mem://test-interlock")
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, dst.Put(fmt.Sprintf("/dir/f%d.txt", i), strings.NewReader("x")))
+	}
+
+	confirmed := func(InterlockReport) (bool, error) { return true, nil }
+	declined := func(InterlockReport) (bool, error) { return false, nil }
+	noTerminal := func(InterlockReport) (bool, error) { return false, errors.New("stdin is not a terminal") }
+
+	cases := []struct {
+		name      string
+		overwrite bool
+		deletes   bool
+		force     bool
+		threshold int64
+		confirm   Confirm
+		atRisk    int64
+		decision  InterlockDecision
+	}{
+		{name: "只覆盖不删除", overwrite: true, threshold: 1, decision: InterlockNotRequired},
+		{name: "只删除不覆盖", deletes: true, threshold: 1, decision: InterlockNotRequired},
+		{name: "未超过阈值", overwrite: true, deletes: true, threshold: 5, atRisk: 5, decision: InterlockNotRequired},
+		{name: "指定了force", overwrite: true, deletes: true, force: true, threshold: 4, atRisk: 5, decision: InterlockForced},
+		{name: "输入确认", overwrite: true, deletes: true, threshold: 4, confirm: confirmed, atRisk: 5, decision: InterlockConfirmed},
+		{name: "拒绝确认", overwrite: true, deletes: true, threshold: 4, confirm: declined, atRisk: 5, decision: InterlockRefused},
+		{name: "非交互模式", overwrite: true, deletes: true, threshold: 0, confirm: noTerminal, atRisk: 5, decision: InterlockRefused},
+		{name: "没有确认方式", overwrite: true, deletes: true, threshold: 0, atRisk: 5, decision: InterlockRefused},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := &MigrateConfig{
+				Source:             "/mnt/src",
+				Destination:        "mem://test-interlock",
+				Overwrite:          c.overwrite,
+				PropagateDeletes:   c.deletes,
+				Force:              c.force,
+				InterlockThreshold: c.threshold,
+				ListConcurrency:    2,
+			}
+			report, err := CheckInterlock(context.Background(), config, dst, c.confirm, "")
+			assert.Equal(t, c.decision, report.Decision)
+			assert.Equal(t, c.atRisk, report.AtRisk)
+			if c.decision == InterlockRefused {
+				assert.ErrorIs(t, err, ErrInterlock)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package migrate
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Failure reasons recorded in the failures ledger
+const (
+	FailureError    = "error"     // 传输返回错误
+	FailureTimeout  = "timeout"   // 超过单文件传输时限
+	FailureStalled  = "stalled"   // 传输长时间没有数据进展
+	FailureUnstable = "unstable"  // 源文件在拷贝期间持续变化，已保留最后一次拷贝
+	FailureAttrs    = "attrs"     // 目标端无法保留部分文件属性(如immutable、hidden)，其他元数据已设置
+	FailureTooLarge = "too-large" // 文件超过--max-file-size，已跳过
+	FailureTooDeep  = "too-deep"  // 条目超过--max-depth，已跳过(目录不再继续遍历)
+	FailureOffline  = "offline"   // 源文件是离线存根(HSM/归档分层)，按--stub-policy跳过或回迁后仍离线
+	FailureMissing  = "missing"   // 迁移波次清单中的条目在扫描后已从源端删除
+)
+
+// Failure is one file that could not be migrated
+type Failure struct {
+	Source      string
+	Destination string
+	Reason      string
+	Attempts    int
+	Err         error
+}
+
+// FailureLedger records the files that could not be migrated, or were copied
+// while changing, as CSV so that they can be inspected and retried after the job
+type FailureLedger struct {
+	mu     sync.Mutex
+	report *csv.Writer
+	failed int64
+}
+
+// NewFailureLedger creates a ledger writing to w, w may be nil to only count failures
+func NewFailureLedger(w io.Writer) *FailureLedger {
+	l := &FailureLedger{}
+	if w != nil {
+		l.report = csv.NewWriter(w)
+		_ = l.report.Write([]string{"time", "source", "destination", "reason", "attempts", "error"})
+	}
+	return l
+}
+
+// Record appends a failure to the ledger
+func (l *FailureLedger) Record(f Failure) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.failed++
+	if l.report != nil {
+		msg := ""
+		if f.Err != nil {
+			msg = f.Err.Error()
+		}
+		_ = l.report.Write([]string{time.Now().UTC().Format(time.RFC3339), f.Source, f.Destination, f.Reason, strconv.Itoa(f.Attempts), msg})
+	}
+}
+
+// Failed returns the number of recorded failures
+func (l *FailureLedger) Failed() int64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.failed
+}
+
+// Flush writes the buffered ledger
+func (l *FailureLedger) Flush() error {
+	if l == nil || l.report == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.report.Flush()
+	return l.report.Error()
+}
@@ -0,0 +1,133 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+	"terrasync/log"
+	"terrasync/object"
+	"time"
+)
+
+// MarkerKey is the destination object recording the job migrating into it.
+// The migration never copies or deletes it.
+const MarkerKey = "/.terrasync-job.json"
+
+// DuplicateRunAction decides what happens when another job recently wrote the destination
+type DuplicateRunAction string
+
+const (
+	// DuplicateRunAbort stops the migration before anything is copied
+	DuplicateRunAbort DuplicateRunAction = "abort"
+	// DuplicateRunWarn only logs a warning
+	DuplicateRunWarn DuplicateRunAction = "warn"
+	// DuplicateRunOff neither checks nor writes the marker
+	DuplicateRunOff DuplicateRunAction = "off"
+)
+
+// ParseDuplicateRunAction parses a duplicate run action, empty means abort
+func ParseDuplicateRunAction(action string) (DuplicateRunAction, error) {
+	switch a := DuplicateRunAction(strings.ToLower(action)); a {
+	case "":
+		return DuplicateRunAbort, nil
+	case DuplicateRunAbort, DuplicateRunWarn, DuplicateRunOff:
+		return a, nil
+	default:
+		return "", fmt.Errorf("unsupported duplicate run action %q, expect abort, warn or off", action)
+	}
+}
+
+// DefaultJobID derives the job ID of a migration from its source and destination,
+// so running the same migration again is not taken for another job
+func DefaultJobID(source, destination string) string {
+	sum := sha256.Sum256([]byte(source + "\n" + destination))
+	return "migrate-" + hex.EncodeToString(sum[:6])
+}
+
+// DestinationMarker is the content of the marker object
+type DestinationMarker struct {
+	JobID     string    `json:"job_id"`
+	Source    string    `json:"source"`
+	Host      string    `json:"host"`
+	User      string    `json:"user"`
+	StartTime time.Time `json:"start_time"` // UTC
+	UpdatedAt time.Time `json:"updated_at"` // UTC，迁移期间定期刷新
+}
+
+func (m DestinationMarker) String() string {
+	return fmt.Sprintf("job %s from %s on %s@%s, last written %s", m.JobID, m.Source, m.User, m.Host, m.UpdatedAt.Format(time.RFC3339))
+}
+
+// ReadMarker returns the marker of the destination, nil when there is none
+func ReadMarker(dst object.Storage) (*DestinationMarker, error) {
+	fileInfo, err := dst.Head(MarkerKey)
+	if err != nil || fileInfo == nil {
+		return nil, err
+	}
+	r, err := fileInfo.Get(0, -1)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var marker DestinationMarker
+	if err := json.Unmarshal(data, &marker); err != nil {
+		return nil, fmt.Errorf("invalid destination marker %s: %w", MarkerKey, err)
+	}
+	return &marker, nil
+}
+
+// WriteMarker writes the marker of the destination
+func WriteMarker(dst object.Storage, marker DestinationMarker) error {
+	data, err := json.MarshalIndent(marker, "", "  ")
+	if err != nil {
+		return err
+	}
+	return dst.Put(MarkerKey, bytes.NewReader(data))
+}
+
+// ClaimDestination detects another terrasync job that wrote the destination within
+// config.DuplicateWindow, e.g. two teams migrating into the same bucket prefix,
+// then marks the destination with this job. Depending on config.DuplicateRun the
+// other job aborts the migration or is only logged.
+func ClaimDestination(ctx context.Context, config *MigrateConfig, dst object.Storage, now time.Time) (*DestinationMarker, error) {
+	// tar流目标无法读取标记
+	if config.DuplicateRun == DuplicateRunOff || object.StorageType(config.Destination) == "stream" {
+		return nil, nil
+	}
+	other, err := ReadMarker(dst)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read destination marker: %w", err)
+	}
+	if other != nil && other.JobID != config.JobID && now.Sub(other.UpdatedAt) < config.DuplicateWindow {
+		if config.DuplicateRun == DuplicateRunAbort {
+			return other, fmt.Errorf("destination %s was written by another terrasync %s, use --duplicate-run warn to proceed anyway",
+				config.Destination, other)
+		}
+		log.Warnf("Destination %s was written by another terrasync %s", config.Destination, other)
+	}
+
+	marker := DestinationMarker{JobID: config.JobID, Source: config.Source, StartTime: now.UTC(), UpdatedAt: now.UTC()}
+	if other != nil && other.JobID == config.JobID {
+		marker.StartTime = other.StartTime
+	}
+	marker.Host, _ = os.Hostname()
+	if u, err := user.Current(); err == nil {
+		marker.User = u.Username
+	}
+	if err := WriteMarker(dst, marker); err != nil {
+		return other, fmt.Errorf("failed to write destination marker: %w", err)
+	}
+	log.Infof("Destination %s marked by job %s", config.Destination, config.JobID)
+	return other, nil
+}
@@ -0,0 +1,77 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"terrasync/log"
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// TestClaimDestination 测试其他任务最近写过目标端时中止或告警
+func TestClaimDestination(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	now := time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+	otherMarker := DestinationMarker{JobID: "team-a", Source: "/mnt/a", StartTime: now.Add(-3 * time.Hour), UpdatedAt: now.Add(-time.Hour)}
+
+	cases := []struct {
+		name    string
+		marker  *DestinationMarker
+		jobID   string
+		action  DuplicateRunAction
+		wantErr bool
+		claimed bool
+	}{
+		{name: "没有标记", jobID: "team-b", action: DuplicateRunAbort, claimed: true},
+		{name: "其他任务最近写入", marker: &otherMarker, jobID: "team-b", action: DuplicateRunAbort, wantErr: true},
+		{name: "其他任务最近写入只告警", marker: &otherMarker, jobID: "team-b", action: DuplicateRunWarn, claimed: true},
+		{name: "同一任务再次运行", marker: &otherMarker, jobID: "team-a", action: DuplicateRunAbort, claimed: true},
+		{name: "不检查", marker: &otherMarker, jobID: "team-b", action: DuplicateRunOff},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dst, err := object.CreateStorage("mem://test-marker-" + c.name)
+			assert.NoError(t, err)
+			if c.marker != nil {
+				assert.NoError(t, WriteMarker(dst, *c.marker))
+			}
+			config := &MigrateConfig{Source: "/mnt/b", Destination: "s3://bucket/prefix", JobID: c.jobID, DuplicateRun: c.action, DuplicateWindow: 24 * time.Hour}
+			other, err := ClaimDestination(context.Background(), config, dst, now)
+			if c.wantErr {
+				assert.Error(t, err)
+				assert.Equal(t, "team-a", other.JobID)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			marker, err := ReadMarker(dst)
+			assert.NoError(t, err)
+			if !c.claimed {
+				assert.Equal(t, c.marker, marker)
+				return
+			}
+			assert.Equal(t, c.jobID, marker.JobID)
+			assert.Equal(t, "/mnt/b", marker.Source)
+			assert.Equal(t, now, marker.UpdatedAt)
+			if c.marker != nil && c.marker.JobID == c.jobID {
+				// 同一任务保留最初的开始时间
+				assert.Equal(t, c.marker.StartTime, marker.StartTime)
+			}
+		})
+	}
+
+	// 标记超过时间窗口后不再视为正在运行的任务
+	dst, err := object.CreateStorage("mem://test-marker-stale")
+	assert.NoError(t, err)
+	assert.NoError(t, WriteMarker(dst, otherMarker))
+	config := &MigrateConfig{Source: "/mnt/b", Destination: "s3://bucket/prefix", JobID: "team-b", DuplicateRun: DuplicateRunAbort, DuplicateWindow: 30 * time.Minute}
+	_, err = ClaimDestination(context.Background(), config, dst, now)
+	assert.NoError(t, err)
+
+	assert.Equal(t, DefaultJobID("/mnt/a", "/mnt/b"), DefaultJobID("/mnt/a", "/mnt/b"))
+	assert.NotEqual(t, DefaultJobID("/mnt/a", "/mnt/b"), DefaultJobID("/mnt/a", "/mnt/c"))
+}
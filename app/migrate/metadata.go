@@ -0,0 +1,55 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"terrasync/object"
+)
+
+// ErrDestinationMissing is returned by SyncMetadata when the file was never copied
+var ErrDestinationMissing = errors.New("destination file does not exist")
+
+// SyncMetadata re-applies the owner, permissions, ACLs, times and attributes of src
+// to the already copied destination file key, without transferring data.
+// It returns the names of the fields that were changed, nil if they already matched.
+// Attributes the destination cannot keep are returned as an *object.AttrsNotPreservedError
+// together with the changed fields, they are recorded as FailureAttrs in the ledger.
+func SyncMetadata(src object.FileInfo, dst object.Storage, key string) ([]string, error) {
+	setter, ok := dst.(object.MetadataSetter)
+	if !ok {
+		return nil, fmt.Errorf("destination storage does not support setting metadata")
+	}
+
+	dstInfo, err := dst.Head(key)
+	if err != nil {
+		return nil, err
+	}
+	if dstInfo == nil {
+		return nil, fmt.Errorf("%s: %w", key, ErrDestinationMissing)
+	}
+	if dstInfo.IsDir() != src.IsDir() {
+		return nil, fmt.Errorf("%s: source and destination types differ", key)
+	}
+
+	srcMeta, err := object.MetadataOf(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata of %s: %w", src.Key(), err)
+	}
+	dstMeta, err := object.MetadataOf(dstInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata of %s: %w", key, err)
+	}
+
+	changed := srcMeta.Diff(dstMeta)
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	if err := setter.SetMetadata(key, srcMeta); err != nil {
+		var lost *object.AttrsNotPreservedError
+		if errors.As(err, &lost) {
+			return changed, err
+		}
+		return nil, err
+	}
+	return changed, nil
+}
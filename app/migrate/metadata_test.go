@@ -0,0 +1,98 @@
+package migrate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSyncMetadata 测试只同步元数据
+func TestSyncMetadata(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not fully supported on Windows")
+	}
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, dir := range []string{srcDir, dstDir} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("data"), 0644))
+	}
+	assert.NoError(t, os.Chmod(filepath.Join(srcDir, "a.txt"), 0600))
+	assert.NoError(t, os.Chtimes(filepath.Join(srcDir, "a.txt"), mtime, mtime))
+
+	src, err := object.CreateStorage(srcDir)
+	assert.NoError(t, err)
+	dst, err := object.CreateStorage(dstDir)
+	assert.NoError(t, err)
+
+	srcInfo, err := src.Head("/a.txt")
+	assert.NoError(t, err)
+
+	changed, err := SyncMetadata(srcInfo, dst, "/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"perm", "mtime"}, changed)
+
+	info, err := os.Stat(filepath.Join(dstDir, "a.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	assert.True(t, info.ModTime().Equal(mtime))
+
+	// 再次同步没有变化
+	changed, err = SyncMetadata(srcInfo, dst, "/a.txt")
+	assert.NoError(t, err)
+	assert.Empty(t, changed)
+
+	// 目标不存在
+	_, err = SyncMetadata(srcInfo, dst, "/missing.txt")
+	assert.ErrorIs(t, err, ErrDestinationMissing)
+}
+
+// TestSyncMetadataAttrs 测试同步文件属性及报告目标端无法保留的属性
+func TestSyncMetadataAttrs(t *testing.T) {
+	src, err := object.CreateStorage("mem://sync-attrs-src")
+	assert.NoError(t, err)
+	assert.NoError(t, src.Put("/a.txt", strings.NewReader("data")))
+	assert.NoError(t, src.(object.MetadataSetter).SetMetadata("/a.txt", object.Metadata{
+		Perm: 0644, Attrs: object.AttrHidden | object.AttrNoDump, AttrsKnown: true,
+	}))
+	srcInfo, err := src.Head("/a.txt")
+	assert.NoError(t, err)
+
+	dst, err := object.CreateStorage("mem://sync-attrs-dst")
+	assert.NoError(t, err)
+	assert.NoError(t, dst.Put("/a.txt", strings.NewReader("data")))
+	assert.NoError(t, dst.(object.MetadataSetter).SetMetadata("/a.txt", object.Metadata{Perm: 0644, MTime: srcInfo.MTime()}))
+
+	changed, err := SyncMetadata(srcInfo, dst, "/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"attrs"}, changed)
+	dstInfo, err := dst.Head("/a.txt")
+	assert.NoError(t, err)
+	attrs, err := dstInfo.(object.AttrsProvider).Attrs()
+	assert.NoError(t, err)
+	assert.Equal(t, object.AttrHidden|object.AttrNoDump, attrs)
+
+	// 本地Linux目标无法保留Windows属性，其他元数据仍然设置
+	if runtime.GOOS != "linux" {
+		return
+	}
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("data"), 0600))
+	local, err := object.CreateStorage(dir)
+	assert.NoError(t, err)
+	changed, err = SyncMetadata(srcInfo, local, "/a.txt")
+	var lost *object.AttrsNotPreservedError
+	assert.True(t, errors.As(err, &lost))
+	assert.Equal(t, object.AttrHidden, lost.Attrs&object.AttrHidden)
+	assert.Contains(t, changed, "perm")
+	info, err := os.Stat(filepath.Join(dir, "a.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
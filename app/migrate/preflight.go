@@ -0,0 +1,187 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"terrasync/app/scan"
+	"terrasync/log"
+	"terrasync/object"
+)
+
+// PreflightAction decides what happens when the destination is too small
+type PreflightAction string
+
+const (
+	// PreflightAbort stops the migration before any data is copied
+	PreflightAbort PreflightAction = "abort"
+	// PreflightWarn only logs a warning
+	PreflightWarn PreflightAction = "warn"
+	// PreflightOff skips the capacity check
+	PreflightOff PreflightAction = "off"
+)
+
+// ParsePreflightAction parses a pre-flight action, empty means abort
+func ParsePreflightAction(action string) (PreflightAction, error) {
+	switch a := PreflightAction(strings.ToLower(action)); a {
+	case "":
+		return PreflightAbort, nil
+	case PreflightAbort, PreflightWarn, PreflightOff:
+		return a, nil
+	default:
+		return "", fmt.Errorf("unsupported preflight action %q, expect abort, warn or off", action)
+	}
+}
+
+// SourceUsage is what the migration needs on the destination
+type SourceUsage struct {
+	Bytes   int64 // 文件总字节数
+	Entries int64 // 文件和目录数，每个条目占用一个inode
+}
+
+// PreflightReport is the outcome of the capacity check
+type PreflightReport struct {
+	Required        int64           // 源端扫描到的字节数
+	RequiredEntries int64           // 源端扫描到的条目数
+	Capacity        object.Capacity // 目标端容量
+	Known           bool            // 目标端是否能报告容量
+	InodesKnown     bool            // 目标端是否能报告inode数量(对象存储没有inode)
+	Headroom        int             // 需要保留的空闲百分比
+	Remaining       int64           // 迁移后剩余的可用空间(扣除保留空间)，负数表示不足
+	InodesRemaining int64           // 迁移后剩余的inode(扣除保留部分)，负数表示不足
+}
+
+// Sufficient reports whether the data fits, unknown capacity is treated as sufficient
+func (r PreflightReport) Sufficient() bool {
+	return r.SpaceSufficient() && r.InodesSufficient()
+}
+
+// SpaceSufficient reports whether the bytes fit
+func (r PreflightReport) SpaceSufficient() bool {
+	return !r.Known || r.Remaining >= 0
+}
+
+// InodesSufficient reports whether enough inodes are free for all entries
+func (r PreflightReport) InodesSufficient() bool {
+	return !r.InodesKnown || r.InodesRemaining >= 0
+}
+
+// String returns the headroom report
+func (r PreflightReport) String() string {
+	if !r.Known {
+		return fmt.Sprintf("required: %s, destination capacity: unknown", scan.FormatFileSize(r.Required))
+	}
+	quota := "none"
+	if r.Capacity.Quota > 0 {
+		quota = scan.FormatFileSize(r.Capacity.Quota)
+	}
+	remaining := scan.FormatFileSize(r.Remaining)
+	if r.Remaining < 0 {
+		remaining = "-" + scan.FormatFileSize(-r.Remaining)
+	}
+	desc := fmt.Sprintf("required: %s, free: %s, quota: %s, total: %s, reserved headroom: %d%%, remaining after migration: %s",
+		scan.FormatFileSize(r.Required), scan.FormatFileSize(r.Capacity.Free), quota,
+		scan.FormatFileSize(r.Capacity.Total), r.Headroom, remaining)
+	if r.InodesKnown {
+		desc += fmt.Sprintf("; inodes required: %d, free: %d, total: %d, remaining after migration: %d",
+			r.RequiredEntries, r.Capacity.FreeInodes, r.Capacity.TotalInodes, r.InodesRemaining)
+	}
+	return desc
+}
+
+// CheckCapacity compares the scanned source bytes and entries with the space and
+// inodes available on the destination, keeping headroom percent of both free
+func CheckCapacity(dst object.Storage, usage SourceUsage, headroom int) (PreflightReport, error) {
+	report := PreflightReport{Required: usage.Bytes, RequiredEntries: usage.Entries, Headroom: headroom}
+
+	provider, ok := dst.(object.CapacityProvider)
+	if !ok {
+		return report, nil
+	}
+	capacity, err := provider.Capacity()
+	if errors.Is(err, object.ErrCapacityUnknown) {
+		return report, nil
+	}
+	if err != nil {
+		return report, fmt.Errorf("failed to query destination capacity: %w", err)
+	}
+	report.Known = true
+	report.Capacity = capacity
+	reserved := capacity.Total * int64(headroom) / 100
+	report.Remaining = capacity.Available() - reserved - usage.Bytes
+
+	// 本地/NFS目标耗尽inode同样会导致迁移中途失败
+	if capacity.TotalInodes > 0 {
+		report.InodesKnown = true
+		reservedInodes := capacity.TotalInodes * int64(headroom) / 100
+		report.InodesRemaining = capacity.FreeInodes - reservedInodes - usage.Entries
+	}
+	return report, nil
+}
+
+// ScanSource lists the source and returns the bytes and entries it contains
+func ScanSource(ctx context.Context, src object.Storage, concurrency int) (SourceUsage, error) {
+	var usage SourceUsage
+	entries, errc := scan.Listing(ctx, src, scan.ListOptions{Concurrency: concurrency})
+	for fileInfo := range entries {
+		usage.Entries++
+		if !fileInfo.IsDir() {
+			usage.Bytes += fileInfo.Size()
+		}
+	}
+	// 无法列举的目录同样无法拷贝，不计入容量
+	err := <-errc
+	var listErr *scan.ListError
+	if errors.As(err, &listErr) {
+		log.Warnf("Source size excludes what could not be listed: %v", err)
+		return usage, nil
+	}
+	return usage, err
+}
+
+// Preflight checks that the source fits on the destination before anything is copied.
+// Depending on config.Preflight an insufficient destination aborts the migration or
+// is only logged.
+func Preflight(ctx context.Context, config *MigrateConfig, src, dst object.Storage) (PreflightReport, error) {
+	if config.Preflight == PreflightOff {
+		return PreflightReport{}, nil
+	}
+	// tar流只能读取一次，无法预先统计大小
+	if object.StorageType(config.Source) == "stream" {
+		log.Infof("Capacity preflight skipped for stream source")
+		return PreflightReport{}, nil
+	}
+
+	// 迁移波次按扫描时清单中的大小检查
+	var usage SourceUsage
+	var err error
+	if config.WaveManifest != "" {
+		usage, err = waveUsage(config.WaveManifest)
+	} else {
+		usage, err = ScanSource(ctx, src, config.ListConcurrency)
+	}
+	if err != nil {
+		return PreflightReport{}, fmt.Errorf("failed to scan source size: %w", err)
+	}
+	report, err := CheckCapacity(dst, usage, config.CapacityHeadroom)
+	if err != nil {
+		return report, err
+	}
+	log.Infof("Capacity preflight: %s", report)
+
+	if report.Sufficient() {
+		return report, nil
+	}
+	what := "capacity"
+	if report.SpaceSufficient() {
+		what = "inodes"
+	} else if !report.InodesSufficient() {
+		what = "capacity and inodes"
+	}
+	if config.Preflight == PreflightWarn {
+		log.Warnf("Destination %s may run out of %s: %s", config.Destination, what, report)
+		return report, nil
+	}
+	return report, fmt.Errorf("insufficient %s on %s: %s", what, config.Destination, report)
+}
@@ -0,0 +1,69 @@
+package migrate
+
+import (
+	"testing"
+
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// capacityStorage 返回固定容量的存储
+type capacityStorage struct {
+	object.Storage
+	capacity object.Capacity
+}
+
+func (s *capacityStorage) Capacity() (object.Capacity, error) {
+	return s.capacity, nil
+}
+
+// TestCheckCapacity 测试容量预检
+func TestCheckCapacity(t *testing.T) {
+	cases := []struct {
+		name       string
+		capacity   object.Capacity
+		required   int64
+		headroom   int
+		remaining  int64
+		sufficient bool
+	}{
+		{name: "空间充足", capacity: object.Capacity{Total: 1000, Free: 500}, required: 300, headroom: 5, remaining: 150, sufficient: true},
+		{name: "保留空间不足", capacity: object.Capacity{Total: 1000, Free: 500}, required: 460, headroom: 5, remaining: -10},
+		{name: "配额限制", capacity: object.Capacity{Total: 1000, Free: 500, Quota: 200}, required: 300, remaining: -100},
+		{name: "刚好放下", capacity: object.Capacity{Total: 1000, Free: 500}, required: 500, remaining: 0, sufficient: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			report, err := CheckCapacity(&capacityStorage{capacity: c.capacity}, SourceUsage{Bytes: c.required}, c.headroom)
+			assert.NoError(t, err)
+			assert.True(t, report.Known)
+			assert.False(t, report.InodesKnown)
+			assert.Equal(t, c.remaining, report.Remaining)
+			assert.Equal(t, c.sufficient, report.Sufficient())
+		})
+	}
+
+	// 不支持容量查询的存储视为充足
+	report, err := CheckCapacity(&struct{ object.Storage }{}, SourceUsage{Bytes: 1 << 40, Entries: 1 << 30}, 5)
+	assert.NoError(t, err)
+	assert.False(t, report.Known)
+	assert.True(t, report.Sufficient())
+}
+
+// TestCheckInodes 测试inode预检
+func TestCheckInodes(t *testing.T) {
+	capacity := object.Capacity{Total: 1 << 40, Free: 1 << 40, TotalInodes: 1000, FreeInodes: 300}
+
+	report, err := CheckCapacity(&capacityStorage{capacity: capacity}, SourceUsage{Bytes: 10, Entries: 200}, 5)
+	assert.NoError(t, err)
+	assert.True(t, report.InodesKnown)
+	assert.Equal(t, int64(50), report.InodesRemaining)
+	assert.True(t, report.Sufficient())
+
+	report, err = CheckCapacity(&capacityStorage{capacity: capacity}, SourceUsage{Bytes: 10, Entries: 260}, 5)
+	assert.NoError(t, err)
+	assert.True(t, report.SpaceSufficient())
+	assert.False(t, report.InodesSufficient())
+	assert.False(t, report.Sufficient())
+}
@@ -0,0 +1,186 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"terrasync/log"
+	"terrasync/object"
+	"time"
+)
+
+// Preserve selects the metadata of the source entries restored on the
+// destination once they are copied, since Put only writes the data
+type Preserve struct {
+	Times bool // mtime及atime
+	Perms bool // 权限、ACL及文件属性
+	Owner bool // uid/gid，需要root权限
+}
+
+// PreserveAll restores every metadata the destination supports
+var PreserveAll = Preserve{Times: true, Perms: true, Owner: true}
+
+// ParsePreserve parses a comma separated list of times, perms and owner, or
+// all or none. Empty preserves everything.
+func ParsePreserve(s string) (Preserve, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case "", "all":
+		return PreserveAll, nil
+	case "none":
+		return Preserve{}, nil
+	}
+	var p Preserve
+	for _, field := range strings.Split(s, ",") {
+		switch strings.TrimSpace(field) {
+		case "times":
+			p.Times = true
+		case "perms":
+			p.Perms = true
+		case "owner":
+			p.Owner = true
+		default:
+			return Preserve{}, fmt.Errorf("unsupported preserve %q, expect a list of times, perms and owner, all or none", field)
+		}
+	}
+	return p, nil
+}
+
+func (p Preserve) String() string {
+	var fields []string
+	for _, f := range []struct {
+		name string
+		on   bool
+	}{{"times", p.Times}, {"perms", p.Perms}, {"owner", p.Owner}} {
+		if f.on {
+			fields = append(fields, f.name)
+		}
+	}
+	if len(fields) == 0 {
+		return "none"
+	}
+	return strings.Join(fields, ",")
+}
+
+// metadata returns the metadata of src to restore, the fields not preserved
+// are left unchanged on the destination
+func (p Preserve) metadata(src object.FileInfo) (object.Metadata, error) {
+	meta, err := object.MetadataOf(src)
+	if err != nil {
+		return meta, fmt.Errorf("failed to read metadata of %s: %w", src.Key(), err)
+	}
+	if !p.Times {
+		meta.MTime, meta.ATime = time.Time{}, time.Time{}
+	}
+	if !p.Perms {
+		meta.KeepPerm, meta.ACL, meta.AttrsKnown = true, nil, false
+	}
+	if !p.Owner {
+		meta.UID, meta.GID = -1, -1
+	}
+	return meta, nil
+}
+
+// pendingDir is a copied directory whose metadata is restored at the end
+type pendingDir struct {
+	source string
+	key    string
+	depth  int
+	meta   object.Metadata
+}
+
+// MetadataRestorer restores the metadata of the copied entries on the
+// destination. Files are restored right after their copy. Directories are
+// restored once everything is copied, deepest first: copying their entries
+// changes their mtime, and a read-only or immutable directory would refuse them.
+// Failures are recorded in the ledger, the data of the entry is copied anyway.
+// A nil MetadataRestorer restores nothing.
+type MetadataRestorer struct {
+	Preserve Preserve
+	Storage  object.MetadataSetter // 目标端
+	Ledger   *FailureLedger
+
+	mu   sync.Mutex
+	dirs []pendingDir
+}
+
+// File restores the metadata of the source file src on its copy key
+func (r *MetadataRestorer) File(src object.FileInfo, key string) {
+	if r == nil {
+		return
+	}
+	meta, err := r.Preserve.metadata(src)
+	if err == nil {
+		err = r.Storage.SetMetadata(key, meta)
+	}
+	r.record(src.Key(), key, err)
+}
+
+// Dir remembers the metadata of the source directory src created as key
+func (r *MetadataRestorer) Dir(src object.FileInfo, key string) {
+	if r == nil {
+		return
+	}
+	meta, err := r.Preserve.metadata(src)
+	if err != nil {
+		r.record(src.Key(), key, err)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dirs = append(r.dirs, pendingDir{source: src.Key(), key: key, depth: strings.Count(strings.Trim(key, "/"), "/"), meta: meta})
+}
+
+// Finish restores the metadata of the directories, children before their parents
+func (r *MetadataRestorer) Finish() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sort.SliceStable(r.dirs, func(i, j int) bool { return r.dirs[i].depth > r.dirs[j].depth })
+	for _, d := range r.dirs {
+		r.record(d.source, d.key, r.Storage.SetMetadata(d.key, d.meta))
+	}
+	r.dirs = nil
+}
+
+// record records a failure to restore the metadata of key in the ledger
+func (r *MetadataRestorer) record(source, key string, err error) {
+	if err == nil {
+		return
+	}
+	reason := FailureError
+	var lost *object.AttrsNotPreservedError
+	if errors.As(err, &lost) {
+		reason = FailureAttrs
+	}
+	log.Warnf("Copied %s without all its metadata: %v", key, err)
+	r.Ledger.Record(Failure{Source: source, Destination: key, Reason: reason, Attempts: 1, Err: err})
+}
+
+// MetadataRestorer returns the restorer of the metadata of the copied entries
+// on dst, nil if nothing is preserved or dst cannot set metadata. Object stores
+// don't keep file metadata and tar streams write it with the entries.
+func (c *MigrateConfig) MetadataRestorer(dst object.Storage, ledger *FailureLedger) *MetadataRestorer {
+	preserve := c.Preserve
+	if preserve == (Preserve{}) {
+		return nil
+	}
+	if _, ok := dst.(object.EntryWriter); ok {
+		return nil
+	}
+	setter, ok := dst.(object.MetadataSetter)
+	if !ok {
+		log.Infof("Metadata is not preserved: the destination storage does not support it")
+		return nil
+	}
+	// Windows没有uid，Geteuid返回-1
+	if preserve.Owner && os.Geteuid() > 0 {
+		log.Warnf("Owners are not preserved: changing them requires root")
+		preserve.Owner = false
+	}
+	return &MetadataRestorer{Preserve: preserve, Storage: setter, Ledger: ledger}
+}
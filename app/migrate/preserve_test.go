@@ -0,0 +1,97 @@
+package migrate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"terrasync/log"
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// TestParsePreserve 测试解析要恢复的元数据
+func TestParsePreserve(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want Preserve
+		err  bool
+	}{
+		{"默认全部", "", PreserveAll, false},
+		{"全部", "ALL", PreserveAll, false},
+		{"不恢复", "none", Preserve{}, false},
+		{"列表", "times, owner", Preserve{Times: true, Owner: true}, false},
+		{"未知字段", "times,xattr", Preserve{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePreserve(tt.in)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	assert.Equal(t, "times,owner", Preserve{Times: true, Owner: true}.String())
+	assert.Equal(t, "none", Preserve{}.String())
+}
+
+// TestMigratePreserve 测试拷贝后在本地目标恢复文件及目录的权限和时间
+func TestMigratePreserve(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not fully supported on Windows")
+	}
+	log.Log = zap.NewNop().Sugar()
+	fileTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	dirTime := time.Date(2019, 6, 7, 8, 9, 10, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		preserve  Preserve
+		wantPerm  bool
+		wantTimes bool
+	}{
+		{"全部恢复", PreserveAll, true, true},
+		{"只恢复时间", Preserve{Times: true}, false, true},
+		{"不恢复", Preserve{}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcDir, dstDir := t.TempDir(), t.TempDir()
+			assert.NoError(t, os.Mkdir(filepath.Join(srcDir, "d"), 0750))
+			file := filepath.Join(srcDir, "d", "a.txt")
+			assert.NoError(t, os.WriteFile(file, []byte("data"), 0600))
+			assert.NoError(t, os.Chmod(file, 0600))
+			assert.NoError(t, os.Chtimes(file, fileTime, fileTime))
+			assert.NoError(t, os.Chtimes(filepath.Join(srcDir, "d"), dirTime, dirTime))
+
+			src, err := object.CreateStorage(srcDir)
+			assert.NoError(t, err)
+			dst, err := object.CreateStorage(dstDir)
+			assert.NoError(t, err)
+			config := MigrateConfig{Source: srcDir, Destination: dstDir, Preserve: tt.preserve, FailureLedger: filepath.Join(t.TempDir(), "failures.csv")}
+			config.ApplyDefaults()
+			result, err := Migrate(context.Background(), &config, src, dst)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(0), result.Failed)
+
+			fileInfo, err := os.Stat(filepath.Join(dstDir, "d", "a.txt"))
+			assert.NoError(t, err)
+			dirInfo, err := os.Stat(filepath.Join(dstDir, "d"))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantPerm, fileInfo.Mode().Perm() == 0600)
+			assert.Equal(t, tt.wantPerm, dirInfo.Mode().Perm() == 0750)
+			// 目录的时间在其中的文件拷贝之后设置
+			assert.Equal(t, tt.wantTimes, fileInfo.ModTime().Equal(fileTime))
+			assert.Equal(t, tt.wantTimes, dirInfo.ModTime().Equal(dirTime))
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package migrate
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"terrasync/app/scan"
+	"terrasync/log"
+	"terrasync/object"
+	"time"
+)
+
+// StubFile is an offline stub of the source found by the pre-read pass
+type StubFile struct {
+	Key      string
+	Size     int64
+	Recalled bool          // 读取后数据已在线
+	Elapsed  time.Duration // 读取(回迁)耗时
+	Err      error
+}
+
+// PrewarmResult is the outcome of the pre-read pass over the source
+type PrewarmResult struct {
+	Files int64 // 源端的普通文件数
+	Bytes int64
+	Stubs []StubFile // 按key排序
+}
+
+// Offline returns the stubs whose data is still offline after the pass
+func (r *PrewarmResult) Offline() []StubFile {
+	var offline []StubFile
+	for _, stub := range r.Stubs {
+		if !stub.Recalled {
+			offline = append(offline, stub)
+		}
+	}
+	return offline
+}
+
+// StubBytes returns the total size of the stubs found
+func (r *PrewarmResult) StubBytes() int64 {
+	var total int64
+	for _, stub := range r.Stubs {
+		total += stub.Size
+	}
+	return total
+}
+
+// Write writes the stubs found as CSV
+func (r *PrewarmResult) Write(w io.Writer) error {
+	report := csv.NewWriter(w)
+	_ = report.Write([]string{"key", "size", "recalled", "elapsed", "error"})
+	for _, stub := range r.Stubs {
+		msg := ""
+		if stub.Err != nil {
+			msg = stub.Err.Error()
+		}
+		_ = report.Write([]string{stub.Key, strconv.FormatInt(stub.Size, 10), strconv.FormatBool(stub.Recalled),
+			stub.Elapsed.Round(time.Millisecond).String(), msg})
+	}
+	report.Flush()
+	return report.Error()
+}
+
+// Prewarm walks the source ahead of the copy pass and reads the first byte of
+// every offline stub, so archive-tiered files (HSM stubs, cloud tiering) are
+// recalled before the copy instead of stalling it one file at a time. Stubs
+// are read with ListConcurrency parallel recalls, a stub is recalled when the
+// source no longer reports it offline afterwards.
+func Prewarm(ctx context.Context, config *MigrateConfig, src object.Storage) (*PrewarmResult, error) {
+	result := &PrewarmResult{}
+	stubs := make(chan object.FileInfo)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < config.ListConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fileInfo := range stubs {
+				stub, _ := recallStub(ctx, src, fileInfo)
+				mu.Lock()
+				result.Stubs = append(result.Stubs, stub)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for fileInfo := range config.listSource(ctx, src, scan.ListOptions{Concurrency: config.ListConcurrency}, nil) {
+		if !fileInfo.IsRegular() {
+			continue
+		}
+		result.Files++
+		result.Bytes += fileInfo.Size()
+		if object.IsStub(fileInfo) {
+			stubs <- fileInfo
+		}
+	}
+	close(stubs)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(result.Stubs, func(i, j int) bool { return result.Stubs[i].Key < result.Stubs[j].Key })
+	return result, nil
+}
+
+// recallStub reads the first byte of a stub and checks whether it is online
+// afterwards, returning the file stat after the read
+func recallStub(ctx context.Context, src object.Storage, fileInfo object.FileInfo) (stub StubFile, after object.FileInfo) {
+	stub = StubFile{Key: fileInfo.Key(), Size: fileInfo.Size()}
+	if ctx.Err() != nil {
+		stub.Err = ctx.Err()
+		return stub, nil
+	}
+	start := time.Now()
+	defer func() { stub.Elapsed = time.Since(start) }()
+
+	in, err := fileInfo.Get(0, 1)
+	if err != nil {
+		stub.Err = fmt.Errorf("failed to read stub: %w", err)
+		log.Warnf("Failed to recall %s: %v", stub.Key, err)
+		return stub, nil
+	}
+	_, err = io.Copy(io.Discard, in)
+	in.Close()
+	if err != nil {
+		stub.Err = fmt.Errorf("failed to read stub: %w", err)
+		log.Warnf("Failed to recall %s: %v", stub.Key, err)
+		return stub, nil
+	}
+
+	after, err = src.Head(stub.Key)
+	if err != nil {
+		stub.Err = fmt.Errorf("failed to stat stub after read: %w", err)
+		return stub, nil
+	}
+	stub.Recalled = after != nil && !object.IsStub(after)
+	if !stub.Recalled {
+		log.Warnf("Stub %s is still offline after read", stub.Key)
+	}
+	return stub, after
+}
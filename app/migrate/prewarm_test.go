@@ -0,0 +1,109 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"terrasync/log"
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// tieredStorage 模拟归档分层的源端，读取存根后回迁，stuck中的存根无法回迁
+type tieredStorage struct {
+	object.Storage
+	mu      sync.Mutex
+	offline map[string]bool
+	stuck   map[string]bool
+}
+
+type tieredFile struct {
+	object.FileInfo
+	s *tieredStorage
+}
+
+func (s *tieredStorage) wrap(fileInfo object.FileInfo) object.FileInfo {
+	return &tieredFile{FileInfo: fileInfo, s: s}
+}
+
+func (s *tieredStorage) List(dir string) (<-chan object.FileInfo, error) {
+	queue, err := s.Storage.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan object.FileInfo)
+	go func() {
+		defer close(out)
+		for fileInfo := range queue {
+			out <- s.wrap(fileInfo)
+		}
+	}()
+	return out, nil
+}
+
+func (s *tieredStorage) Head(key string) (object.FileInfo, error) {
+	fileInfo, err := s.Storage.Head(key)
+	if err != nil || fileInfo == nil {
+		return fileInfo, err
+	}
+	return s.wrap(fileInfo), nil
+}
+
+func (f *tieredFile) Stub() bool {
+	f.s.mu.Lock()
+	defer f.s.mu.Unlock()
+	return f.s.offline[f.Key()]
+}
+
+func (f *tieredFile) Get(offset, limit int64) (io.ReadCloser, error) {
+	f.s.mu.Lock()
+	if !f.s.stuck[f.Key()] {
+		delete(f.s.offline, f.Key())
+	}
+	f.s.mu.Unlock()
+	return f.FileInfo.Get(offset, limit)
+}
+
+// TestPrewarm 测试预读触发存根回迁并单独报告存根
+func TestPrewarm(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	mem, err := object.CreateStorage("mem://test-prewarm")
+	assert.NoError(t, err)
+	for key, data := range map[string]string{"/a/online.txt": "online", "/a/stub.txt": "recalled", "/b/stuck.txt": "tape"} {
+		assert.NoError(t, mem.Put(key, strings.NewReader(data)))
+	}
+	src := &tieredStorage{Storage: mem,
+		offline: map[string]bool{"/a/stub.txt": true, "/b/stuck.txt": true},
+		stuck:   map[string]bool{"/b/stuck.txt": true},
+	}
+
+	config := &MigrateConfig{ListConcurrency: 2, Prewarm: true}
+	result, err := Prewarm(context.Background(), config, src)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), result.Files)
+	assert.Equal(t, int64(18), result.Bytes)
+	assert.Len(t, result.Stubs, 2)
+	assert.Equal(t, int64(12), result.StubBytes())
+	assert.Equal(t, "/a/stub.txt", result.Stubs[0].Key)
+	assert.True(t, result.Stubs[0].Recalled)
+	assert.False(t, result.Stubs[1].Recalled)
+	offline := result.Offline()
+	assert.Len(t, offline, 1)
+	assert.Equal(t, "/b/stuck.txt", offline[0].Key)
+
+	var buf bytes.Buffer
+	assert.NoError(t, result.Write(&buf))
+	records, err := csv.NewReader(&buf).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key", "size", "recalled", "elapsed", "error"}, records[0])
+	assert.Equal(t, []string{"/a/stub.txt", "8", "true"}, records[1][:3])
+	assert.Equal(t, []string{"/b/stuck.txt", "4", "false"}, records[2][:3])
+
+}
@@ -0,0 +1,357 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Work item states
+const (
+	ItemPending = "pending" // 等待领取
+	ItemClaimed = "claimed" // 已被工作节点领取，租约到期前由该节点处理
+	ItemDone    = "done"
+	ItemFailed  = "failed" // 重试次数用尽
+)
+
+// DefaultMaxAttempts is how often an item is handed out before it is marked failed
+const DefaultMaxAttempts = 3
+
+// ErrLeaseLost is returned when a worker reports on an item whose lease expired
+// and which was handed off to another worker
+var ErrLeaseLost = errors.New("lease lost, the item was handed off to another worker")
+
+// WorkItem is a subtree of the source migrated by one worker
+type WorkItem struct {
+	Key        string
+	Worker     string
+	Attempts   int
+	LeaseUntil time.Time
+}
+
+// WorkQueue shards a migration across worker hosts. The coordinator enqueues
+// subtrees of the source, usually its top-level directories, into a SQLite
+// database reachable by all workers; each worker claims one item at a time with
+// a lease it renews while copying. Items of a worker that dies are handed off
+// to the next worker once their lease expires, and the failures of all workers
+// are collected in the same database.
+type WorkQueue struct {
+	db  *sql.DB
+	now func() time.Time
+
+	MaxAttempts int // 领取次数达到该值后失败的工作项不再重新排队
+}
+
+// OpenWorkQueue opens or creates the work queue database at path
+func OpenWorkQueue(ctx context.Context, path string) (*WorkQueue, error) {
+	// 多个工作节点并发领取，等待锁释放而不是立即返回SQLITE_BUSY
+	sqldb, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(10000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open work queue: %w", err)
+	}
+	if _, err := sqldb.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS work_items (
+	seq INTEGER PRIMARY KEY AUTOINCREMENT,
+	key TEXT NOT NULL UNIQUE,
+	state TEXT NOT NULL,
+	worker TEXT NOT NULL DEFAULT '',
+	lease_until INTEGER NOT NULL DEFAULT 0,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	files INTEGER NOT NULL DEFAULT 0,
+	bytes INTEGER NOT NULL DEFAULT 0,
+	error TEXT NOT NULL DEFAULT '',
+	updated DATETIME
+);
+CREATE TABLE IF NOT EXISTS work_failures (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	time DATETIME,
+	worker TEXT,
+	item TEXT,
+	source TEXT,
+	destination TEXT,
+	reason TEXT,
+	attempts INTEGER,
+	error TEXT
+);`); err != nil {
+		sqldb.Close()
+		return nil, fmt.Errorf("failed to create work queue tables: %w", err)
+	}
+	return &WorkQueue{db: sqldb, now: time.Now, MaxAttempts: DefaultMaxAttempts}, nil
+}
+
+// Close closes the queue database
+func (q *WorkQueue) Close() error {
+	return q.db.Close()
+}
+
+// Enqueue adds subtrees of the source to the queue. A key that is already
+// queued, or overlaps a queued subtree (its ancestor or descendant), is skipped
+// so no file is copied by two workers. Returns the number of keys added.
+func (q *WorkQueue) Enqueue(ctx context.Context, keys []string) (int, error) {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	added := 0
+	for _, key := range keys {
+		key = path.Clean("/" + key)
+		var overlap int
+		// 已有相同的工作项、其上级目录或下级目录
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM work_items WHERE key = ?1
+	OR key = '/' OR ?1 = '/'
+	OR substr(?1, 1, length(key) + 1) = key || '/'
+	OR substr(key, 1, length(?1) + 1) = ?1 || '/'`, key).Scan(&overlap); err != nil {
+			return 0, fmt.Errorf("failed to check work item %s: %w", key, err)
+		}
+		if overlap > 0 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO work_items (key, state, updated) VALUES (?, ?, ?)`,
+			key, ItemPending, time.Now().UTC()); err != nil {
+			return 0, fmt.Errorf("failed to enqueue %s: %w", key, err)
+		}
+		added++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit work items: %w", err)
+	}
+	return added, nil
+}
+
+// Claim hands the oldest pending item, or an item whose lease expired, to
+// worker for the lease duration. An expired item already claimed MaxAttempts
+// times is marked failed instead, so an item that kills every worker is not
+// handed out forever. Returns nil when no item is left to claim.
+func (q *WorkQueue) Claim(ctx context.Context, worker string, lease time.Duration) (*WorkItem, error) {
+	now := q.now()
+	if _, err := q.db.ExecContext(ctx, `UPDATE work_items
+	SET state = ?1, lease_until = 0, error = 'lease of ' || worker || ' expired', updated = ?2
+	WHERE state = ?3 AND lease_until < ?4 AND attempts >= ?5`,
+		ItemFailed, now.UTC(), ItemClaimed, now.UnixMilli(), q.MaxAttempts); err != nil {
+		return nil, fmt.Errorf("failed to expire work items: %w", err)
+	}
+
+	item := &WorkItem{Worker: worker, LeaseUntil: now.Add(lease)}
+	// 单条语句完成选择和更新，多个节点并发领取时不会拿到同一工作项
+	err := q.db.QueryRowContext(ctx, `UPDATE work_items
+	SET state = ?1, worker = ?2, lease_until = ?3, attempts = attempts + 1, updated = ?4
+	WHERE seq = (SELECT seq FROM work_items
+		WHERE state = ?5 OR (state = ?1 AND lease_until < ?6)
+		ORDER BY seq LIMIT 1)
+	RETURNING key, attempts`,
+		ItemClaimed, worker, item.LeaseUntil.UnixMilli(), now.UTC(), ItemPending, now.UnixMilli()).Scan(&item.Key, &item.Attempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim work item: %w", err)
+	}
+	return item, nil
+}
+
+// Renew extends the lease of an item still held by its worker
+func (q *WorkQueue) Renew(ctx context.Context, item *WorkItem, lease time.Duration) error {
+	until := q.now().Add(lease)
+	if err := q.update(ctx, item, `lease_until = ?`, until.UnixMilli()); err != nil {
+		return err
+	}
+	item.LeaseUntil = until
+	return nil
+}
+
+// Complete marks an item migrated with the number of files and bytes copied
+func (q *WorkQueue) Complete(ctx context.Context, item *WorkItem, files, bytes int64) error {
+	return q.update(ctx, item, `state = ?, files = ?, bytes = ?, error = ''`, ItemDone, files, bytes)
+}
+
+// Fail returns an item to the queue for another worker, or marks it failed
+// once it was claimed MaxAttempts times
+func (q *WorkQueue) Fail(ctx context.Context, item *WorkItem, cause error) error {
+	state := ItemPending
+	if item.Attempts >= q.MaxAttempts {
+		state = ItemFailed
+	}
+	msg := ""
+	if cause != nil {
+		msg = cause.Error()
+	}
+	return q.update(ctx, item, `state = ?, lease_until = 0, error = ?`, state, msg)
+}
+
+// update changes an item claimed by item.Worker, failing with ErrLeaseLost
+// when the item was handed off in the meantime
+func (q *WorkQueue) update(ctx context.Context, item *WorkItem, set string, args ...any) error {
+	args = append(args, q.now().UTC(), item.Key, ItemClaimed, item.Worker)
+	result, err := q.db.ExecContext(ctx, `UPDATE work_items SET `+set+`, updated = ?
+	WHERE key = ? AND state = ? AND worker = ?`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update work item %s: %w", item.Key, err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update work item %s: %w", item.Key, err)
+	} else if n == 0 {
+		return fmt.Errorf("work item %s: %w", item.Key, ErrLeaseLost)
+	}
+	return nil
+}
+
+// RecordFailure records a file of an item that could not be migrated in the
+// failures shared by all workers
+func (q *WorkQueue) RecordFailure(ctx context.Context, item *WorkItem, f Failure) error {
+	msg := ""
+	if f.Err != nil {
+		msg = f.Err.Error()
+	}
+	if _, err := q.db.ExecContext(ctx, `INSERT INTO work_failures (
+	time, worker, item, source, destination, reason, attempts, error
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		time.Now().UTC(), item.Worker, item.Key, f.Source, f.Destination, f.Reason, f.Attempts, msg); err != nil {
+		return fmt.Errorf("failed to record failure of %s: %w", f.Source, err)
+	}
+	return nil
+}
+
+// WorkerStatus is the progress of one worker
+type WorkerStatus struct {
+	Name    string
+	Claimed int64 // 当前持有的工作项
+	Done    int64
+	Files   int64
+	Bytes   int64
+}
+
+// QueueStatus is the progress of a distributed migration
+type QueueStatus struct {
+	Pending  int64
+	Claimed  int64
+	Expired  int64 // 租约已过期、等待移交的工作项，包含在Claimed中
+	Done     int64
+	Failed   int64
+	Files    int64
+	Bytes    int64
+	Failures int64 // 所有节点记录的失败文件数
+	Workers  []WorkerStatus
+}
+
+// Finished reports whether no item is left to migrate
+func (s QueueStatus) Finished() bool {
+	return s.Pending == 0 && s.Claimed == 0
+}
+
+// Status returns the progress of all items and workers
+func (q *WorkQueue) Status(ctx context.Context) (QueueStatus, error) {
+	var status QueueStatus
+	rows, err := q.db.QueryContext(ctx, `SELECT worker, state, lease_until < ?1, COUNT(*), SUM(files), SUM(bytes)
+	FROM work_items GROUP BY worker, state, lease_until < ?1`, q.now().UnixMilli())
+	if err != nil {
+		return status, fmt.Errorf("failed to query work queue: %w", err)
+	}
+	defer rows.Close()
+
+	workers := make(map[string]*WorkerStatus)
+	worker := func(name string) *WorkerStatus {
+		w := workers[name]
+		if w == nil {
+			w = &WorkerStatus{Name: name}
+			workers[name] = w
+		}
+		return w
+	}
+	for rows.Next() {
+		var name, state string
+		var expired bool
+		var count, files, bytes int64
+		if err := rows.Scan(&name, &state, &expired, &count, &files, &bytes); err != nil {
+			return status, fmt.Errorf("failed to scan work queue: %w", err)
+		}
+		switch state {
+		case ItemPending:
+			status.Pending += count
+		case ItemClaimed:
+			status.Claimed += count
+			if expired {
+				status.Expired += count
+			}
+			worker(name).Claimed += count
+		case ItemDone:
+			status.Done += count
+			status.Files += files
+			status.Bytes += bytes
+			w := worker(name)
+			w.Done += count
+			w.Files += files
+			w.Bytes += bytes
+		case ItemFailed:
+			status.Failed += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return status, fmt.Errorf("failed to scan work queue: %w", err)
+	}
+	for _, w := range workers {
+		status.Workers = append(status.Workers, *w)
+	}
+	sort.Slice(status.Workers, func(i, j int) bool { return status.Workers[i].Name < status.Workers[j].Name })
+
+	if err := q.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM work_failures`).Scan(&status.Failures); err != nil {
+		return status, fmt.Errorf("failed to count failures: %w", err)
+	}
+	return status, nil
+}
+
+// WriteFailures writes the failures of all workers as CSV, in the columns of
+// the failures ledger of a single host migration plus the worker and item
+func (q *WorkQueue) WriteFailures(ctx context.Context, w io.Writer) error {
+	rows, err := q.db.QueryContext(ctx, `SELECT time, source, destination, reason, attempts, error, worker, item
+	FROM work_failures ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("failed to query failures: %w", err)
+	}
+	defer rows.Close()
+
+	report := csv.NewWriter(w)
+	_ = report.Write([]string{"time", "source", "destination", "reason", "attempts", "error", "worker", "item"})
+	for rows.Next() {
+		var t time.Time
+		var source, destination, reason, msg, worker, item string
+		var attempts int
+		if err := rows.Scan(&t, &source, &destination, &reason, &attempts, &msg, &worker, &item); err != nil {
+			return fmt.Errorf("failed to scan failures: %w", err)
+		}
+		_ = report.Write([]string{t.UTC().Format(time.RFC3339), source, destination, reason, strconv.Itoa(attempts), msg, worker, item})
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to scan failures: %w", err)
+	}
+	report.Flush()
+	return report.Error()
+}
+
+// FailedItems returns the items that failed on every attempt with their last error
+func (q *WorkQueue) FailedItems(ctx context.Context) (map[string]string, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT key, error FROM work_items WHERE state = ?`, ItemFailed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query failed items: %w", err)
+	}
+	defer rows.Close()
+	items := make(map[string]string)
+	for rows.Next() {
+		var key, msg string
+		if err := rows.Scan(&key, &msg); err != nil {
+			return nil, fmt.Errorf("failed to scan failed items: %w", err)
+		}
+		items[key] = msg
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,193 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openTestQueue 打开临时目录中的工作队列
+func openTestQueue(t *testing.T) *WorkQueue {
+	q, err := OpenWorkQueue(context.Background(), filepath.Join(t.TempDir(), "queue.db"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+// TestWorkQueueEnqueue 测试重复及重叠的工作项只入队一次
+func TestWorkQueueEnqueue(t *testing.T) {
+	ctx := context.Background()
+	q := openTestQueue(t)
+
+	added, err := q.Enqueue(ctx, []string{"/home", "projects", "/home/alice", "/home2"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, added)
+	// 再次入队(如协调节点重启)
+	added, err = q.Enqueue(ctx, []string{"/home", "/projects/", "/archive"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, added)
+	// 根目录与所有工作项重叠
+	added, err = q.Enqueue(ctx, []string{"/"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, added)
+
+	status, err := q.Status(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), status.Pending)
+}
+
+// TestWorkQueueClaim 测试多个节点并发领取时每个工作项只被领取一次
+func TestWorkQueueClaim(t *testing.T) {
+	ctx := context.Background()
+	q := openTestQueue(t)
+	var keys []string
+	for i := 0; i < 50; i++ {
+		keys = append(keys, fmt.Sprintf("/dir%d", i))
+	}
+	_, err := q.Enqueue(ctx, keys)
+	assert.NoError(t, err)
+
+	var mu sync.Mutex
+	claimed := make(map[string]string)
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(worker string) {
+			defer wg.Done()
+			for {
+				item, err := q.Claim(ctx, worker, time.Minute)
+				if !assert.NoError(t, err) || item == nil {
+					return
+				}
+				mu.Lock()
+				_, dup := claimed[item.Key]
+				claimed[item.Key] = worker
+				mu.Unlock()
+				assert.False(t, dup, item.Key)
+				assert.NoError(t, q.Complete(ctx, item, 2, 100))
+			}
+		}(fmt.Sprintf("node%d", w))
+	}
+	wg.Wait()
+	assert.Len(t, claimed, 50)
+
+	status, err := q.Status(ctx)
+	assert.NoError(t, err)
+	assert.True(t, status.Finished())
+	assert.Equal(t, int64(50), status.Done)
+	assert.Equal(t, int64(100), status.Files)
+	assert.Equal(t, int64(5000), status.Bytes)
+	var done int64
+	for _, w := range status.Workers {
+		done += w.Done
+	}
+	assert.Equal(t, int64(50), done)
+}
+
+// TestWorkQueueHandoff 测试租约过期的工作项移交给其他节点，原节点不能再完成
+func TestWorkQueueHandoff(t *testing.T) {
+	ctx := context.Background()
+	q := openTestQueue(t)
+	now := time.Now()
+	q.now = func() time.Time { return now }
+	_, err := q.Enqueue(ctx, []string{"/a"})
+	require.NoError(t, err)
+
+	item, err := q.Claim(ctx, "node1", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, item)
+	assert.Equal(t, "/a", item.Key)
+	none, err := q.Claim(ctx, "node2", time.Minute)
+	assert.NoError(t, err)
+	assert.Nil(t, none)
+
+	now = now.Add(2 * time.Minute)
+	status, err := q.Status(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), status.Expired)
+
+	handoff, err := q.Claim(ctx, "node2", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, handoff)
+	assert.Equal(t, "/a", handoff.Key)
+	assert.Equal(t, 2, handoff.Attempts)
+	assert.ErrorIs(t, q.Renew(ctx, item, time.Minute), ErrLeaseLost)
+	assert.ErrorIs(t, q.Complete(ctx, item, 1, 1), ErrLeaseLost)
+	assert.NoError(t, q.Renew(ctx, handoff, time.Minute))
+	assert.NoError(t, q.Complete(ctx, handoff, 1, 1))
+}
+
+// TestWorkQueueLeaseExhausted 测试每次领取后租约都过期(如节点崩溃)的工作项在次数用尽后标记为失败
+func TestWorkQueueLeaseExhausted(t *testing.T) {
+	ctx := context.Background()
+	q := openTestQueue(t)
+	q.MaxAttempts = 2
+	now := time.Now()
+	q.now = func() time.Time { return now }
+	_, err := q.Enqueue(ctx, []string{"/a"})
+	require.NoError(t, err)
+
+	for i, worker := range []string{"node1", "node2"} {
+		item, err := q.Claim(ctx, worker, time.Minute)
+		require.NoError(t, err)
+		require.NotNil(t, item)
+		assert.Equal(t, i+1, item.Attempts)
+		now = now.Add(2 * time.Minute)
+	}
+	item, err := q.Claim(ctx, "node3", time.Minute)
+	assert.NoError(t, err)
+	assert.Nil(t, item)
+
+	status, err := q.Status(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), status.Failed)
+	assert.True(t, status.Finished())
+	failed, err := q.FailedItems(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"/a": "lease of node2 expired"}, failed)
+}
+
+// TestWorkQueueFailures 测试失败的工作项重新排队直到次数用尽，并汇总所有节点的失败文件
+func TestWorkQueueFailures(t *testing.T) {
+	ctx := context.Background()
+	q := openTestQueue(t)
+	q.MaxAttempts = 2
+	_, err := q.Enqueue(ctx, []string{"/a"})
+	assert.NoError(t, err)
+
+	for i, worker := range []string{"node1", "node2"} {
+		item, err := q.Claim(ctx, worker, time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, i+1, item.Attempts)
+		assert.NoError(t, q.RecordFailure(ctx, item, Failure{Source: "/a/x", Destination: "/dst/a/x", Reason: FailureError, Attempts: 1, Err: errors.New("permission denied")}))
+		assert.NoError(t, q.Fail(ctx, item, errors.New("mount lost")))
+	}
+	item, err := q.Claim(ctx, "node3", time.Minute)
+	assert.NoError(t, err)
+	assert.Nil(t, item)
+
+	status, err := q.Status(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), status.Failed)
+	assert.Equal(t, int64(2), status.Failures)
+	assert.True(t, status.Finished())
+	failed, err := q.FailedItems(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"/a": "mount lost"}, failed)
+
+	var buf bytes.Buffer
+	assert.NoError(t, q.WriteFailures(ctx, &buf))
+	records, err := csv.NewReader(&buf).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, []string{"/a/x", "/dst/a/x", FailureError, "1", "permission denied", "node2", "/a"}, records[2][1:])
+}
@@ -0,0 +1,113 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"terrasync/object"
+)
+
+// rangeReader reads a large file with several concurrent ranged reads of one
+// pool buffer each and returns the data in order, so that high latency sources
+// such as object stores and WAN mounts are read at the speed of several streams.
+// At most streams chunks are read or buffered at a time.
+type rangeReader struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	chunks  chan chan rangeChunk // 按偏移排序的分段
+	current *rangeChunk
+	pos     int
+	err     error
+}
+
+// rangeChunk is one ranged read of the file
+type rangeChunk struct {
+	buf *[]byte
+	n   int
+	err error
+}
+
+// newRangeReader starts reading fileInfo with streams concurrent ranged reads
+func newRangeReader(ctx context.Context, fileInfo object.FileInfo, streams int) *rangeReader {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &rangeReader{ctx: ctx, cancel: cancel, chunks: make(chan chan rangeChunk, max(streams-1, 0))}
+	go func() {
+		defer close(r.chunks)
+		size, chunkSize := fileInfo.Size(), int64(object.BufferPoolSize)
+		for offset := int64(0); offset < size; offset += chunkSize {
+			done := make(chan rangeChunk, 1)
+			select {
+			case r.chunks <- done:
+			case <-ctx.Done():
+				return
+			}
+			go func(offset, n int64) {
+				done <- readChunk(fileInfo, offset, n)
+			}(offset, min(chunkSize, size-offset))
+		}
+	}()
+	return r
+}
+
+// readChunk reads n bytes of fileInfo at offset into a pool buffer
+func readChunk(fileInfo object.FileInfo, offset, n int64) rangeChunk {
+	buf := object.GetBuffer()
+	in, err := fileInfo.Get(offset, n)
+	if err == nil {
+		_, err = io.ReadFull(in, (*buf)[:n])
+		in.Close()
+	}
+	if err != nil {
+		object.PutBuffer(buf)
+		return rangeChunk{err: fmt.Errorf("failed to read %s at offset %d: %w", fileInfo.Key(), offset, err)}
+	}
+	return rangeChunk{buf: buf, n: int(n)}
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.current == nil {
+		done, ok := <-r.chunks
+		if !ok {
+			// 取消时分段不完整，不能当作文件结束
+			r.err = io.EOF
+			if err := r.ctx.Err(); err != nil {
+				r.err = err
+			}
+			return 0, r.err
+		}
+		chunk := <-done
+		if chunk.err != nil {
+			r.err = chunk.err
+			return 0, r.err
+		}
+		r.current, r.pos = &chunk, 0
+	}
+	n := copy(p, (*r.current.buf)[r.pos:r.current.n])
+	r.pos += n
+	if r.pos == r.current.n {
+		object.PutBuffer(r.current.buf)
+		r.current = nil
+	}
+	return n, nil
+}
+
+// Close stops reading ahead, the buffers of pending chunks are returned to the
+// pool in the background so a hung read does not block the caller
+func (r *rangeReader) Close() error {
+	r.cancel()
+	if r.current != nil {
+		object.PutBuffer(r.current.buf)
+		r.current = nil
+	}
+	go func() {
+		for done := range r.chunks {
+			if chunk := <-done; chunk.buf != nil {
+				object.PutBuffer(chunk.buf)
+			}
+		}
+	}()
+	return nil
+}
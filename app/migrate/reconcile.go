@@ -0,0 +1,177 @@
+package migrate
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"terrasync/app/scan"
+	"terrasync/log"
+	"terrasync/object"
+)
+
+// ReconcileAction decides what happens when source and destination counts differ
+type ReconcileAction string
+
+const (
+	// ReconcileWarn reports the discrepancies
+	ReconcileWarn ReconcileAction = "warn"
+	// ReconcileFail reports the discrepancies and fails the migration
+	ReconcileFail ReconcileAction = "fail"
+	// ReconcileOff skips the reconciliation
+	ReconcileOff ReconcileAction = "off"
+)
+
+// ParseReconcileAction parses a reconcile action, empty means warn
+func ParseReconcileAction(action string) (ReconcileAction, error) {
+	switch a := ReconcileAction(strings.ToLower(action)); a {
+	case "":
+		return ReconcileWarn, nil
+	case ReconcileWarn, ReconcileFail, ReconcileOff:
+		return a, nil
+	default:
+		return "", fmt.Errorf("unsupported reconcile action %q, expect warn, fail or off", action)
+	}
+}
+
+// ReconcileCount is the files and bytes of a top-level directory on both sides
+type ReconcileCount struct {
+	Dir      string // 第一级目录，根目录下的文件计入"/"
+	SrcFiles int64
+	SrcBytes int64
+	DstFiles int64
+	DstBytes int64
+}
+
+// Match reports whether source and destination hold the same files and bytes
+func (c ReconcileCount) Match() bool {
+	return c.SrcFiles == c.DstFiles && c.SrcBytes == c.DstBytes
+}
+
+// Reconciliation compares the source and destination after a migration
+type Reconciliation struct {
+	Dirs  []ReconcileCount // 按目录排序，目标key被变换时为空，只比较总数
+	Total ReconcileCount
+}
+
+// Discrepancies returns the directories whose counts differ, or the total when
+// the directories are not compared
+func (r *Reconciliation) Discrepancies() []ReconcileCount {
+	var diff []ReconcileCount
+	for _, c := range r.Dirs {
+		if !c.Match() {
+			diff = append(diff, c)
+		}
+	}
+	if len(r.Dirs) == 0 && !r.Total.Match() {
+		diff = append(diff, r.Total)
+	}
+	return diff
+}
+
+// Write writes the counts of every directory and the total as CSV
+func (r *Reconciliation) Write(w io.Writer) error {
+	report := csv.NewWriter(w)
+	_ = report.Write([]string{"dir", "source_files", "source_bytes", "destination_files", "destination_bytes", "match"})
+	for _, c := range append(r.Dirs, r.Total) {
+		_ = report.Write([]string{c.Dir,
+			strconv.FormatInt(c.SrcFiles, 10), strconv.FormatInt(c.SrcBytes, 10),
+			strconv.FormatInt(c.DstFiles, 10), strconv.FormatInt(c.DstBytes, 10),
+			strconv.FormatBool(c.Match())})
+	}
+	report.Flush()
+	return report.Error()
+}
+
+// topDir returns the top-level directory of a key, "/" for files in the root
+func topDir(key string) string {
+	first, rest, found := strings.Cut(strings.TrimPrefix(key, "/"), "/")
+	if !found || rest == "" {
+		return "/"
+	}
+	return "/" + first
+}
+
+// countFiles lists a storage and counts the files and bytes per top-level
+// directory. What could not be listed is logged and left out of the counts,
+// where it shows up as a discrepancy.
+func countFiles(ctx context.Context, s object.Storage, concurrency int) (map[string][2]int64, error) {
+	counts := make(map[string][2]int64)
+	entries, errc := scan.Listing(ctx, s, scan.ListOptions{Concurrency: concurrency})
+	for fileInfo := range entries {
+		if fileInfo.IsDir() || fileInfo.Key() == MarkerKey {
+			continue
+		}
+		dir := topDir(fileInfo.Key())
+		c := counts[dir]
+		c[0]++
+		c[1] += fileInfo.Size()
+		counts[dir] = c
+	}
+	err := <-errc
+	var listErr *scan.ListError
+	if errors.As(err, &listErr) {
+		log.Warnf("Reconciliation counts exclude what could not be listed: %v", err)
+		return counts, nil
+	}
+	return counts, err
+}
+
+// Reconcile lists the source and the destination and compares their files and
+// bytes per top-level directory, the acceptance check of a migration. When
+// destination keys are rewritten, templated or transformed the directories
+// don't correspond and only the totals are compared.
+func Reconcile(ctx context.Context, config *MigrateConfig, src, dst object.Storage) (*Reconciliation, error) {
+	var srcCounts, dstCounts map[string][2]int64
+	var srcErr, dstErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		srcCounts, srcErr = countFiles(ctx, src, config.ListConcurrency)
+	}()
+	go func() {
+		defer wg.Done()
+		dstCounts, dstErr = countFiles(ctx, dst, config.ListConcurrency)
+	}()
+	wg.Wait()
+	if srcErr != nil {
+		return nil, fmt.Errorf("failed to list source: %w", srcErr)
+	}
+	if dstErr != nil {
+		return nil, fmt.Errorf("failed to list destination: %w", dstErr)
+	}
+
+	result := &Reconciliation{Total: ReconcileCount{Dir: "total"}}
+	dirs := make(map[string]*ReconcileCount)
+	get := func(dir string) *ReconcileCount {
+		if dirs[dir] == nil {
+			dirs[dir] = &ReconcileCount{Dir: dir}
+		}
+		return dirs[dir]
+	}
+	for dir, c := range srcCounts {
+		get(dir).SrcFiles, get(dir).SrcBytes = c[0], c[1]
+		result.Total.SrcFiles += c[0]
+		result.Total.SrcBytes += c[1]
+	}
+	for dir, c := range dstCounts {
+		get(dir).DstFiles, get(dir).DstBytes = c[0], c[1]
+		result.Total.DstFiles += c[0]
+		result.Total.DstBytes += c[1]
+	}
+
+	transformed := config.DestTemplate != "" || len(config.Rewrite) > 0 || config.KeyTransform.Enabled()
+	if !transformed {
+		for _, c := range dirs {
+			result.Dirs = append(result.Dirs, *c)
+		}
+		sort.Slice(result.Dirs, func(i, j int) bool { return result.Dirs[i].Dir < result.Dirs[j].Dir })
+	}
+	return result, nil
+}
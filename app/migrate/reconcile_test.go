@@ -0,0 +1,56 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"terrasync/log"
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// TestReconcile 测试按第一级目录比较源和目标的文件数及字节数
+func TestReconcile(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	src, err := object.CreateStorage("mem://test-reconcile-src")
+	assert.NoError(t, err)
+	dst, err := object.CreateStorage("mem://test-reconcile-dst")
+	assert.NoError(t, err)
+	for key, data := range map[string]string{"/a/1.txt": "one", "/a/b/2.txt": "two", "/c/3.txt": "three", "/root.txt": "r"} {
+		assert.NoError(t, src.Put(key, strings.NewReader(data)))
+	}
+	// 目标端/c下的文件不完整，标记文件不计入
+	for key, data := range map[string]string{"/a/1.txt": "one", "/a/b/2.txt": "two", "/c/3.txt": "thr", "/root.txt": "r"} {
+		assert.NoError(t, dst.Put(key, strings.NewReader(data)))
+	}
+	assert.NoError(t, WriteMarker(dst, DestinationMarker{JobID: "job"}))
+
+	config := &MigrateConfig{ListConcurrency: 2}
+	result, err := Reconcile(context.Background(), config, src, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, []ReconcileCount{
+		{Dir: "/", SrcFiles: 1, SrcBytes: 1, DstFiles: 1, DstBytes: 1},
+		{Dir: "/a", SrcFiles: 2, SrcBytes: 6, DstFiles: 2, DstBytes: 6},
+		{Dir: "/c", SrcFiles: 1, SrcBytes: 5, DstFiles: 1, DstBytes: 3},
+	}, result.Dirs)
+	assert.Equal(t, ReconcileCount{Dir: "total", SrcFiles: 4, SrcBytes: 12, DstFiles: 4, DstBytes: 10}, result.Total)
+	diff := result.Discrepancies()
+	assert.Len(t, diff, 1)
+	assert.Equal(t, "/c", diff[0].Dir)
+
+	var buf bytes.Buffer
+	assert.NoError(t, result.Write(&buf))
+	assert.Equal(t, "dir,source_files,source_bytes,destination_files,destination_bytes,match\n"+
+		"/,1,1,1,1,true\n/a,2,6,2,6,true\n/c,1,5,1,3,false\ntotal,4,12,4,10,false\n", buf.String())
+
+	// 目标key被变换时只比较总数
+	config.KeyTransform.DestPrefix = "/archive"
+	result, err = Reconcile(context.Background(), config, src, dst)
+	assert.NoError(t, err)
+	assert.Empty(t, result.Dirs)
+	assert.Equal(t, []ReconcileCount{result.Total}, result.Discrepancies())
+}
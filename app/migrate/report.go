@@ -0,0 +1,89 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"terrasync/app/scan"
+	"terrasync/i18n"
+	"terrasync/pkg/report"
+	"time"
+)
+
+// console prints the migration report to stderr and the log, stdout may be a tar stream
+var console = report.NewPrinter(os.Stderr)
+
+// PrintReport prints the migration summary, jobErr is reported as the job status
+func PrintReport(config *MigrateConfig, result *Result, jobErr error) {
+	fmt.Fprintln(os.Stderr)
+	console.Title("Migrate Statistics")
+
+	console.Header("Command", config.CmdLine)
+	console.Header("Start time", i18n.FormatTime(config.StartTime))
+	totalTime := time.Since(config.StartTime)
+	console.Header("Total time", totalTime.Round(time.Second))
+	console.Header("Source", config.Source)
+	console.Header("Destination", config.Destination)
+	if result != nil && result.Failed > 0 && config.FailureLedger != "" {
+		console.Header("Failures", config.FailureLedger)
+	}
+	if result != nil && result.Rewritten > 0 && config.RewriteReport != "" {
+		console.Header("Rewrite report", config.RewriteReport)
+	}
+	console.Status(jobErr)
+
+	if result == nil {
+		console.Summary(summaryPairs(config, nil, totalTime, jobErr), jobErr)
+		return
+	}
+	snap := result.Stats
+
+	console.Section("Scanned Count")
+	console.Field("Files", snap.Files)
+	console.Field("Directories", snap.Dirs)
+	console.Field("Capacity", scan.FormatFileSize(snap.Bytes))
+
+	console.Section("Transfer")
+	if config.MetadataOnly {
+		console.Field("Metadata updated", result.Updated)
+	} else {
+		console.Field("Copied", snap.Copied)
+		console.Field("Copied size", scan.FormatFileSize(snap.CopiedBytes))
+		console.Field("Throughput", scan.FormatFileSize(int64(snap.CopiedBytesPerSec()))+"/s")
+	}
+	console.Field("Skipped", snap.Skipped)
+	console.Field("Skipped size", scan.FormatFileSize(snap.SkippedBytes))
+	console.Field("Failed", result.Failed)
+	if config.Resume {
+		console.Field("Completed before", result.Resumed)
+	}
+	if config.PropagateDeletes {
+		console.Field("Deleted", result.Deleted)
+	}
+	if result.Rewritten > 0 {
+		console.Field("Rewritten paths", result.Rewritten)
+	}
+	console.Summary(summaryPairs(config, result, totalTime, jobErr), jobErr)
+}
+
+// summaryPairs returns the key=value pairs of the SUMMARY line printed after the
+// report, only the job and its status when the migration did not start
+func summaryPairs(config *MigrateConfig, result *Result, totalTime time.Duration, jobErr error) []report.Pair {
+	pairs := []report.Pair{
+		{Key: "job", Value: config.JobID},
+		{Key: "status", Value: report.StatusOf(jobErr)},
+	}
+	if result != nil {
+		snap := result.Stats
+		pairs = append(pairs,
+			report.Pair{Key: "files", Value: snap.Files},
+			report.Pair{Key: "dirs", Value: snap.Dirs},
+			report.Pair{Key: "bytes", Value: snap.Bytes},
+			report.Pair{Key: "copied", Value: snap.Copied},
+			report.Pair{Key: "copied_bytes", Value: snap.CopiedBytes},
+			report.Pair{Key: "skipped", Value: snap.Skipped},
+			report.Pair{Key: "failed", Value: result.Failed},
+			report.Pair{Key: "deleted", Value: result.Deleted},
+		)
+	}
+	return append(pairs, report.Pair{Key: "elapsed_sec", Value: int64(totalTime.Round(time.Second).Seconds())})
+}
@@ -0,0 +1,130 @@
+package migrate
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// rewriteSeparator separates the pattern from the replacement in a rewrite rule
+const rewriteSeparator = "=>"
+
+// RewriteRule rewrites destination keys matching Pattern.
+// Replacement may reference capture groups with $1 or ${name}.
+type RewriteRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// ParseRewriteRule parses a rule in the form 'regex=>replacement',
+// e.g. '^/home/([^/]+)/=>/users/${1}/'
+func ParseRewriteRule(rule string) (RewriteRule, error) {
+	pattern, replacement, ok := strings.Cut(rule, rewriteSeparator)
+	if !ok || pattern == "" {
+		return RewriteRule{}, fmt.Errorf("invalid rewrite rule %q, expect 'regex=>replacement'", rule)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return RewriteRule{}, fmt.Errorf("invalid rewrite rule %q: %w", rule, err)
+	}
+	return RewriteRule{Pattern: re, Replacement: replacement}, nil
+}
+
+// ParseRewriteRules parses multiple rewrite rules, keeping their order
+func ParseRewriteRules(rules []string) ([]RewriteRule, error) {
+	parsed := make([]RewriteRule, 0, len(rules))
+	for _, rule := range rules {
+		r, err := ParseRewriteRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, r)
+	}
+	return parsed, nil
+}
+
+// Rewriter maps source keys to destination keys.
+// Rules are tried in order and the first matching rule wins. Every rewritten
+// path is appended to the mapping report, if one is given.
+type Rewriter struct {
+	rules []RewriteRule
+
+	mu        sync.Mutex
+	report    *csv.Writer
+	rewritten int64
+}
+
+// NewRewriter creates a rewriter, report receives the mapping report as CSV and may be nil
+func NewRewriter(rules []RewriteRule, report io.Writer) *Rewriter {
+	r := &Rewriter{rules: rules}
+	if report != nil {
+		r.report = csv.NewWriter(report)
+		_ = r.report.Write([]string{"source", "destination", "rule"})
+	}
+	return r
+}
+
+// Len returns the number of rules
+func (r *Rewriter) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.rules)
+}
+
+// Rewrite returns the destination key of key.
+// Keys not matching any rule are returned unchanged.
+func (r *Rewriter) Rewrite(key string) (string, error) {
+	if r.Len() == 0 {
+		return key, nil
+	}
+
+	for _, rule := range r.rules {
+		if !rule.Pattern.MatchString(key) {
+			continue
+		}
+		newKey := rule.Pattern.ReplaceAllString(key, rule.Replacement)
+		if newKey == "" {
+			return "", fmt.Errorf("rewrite rule %s=>%s maps %s to an empty path", rule.Pattern, rule.Replacement, key)
+		}
+		if newKey != key {
+			r.record(key, newKey, rule)
+		}
+		return newKey, nil
+	}
+	return key, nil
+}
+
+// record appends a rewritten path to the mapping report
+func (r *Rewriter) record(key, newKey string, rule RewriteRule) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rewritten++
+	if r.report != nil {
+		_ = r.report.Write([]string{key, newKey, rule.Pattern.String() + rewriteSeparator + rule.Replacement})
+	}
+}
+
+// Rewritten returns the number of rewritten paths
+func (r *Rewriter) Rewritten() int64 {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rewritten
+}
+
+// Flush writes the buffered mapping report
+func (r *Rewriter) Flush() error {
+	if r == nil || r.report == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.Flush()
+	return r.report.Error()
+}
@@ -0,0 +1,89 @@
+package migrate
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseRewriteRule 测试重写规则解析
+func TestParseRewriteRule(t *testing.T) {
+	cases := []struct {
+		name    string
+		rule    string
+		wantErr bool
+	}{
+		{name: "正常规则", rule: `^/home/([^/]+)/=>/users/${1}/`},
+		{name: "替换为空", rule: `\.bak$=>`},
+		{name: "替换串包含=>", rule: `^/a/=>/b=>c/`},
+		{name: "缺少分隔符", rule: `^/home/`, wantErr: true},
+		{name: "空正则", rule: `=>/users/`, wantErr: true},
+		{name: "非法正则", rule: `^/home/(=>/users/`, wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ParseRewriteRule(c.rule)
+			if c.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// TestRewriter 测试目标路径重写及映射报告
+func TestRewriter(t *testing.T) {
+	rules, err := ParseRewriteRules([]string{
+		`^/home/([^/]+)/=>/users/${1}/`,
+		`^/home/=>/legacy/`,
+		`^/tmp/.*$=>`,
+	})
+	assert.NoError(t, err)
+
+	var report bytes.Buffer
+	rewriter := NewRewriter(rules, &report)
+
+	cases := []struct {
+		name    string
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{name: "分组引用", key: "/home/alice/doc.txt", want: "/users/alice/doc.txt"},
+		{name: "第一个匹配的规则生效", key: "/home/bob/", want: "/users/bob/"},
+		{name: "第二条规则", key: "/home/file.txt", want: "/legacy/file.txt"},
+		{name: "不匹配保持不变", key: "/data/a.txt", want: "/data/a.txt"},
+		{name: "重写为空路径报错", key: "/tmp/a", wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := rewriter.Rewrite(c.key)
+			if c.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.want, got)
+		})
+	}
+
+	assert.Equal(t, int64(3), rewriter.Rewritten())
+	assert.NoError(t, rewriter.Flush())
+	assert.Equal(t, "source,destination,rule\n"+
+		"/home/alice/doc.txt,/users/alice/doc.txt,^/home/([^/]+)/=>/users/${1}/\n"+
+		"/home/bob/,/users/bob/,^/home/([^/]+)/=>/users/${1}/\n"+
+		"/home/file.txt,/legacy/file.txt,^/home/=>/legacy/\n", report.String())
+}
+
+// TestNilRewriter 测试未配置规则时保持不变
+func TestNilRewriter(t *testing.T) {
+	var rewriter *Rewriter
+	got, err := rewriter.Rewrite("/a")
+	assert.NoError(t, err)
+	assert.Equal(t, "/a", got)
+	assert.NoError(t, rewriter.Flush())
+}
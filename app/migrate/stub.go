@@ -0,0 +1,76 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"terrasync/log"
+	"terrasync/object"
+	"terrasync/pkg/stats"
+)
+
+// StubPolicy decides how offline stubs of the source are copied
+type StubPolicy string
+
+const (
+	// StubRecall reads a stub to recall it and copies it once online, stubs still
+	// offline are skipped
+	StubRecall StubPolicy = "recall"
+	// StubSkip skips stubs, they are recorded as offline in the failures ledger
+	StubSkip StubPolicy = "skip"
+	// StubCopy copies stubs like other files without checking they are online
+	StubCopy StubPolicy = "copy-stub"
+)
+
+// ParseStubPolicy parses a stub policy, empty means recall
+func ParseStubPolicy(policy string) (StubPolicy, error) {
+	switch p := StubPolicy(strings.ToLower(policy)); p {
+	case "":
+		return StubRecall, nil
+	case StubRecall, StubSkip, StubCopy:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unsupported stub policy %q, expect recall, skip or copy-stub", policy)
+	}
+}
+
+// StubHandler applies the stub policy to the files of the copy pass, so stubs
+// are not copied as tiny files. Skipped stubs are recorded in the failures ledger
+// as FailureOffline and counted as skipped in Stats. A nil StubHandler copies
+// everything.
+type StubHandler struct {
+	Policy  StubPolicy
+	Storage object.Storage // 源端，用于回迁后重新stat
+	Ledger  *FailureLedger
+	Stats   *stats.Stats
+}
+
+// Allow reports whether src is copied to dst. With the recall policy a stub is
+// recalled first and the returned file describes it once online.
+func (h *StubHandler) Allow(ctx context.Context, src object.FileInfo, dst string) (object.FileInfo, bool) {
+	if h == nil || !object.IsStub(src) {
+		return src, true
+	}
+	var err error
+	switch h.Policy {
+	case StubCopy:
+		return src, true
+	case StubRecall:
+		stub, after := recallStub(ctx, h.Storage, src)
+		if stub.Recalled {
+			log.Infof("Recalled stub %s in %v", src.Key(), stub.Elapsed)
+			return after, true
+		}
+		err = stub.Err
+		if err == nil {
+			err = fmt.Errorf("data of the stub is still offline after read")
+		}
+	default:
+		err = fmt.Errorf("data of the stub is offline")
+	}
+
+	log.Warnf("Skip %s: %v", src.Key(), err)
+	h.Stats.AddSkipped(src.Size())
+	h.Ledger.Record(Failure{Source: src.Key(), Destination: dst, Reason: FailureOffline, Err: err})
+	return src, false
+}
@@ -0,0 +1,90 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"terrasync/log"
+	"terrasync/object"
+	"terrasync/pkg/stats"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// TestStubHandler 测试按存根策略处理离线存根
+func TestStubHandler(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	cases := []struct {
+		name    string
+		policy  StubPolicy
+		allowed []string
+		skipped []string
+	}{
+		{name: "回迁后拷贝", policy: StubRecall, allowed: []string{"/online.txt", "/stub.txt"}, skipped: []string{"/stuck.txt"}},
+		{name: "跳过存根", policy: StubSkip, allowed: []string{"/online.txt"}, skipped: []string{"/stub.txt", "/stuck.txt"}},
+		{name: "拷贝存根", policy: StubCopy, allowed: []string{"/online.txt", "/stub.txt", "/stuck.txt"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mem, err := object.CreateStorage("mem://test-stub-" + string(c.policy))
+			assert.NoError(t, err)
+			for _, key := range []string{"/online.txt", "/stub.txt", "/stuck.txt"} {
+				assert.NoError(t, mem.Put(key, strings.NewReader("data")))
+			}
+			src := &tieredStorage{Storage: mem,
+				offline: map[string]bool{"/stub.txt": true, "/stuck.txt": true},
+				stuck:   map[string]bool{"/stuck.txt": true},
+			}
+
+			var report bytes.Buffer
+			config := &MigrateConfig{StubPolicy: c.policy}
+			handler := config.StubHandler(src, NewFailureLedger(&report), stats.New())
+			var allowed, skipped []string
+			for _, key := range []string{"/online.txt", "/stub.txt", "/stuck.txt"} {
+				fileInfo, err := src.Head(key)
+				assert.NoError(t, err)
+				copied, ok := handler.Allow(context.Background(), fileInfo, "/dst"+key)
+				if ok {
+					allowed = append(allowed, key)
+					assert.Equal(t, c.policy == StubCopy && key != "/online.txt", object.IsStub(copied), key)
+				} else {
+					skipped = append(skipped, key)
+				}
+			}
+			assert.Equal(t, c.allowed, allowed)
+			assert.Equal(t, c.skipped, skipped)
+
+			if handler == nil {
+				return
+			}
+			assert.NoError(t, handler.Ledger.Flush())
+			records, err := csv.NewReader(&report).ReadAll()
+			assert.NoError(t, err)
+			assert.Len(t, records, len(c.skipped)+1)
+			for i, key := range c.skipped {
+				assert.Equal(t, []string{key, "/dst" + key, FailureOffline}, records[i+1][1:4])
+			}
+			assert.Equal(t, int64(len(c.skipped)), handler.Stats.Snapshot().Skipped)
+		})
+	}
+}
+
+// TestStubPolicyConfig 测试存根策略的解析及与预读的冲突
+func TestStubPolicyConfig(t *testing.T) {
+	policy, err := ParseStubPolicy("")
+	assert.NoError(t, err)
+	assert.Equal(t, StubRecall, policy)
+	policy, err = ParseStubPolicy("Copy-Stub")
+	assert.NoError(t, err)
+	assert.Equal(t, StubCopy, policy)
+	_, err = ParseStubPolicy("ignore")
+	assert.Error(t, err)
+
+	config := MigrateConfig{Source: "/src", Destination: "/dst", Prewarm: true, StubPolicy: StubCopy}
+	config.ApplyDefaults()
+	assert.ErrorContains(t, config.Validate(), "prewarm cannot be combined")
+}
@@ -0,0 +1,102 @@
+package migrate
+
+import (
+	"fmt"
+	"strings"
+	"terrasync/object"
+	"time"
+)
+
+// Temperature classifies how recently a file was used
+type Temperature string
+
+const (
+	TemperatureHot  Temperature = "hot"
+	TemperatureWarm Temperature = "warm"
+	TemperatureCold Temperature = "cold"
+)
+
+// TemperatureBasis is the time a file's temperature is computed from
+type TemperatureBasis string
+
+const (
+	// BasisATime uses the last access, or the last modification if it is later.
+	// Filesystems mounted with noatime/relatime keep atime behind mtime.
+	BasisATime TemperatureBasis = "atime"
+	// BasisMTime uses the last modification only
+	BasisMTime TemperatureBasis = "mtime"
+)
+
+// Object tags set on migrated objects, lifecycle rules can filter on them
+const (
+	TagTemperature  = "temperature"
+	TagLastAccess   = "last-access"
+	TagLastModified = "last-modified"
+)
+
+// ParseTemperatureBasis parses a temperature basis, empty means atime
+func ParseTemperatureBasis(basis string) (TemperatureBasis, error) {
+	switch b := TemperatureBasis(strings.ToLower(basis)); b {
+	case "":
+		return BasisATime, nil
+	case BasisATime, BasisMTime:
+		return b, nil
+	default:
+		return "", fmt.Errorf("unsupported temperature basis %q, expect atime or mtime", basis)
+	}
+}
+
+// TemperatureTagger tags migrated objects with the temperature and the last
+// access and modification dates of their source files, as seen by the scan.
+// A nil TemperatureTagger does not tag.
+type TemperatureTagger struct {
+	Basis TemperatureBasis
+	Warm  time.Duration // 超过该时间未使用的文件为warm
+	Cold  time.Duration // 超过该时间未使用的文件为cold
+	Now   time.Time     // 计算温度的参考时间，整个迁移使用同一时间
+}
+
+// LastUsed returns the time the temperature of fileInfo is computed from
+func (t *TemperatureTagger) LastUsed(fileInfo object.FileInfo) time.Time {
+	if t.Basis == BasisMTime || fileInfo.ATime().Before(fileInfo.MTime()) {
+		return fileInfo.MTime()
+	}
+	return fileInfo.ATime()
+}
+
+// Classify returns the temperature of fileInfo
+func (t *TemperatureTagger) Classify(fileInfo object.FileInfo) Temperature {
+	idle := t.Now.Sub(t.LastUsed(fileInfo))
+	switch {
+	case idle >= t.Cold:
+		return TemperatureCold
+	case idle >= t.Warm:
+		return TemperatureWarm
+	default:
+		return TemperatureHot
+	}
+}
+
+// Tags returns the object tags of fileInfo, dates are UTC days
+func (t *TemperatureTagger) Tags(fileInfo object.FileInfo) map[string]string {
+	return map[string]string{
+		TagTemperature:  string(t.Classify(fileInfo)),
+		TagLastAccess:   fileInfo.ATime().UTC().Format(time.DateOnly),
+		TagLastModified: fileInfo.MTime().UTC().Format(time.DateOnly),
+	}
+}
+
+// Tag sets the tags of the source file src on the migrated object key of dst
+func (t *TemperatureTagger) Tag(dst object.Storage, key string, src object.FileInfo) error {
+	if t == nil || !src.IsRegular() {
+		return nil
+	}
+	tagger, ok := dst.(object.Tagger)
+	if !ok {
+		return fmt.Errorf("destination storage does not support object tags")
+	}
+	if err := tagger.SetTags(key, t.Tags(src)); err != nil {
+		return fmt.Errorf("failed to tag %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTemperatureClassify 测试按访问/修改时间计算温度
+func TestTemperatureClassify(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	cases := []struct {
+		name  string
+		basis TemperatureBasis
+		atime time.Duration // 距now的时间
+		mtime time.Duration
+		want  Temperature
+	}{
+		{name: "最近访问", basis: BasisATime, atime: day, mtime: 400 * day, want: TemperatureHot},
+		{name: "访问时间落后于修改时间", basis: BasisATime, atime: 400 * day, mtime: 40 * day, want: TemperatureWarm},
+		{name: "长期未访问", basis: BasisATime, atime: 200 * day, mtime: 300 * day, want: TemperatureCold},
+		{name: "按修改时间", basis: BasisMTime, atime: day, mtime: 200 * day, want: TemperatureCold},
+		{name: "刚好达到warm", basis: BasisMTime, atime: 0, mtime: 30 * day, want: TemperatureWarm},
+	}
+
+	storage, err := object.CreateStorage("mem://temperature-classify")
+	assert.NoError(t, err)
+	assert.NoError(t, storage.Put("/a.txt", strings.NewReader("a")))
+	setter := storage.(object.MetadataSetter)
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.NoError(t, setter.SetMetadata("/a.txt", object.Metadata{Perm: 0644, ATime: now.Add(-c.atime), MTime: now.Add(-c.mtime)}))
+			fi, err := storage.Head("/a.txt")
+			assert.NoError(t, err)
+
+			tagger := &TemperatureTagger{Basis: c.basis, Warm: 30 * day, Cold: 180 * day, Now: now}
+			assert.Equal(t, c.want, tagger.Classify(fi))
+		})
+	}
+}
+
+// TestTemperatureTag 测试给目标对象打温度标签
+func TestTemperatureTag(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	src, err := object.CreateStorage("mem://temperature-src")
+	assert.NoError(t, err)
+	dst, err := object.CreateStorage("mem://temperature-dst")
+	assert.NoError(t, err)
+	assert.NoError(t, src.Put("/home/report.pdf", strings.NewReader("pdf")))
+	assert.NoError(t, src.(object.MetadataSetter).SetMetadata("/home/report.pdf", object.Metadata{
+		Perm:  0644,
+		ATime: time.Date(2025, 5, 20, 23, 0, 0, 0, time.FixedZone("CST", 8*3600)),
+		MTime: time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC),
+	}))
+	assert.NoError(t, dst.Put("/archive/report.pdf", strings.NewReader("pdf")))
+	fi, err := src.Head("/home/report.pdf")
+	assert.NoError(t, err)
+
+	config := MigrateConfig{Source: "mem://temperature-src", Destination: "mem://temperature-dst", TagTemperature: true}
+	config.ApplyDefaults()
+	assert.NoError(t, config.Validate())
+	tagger := config.TemperatureTagger(now)
+	assert.NoError(t, tagger.Tag(dst, "/archive/report.pdf", fi))
+
+	tagged, err := dst.Head("/archive/report.pdf")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		TagTemperature:  "hot",
+		TagLastAccess:   "2025-05-20",
+		TagLastModified: "2024-01-02",
+	}, tagged.(object.TagsProvider).Tags())
+
+	// 未启用时不打标签
+	config.TagTemperature = false
+	assert.Nil(t, config.TemperatureTagger(now))
+	assert.NoError(t, config.TemperatureTagger(now).Tag(dst, "/archive/report.pdf", fi))
+
+	// 目标对象不存在
+	assert.Error(t, tagger.Tag(dst, "/archive/missing.pdf", fi))
+}
+
+// TestTemperatureConfig 测试温度标签配置校验
+func TestTemperatureConfig(t *testing.T) {
+	config := MigrateConfig{Source: "/a", Destination: "/b", TagTemperature: true, WarmAfter: 48 * time.Hour, ColdAfter: 24 * time.Hour}
+	config.ApplyDefaults()
+	assert.Error(t, config.Validate())
+
+	_, err := ParseTemperatureBasis("ctime")
+	assert.Error(t, err)
+	basis, err := ParseTemperatureBasis("MTIME")
+	assert.NoError(t, err)
+	assert.Equal(t, BasisMTime, basis)
+}
@@ -0,0 +1,120 @@
+package migrate
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"terrasync/object"
+	"text/template"
+	"time"
+)
+
+// TemplateData is the metadata available to destination key templates
+type TemplateData struct {
+	Key   string      // 源key，如/home/alice/a.txt
+	Dir   string      // 所在目录，如/home/alice
+	Name  string      // 文件名，如a.txt
+	Base  string      // 不含扩展名的文件名，如a
+	Ext   string      // 不含点的扩展名，如txt
+	Size  int64       // 文件大小
+	MTime time.Time   // 修改时间
+	CTime time.Time   // 创建时间
+	ATime time.Time   // 访问时间
+	Perm  os.FileMode // 权限
+}
+
+// newTemplateData extracts the template metadata of a file
+func newTemplateData(fileInfo object.FileInfo) TemplateData {
+	key := path.Clean("/" + filepath.ToSlash(fileInfo.Key()))
+	name := path.Base(key)
+	ext := path.Ext(name)
+	return TemplateData{
+		Key:   key,
+		Dir:   path.Dir(key),
+		Name:  name,
+		Base:  strings.TrimSuffix(name, ext),
+		Ext:   strings.TrimPrefix(ext, "."),
+		Size:  fileInfo.Size(),
+		MTime: fileInfo.MTime(),
+		CTime: fileInfo.CTime(),
+		ATime: fileInfo.ATime(),
+		Perm:  fileInfo.Perm(),
+	}
+}
+
+// templateFuncs are the helper functions available to key templates
+var templateFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	// default returns def when value is empty, e.g. {{default "noext" .Ext}}
+	"default": func(def, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+}
+
+// KeyTemplate builds destination keys from file metadata,
+// e.g. `{{.Ext}}/{{.MTime.Year}}/{{.Key}}`
+type KeyTemplate struct {
+	text string
+	tmpl *template.Template
+}
+
+// ParseKeyTemplate parses a destination key template and validates it by
+// rendering a sample file, so that unknown fields fail at job start
+func ParseKeyTemplate(text string) (*KeyTemplate, error) {
+	tmpl, err := template.New("dest").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination template %q: %w", text, err)
+	}
+	t := &KeyTemplate{text: text, tmpl: tmpl}
+
+	sample := TemplateData{
+		Key:   "/dir/sample.txt",
+		Dir:   "/dir",
+		Name:  "sample.txt",
+		Base:  "sample",
+		Ext:   "txt",
+		Size:  1,
+		MTime: time.Now(),
+		CTime: time.Now(),
+		ATime: time.Now(),
+		Perm:  0644,
+	}
+	if _, err := t.render(sample); err != nil {
+		return nil, fmt.Errorf("invalid destination template %q: %w", text, err)
+	}
+	return t, nil
+}
+
+// String returns the template text
+func (t *KeyTemplate) String() string {
+	return t.text
+}
+
+// Execute renders the destination key of a file
+func (t *KeyTemplate) Execute(fileInfo object.FileInfo) (string, error) {
+	key, err := t.render(newTemplateData(fileInfo))
+	if err != nil {
+		return "", fmt.Errorf("failed to render destination of %s: %w", fileInfo.Key(), err)
+	}
+	return key, nil
+}
+
+func (t *KeyTemplate) render(data TemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(buf.String())
+	if key == "" {
+		return "", fmt.Errorf("template renders an empty key")
+	}
+	// 模板中的{{.Key}}以/开头，清理重复的分隔符
+	return path.Clean("/" + key), nil
+}
@@ -0,0 +1,71 @@
+package migrate
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockFileInfo 测试用文件信息
+type mockFileInfo struct {
+	key   string
+	size  int64
+	mtime time.Time
+	isDir bool
+}
+
+func (m *mockFileInfo) Key() string                                    { return m.key }
+func (m *mockFileInfo) Size() int64                                    { return m.size }
+func (m *mockFileInfo) MTime() time.Time                               { return m.mtime }
+func (m *mockFileInfo) CTime() time.Time                               { return m.mtime }
+func (m *mockFileInfo) ATime() time.Time                               { return m.mtime }
+func (m *mockFileInfo) Perm() os.FileMode                              { return 0644 }
+func (m *mockFileInfo) IsDir() bool                                    { return m.isDir }
+func (m *mockFileInfo) IsSymlink() bool                                { return false }
+func (m *mockFileInfo) IsRegular() bool                                { return !m.isDir }
+func (m *mockFileInfo) IsSticky() bool                                 { return false }
+func (m *mockFileInfo) Get(offset, limit int64) (io.ReadCloser, error) { return nil, nil }
+func (m *mockFileInfo) Delete() error                                  { return nil }
+
+// TestKeyTemplate 测试目标key模板
+func TestKeyTemplate(t *testing.T) {
+	mtime := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name string
+		text string
+		key  string
+		want string
+	}{
+		{name: "按扩展名和年份", text: "{{.Ext}}/{{.MTime.Year}}/{{.Key}}", key: "/home/a/Report.PDF", want: "/PDF/2023/home/a/Report.PDF"},
+		{name: "小写扩展名", text: "{{lower .Ext}}/{{.Name}}", key: "/a/Report.PDF", want: "/pdf/Report.PDF"},
+		{name: "无扩展名使用默认值", text: `{{default "noext" .Ext}}/{{.Base}}`, key: "/a/Makefile", want: "/noext/Makefile"},
+		{name: "按月份归档", text: `{{.MTime.Format "2006/01"}}{{.Dir}}/{{.Name}}`, key: "/x/y.txt", want: "/2023/07/x/y.txt"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tmpl, err := ParseKeyTemplate(c.text)
+			assert.NoError(t, err)
+			got, err := tmpl.Execute(&mockFileInfo{key: c.key, mtime: mtime})
+			assert.NoError(t, err)
+			assert.Equal(t, c.want, got)
+		})
+	}
+}
+
+// TestParseKeyTemplateInvalid 测试任务开始时的模板校验
+func TestParseKeyTemplateInvalid(t *testing.T) {
+	for _, text := range []string{
+		"{{.Ext",            // 语法错误
+		"{{.Extension}}",    // 未知字段
+		"{{.MTime.Decade}}", // 未知方法
+		"{{title .Name}}",   // 未知函数
+		"{{if false}}x{{end}}",
+	} {
+		_, err := ParseKeyTemplate(text)
+		assert.Error(t, err, text)
+	}
+}
@@ -0,0 +1,135 @@
+package migrate
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// CollisionPolicy decides what happens when flattening maps two files to the same key
+type CollisionPolicy string
+
+const (
+	// CollisionRename appends ~N before the extension of the later file
+	CollisionRename CollisionPolicy = "rename"
+	// CollisionSkip keeps the first file and skips the later ones
+	CollisionSkip CollisionPolicy = "skip"
+	// CollisionOverwrite lets the later file overwrite the earlier one
+	CollisionOverwrite CollisionPolicy = "overwrite"
+	// CollisionFail stops the migration
+	CollisionFail CollisionPolicy = "fail"
+)
+
+// ParseCollisionPolicy parses a collision policy, empty means rename
+func ParseCollisionPolicy(policy string) (CollisionPolicy, error) {
+	switch p := CollisionPolicy(strings.ToLower(policy)); p {
+	case "":
+		return CollisionRename, nil
+	case CollisionRename, CollisionSkip, CollisionOverwrite, CollisionFail:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unsupported collision policy %q, expect rename, skip, overwrite or fail", policy)
+	}
+}
+
+// KeyTransform holds the simple destination key transformations.
+// They are applied after the rewrite rules in this order: strip components, flatten, prefix.
+type KeyTransform struct {
+	DestPrefix      string          // 目标key前缀
+	StripComponents int             // 去掉源路径开头的N级目录
+	Flatten         bool            // 去掉目录层级，只保留文件名
+	Collision       CollisionPolicy // 打平后文件名冲突时的处理策略
+}
+
+// Enabled reports whether any transformation is configured
+func (t KeyTransform) Enabled() bool {
+	return t.DestPrefix != "" || t.StripComponents > 0 || t.Flatten
+}
+
+// KeyMapper applies a KeyTransform and tracks flattened keys to detect collisions
+type KeyMapper struct {
+	transform KeyTransform
+
+	mu   sync.Mutex
+	seen map[string]int // 打平后的key -> 已使用次数
+}
+
+// NewKeyMapper creates a key mapper
+func NewKeyMapper(transform KeyTransform) *KeyMapper {
+	if transform.Collision == "" {
+		transform.Collision = CollisionRename
+	}
+	return &KeyMapper{transform: transform, seen: make(map[string]int)}
+}
+
+// Map returns the destination key of key.
+// It returns false when the entry must not be copied: directories when flattening,
+// entries with no more than StripComponents components and skipped collisions.
+func (m *KeyMapper) Map(key string, isDir bool) (string, bool, error) {
+	if m == nil || !m.transform.Enabled() {
+		return key, true, nil
+	}
+
+	// 统一使用/作为分隔符，目标可能是对象存储
+	key = path.Clean("/" + filepath.ToSlash(key))
+
+	if n := m.transform.StripComponents; n > 0 {
+		parts := strings.Split(strings.TrimPrefix(key, "/"), "/")
+		if len(parts) <= n {
+			return "", false, nil
+		}
+		key = "/" + strings.Join(parts[n:], "/")
+	}
+
+	if m.transform.Flatten {
+		if isDir {
+			return "", false, nil
+		}
+		var ok bool
+		var err error
+		if key, ok, err = m.flatten(key); !ok || err != nil {
+			return "", ok, err
+		}
+	}
+
+	if m.transform.DestPrefix != "" {
+		key = path.Join("/", m.transform.DestPrefix, key)
+	}
+	return key, true, nil
+}
+
+// flatten reduces key to its file name and resolves collisions
+func (m *KeyMapper) flatten(key string) (string, bool, error) {
+	name := "/" + path.Base(key)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := m.seen[name]
+	m.seen[name] = count + 1
+	if count == 0 {
+		return name, true, nil
+	}
+
+	switch m.transform.Collision {
+	case CollisionSkip:
+		return "", false, nil
+	case CollisionOverwrite:
+		return name, true, nil
+	case CollisionFail:
+		return "", false, fmt.Errorf("flatten collision: %s maps to existing %s", key, name)
+	}
+
+	// rename: name~1.ext, name~2.ext ... skipping names already taken by other files
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := count; ; i++ {
+		candidate := fmt.Sprintf("%s~%d%s", base, i, ext)
+		if m.seen[candidate] == 0 {
+			m.seen[candidate] = 1
+			return candidate, true, nil
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package migrate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestKeyMapper 测试目标key变换
+func TestKeyMapper(t *testing.T) {
+	type entry struct {
+		key   string
+		isDir bool
+		want  string
+		ok    bool
+	}
+	cases := []struct {
+		name      string
+		transform KeyTransform
+		entries   []entry
+		wantErr   bool
+	}{{
+		name:      "未配置保持不变",
+		transform: KeyTransform{},
+		entries:   []entry{{key: "/a/b.txt", want: "/a/b.txt", ok: true}},
+	}, {
+		name:      "添加前缀",
+		transform: KeyTransform{DestPrefix: "backup/2024/"},
+		entries:   []entry{{key: "/a/b.txt", want: "/backup/2024/a/b.txt", ok: true}},
+	}, {
+		name:      "去掉开头目录",
+		transform: KeyTransform{StripComponents: 2},
+		entries: []entry{
+			{key: "/home/alice/doc/a.txt", want: "/doc/a.txt", ok: true},
+			{key: "/home/alice", isDir: true, ok: false},
+		},
+	}, {
+		name:      "打平并重命名冲突",
+		transform: KeyTransform{Flatten: true},
+		entries: []entry{
+			{key: "/a", isDir: true, ok: false},
+			{key: "/a/x.txt", want: "/x.txt", ok: true},
+			{key: "/b/x.txt", want: "/x~1.txt", ok: true},
+			{key: "/c/x~1.txt", want: "/x~1~1.txt", ok: true},
+			{key: "/d/x.txt", want: "/x~2.txt", ok: true},
+			{key: "/e/README", want: "/README", ok: true},
+			{key: "/f/README", want: "/README~1", ok: true},
+		},
+	}, {
+		name:      "打平冲突跳过",
+		transform: KeyTransform{Flatten: true, Collision: CollisionSkip},
+		entries: []entry{
+			{key: "/a/x.txt", want: "/x.txt", ok: true},
+			{key: "/b/x.txt", ok: false},
+		},
+	}, {
+		name:      "打平冲突覆盖并加前缀",
+		transform: KeyTransform{Flatten: true, Collision: CollisionOverwrite, DestPrefix: "flat"},
+		entries: []entry{
+			{key: "/a/x.txt", want: "/flat/x.txt", ok: true},
+			{key: "/b/x.txt", want: "/flat/x.txt", ok: true},
+		},
+	}, {
+		name:      "打平冲突报错",
+		transform: KeyTransform{Flatten: true, Collision: CollisionFail},
+		entries: []entry{
+			{key: "/a/x.txt", want: "/x.txt", ok: true},
+			{key: "/b/x.txt"},
+		},
+		wantErr: true,
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mapper := NewKeyMapper(c.transform)
+			for i, e := range c.entries {
+				got, ok, err := mapper.Map(e.key, e.isDir)
+				if c.wantErr && i == len(c.entries)-1 {
+					assert.Error(t, err)
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, e.ok, ok, e.key)
+				if ok {
+					assert.Equal(t, e.want, got, e.key)
+				}
+			}
+		})
+	}
+}
+
+// TestParseCollisionPolicy 测试冲突策略解析
+func TestParseCollisionPolicy(t *testing.T) {
+	p, err := ParseCollisionPolicy("")
+	assert.NoError(t, err)
+	assert.Equal(t, CollisionRename, p)
+	p, err = ParseCollisionPolicy("SKIP")
+	assert.NoError(t, err)
+	assert.Equal(t, CollisionSkip, p)
+	_, err = ParseCollisionPolicy("merge")
+	assert.Error(t, err)
+}
@@ -0,0 +1,72 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"terrasync/log"
+	"terrasync/object"
+	"time"
+)
+
+// ChangeDetector re-copies source files whose size or mtime changed while they
+// were being copied. Live migrations of home directories always hit files being
+// written; a file still changing after Retries re-copies keeps its last copy and
+// is flagged as unstable in the failures ledger.
+type ChangeDetector struct {
+	Retries int // 源文件在拷贝期间变化时重新拷贝的次数
+	Ledger  *FailureLedger
+}
+
+// CopyFunc copies the source file described by src to the destination
+type CopyFunc func(ctx context.Context, src object.FileInfo) error
+
+// Copy copies src with copy and checks that the source did not change meanwhile.
+// It returns false if the file was still changing after the last attempt.
+func (d *ChangeDetector) Copy(ctx context.Context, storage object.Storage, src object.FileInfo, dst string, copy CopyFunc) (bool, error) {
+	before := src
+	for attempt := 1; ; attempt++ {
+		if err := copy(ctx, before); err != nil {
+			return false, err
+		}
+
+		// 拷贝期间被删除或无法访问的文件同样视为不稳定
+		after, err := storage.Head(src.Key())
+		if err != nil {
+			return d.unstable(src.Key(), dst, attempt, fmt.Errorf("failed to stat source after copy: %w", err))
+		}
+		if after == nil {
+			return d.unstable(src.Key(), dst, attempt, fmt.Errorf("source deleted during copy"))
+		}
+		change := describeChange(before, after)
+		if change == "" {
+			return true, nil
+		}
+		if attempt > d.Retries {
+			return d.unstable(src.Key(), dst, attempt, fmt.Errorf("source changed during copy: %s", change))
+		}
+		log.Warnf("Re-copy %s, source changed during copy: %s", src.Key(), change)
+		before = after
+	}
+}
+
+// unstable flags a file that kept changing during the copy
+func (d *ChangeDetector) unstable(src, dst string, attempts int, err error) (bool, error) {
+	log.Warnf("Unstable file %s after %d attempts: %v", src, attempts, err)
+	d.Ledger.Record(Failure{Source: src, Destination: dst, Reason: FailureUnstable, Attempts: attempts, Err: err})
+	return false, nil
+}
+
+// describeChange describes the size and mtime changes between two stats of a file, "" if none
+func describeChange(before, after object.FileInfo) string {
+	var change string
+	if before.Size() != after.Size() {
+		change = fmt.Sprintf("size %d -> %d", before.Size(), after.Size())
+	}
+	if !before.MTime().Equal(after.MTime()) {
+		if change != "" {
+			change += ", "
+		}
+		change += fmt.Sprintf("mtime %s -> %s", before.MTime().UTC().Format(time.RFC3339Nano), after.MTime().UTC().Format(time.RFC3339Nano))
+	}
+	return change
+}
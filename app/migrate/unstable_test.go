@@ -0,0 +1,83 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"testing"
+
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestChangeDetector 测试拷贝期间变化的源文件被重新拷贝或标记为不稳定
+func TestChangeDetector(t *testing.T) {
+	cases := []struct {
+		name       string
+		retries    int
+		changes    int // 前几次拷贝期间修改源文件
+		wantStable bool
+		wantCopies int
+	}{
+		{name: "源文件未变化", retries: 2, changes: 0, wantStable: true, wantCopies: 1},
+		{name: "重新拷贝后稳定", retries: 2, changes: 1, wantStable: true, wantCopies: 2},
+		{name: "持续变化标记为不稳定", retries: 2, changes: 5, wantStable: false, wantCopies: 3},
+		{name: "不重新拷贝", retries: 0, changes: 1, wantStable: false, wantCopies: 1},
+	}
+
+	for i, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			storage, err := object.CreateStorage(fmt.Sprintf("mem://unstable-test-%d", i))
+			assert.NoError(t, err)
+			assert.NoError(t, storage.Put("/home/a.log", strings.NewReader("v")))
+			src, err := storage.Head("/home/a.log")
+			assert.NoError(t, err)
+
+			var report bytes.Buffer
+			detector := &ChangeDetector{Retries: c.retries, Ledger: NewFailureLedger(&report)}
+			copies := 0
+			stable, err := detector.Copy(context.Background(), storage, src, "/dst/a.log", func(ctx context.Context, fi object.FileInfo) error {
+				copies++
+				if copies <= c.changes {
+					// 模拟拷贝期间应用继续写入
+					return storage.Put("/home/a.log", strings.NewReader(strings.Repeat("v", copies+1)))
+				}
+				return nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, c.wantStable, stable)
+			assert.Equal(t, c.wantCopies, copies)
+
+			assert.NoError(t, detector.Ledger.Flush())
+			records, err := csv.NewReader(&report).ReadAll()
+			assert.NoError(t, err)
+			if c.wantStable {
+				assert.Len(t, records, 1)
+				return
+			}
+			assert.Len(t, records, 2)
+			assert.Equal(t, []string{"/home/a.log", "/dst/a.log", FailureUnstable, fmt.Sprint(c.wantCopies)}, records[1][1:5])
+			assert.Contains(t, records[1][5], "size")
+		})
+	}
+}
+
+// TestChangeDetectorDeleted 测试拷贝期间被删除的源文件
+func TestChangeDetectorDeleted(t *testing.T) {
+	storage, err := object.CreateStorage("mem://unstable-test-deleted")
+	assert.NoError(t, err)
+	assert.NoError(t, storage.Put("/tmp.swp", strings.NewReader("x")))
+	src, err := storage.Head("/tmp.swp")
+	assert.NoError(t, err)
+
+	detector := &ChangeDetector{Retries: 2, Ledger: NewFailureLedger(nil)}
+	stable, err := detector.Copy(context.Background(), storage, src, "/tmp.swp", func(ctx context.Context, fi object.FileInfo) error {
+		return storage.Delete("/tmp.swp")
+	})
+	assert.NoError(t, err)
+	assert.False(t, stable)
+	assert.Equal(t, int64(1), detector.Ledger.Failed())
+}
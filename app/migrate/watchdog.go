@@ -0,0 +1,141 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"terrasync/log"
+	"terrasync/pkg/stats"
+	"time"
+)
+
+// Errors returned for transfers cancelled by the watchdog
+var (
+	ErrTransferTimeout = errors.New("transfer timed out")
+	ErrTransferStalled = errors.New("transfer stalled")
+)
+
+// TransferFunc copies one file. The data must be read through track so that the
+// watchdog sees the progress of the transfer, and the copy should stop when ctx
+// is cancelled.
+type TransferFunc func(ctx context.Context, track func(io.Reader) io.Reader) error
+
+// Watchdog bounds the duration of single file transfers.
+// A transfer exceeding FileTimeout, or not moving any data for StallTimeout, is
+// cancelled and requeued up to Retries times, then recorded in the failures ledger.
+// The watchdog does not wait for a cancelled transfer to return: reads blocked on a
+// dead SMB session or hung NFS mount never return, and would otherwise hold the
+// worker forever. Reads through track fail as soon as the transfer is cancelled.
+// Copied files with the bytes read through track, and failed files, are counted in Stats.
+type Watchdog struct {
+	FileTimeout  time.Duration // 单文件传输时限，0表示不限制
+	StallTimeout time.Duration // 没有数据进展的时限，0表示不检测
+	Retries      int           // 超时或卡住后重新排队的次数
+	Ledger       *FailureLedger
+	Stats        *stats.Stats
+}
+
+// Run runs transfer under the watchdog, src and dst identify the file in the log and ledger
+func (w *Watchdog) Run(ctx context.Context, src, dst string, transfer TransferFunc) error {
+	var err error
+	attempts := 0
+	for attempts <= w.Retries {
+		attempts++
+		var copied int64
+		copied, err = w.runOnce(ctx, transfer)
+		if err == nil {
+			w.Stats.AddCopied(copied)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		if !errors.Is(err, ErrTransferTimeout) && !errors.Is(err, ErrTransferStalled) {
+			break
+		}
+		if attempts <= w.Retries {
+			log.Warnf("Requeue %s after attempt %d: %v", src, attempts, err)
+		}
+	}
+
+	reason := FailureError
+	switch {
+	case errors.Is(err, ErrTransferTimeout):
+		reason = FailureTimeout
+	case errors.Is(err, ErrTransferStalled):
+		reason = FailureStalled
+	}
+	log.Errorf("Failed to migrate %s after %d attempts: %v", src, attempts, err)
+	w.Stats.AddError()
+	w.Ledger.Record(Failure{Source: src, Destination: dst, Reason: reason, Attempts: attempts, Err: err})
+	return err
+}
+
+// runOnce runs one attempt of transfer and returns the bytes read through track
+func (w *Watchdog) runOnce(parent context.Context, transfer TransferFunc) (int64, error) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	start := time.Now()
+	var lastActive, copied atomic.Int64
+	lastActive.Store(start.UnixNano())
+	track := func(r io.Reader) io.Reader {
+		return &trackedReader{ctx: ctx, r: r, lastActive: &lastActive, copied: &copied}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- transfer(ctx, track)
+	}()
+
+	var deadline <-chan time.Time
+	if w.FileTimeout > 0 {
+		timer := time.NewTimer(w.FileTimeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	var check <-chan time.Time
+	if w.StallTimeout > 0 {
+		ticker := time.NewTicker(max(w.StallTimeout/4, time.Millisecond))
+		defer ticker.Stop()
+		check = ticker.C
+	}
+
+	for {
+		select {
+		case err := <-done:
+			return copied.Load(), err
+		case <-parent.Done():
+			return 0, parent.Err()
+		case <-deadline:
+			return 0, fmt.Errorf("%w after %v", ErrTransferTimeout, w.FileTimeout)
+		case now := <-check:
+			if idle := now.Sub(time.Unix(0, lastActive.Load())); idle >= w.StallTimeout {
+				return 0, fmt.Errorf("%w: no data for %v (running %v)", ErrTransferStalled, idle.Round(time.Millisecond), now.Sub(start).Round(time.Millisecond))
+			}
+		}
+	}
+}
+
+// trackedReader records the time of the last read and the bytes read, and fails
+// once the transfer is cancelled
+type trackedReader struct {
+	ctx        context.Context
+	r          io.Reader
+	lastActive *atomic.Int64
+	copied     *atomic.Int64
+}
+
+func (t *trackedReader) Read(p []byte) (int, error) {
+	if err := t.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.lastActive.Store(time.Now().UnixNano())
+		t.copied.Add(int64(n))
+	}
+	return n, err
+}
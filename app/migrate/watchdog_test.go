@@ -0,0 +1,146 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"terrasync/log"
+	"terrasync/pkg/stats"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func init() {
+	log.Log = zap.NewNop().Sugar()
+}
+
+// blockingReader 读取时一直阻塞，模拟断开的SMB会话
+type blockingReader struct{ block chan struct{} }
+
+func (b *blockingReader) Read(p []byte) (int, error) {
+	<-b.block
+	return 0, io.EOF
+}
+
+// TestWatchdog 测试单文件超时、卡住检测、重新排队及失败记录
+func TestWatchdog(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	cases := []struct {
+		name     string
+		watchdog Watchdog
+		transfer func(attempt int) TransferFunc
+		wantErr  error
+		reason   string
+		attempts string
+	}{
+		{
+			name:     "正常传输",
+			watchdog: Watchdog{FileTimeout: time.Second, StallTimeout: time.Second, Retries: 2},
+			transfer: func(int) TransferFunc {
+				return func(ctx context.Context, track func(io.Reader) io.Reader) error {
+					_, err := io.Copy(io.Discard, track(strings.NewReader("data")))
+					return err
+				}
+			},
+		},
+		{
+			name:     "卡住后重新排队成功",
+			watchdog: Watchdog{StallTimeout: 20 * time.Millisecond, Retries: 2},
+			transfer: func(attempt int) TransferFunc {
+				return func(ctx context.Context, track func(io.Reader) io.Reader) error {
+					if attempt == 1 {
+						_, err := io.Copy(io.Discard, track(&blockingReader{block}))
+						return err
+					}
+					return nil
+				}
+			},
+		},
+		{
+			name:     "卡住超过重试次数",
+			watchdog: Watchdog{StallTimeout: 20 * time.Millisecond, Retries: 1},
+			transfer: func(int) TransferFunc {
+				return func(ctx context.Context, track func(io.Reader) io.Reader) error {
+					_, err := io.Copy(io.Discard, track(&blockingReader{block}))
+					return err
+				}
+			},
+			wantErr:  ErrTransferStalled,
+			reason:   FailureStalled,
+			attempts: "2",
+		},
+		{
+			name:     "超过单文件时限",
+			watchdog: Watchdog{FileTimeout: 30 * time.Millisecond},
+			transfer: func(int) TransferFunc {
+				return func(ctx context.Context, track func(io.Reader) io.Reader) error {
+					// 持续有数据但总时长超限
+					for ctx.Err() == nil {
+						_, _ = track(strings.NewReader("x")).Read(make([]byte, 1))
+						time.Sleep(time.Millisecond)
+					}
+					return ctx.Err()
+				}
+			},
+			wantErr:  ErrTransferTimeout,
+			reason:   FailureTimeout,
+			attempts: "1",
+		},
+		{
+			name:     "其他错误不重新排队",
+			watchdog: Watchdog{StallTimeout: time.Second, Retries: 3},
+			transfer: func(int) TransferFunc {
+				return func(ctx context.Context, track func(io.Reader) io.Reader) error {
+					return errors.New("permission denied")
+				}
+			},
+			wantErr:  errors.New("permission denied"),
+			reason:   FailureError,
+			attempts: "1",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var report bytes.Buffer
+			w := c.watchdog
+			w.Ledger = NewFailureLedger(&report)
+			w.Stats = stats.New()
+			// 被放弃的传输仍在运行，尝试次数需要原子计数
+			var attempt atomic.Int32
+			err := w.Run(context.Background(), "/src/a", "/dst/a", func(ctx context.Context, track func(io.Reader) io.Reader) error {
+				return c.transfer(int(attempt.Add(1)))(ctx, track)
+			})
+			assert.NoError(t, w.Ledger.Flush())
+
+			records, rerr := csv.NewReader(&report).ReadAll()
+			assert.NoError(t, rerr)
+			if c.wantErr == nil {
+				assert.NoError(t, err)
+				assert.Equal(t, int64(0), w.Ledger.Failed())
+				assert.Len(t, records, 1)
+				assert.Equal(t, int64(1), w.Stats.Snapshot().Copied)
+				return
+			}
+			if errors.Is(c.wantErr, ErrTransferStalled) || errors.Is(c.wantErr, ErrTransferTimeout) {
+				assert.ErrorIs(t, err, c.wantErr)
+			} else {
+				assert.EqualError(t, err, c.wantErr.Error())
+			}
+			assert.Equal(t, int64(1), w.Ledger.Failed())
+			assert.Equal(t, int64(1), w.Stats.Snapshot().Errors)
+			assert.Len(t, records, 2)
+			assert.Equal(t, []string{"/src/a", "/dst/a", c.reason, c.attempts}, records[1][1:5])
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"terrasync/app/plan"
+	"terrasync/app/scan"
+	"terrasync/log"
+	"terrasync/object"
+)
+
+// listSource lists the entries to migrate: the whole source, or with
+// config.WaveManifest the entries of the wave as they are on the source now.
+// Wave entries gone from the source since the scan are passed to missing,
+// which may be nil.
+func (c *MigrateConfig) listSource(ctx context.Context, src object.Storage, opts scan.ListOptions, missing func(plan.Entry)) <-chan object.FileInfo {
+	if c.WaveManifest == "" {
+		return scan.ListAll(ctx, src, opts)
+	}
+	return listWave(ctx, src, c.WaveManifest, opts, missing)
+}
+
+// listWave stats the entries of a wave manifest with opts.Concurrency workers and
+// sends those passing the match and exclude conditions and the depth limit
+func listWave(ctx context.Context, src object.Storage, manifest string, opts scan.ListOptions, missing func(plan.Entry)) <-chan object.FileInfo {
+	entries := make(chan plan.Entry, max(opts.Concurrency, 1))
+	results := make(chan object.FileInfo, max(opts.Concurrency, 1))
+	go func() {
+		defer close(entries)
+		err := plan.ReadManifest(manifest, func(entry plan.Entry) error {
+			if opts.Depth > 0 && keyDepth(entry.Path) > opts.Depth {
+				return nil
+			}
+			select {
+			case entries <- entry:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Errorf("Failed to read wave manifest: %v", err)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < max(opts.Concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range entries {
+				fileInfo, err := src.Head(entry.Path)
+				if err != nil {
+					log.Errorf("Scan error: failed to stat %s: %v", entry.Path, err)
+					continue
+				}
+				if fileInfo == nil {
+					if missing != nil {
+						missing(entry)
+					}
+					continue
+				}
+				if !opts.Match.Empty() && !opts.Match.IsSatisfied(fileInfo) {
+					continue
+				}
+				if !opts.Exclude.Empty() && opts.Exclude.IsSatisfied(fileInfo) {
+					continue
+				}
+				select {
+				case results <- fileInfo:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// waveUsage returns the bytes and entries of a wave manifest as scanned
+func waveUsage(manifest string) (SourceUsage, error) {
+	var usage SourceUsage
+	err := plan.ReadManifest(manifest, func(entry plan.Entry) error {
+		usage.Entries++
+		if !entry.IsDir {
+			usage.Bytes += entry.Size
+		}
+		return nil
+	})
+	return usage, err
+}
+
+// missingFromWave records a wave entry deleted from the source since the scan
+func (m *migrator) missingFromWave(entry plan.Entry) {
+	log.Warnf("Skip %s: in the wave manifest but no longer on the source", entry.Path)
+	m.stats.AddSkipped(entry.Size)
+	m.ledger.Record(Failure{Source: entry.Path, Reason: FailureMissing, Err: fmt.Errorf("%s: %w", entry.Path, os.ErrNotExist)})
+}
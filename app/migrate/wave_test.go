@@ -0,0 +1,62 @@
+package migrate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/log"
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// TestMigrateWave 测试只迁移波次清单中的条目，已删除的条目记录为missing
+func TestMigrateWave(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	run := strconv.FormatInt(time.Now().UnixNano(), 36)
+	srcURI, dstURI := "mem://test-migrate-wave-src-"+run, "mem://test-migrate-wave-dst-"+run
+	src, err := object.CreateStorage(srcURI)
+	assert.NoError(t, err)
+	dst, err := object.CreateStorage(dstURI)
+	assert.NoError(t, err)
+	for _, key := range []string{"/hr/a.txt", "/hr/b.log", "/finance/c.txt"} {
+		assert.NoError(t, src.Put(key, strings.NewReader("data")))
+	}
+
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "wave_1.csv")
+	assert.NoError(t, os.WriteFile(manifest, []byte("path,type,size,mtime\n"+
+		"/hr/a.txt,file,4,2024-01-01T00:00:00Z\n"+
+		"/hr/b.log,file,4,2024-01-01T00:00:00Z\n"+
+		"/hr/gone.txt,file,10,2024-01-01T00:00:00Z\n"), 0644))
+
+	config := MigrateConfig{
+		Source:        srcURI,
+		Destination:   dstURI,
+		Exclude:       []string{"name like %.log"},
+		WaveManifest:  manifest,
+		FailureLedger: filepath.Join(dir, "failures.csv"),
+	}
+	config.ApplyDefaults()
+	result, err := Migrate(context.Background(), &config, src, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.Stats.Copied)
+	assert.Equal(t, int64(1), result.Failed)
+	assert.Equal(t, "data", readKey(t, dst, "/hr/a.txt"))
+	assert.Equal(t, "", readKey(t, dst, "/hr/b.log"))
+	assert.Equal(t, "", readKey(t, dst, "/finance/c.txt"))
+	failures, err := os.ReadFile(config.FailureLedger)
+	assert.NoError(t, err)
+	assert.Contains(t, string(failures), "/hr/gone.txt,,missing,")
+
+	// 容量预检按清单中的大小统计
+	usage, err := waveUsage(manifest)
+	assert.NoError(t, err)
+	assert.Equal(t, SourceUsage{Bytes: 18, Entries: 3}, usage)
+}
@@ -0,0 +1,452 @@
+// Package plan splits the entries of a scan job into migration waves by filter
+// rules, writing a manifest and an estimate per wave. Each wave is then
+// migrated on its own with migrate --plan <job> --wave N.
+package plan
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"terrasync/app/scan"
+	"terrasync/db"
+	"terrasync/object"
+	"time"
+)
+
+const (
+	// DirName is the directory of the plan in the scan job directory
+	DirName = "plan"
+	// PlanName is the file describing the waves in the plan directory
+	PlanName = "plan.json"
+)
+
+// Built-in ways of splitting a job into waves
+const (
+	ByDir  = "dir"  // 每个第一级目录(如部门目录)一个波次，按字节数从小到大
+	BySize = "size" // 按文件大小分为large、medium、small
+	ByAge  = "age"  // 按修改时间分为cold、warm、hot
+)
+
+// Defaults of the estimate
+const (
+	DefaultThroughput  = 100 << 20 // 每秒传输的字节数
+	DefaultFilesPerSec = 200       // 每秒创建的条目数，小文件受元数据操作限制
+)
+
+// Config selects the scan job and how its entries are split
+type Config struct {
+	JobDir      string
+	Rules       []string // 波次规则，格式为'name=expr'或'expr'，条目属于第一个匹配的波次
+	By          string   // 在规则之后按dir、size或age生成的波次，为空不生成
+	Throughput  int64    // 估计传输时间的每秒字节数，<=0使用默认值
+	FilesPerSec int64    // 估计传输时间的每秒条目数，<=0使用默认值
+	Now         time.Time
+}
+
+// Wave is one step of the migration
+type Wave struct {
+	Number      int    `json:"number"`
+	Name        string `json:"name"`
+	Rule        string `json:"rule"`
+	Files       int64  `json:"files"`
+	Dirs        int64  `json:"dirs"`
+	Bytes       int64  `json:"bytes"`
+	EstimateSec int64  `json:"estimate_sec"`
+	Manifest    string `json:"manifest"` // 清单文件名，位于计划目录中
+}
+
+// Estimate returns the estimated transfer time of the wave
+func (w Wave) Estimate() time.Duration {
+	return time.Duration(w.EstimateSec) * time.Second
+}
+
+// Plan is the split of a scan job into waves, saved as plan.json
+type Plan struct {
+	JobID       string    `json:"job_id"`
+	Source      string    `json:"source"`
+	By          string    `json:"by,omitempty"`
+	Throughput  int64     `json:"throughput"`
+	FilesPerSec int64     `json:"files_per_sec"`
+	CreatedAt   time.Time `json:"created_at"`
+	Waves       []Wave    `json:"waves"`
+}
+
+// Wave returns the wave numbered n
+func (p *Plan) Wave(n int) (*Wave, error) {
+	for i := range p.Waves {
+		if p.Waves[i].Number == n {
+			return &p.Waves[i], nil
+		}
+	}
+	return nil, fmt.Errorf("wave %d not found, the plan of job %s has %d waves", n, p.JobID, len(p.Waves))
+}
+
+// rule assigns the entries it matches to a wave
+type rule struct {
+	name  string
+	desc  string
+	match func(object.FileInfo) bool
+}
+
+// ruleName splits 'name=expr', the operator == is not a name
+var ruleName = regexp.MustCompile(`^\s*([\w-]+)\s*=([^=].*)$`)
+
+// parseRule parses a wave rule, an unnamed rule is named after its number
+func parseRule(value string, n int) (rule, error) {
+	name, expr := fmt.Sprintf("wave-%d", n), value
+	if m := ruleName.FindStringSubmatch(value); m != nil {
+		name, expr = m[1], m[2]
+	}
+	conditions := scan.ParseConditions(expr)
+	if len(conditions) == 0 {
+		return rule{}, fmt.Errorf("wave rule %q has no condition", value)
+	}
+	filter, err := scan.NewConditionFilter(conditions)
+	if err != nil {
+		return rule{}, fmt.Errorf("invalid wave rule %q: %w", value, err)
+	}
+	return rule{name: name, desc: strings.TrimSpace(expr), match: filter.IsSatisfied}, nil
+}
+
+// builtinRules returns the rules of a built-in split
+func builtinRules(ctx context.Context, dbInstance db.DB, by string) ([]rule, error) {
+	var exprs [][2]string
+	switch by {
+	case "":
+		return nil, nil
+	case ByDir:
+		return dirRules(ctx, dbInstance)
+	case BySize:
+		exprs = [][2]string{{"large", "size >= 1G"}, {"medium", "size >= 1M"}, {"small", "size < 1M"}}
+	case ByAge:
+		// 先迁移不再变化的冷数据
+		exprs = [][2]string{{"cold", "modified < 180d"}, {"warm", "modified < 30d"}, {"hot", "modified >= 30d"}}
+	default:
+		return nil, fmt.Errorf("unsupported wave split %q, expect dir, size or age", by)
+	}
+	rules := make([]rule, 0, len(exprs))
+	for _, e := range exprs {
+		r, err := parseRule(e[0]+"="+e[1], 0)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// dirRules returns a rule per top-level directory, smallest first so the first
+// waves are quick pilots
+func dirRules(ctx context.Context, dbInstance db.DB) ([]rule, error) {
+	bytes := make(map[string]int64)
+	err := dbInstance.ListEntries(ctx, "file_entries", func(entry db.FileInfoData) error {
+		// 第一级的文件不属于任何目录，归入剩余的波次
+		top, _, nested := strings.Cut(strings.TrimPrefix(entry.Key, "/"), "/")
+		switch {
+		case top == "" || !nested && !entry.IsDir:
+		case entry.IsDir:
+			bytes[top] += 0
+		default:
+			bytes[top] += entry.Size
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job entries: %w", err)
+	}
+	dirs := make([]string, 0, len(bytes))
+	for dir := range bytes {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if bytes[dirs[i]] != bytes[dirs[j]] {
+			return bytes[dirs[i]] < bytes[dirs[j]]
+		}
+		return dirs[i] < dirs[j]
+	})
+	rules := make([]rule, 0, len(dirs))
+	for _, dir := range dirs {
+		prefix := "/" + dir
+		rules = append(rules, rule{
+			name: dir,
+			desc: "path under " + prefix,
+			match: func(fileInfo object.FileInfo) bool {
+				return fileInfo.Key() == prefix || strings.HasPrefix(fileInfo.Key(), prefix+"/")
+			},
+		})
+	}
+	return rules, nil
+}
+
+// manifestHeader is the header of the wave manifests
+var manifestHeader = []string{"path", "type", "size", "mtime"}
+
+// waveWriter writes the manifest of a wave
+type waveWriter struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+func newWaveWriter(path string) (*waveWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wave manifest: %w", err)
+	}
+	w := &waveWriter{file: f, writer: csv.NewWriter(f)}
+	if err := w.writer.Write(manifestHeader); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write wave manifest: %w", err)
+	}
+	return w, nil
+}
+
+func (w *waveWriter) Write(entry db.FileInfoData) error {
+	kind := "file"
+	switch {
+	case entry.IsDir:
+		kind = "dir"
+	case entry.IsSymlink:
+		kind = "symlink"
+	}
+	return w.writer.Write([]string{entry.Key, kind, strconv.FormatInt(entry.Size, 10), entry.MTime.UTC().Format(time.RFC3339)})
+}
+
+func (w *waveWriter) Close() error {
+	w.writer.Flush()
+	err := w.writer.Error()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Create splits the entries of the scan job into waves. Every entry belongs to
+// the first wave whose rule it matches, the entries matching no rule form a last
+// wave named remaining. The plan and the wave manifests replace those of an
+// earlier plan of the job.
+func Create(ctx context.Context, config Config) (*Plan, error) {
+	if config.Throughput <= 0 {
+		config.Throughput = DefaultThroughput
+	}
+	if config.FilesPerSec <= 0 {
+		config.FilesPerSec = DefaultFilesPerSec
+	}
+	if config.Now.IsZero() {
+		config.Now = time.Now()
+	}
+	summary, err := scan.LoadJobSummary(config.JobDir)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]rule, 0, len(config.Rules))
+	for i, value := range config.Rules {
+		r, err := parseRule(value, i+1)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	dbInstance, err := scan.NewDB(summary.DbType, config.JobDir)
+	if err != nil {
+		return nil, err
+	}
+	defer (*dbInstance).Close()
+	builtin, err := builtinRules(ctx, *dbInstance, config.By)
+	if err != nil {
+		return nil, err
+	}
+	rules = append(rules, builtin...)
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no wave rules, add --wave rules or --by dir, size or age")
+	}
+	rules = append(rules, rule{name: "remaining", desc: "entries matching no rule"})
+
+	dir := filepath.Join(config.JobDir, DirName)
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("failed to remove previous plan: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create plan directory: %w", err)
+	}
+
+	waves := make([]Wave, len(rules))
+	writers := make([]*waveWriter, len(rules))
+	defer func() {
+		for _, w := range writers {
+			if w != nil {
+				w.Close()
+			}
+		}
+	}()
+	err = (*dbInstance).ListEntries(ctx, "file_entries", func(entry db.FileInfoData) error {
+		info := entryInfo{entry}
+		i := len(rules) - 1
+		for j, r := range rules[:len(rules)-1] {
+			if r.match(info) {
+				i = j
+				break
+			}
+		}
+		if writers[i] == nil {
+			waves[i].Manifest = fmt.Sprintf("wave_%d.csv", i+1)
+			if writers[i], err = newWaveWriter(filepath.Join(dir, waves[i].Manifest)); err != nil {
+				return err
+			}
+		}
+		if entry.IsDir {
+			waves[i].Dirs++
+		} else {
+			waves[i].Files++
+			waves[i].Bytes += entry.Size
+		}
+		if err := writers[i].Write(entry); err != nil {
+			return fmt.Errorf("failed to write wave manifest: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to split job entries into waves: %w", err)
+	}
+	for i, w := range writers {
+		if w == nil {
+			continue
+		}
+		writers[i] = nil
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("failed to write wave manifest: %w", err)
+		}
+	}
+
+	plan := &Plan{
+		JobID:       summary.JobID,
+		Source:      summary.Path,
+		By:          config.By,
+		Throughput:  config.Throughput,
+		FilesPerSec: config.FilesPerSec,
+		CreatedAt:   config.Now.UTC(),
+	}
+	// 没有条目的规则仍保留编号，没有剩余条目时不生成最后的波次
+	for i, r := range rules {
+		if i == len(rules)-1 && waves[i].Manifest == "" {
+			break
+		}
+		w := waves[i]
+		w.Number, w.Name, w.Rule = i+1, r.name, r.desc
+		w.EstimateSec = estimate(w, config.Throughput, config.FilesPerSec)
+		plan.Waves = append(plan.Waves, w)
+	}
+	if err := save(dir, plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// estimate returns the seconds a wave takes at the given rates, bounded by
+// either the bytes or the entries to create
+func estimate(w Wave, throughput, filesPerSec int64) int64 {
+	return max((w.Bytes+throughput-1)/throughput, (w.Files+w.Dirs+filesPerSec-1)/filesPerSec)
+}
+
+func save(dir string, plan *Plan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode plan: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, PlanName), data, 0644); err != nil {
+		return fmt.Errorf("failed to save plan: %w", err)
+	}
+	return nil
+}
+
+// Load reads the plan of a scan job
+func Load(jobDir string) (*Plan, error) {
+	data, err := os.ReadFile(filepath.Join(jobDir, DirName, PlanName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan, create it with terrasync plan: %w", err)
+	}
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to decode plan: %w", err)
+	}
+	return &plan, nil
+}
+
+// ManifestPath returns the path of the manifest of a wave, empty when the wave has no entries
+func ManifestPath(jobDir string, w *Wave) string {
+	if w.Manifest == "" {
+		return ""
+	}
+	return filepath.Join(jobDir, DirName, w.Manifest)
+}
+
+// Entry is one entry of a wave manifest
+type Entry struct {
+	Path  string
+	IsDir bool
+	Size  int64
+}
+
+// ReadManifest calls fn for each entry of a wave manifest, in scan order, and
+// stops at the first error returned by fn
+func ReadManifest(path string, fn func(Entry) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open wave manifest: %w", err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = len(manifestHeader)
+	r.ReuseRecord = true
+	if _, err := r.Read(); err != nil {
+		return fmt.Errorf("failed to read wave manifest %s: %w", path, err)
+	}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read wave manifest %s: %w", path, err)
+		}
+		size, err := strconv.ParseInt(record[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid size in wave manifest %s: %w", path, err)
+		}
+		if err := fn(Entry{Path: record[0], IsDir: record[1] == "dir", Size: size}); err != nil {
+			return err
+		}
+	}
+}
+
+// entryInfo is an entry of the job database matched by the wave rules
+type entryInfo struct {
+	data db.FileInfoData
+}
+
+func (e entryInfo) Key() string       { return e.data.Key }
+func (e entryInfo) Size() int64       { return e.data.Size }
+func (e entryInfo) MTime() time.Time  { return e.data.MTime }
+func (e entryInfo) CTime() time.Time  { return e.data.CTime }
+func (e entryInfo) ATime() time.Time  { return e.data.ATime }
+func (e entryInfo) Perm() os.FileMode { return os.FileMode(e.data.Perm) }
+func (e entryInfo) IsDir() bool       { return e.data.IsDir }
+func (e entryInfo) IsSymlink() bool   { return e.data.IsSymlink }
+func (e entryInfo) IsRegular() bool   { return e.data.IsRegular }
+func (e entryInfo) IsSticky() bool    { return e.Perm()&os.ModeSticky != 0 }
+
+func (e entryInfo) Get(offset, limit int64) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("%s: entries of the job database have no data", e.data.Key)
+}
+
+func (e entryInfo) Delete() error {
+	return fmt.Errorf("%s: entries of the job database can't be deleted", e.data.Key)
+}
@@ -0,0 +1,149 @@
+package plan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/app/scan"
+	"terrasync/log"
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// savePlanJob 保存一个扫描任务，条目为给定大小及修改时间的文件及其目录
+func savePlanJob(t *testing.T, now time.Time) string {
+	ctx := context.Background()
+	src := t.TempDir()
+	files := []struct {
+		key  string
+		size int
+		age  time.Duration
+	}{
+		{"/finance/q1.xlsx", 3000, 400 * 24 * time.Hour},
+		{"/finance/q2.xlsx", 2000, time.Hour},
+		{"/hr/people.csv", 100, 60 * 24 * time.Hour},
+		{"/eng/src/main.go", 20 << 20, time.Hour},
+		{"/readme.txt", 10, time.Hour},
+	}
+	storage, err := object.CreateStorage(src)
+	assert.NoError(t, err)
+	var keys []string
+	for _, f := range files {
+		path := filepath.Join(src, f.key)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(strings.Repeat("x", f.size)), 0644))
+		mtime := now.Add(-f.age)
+		assert.NoError(t, os.Chtimes(path, mtime, mtime))
+		keys = append(keys, f.key)
+	}
+	keys = append(keys, "/finance", "/hr", "/eng", "/eng/src")
+	var entries []object.FileInfo
+	for _, key := range keys {
+		fi, err := storage.Head(key)
+		assert.NoError(t, err)
+		entries = append(entries, fi)
+	}
+
+	jobDir := filepath.Join(t.TempDir(), "Job_1_scan")
+	dbInstance, err := scan.InitDatabase(ctx, "sqlite", jobDir)
+	assert.NoError(t, err)
+	assert.NoError(t, (*dbInstance).SaveEntries(ctx, entries, ""))
+	assert.NoError(t, (*dbInstance).Close())
+	assert.NoError(t, scan.SaveJobSummary(jobDir, scan.JobSummary{JobID: "1", Path: src, DbType: "sqlite", StartTime: now}))
+	return jobDir
+}
+
+// manifestKeys 返回波次清单中的路径
+func manifestKeys(t *testing.T, jobDir string, w *Wave) []string {
+	var keys []string
+	if w.Manifest == "" {
+		return nil
+	}
+	assert.NoError(t, ReadManifest(ManifestPath(jobDir, w), func(e Entry) error {
+		keys = append(keys, e.Path)
+		return nil
+	}))
+	return keys
+}
+
+// TestCreate 测试按规则及内置方式划分波次
+func TestCreate(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	now := time.Now()
+	jobDir := savePlanJob(t, now)
+
+	tests := []struct {
+		name  string
+		rules []string
+		by    string
+		want  []string // 各波次的名称及条目
+	}{
+		{"按部门目录", nil, ByDir, []string{
+			"hr: /hr/people.csv /hr",
+			"finance: /finance/q1.xlsx /finance/q2.xlsx /finance",
+			"eng: /eng/src/main.go /eng /eng/src",
+			"remaining: /readme.txt",
+		}},
+		{"按大小", nil, BySize, []string{
+			"large:",
+			"medium: /eng/src/main.go",
+			"small: /finance/q1.xlsx /finance/q2.xlsx /hr/people.csv /readme.txt /finance /hr /eng /eng/src",
+		}},
+		{"按修改时间", []string{"pilot=path like /hr/%"}, ByAge, []string{
+			"pilot: /hr/people.csv",
+			"cold: /finance/q1.xlsx",
+			"warm:",
+			"hot: /finance/q2.xlsx /eng/src/main.go /readme.txt /finance /hr /eng /eng/src",
+		}},
+		{"未命名规则", []string{"type == dir", "name like %.xlsx"}, "", []string{
+			"wave-1: /finance /hr /eng /eng/src",
+			"wave-2: /finance/q1.xlsx /finance/q2.xlsx",
+			"remaining: /hr/people.csv /eng/src/main.go /readme.txt",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := Create(context.Background(), Config{JobDir: jobDir, Rules: tt.rules, By: tt.by, Now: now})
+			assert.NoError(t, err)
+			var got []string
+			for i := range plan.Waves {
+				w := &plan.Waves[i]
+				assert.Equal(t, i+1, w.Number)
+				got = append(got, strings.TrimSpace(w.Name+": "+strings.Join(manifestKeys(t, jobDir, w), " ")))
+			}
+			assert.Equal(t, tt.want, got)
+
+			loaded, err := Load(jobDir)
+			assert.NoError(t, err)
+			assert.Equal(t, plan.Waves, loaded.Waves)
+			assert.Equal(t, "1", loaded.JobID)
+		})
+	}
+
+	// 重新划分时删除之前的清单
+	assert.NoFileExists(t, filepath.Join(jobDir, DirName, "wave_4.csv"))
+
+	// 统计及估计时间
+	plan, err := Create(context.Background(), Config{JobDir: jobDir, By: ByDir, Throughput: 1 << 20, FilesPerSec: 1})
+	assert.NoError(t, err)
+	eng, err := plan.Wave(3)
+	assert.NoError(t, err)
+	assert.Equal(t, Wave{Number: 3, Name: "eng", Rule: "path under /eng", Files: 1, Dirs: 2, Bytes: 20 << 20, EstimateSec: 20, Manifest: "wave_3.csv"}, *eng)
+	_, err = plan.Wave(5)
+	assert.Error(t, err)
+
+	for _, rules := range [][]string{{"pilot="}, {"size >> 1"}} {
+		_, err := Create(context.Background(), Config{JobDir: jobDir, Rules: rules})
+		assert.Error(t, err)
+	}
+	_, err = Create(context.Background(), Config{JobDir: jobDir})
+	assert.Error(t, err)
+	_, err = Create(context.Background(), Config{JobDir: jobDir, By: "owner"})
+	assert.Error(t, err)
+}
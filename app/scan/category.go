@@ -0,0 +1,138 @@
+package scan
+
+import (
+	"fmt"
+	"mime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// fileCategories are the categories of the extension rollups, in report
+// order. The labels are translated in the reports, the names are used in the
+// categories section of the configuration.
+var fileCategories = []struct {
+	name  string
+	label string
+}{
+	{"documents", "Documents"},
+	{"media", "Media"},
+	{"code", "Code"},
+	{"vm_images", "VM images"},
+	{"databases", "Databases"},
+	{"archives", "Archives"},
+	{"other", "Other"},
+}
+
+// otherCategory is the index of the category of the unknown extensions
+var otherCategory = len(fileCategories) - 1
+
+// defaultCategoryExtensions is the built-in extension dictionary
+var defaultCategoryExtensions = map[string][]string{
+	"documents": {".doc", ".docx", ".dot", ".odt", ".rtf", ".txt", ".md", ".pdf", ".xls", ".xlsx", ".xlsm", ".csv", ".ods",
+		".ppt", ".pptx", ".odp", ".vsd", ".vsdx", ".pages", ".numbers", ".epub", ".tex", ".msg", ".eml", ".one", ".wps", ".et", ".dps"},
+	"media": {".jpg", ".jpeg", ".png", ".gif", ".bmp", ".tif", ".tiff", ".webp", ".heic", ".svg", ".psd", ".raw", ".cr2", ".nef", ".dng",
+		".mp3", ".wav", ".flac", ".aac", ".m4a", ".ogg", ".wma", ".mp4", ".mov", ".avi", ".mkv", ".wmv", ".flv", ".m4v", ".mpg", ".mpeg", ".mxf"},
+	"code": {".go", ".c", ".h", ".cc", ".cpp", ".hpp", ".cs", ".java", ".class", ".jar", ".py", ".pyc", ".js", ".mjs", ".jsx", ".ts", ".tsx",
+		".rb", ".php", ".pl", ".rs", ".swift", ".kt", ".scala", ".sh", ".ps1", ".bat", ".sql", ".html", ".htm", ".css", ".xml", ".json",
+		".yaml", ".yml", ".toml", ".ini", ".o", ".so", ".dll", ".exe", ".lib", ".a"},
+	"vm_images": {".vmdk", ".vhd", ".vhdx", ".qcow2", ".img", ".iso", ".ova", ".ovf", ".vdi", ".nvram", ".vmsn", ".vmem", ".avhdx"},
+	"databases": {".db", ".sqlite", ".sqlite3", ".mdb", ".accdb", ".mdf", ".ldf", ".ndf", ".bak", ".dbf", ".ibd", ".frm", ".myd", ".myi",
+		".dmp", ".ora", ".nsf", ".pst", ".ost", ".wal"},
+	"archives": {".zip", ".rar", ".7z", ".tar", ".gz", ".tgz", ".bz2", ".xz", ".zst", ".lz4", ".cab", ".z"},
+}
+
+var (
+	categoryMu sync.RWMutex
+	// categoryIndex maps a lower case extension to its index in fileCategories
+	categoryIndex = buildCategoryIndex(nil)
+)
+
+// buildCategoryIndex builds the dictionary of the built-in extensions and the
+// extra extensions, which take precedence
+func buildCategoryIndex(extra map[string][]string) map[string]int {
+	index := make(map[string]int)
+	for _, dict := range []map[string][]string{defaultCategoryExtensions, extra} {
+		for i, c := range fileCategories {
+			for _, ext := range dict[c.name] {
+				index[normalizeExt(ext)] = i
+			}
+		}
+	}
+	return index
+}
+
+// normalizeExt returns the lower case extension with its leading dot
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// SetCategories adds extensions to the categories of the rollups, such as
+// {"media": [".r3d"]}, replacing the category of a built-in extension
+func SetCategories(extra map[string][]string) error {
+	names := make([]string, 0, len(fileCategories))
+	for _, c := range fileCategories {
+		names = append(names, c.name)
+	}
+	for name := range extra {
+		if !slices.Contains(names, name) {
+			return fmt.Errorf("unknown file category %q, expect one of %s", name, strings.Join(names, ", "))
+		}
+	}
+	index := buildCategoryIndex(extra)
+	categoryMu.Lock()
+	defer categoryMu.Unlock()
+	categoryIndex = index
+	return nil
+}
+
+// categoryOf returns the index in fileCategories of an extension, looked up
+// in the dictionary first and by the MIME type of the extension otherwise
+func categoryOf(ext string) int {
+	ext = normalizeExt(ext)
+	if ext == "" {
+		return otherCategory
+	}
+	categoryMu.RLock()
+	i, ok := categoryIndex[ext]
+	categoryMu.RUnlock()
+	if ok {
+		return i
+	}
+
+	mediaType, _, _ := strings.Cut(mime.TypeByExtension(ext), ";")
+	switch {
+	case strings.HasPrefix(mediaType, "image/"), strings.HasPrefix(mediaType, "audio/"), strings.HasPrefix(mediaType, "video/"):
+		return categoryIndexOf("media")
+	case strings.HasPrefix(mediaType, "text/"), strings.Contains(mediaType, "officedocument"), strings.Contains(mediaType, "opendocument"):
+		return categoryIndexOf("documents")
+	}
+	return otherCategory
+}
+
+func categoryIndexOf(name string) int {
+	for i, c := range fileCategories {
+		if c.name == name {
+			return i
+		}
+	}
+	return otherCategory
+}
+
+// Category returns the name of the category of an extension, such as media for .MP4
+func Category(ext string) string {
+	return fileCategories[categoryOf(ext)].name
+}
+
+// newCategoryHistogram returns the histogram of the files per category
+func newCategoryHistogram() Histogram {
+	labels := make([]string, len(fileCategories))
+	for i, c := range fileCategories {
+		labels[i] = c.label
+	}
+	return newHistogram(labels)
+}
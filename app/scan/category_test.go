@@ -0,0 +1,39 @@
+package scan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCategory 测试按扩展名字典、MIME类型及配置的扩展名分类
+func TestCategory(t *testing.T) {
+	defer SetCategories(nil)
+
+	tests := []struct {
+		name string
+		ext  string
+		want string
+	}{
+		{"文档", ".docx", "documents"},
+		{"大写扩展名", ".MP4", "media"},
+		{"不带点", "vmdk", "vm_images"},
+		{"数据库", ".mdf", "databases"},
+		{"压缩包", ".tgz", "archives"},
+		{"代码", ".go", "code"},
+		{"无扩展名", "", "other"},
+		{"未知扩展名", ".zzq", "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Category(tt.ext))
+		})
+	}
+
+	// 配置的扩展名优先于内置字典
+	assert.NoError(t, SetCategories(map[string][]string{"media": {"R3D"}, "documents": {".go"}}))
+	assert.Equal(t, "media", Category(".r3d"))
+	assert.Equal(t, "documents", Category(".go"))
+	assert.Error(t, SetCategories(map[string][]string{"videos": {".mp4"}}))
+	assert.Equal(t, "media", Category(".r3d"))
+}
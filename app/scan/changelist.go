@@ -0,0 +1,124 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"terrasync/changelist"
+	"terrasync/db"
+	"terrasync/log"
+	"terrasync/object"
+)
+
+// ChangeListing returns the entries reported by a vendor change list as the
+// source of an incremental scan, so the tree is not walked at all. The match and
+// exclude filters of opts apply as in ListAll. Deleted entries are only counted,
+// incremental scans report new and changed files. Reading the data of an entry
+// reads the file as it is now in the storage. The error channel receives the
+// error of the change list, or nil, once it is read.
+func ChangeListing(ctx context.Context, source changelist.Source, storage object.Storage, opts ListOptions) (<-chan object.FileInfo, <-chan error) {
+	results := make(chan object.FileInfo, listQueueLen)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(results)
+		var entries, deleted int64
+		err := source.Changes(ctx, func(change changelist.Change) error {
+			if change.Type == changelist.Deleted {
+				deleted++
+				return nil
+			}
+			perm := change.Perm
+			entry := &snapshotEntry{storage: storage, data: db.FileInfoData{
+				Key:       change.Key,
+				Size:      change.Size,
+				CTime:     change.CTime,
+				MTime:     change.MTime,
+				ATime:     change.ATime,
+				Perm:      int(perm),
+				IsSymlink: perm&os.ModeSymlink != 0,
+				IsDir:     change.IsDir,
+				IsRegular: perm.IsRegular() && !change.IsDir,
+			}}
+			matchOk := opts.Match.Empty() || opts.Match.IsSatisfied(entry)
+			excludeOk := !opts.Exclude.Empty() && opts.Exclude.IsSatisfied(entry)
+			if !matchOk || excludeOk {
+				return nil
+			}
+			select {
+			case results <- entry:
+				entries++
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		log.Infof("Change list reported %d entries and %d deletions", entries, deleted)
+		errc <- err
+	}()
+	return results, errc
+}
+
+// changeCursor is the position of a change list read from where the previous
+// run stopped, saved in the job database once the scan succeeded
+type changeCursor struct {
+	name     string // 变更列表的名称，如usn:C:
+	position string // 本次运行开始时变更列表的末尾
+}
+
+// positionChangeList sets the position saved by the previous run on a change
+// list implementing changelist.Cursor, such as the NTFS USN journal. since is
+// false when the changes since the previous run are not available, because no
+// position was saved yet or the journal expired, and the tree has to be walked.
+// The returned cursor is nil for change lists between two points of the URI.
+func positionChangeList(ctx context.Context, scanConfig ScanConfig) (cursor *changeCursor, since bool, err error) {
+	source, ok := scanConfig.ChangeList.(changelist.Cursor)
+	if !ok {
+		return nil, true, nil
+	}
+	dbInstance, err := NewDB(scanConfig.DbType, scanConfig.JobDir)
+	if err != nil {
+		return nil, false, err
+	}
+	defer (*dbInstance).Close()
+	cursors, err := (*dbInstance).ListCursors(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 先取得变更列表的末尾，扫描期间的变化下次会再次读取
+	cursor = &changeCursor{name: source.CursorName()}
+	if cursor.position, err = source.Position(ctx); err != nil {
+		return nil, false, fmt.Errorf("failed to get position of change list %s: %w", cursor.name, err)
+	}
+	saved, ok := cursors[cursor.name]
+	if !ok {
+		return cursor, false, nil
+	}
+	if err := source.Since(saved); err != nil {
+		if errors.Is(err, changelist.ErrCursorExpired) {
+			log.Warnf("%v", err)
+			return cursor, false, nil
+		}
+		return nil, false, err
+	}
+	log.Infof("Reading change list %s from %s to %s", cursor.name, saved, cursor.position)
+	return cursor, true, nil
+}
+
+// save saves the position in the job database, where the next run starts
+func (c *changeCursor) save(ctx context.Context, scanConfig ScanConfig) error {
+	if c == nil {
+		return nil
+	}
+	dbInstance, err := NewDB(scanConfig.DbType, scanConfig.JobDir)
+	if err != nil {
+		return err
+	}
+	defer (*dbInstance).Close()
+	if err := (*dbInstance).SaveCursor(ctx, c.name, c.position); err != nil {
+		return err
+	}
+	log.Infof("Saved position %s of change list %s", c.position, c.name)
+	return nil
+}
@@ -0,0 +1,135 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"terrasync/changelist"
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeChangeList 返回固定的变更
+type fakeChangeList struct {
+	changes []changelist.Change
+	err     error
+}
+
+func (s *fakeChangeList) Changes(ctx context.Context, fn func(changelist.Change) error) error {
+	for _, change := range s.changes {
+		if err := fn(change); err != nil {
+			return err
+		}
+	}
+	return s.err
+}
+
+func (s *fakeChangeList) Close() error {
+	return nil
+}
+
+// TestChangeListing 测试从变更列表读取增量扫描的条目
+func TestChangeListing(t *testing.T) {
+	ctx := context.Background()
+	storage, err := object.CreateStorage("mem://changelist-test")
+	assert.NoError(t, err)
+	assert.NoError(t, storage.Put("/dir/a.txt", strings.NewReader("data")))
+
+	source := &fakeChangeList{changes: []changelist.Change{
+		{Type: changelist.Added, Key: "/dir/a.txt", Size: 4, Perm: 0644},
+		{Type: changelist.Modified, Key: "/dir", IsDir: true, Perm: 0755},
+		{Type: changelist.Added, Key: "/dir/b.log", Size: 1, Perm: 0644},
+		{Type: changelist.Deleted, Key: "/gone.txt"},
+	}}
+	exclude, err := NewConditionFilter(ParseConditions("name like '%.log'"))
+	assert.NoError(t, err)
+
+	entries, errc := ChangeListing(ctx, source, storage, ListOptions{Exclude: exclude})
+	var keys []string
+	for fi := range entries {
+		keys = append(keys, fi.Key())
+		if fi.Key() == "/dir/a.txt" {
+			assert.True(t, fi.IsRegular())
+			in, err := fi.Get(0, -1)
+			assert.NoError(t, err)
+			data, _ := io.ReadAll(in)
+			in.Close()
+			assert.Equal(t, "data", string(data))
+		}
+		if fi.Key() == "/dir" {
+			assert.True(t, fi.IsDir())
+		}
+	}
+	assert.NoError(t, <-errc)
+	sort.Strings(keys)
+	assert.Equal(t, []string{"/dir", "/dir/a.txt"}, keys)
+
+	// 读取变更列表失败时返回错误
+	source.err = errors.New("401 Unauthorized")
+	entries, errc = ChangeListing(ctx, source, storage, ListOptions{})
+	for range entries {
+	}
+	assert.ErrorContains(t, <-errc, "401")
+}
+
+// fakeCursorList 是从上次运行的位置读取的变更列表
+type fakeCursorList struct {
+	fakeChangeList
+	position string
+	since    string
+	expired  bool
+}
+
+func (s *fakeCursorList) CursorName() string {
+	return "usn:D:"
+}
+
+func (s *fakeCursorList) Position(ctx context.Context) (string, error) {
+	return s.position, nil
+}
+
+func (s *fakeCursorList) Since(position string) error {
+	if s.expired {
+		return fmt.Errorf("%w: journal recreated", changelist.ErrCursorExpired)
+	}
+	s.since = position
+	return nil
+}
+
+// TestPositionChangeList 测试在任务数据库中保存及读取变更日志的位置
+func TestPositionChangeList(t *testing.T) {
+	ctx := context.Background()
+	source := &fakeCursorList{position: "7:100"}
+	scanConfig := ScanConfig{DbType: "sqlite", JobDir: t.TempDir(), ChangeList: source}
+
+	// 第一次运行没有保存的位置，需要遍历目录树
+	cursor, since, err := positionChangeList(ctx, scanConfig)
+	assert.NoError(t, err)
+	assert.False(t, since)
+	assert.NoError(t, cursor.save(ctx, scanConfig))
+
+	source.position = "7:200"
+	cursor, since, err = positionChangeList(ctx, scanConfig)
+	assert.NoError(t, err)
+	assert.True(t, since)
+	assert.Equal(t, "7:100", source.since)
+	assert.Equal(t, "7:200", cursor.position)
+
+	// 日志过期时同样遍历目录树
+	source.expired = true
+	_, since, err = positionChangeList(ctx, scanConfig)
+	assert.NoError(t, err)
+	assert.False(t, since)
+
+	// 两个时间点之间的变更列表不保存位置
+	cursor, since, err = positionChangeList(ctx, ScanConfig{ChangeList: &fakeChangeList{}})
+	assert.NoError(t, err)
+	assert.True(t, since)
+	assert.Nil(t, cursor)
+}
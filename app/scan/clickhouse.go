@@ -0,0 +1,225 @@
+package scan
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"terrasync/db"
+	"terrasync/log"
+	"terrasync/object"
+	"time"
+)
+
+const (
+	defaultClickHouseTable      = "file_entries"
+	defaultClickHouseBatchSize  = 100000
+	defaultClickHouseMaxLatency = 10 * time.Second
+	defaultClickHouseTimeout    = 2 * time.Minute
+
+	// clickHouseTimeFormat is accepted by DateTime64 columns in JSONEachRow
+	clickHouseTimeFormat = "2006-01-02 15:04:05.000"
+)
+
+// clickHouseName matches the database and table names used without quoting
+var clickHouseName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ClickHouseConfig configures the ClickHouse sink. Entries are inserted in large
+// batches through the HTTP interface (port 8123, or 8443 with TLS) as gzip
+// compressed JSONEachRow, so scans of billions of files can be analysed in a
+// columnar table instead of the SQLite job database. Zero values use the defaults.
+type ClickHouseConfig struct {
+	URL        string        // HTTP接口地址，例如http://clickhouse:8123，为空表示不启用
+	Database   string        // 数据库，为空时使用用户的默认数据库
+	Table      string        // 表名，默认file_entries，不存在时自动创建
+	User       string        // 用户名，为空时使用default用户
+	Password   string        // 密码
+	BatchSize  int           // 每次INSERT的行数
+	MaxLatency time.Duration // 未满的批次等待超过该时间时写入
+	Timeout    time.Duration // 单次INSERT的超时
+	Retries    int           // 失败的INSERT按指数退避重试的次数，<0表示不重试
+}
+
+// Enabled reports whether a ClickHouse URL is configured
+func (c ClickHouseConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+// Validate checks the URL and the database and table names
+func (c ClickHouseConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid clickhouse url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid clickhouse url %q, expect the HTTP interface such as http://host:8123", c.URL)
+	}
+	for _, name := range []string{c.Database, c.Table} {
+		if name != "" && !clickHouseName.MatchString(name) {
+			return fmt.Errorf("invalid clickhouse database or table name %q", name)
+		}
+	}
+	return nil
+}
+
+// withDefaults fills in the defaults of the zero values
+func (c ClickHouseConfig) withDefaults() ClickHouseConfig {
+	if c.Table == "" {
+		c.Table = defaultClickHouseTable
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultClickHouseBatchSize
+	}
+	if c.MaxLatency <= 0 {
+		c.MaxLatency = defaultClickHouseMaxLatency
+	}
+	if c.Retries == 0 {
+		c.Retries = defaultWebhookRetries
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultClickHouseTimeout
+	}
+	return c
+}
+
+// clickHouseRow is a row of the entries table
+type clickHouseRow struct {
+	JobID     string `json:"job_id"`
+	Path      string `json:"path"`
+	Ext       string `json:"ext"`
+	Size      int64  `json:"size"`
+	Perm      int    `json:"perm"`
+	IsDir     bool   `json:"is_dir"`
+	IsSymlink bool   `json:"is_symlink"`
+	IsRegular bool   `json:"is_regular"`
+	Attrs     uint32 `json:"attrs"`
+	MTime     string `json:"mtime"`
+	CTime     string `json:"ctime"`
+	ATime     string `json:"atime"`
+}
+
+// clickHouseSink inserts the scanned entries into a ClickHouse table
+type clickHouseSink struct {
+	config ClickHouseConfig
+	jobID  string
+	poster *httpPoster
+	header http.Header
+}
+
+// newClickHouseSink creates the sink of a validated config and creates the
+// table when it doesn't exist
+func newClickHouseSink(ctx context.Context, config ClickHouseConfig, jobID string) (*clickHouseSink, error) {
+	config = config.withDefaults()
+	s := &clickHouseSink{
+		config: config,
+		jobID:  jobID,
+		poster: newHTTPPoster(config.Timeout, config.Retries, defaultWebhookRetryWait),
+		header: http.Header{},
+	}
+	if config.User != "" {
+		s.header.Set("X-ClickHouse-User", config.User)
+	}
+	if config.Password != "" {
+		s.header.Set("X-ClickHouse-Key", config.Password)
+	}
+	if err := s.poster.post(ctx, s.queryURL(nil), s.header, []byte(s.createTable()), "clickhouse create table"); err != nil {
+		return nil, fmt.Errorf("failed to create clickhouse table %s: %w", s.table(), err)
+	}
+	log.Infof("Inserting entries into clickhouse table %s", s.table())
+	return s, nil
+}
+
+// table returns the qualified table name
+func (s *clickHouseSink) table() string {
+	if s.config.Database == "" {
+		return s.config.Table
+	}
+	return s.config.Database + "." + s.config.Table
+}
+
+// createTable returns the DDL of the entries table, ordered by job and path so
+// the entries of a job are stored together
+func (s *clickHouseSink) createTable() string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	job_id LowCardinality(String),
+	path String,
+	ext LowCardinality(String),
+	size UInt64,
+	perm UInt32,
+	is_dir Bool,
+	is_symlink Bool,
+	is_regular Bool,
+	attrs UInt32,
+	mtime DateTime64(3, 'UTC'),
+	ctime DateTime64(3, 'UTC'),
+	atime DateTime64(3, 'UTC')
+) ENGINE = MergeTree ORDER BY (job_id, path)`, s.table())
+}
+
+// queryURL returns the URL of the HTTP interface with the query parameters
+func (s *clickHouseSink) queryURL(params url.Values) string {
+	u, _ := url.Parse(s.config.URL)
+	if params == nil {
+		params = url.Values{}
+	}
+	if s.config.Database != "" {
+		params.Set("database", s.config.Database)
+	}
+	u.RawQuery = params.Encode()
+	return u.String()
+}
+
+// sinkConfig returns the dispatcher config of the sink
+func (s *clickHouseSink) sinkConfig() SinkConfig {
+	return SinkConfig{
+		Sink:       s,
+		QueueLen:   s.config.BatchSize,
+		MaxBatch:   s.config.BatchSize,
+		MaxLatency: s.config.MaxLatency,
+		Overflow:   OverflowBlock,
+	}
+}
+
+func (s *clickHouseSink) Name() string {
+	return "clickhouse"
+}
+
+func (s *clickHouseSink) Write(ctx context.Context, batch []object.FileInfo) error {
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	enc := json.NewEncoder(zw)
+	for _, fileInfo := range batch {
+		data := db.ProcessFileInfo(fileInfo)
+		if err := enc.Encode(clickHouseRow{
+			JobID:     s.jobID,
+			Path:      data.Key,
+			Ext:       data.Ext,
+			Size:      data.Size,
+			Perm:      data.Perm,
+			IsDir:     data.IsDir,
+			IsSymlink: data.IsSymlink,
+			IsRegular: data.IsRegular,
+			Attrs:     uint32(data.Attrs),
+			MTime:     data.MTime.UTC().Format(clickHouseTimeFormat),
+			CTime:     data.CTime.UTC().Format(clickHouseTimeFormat),
+			ATime:     data.ATime.UTC().Format(clickHouseTimeFormat),
+		}); err != nil {
+			return fmt.Errorf("failed to encode clickhouse row: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress clickhouse rows: %w", err)
+	}
+
+	header := s.header.Clone()
+	header.Set("Content-Encoding", "gzip")
+	target := s.queryURL(url.Values{"query": {fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", s.table())}})
+	return s.poster.post(ctx, target, header, body.Bytes(), fmt.Sprintf("clickhouse insert of %d rows", len(batch)))
+}
@@ -0,0 +1,106 @@
+package scan
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// clickHouseServer 模拟ClickHouse的HTTP接口，记录执行的语句及插入的行
+type clickHouseServer struct {
+	mu      sync.Mutex
+	queries []string
+	rows    []clickHouseRow
+	user    string
+}
+
+func (s *clickHouseServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user = r.Header.Get("X-ClickHouse-User")
+	query := r.URL.Query().Get("query")
+	if query == "" {
+		// 没有query参数时语句在请求体中
+		body, _ := io.ReadAll(r.Body)
+		query = string(body)
+	} else {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		scanner := bufio.NewScanner(zr)
+		for scanner.Scan() {
+			var row clickHouseRow
+			if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.rows = append(s.rows, row)
+		}
+	}
+	s.queries = append(s.queries, strings.Fields(query)[0]+" "+r.URL.Query().Get("database"))
+}
+
+// TestClickHouseSink 测试创建表并按批次插入
+func TestClickHouseSink(t *testing.T) {
+	server := &clickHouseServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	config := ClickHouseConfig{URL: ts.URL, Database: "analytics", User: "scanner", BatchSize: 2}
+	assert.NoError(t, config.Validate())
+	sink, err := newClickHouseSink(context.Background(), config, "job")
+	assert.NoError(t, err)
+	assert.Contains(t, sink.createTable(), "CREATE TABLE IF NOT EXISTS analytics.file_entries")
+
+	dispatcher, err := NewDispatcher(context.Background(), sink.sinkConfig())
+	assert.NoError(t, err)
+	for _, fileInfo := range dispatchEntries(t, 3) {
+		dispatcher.Dispatch(fileInfo)
+	}
+	stats := dispatcher.Close()
+	assert.NoError(t, stats[0].Err)
+
+	assert.Equal(t, []string{"CREATE analytics", "INSERT analytics", "INSERT analytics"}, server.queries)
+	assert.Equal(t, "scanner", server.user)
+	assert.Len(t, server.rows, 3)
+	assert.Equal(t, "job", server.rows[0].JobID)
+	assert.Equal(t, "/f0.txt", server.rows[0].Path)
+	assert.Equal(t, ".txt", server.rows[0].Ext)
+	assert.Equal(t, int64(4), server.rows[0].Size)
+	assert.Len(t, server.rows[0].MTime, len(clickHouseTimeFormat))
+}
+
+// TestClickHouseConfigValidate 测试ClickHouse配置的校验
+func TestClickHouseConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ClickHouseConfig
+		wantErr bool
+	}{
+		{"未启用", ClickHouseConfig{}, false},
+		{"HTTP接口", ClickHouseConfig{URL: "http://clickhouse:8123", Database: "analytics", Table: "scan_entries"}, false},
+		{"原生协议地址", ClickHouseConfig{URL: "tcp://clickhouse:9000"}, true},
+		{"非法表名", ClickHouseConfig{URL: "http://clickhouse:8123", Table: "entries; DROP TABLE x"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
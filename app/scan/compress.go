@@ -0,0 +1,271 @@
+package scan
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"terrasync/i18n"
+	"terrasync/log"
+	"terrasync/object"
+	"terrasync/pkg/report"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// compressBlockSize is the size of each block sampled from a file
+	compressBlockSize = 64 << 10
+	// compressBlocks is the number of blocks sampled from the start, middle and end of a file
+	compressBlocks = 3
+	// maxCompressGroups limits the extensions and directories listed in the report
+	maxCompressGroups = 10
+)
+
+// CompressionGroup is the compression estimate of the files sharing an extension or directory
+type CompressionGroup struct {
+	Files      int64 `json:"files"`      // 文件数
+	Size       int64 `json:"size"`       // 所有文件的总大小
+	Sampled    int64 `json:"sampled"`    // 采样的字节数
+	Compressed int64 `json:"compressed"` // 采样数据压缩后的字节数
+}
+
+// Ratio returns the compressed size of the sampled data relative to its size, 1 if nothing was sampled
+func (g CompressionGroup) Ratio() float64 {
+	if g.Sampled == 0 {
+		return 1
+	}
+	return float64(g.Compressed) / float64(g.Sampled)
+}
+
+// Savings returns the bytes expected to be saved by compressing all files of the group
+func (g CompressionGroup) Savings() int64 {
+	return int64(float64(g.Size) * (1 - g.Ratio()))
+}
+
+func (g *CompressionGroup) add(o CompressionGroup) {
+	g.Files += o.Files
+	g.Size += o.Size
+	g.Sampled += o.Sampled
+	g.Compressed += o.Compressed
+}
+
+// CompressionStats is the compressibility estimated from sampled file contents
+type CompressionStats struct {
+	SampleRate   float64                     `json:"sample_rate"`
+	SampledFiles int64                       `json:"sampled_files"`
+	Total        CompressionGroup            `json:"total"`
+	Extensions   map[string]CompressionGroup `json:"extensions"`
+	Dirs         map[string]CompressionGroup `json:"dirs"` // 按第一级目录汇总
+}
+
+// merge adds the estimate of another part of the tree
+func (c *CompressionStats) merge(o *CompressionStats) {
+	c.SampledFiles += o.SampledFiles
+	c.Total.add(o.Total)
+	c.Extensions = mergeGroups(c.Extensions, o.Extensions)
+	c.Dirs = mergeGroups(c.Dirs, o.Dirs)
+}
+
+func mergeGroups(dst, src map[string]CompressionGroup) map[string]CompressionGroup {
+	if dst == nil {
+		dst = make(map[string]CompressionGroup, len(src))
+	}
+	for key, g := range src {
+		merged := dst[key]
+		merged.add(g)
+		dst[key] = merged
+	}
+	return dst
+}
+
+// CompressionEstimator samples blocks of a fraction of the scanned files and
+// compresses them with zstd at its fastest level, which is close to what inline
+// compression of storage systems achieves, to estimate the expected savings per
+// extension and top-level directory.
+type CompressionEstimator struct {
+	rate    float64
+	encoder *zstd.Encoder
+	queue   chan object.FileInfo
+	wg      sync.WaitGroup
+
+	mu    sync.Mutex
+	stats CompressionStats
+}
+
+// NewCompressionEstimator samples about rate (0~1] of the regular files with concurrency workers
+func NewCompressionEstimator(rate float64, concurrency int) (*CompressionEstimator, error) {
+	if rate <= 0 || rate > 1 {
+		return nil, fmt.Errorf("compression sample rate must be in (0, 1]: %v", rate)
+	}
+	concurrency = max(concurrency, 1)
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithEncoderConcurrency(concurrency))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+	}
+
+	e := &CompressionEstimator{
+		rate:    rate,
+		encoder: encoder,
+		queue:   make(chan object.FileInfo, concurrency*2),
+		stats: CompressionStats{
+			SampleRate: rate,
+			Extensions: make(map[string]CompressionGroup),
+			Dirs:       make(map[string]CompressionGroup),
+		},
+	}
+	for i := 0; i < concurrency; i++ {
+		e.wg.Add(1)
+		go func() {
+			defer e.wg.Done()
+			for fileInfo := range e.queue {
+				sampled, compressed, err := e.sample(fileInfo)
+				if err != nil {
+					log.Warnf("Failed to sample %s for compression: %v", fileInfo.Key(), err)
+					continue
+				}
+				e.record(fileInfo, CompressionGroup{Sampled: sampled, Compressed: compressed}, true)
+			}
+		}()
+	}
+	return e, nil
+}
+
+// Add counts a scanned entry and queues regular files selected for sampling.
+// Files are selected by a hash of their key, so repeated scans sample the same files.
+func (e *CompressionEstimator) Add(fileInfo object.FileInfo) {
+	if e == nil || !fileInfo.IsRegular() {
+		return
+	}
+	e.record(fileInfo, CompressionGroup{Files: 1, Size: fileInfo.Size()}, false)
+	if fileInfo.Size() == 0 {
+		return
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(fileInfo.Key()))
+	if float64(h.Sum32()%10000) < e.rate*10000 {
+		e.queue <- fileInfo
+	}
+}
+
+// Close waits for the queued samples and returns the estimate
+func (e *CompressionEstimator) Close() *CompressionStats {
+	if e == nil {
+		return nil
+	}
+	close(e.queue)
+	e.wg.Wait()
+	e.encoder.Close()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	stats := e.stats
+	return &stats
+}
+
+// record adds the counts of one file to the total, its extension and its top-level directory
+func (e *CompressionEstimator) record(fileInfo object.FileInfo, counts CompressionGroup, sampled bool) {
+	ext := strings.ToLower(filepath.Ext(fileInfo.Key()))
+	dir := topLevelDir(fileInfo.Key())
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if sampled {
+		e.stats.SampledFiles++
+	}
+	e.stats.Total.add(counts)
+	g := e.stats.Extensions[ext]
+	g.add(counts)
+	e.stats.Extensions[ext] = g
+	g = e.stats.Dirs[dir]
+	g.add(counts)
+	e.stats.Dirs[dir] = g
+}
+
+// sample compresses blocks from the start, middle and end of the file
+func (e *CompressionEstimator) sample(fileInfo object.FileInfo) (int64, int64, error) {
+	size := fileInfo.Size()
+	offsets := []int64{0}
+	if size > compressBlocks*compressBlockSize {
+		offsets = append(offsets, size/2-compressBlockSize/2, size-compressBlockSize)
+	}
+
+	var sampled, compressed int64
+	buf := make([]byte, compressBlockSize*compressBlocks)
+	for _, offset := range offsets {
+		limit := int64(len(buf))
+		if len(offsets) > 1 {
+			limit = compressBlockSize
+		}
+		in, err := fileInfo.Get(offset, limit)
+		if err != nil {
+			return 0, 0, err
+		}
+		n, err := io.ReadFull(in, buf[:limit])
+		in.Close()
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return 0, 0, err
+		}
+		sampled += int64(n)
+		// 与存储系统的在线压缩一样，压缩后没有变小的块按原样保存
+		compressed += int64(min(len(e.encoder.EncodeAll(buf[:n], nil)), n))
+	}
+	return sampled, compressed, nil
+}
+
+// topLevelDir returns the first path component of key, "/" for files in the root
+func topLevelDir(key string) string {
+	key = strings.TrimPrefix(filepath.ToSlash(key), "/")
+	if dir, _, ok := strings.Cut(key, "/"); ok {
+		return "/" + dir
+	}
+	return "/"
+}
+
+// Print prints the estimate with the extensions and directories saving the most
+func (c *CompressionStats) Print() {
+	console.Section("Compression Estimate")
+	console.Field("Sampled files", c.SampledFiles)
+	console.Field("Sampled", FormatFileSize(c.Total.Sampled))
+	console.Field("Ratio", fmt.Sprintf("%.2f", c.Total.Ratio()))
+	console.Field("Expected savings", fmt.Sprintf("%s (%.1f%%)", FormatFileSize(c.Total.Savings()), (1-c.Total.Ratio())*100))
+
+	printCompressionGroups("Extension", c.Extensions)
+	printCompressionGroups("Directory", c.Dirs)
+}
+
+// printCompressionGroups prints the groups with the highest expected savings
+func printCompressionGroups(title string, groups map[string]CompressionGroup) {
+	names := make([]string, 0, len(groups))
+	for name, g := range groups {
+		if g.Sampled > 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	sort.Slice(names, func(i, j int) bool {
+		si, sj := groups[names[i]].Savings(), groups[names[j]].Savings()
+		if si != sj {
+			return si > sj
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > maxCompressGroups {
+		names = names[:maxCompressGroups]
+	}
+
+	console.Printf("\n  %s %12s %8s %12s\n", i18n.Pad(i18n.T(title), report.LabelWidth(28)), i18n.T("Total"), i18n.T("Ratio"), i18n.T("Savings"))
+	for _, name := range names {
+		g := groups[name]
+		label := name
+		if label == "" {
+			label = i18n.T("(none)")
+		}
+		console.Printf("  %s %12s %8.2f %12s\n", i18n.Pad(label, report.LabelWidth(28)), FormatFileSize(g.Size), g.Ratio(), FormatFileSize(g.Savings()))
+	}
+}
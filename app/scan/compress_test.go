@@ -0,0 +1,113 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCompressionEstimator 测试按扩展名和目录估算压缩率
+func TestCompressionEstimator(t *testing.T) {
+	storage, err := object.CreateStorage("mem://compress-test")
+	assert.NoError(t, err)
+
+	random := make([]byte, 300<<10)
+	rand.New(rand.NewSource(1)).Read(random)
+	files := map[string][]byte{
+		"/logs/app.log":    []byte(strings.Repeat("INFO request handled in 3ms\n", 20000)),
+		"/logs/small.log":  []byte(strings.Repeat("a", 1000)),
+		"/media/video.mp4": random,
+		"/media/empty.mp4": nil,
+		"/readme":          []byte("hello hello hello hello"),
+	}
+	for key, data := range files {
+		assert.NoError(t, storage.Put(key, bytes.NewReader(data)))
+	}
+
+	estimator, err := NewCompressionEstimator(1, 2)
+	assert.NoError(t, err)
+	for key := range files {
+		fi, err := storage.Head(key)
+		assert.NoError(t, err)
+		estimator.Add(fi)
+	}
+	dir, err := storage.Head("/logs/")
+	assert.NoError(t, err)
+	if dir != nil {
+		estimator.Add(dir)
+	}
+	stats := estimator.Close()
+
+	assert.Equal(t, int64(4), stats.SampledFiles)
+	assert.Equal(t, int64(5), stats.Total.Files)
+
+	logs := stats.Extensions[".log"]
+	assert.Equal(t, int64(2), logs.Files)
+	assert.Less(t, logs.Ratio(), 0.1)
+	// 大文件只采样开头、中间和结尾三个块
+	assert.Equal(t, int64(3*compressBlockSize+1000), logs.Sampled)
+
+	mp4 := stats.Extensions[".mp4"]
+	assert.Equal(t, 1.0, mp4.Ratio())
+	assert.Equal(t, int64(len(random)), mp4.Size)
+
+	// 无法压缩的数据按原样计算，预计节省不为负数
+	assert.Equal(t, 1.0, stats.Extensions[""].Ratio())
+	assert.Equal(t, int64(2), stats.Dirs["/logs"].Files)
+	assert.Equal(t, int64(1), stats.Dirs["/"].Files)
+	assert.Equal(t, stats.Extensions[""], stats.Dirs["/"])
+	assert.Greater(t, stats.Total.Savings(), int64(0))
+
+	// 统计随扫描摘要保存和还原
+	s := NewStats()
+	s.SetCompression(stats)
+	assert.Equal(t, stats, s.Snapshot().Stats().GetCompression())
+	s.Print()
+
+	_, err = NewCompressionEstimator(1.5, 1)
+	assert.Error(t, err)
+}
+
+// TestTopLevelDir 测试第一级目录
+func TestTopLevelDir(t *testing.T) {
+	cases := []struct {
+		key  string
+		want string
+	}{
+		{"/a/b/c.txt", "/a"},
+		{"/a/c.txt", "/a"},
+		{"/c.txt", "/"},
+		{"c.txt", "/"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, topLevelDir(c.key), c.key)
+	}
+}
+
+// TestCompressionSampleSelection 测试按key哈希采样，重复扫描采样相同文件
+func TestCompressionSampleSelection(t *testing.T) {
+	storage, err := object.CreateStorage("mem://compress-test-rate")
+	assert.NoError(t, err)
+	for i := 0; i < 400; i++ {
+		assert.NoError(t, storage.Put(fmt.Sprintf("/d%d/f%d.txt", i%7, i), strings.NewReader("data")))
+	}
+
+	count := func() int64 {
+		estimator, err := NewCompressionEstimator(0.25, 4)
+		assert.NoError(t, err)
+		for fi := range ListAll(context.Background(), storage, ListOptions{Concurrency: 2}) {
+			estimator.Add(fi)
+		}
+		return estimator.Close().SampledFiles
+	}
+	first := count()
+	assert.Equal(t, first, count())
+	assert.InDelta(t, 100, first, 40)
+}
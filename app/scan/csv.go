@@ -0,0 +1,197 @@
+package scan
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"terrasync/db"
+	"terrasync/i18n"
+	"terrasync/object"
+	"time"
+)
+
+// CSVReportName is the file name of the CSV report in the job directory
+const CSVReportName = "report.csv"
+
+// SummaryCSVName is the file name of the CSV report of the statistics totals
+const SummaryCSVName = "summary.csv"
+
+// ExtensionsCSVName is the file name of the CSV report of the extension breakdown
+const ExtensionsCSVName = "extensions.csv"
+
+// csvHeader lists the report columns. Sizes and times have a raw column that
+// spreadsheets sort correctly (bytes, Unix seconds) and a *_human column for reading.
+var csvHeader = []string{
+	"path", "type", "ext",
+	"size", "size_human",
+	"mtime", "mtime_human",
+	"ctime", "ctime_human",
+	"atime", "atime_human",
+	"perm",
+}
+
+// csvReport writes the scanned entries as CSV
+type csvReport struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+// ParseCSVDelimiter parses the field delimiter of the CSV reports, a single
+// character or "tab"; empty selects the comma
+func ParseCSVDelimiter(value string) (rune, error) {
+	switch value {
+	case "":
+		return ',', nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	runes := []rune(value)
+	if len(runes) != 1 || strings.ContainsRune("\"\r\n\uFFFD", runes[0]) {
+		return 0, fmt.Errorf("invalid CSV delimiter %q, expect a single character other than a quote or newline", value)
+	}
+	return runes[0], nil
+}
+
+// newCSVWriter returns a CSV writer of f with the delimiter, 0 for the comma
+func newCSVWriter(f *os.File, delimiter rune) *csv.Writer {
+	w := csv.NewWriter(f)
+	if delimiter != 0 {
+		w.Comma = delimiter
+	}
+	return w
+}
+
+// newCSVReport creates the CSV report at path and writes the header
+func newCSVReport(path string, delimiter rune) (*csvReport, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CSV report: %w", err)
+	}
+	r := &csvReport{file: f, writer: newCSVWriter(f, delimiter)}
+	if err := r.writer.Write(csvHeader); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to write CSV report: %w", err)
+	}
+	return r, nil
+}
+
+// Write appends one scanned entry, path is the full path shown to the user
+func (r *csvReport) Write(path string, fileInfo object.FileInfo) error {
+	var ext string
+	if !fileInfo.IsDir() {
+		ext = filepath.Ext(fileInfo.Key())
+	}
+	return r.writer.Write(csvRecord(path, entryType(fileInfo.IsDir(), fileInfo.IsSymlink()), ext, fileInfo.Size(),
+		fileInfo.MTime(), fileInfo.CTime(), fileInfo.ATime(), fileInfo.Perm()))
+}
+
+// WriteEntry appends one entry saved in the job database
+func (r *csvReport) WriteEntry(path string, entry db.FileInfoData) error {
+	return r.writer.Write(csvRecord(path, entryType(entry.IsDir, entry.IsSymlink), entry.Ext, entry.Size,
+		entry.MTime, entry.CTime, entry.ATime, os.FileMode(entry.Perm)))
+}
+
+// Close flushes and closes the report
+func (r *csvReport) Close() error {
+	r.writer.Flush()
+	err := r.writer.Error()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func entryType(isDir, isSymlink bool) string {
+	switch {
+	case isDir:
+		return "dir"
+	case isSymlink:
+		return "symlink"
+	}
+	return "file"
+}
+
+// csvRecord formats one entry in the order of csvHeader
+func csvRecord(path, entryType, ext string, size int64, mtime, ctime, atime time.Time, perm os.FileMode) []string {
+	return []string{
+		path, entryType, ext,
+		strconv.FormatInt(size, 10), FormatFileSize(size),
+		strconv.FormatInt(mtime.Unix(), 10), i18n.FormatTime(mtime),
+		strconv.FormatInt(ctime.Unix(), 10), i18n.FormatTime(ctime),
+		strconv.FormatInt(atime.Unix(), 10), i18n.FormatTime(atime),
+		perm.String(),
+	}
+}
+
+// writeSummaryCSV writes the totals of the statistics of a scan job as metric,
+// value rows, the CSV counterpart of the console report
+func writeSummaryCSV(path string, delimiter rune, summary *JobSummary) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create summary CSV report: %w", err)
+	}
+	defer f.Close()
+
+	snap := summary.Stats
+	stats := snap.Stats()
+	status := "succeeded"
+	if summary.Error != "" {
+		status = "failed: " + summary.Error
+	}
+	rows := [][]string{
+		{"metric", "value"},
+		{"job_id", summary.JobID},
+		{"path", summary.Path},
+		{"status", status},
+		{"start_time", i18n.FormatTime(summary.StartTime)},
+		{"end_time", i18n.FormatTime(summary.EndTime)},
+		{"duration_seconds", strconv.FormatInt(int64(summary.EndTime.Sub(summary.StartTime).Seconds()), 10)},
+		{"files", strconv.FormatInt(snap.FileCount, 10)},
+		{"regular_files", strconv.FormatInt(snap.TotalRegularFile, 10)},
+		{"symlinks", strconv.FormatInt(snap.TotalSymlink, 10)},
+		{"dirs", strconv.FormatInt(snap.DirCount, 10)},
+		{"skipped", strconv.FormatInt(snap.SkippedCount, 10)},
+		{"total_size", strconv.FormatInt(snap.TotalSize, 10)},
+		{"total_size_human", FormatFileSize(snap.TotalSize)},
+		{"file_types", strconv.Itoa(summary.FileTypes)},
+		{"avg_name_length", strconv.Itoa(stats.GetAvgNameLength())},
+		{"max_name_length", strconv.Itoa(snap.MaxNameLength)},
+		{"avg_dir_depth", strconv.Itoa(stats.GetAvgDirDepth())},
+		{"max_dir_depth", strconv.Itoa(snap.MaxDirDepth)},
+		{"max_dir_entries", strconv.FormatInt(snap.MaxDirEntries, 10)},
+		{"huge_dirs", strconv.FormatInt(snap.HugeDirCount, 10)},
+		{"stubs", strconv.FormatInt(snap.StubCount, 10)},
+		{"stub_size", strconv.FormatInt(snap.StubBytes, 10)},
+		{"empty_dirs", strconv.FormatInt(snap.EmptyDirCount, 10)},
+		{"empty_files", strconv.FormatInt(snap.EmptyFileCount, 10)},
+	}
+	w := newCSVWriter(f, delimiter)
+	if err := w.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write summary CSV report: %w", err)
+	}
+	return f.Close()
+}
+
+// writeExtensionsCSV writes the extension breakdown of a scan job, one row per
+// extension with the remaining extensions summed up in the * row
+func writeExtensionsCSV(path string, delimiter rune, summary *JobSummary) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create extensions CSV report: %w", err)
+	}
+	defer f.Close()
+
+	rows := [][]string{{"ext", "files", "bytes", "bytes_human", "avg_bytes"}}
+	for _, usage := range summary.Extensions {
+		rows = append(rows, []string{usage.Ext, strconv.FormatInt(usage.Files, 10),
+			strconv.FormatInt(usage.Bytes, 10), FormatFileSize(usage.Bytes), strconv.FormatInt(usage.AvgSize(), 10)})
+	}
+	w := newCSVWriter(f, delimiter)
+	if err := w.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write extensions CSV report: %w", err)
+	}
+	return f.Close()
+}
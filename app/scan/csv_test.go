@@ -0,0 +1,133 @@
+package scan
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"terrasync/i18n"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCSVReport 测试CSV报告同时包含原始值和可读列
+func TestCSVReport(t *testing.T) {
+	assert.NoError(t, i18n.SetTimezone("UTC"))
+	defer i18n.SetTimezone("Local")
+
+	mtime := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	file := &MockFileInfo{key: "/a/b.txt", _size: 1536, _mtime: mtime, _ctime: mtime, _atime: mtime, _fileMode: 0644}
+	file.On("IsSymlink").Return(false)
+	dir := &MockFileInfo{key: "/a", _isDir: true, _mtime: mtime, _ctime: mtime, _atime: mtime, _fileMode: 0755}
+	dir.On("IsSymlink").Return(false)
+
+	path := filepath.Join(t.TempDir(), CSVReportName)
+	report, err := newCSVReport(path, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, report.Write("/mnt/a/b.txt", file))
+	assert.NoError(t, report.Write("/mnt/a", dir))
+	assert.NoError(t, report.Close())
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, csvHeader, records[0])
+	assert.Equal(t, []string{
+		"/mnt/a/b.txt", "file", ".txt",
+		"1536", "1.50 KiB",
+		"1709296200", "2024-03-01T12:30:00Z",
+		"1709296200", "2024-03-01T12:30:00Z",
+		"1709296200", "2024-03-01T12:30:00Z",
+		"-rw-r--r--",
+	}, records[1])
+	assert.Equal(t, "dir", records[2][1])
+	assert.Equal(t, "", records[2][2])
+}
+
+// TestSummaryCSV 测试按分隔符写入统计汇总
+func TestSummaryCSV(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	summary := &JobSummary{
+		JobID:     "Job_share_scan",
+		Path:      "/mnt/share",
+		StartTime: start,
+		EndTime:   start.Add(90 * time.Second),
+		FileTypes: 3,
+		Stats:     StatsSnapshot{FileCount: 12, DirCount: 2, TotalRegularFile: 10, TotalSymlink: 2, TotalSize: 2048},
+	}
+	path := filepath.Join(t.TempDir(), SummaryCSVName)
+	assert.NoError(t, writeSummaryCSV(path, ';', summary))
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	reader := csv.NewReader(f)
+	reader.Comma = ';'
+	records, err := reader.ReadAll()
+	assert.NoError(t, err)
+	values := make(map[string]string)
+	for _, record := range records[1:] {
+		values[record[0]] = record[1]
+	}
+	assert.Equal(t, []string{"metric", "value"}, records[0])
+	assert.Equal(t, "succeeded", values["status"])
+	assert.Equal(t, "90", values["duration_seconds"])
+	assert.Equal(t, "12", values["files"])
+	assert.Equal(t, "2", values["dirs"])
+	assert.Equal(t, "2048", values["total_size"])
+	assert.Equal(t, "2.00 KiB", values["total_size_human"])
+	assert.Equal(t, "3", values["file_types"])
+}
+
+// TestExtensionsCSV 测试写入扩展名分布及平均大小
+func TestExtensionsCSV(t *testing.T) {
+	summary := &JobSummary{Extensions: []ExtensionUsage{
+		{Ext: ".pdf", Files: 2, Bytes: 3072},
+		{Ext: "", Files: 1, Bytes: 10},
+		{Ext: "*", Files: 3, Bytes: 30},
+	}}
+	path := filepath.Join(t.TempDir(), ExtensionsCSVName)
+	assert.NoError(t, writeExtensionsCSV(path, 0, summary))
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"ext", "files", "bytes", "bytes_human", "avg_bytes"},
+		{".pdf", "2", "3072", "3.00 KiB", "1536"},
+		{"", "1", "10", "10 B", "10"},
+		{"*", "3", "30", "30 B", "10"},
+	}, records)
+}
+
+// TestParseCSVDelimiter 测试CSV分隔符的解析
+func TestParseCSVDelimiter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  rune
+		err   bool
+	}{
+		{value: "", want: ','},
+		{value: ";", want: ';'},
+		{value: "tab", want: '\t'},
+		{value: "|", want: '|'},
+		{value: "::", err: true},
+		{value: `"`, err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCSVDelimiter(tt.value)
+		if tt.err {
+			assert.Error(t, err, tt.value)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got, tt.value)
+	}
+}
@@ -0,0 +1,50 @@
+package scan
+
+import (
+	"terrasync/db"
+)
+
+// DBChoice is the database type selected for a job and the number of entries
+// it was selected by
+type DBChoice struct {
+	Type      string
+	Estimated int64  // 预计条目数，0表示无法估计
+	Source    string // 估计的来源：previous job或estimate
+	Oversized bool   // 预计条目数超过阈值，但使用SQLite
+}
+
+// SelectDB resolves the database type of a job. An explicit type is kept, auto
+// keeps the type of the job database of a previous run (the tables of an
+// incremental scan can't move), otherwise it picks SQLite up to threshold
+// entries and the first configured server or KV backend beyond. The entries
+// are estimated from the previous run of the job when estimated is 0. Oversized
+// tells the caller to warn that SQLite is used for a scan above the threshold.
+func SelectDB(dbType, jobDir string, estimated, threshold int64) DBChoice {
+	choice := DBChoice{Type: dbType, Estimated: estimated, Source: "estimate"}
+	// 没有摘要的任务按首次扫描处理
+	previous, _ := LoadJobSummary(jobDir)
+	if estimated <= 0 && previous != nil {
+		choice.Estimated = previous.Stats.FileCount + previous.Stats.DirCount
+		choice.Source = "previous job"
+	}
+	large := threshold > 0 && choice.Estimated > threshold
+
+	if dbType == db.TypeAuto {
+		switch {
+		case previous != nil && previous.DbType != "" && previous.DbType != db.TypeAuto:
+			choice.Type = previous.DbType
+		case large:
+			choice.Type = "sqlite"
+			for _, t := range db.Types() {
+				if t != "sqlite" && db.Available(t) {
+					choice.Type = t
+					break
+				}
+			}
+		default:
+			choice.Type = "sqlite"
+		}
+	}
+	choice.Oversized = large && choice.Type == "sqlite"
+	return choice
+}
@@ -0,0 +1,51 @@
+package scan
+
+import (
+	"testing"
+
+	"terrasync/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSelectDB 测试按预计条目数及上次运行选择任务数据库
+func TestSelectDB(t *testing.T) {
+	previousJob := t.TempDir()
+	assert.NoError(t, SaveJobSummary(previousJob, JobSummary{
+		JobID:  "Job_big_scan",
+		DbType: "sqlite",
+		Stats:  StatsSnapshot{FileCount: 900, DirCount: 100},
+	}))
+
+	tests := []struct {
+		name      string
+		dbType    string
+		jobDir    string
+		estimated int64
+		want      DBChoice
+	}{
+		{"指定类型不变", "sqlite", t.TempDir(), 10, DBChoice{Type: "sqlite", Estimated: 10, Source: "estimate"}},
+		{"自动选择小规模扫描", db.TypeAuto, t.TempDir(), 10, DBChoice{Type: "sqlite", Estimated: 10, Source: "estimate"}},
+		{"无法估计", db.TypeAuto, t.TempDir(), 0, DBChoice{Type: "sqlite", Source: "estimate"}},
+		{"超过阈值只有SQLite", db.TypeAuto, t.TempDir(), 5000, DBChoice{Type: "sqlite", Estimated: 5000, Source: "estimate", Oversized: true}},
+		{"按上次运行估计", db.TypeAuto, previousJob, 0, DBChoice{Type: "sqlite", Estimated: 1000, Source: "previous job"}},
+		{"指定的预计条目数优先", "sqlite", previousJob, 2000, DBChoice{Type: "sqlite", Estimated: 2000, Source: "estimate", Oversized: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SelectDB(tt.dbType, tt.jobDir, tt.estimated, 1000))
+		})
+	}
+
+	// 配置了服务器时大规模扫描使用服务器
+	db.SetPostgresDSN("postgres://localhost/terrasync")
+	defer db.SetPostgresDSN("")
+	assert.Equal(t, DBChoice{Type: "postgres", Estimated: 5000, Source: "estimate"}, SelectDB(db.TypeAuto, t.TempDir(), 5000, 1000))
+	assert.Equal(t, DBChoice{Type: "sqlite", Estimated: 10, Source: "estimate"}, SelectDB(db.TypeAuto, t.TempDir(), 10, 1000))
+
+	// database.dsn的协议决定可用的服务器类型
+	db.SetMySQLDSN("mysql://localhost/terrasync")
+	defer db.SetMySQLDSN("")
+	db.SetPostgresDSN("mysql://localhost/terrasync")
+	assert.Equal(t, DBChoice{Type: "mysql", Estimated: 5000, Source: "estimate"}, SelectDB(db.TypeAuto, t.TempDir(), 5000, 1000))
+}
@@ -0,0 +1,91 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// dirTask is a directory waiting to be listed
+type dirTask struct {
+	path  string
+	mtime time.Time // 父目录列举到的mtime，根目录为零值
+	depth int       // 相对根目录的深度，根目录为1
+}
+
+// dirQueue is the unbounded queue of the directories waiting to be listed, so
+// that a worker never blocks handing over a subdirectory however wide the tree.
+// Workers take the most recently found directory first: the traversal goes
+// depth first and the queue holds about depth x fan-out directories.
+type dirQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	dirs    []dirTask
+	pending int // 已入队且尚未列举完成的目录数
+}
+
+func newDirQueue() *dirQueue {
+	q := &dirQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push adds a directory to list
+func (q *dirQueue) push(d dirTask) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dirs = append(q.dirs, d)
+	q.pending++
+	q.cond.Signal()
+}
+
+// pop waits for a directory to list, false once every directory pushed has
+// been listed or ctx is done
+func (q *dirQueue) pop(ctx context.Context) (dirTask, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.dirs) == 0 && q.pending > 0 && ctx.Err() == nil {
+		q.cond.Wait()
+	}
+	if len(q.dirs) == 0 || ctx.Err() != nil {
+		return dirTask{}, false
+	}
+	d := q.dirs[len(q.dirs)-1]
+	q.dirs = q.dirs[:len(q.dirs)-1]
+	return d, true
+}
+
+// done marks a directory returned by pop as listed, its subdirectories must be
+// pushed before
+func (q *dirQueue) done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending--
+	if q.pending == 0 {
+		q.cond.Broadcast()
+	}
+}
+
+// wake wakes up the workers waiting in pop, used when the context is done
+func (q *dirQueue) wake() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// ListError reports the directories and entries a traversal could not list,
+// the traversal itself went on and returned everything else
+type ListError struct {
+	Failed int64 // 失败的目录及条目数
+	Path   string
+	Err    error // 第一个失败的错误
+}
+
+func (e *ListError) Error() string {
+	return fmt.Sprintf("failed to list %d directories or entries, first %s: %v", e.Failed, e.Path, e.Err)
+}
+
+func (e *ListError) Unwrap() error {
+	return e.Err
+}
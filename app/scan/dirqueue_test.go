@@ -0,0 +1,69 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestListingWideAndDeep 测试子目录数远超worker数及目录很深时遍历完整结束
+func TestListingWideAndDeep(t *testing.T) {
+	mem, err := object.CreateStorage("mem://listing-wide-deep")
+	assert.NoError(t, err)
+	for i := 0; i < 3000; i++ {
+		assert.NoError(t, mem.Put(fmt.Sprintf("/wide/d%d/f.txt", i), strings.NewReader("data")))
+	}
+	deep := "/deep"
+	for i := 0; i < 200; i++ {
+		deep += fmt.Sprintf("/l%d", i)
+	}
+	assert.NoError(t, mem.Put(deep+"/f.txt", strings.NewReader("data")))
+
+	entries, errc := Listing(context.Background(), mem, ListOptions{Concurrency: 2})
+	var files, dirs int
+	for fi := range entries {
+		if fi.IsDir() {
+			dirs++
+		} else {
+			files++
+		}
+	}
+	assert.NoError(t, <-errc)
+	assert.Equal(t, 3001, files)
+	assert.Equal(t, 1+3000+1+200, dirs)
+}
+
+// TestListingErrors 测试列举失败的目录被跳过并在结束时报告，取消时报告取消
+func TestListingErrors(t *testing.T) {
+	mem, err := object.CreateStorage("mem://listing-errors")
+	assert.NoError(t, err)
+	for _, key := range []string{"/ok/a.txt", "/denied/b.txt"} {
+		assert.NoError(t, mem.Put(key, strings.NewReader("data")))
+	}
+
+	entries, errc := Listing(context.Background(), &failListStorage{Storage: mem, dir: "/denied"}, ListOptions{Concurrency: 2})
+	var keys []string
+	for fi := range entries {
+		keys = append(keys, fi.Key())
+	}
+	assert.Contains(t, keys, "/ok/a.txt")
+	assert.NotContains(t, keys, "/denied/b.txt")
+	err = <-errc
+	var listErr *ListError
+	assert.True(t, errors.As(err, &listErr))
+	assert.Equal(t, int64(1), listErr.Failed)
+	assert.Equal(t, "/denied", listErr.Path)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	entries, errc = Listing(ctx, mem, ListOptions{Concurrency: 2})
+	for range entries {
+	}
+	assert.ErrorIs(t, <-errc, context.Canceled)
+}
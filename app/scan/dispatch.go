@@ -0,0 +1,463 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"terrasync/db"
+	"terrasync/log"
+	"terrasync/object"
+	"time"
+)
+
+// dbFlushInterval bounds how long scanned entries wait in a partial batch before
+// they are saved, so slow listings still show progress in the job database
+const dbFlushInterval = 5 * time.Second
+
+// deliveryCheckpointInterval is how often the high-water marks of the sinks are
+// saved, the entries delivered since the last checkpoint are sent again on resume
+const deliveryCheckpointInterval = time.Second
+
+// Sink receives the scanned entries in batches, e.g. the job database or Kafka
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, batch []object.FileInfo) error
+}
+
+// OverflowStrategy decides what happens to an entry when the queue of a sink is full
+type OverflowStrategy string
+
+const (
+	// OverflowBlock waits for room in the queue, slowing down the scan
+	OverflowBlock OverflowStrategy = "block"
+	// OverflowDrop drops the entry and counts it
+	OverflowDrop OverflowStrategy = "drop"
+	// OverflowSpool appends the entry to a spool file, written to the sink after the scan
+	OverflowSpool OverflowStrategy = "spool"
+)
+
+// ParseOverflowStrategy parses an overflow strategy, empty means block
+func ParseOverflowStrategy(strategy string) (OverflowStrategy, error) {
+	switch s := OverflowStrategy(strings.ToLower(strategy)); s {
+	case "":
+		return OverflowBlock, nil
+	case OverflowBlock, OverflowDrop, OverflowSpool:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unsupported overflow strategy %q, expect block, drop or spool", strategy)
+	}
+}
+
+// SinkConfig configures the queue and flush policy of a sink
+type SinkConfig struct {
+	Sink       Sink
+	QueueLen   int              // 队列长度
+	Workers    int              // 并发写入的worker数，<=0为1
+	MaxBatch   int              // 批次达到该条目数时写入，<=0为1
+	MaxLatency time.Duration    // 批次中最早的条目等待超过该时间时写入，0表示只按大小写入
+	Overflow   OverflowStrategy // 队列满时的处理: block, drop 或 spool
+	SpoolPath  string           // spool文件路径，Overflow为spool时必须设置
+	Delivered  int64            // 之前的运行已送达的高水位，序号不超过它的条目被跳过
+}
+
+// SinkStats is the outcome of a sink
+type SinkStats struct {
+	Name          string
+	Written       int64 // 成功写入的条目数
+	Batches       int64
+	FailedBatches int64
+	FailedEntries int64
+	Dropped       int64
+	Spooled       int64
+	Skipped       int64 // 之前的运行已送达而跳过的条目数
+	Delivered     int64 // 送达高水位，序号不超过它的条目都已送达或丢弃
+	Err           error // 第一个写入错误
+}
+
+func (s SinkStats) String() string {
+	desc := fmt.Sprintf("%s: %d entries in %d batches", s.Name, s.Written, s.Batches)
+	if s.FailedBatches > 0 {
+		desc += fmt.Sprintf(", %d batches (%d entries) failed", s.FailedBatches, s.FailedEntries)
+	}
+	if s.Dropped > 0 {
+		desc += fmt.Sprintf(", %d dropped", s.Dropped)
+	}
+	if s.Spooled > 0 {
+		desc += fmt.Sprintf(", %d spooled", s.Spooled)
+	}
+	if s.Skipped > 0 {
+		desc += fmt.Sprintf(", %d already delivered", s.Skipped)
+	}
+	return desc
+}
+
+// Dispatcher fans the scanned entries out to sinks. Every sink has its own
+// bounded queue, coalesces entries into batches flushed by size or latency, and
+// handles a full queue by blocking, dropping or spooling, so a slow sink only
+// slows the scan when it must not lose entries.
+//
+// Entries are numbered in the order they are dispatched. Every sink tracks its
+// high-water mark, the sequence number up to which all entries were delivered
+// (or dropped by the overflow strategy), which TrackDelivery saves in the job
+// database. Dispatching the same entries in the same order again, as a resumed
+// two-phase scan does from its listing snapshot, skips the entries below the
+// mark of each sink, so sinks get every entry at least once, duplicated at most
+// for the entries delivered since the last checkpoint.
+type Dispatcher struct {
+	ctx    context.Context
+	queues []*sinkQueue
+	seq    int64
+
+	store DeliveryStore
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// DeliveryStore saves the high-water marks of the sinks, such as the job database
+type DeliveryStore interface {
+	SaveDelivery(ctx context.Context, sink string, seq int64) error
+}
+
+// queuedEntry is an entry with its sequence number
+type queuedEntry struct {
+	seq      int64
+	fileInfo object.FileInfo
+}
+
+// spoolRecord is an entry in the spool file
+type spoolRecord struct {
+	Seq   int64           `json:"seq"`
+	Entry db.FileInfoData `json:"entry"`
+}
+
+// sinkQueue is the queue and workers of one sink
+type sinkQueue struct {
+	config SinkConfig
+	ch     chan queuedEntry
+	wg     sync.WaitGroup
+
+	written, batches, failedBatches, failedEntries, dropped, spooled, skipped atomic.Int64
+
+	mu    sync.Mutex
+	err   error
+	spool *os.File
+	enc   *json.Encoder
+
+	// 送达高水位，done是高水位之后已送达的序号，failedAt是第一个写入失败的序号
+	delivered int64
+	done      map[int64]struct{}
+	failedAt  int64
+}
+
+// NewDispatcher starts the workers of the sinks, Close must be called to flush them
+func NewDispatcher(ctx context.Context, configs ...SinkConfig) (*Dispatcher, error) {
+	d := &Dispatcher{ctx: ctx}
+	for _, config := range configs {
+		if config.Overflow == "" {
+			config.Overflow = OverflowBlock
+		}
+		if config.Overflow == OverflowSpool && config.SpoolPath == "" {
+			return nil, fmt.Errorf("sink %s spools without a spool path", config.Sink.Name())
+		}
+		config.Workers = max(config.Workers, 1)
+		config.MaxBatch = max(config.MaxBatch, 1)
+		q := &sinkQueue{
+			config:    config,
+			ch:        make(chan queuedEntry, config.QueueLen),
+			delivered: config.Delivered,
+			done:      make(map[int64]struct{}),
+		}
+		for i := 0; i < config.Workers; i++ {
+			q.wg.Add(1)
+			go q.run(ctx)
+		}
+		d.queues = append(d.queues, q)
+	}
+	return d, nil
+}
+
+// TrackDelivery saves the high-water marks of the sinks in store every interval
+// and on Close. It must be called before the first Dispatch, the marks saved
+// right away record that the entries can be dispatched again.
+func (d *Dispatcher) TrackDelivery(store DeliveryStore, interval time.Duration) error {
+	d.store = store
+	if err := d.checkpoint(); err != nil {
+		return err
+	}
+	d.stop = make(chan struct{})
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+				if err := d.checkpoint(); err != nil {
+					log.Warnf("%v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// checkpoint saves the high-water marks of the sinks
+func (d *Dispatcher) checkpoint() error {
+	for _, q := range d.queues {
+		if err := d.store.SaveDelivery(d.ctx, q.config.Sink.Name(), q.highWaterMark()); err != nil {
+			return fmt.Errorf("failed to save delivery checkpoint: %w", err)
+		}
+	}
+	return nil
+}
+
+// Dispatch queues an entry for every sink that didn't get it in a previous run
+func (d *Dispatcher) Dispatch(fileInfo object.FileInfo) {
+	d.seq++
+	for _, q := range d.queues {
+		q.put(queuedEntry{seq: d.seq, fileInfo: fileInfo})
+	}
+}
+
+// Close flushes the queued entries, writes the spooled entries and returns the
+// outcome of every sink in the order they were configured
+func (d *Dispatcher) Close() []SinkStats {
+	for _, q := range d.queues {
+		close(q.ch)
+	}
+	results := make([]SinkStats, 0, len(d.queues))
+	for _, q := range d.queues {
+		q.wg.Wait()
+		q.replaySpool(d.ctx)
+		results = append(results, q.stats())
+	}
+	if d.store != nil {
+		close(d.stop)
+		d.wg.Wait()
+		if err := d.checkpoint(); err != nil {
+			log.Errorf("%v", err)
+		}
+	}
+	return results
+}
+
+func (q *sinkQueue) put(entry queuedEntry) {
+	if entry.seq <= q.config.Delivered {
+		q.skipped.Add(1)
+		return
+	}
+	if q.config.Overflow == OverflowBlock {
+		q.ch <- entry
+		return
+	}
+	select {
+	case q.ch <- entry:
+		return
+	default:
+	}
+	if q.config.Overflow == OverflowSpool && q.writeSpool(entry) == nil {
+		q.spooled.Add(1)
+		return
+	}
+	// 丢弃的条目不会再送达，高水位越过它们
+	q.dropped.Add(1)
+	q.markDelivered([]queuedEntry{entry})
+}
+
+// markDelivered advances the high-water mark over the delivered entries. Once a
+// write failed the mark stops before the failed entry, so a resumed scan sends
+// it again, and later entries are no longer tracked.
+func (q *sinkQueue) markDelivered(entries []queuedEntry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, entry := range entries {
+		if q.failedAt == 0 || entry.seq < q.failedAt {
+			q.done[entry.seq] = struct{}{}
+		}
+	}
+	for {
+		if _, ok := q.done[q.delivered+1]; !ok {
+			return
+		}
+		delete(q.done, q.delivered+1)
+		q.delivered++
+	}
+}
+
+// markFailed stops the high-water mark before the first failed entry
+func (q *sinkQueue) markFailed(entries []queuedEntry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, entry := range entries {
+		if q.failedAt == 0 || entry.seq < q.failedAt {
+			q.failedAt = entry.seq
+		}
+	}
+	for seq := range q.done {
+		if seq > q.failedAt {
+			delete(q.done, seq)
+		}
+	}
+}
+
+func (q *sinkQueue) highWaterMark() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.delivered
+}
+
+// run coalesces queued entries into batches, flushed when MaxBatch entries are
+// collected or the oldest entry waited MaxLatency
+func (q *sinkQueue) run(ctx context.Context) {
+	defer q.wg.Done()
+	batch := make([]queuedEntry, 0, q.config.MaxBatch)
+	var timer *time.Timer
+	var deadline <-chan time.Time
+	flush := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, deadline = nil, nil
+		}
+		if len(batch) == 0 {
+			return
+		}
+		q.write(ctx, batch)
+		batch = make([]queuedEntry, 0, q.config.MaxBatch)
+	}
+	for {
+		select {
+		case entry, ok := <-q.ch:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, entry)
+			if len(batch) >= q.config.MaxBatch {
+				flush()
+			} else if len(batch) == 1 && q.config.MaxLatency > 0 {
+				timer = time.NewTimer(q.config.MaxLatency)
+				deadline = timer.C
+			}
+		case <-deadline:
+			timer, deadline = nil, nil
+			flush()
+		}
+	}
+}
+
+// write writes a batch to the sink, failures are counted and logged
+func (q *sinkQueue) write(ctx context.Context, batch []queuedEntry) {
+	start := time.Now()
+	fileInfos := make([]object.FileInfo, 0, len(batch))
+	for _, entry := range batch {
+		fileInfos = append(fileInfos, entry.fileInfo)
+	}
+	if err := q.config.Sink.Write(ctx, fileInfos); err != nil {
+		log.Errorf("Failed to write batch of %d entries to %s: %v", len(batch), q.config.Sink.Name(), err)
+		q.failedBatches.Add(1)
+		q.failedEntries.Add(int64(len(batch)))
+		q.markFailed(batch)
+		q.mu.Lock()
+		if q.err == nil {
+			q.err = err
+		}
+		q.mu.Unlock()
+		return
+	}
+	q.markDelivered(batch)
+	log.Debugf("Wrote batch of %d entries to %s in %v", len(batch), q.config.Sink.Name(), time.Since(start))
+	q.batches.Add(1)
+	q.written.Add(int64(len(batch)))
+}
+
+// writeSpool appends an entry to the spool file
+func (q *sinkQueue) writeSpool(entry queuedEntry) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.spool == nil {
+		f, err := os.OpenFile(q.config.SpoolPath, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0600)
+		if err != nil {
+			log.Errorf("Failed to create spool file of %s: %v", q.config.Sink.Name(), err)
+			return err
+		}
+		q.spool, q.enc = f, json.NewEncoder(f)
+	}
+	if err := q.enc.Encode(spoolRecord{Seq: entry.seq, Entry: db.ProcessFileInfo(entry.fileInfo)}); err != nil {
+		log.Errorf("Failed to spool entry of %s: %v", q.config.Sink.Name(), err)
+		return err
+	}
+	return nil
+}
+
+// replaySpool writes the spooled entries to the sink in batches and removes the spool file
+func (q *sinkQueue) replaySpool(ctx context.Context) {
+	if q.spool == nil {
+		return
+	}
+	defer os.Remove(q.config.SpoolPath)
+	defer q.spool.Close()
+	if _, err := q.spool.Seek(0, 0); err != nil {
+		log.Errorf("Failed to read spool file of %s: %v", q.config.Sink.Name(), err)
+		return
+	}
+	log.Infof("Writing %d spooled entries to %s", q.spooled.Load(), q.config.Sink.Name())
+	batch := make([]queuedEntry, 0, q.config.MaxBatch)
+	scanner := bufio.NewScanner(q.spool)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record spoolRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.Errorf("Invalid spooled entry of %s: %v", q.config.Sink.Name(), err)
+			continue
+		}
+		batch = append(batch, queuedEntry{seq: record.Seq, fileInfo: &snapshotEntry{data: record.Entry}})
+		if len(batch) >= q.config.MaxBatch {
+			q.write(ctx, batch)
+			batch = make([]queuedEntry, 0, q.config.MaxBatch)
+		}
+	}
+	if len(batch) > 0 {
+		q.write(ctx, batch)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Errorf("Failed to read spool file of %s: %v", q.config.Sink.Name(), err)
+	}
+}
+
+func (q *sinkQueue) stats() SinkStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return SinkStats{
+		Name:          q.config.Sink.Name(),
+		Written:       q.written.Load(),
+		Batches:       q.batches.Load(),
+		FailedBatches: q.failedBatches.Load(),
+		FailedEntries: q.failedEntries.Load(),
+		Dropped:       q.dropped.Load(),
+		Spooled:       q.spooled.Load(),
+		Skipped:       q.skipped.Load(),
+		Delivered:     q.delivered,
+		Err:           q.err,
+	}
+}
+
+// dbSink saves entries in the file_entries table of the job database
+type dbSink struct {
+	db *db.DB
+}
+
+func (s *dbSink) Name() string {
+	return "database"
+}
+
+func (s *dbSink) Write(ctx context.Context, batch []object.FileInfo) error {
+	return (*s.db).SaveEntries(ctx, batch, "")
+}
@@ -0,0 +1,227 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordSink 记录写入的批次，release非nil时通知writing后阻塞直到release关闭
+type recordSink struct {
+	mu      sync.Mutex
+	batches [][]string
+	writing chan struct{}
+	release chan struct{}
+	err     error
+}
+
+func (s *recordSink) Name() string {
+	return "record"
+}
+
+func (s *recordSink) Write(ctx context.Context, batch []object.FileInfo) error {
+	if s.release != nil {
+		select {
+		case s.writing <- struct{}{}:
+		default:
+		}
+		<-s.release
+	}
+	if s.err != nil {
+		return s.err
+	}
+	keys := make([]string, 0, len(batch))
+	for _, fileInfo := range batch {
+		keys = append(keys, fileInfo.Key())
+	}
+	s.mu.Lock()
+	s.batches = append(s.batches, keys)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *recordSink) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for _, batch := range s.batches {
+		keys = append(keys, batch...)
+	}
+	return keys
+}
+
+func dispatchEntries(t *testing.T, n int) []object.FileInfo {
+	mem, err := object.CreateStorage("mem://dispatch-test")
+	assert.NoError(t, err)
+	entries := make([]object.FileInfo, 0, n)
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("/f%d.txt", i)
+		assert.NoError(t, mem.Put(key, strings.NewReader("data")))
+		fileInfo, err := mem.Head(key)
+		assert.NoError(t, err)
+		entries = append(entries, fileInfo)
+	}
+	return entries
+}
+
+// TestDispatcherBatches 测试按批次大小及等待时间写入
+func TestDispatcherBatches(t *testing.T) {
+	entries := dispatchEntries(t, 5)
+
+	// 按大小写入，剩余条目在Close时写入
+	sink := &recordSink{}
+	dispatcher, err := NewDispatcher(context.Background(), SinkConfig{Sink: sink, QueueLen: 10, MaxBatch: 2})
+	assert.NoError(t, err)
+	for _, fileInfo := range entries {
+		dispatcher.Dispatch(fileInfo)
+	}
+	stats := dispatcher.Close()
+	assert.Equal(t, [][]string{{"/f0.txt", "/f1.txt"}, {"/f2.txt", "/f3.txt"}, {"/f4.txt"}}, sink.batches)
+	assert.Equal(t, SinkStats{Name: "record", Written: 5, Batches: 3, Delivered: 5}, stats[0])
+
+	// 未满的批次等待MaxLatency后写入
+	sink = &recordSink{}
+	dispatcher, err = NewDispatcher(context.Background(), SinkConfig{Sink: sink, QueueLen: 10, MaxBatch: 100, MaxLatency: 20 * time.Millisecond})
+	assert.NoError(t, err)
+	dispatcher.Dispatch(entries[0])
+	assert.Eventually(t, func() bool { return len(sink.keys()) == 1 }, time.Second, 5*time.Millisecond)
+	dispatcher.Close()
+}
+
+// TestDispatcherOverflow 测试队列满时丢弃或写入spool文件
+func TestDispatcherOverflow(t *testing.T) {
+	entries := dispatchEntries(t, 5)
+	spoolPath := filepath.Join(t.TempDir(), "record.spool")
+	tests := []struct {
+		name     string
+		overflow OverflowStrategy
+		dropped  int64
+		spooled  int64
+		written  int64
+	}{
+		{"丢弃", OverflowDrop, 3, 0, 2},
+		{"写入spool文件", OverflowSpool, 0, 3, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 第一个条目阻塞在写入，第二个条目占满队列，其余条目溢出
+			sink := &recordSink{writing: make(chan struct{}, 1), release: make(chan struct{})}
+			dispatcher, err := NewDispatcher(context.Background(), SinkConfig{
+				Sink: sink, QueueLen: 1, Overflow: tt.overflow, SpoolPath: spoolPath,
+			})
+			assert.NoError(t, err)
+			dispatcher.Dispatch(entries[0])
+			<-sink.writing
+			for _, fileInfo := range entries[1:] {
+				dispatcher.Dispatch(fileInfo)
+			}
+			close(sink.release)
+			stats := dispatcher.Close()
+
+			assert.Equal(t, tt.dropped, stats[0].Dropped)
+			assert.Equal(t, tt.spooled, stats[0].Spooled)
+			assert.Equal(t, tt.written, stats[0].Written)
+			assert.Len(t, sink.keys(), int(tt.written))
+			_, err = os.Stat(spoolPath)
+			assert.True(t, os.IsNotExist(err), "spool file is removed")
+		})
+	}
+
+	_, err := NewDispatcher(context.Background(), SinkConfig{Sink: &recordSink{}, Overflow: OverflowSpool})
+	assert.Error(t, err)
+}
+
+// TestDispatcherFailure 测试写入失败的批次及第一个错误
+func TestDispatcherFailure(t *testing.T) {
+	entries := dispatchEntries(t, 3)
+	sink := &recordSink{err: errors.New("disk full")}
+	dispatcher, err := NewDispatcher(context.Background(), SinkConfig{Sink: sink, QueueLen: 10, MaxBatch: 2})
+	assert.NoError(t, err)
+	for _, fileInfo := range entries {
+		dispatcher.Dispatch(fileInfo)
+	}
+	stats := dispatcher.Close()
+	assert.Equal(t, int64(2), stats[0].FailedBatches)
+	assert.Equal(t, int64(3), stats[0].FailedEntries)
+	assert.EqualError(t, stats[0].Err, "disk full")
+	assert.Equal(t, "record: 0 entries in 0 batches, 2 batches (3 entries) failed", stats[0].String())
+}
+
+// deliveryStore 记录各sink保存的送达高水位
+type deliveryStore struct {
+	mu    sync.Mutex
+	marks map[string]int64
+}
+
+func (s *deliveryStore) SaveDelivery(ctx context.Context, sink string, seq int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marks[sink] = seq
+	return nil
+}
+
+// failKeySink 写入包含指定条目的批次时失败
+type failKeySink struct {
+	recordSink
+	key string
+}
+
+func (s *failKeySink) Write(ctx context.Context, batch []object.FileInfo) error {
+	for _, fileInfo := range batch {
+		if fileInfo.Key() == s.key {
+			return errors.New("broker unavailable")
+		}
+	}
+	return s.recordSink.Write(ctx, batch)
+}
+
+// TestDispatcherDelivery 测试送达高水位的记录及继续时跳过已送达的条目
+func TestDispatcherDelivery(t *testing.T) {
+	entries := dispatchEntries(t, 5)
+	tests := []struct {
+		name      string
+		sink      Sink
+		delivered int64 // 之前的运行已送达
+		keys      []string
+		mark      int64
+	}{
+		{"全部送达", &recordSink{}, 0, []string{"/f0.txt", "/f1.txt", "/f2.txt", "/f3.txt", "/f4.txt"}, 5},
+		{"跳过已送达的条目", &recordSink{}, 3, []string{"/f3.txt", "/f4.txt"}, 5},
+		{"高水位停在失败的条目之前", &failKeySink{key: "/f2.txt"}, 0, []string{"/f0.txt", "/f1.txt", "/f3.txt", "/f4.txt"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &deliveryStore{marks: map[string]int64{}}
+			dispatcher, err := NewDispatcher(context.Background(), SinkConfig{Sink: tt.sink, QueueLen: 10, Delivered: tt.delivered})
+			assert.NoError(t, err)
+			assert.NoError(t, dispatcher.TrackDelivery(store, time.Hour))
+			assert.Equal(t, tt.delivered, store.marks["record"])
+			for _, fileInfo := range entries {
+				dispatcher.Dispatch(fileInfo)
+			}
+			stats := dispatcher.Close()
+
+			var keys []string
+			switch sink := tt.sink.(type) {
+			case *recordSink:
+				keys = sink.keys()
+			case *failKeySink:
+				keys = sink.keys()
+			}
+			assert.Equal(t, tt.keys, keys)
+			assert.Equal(t, tt.delivered, stats[0].Skipped)
+			assert.Equal(t, tt.mark, stats[0].Delivered)
+			assert.Equal(t, tt.mark, store.marks["record"])
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package scan
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"terrasync/db"
+	"terrasync/i18n"
+)
+
+// DuplicateDirOptions selects the identical subtrees worth reporting
+type DuplicateDirOptions struct {
+	MinBytes int64 // 子树的最小字节数，更小的重复目录不报告
+	MinFiles int64 // 子树的最少文件数，<=0按1计算，空目录不报告
+	Top      int   // 按可回收空间保留的组数，0保留全部
+}
+
+// DuplicateGroup is a set of directories with identical subtrees
+type DuplicateGroup struct {
+	Dirs   []string
+	Files  int64 // 每个目录子树中的文件数
+	Bytes  int64 // 每个目录子树的字节数
+	Wasted int64 // 只保留一份时可回收的字节数
+}
+
+// DuplicateDirs are the identical subtrees found in a scan job
+type DuplicateDirs struct {
+	JobID    string
+	Path     string
+	Compared int64 // 比较的目录数
+	Groups   []DuplicateGroup
+	Found    int   // 截取前的组数
+	Wasted   int64 // 所有组可回收的字节数
+}
+
+// dirDigest accumulates the order independent hash of the children of a
+// directory: the lanes of the SHA-256 of every child are summed up, so the
+// entries can be added in any order without holding their names
+type dirDigest struct {
+	sum   [4]uint64
+	files int64
+	bytes int64
+	hash  [32]byte // 所有子目录加入后计算
+}
+
+func (d *dirDigest) add(kind, name string, value []byte) {
+	h := sha256.New()
+	h.Write([]byte(kind))
+	h.Write([]byte{0})
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write(value)
+	sum := h.Sum(nil)
+	for i := range d.sum {
+		d.sum[i] += binary.BigEndian.Uint64(sum[i*8:])
+	}
+}
+
+func (d *dirDigest) finish() {
+	var buf [48]byte
+	for i, v := range d.sum {
+		binary.BigEndian.PutUint64(buf[i*8:], v)
+	}
+	binary.BigEndian.PutUint64(buf[32:], uint64(d.files))
+	binary.BigEndian.PutUint64(buf[40:], uint64(d.bytes))
+	d.hash = sha256.Sum256(buf[:])
+}
+
+// FindDuplicateDirs finds the directories of a scan job whose subtrees are
+// identical: the same names and sizes of the files and subdirectories at every
+// level, whatever the name of the directory itself. File contents are not
+// read. Only the topmost directories of a duplicated tree are reported, the
+// subdirectories of two identical copies are not repeated.
+func FindDuplicateDirs(ctx context.Context, jobDir string, opts DuplicateDirOptions) (*DuplicateDirs, error) {
+	summary, err := LoadJobSummary(jobDir)
+	if err != nil {
+		return nil, err
+	}
+	dbInstance, err := NewDB(summary.DbType, jobDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job database: %w", err)
+	}
+	defer (*dbInstance).Close()
+
+	dirs := make(map[string]*dirDigest)
+	digest := func(key string) *dirDigest {
+		d, ok := dirs[key]
+		if !ok {
+			d = &dirDigest{}
+			dirs[key] = d
+		}
+		return d
+	}
+	err = (*dbInstance).ListEntries(ctx, "", func(entry db.FileInfoData) error {
+		key := filepath.ToSlash(entry.Key)
+		if entry.IsDir {
+			digest(key)
+			return nil
+		}
+		d := digest(path.Dir(key))
+		d.add("f", path.Base(key), []byte(strconv.FormatInt(entry.Size, 10)))
+		d.files++
+		d.bytes += entry.Size
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job database: %w", err)
+	}
+
+	// 补齐没有保存的上级目录，再自底向上把子目录的哈希加入上级目录
+	for key := range dirs {
+		for parent := path.Dir(key); parent != key; key, parent = parent, path.Dir(parent) {
+			if _, ok := dirs[parent]; ok {
+				break
+			}
+			digest(parent)
+		}
+	}
+	keys := make([]string, 0, len(dirs))
+	for key := range dirs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if di, dj := strings.Count(keys[i], "/"), strings.Count(keys[j], "/"); di != dj {
+			return di > dj
+		}
+		return keys[i] < keys[j]
+	})
+	for _, key := range keys {
+		d := dirs[key]
+		d.finish()
+		if parent := path.Dir(key); parent != key {
+			p := dirs[parent]
+			p.add("d", path.Base(key), d.hash[:])
+			p.files += d.files
+			p.bytes += d.bytes
+		}
+	}
+
+	minFiles := max(opts.MinFiles, 1)
+	candidates := make(map[[32]byte][]string)
+	for _, key := range keys {
+		if d := dirs[key]; d.files >= minFiles && d.bytes >= opts.MinBytes && path.Dir(key) != key {
+			candidates[d.hash] = append(candidates[d.hash], key)
+		}
+	}
+
+	result := &DuplicateDirs{JobID: summary.JobID, Path: summary.Path, Compared: int64(len(dirs))}
+	for _, members := range candidates {
+		if len(members) < 2 || withinDuplicates(members, dirs, candidates) {
+			continue
+		}
+		sort.Strings(members)
+		d := dirs[members[0]]
+		group := DuplicateGroup{Files: d.files, Bytes: d.bytes, Wasted: d.bytes * int64(len(members)-1)}
+		for _, key := range members {
+			group.Dirs = append(group.Dirs, filepath.Join(summary.Path, key))
+		}
+		result.Groups = append(result.Groups, group)
+		result.Wasted += group.Wasted
+	}
+	sort.Slice(result.Groups, func(i, j int) bool {
+		if result.Groups[i].Wasted != result.Groups[j].Wasted {
+			return result.Groups[i].Wasted > result.Groups[j].Wasted
+		}
+		return result.Groups[i].Dirs[0] < result.Groups[j].Dirs[0]
+	})
+	result.Found = len(result.Groups)
+	if opts.Top > 0 && len(result.Groups) > opts.Top {
+		result.Groups = result.Groups[:opts.Top]
+	}
+	return result, nil
+}
+
+// withinDuplicates reports whether every directory of a group lies in a
+// directory that is itself duplicated, the group is then part of a larger one
+func withinDuplicates(members []string, dirs map[string]*dirDigest, candidates map[[32]byte][]string) bool {
+	for _, key := range members {
+		parent := path.Dir(key)
+		if parent == key || path.Dir(parent) == parent {
+			return false
+		}
+		if len(candidates[dirs[parent].hash]) < 2 {
+			return false
+		}
+	}
+	return true
+}
+
+// Print prints the duplicate directories to the console and the log
+func (r *DuplicateDirs) Print() {
+	fmt.Println()
+	console.Title("Duplicate Directories")
+
+	console.Field("Job ID", r.JobID)
+	console.Field("Directories", r.Compared)
+	console.Field("Duplicate groups", r.Found)
+	console.Field("Reclaimable", FormatFileSize(r.Wasted))
+
+	for i, group := range r.Groups {
+		console.Section(i18n.Sprintf("Group %d", i+1))
+		console.Printf("  %s\n", i18n.Sprintf("%d copies of %d files, %s each, %s reclaimable",
+			len(group.Dirs), group.Files, FormatFileSize(group.Bytes), FormatFileSize(group.Wasted)))
+		for _, dir := range group.Dirs {
+			console.Printf("  %s\n", dir)
+		}
+	}
+	if len(r.Groups) < r.Found {
+		console.Printf("\n  %s\n", i18n.Sprintf("%d more groups not shown", r.Found-len(r.Groups)))
+	}
+
+	console.End()
+}
+
+// WriteCSV writes one row per duplicate directory, the rows of a group share its number
+func (r *DuplicateDirs) WriteCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create duplicate directories report: %w", err)
+	}
+	w := csv.NewWriter(f)
+	_ = w.Write([]string{"group", "path", "copies", "files", "bytes", "bytes_human", "reclaimable_bytes"})
+	for i, group := range r.Groups {
+		for _, dir := range group.Dirs {
+			_ = w.Write([]string{strconv.Itoa(i + 1), dir, strconv.Itoa(len(group.Dirs)),
+				strconv.FormatInt(group.Files, 10), strconv.FormatInt(group.Bytes, 10), FormatFileSize(group.Bytes),
+				strconv.FormatInt(group.Wasted, 10)})
+		}
+	}
+	w.Flush()
+	err = w.Error()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write duplicate directories report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package scan
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFindDuplicateDirs 测试找出相同的子树，只报告最上层的重复目录
+func TestFindDuplicateDirs(t *testing.T) {
+	ctx := context.Background()
+	jobDir := t.TempDir()
+
+	storage, err := object.CreateStorage("mem://dupdirs-test")
+	assert.NoError(t, err)
+	files := map[string]int{
+		"/projA/src/a.go":             10,
+		"/projA/src/b.go":             20,
+		"/projA/doc.txt":              30,
+		"/backup/projA_copy/src/a.go": 10,
+		"/backup/projA_copy/src/b.go": 20,
+		"/backup/projA_copy/doc.txt":  30,
+		"/x/src/a.go":                 10,
+		"/x/src/b.go":                 20,
+		"/x/extra.txt":                5,
+		"/other/src/a.go":             10,
+		"/other/src/b.go":             21,
+	}
+	var entries []object.FileInfo
+	for key, size := range files {
+		assert.NoError(t, storage.Put(key, strings.NewReader(strings.Repeat("x", size))))
+		fi, err := storage.Head(key)
+		assert.NoError(t, err)
+		entries = append(entries, fi)
+	}
+	dbInstance, err := InitDatabase(ctx, "sqlite", jobDir)
+	assert.NoError(t, err)
+	assert.NoError(t, (*dbInstance).SaveEntries(ctx, entries, ""))
+	assert.NoError(t, (*dbInstance).Close())
+	assert.NoError(t, SaveJobSummary(jobDir, JobSummary{
+		JobID:     "Job_dupdirs_scan",
+		Path:      "/mnt",
+		DbType:    "sqlite",
+		StartTime: time.Now().UTC(),
+		EndTime:   time.Now().UTC(),
+		Stats:     NewStats().Snapshot(),
+	}))
+
+	result, err := FindDuplicateDirs(ctx, jobDir, DuplicateDirOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Found)
+	assert.Equal(t, int64(120), result.Wasted)
+	if assert.Len(t, result.Groups, 2) {
+		assert.Equal(t, DuplicateGroup{
+			Dirs:  []string{filepath.Join("/mnt", "/backup/projA_copy"), filepath.Join("/mnt", "/projA")},
+			Files: 3, Bytes: 60, Wasted: 60,
+		}, result.Groups[0])
+		// /x/src与两个副本中的src相同，但/x不是重复目录
+		assert.Equal(t, []string{filepath.Join("/mnt", "/backup/projA_copy/src"), filepath.Join("/mnt", "/projA/src"), filepath.Join("/mnt", "/x/src")}, result.Groups[1].Dirs)
+		assert.Equal(t, int64(60), result.Groups[1].Wasted)
+	}
+	result.Print()
+
+	csvPath := filepath.Join(t.TempDir(), "duplicates.csv")
+	assert.NoError(t, result.WriteCSV(csvPath))
+	f, err := os.Open(csvPath)
+	assert.NoError(t, err)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 1+2+3)
+
+	tests := []struct {
+		name   string
+		opts   DuplicateDirOptions
+		groups int
+		found  int
+	}{
+		{"最小字节数", DuplicateDirOptions{MinBytes: 50}, 1, 1},
+		{"最少文件数", DuplicateDirOptions{MinFiles: 3}, 1, 1},
+		{"只列出前几组", DuplicateDirOptions{Top: 1}, 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := FindDuplicateDirs(ctx, jobDir, tt.opts)
+			assert.NoError(t, err)
+			assert.Len(t, result.Groups, tt.groups)
+			assert.Equal(t, tt.found, result.Found)
+		})
+	}
+}
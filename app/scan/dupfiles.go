@@ -0,0 +1,300 @@
+package scan
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"terrasync/db"
+	"terrasync/i18n"
+	"terrasync/log"
+	"terrasync/object"
+	"terrasync/security"
+)
+
+// DuplicateFileOptions selects the files compared and how their contents are hashed
+type DuplicateFileOptions struct {
+	Concurrency int              // 列举及读取文件的并发数
+	Depth       int              // 最大深度，<=0表示不限制
+	Match       *ConditionFilter // 只比较匹配的文件，可为nil
+	Exclude     *ConditionFilter // 不比较匹配的文件，可为nil
+	MinBytes    int64            // 更小的文件不比较，<=0按1计算，0字节文件不报告
+	Hash        string           // 哈希算法(md5、sha1、sha256、xxhash)，为空使用xxhash
+	Sample      int64            // >0时只读取大于3倍的文件开头、中间及结尾各Sample字节
+	Top         int              // 控制台列出的组数，0列出全部
+}
+
+// DuplicateFileGroup is a set of files with the same size and digest
+type DuplicateFileGroup struct {
+	Hash   string   // 文件内容(抽样时为抽样块)的十六进制摘要
+	Size   int64    // 每个文件的字节数
+	Files  []string // 按路径排序
+	Wasted int64    // 只保留一份时可回收的字节数
+}
+
+// DuplicateFiles are the duplicate files found below a path
+type DuplicateFiles struct {
+	Path       string
+	Hash       string
+	Sample     int64
+	Files      int64 // 比较的文件数
+	Bytes      int64 // 比较的文件的字节数
+	Candidates int64 // 与其他文件大小相同而读取的文件数
+	Read       int64 // 读取的字节数
+	Errors     int64 // 读取失败的文件数
+	Groups     []DuplicateFileGroup
+	Duplicates int64 // 每组第一个之外的文件数
+	Wasted     int64 // 所有组可回收的字节数
+	Top        int
+}
+
+// sizedKey is a file to hash
+type sizedKey struct {
+	key  string
+	size int64
+}
+
+// FindDuplicateFiles lists the regular files of storage, groups them by size
+// and hashes the contents of the files sharing their size with another one,
+// reporting the sets of identical files. With opts.Sample only three blocks
+// of the larger files are read, files reported identical then only probably
+// are.
+func FindDuplicateFiles(ctx context.Context, storage object.Storage, path string, opts DuplicateFileOptions) (*DuplicateFiles, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 5
+	}
+	if opts.Hash == "" {
+		opts.Hash = "xxhash"
+	}
+	if _, err := security.NewHash(opts.Hash); err != nil {
+		return nil, err
+	}
+	minBytes := max(opts.MinBytes, 1)
+
+	result := &DuplicateFiles{Path: path, Hash: opts.Hash, Sample: opts.Sample, Top: opts.Top}
+	bySize := make(map[int64][]string)
+	listOpts := ListOptions{Concurrency: opts.Concurrency, Depth: opts.Depth, Match: opts.Match, Exclude: opts.Exclude}
+	for fileInfo := range ListAll(ctx, storage, listOpts) {
+		if !fileInfo.IsRegular() || fileInfo.Size() < minBytes {
+			continue
+		}
+		result.Files++
+		result.Bytes += fileInfo.Size()
+		bySize[fileInfo.Size()] = append(bySize[fileInfo.Size()], fileInfo.Key())
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	// 只读取与其他文件大小相同的文件
+	candidates := make(chan sizedKey)
+	go func() {
+		defer close(candidates)
+		for size, keys := range bySize {
+			if len(keys) < 2 {
+				continue
+			}
+			for _, key := range keys {
+				select {
+				case candidates <- sizedKey{key: key, size: size}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	type groupKey struct {
+		size int64
+		hash string
+	}
+	var mu sync.Mutex
+	groups := make(map[groupKey][]string)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range candidates {
+				sum, read, err := digestFile(storage, c, opts)
+				mu.Lock()
+				result.Candidates++
+				result.Read += read
+				if err != nil {
+					log.Errorf("Failed to read %s: %v", c.key, err)
+					result.Errors++
+				} else {
+					k := groupKey{size: c.size, hash: sum}
+					groups[k] = append(groups[k], c.key)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	for k, files := range groups {
+		if len(files) < 2 {
+			continue
+		}
+		sort.Strings(files)
+		group := DuplicateFileGroup{Hash: k.hash, Size: k.size, Files: files, Wasted: k.size * int64(len(files)-1)}
+		result.Groups = append(result.Groups, group)
+		result.Duplicates += int64(len(files) - 1)
+		result.Wasted += group.Wasted
+	}
+	sort.Slice(result.Groups, func(i, j int) bool {
+		if result.Groups[i].Wasted != result.Groups[j].Wasted {
+			return result.Groups[i].Wasted > result.Groups[j].Wasted
+		}
+		return result.Groups[i].Files[0] < result.Groups[j].Files[0]
+	})
+	return result, nil
+}
+
+// digestFile returns the hex digest of the contents of a file and the bytes
+// read, only the first, middle and last opts.Sample bytes of a larger file
+func digestFile(storage object.Storage, c sizedKey, opts DuplicateFileOptions) (string, int64, error) {
+	fileInfo, err := storage.Head(c.key)
+	if err != nil {
+		return "", 0, err
+	}
+	if fileInfo == nil {
+		return "", 0, fmt.Errorf("file no longer exists")
+	}
+	h, err := security.NewHash(opts.Hash)
+	if err != nil {
+		return "", 0, err
+	}
+	var read int64
+	if opts.Sample > 0 && c.size > 3*opts.Sample {
+		for _, offset := range []int64{0, (c.size - opts.Sample) / 2, c.size - opts.Sample} {
+			n, err := copyRange(h, fileInfo, offset, opts.Sample)
+			read += n
+			if err != nil {
+				return "", read, err
+			}
+		}
+	} else {
+		n, err := copyRange(h, fileInfo, 0, -1)
+		read += n
+		if err != nil {
+			return "", read, err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), read, nil
+}
+
+// copyRange adds limit bytes of a file from offset to h, the rest of the file when limit is negative
+func copyRange(h hash.Hash, fileInfo object.FileInfo, offset, limit int64) (int64, error) {
+	in, err := fileInfo.Get(offset, limit)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	buf := object.GetBuffer()
+	defer object.PutBuffer(buf)
+	return io.CopyBuffer(h, in, *buf)
+}
+
+// hashLabel describes the hashing of the report, with the sample size
+func (r *DuplicateFiles) hashLabel() string {
+	if r.Sample <= 0 {
+		return r.Hash
+	}
+	return i18n.Sprintf("%s (sampled, 3 x %s)", r.Hash, FormatFileSize(r.Sample))
+}
+
+// Print prints the counts and the largest sets of duplicate files to the console and the log
+func (r *DuplicateFiles) Print() {
+	fmt.Println()
+	console.Title("Duplicate Files")
+
+	console.Field("Path", r.Path)
+	console.Field("Hash", r.hashLabel())
+	console.Field("Files", r.Files)
+	console.Field("Same-size files", r.Candidates)
+	console.Field("Read", FormatFileSize(r.Read))
+	if r.Errors > 0 {
+		console.Field("Read errors", r.Errors)
+	}
+	console.Field("Duplicate sets", len(r.Groups))
+	console.Field("Duplicate files", r.Duplicates)
+	console.Field("Reclaimable", FormatFileSize(r.Wasted))
+
+	shown := r.Groups
+	if r.Top > 0 && len(shown) > r.Top {
+		shown = shown[:r.Top]
+	}
+	for i, group := range shown {
+		console.Section(i18n.Sprintf("Set %d", i+1))
+		console.Printf("  %s\n", i18n.Sprintf("%d copies, %s each, %s reclaimable",
+			len(group.Files), FormatFileSize(group.Size), FormatFileSize(group.Wasted)))
+		for _, file := range group.Files {
+			console.Printf("  %s\n", file)
+		}
+	}
+	if len(shown) < len(r.Groups) {
+		console.Printf("\n  %s\n", i18n.Sprintf("%d more sets not shown, see the CSV export", len(r.Groups)-len(shown)))
+	}
+
+	console.End()
+}
+
+// WriteCSV writes one row per duplicate file, the rows of a set share its number
+func (r *DuplicateFiles) WriteCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create duplicate files report: %w", err)
+	}
+	w := csv.NewWriter(f)
+	_ = w.Write([]string{"set", "hash", "path", "copies", "bytes", "bytes_human", "reclaimable_bytes"})
+	for i, group := range r.Groups {
+		for _, file := range group.Files {
+			_ = w.Write([]string{strconv.Itoa(i + 1), group.Hash, file, strconv.Itoa(len(group.Files)),
+				strconv.FormatInt(group.Size, 10), FormatFileSize(group.Size), strconv.FormatInt(group.Wasted, 10)})
+		}
+	}
+	w.Flush()
+	err = w.Error()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write duplicate files report: %w", err)
+	}
+	return nil
+}
+
+// SaveToJob replaces the duplicate_files table of a scan job database with the sets found
+func (r *DuplicateFiles) SaveToJob(ctx context.Context, jobDir string) error {
+	summary, err := LoadJobSummary(jobDir)
+	if err != nil {
+		return err
+	}
+	if summary.Path != r.Path {
+		log.Warnf("Saving the duplicate files of %s into job %s of %s", r.Path, summary.JobID, summary.Path)
+	}
+	dbInstance, err := NewDB(summary.DbType, jobDir)
+	if err != nil {
+		return fmt.Errorf("failed to open job database: %w", err)
+	}
+	defer (*dbInstance).Close()
+
+	var files []db.DuplicateFileData
+	for i, group := range r.Groups {
+		for _, file := range group.Files {
+			files = append(files, db.DuplicateFileData{Group: i + 1, Hash: group.Hash, Size: group.Size, Path: file})
+		}
+	}
+	return (*dbInstance).SaveDuplicates(ctx, files)
+}
@@ -0,0 +1,106 @@
+package scan
+
+import (
+	"context"
+	"encoding/csv"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFindDuplicateFiles 测试按大小分组后比较内容的哈希，抽样时只读取三个块
+func TestFindDuplicateFiles(t *testing.T) {
+	ctx := context.Background()
+	storage, err := object.CreateStorage("mem://dupfiles-test")
+	assert.NoError(t, err)
+
+	big := make([]byte, 100<<10)
+	rand.New(rand.NewSource(1)).Read(big)
+	bigChanged := append([]byte(nil), big...)
+	bigChanged[10<<10] ^= 1 // 不在抽样块中
+	files := map[string][]byte{
+		"/a/report.doc":      []byte(strings.Repeat("a", 100)),
+		"/b/report copy.doc": []byte(strings.Repeat("a", 100)),
+		"/c/report.doc":      []byte(strings.Repeat("a", 100)),
+		"/a/other.doc":       []byte(strings.Repeat("b", 100)), // 大小相同内容不同
+		"/a/unique.txt":      []byte("unique"),
+		"/a/empty":           nil,
+		"/b/empty":           nil,
+		"/media/video.mp4":   big,
+		"/media/edited.mp4":  bigChanged,
+	}
+	for key, data := range files {
+		assert.NoError(t, storage.Put(key, strings.NewReader(string(data))))
+	}
+
+	for _, hash := range []string{"", "md5", "sha256"} {
+		result, err := FindDuplicateFiles(ctx, storage, "/mnt", DuplicateFileOptions{Hash: hash})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), result.Files, "0字节文件不比较")
+		assert.Equal(t, int64(6), result.Candidates)
+		assert.Zero(t, result.Errors)
+		if assert.Len(t, result.Groups, 1) {
+			assert.Equal(t, []string{"/a/report.doc", "/b/report copy.doc", "/c/report.doc"}, result.Groups[0].Files)
+			assert.Equal(t, int64(200), result.Groups[0].Wasted)
+		}
+		assert.Equal(t, int64(2), result.Duplicates)
+	}
+
+	_, err = FindDuplicateFiles(ctx, storage, "/mnt", DuplicateFileOptions{Hash: "crc"})
+	assert.Error(t, err)
+
+	// 抽样时两个视频只有未读取的字节不同
+	result, err := FindDuplicateFiles(ctx, storage, "/mnt", DuplicateFileOptions{Sample: 4 << 10, MinBytes: 1 << 10, Top: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result.Files)
+	assert.Equal(t, int64(2*3*4<<10), result.Read)
+	if assert.Len(t, result.Groups, 1) {
+		assert.Equal(t, []string{"/media/edited.mp4", "/media/video.mp4"}, result.Groups[0].Files)
+	}
+	result.Print()
+
+	// 导出CSV并保存到扫描任务的数据库
+	result, err = FindDuplicateFiles(ctx, storage, "/mnt", DuplicateFileOptions{})
+	assert.NoError(t, err)
+	csvPath := filepath.Join(t.TempDir(), "duplicates.csv")
+	assert.NoError(t, result.WriteCSV(csvPath))
+	f, err := os.Open(csvPath)
+	assert.NoError(t, err)
+	rows, err := csv.NewReader(f).ReadAll()
+	f.Close()
+	assert.NoError(t, err)
+	assert.Len(t, rows, 4)
+	assert.Equal(t, []string{"1", result.Groups[0].Hash, "/a/report.doc", "3", "100", "100 B", "200"}, rows[1])
+
+	jobDir := t.TempDir()
+	dbInstance, err := InitDatabase(ctx, "sqlite", jobDir)
+	assert.NoError(t, err)
+	assert.NoError(t, (*dbInstance).Close())
+	assert.NoError(t, SaveJobSummary(jobDir, JobSummary{
+		JobID:     "Job_dupfiles_scan",
+		Path:      "/mnt",
+		DbType:    "sqlite",
+		StartTime: time.Now().UTC(),
+		EndTime:   time.Now().UTC(),
+		Stats:     NewStats().Snapshot(),
+	}))
+	assert.NoError(t, result.SaveToJob(ctx, jobDir))
+	dbInstance, err = NewDB("sqlite", jobDir)
+	assert.NoError(t, err)
+	defer (*dbInstance).Close()
+	var count int
+	dbRows, err := (*dbInstance).Query(ctx, "SELECT COUNT(*) FROM duplicate_files WHERE group_id = 1")
+	assert.NoError(t, err)
+	for dbRows.Next() {
+		assert.NoError(t, dbRows.Scan(&count))
+	}
+	dbRows.Close()
+	assert.Equal(t, 3, count)
+}
@@ -0,0 +1,123 @@
+package scan
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"terrasync/db"
+	"terrasync/i18n"
+)
+
+// EmptyEntries are the empty directories and zero-byte files of a scan job
+type EmptyEntries struct {
+	JobID string
+	Path  string
+	Dirs  []string // 没有任何条目的目录，按路径排序
+	Files []string // 0字节的普通文件，按路径排序
+	Top   int      // 控制台每类列出的条目数，0列出全部
+}
+
+// FindEmptyEntries lists the directories saved in a scan job without any entry
+// under them and the zero-byte regular files. Entries excluded by the filters
+// of the scan are not saved, a directory holding only such entries is empty.
+func FindEmptyEntries(ctx context.Context, jobDir string, top int) (*EmptyEntries, error) {
+	summary, err := LoadJobSummary(jobDir)
+	if err != nil {
+		return nil, err
+	}
+	dbInstance, err := NewDB(summary.DbType, jobDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job database: %w", err)
+	}
+	defer (*dbInstance).Close()
+
+	dirs := make(map[string]bool) // 目录是否有条目
+	result := &EmptyEntries{JobID: summary.JobID, Path: summary.Path, Top: top}
+	err = (*dbInstance).ListEntries(ctx, "", func(entry db.FileInfoData) error {
+		key := filepath.ToSlash(entry.Key)
+		if entry.IsDir {
+			if _, ok := dirs[key]; !ok {
+				dirs[key] = false
+			}
+		} else if entry.IsRegular && entry.Size == 0 {
+			result.Files = append(result.Files, filepath.Join(summary.Path, key))
+		}
+		if parent := path.Dir(key); parent != key {
+			dirs[parent] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job database: %w", err)
+	}
+
+	for key, used := range dirs {
+		if !used {
+			result.Dirs = append(result.Dirs, filepath.Join(summary.Path, key))
+		}
+	}
+	sort.Strings(result.Dirs)
+	sort.Strings(result.Files)
+	return result, nil
+}
+
+// Print prints the counts and the first empty entries to the console and the log
+func (r *EmptyEntries) Print() {
+	fmt.Println()
+	console.Title("Empty Entries")
+
+	console.Field("Job ID", r.JobID)
+	console.Field("Directories", len(r.Dirs))
+	console.Field("Zero-byte files", len(r.Files))
+
+	for _, list := range []struct {
+		title string
+		paths []string
+	}{{"Empty Directories", r.Dirs}, {"Zero-byte Files", r.Files}} {
+		if len(list.paths) == 0 {
+			continue
+		}
+		console.Section(list.title)
+		shown := list.paths
+		if r.Top > 0 && len(shown) > r.Top {
+			shown = shown[:r.Top]
+		}
+		for _, p := range shown {
+			console.Printf("  %s\n", p)
+		}
+		if len(shown) < len(list.paths) {
+			console.Printf("  %s\n", i18n.Sprintf("... (%d more, see the CSV export)", len(list.paths)-len(shown)))
+		}
+	}
+
+	console.End()
+}
+
+// WriteCSV writes every empty directory and zero-byte file, one per row
+func (r *EmptyEntries) WriteCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create empty entries report: %w", err)
+	}
+	w := csv.NewWriter(f)
+	_ = w.Write([]string{"type", "path"})
+	for _, dir := range r.Dirs {
+		_ = w.Write([]string{"dir", dir})
+	}
+	for _, file := range r.Files {
+		_ = w.Write([]string{"file", file})
+	}
+	w.Flush()
+	err = w.Error()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write empty entries report: %w", err)
+	}
+	return nil
+}
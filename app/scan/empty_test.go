@@ -0,0 +1,73 @@
+package scan
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFindEmptyEntries 测试列出空目录及0字节文件，有子目录的目录不算空目录
+func TestFindEmptyEntries(t *testing.T) {
+	ctx := context.Background()
+	jobDir := t.TempDir()
+
+	storage, err := object.CreateStorage("mem://empty-test")
+	assert.NoError(t, err)
+	for key, data := range map[string]string{
+		"/a/data.txt":    "data",
+		"/a/zero.txt":    "",
+		"/b/c/zero.log":  "",
+		"/d/e/":          "",
+		"/logs/":         "",
+		"/logs/old/tmp/": "",
+	} {
+		assert.NoError(t, storage.Put(key, strings.NewReader(data)))
+	}
+	var entries []object.FileInfo
+	for _, key := range []string{"/a", "/a/data.txt", "/a/zero.txt", "/b", "/b/c", "/b/c/zero.log", "/d", "/d/e", "/logs", "/logs/old", "/logs/old/tmp"} {
+		fi, err := storage.Head(key)
+		assert.NoError(t, err)
+		entries = append(entries, fi)
+	}
+	dbInstance, err := InitDatabase(ctx, "sqlite", jobDir)
+	assert.NoError(t, err)
+	assert.NoError(t, (*dbInstance).SaveEntries(ctx, entries, ""))
+	assert.NoError(t, (*dbInstance).Close())
+	assert.NoError(t, SaveJobSummary(jobDir, JobSummary{
+		JobID:     "Job_empty_scan",
+		Path:      "/mnt",
+		DbType:    "sqlite",
+		StartTime: time.Now().UTC(),
+		EndTime:   time.Now().UTC(),
+		Stats:     NewStats().Snapshot(),
+	}))
+
+	result, err := FindEmptyEntries(ctx, jobDir, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join("/mnt", "/d/e"), filepath.Join("/mnt", "/logs/old/tmp")}, result.Dirs)
+	assert.Equal(t, []string{filepath.Join("/mnt", "/a/zero.txt"), filepath.Join("/mnt", "/b/c/zero.log")}, result.Files)
+	result.Print()
+
+	csvPath := filepath.Join(t.TempDir(), "empty.csv")
+	assert.NoError(t, result.WriteCSV(csvPath))
+	f, err := os.Open(csvPath)
+	assert.NoError(t, err)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"type", "path"},
+		{"dir", filepath.Join("/mnt", "/d/e")},
+		{"dir", filepath.Join("/mnt", "/logs/old/tmp")},
+		{"file", filepath.Join("/mnt", "/a/zero.txt")},
+		{"file", filepath.Join("/mnt", "/b/c/zero.log")},
+	}, records)
+}
@@ -0,0 +1,196 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"terrasync/log"
+	"terrasync/object"
+	"time"
+)
+
+// EventType is a class of scan events sent to Kafka
+type EventType string
+
+const (
+	// EventFiles is a scanned file, symlink or other non-directory entry
+	EventFiles EventType = "files"
+	// EventDirectories is a scanned directory
+	EventDirectories EventType = "directories"
+	// EventErrors is a directory or entry that failed to scan
+	EventErrors EventType = "errors"
+	// EventSummary is the job summary sent when the scan ends
+	EventSummary EventType = "summary"
+)
+
+// EventTypes lists the event types in the order they are documented
+var EventTypes = []EventType{EventFiles, EventDirectories, EventErrors, EventSummary}
+
+// TopicConfig is the Kafka topic of an event type
+type TopicConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Topic   string `mapstructure:"topic"`
+}
+
+// ParseKafkaTopics validates the topics configured per event type (kafka.topics).
+// Without any, files and directories are sent to defaultTopic (kafka.topic) as
+// before; otherwise the event types not configured are not sent.
+func ParseKafkaTopics(topics map[string]TopicConfig, defaultTopic string) (map[EventType]TopicConfig, error) {
+	if len(topics) == 0 {
+		if defaultTopic == "" {
+			return nil, nil
+		}
+		return map[EventType]TopicConfig{
+			EventFiles:       {Enabled: true, Topic: defaultTopic},
+			EventDirectories: {Enabled: true, Topic: defaultTopic},
+		}, nil
+	}
+	parsed := make(map[EventType]TopicConfig, len(topics))
+	for name, topic := range topics {
+		event := EventType(strings.ToLower(name))
+		if !isEventType(event) {
+			return nil, fmt.Errorf("unsupported kafka event type %q, expect one of %v", name, EventTypes)
+		}
+		if topic.Enabled && topic.Topic == "" {
+			return nil, fmt.Errorf("kafka topic of %s events is enabled without a topic name", event)
+		}
+		parsed[event] = topic
+	}
+	return parsed, nil
+}
+
+func isEventType(event EventType) bool {
+	for _, e := range EventTypes {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// EventPublisher sends the scan events to the Kafka topic of their event type.
+// A nil publisher, used when Kafka is disabled or unreachable, sends nothing.
+type EventPublisher struct {
+	producer *KafkaProducer
+	topics   map[EventType]string
+	jobID    string
+}
+
+// NewEventPublisher connects to Kafka when it is enabled with at least one topic,
+// it returns nil otherwise
+func NewEventPublisher(config KafkaConfig, jobID string) (*EventPublisher, error) {
+	topics := make(map[EventType]string)
+	for event, topic := range config.Topics {
+		if topic.Enabled {
+			topics[event] = topic.Topic
+		}
+	}
+	if !config.Enabled || len(topics) == 0 {
+		return nil, nil
+	}
+	producer, err := InitKafkaProducer(config)
+	if err != nil {
+		return nil, err
+	}
+	p := &EventPublisher{producer: producer, topics: topics, jobID: jobID}
+	log.Infof("Kafka topics: %s", p)
+	return p, nil
+}
+
+// String describes the topic of every enabled event type
+func (p *EventPublisher) String() string {
+	routes := make([]string, 0, len(p.topics))
+	for event, topic := range p.topics {
+		routes = append(routes, fmt.Sprintf("%s=%s", event, topic))
+	}
+	sort.Strings(routes)
+	return strings.Join(routes, ", ")
+}
+
+// Enabled reports whether events of the type are sent
+func (p *EventPublisher) Enabled(event EventType) bool {
+	if p == nil {
+		return false
+	}
+	_, ok := p.topics[event]
+	return ok
+}
+
+// PublishEntry sends a scanned entry to the files or directories topic, the
+// message is the key of the entry. Nothing is sent once ctx is done.
+func (p *EventPublisher) PublishEntry(ctx context.Context, fileInfo object.FileInfo) error {
+	event := EventFiles
+	if fileInfo.IsDir() {
+		event = EventDirectories
+	}
+	if !p.Enabled(event) {
+		return nil
+	}
+	return p.producer.SendMessage(ctx, p.topics[event], fileInfo)
+}
+
+// errorEvent is the message sent to the errors topic
+type errorEvent struct {
+	JobID string    `json:"job_id"`
+	Path  string    `json:"path"`
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+// PublishError sends a scan error to the errors topic, failures are only logged
+// since the error is in the log already
+func (p *EventPublisher) PublishError(path string, scanErr error) {
+	if !p.Enabled(EventErrors) {
+		return
+	}
+	value, err := json.Marshal(errorEvent{JobID: p.jobID, Path: path, Error: scanErr.Error(), Time: time.Now().UTC()})
+	if err == nil {
+		err = p.producer.Send(p.topics[EventErrors], value)
+	}
+	if err != nil {
+		log.Warnf("Failed to send scan error of %s to Kafka: %v", path, err)
+	}
+}
+
+// PublishSummary sends the job summary to the summary topic
+func (p *EventPublisher) PublishSummary(summary *JobSummary) error {
+	if !p.Enabled(EventSummary) {
+		return nil
+	}
+	value, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("failed to encode job summary: %w", err)
+	}
+	if err := p.producer.Send(p.topics[EventSummary], value); err != nil {
+		return fmt.Errorf("failed to send job summary to Kafka: %w", err)
+	}
+	return nil
+}
+
+// Close closes the Kafka producer
+func (p *EventPublisher) Close() error {
+	if p == nil {
+		return nil
+	}
+	return p.producer.Close()
+}
+
+// kafkaSink sends the scanned entries to the files and directories topics
+type kafkaSink struct {
+	publisher *EventPublisher
+}
+
+func (s *kafkaSink) Name() string {
+	return "kafka"
+}
+
+func (s *kafkaSink) Write(ctx context.Context, batch []object.FileInfo) error {
+	for _, fileInfo := range batch {
+		if err := s.publisher.PublishEntry(ctx, fileInfo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseKafkaTopics 测试按事件类型配置topic
+func TestParseKafkaTopics(t *testing.T) {
+	tests := []struct {
+		name         string
+		topics       map[string]TopicConfig
+		defaultTopic string
+		want         map[EventType]TopicConfig
+		wantErr      bool
+	}{
+		{
+			name:         "未配置时文件和目录发送到kafka.topic",
+			defaultTopic: "scan",
+			want: map[EventType]TopicConfig{
+				EventFiles:       {Enabled: true, Topic: "scan"},
+				EventDirectories: {Enabled: true, Topic: "scan"},
+			},
+		},
+		{
+			name: "按事件类型配置",
+			topics: map[string]TopicConfig{
+				"files":   {Enabled: true, Topic: "scan-files"},
+				"Errors":  {Enabled: true, Topic: "scan-errors"},
+				"summary": {Enabled: false},
+			},
+			defaultTopic: "scan",
+			want: map[EventType]TopicConfig{
+				EventFiles:   {Enabled: true, Topic: "scan-files"},
+				EventErrors:  {Enabled: true, Topic: "scan-errors"},
+				EventSummary: {Enabled: false},
+			},
+		},
+		{
+			name:    "不支持的事件类型",
+			topics:  map[string]TopicConfig{"dirs": {Enabled: true, Topic: "scan-dirs"}},
+			wantErr: true,
+		},
+		{
+			name:    "启用但没有topic名称",
+			topics:  map[string]TopicConfig{"errors": {Enabled: true}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseKafkaTopics(tt.topics, tt.defaultTopic)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestEventPublisherDisabled 测试Kafka未启用时不发送任何事件
+func TestEventPublisherDisabled(t *testing.T) {
+	publisher, err := NewEventPublisher(KafkaConfig{
+		Topics: map[EventType]TopicConfig{EventFiles: {Enabled: true, Topic: "scan"}},
+	}, "job")
+	assert.NoError(t, err)
+	assert.Nil(t, publisher)
+	assert.False(t, publisher.Enabled(EventFiles))
+	publisher.PublishError("/a", errors.New("failed"))
+	assert.NoError(t, publisher.PublishSummary(&JobSummary{}))
+	assert.NoError(t, publisher.Close())
+}
+
+// failListStorage 列举指定目录时失败
+type failListStorage struct {
+	object.Storage
+	dir string
+}
+
+func (s *failListStorage) List(dir string) (<-chan object.FileInfo, error) {
+	if dir == s.dir {
+		return nil, errors.New("permission denied")
+	}
+	return s.Storage.List(dir)
+}
+
+// TestListAllOnError 测试列举失败的目录报告给OnError
+func TestListAllOnError(t *testing.T) {
+	mem, err := object.CreateStorage("mem://events-test")
+	assert.NoError(t, err)
+	for _, key := range []string{"/ok/a.txt", "/denied/b.txt"} {
+		assert.NoError(t, mem.Put(key, strings.NewReader("data")))
+	}
+	var mu sync.Mutex
+	failed := map[string]string{}
+	entries := ListAll(context.Background(), &failListStorage{Storage: mem, dir: "/denied"}, ListOptions{
+		Concurrency: 2,
+		OnError: func(path string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed[path] = err.Error()
+		},
+	})
+	for range entries {
+	}
+	assert.Equal(t, map[string]string{"/denied": "storage list failed: permission denied"}, failed)
+}
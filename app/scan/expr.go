@@ -0,0 +1,247 @@
+package scan
+
+import (
+	"fmt"
+	"strings"
+	"terrasync/object"
+	"time"
+)
+
+// exprNode 是过滤表达式语法树的节点
+type exprNode interface {
+	eval(fileInfo object.FileInfo, now time.Time) bool
+}
+
+// andNode 所有子表达式都满足时满足
+type andNode []exprNode
+
+func (n andNode) eval(fileInfo object.FileInfo, now time.Time) bool {
+	for _, child := range n {
+		if !child.eval(fileInfo, now) {
+			return false
+		}
+	}
+	return true
+}
+
+// orNode 任一子表达式满足时满足
+type orNode []exprNode
+
+func (n orNode) eval(fileInfo object.FileInfo, now time.Time) bool {
+	for _, child := range n {
+		if child.eval(fileInfo, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// notNode 子表达式不满足时满足
+type notNode struct {
+	child exprNode
+}
+
+func (n notNode) eval(fileInfo object.FileInfo, now time.Time) bool {
+	return !n.child.eval(fileInfo, now)
+}
+
+// condNode 是单个条件
+type condNode Condition
+
+func (n condNode) eval(fileInfo object.FileInfo, now time.Time) bool {
+	return matchCondition(fileInfo, Condition(n), now)
+}
+
+// token 类型
+const (
+	tokenWord   = iota // 属性名、关键字、运算符in/like或不带引号的值
+	tokenString        // 带引号的值
+	tokenOp            // ==、!=、=~、>、<、>=、<=
+	tokenLParen
+	tokenRParen
+)
+
+// token 是表达式中的一个词
+type token struct {
+	kind int
+	text string
+	pos  int // 在表达式中的位置(从1开始)
+}
+
+// tokenize 把表达式拆分为词，引号中的字符(包括空格、括号及and)属于同一个值
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(input); {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i + 1})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i + 1})
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(input[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("第%d个字符处的引号没有结束", i+1)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: input[i+1 : i+1+end], pos: i + 1})
+			i += end + 2
+		case strings.ContainsRune("=!<>", rune(c)):
+			op := input[i : i+1]
+			if i+1 < len(input) && (input[i+1] == '=' || c == '=' && input[i+1] == '~') {
+				op = input[i : i+2]
+			}
+			if op == "=" || op == "!" {
+				return nil, fmt.Errorf("第%d个字符处的运算符无效: %s", i+1, op)
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: op, pos: i + 1})
+			i += len(op)
+		default:
+			start := i
+			for i < len(input) && !strings.ContainsRune(" \t\n\r()'\"=!<>", rune(input[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: input[start:i], pos: start + 1})
+		}
+	}
+	return tokens, nil
+}
+
+// exprParser 按优先级(not高于and高于or)解析过滤表达式:
+//
+//	expr    = and { "or" and }
+//	and     = unary { "and" unary }
+//	unary   = "not" unary | primary
+//	primary = "(" expr ")" | 属性名 运算符 值
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+// parseExpr 解析过滤表达式，关键字、属性名及运算符不区分大小写
+func parseExpr(input string) (exprNode, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("表达式为空")
+	}
+	p := &exprParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.peek(); ok {
+		return nil, fmt.Errorf("第%d个字符处多余的内容: %s", t.pos, t.text)
+	}
+	return node, nil
+}
+
+// peek 返回下一个词
+func (p *exprParser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// keyword 下一个词是关键字kw时跳过它
+func (p *exprParser) keyword(kw string) bool {
+	t, ok := p.peek()
+	if ok && t.kind == tokenWord && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	nodes := orNode{node}
+	for p.keyword("or") {
+		if node, err = p.parseAnd(); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	node, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	nodes := andNode{node}
+	for p.keyword("and") {
+		if node, err = p.parseUnary(); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.keyword("not") {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{child: node}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("表达式不完整，缺少条件")
+	}
+	if t.kind == tokenLParen {
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if r, ok := p.peek(); !ok || r.kind != tokenRParen {
+			return nil, fmt.Errorf("第%d个字符处的括号没有结束", t.pos)
+		}
+		p.pos++
+		return node, nil
+	}
+	if t.kind != tokenWord {
+		return nil, fmt.Errorf("第%d个字符处应为属性名: %s", t.pos, t.text)
+	}
+
+	// 属性名 运算符 值
+	if p.pos+2 >= len(p.tokens) {
+		return nil, fmt.Errorf("第%d个字符处的条件不完整: %s", t.pos, t.text)
+	}
+	op, value := p.tokens[p.pos+1], p.tokens[p.pos+2]
+	if op.kind != tokenOp && !(op.kind == tokenWord && (strings.EqualFold(op.text, "in") || strings.EqualFold(op.text, "like"))) {
+		return nil, fmt.Errorf("第%d个字符处找不到有效的运算符: %s", op.pos, op.text)
+	}
+	if value.kind != tokenWord && value.kind != tokenString {
+		return nil, fmt.Errorf("第%d个字符处缺少值", value.pos)
+	}
+	p.pos += 3
+	cond, err := newCondition(t.text, op.text, value.text, value.kind == tokenString)
+	if err != nil {
+		return nil, fmt.Errorf("第%d个字符处的条件无效: %w", t.pos, err)
+	}
+	return condNode(cond), nil
+}
@@ -7,47 +7,60 @@ import (
 	"strconv"
 	"strings"
 	"terrasync/object"
+	"terrasync/pkg/units"
 	"time"
 )
 
 // Condition 表示单个过滤条件
 type Condition struct {
-	Property string      // 属性名(name, size, modified等)
-	Operator string      // 运算符(==, !=, >, <, >=, <=, in)
-	Value    interface{} // 值
+	Property string         // 属性名(name, size, modified等)
+	Operator string         // 运算符(==, !=, >, <, >=, <=, in, like, =~)
+	Value    interface{}    // 值
+	Regexp   *regexp.Regexp // =~预编译的正则表达式
 }
 
-// ConditionFilter 实现Filter接口，用于基于多个条件过滤文件
+// ConditionFilter 实现Filter接口，用于基于过滤表达式(条件以and、or、not及括号组合)过滤文件
 type ConditionFilter struct {
-	conditions []Condition
+	expr exprNode // 为nil时没有条件
 }
 
-// NewConditionFilter 创建一个新的条件过滤器
+// NewConditionFilter 创建一个新的条件过滤器，文件需要满足每一个表达式
 func NewConditionFilter(conditions []string) (*ConditionFilter, error) {
 	filter := &ConditionFilter{}
+	var nodes andNode
 	for _, condStr := range conditions {
-		cond, err := parseCondition(condStr)
+		node, err := parseExpr(condStr)
 		if err != nil {
 			return nil, fmt.Errorf("解析条件失败: %s, 错误: %v", condStr, err)
 		}
-		filter.conditions = append(filter.conditions, cond)
+		nodes = append(nodes, node)
+	}
+	switch len(nodes) {
+	case 0:
+	case 1:
+		filter.expr = nodes[0]
+	default:
+		filter.expr = nodes
 	}
 	return filter, nil
 }
 
+// Empty reports whether the filter has no conditions, a nil filter is empty
+func (f *ConditionFilter) Empty() bool {
+	return f == nil || f.expr == nil
+}
+
 func (f *ConditionFilter) IsSatisfied(fileInfo object.FileInfo) bool {
-	for _, cond := range f.conditions {
-		if !f.matchCondition(fileInfo, cond) {
-			return false // 任何一个条件不满足则跳过
-		}
+	if f.expr == nil {
+		return true // 没有条件时都满足
 	}
-	return true // 所有条件都满足则不跳过
+	return f.expr.eval(fileInfo, time.Now())
 }
 
 // 解析单个条件字符串
 func parseCondition(condStr string) (Condition, error) {
 	// 支持的运算符，按优先级排序
-	operators := []string{">=", "<=", "==", "!=", "in", "like", ">", "<"}
+	operators := []string{">=", "<=", "==", "!=", "=~", "in", "like", ">", "<"}
 
 	opRegex := regexp.MustCompile(`\s*(` + strings.Join(operators, "|") + `)\s*`)
 
@@ -64,8 +77,15 @@ func parseCondition(condStr string) (Condition, error) {
 		return Condition{}, fmt.Errorf("条件格式错误: %s", condStr)
 	}
 
-	property := strings.TrimSpace(parts[0])
-	valueStr := strings.TrimSpace(parts[1])
+	return newCondition(parts[0], operator, parts[1], false)
+}
+
+// newCondition 根据属性类型解析条件的值，quoted表示值在表达式中带引号
+func newCondition(property, operator, valueStr string, quoted bool) (Condition, error) {
+	property = strings.TrimSpace(property)
+	if !quoted {
+		valueStr = strings.TrimSpace(valueStr)
+	}
 
 	// 解析值根据属性类型
 	var value interface{}
@@ -74,13 +94,16 @@ func parseCondition(condStr string) (Condition, error) {
 	switch strings.ToLower(property) {
 	case "name", "type", "path":
 		// 字符串类型(去除引号)
-		value = strings.Trim(valueStr, "'\"")
+		if !quoted {
+			valueStr = strings.Trim(valueStr, "'\"")
+		}
+		value = valueStr
 	case "size":
-		// 大小类型(支持K, M, G)
-		value, err = parseSize(valueStr)
+		// 大小类型(支持K, MiB, GB等单位)
+		value, err = units.ParseSize(valueStr)
 	case "modified":
-		// 时间类型(小时)
-		value, err = parseDuration(valueStr)
+		// 时间类型(小时)，单位不区分大小写
+		value, err = parseDuration(strings.ToLower(valueStr))
 	default:
 		return Condition{}, fmt.Errorf("不支持的属性: %s", property)
 	}
@@ -89,69 +112,47 @@ func parseCondition(condStr string) (Condition, error) {
 		return Condition{}, err
 	}
 
-	return Condition{
+	cond := Condition{
 		Property: strings.ToLower(property),
 		Operator: strings.ToLower(operator),
 		Value:    value,
-	}, nil
-}
-
-// 解析大小字符串(如: 100, 10K, 2M, 3G)
-func parseSize(sizeStr string) (int64, error) {
-	sizeStr = strings.TrimSpace(sizeStr)
-	multipliers := map[string]int64{
-		"k": 1 << 10,
-		"m": 1 << 20,
-		"g": 1 << 30,
-		"t": 1 << 40,
-	}
-
-	// 提取数字和单位
-	re := regexp.MustCompile(`^([0-9.]+)([kmgKMGtT]?)$`)
-	matches := re.FindStringSubmatch(sizeStr)
-	if len(matches) < 2 {
-		return 0, fmt.Errorf("无效的大小格式: %s", sizeStr)
-	}
-
-	// 解析数值
-	num, err := strconv.ParseFloat(matches[1], 64)
-	if err != nil {
-		return 0, err
 	}
-
-	// 应用单位乘数
-	unit := strings.ToLower(matches[2])
-	multiplier := int64(1)
-	if unit != "" {
-		multiplier = multipliers[unit]
-		if multiplier == 0 {
-			return 0, fmt.Errorf("无效的单位: %s", unit)
+	if cond.Operator == "=~" {
+		// 正则表达式只编译一次，不要求匹配整个字符串
+		pattern, ok := value.(string)
+		if !ok {
+			return Condition{}, fmt.Errorf("运算符=~只能用于name、path及type: %s", property)
+		}
+		if cond.Regexp, err = regexp.Compile(pattern); err != nil {
+			return Condition{}, fmt.Errorf("正则表达式无效: %v", err)
 		}
 	}
-
-	return int64(num * float64(multiplier)), nil
+	return cond, nil
 }
 
-// 解析时间字符串(如: 0.5, 24 表示小时)
+// 解析时间字符串(如: 0.5, 24 表示小时，也可以带单位，如: 30m, 7d, 4w)
 func parseDuration(durStr string) (time.Duration, error) {
 	durStr = strings.TrimSpace(durStr)
-	hours, err := strconv.ParseFloat(durStr, 64)
-	if err != nil {
-		return 0, err
+	if hours, err := strconv.ParseFloat(durStr, 64); err == nil {
+		return time.Duration(hours * float64(time.Hour)), nil
 	}
-	return time.Duration(hours * float64(time.Hour)), nil
+	return units.ParseDuration(durStr)
 }
 
-// 匹配单个条件
-func (f *ConditionFilter) matchCondition(fileInfo object.FileInfo, cond Condition) bool {
-	now := time.Now()
-
+// 匹配单个条件，modified相对于now比较
+func matchCondition(fileInfo object.FileInfo, cond Condition, now time.Time) bool {
 	switch cond.Property {
 	case "name":
 		fileName := filepath.Base(fileInfo.Key())
+		if cond.Regexp != nil {
+			return cond.Regexp.MatchString(fileName)
+		}
 		return matchString(fileName, cond.Operator, cond.Value.(string))
 	case "path":
 		filePath := fileInfo.Key()
+		if cond.Regexp != nil {
+			return cond.Regexp.MatchString(filePath)
+		}
 		return matchString(filePath, cond.Operator, cond.Value.(string))
 	case "size":
 		fileSize := fileInfo.Size()
@@ -165,6 +166,9 @@ func (f *ConditionFilter) matchCondition(fileInfo object.FileInfo, cond Conditio
 		if fileInfo.IsDir() {
 			fileType = "dir"
 		}
+		if cond.Regexp != nil {
+			return cond.Regexp.MatchString(fileType)
+		}
 		return matchString(fileType, cond.Operator, cond.Value.(string))
 	default:
 		return false
@@ -191,57 +191,6 @@ func TestParseCondition(t *testing.T) {
 	}
 }
 
-// TestParseSize 测试解析大小字符串
-func TestParseSize(t *testing.T) {
-	cases := []struct {
-		name      string
-		sizeStr   string
-		expected  int64
-		expectErr bool
-	}{{
-		name:      "纯数字",
-		sizeStr:   "100",
-		expected:  100,
-		expectErr: false,
-	}, {
-		name:      "K单位",
-		sizeStr:   "10K",
-		expected:  10 * 1024,
-		expectErr: false,
-	}, {
-		name:      "M单位",
-		sizeStr:   "2M",
-		expected:  2 * 1024 * 1024,
-		expectErr: false,
-	}, {
-		name:      "G单位",
-		sizeStr:   "3G",
-		expected:  3 * 1024 * 1024 * 1024,
-		expectErr: false,
-	}, {
-		name:      "带小数点",
-		sizeStr:   "1.5K",
-		expected:  1536, // 1.5 * 1024
-		expectErr: false,
-	}, {
-		name:      "无效格式",
-		sizeStr:   "10X",
-		expectErr: true,
-	}}
-
-	for _, tc := range cases {
-		t.Run(tc.name, func(t *testing.T) {
-			size, err := parseSize(tc.sizeStr)
-			if tc.expectErr {
-				assert.Error(t, err)
-				return
-			}
-			assert.NoError(t, err)
-			assert.Equal(t, tc.expected, size)
-		})
-	}
-}
-
 // TestMatchLike 测试like操作符的匹配功能
 func TestMatchLike(t *testing.T) {
 	cases := []struct {
@@ -306,6 +255,11 @@ func TestParseDuration(t *testing.T) {
 		durStr:    "0.5",
 		expected:  30 * time.Minute,
 		expectErr: false,
+	}, {
+		name:      "带单位",
+		durStr:    "7d",
+		expected:  7 * 24 * time.Hour,
+		expectErr: false,
 	}, {
 		name:      "无效格式",
 		durStr:    "abc",
@@ -580,3 +534,95 @@ func TestConditionFilter_IsSatisfied(t *testing.T) {
 		})
 	}
 }
+
+// TestConditionExpression 测试and、or、not及括号组成的过滤表达式
+func TestConditionExpression(t *testing.T) {
+	now := time.Now()
+	big := &MockFileInfo{key: "/data/Brand Video.MP4", _size: 2 << 30, _mtime: now.Add(-48 * time.Hour)}
+	recent := &MockFileInfo{key: "/data/notes.txt", _size: 10, _mtime: now.Add(-30 * time.Minute)}
+	old := &MockFileInfo{key: "/data/android.log", _size: 100, _mtime: now.Add(-72 * time.Hour)}
+	dir := &MockFileInfo{key: "/data/sub", _isDir: true, _mtime: now.Add(-72 * time.Hour)}
+
+	cases := []struct {
+		name     string
+		expr     string
+		expected []bool // big, recent, old, dir
+	}{
+		{name: "or", expr: "size>1G or modified>1", expected: []bool{true, true, false, false}},
+		{name: "or及修改时间早于", expr: "size<1K or modified<60", expected: []bool{false, true, true, true}},
+		{name: "and优先于or", expr: "type == file and size > 1K or name like '%.log'", expected: []bool{true, false, true, false}},
+		{name: "括号", expr: "type == file and (size > 1K or name like '%.log')", expected: []bool{true, false, true, false}},
+		{name: "not", expr: "not type == dir and not (name like '%.log')", expected: []bool{true, true, false, false}},
+		{name: "双重not", expr: "not not size > 1G", expected: []bool{true, false, false, false}},
+		{name: "关键字不区分大小写", expr: "Size > 1g OR Modified > 1H", expected: []bool{true, true, false, false}},
+		{name: "值中的and及空格", expr: "name == 'Brand Video.MP4' or name like '%and%'", expected: []bool{true, false, true, false}},
+		{name: "兼容旧表达式", expr: "type==file and size > 50 and modified > 36", expected: []bool{false, false, false, false}},
+		{name: "嵌套括号", expr: "((size < 1K) and (name in 'NOTES' or path in 'sub'))", expected: []bool{false, true, false, true}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			filter, err := NewConditionFilter(ParseConditions(tc.expr))
+			assert.NoError(t, err)
+			for i, fileInfo := range []*MockFileInfo{big, recent, old, dir} {
+				assert.Equal(t, tc.expected[i], filter.IsSatisfied(fileInfo), fileInfo.key)
+			}
+		})
+	}
+
+	assert.True(t, (*ConditionFilter)(nil).Empty())
+	filter, err := NewConditionFilter(ParseConditions("  "))
+	assert.NoError(t, err)
+	assert.True(t, filter.Empty())
+	assert.True(t, filter.IsSatisfied(big))
+
+	for _, expr := range []string{
+		"size > 1G or",
+		"(size > 1G",
+		"size > 1G)",
+		"not",
+		"size 1G",
+		"size >",
+		"owner == root",
+		"name == 'unterminated",
+		"size = 1G",
+		"size > 1G size < 2G",
+	} {
+		_, err := NewConditionFilter(ParseConditions(expr))
+		assert.Error(t, err, expr)
+	}
+}
+
+// TestRegexCondition 测试=~运算符使用预编译的正则表达式匹配
+func TestRegexCondition(t *testing.T) {
+	cond, err := parseCondition(`name =~ '^backup_\d{8}\.tar$'`)
+	assert.NoError(t, err)
+	assert.Equal(t, "=~", cond.Operator)
+	if assert.NotNil(t, cond.Regexp) {
+		assert.Equal(t, `^backup_\d{8}\.tar$`, cond.Regexp.String())
+	}
+
+	cases := []struct {
+		expr     string
+		key      string
+		isDir    bool
+		expected bool
+	}{
+		{expr: `name =~ '^backup_\d{8}\.tar$'`, key: "/archive/backup_20240131.tar", expected: true},
+		{expr: `name =~ '^backup_\d{8}\.tar$'`, key: "/archive/backup_2024.tar", expected: false},
+		{expr: `name =~ '^backup_\d{8}\.tar$'`, key: "/archive/backup_20240131.tar.gz", expected: false},
+		{expr: `path=~"/(tmp|cache)/"`, key: "/home/user/cache/data.bin", expected: true},
+		{expr: `path =~ '(?i)\.JPE?G$' and not type == dir`, key: "/photos/IMG_0001.jpg", expected: true},
+		{expr: `type =~ 'dir' or name =~ '^\.'`, key: "/home/user/.cache", isDir: true, expected: true},
+		{expr: `name =~ 'report'`, key: "/docs/2024 report final.pdf", expected: true},
+	}
+	for _, tc := range cases {
+		filter, err := NewConditionFilter(ParseConditions(tc.expr))
+		assert.NoError(t, err, tc.expr)
+		assert.Equal(t, tc.expected, filter.IsSatisfied(&MockFileInfo{key: tc.key, _isDir: tc.isDir}), tc.expr+" "+tc.key)
+	}
+
+	for _, expr := range []string{"name =~ '([a-z'", "size =~ '1G'", "modified =~ 24", "name = ~ 'a'"} {
+		_, err := NewConditionFilter(ParseConditions(expr))
+		assert.Error(t, err, expr)
+	}
+}
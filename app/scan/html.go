@@ -0,0 +1,166 @@
+package scan
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"terrasync/db"
+	"terrasync/i18n"
+	"terrasync/log"
+	"time"
+)
+
+// HTMLReportName is the file name of the HTML report in the job directory
+const HTMLReportName = "report.html"
+
+// htmlTopFiles is the number of rows of the largest files in the HTML report
+const htmlTopFiles = 20
+
+// htmlFuncs are the helpers of the HTML report template
+var htmlFuncs = template.FuncMap{
+	"t":    i18n.T,
+	"time": i18n.FormatTime,
+	"size": FormatFileSize,
+	"duration": func(start, end time.Time) time.Duration {
+		return end.Sub(start).Round(time.Second)
+	},
+	"lifecycleJSON": LifecycleJSON,
+	"percent":       percent,
+	"sorted": func(m map[string]int64) []string {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	},
+}
+
+//go:embed templates/report.html
+var htmlTemplates embed.FS
+
+// htmlReport is the template of the job report, embedded so the binary writes
+// a self-contained page without files next to it
+var htmlReport = template.Must(template.New("report.html").Funcs(htmlFuncs).ParseFS(htmlTemplates, "templates/report.html"))
+
+// ExtensionUsage is the number and size of the files with one extension
+type ExtensionUsage struct {
+	Ext   string `json:"ext"` // 空表示无扩展名，*表示其余扩展名之和
+	Files int64  `json:"files"`
+	Bytes int64  `json:"bytes"`
+}
+
+// AvgSize returns the average size of the files
+func (u ExtensionUsage) AvgSize() int64 {
+	if u.Files == 0 {
+		return 0
+	}
+	return u.Bytes / u.Files
+}
+
+// LargeFile is one of the largest files of a job
+type LargeFile struct {
+	Path  string
+	Size  int64
+	MTime time.Time
+}
+
+// htmlDetails are the sections of the HTML report read from the job database
+type htmlDetails struct {
+	Files      int64
+	Bytes      int64
+	Sizes      Histogram
+	Categories Histogram // 按扩展名分类汇总
+	Extensions []ExtensionUsage
+	Largest    []LargeFile
+}
+
+// loadHTMLDetails reads the files of the job database once for the size
+// histogram, the categories and the largest files, the extension breakdown is
+// grouped by the database
+func loadHTMLDetails(ctx context.Context, dbInstance *db.DB, summary *JobSummary) (*htmlDetails, error) {
+	sizeLabels := make([]string, len(sizeBuckets))
+	for i, b := range sizeBuckets {
+		sizeLabels[i] = b.label
+	}
+	d := &htmlDetails{Sizes: newHistogram(sizeLabels), Categories: newCategoryHistogram()}
+
+	err := (*dbInstance).ListEntries(ctx, "", func(entry db.FileInfoData) error {
+		if entry.IsDir {
+			return nil
+		}
+		d.Files++
+		d.Bytes += entry.Size
+		for i, b := range sizeBuckets {
+			if b.max < 0 || entry.Size < b.max {
+				d.Sizes.add(i, entry.Size)
+				break
+			}
+		}
+		d.Categories.add(categoryOf(entry.Ext), entry.Size)
+
+		// 按大小降序保留前htmlTopFiles个文件
+		if len(d.Largest) == htmlTopFiles && entry.Size <= d.Largest[len(d.Largest)-1].Size {
+			return nil
+		}
+		i := sort.Search(len(d.Largest), func(i int) bool { return d.Largest[i].Size < entry.Size })
+		if len(d.Largest) < htmlTopFiles {
+			d.Largest = append(d.Largest, LargeFile{})
+		}
+		copy(d.Largest[i+1:], d.Largest[i:])
+		d.Largest[i] = LargeFile{Path: filepath.Join(summary.Path, entry.Key), Size: entry.Size, MTime: entry.MTime}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job database: %w", err)
+	}
+
+	if d.Extensions, err = extensionUsage(ctx, dbInstance); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// writeHTMLReport renders the job summary as a standalone HTML page, with the
+// size histogram, extension breakdown and largest files read from the job
+// database. Without the database only the summary is rendered.
+func writeHTMLReport(ctx context.Context, path string, summary *JobSummary, dbInstance *db.DB) error {
+	var details *htmlDetails
+	if dbInstance != nil {
+		var err error
+		if details, err = loadHTMLDetails(ctx, dbInstance, summary); err != nil {
+			log.Warnf("HTML report without file details: %v", err)
+		}
+	}
+
+	stats := summary.Stats.Stats()
+	var averageSize int64
+	if summary.Stats.FileCount > 0 {
+		averageSize = summary.Stats.TotalSize / summary.Stats.FileCount
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create HTML report: %w", err)
+	}
+	err = htmlReport.Execute(f, map[string]interface{}{
+		"Summary":     summary,
+		"Stats":       stats,
+		"Entries":     summary.Stats.FileCount + summary.Stats.DirCount,
+		"AverageSize": averageSize,
+		"CryptoMode":  summary.CryptoMode,
+		"Failed":      i18n.Sprintf("Failed (%v)", summary.Error),
+		"Details":     details,
+	})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write HTML report: %w", err)
+	}
+	return nil
+}
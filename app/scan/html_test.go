@@ -0,0 +1,80 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHTMLReport 测试HTML报告包含从任务数据库读取的大小分布、扩展名分布及最大的文件
+func TestHTMLReport(t *testing.T) {
+	ctx := context.Background()
+	jobDir := t.TempDir()
+
+	storage, err := object.CreateStorage("mem://html-report-test")
+	assert.NoError(t, err)
+	keys := []string{"/data", "/data/README"}
+	assert.NoError(t, storage.Put("/data/README", strings.NewReader("readme")))
+	for i := 0; i < 25; i++ {
+		key := fmt.Sprintf("/data/f%02d.e%02d", i, i)
+		assert.NoError(t, storage.Put(key, strings.NewReader(strings.Repeat("x", 100*(i+1)))))
+		keys = append(keys, key)
+	}
+	var entries []object.FileInfo
+	for _, key := range keys {
+		fi, err := storage.Head(key)
+		assert.NoError(t, err)
+		entries = append(entries, fi)
+	}
+	dbInstance, err := InitDatabase(ctx, "sqlite", jobDir)
+	assert.NoError(t, err)
+	defer (*dbInstance).Close()
+	assert.NoError(t, (*dbInstance).SaveEntries(ctx, entries, ""))
+
+	summary := &JobSummary{
+		JobID:     "Job_html",
+		Path:      "/mnt",
+		StartTime: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		EndTime:   time.Date(2024, 3, 1, 12, 1, 0, 0, time.UTC),
+		Stats:     NewStats().Snapshot(),
+	}
+	details, err := loadHTMLDetails(ctx, dbInstance, summary)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(26), details.Files)
+	assert.Equal(t, int64(26), details.Sizes.Files[0])
+	assert.Equal(t, int64(26), details.Categories.Files[otherCategory])
+
+	// 前20个扩展名按容量降序，其余5个及无扩展名的文件合为一行
+	assert.Len(t, details.Extensions, topExtensions+1)
+	assert.Equal(t, ExtensionUsage{Ext: ".e24", Files: 1, Bytes: 2500}, details.Extensions[0])
+	assert.Equal(t, ExtensionUsage{Ext: "*", Files: 6, Bytes: 100 + 200 + 300 + 400 + 500 + 6}, details.Extensions[topExtensions])
+
+	assert.Len(t, details.Largest, htmlTopFiles)
+	assert.Equal(t, filepath.Join("/mnt", "/data/f24.e24"), details.Largest[0].Path)
+	assert.Equal(t, int64(600), details.Largest[htmlTopFiles-1].Size)
+
+	path := filepath.Join(jobDir, HTMLReportName)
+	assert.NoError(t, writeHTMLReport(ctx, path, summary, dbInstance))
+	html, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	for _, s := range []string{"Job_html", "Largest Files", ".e24", "Other", filepath.Join("/mnt", "/data/f05.e05"), "width:7.7%"} {
+		assert.Contains(t, string(html), s)
+	}
+	assert.NotContains(t, string(html), "f04.e04")
+	assert.NotContains(t, string(html), "ZgotmplZ")
+
+	// 没有数据库时只输出摘要
+	assert.NoError(t, writeHTMLReport(ctx, path, summary, nil))
+	html, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(html), "Job_html")
+	assert.NotContains(t, string(html), "Largest Files")
+}
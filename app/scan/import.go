@@ -0,0 +1,118 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"terrasync/changelist"
+	"terrasync/log"
+	"terrasync/object"
+	"terrasync/security"
+	"time"
+)
+
+// ImportConfig describes a listing produced by the storage platform, such as an
+// S3 inventory report, loaded as a scan job
+type ImportConfig struct {
+	Source      changelist.Source // 列举存储的所有条目的清单
+	JobDir      string            // 导入的任务目录，不能已存在
+	JobID       string
+	AppVersion  string
+	CmdLine     string
+	Path        string // 清单列举的存储路径，如s3://bucket/share，之后以该路径增量扫描
+	DbType      string
+	DBBatchSize int
+	Match       []string
+	Exclude     []string
+}
+
+// ImportListing saves the entries of a platform listing in the database of a new
+// scan job with its summary, as if the storage had been walked by a full scan.
+// The job is the baseline of later incremental scans of config.Path and its
+// reports are produced like those of a scan, without listing the storage.
+// Deleted entries of the listing are ignored.
+func ImportListing(ctx context.Context, config ImportConfig) (*JobSummary, error) {
+	matchConditions, err := NewConditionFilter(config.Match)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create match conditions: %w", err)
+	}
+	excludeConditions, err := NewConditionFilter(config.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exclude conditions: %w", err)
+	}
+	if _, err := os.Stat(config.JobDir); err == nil {
+		return nil, fmt.Errorf("job directory %s already exists", config.JobDir)
+	}
+	dbInstance, err := InitDatabase(ctx, config.DbType, config.JobDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer (*dbInstance).Close()
+
+	batchSize := config.DBBatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	batch := make([]object.FileInfo, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := (*dbInstance).SaveEntries(ctx, batch, "")
+		batch = batch[:0]
+		return err
+	}
+
+	start := time.Now()
+	stats := NewStats()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	entries, errc := ChangeListing(ctx, config.Source, nil, ListOptions{Match: matchConditions, Exclude: excludeConditions})
+	var saveErr error
+	for fileInfo := range entries {
+		if saveErr != nil {
+			continue
+		}
+		stats.Update(fileInfo)
+		batch = append(batch, fileInfo)
+		if len(batch) >= batchSize {
+			// 保存失败时停止读取清单
+			if saveErr = flush(); saveErr != nil {
+				cancel()
+			}
+		}
+	}
+	if saveErr == nil {
+		saveErr = flush()
+	}
+	if saveErr != nil {
+		return nil, fmt.Errorf("failed to save entries: %w", saveErr)
+	}
+	if err := <-errc; err != nil {
+		return nil, fmt.Errorf("failed to read listing: %w", err)
+	}
+	end := time.Now()
+	log.Infof("Imported %d files of %s into job %s in %v", stats.GetFileCount(), config.Path, config.JobID, end.Sub(start))
+
+	summary := &JobSummary{
+		JobID:      config.JobID,
+		AppVersion: config.AppVersion,
+		CmdLine:    config.CmdLine,
+		Path:       config.Path,
+		Match:      config.Match,
+		Exclude:    config.Exclude,
+		DbType:     config.DbType,
+		CryptoMode: security.Mode(),
+		StartTime:  start.UTC(),
+		EndTime:    end.UTC(),
+		FileTypes:  fileTypeCount(ctx, dbInstance),
+		Extensions: extensionBreakdown(ctx, dbInstance),
+		Stats:      stats.Snapshot(),
+		// 没有访问存储
+		ReadOnly: true,
+	}
+	if err := SaveJobSummary(config.JobDir, *summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
@@ -0,0 +1,61 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"terrasync/changelist"
+	"terrasync/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestImportListing 测试把平台的列举清单导入为扫描任务
+func TestImportListing(t *testing.T) {
+	ctx := context.Background()
+	source := &fakeChangeList{changes: []changelist.Change{
+		{Type: changelist.Listed, Key: "/a/1.txt", Size: 10, Perm: 0644},
+		{Type: changelist.Listed, Key: "/a/2.log", Size: 20, Perm: 0644},
+		{Type: changelist.Listed, Key: "/b.txt", Size: 300, Perm: 0644},
+		{Type: changelist.Deleted, Key: "/gone.txt"},
+	}}
+	jobDir := filepath.Join(t.TempDir(), "Job_inventory_scan")
+	config := ImportConfig{
+		Source:  source,
+		JobDir:  jobDir,
+		JobID:   "Job_inventory_scan",
+		Path:    "s3://bucket/share",
+		DbType:  "sqlite",
+		Exclude: []string{"name like '%.log'"},
+	}
+	summary, err := ImportListing(ctx, config)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), summary.Stats.FileCount)
+	assert.Equal(t, int64(310), summary.Stats.TotalSize)
+	assert.True(t, summary.ReadOnly)
+
+	// 导入的任务可以像扫描一样重新生成报告及作为增量扫描的基线
+	saved, err := LoadJobSummary(jobDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://bucket/share", saved.Path)
+	dbInstance, err := NewDB(saved.DbType, jobDir)
+	assert.NoError(t, err)
+	var keys []string
+	assert.NoError(t, (*dbInstance).ListEntries(ctx, "", func(entry db.FileInfoData) error {
+		keys = append(keys, entry.Key)
+		return nil
+	}))
+	assert.ElementsMatch(t, []string{"/a/1.txt", "/b.txt"}, keys)
+	assert.NoError(t, (*dbInstance).Close())
+
+	// 任务目录已存在
+	_, err = ImportListing(ctx, config)
+	assert.ErrorContains(t, err, "already exists")
+	// 读取清单失败
+	config.JobDir = filepath.Join(t.TempDir(), "x")
+	config.Source = &fakeChangeList{err: errors.New("truncated data file")}
+	_, err = ImportListing(ctx, config)
+	assert.ErrorContains(t, err, "truncated data file")
+}
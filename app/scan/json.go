@@ -0,0 +1,155 @@
+package scan
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"terrasync/db"
+	"terrasync/object"
+	"time"
+)
+
+// JSONReportName is the file name of the NDJSON report in the job directory
+const JSONReportName = "report.ndjson"
+
+// Console output modes of the scan summary
+const (
+	OutputText = "text"
+	OutputJSON = "json" // 控制台只输出一个JSON摘要对象，过程信息写入日志
+)
+
+// ParseOutput checks the console output mode, empty selects text
+func ParseOutput(value string) (string, error) {
+	switch value {
+	case "", OutputText:
+		return OutputText, nil
+	case OutputJSON:
+		return OutputJSON, nil
+	}
+	return "", fmt.Errorf("invalid output %q, expect text or json", value)
+}
+
+// jsonEntry is the NDJSON record of one scanned entry
+type jsonEntry struct {
+	Type  string    `json:"type"` // file、dir或symlink
+	Path  string    `json:"path"`
+	Ext   string    `json:"ext,omitempty"`
+	Size  int64     `json:"size"`
+	MTime time.Time `json:"mtime"`
+	CTime time.Time `json:"ctime"`
+	ATime time.Time `json:"atime"`
+	Perm  string    `json:"perm"`
+}
+
+// jsonSummary is the last record of the NDJSON report and the object printed
+// by --output json, the job summary with the status and the report paths
+type jsonSummary struct {
+	Type       string `json:"type"` // summary
+	Status     string `json:"status"`
+	ElapsedSec int64  `json:"elapsed_sec"`
+	LogPath    string `json:"log_path,omitempty"`
+	CsvPath    string `json:"csv_report,omitempty"`
+	HtmlPath   string `json:"html_report,omitempty"`
+	JSONPath   string `json:"json_report,omitempty"`
+	Manifest   string `json:"manifest,omitempty"`
+	*JobSummary
+}
+
+// newJSONSummary returns the summary record of a job
+func newJSONSummary(summary *JobSummary) jsonSummary {
+	status := "succeeded"
+	if summary.Interrupted {
+		status = "interrupted"
+	} else if summary.Error != "" {
+		status = "failed"
+	}
+	return jsonSummary{
+		Type:       "summary",
+		Status:     status,
+		ElapsedSec: int64(summary.EndTime.Sub(summary.StartTime).Seconds()),
+		JobSummary: summary,
+	}
+}
+
+// jsonReport writes the scanned entries as NDJSON, one record per line
+type jsonReport struct {
+	file   *os.File
+	writer *bufio.Writer
+	enc    *json.Encoder
+}
+
+// newJSONReport creates the NDJSON report at path
+func newJSONReport(path string) (*jsonReport, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JSON report: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	return &jsonReport{file: f, writer: w, enc: json.NewEncoder(w)}, nil
+}
+
+// Write appends one scanned entry, path is the full path shown to the user
+func (r *jsonReport) Write(path string, fileInfo object.FileInfo) error {
+	var ext string
+	if !fileInfo.IsDir() {
+		ext = filepath.Ext(fileInfo.Key())
+	}
+	return r.enc.Encode(jsonEntry{
+		Type:  entryType(fileInfo.IsDir(), fileInfo.IsSymlink()),
+		Path:  path,
+		Ext:   ext,
+		Size:  fileInfo.Size(),
+		MTime: fileInfo.MTime().UTC(),
+		CTime: fileInfo.CTime().UTC(),
+		ATime: fileInfo.ATime().UTC(),
+		Perm:  fileInfo.Perm().String(),
+	})
+}
+
+// WriteEntry appends one entry saved in the job database
+func (r *jsonReport) WriteEntry(path string, entry db.FileInfoData) error {
+	return r.enc.Encode(jsonEntry{
+		Type:  entryType(entry.IsDir, entry.IsSymlink),
+		Path:  path,
+		Ext:   entry.Ext,
+		Size:  entry.Size,
+		MTime: entry.MTime.UTC(),
+		CTime: entry.CTime.UTC(),
+		ATime: entry.ATime.UTC(),
+		Perm:  os.FileMode(entry.Perm).String(),
+	})
+}
+
+// WriteSummary appends the final summary record
+func (r *jsonReport) WriteSummary(summary *JobSummary) error {
+	return r.enc.Encode(newJSONSummary(summary))
+}
+
+// Close flushes and closes the report
+func (r *jsonReport) Close() error {
+	err := r.writer.Flush()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// writeJSONSummary prints the summary of a job as one indented JSON object
+// for --output json, with the paths of the reports written by the job
+func writeJSONSummary(w io.Writer, reportConfig ReportConfig, summary *JobSummary) error {
+	record := newJSONSummary(summary)
+	record.LogPath = reportConfig.LogPath
+	record.CsvPath = reportConfig.CsvPath
+	record.HtmlPath = reportConfig.HtmlPath
+	record.JSONPath = reportConfig.JSONPath
+	record.Manifest = reportConfig.Manifest
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(record); err != nil {
+		return fmt.Errorf("failed to write JSON summary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package scan
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseOutput 测试解析控制台输出模式
+func TestParseOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"默认文本", "", OutputText, false},
+		{"文本", "text", OutputText, false},
+		{"JSON", "json", OutputJSON, false},
+		{"无效", "yaml", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOutput(tt.value)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestWriteJSONSummary 测试--output json输出的摘要对象包含统计及报告路径
+func TestWriteJSONSummary(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	assert.NoError(t, writeJSONSummary(&buf, ReportConfig{JSONPath: "/jobs/Job_ci_scan/report.ndjson"}, &JobSummary{
+		JobID:     "Job_ci_scan",
+		Path:      "/data",
+		StartTime: start,
+		EndTime:   start.Add(90 * time.Second),
+		Stats:     StatsSnapshot{FileCount: 3, DirCount: 1, TotalSize: 4096},
+	}))
+
+	var got struct {
+		Type       string        `json:"type"`
+		Status     string        `json:"status"`
+		ElapsedSec int64         `json:"elapsed_sec"`
+		JSONPath   string        `json:"json_report"`
+		CsvPath    *string       `json:"csv_report"`
+		JobID      string        `json:"job_id"`
+		Stats      StatsSnapshot `json:"stats"`
+	}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, "summary", got.Type)
+	assert.Equal(t, "succeeded", got.Status)
+	assert.Equal(t, int64(90), got.ElapsedSec)
+	assert.Equal(t, "/jobs/Job_ci_scan/report.ndjson", got.JSONPath)
+	assert.Nil(t, got.CsvPath)
+	assert.Equal(t, "Job_ci_scan", got.JobID)
+	assert.Equal(t, int64(4096), got.Stats.TotalSize)
+}
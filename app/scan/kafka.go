@@ -1,7 +1,9 @@
 package scan
 
 import (
+	"context"
 	"terrasync/log"
+	"terrasync/security"
 
 	"terrasync/object"
 	"time"
@@ -15,7 +17,7 @@ type KafkaProducer struct {
 }
 
 // NewKafkaProducer 创建一个新的Kafka生产者
-func NewKafkaProducer(brokers []string) (*KafkaProducer, error) {
+func NewKafkaProducer(brokers []string, useTLS bool) (*KafkaProducer, error) {
 	config := sarama.NewConfig()
 	config.Producer.Return.Successes = true
 	config.Producer.RequiredAcks = sarama.WaitForAll
@@ -24,6 +26,11 @@ func NewKafkaProducer(brokers []string) (*KafkaProducer, error) {
 	config.Net.DialTimeout = 2 * time.Second
 	config.Net.ReadTimeout = 2 * time.Second
 	config.Net.WriteTimeout = 2 * time.Second
+	// TLS配置遵循当前加密模式(FIPS模式下限制协议版本和密码套件)
+	if useTLS {
+		config.Net.TLS.Enable = true
+		config.Net.TLS.Config = security.TLSConfig()
+	}
 
 	producer, err := sarama.NewSyncProducer(brokers, config)
 	if err != nil {
@@ -33,24 +40,32 @@ func NewKafkaProducer(brokers []string) (*KafkaProducer, error) {
 	return &KafkaProducer{producer: producer}, nil
 }
 
-// SendMessage 发送消息到Kafka
-func (kp *KafkaProducer) SendMessage(topic string, fileInfo object.FileInfo) error {
-	// 创建消息
-	msg := &sarama.ProducerMessage{
-		Topic: topic,
-		Value: sarama.StringEncoder(fileInfo.Key()),
+// SendMessage 发送消息到Kafka，消息内容为文件的key。ctx取消后不再发送
+func (kp *KafkaProducer) SendMessage(ctx context.Context, topic string, fileInfo object.FileInfo) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-
-	// 发送消息
-	_, _, err := kp.producer.SendMessage(msg)
-	if err != nil {
+	if err := kp.send(topic, sarama.StringEncoder(fileInfo.Key())); err != nil {
 		return err
 	}
-
 	log.Infof("Successfully sent message to Kafka topic %s: %s", topic, fileInfo.Key())
 	return nil
 }
 
+// Send 发送JSON等任意内容的消息到Kafka
+func (kp *KafkaProducer) Send(topic string, value []byte) error {
+	return kp.send(topic, sarama.ByteEncoder(value))
+}
+
+func (kp *KafkaProducer) send(topic string, value sarama.Encoder) error {
+	msg := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: value,
+	}
+	_, _, err := kp.producer.SendMessage(msg)
+	return err
+}
+
 // Close 关闭Kafka生产者
 func (kp *KafkaProducer) Close() error {
 	return kp.producer.Close()
@@ -0,0 +1,143 @@
+package scan
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"terrasync/i18n"
+	"terrasync/object"
+	"time"
+)
+
+// lifecycleTiers are the idle times checked for lifecycle suggestions, each with
+// the S3 storage class its data is suggested to transition to
+var lifecycleTiers = []struct {
+	label string
+	days  int
+	class string
+}{
+	{"90d", 90, "STANDARD_IA"},
+	{"180d", 180, "GLACIER_IR"},
+	{"1y", 365, "GLACIER"},
+	{"3y", 3 * 365, "DEEP_ARCHIVE"},
+}
+
+// minLifecycleShare is the share of bytes a tier needs before a rule is suggested
+const minLifecycleShare = 0.1
+
+// idleCounter counts the files and bytes not used for at least each lifecycle tier.
+// A file is used when it is read or modified, so the later of atime and mtime
+// counts: filesystems mounted with noatime/relatime keep atime behind mtime.
+type idleCounter struct {
+	now   time.Time
+	files []int64
+	bytes []int64
+}
+
+func newIdleCounter(now time.Time) *idleCounter {
+	return &idleCounter{
+		now:   now,
+		files: make([]int64, len(lifecycleTiers)),
+		bytes: make([]int64, len(lifecycleTiers)),
+	}
+}
+
+// add counts a regular file in every tier it has been idle for
+func (c *idleCounter) add(fileInfo object.FileInfo) {
+	lastUsed := fileInfo.MTime()
+	if fileInfo.ATime().After(lastUsed) {
+		lastUsed = fileInfo.ATime()
+	}
+	idle := c.now.Sub(lastUsed)
+	for i, tier := range lifecycleTiers {
+		if idle < time.Duration(tier.days)*24*time.Hour {
+			break
+		}
+		atomic.AddInt64(&c.files[i], 1)
+		atomic.AddInt64(&c.bytes[i], fileInfo.Size())
+	}
+}
+
+// LifecycleSuggestion is a candidate lifecycle transition derived from the idle statistics
+type LifecycleSuggestion struct {
+	Idle         string  // 未使用时长，如3y
+	Days         int     // 转换前的天数
+	StorageClass string  // 建议转换到的存储类型
+	Files        int64   // 超过该时长未使用的文件数
+	Bytes        int64   // 超过该时长未使用的字节数
+	Share        float64 // 占总字节数的比例
+}
+
+// LifecycleSuggestions returns a transition for every tier holding at least
+// minLifecycleShare of the scanned bytes, ordered by idle time
+func (s *Stats) LifecycleSuggestions() []LifecycleSuggestion {
+	total := s.GetTotalSize()
+	if total == 0 {
+		return nil
+	}
+	var suggestions []LifecycleSuggestion
+	for i, tier := range lifecycleTiers {
+		bytes := atomic.LoadInt64(&s.idle.bytes[i])
+		share := float64(bytes) / float64(total)
+		if share < minLifecycleShare {
+			continue
+		}
+		suggestions = append(suggestions, LifecycleSuggestion{
+			Idle:         tier.label,
+			Days:         tier.days,
+			StorageClass: tier.class,
+			Files:        atomic.LoadInt64(&s.idle.files[i]),
+			Bytes:        bytes,
+			Share:        share,
+		})
+	}
+	return suggestions
+}
+
+// String describes the suggestion, e.g. "42% of bytes untouched >3y → transition to DEEP_ARCHIVE"
+func (l LifecycleSuggestion) String() string {
+	return i18n.Sprintf("%.0f%% of bytes untouched >%s (%d files, %s) → transition to %s",
+		l.Share*100, l.Idle, l.Files, FormatFileSize(l.Bytes), l.StorageClass)
+}
+
+// LifecycleJSON returns the suggestions as an S3 bucket lifecycle configuration,
+// ready for aws s3api put-bucket-lifecycle-configuration.
+// S3 counts the days from the upload of an object, so for migrated data the rule
+// is best combined with the temperature tags set by migrate --tag-temperature.
+func LifecycleJSON(suggestions []LifecycleSuggestion) (string, error) {
+	type transition struct {
+		Days         int    `json:"Days"`
+		StorageClass string `json:"StorageClass"`
+	}
+	type rule struct {
+		ID          string            `json:"ID"`
+		Status      string            `json:"Status"`
+		Filter      map[string]string `json:"Filter"`
+		Transitions []transition      `json:"Transitions"`
+	}
+	r := rule{ID: "terrasync-suggested-tiering", Status: "Enabled", Filter: map[string]string{"Prefix": ""}}
+	for _, s := range suggestions {
+		r.Transitions = append(r.Transitions, transition{Days: s.Days, StorageClass: s.StorageClass})
+	}
+	data, err := json.MarshalIndent(map[string][]rule{"Rules": {r}}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// printLifecycleSuggestions prints the suggested transitions and their lifecycle configuration
+func (s *Stats) printLifecycleSuggestions() {
+	suggestions := s.LifecycleSuggestions()
+	if len(suggestions) == 0 {
+		return
+	}
+	console.Section("Lifecycle Suggestions")
+	for _, suggestion := range suggestions {
+		console.Printf("  %s\n", suggestion)
+	}
+	config, err := LifecycleJSON(suggestions)
+	if err != nil {
+		return
+	}
+	console.Printf("\n%s\n", config)
+}
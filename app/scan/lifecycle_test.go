@@ -0,0 +1,74 @@
+package scan
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLifecycleSuggestions 测试按未使用时长建议生命周期规则
+func TestLifecycleSuggestions(t *testing.T) {
+	storage, err := object.CreateStorage("mem://lifecycle-test")
+	assert.NoError(t, err)
+	setter := storage.(object.MetadataSetter)
+	now := time.Now()
+	day := 24 * time.Hour
+
+	files := []struct {
+		key   string
+		size  int
+		atime time.Duration // 距现在的时间
+		mtime time.Duration
+	}{
+		{key: "/hot.dat", size: 50, atime: day, mtime: 500 * day},
+		{key: "/warm.dat", size: 8, atime: 100 * day, mtime: 120 * day},
+		{key: "/old.dat", size: 42, atime: 4 * 365 * day, mtime: 5 * 365 * day},
+	}
+	stats := NewStats()
+	for _, f := range files {
+		assert.NoError(t, storage.Put(f.key, strings.NewReader(strings.Repeat("x", f.size))))
+		assert.NoError(t, setter.SetMetadata(f.key, object.Metadata{Perm: 0644, ATime: now.Add(-f.atime), MTime: now.Add(-f.mtime)}))
+		fi, err := storage.Head(f.key)
+		assert.NoError(t, err)
+		stats.Update(fi)
+	}
+
+	// 90d: 50%，180d/1y/3y: 42%
+	suggestions := stats.LifecycleSuggestions()
+	assert.Len(t, suggestions, 4)
+	assert.Equal(t, int64(2), suggestions[0].Files)
+	assert.Equal(t, "STANDARD_IA", suggestions[0].StorageClass)
+	last := suggestions[3]
+	assert.Equal(t, int64(42), last.Bytes)
+	assert.Equal(t, "DEEP_ARCHIVE", last.StorageClass)
+	assert.Contains(t, last.String(), "42% of bytes untouched >3y")
+
+	config, err := LifecycleJSON(suggestions)
+	assert.NoError(t, err)
+	var decoded struct {
+		Rules []struct {
+			Status      string
+			Transitions []struct {
+				Days         int
+				StorageClass string
+			}
+		}
+	}
+	assert.NoError(t, json.Unmarshal([]byte(config), &decoded))
+	assert.Len(t, decoded.Rules, 1)
+	assert.Equal(t, "Enabled", decoded.Rules[0].Status)
+	assert.Equal(t, 90, decoded.Rules[0].Transitions[0].Days)
+	assert.Equal(t, 3*365, decoded.Rules[0].Transitions[3].Days)
+
+	// 重新生成报告时从摘要还原
+	assert.Equal(t, suggestions, stats.Snapshot().Stats().LifecycleSuggestions())
+
+	// 旧版本的摘要没有未使用统计
+	assert.Empty(t, StatsSnapshot{TotalSize: 100}.Stats().LifecycleSuggestions())
+	assert.Empty(t, NewStats().LifecycleSuggestions())
+}
@@ -0,0 +1,100 @@
+package scan
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"terrasync/security"
+)
+
+// ManifestName is the file in the job directory holding the SHA-256 checksums of
+// the reports in sha256sum format, so it can also be checked with `sha256sum -c`
+const ManifestName = "manifest.sha256"
+
+// manifestFiles are the job artifacts covered by the manifest when they exist
+var manifestFiles = []string{JobSummaryName, CSVReportName, SummaryCSVName, ExtensionsCSVName, HTMLReportName, JSONReportName}
+
+// SignReports writes the manifest of the reports in jobDir and signs it with
+// key, so the sign-off artifacts handed to customers are tamper-evident
+func SignReports(jobDir string, key *security.SigningKey) (string, error) {
+	var manifest strings.Builder
+	for _, name := range manifestFiles {
+		sum, err := fileSHA256(filepath.Join(jobDir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to checksum %s: %w", name, err)
+		}
+		fmt.Fprintf(&manifest, "%s  %s\n", sum, name)
+	}
+
+	path := filepath.Join(jobDir, ManifestName)
+	if err := os.WriteFile(path, []byte(manifest.String()), 0644); err != nil {
+		return "", fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if _, err := key.SignFile(path); err != nil {
+		return "", fmt.Errorf("failed to sign manifest: %w", err)
+	}
+	return path, nil
+}
+
+// VerifyReports checks the signature of the manifest in dir and the checksums of
+// the files it lists, returning the names of the verified files
+func VerifyReports(dir string, key *security.VerifyKey) ([]string, error) {
+	path := filepath.Join(dir, ManifestName)
+	if err := key.VerifyFile(path); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	defer f.Close()
+
+	var verified, modified []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		want, name, ok := strings.Cut(scanner.Text(), "  ")
+		// 清单只列出任务目录下的文件
+		if !ok || name != filepath.Base(name) {
+			return nil, fmt.Errorf("invalid manifest line %q", scanner.Text())
+		}
+		got, err := fileSHA256(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to checksum %s: %w", name, err)
+		}
+		if got != want {
+			modified = append(modified, name)
+			continue
+		}
+		verified = append(verified, name)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if len(modified) > 0 {
+		return verified, fmt.Errorf("checksum mismatch, modified after signing: %s", strings.Join(modified, ", "))
+	}
+	return verified, nil
+}
+
+// fileSHA256 returns the hex SHA-256 of the file at path
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
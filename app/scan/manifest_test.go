@@ -0,0 +1,57 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"terrasync/security"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSignReports 测试报告清单的签名和校验，签名后修改报告或清单会被发现
+func TestSignReports(t *testing.T) {
+	keyDir := t.TempDir()
+	pub, err := security.GenerateSigningKey(filepath.Join(keyDir, "op.key"), filepath.Join(keyDir, "op.pub"))
+	assert.NoError(t, err)
+	key, err := security.LoadSigningKey(filepath.Join(keyDir, "op.key"))
+	assert.NoError(t, err)
+	assert.Equal(t, pub.ID, key.ID)
+
+	jobDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(jobDir, JobSummaryName), []byte(`{"JobID":"x"}`), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(jobDir, HTMLReportName), []byte("<html></html>"), 0644))
+	manifest, err := SignReports(jobDir, key)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(jobDir, ManifestName), manifest)
+
+	verifyKey, err := security.LoadVerifyKey(filepath.Join(keyDir, "op.pub"))
+	assert.NoError(t, err)
+	verified, err := VerifyReports(jobDir, verifyKey)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{JobSummaryName, HTMLReportName}, verified)
+
+	// 修改报告
+	assert.NoError(t, os.WriteFile(filepath.Join(jobDir, HTMLReportName), []byte("<html>edited</html>"), 0644))
+	verified, err = VerifyReports(jobDir, verifyKey)
+	assert.ErrorContains(t, err, HTMLReportName)
+	assert.Equal(t, []string{JobSummaryName}, verified)
+
+	// 修改清单
+	assert.NoError(t, os.WriteFile(manifest, []byte("0000  "+HTMLReportName+"\n"), 0644))
+	_, err = VerifyReports(jobDir, verifyKey)
+	assert.ErrorContains(t, err, "invalid signature")
+
+	// 其他密钥的公钥
+	otherDir := t.TempDir()
+	other, err := security.GenerateSigningKey(filepath.Join(otherDir, "other.key"), filepath.Join(otherDir, "other.pub"))
+	assert.NoError(t, err)
+	_, err = SignReports(jobDir, key)
+	assert.NoError(t, err)
+	_, err = VerifyReports(jobDir, other)
+	assert.ErrorContains(t, err, key.ID)
+
+	// 私钥文件不能作为公钥使用
+	_, err = security.LoadVerifyKey(filepath.Join(keyDir, "op.key"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,241 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"terrasync/db"
+	"terrasync/log"
+	"terrasync/object"
+	"time"
+)
+
+// MergeConfig describes the partition jobs of a distributed scan combined into one job
+type MergeConfig struct {
+	JobDirs     []string // 各节点扫描分区的任务目录
+	JobDir      string   // 合并后的任务目录，不能已存在
+	JobID       string
+	AppVersion  string
+	CmdLine     string
+	DBBatchSize int
+}
+
+// MergePartitions combines the partition jobs of a distributed scan into one
+// job with a single database and summary, so its reports are produced like
+// those of a scan run on one host. All partitions of the scan must be present.
+func MergePartitions(ctx context.Context, config MergeConfig) (*JobSummary, error) {
+	summaries := make([]*JobSummary, len(config.JobDirs))
+	jobDirs := make(map[*JobSummary]string, len(config.JobDirs))
+	for i, jobDir := range config.JobDirs {
+		summary, err := LoadJobSummary(jobDir)
+		if err != nil {
+			return nil, err
+		}
+		summaries[i] = summary
+		jobDirs[summary] = jobDir
+	}
+	if err := checkPartitions(summaries); err != nil {
+		return nil, err
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Partition.Index < summaries[j].Partition.Index })
+
+	if _, err := os.Stat(config.JobDir); err == nil {
+		return nil, fmt.Errorf("job directory %s already exists", config.JobDir)
+	}
+	first := summaries[0]
+	dbInstance, err := InitDatabase(ctx, first.DbType, config.JobDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer (*dbInstance).Close()
+
+	merged := &JobSummary{
+		JobID:      config.JobID,
+		AppVersion: config.AppVersion,
+		CmdLine:    config.CmdLine,
+		Path:       first.Path,
+		Match:      first.Match,
+		Exclude:    first.Exclude,
+		Depth:      first.Depth,
+		DbType:     first.DbType,
+		CryptoMode: first.CryptoMode,
+		StartTime:  first.StartTime,
+		EndTime:    first.EndTime,
+		ReadOnly:   true,
+	}
+	var failed []string
+	for _, summary := range summaries {
+		if summary.Path != first.Path {
+			log.Warnf("Partition %s of job %s scanned %s, partition %s scanned %s", summary.Partition, summary.JobID, summary.Path, first.Partition, first.Path)
+		}
+		if summary.StartTime.Before(merged.StartTime) {
+			merged.StartTime = summary.StartTime
+		}
+		if summary.EndTime.After(merged.EndTime) {
+			merged.EndTime = summary.EndTime
+		}
+		if summary.Error != "" {
+			failed = append(failed, fmt.Sprintf("partition %s: %s", summary.Partition, summary.Error))
+		}
+		// 所有分区都以只读方式扫描时合并任务才是只读的
+		merged.ReadOnly = merged.ReadOnly && summary.ReadOnly
+		merged.Stats.Merge(summary.Stats)
+		merged.Partitions = append(merged.Partitions, summary.JobID)
+
+		if err := copyEntries(ctx, jobDirs[summary], summary, dbInstance, config.DBBatchSize); err != nil {
+			return nil, fmt.Errorf("failed to merge job %s: %w", summary.JobID, err)
+		}
+	}
+	merged.Error = strings.Join(failed, "; ")
+	merged.FileTypes = fileTypeCount(ctx, dbInstance)
+	merged.Extensions = extensionBreakdown(ctx, dbInstance)
+
+	if err := SaveJobSummary(config.JobDir, *merged); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// checkPartitions verifies that the summaries are the complete set of partitions of one scan
+func checkPartitions(summaries []*JobSummary) error {
+	if len(summaries) == 0 {
+		return fmt.Errorf("no partition jobs to merge")
+	}
+	count := 0
+	seen := make(map[int]string)
+	for _, summary := range summaries {
+		p := summary.Partition
+		if p == nil {
+			return fmt.Errorf("job %s is not a partition of a distributed scan", summary.JobID)
+		}
+		if count == 0 {
+			count = p.Count
+		}
+		if p.Count != count {
+			return fmt.Errorf("job %s is partition %s, other jobs are partitions of %d", summary.JobID, p, count)
+		}
+		if other, ok := seen[p.Index]; ok {
+			return fmt.Errorf("jobs %s and %s both scanned partition %s", other, summary.JobID, p)
+		}
+		if summary.DbType != summaries[0].DbType {
+			return fmt.Errorf("job %s uses database %s, job %s uses %s", summary.JobID, summary.DbType, summaries[0].JobID, summaries[0].DbType)
+		}
+		seen[p.Index] = summary.JobID
+	}
+	var missing []string
+	for i := 1; i <= count; i++ {
+		if _, ok := seen[i]; !ok {
+			missing = append(missing, fmt.Sprintf("%d/%d", i, count))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("incomplete distributed scan, missing partitions %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// copyEntries copies the entries of a partition job database into dst in batches
+func copyEntries(ctx context.Context, jobDir string, summary *JobSummary, dst *db.DB, batchSize int) error {
+	src, err := NewDB(summary.DbType, jobDir)
+	if err != nil {
+		return fmt.Errorf("failed to open job database: %w", err)
+	}
+	defer (*src).Close()
+
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	batch := make([]object.FileInfo, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := (*dst).SaveEntries(ctx, batch, "")
+		batch = batch[:0]
+		return err
+	}
+	start := time.Now()
+	var copied int64
+	err = (*src).ListEntries(ctx, "", func(entry db.FileInfoData) error {
+		batch = append(batch, &snapshotEntry{data: entry})
+		copied++
+		if len(batch) < batchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return err
+	}
+	log.Infof("Merged %d entries of partition %s from job %s in %v", copied, summary.Partition, summary.JobID, time.Since(start))
+	return nil
+}
+
+// Merge adds the statistics of a disjoint part of the tree, such as a partition
+// of a distributed scan
+func (snap *StatsSnapshot) Merge(other StatsSnapshot) {
+	snap.FileCount += other.FileCount
+	snap.DirCount += other.DirCount
+	snap.TotalSize += other.TotalSize
+	snap.TotalSymlink += other.TotalSymlink
+	snap.TotalRegularFile += other.TotalRegularFile
+	snap.TotalNameLength += other.TotalNameLength
+	snap.MaxNameLength = max(snap.MaxNameLength, other.MaxNameLength)
+	snap.TotalDirDepth += other.TotalDirDepth
+	snap.MaxDirDepth = max(snap.MaxDirDepth, other.MaxDirDepth)
+	snap.MaxDirEntries = max(snap.MaxDirEntries, other.MaxDirEntries)
+	snap.HugeDirThreshold = max(snap.HugeDirThreshold, other.HugeDirThreshold)
+	snap.SkippedCount += other.SkippedCount
+	snap.StubCount += other.StubCount
+	snap.StubBytes += other.StubBytes
+	snap.EmptyDirCount += other.EmptyDirCount
+	snap.EmptyFileCount += other.EmptyFileCount
+	snap.TopN = max(snap.TopN, other.TopN)
+	snap.TopFiles = mergeTopEntries(snap.TopN, entryBytes, snap.TopFiles, other.TopFiles)
+	snap.TopDirsByFiles = mergeTopEntries(snap.TopN, entryFiles, snap.TopDirsByFiles, other.TopDirsByFiles)
+	snap.TopDirsByBytes = mergeTopEntries(snap.TopN, entryBytes, snap.TopDirsByBytes, other.TopDirsByBytes)
+
+	// 每个分区都列举了根目录，根目录只计一次
+	for _, dir := range other.HugeDirs {
+		if dir == "/" && slices.Contains(snap.HugeDirs, dir) {
+			other.HugeDirCount--
+			continue
+		}
+		if len(snap.HugeDirs) < maxHugeDirsRecorded {
+			snap.HugeDirs = append(snap.HugeDirs, dir)
+		}
+	}
+	snap.HugeDirCount += other.HugeDirCount
+
+	for dest, count := range other.Routes {
+		if snap.Routes == nil {
+			snap.Routes = make(map[string]int64)
+		}
+		snap.Routes[dest] += count
+	}
+	if other.Compression != nil {
+		if snap.Compression == nil {
+			snap.Compression = &CompressionStats{SampleRate: other.Compression.SampleRate}
+		}
+		snap.Compression.merge(other.Compression)
+	}
+	snap.IdleFiles = addInt64s(snap.IdleFiles, other.IdleFiles)
+	snap.IdleBytes = addInt64s(snap.IdleBytes, other.IdleBytes)
+}
+
+// addInt64s adds b to a element by element, growing a as needed
+func addInt64s(a, b []int64) []int64 {
+	for len(a) < len(b) {
+		a = append(a, 0)
+	}
+	for i, v := range b {
+		a[i] += v
+	}
+	return a
+}
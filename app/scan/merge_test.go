@@ -0,0 +1,62 @@
+package scan
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"terrasync/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// savePartitionJob 保存一个分布式扫描的分区任务
+func savePartitionJob(t *testing.T, name string, sizes []int, p *Partition) string {
+	jobDir := saveRollupJob(t, name, sizes, "")
+	summary, err := LoadJobSummary(jobDir)
+	assert.NoError(t, err)
+	summary.Partition = p
+	assert.NoError(t, SaveJobSummary(jobDir, *summary))
+	return jobDir
+}
+
+// TestMergePartitions 测试合并分布式扫描的各分区任务
+func TestMergePartitions(t *testing.T) {
+	ctx := context.Background()
+	p1 := savePartitionJob(t, "p1", []int{10, 5000}, &Partition{Index: 1, Count: 2})
+	p2 := savePartitionJob(t, "p2", []int{100000}, &Partition{Index: 2, Count: 2})
+
+	jobDir := filepath.Join(t.TempDir(), "Job_merged_scan")
+	merged, err := MergePartitions(ctx, MergeConfig{JobDirs: []string{p2, p1}, JobDir: jobDir, JobID: "Job_merged_scan"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Job_p1_scan", "Job_p2_scan"}, merged.Partitions)
+	assert.Equal(t, int64(3), merged.Stats.FileCount)
+	assert.Equal(t, int64(105010), merged.Stats.TotalSize)
+
+	// 合并后的任务可以像单机扫描一样重新生成报告
+	summary, err := LoadJobSummary(jobDir)
+	assert.NoError(t, err)
+	assert.Equal(t, merged.Stats, summary.Stats)
+	dbInstance, err := NewDB(summary.DbType, jobDir)
+	assert.NoError(t, err)
+	var keys []string
+	assert.NoError(t, (*dbInstance).ListEntries(ctx, "", func(entry db.FileInfoData) error {
+		keys = append(keys, entry.Key)
+		return nil
+	}))
+	assert.ElementsMatch(t, []string{"/f0", "/f1", "/f0"}, keys)
+	assert.NoError(t, (*dbInstance).Close())
+
+	// 合并后的任务目录已存在
+	_, err = MergePartitions(ctx, MergeConfig{JobDirs: []string{p1, p2}, JobDir: jobDir})
+	assert.ErrorContains(t, err, "already exists")
+	// 缺少分区
+	_, err = MergePartitions(ctx, MergeConfig{JobDirs: []string{p1}, JobDir: filepath.Join(t.TempDir(), "x")})
+	assert.ErrorContains(t, err, "missing partitions 2/2")
+	// 重复的分区
+	_, err = MergePartitions(ctx, MergeConfig{JobDirs: []string{p1, p1}, JobDir: filepath.Join(t.TempDir(), "x")})
+	assert.ErrorContains(t, err, "both scanned partition 1/2")
+	// 不是分区任务
+	_, err = MergePartitions(ctx, MergeConfig{JobDirs: []string{saveRollupJob(t, "full", []int{1}, "")}, JobDir: filepath.Join(t.TempDir(), "x")})
+	assert.ErrorContains(t, err, "not a partition")
+}
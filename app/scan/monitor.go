@@ -0,0 +1,93 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"terrasync/db"
+	"terrasync/heartbeat"
+	"terrasync/log"
+	"terrasync/object"
+)
+
+// errStalled is returned for listings aborted by the heartbeat monitor
+var errStalled = errors.New("no progress before the stall timeout")
+
+// startMonitor starts the heartbeat monitor of a scan job.
+// Heartbeats are logged together with the connection pool metrics of the storage
+// and the hit rate of the stat cache, and saved in the heartbeats table of the
+// job database. The returned function
+// stops the monitor; both are no-ops when heartbeats are disabled.
+func startMonitor(scanConfig ScanConfig, storage object.Storage) (*heartbeat.Monitor, func(), error) {
+	if scanConfig.Heartbeat.Interval <= 0 {
+		return nil, func() {}, nil
+	}
+
+	// 使用单独的连接写心跳，任务数据库由扫描处理流程打开和关闭
+	dbInstance, err := NewDB(scanConfig.DbType, scanConfig.JobDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	monitor := heartbeat.NewMonitor(scanConfig.Heartbeat, func(beat heartbeat.Beat) {
+		if provider, ok := storage.(object.PoolStatsProvider); ok {
+			pool := provider.PoolStats()
+			log.Infof("Heartbeat pool: %d requests, %d new connections, %d reused, %d open",
+				pool.Requests, pool.NewConns, pool.ReusedConns, pool.OpenConns)
+		}
+		if cache := object.CurrentStatCache(); cache != nil {
+			log.Infof("Heartbeat stat cache: %s", cache.Stats())
+		}
+		// 任务取消后仍然记录最后一次心跳
+		if err := (*dbInstance).SaveHeartbeat(context.Background(), db.HeartbeatData{
+			Time:          beat.Time,
+			Entries:       beat.Entries,
+			Bytes:         beat.Bytes,
+			DeltaEntries:  beat.DeltaEntries,
+			DeltaBytes:    beat.DeltaBytes,
+			EntriesPerSec: beat.EntriesPerSec,
+			InFlight:      beat.InFlight,
+			Stalled:       beat.Stalled,
+		}); err != nil {
+			log.Warnf("%v", err)
+		}
+	})
+	monitor.Start()
+
+	return monitor, func() {
+		monitor.Stop()
+		if err := (*dbInstance).Close(); err != nil {
+			log.Errorf("Error closing database: %v", err)
+		}
+	}, nil
+}
+
+// listDir lists dir, giving up when op is aborted while the storage call blocks.
+// A call that is given up keeps running in the background and its entries are discarded.
+func listDir(storage object.Storage, dir string, op *heartbeat.Op) (<-chan object.FileInfo, error) {
+	if op == nil {
+		return storage.List(dir)
+	}
+
+	type result struct {
+		queue <-chan object.FileInfo
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		queue, err := storage.List(dir)
+		done <- result{queue, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.queue, r.err
+	case <-op.Aborted():
+		go func() {
+			if r := <-done; r.queue != nil {
+				for range r.queue {
+				}
+			}
+		}()
+		return nil, errStalled
+	}
+}
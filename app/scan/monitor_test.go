@@ -0,0 +1,161 @@
+package scan
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/heartbeat"
+	"terrasync/object"
+	"terrasync/sharedset"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// hungStorage 列举指定目录时一直阻塞，模拟挂起的NFS挂载
+type hungStorage struct {
+	object.Storage
+	dir     string
+	release chan struct{}
+}
+
+func (s *hungStorage) List(dir string) (<-chan object.FileInfo, error) {
+	if dir == s.dir {
+		<-s.release
+	}
+	return s.Storage.List(dir)
+}
+
+// TestListAllAbortStalled 测试卡住的目录被中止，其他目录继续扫描
+func TestListAllAbortStalled(t *testing.T) {
+	mem, err := object.CreateStorage("mem://monitor-test")
+	assert.NoError(t, err)
+	for _, key := range []string{"/ok/a.txt", "/ok/b.txt", "/hung/c.txt"} {
+		assert.NoError(t, mem.Put(key, strings.NewReader("data")))
+	}
+	storage := &hungStorage{Storage: mem, dir: "/hung", release: make(chan struct{})}
+	defer close(storage.release)
+
+	monitor := heartbeat.NewMonitor(heartbeat.Config{
+		Interval:     10 * time.Millisecond,
+		StallTimeout: 50 * time.Millisecond,
+		AbortStalled: true,
+	}, nil)
+	monitor.Start()
+
+	var keys []string
+	for fi := range ListAll(context.Background(), storage, ListOptions{Concurrency: 2, Monitor: monitor}) {
+		keys = append(keys, fi.Key())
+	}
+	monitor.Stop()
+
+	sort.Strings(keys)
+	assert.Equal(t, []string{"/hung", "/ok", "/ok/a.txt", "/ok/b.txt"}, keys)
+	assert.Equal(t, int64(4), monitor.Last().Entries)
+	assert.Equal(t, 0, monitor.Last().InFlight)
+}
+
+// TestListAllVisited 测试跳过其他节点已列举的子目录
+func TestListAllVisited(t *testing.T) {
+	ctx := context.Background()
+	mem, err := object.CreateStorage("mem://visited-test")
+	assert.NoError(t, err)
+	for _, key := range []string{"/a/1.txt", "/b/2.txt", "/c.txt"} {
+		assert.NoError(t, mem.Put(key, strings.NewReader("data")))
+	}
+	visited := sharedset.NewLocal()
+	_, err = visited.Add(ctx, "/b")
+	assert.NoError(t, err)
+
+	var keys []string
+	for fi := range ListAll(ctx, mem, ListOptions{Concurrency: 2, Visited: visited}) {
+		keys = append(keys, fi.Key())
+	}
+	sort.Strings(keys)
+	assert.Equal(t, []string{"/a", "/a/1.txt", "/b", "/c.txt"}, keys)
+}
+
+// nilListStorage 列举指定目录时既不返回错误也不返回条目通道
+type nilListStorage struct {
+	object.Storage
+	dir string
+}
+
+func (s *nilListStorage) List(dir string) (<-chan object.FileInfo, error) {
+	if dir == s.dir {
+		return nil, nil
+	}
+	return s.Storage.List(dir)
+}
+
+// TestListingNilChannel 测试没有返回条目通道的目录按列举错误处理，而不是一直等待
+func TestListingNilChannel(t *testing.T) {
+	mem, err := object.CreateStorage("mem://nil-list-test")
+	assert.NoError(t, err)
+	for _, key := range []string{"/ok/a.txt", "/nil/b.txt"} {
+		assert.NoError(t, mem.Put(key, strings.NewReader("data")))
+	}
+
+	results, errc := Listing(context.Background(), &nilListStorage{Storage: mem, dir: "/nil"}, ListOptions{Concurrency: 2})
+	var keys []string
+	for fi := range results {
+		keys = append(keys, fi.Key())
+	}
+	sort.Strings(keys)
+	assert.Equal(t, []string{"/nil", "/ok", "/ok/a.txt"}, keys)
+	var listErr *ListError
+	if assert.ErrorAs(t, <-errc, &listErr) {
+		assert.Equal(t, "/nil", listErr.Path)
+	}
+}
+
+// openListStorage 列举指定目录时返回一个条目后不再关闭通道，模拟列举到一半挂起
+type openListStorage struct {
+	object.Storage
+	dir     string
+	release chan struct{}
+}
+
+func (s *openListStorage) List(dir string) (<-chan object.FileInfo, error) {
+	if dir != s.dir {
+		return s.Storage.List(dir)
+	}
+	entries, err := s.Storage.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan object.FileInfo)
+	go func() {
+		defer close(out)
+		out <- <-entries
+		<-s.release
+		for o := range entries {
+			out <- o
+		}
+	}()
+	return out, nil
+}
+
+// TestListingCancelled 测试取消任务时正在读取条目的目录立即返回
+func TestListingCancelled(t *testing.T) {
+	mem, err := object.CreateStorage("mem://cancel-list-test")
+	assert.NoError(t, err)
+	assert.NoError(t, mem.Put("/hung/a.txt", strings.NewReader("data")))
+	storage := &openListStorage{Storage: mem, dir: "/hung", release: make(chan struct{})}
+	defer close(storage.release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results, errc := Listing(ctx, storage, ListOptions{Concurrency: 1})
+	var keys []string
+	for fi := range results {
+		keys = append(keys, fi.Key())
+		if fi.Key() == "/hung/a.txt" {
+			cancel()
+		}
+	}
+	assert.Contains(t, keys, "/hung/a.txt")
+	assert.ErrorIs(t, <-errc, context.Canceled)
+}
@@ -0,0 +1,58 @@
+package scan
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Partition is the share of the namespace scanned by one worker node of a
+// distributed scan. Entries of the scan root are assigned to partitions by the
+// hash of their name, a directory and everything below it belong to the same
+// partition, so the partitions of a tree never overlap.
+type Partition struct {
+	Index int `json:"index"` // 1..Count
+	Count int `json:"count"`
+}
+
+// ParsePartition parses "i/n", e.g. 3/8 for the third of eight partitions, an
+// empty string means no partitioning
+func ParsePartition(s string) (*Partition, error) {
+	if s == "" {
+		return nil, nil
+	}
+	index, count, ok := strings.Cut(s, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid partition %q, expected index/count such as 3/8", s)
+	}
+	p := &Partition{}
+	var err error
+	if p.Index, err = strconv.Atoi(strings.TrimSpace(index)); err != nil {
+		return nil, fmt.Errorf("invalid partition %q: %w", s, err)
+	}
+	if p.Count, err = strconv.Atoi(strings.TrimSpace(count)); err != nil {
+		return nil, fmt.Errorf("invalid partition %q: %w", s, err)
+	}
+	if p.Count < 1 || p.Index < 1 || p.Index > p.Count {
+		return nil, fmt.Errorf("invalid partition %q, index must be between 1 and %d", s, p.Count)
+	}
+	return p, nil
+}
+
+func (p *Partition) String() string {
+	return fmt.Sprintf("%d/%d", p.Index, p.Count)
+}
+
+// Contains reports whether the entry of the scan root with the given key belongs
+// to the partition, a nil partition contains everything
+func (p *Partition) Contains(key string) bool {
+	if p == nil || p.Count <= 1 {
+		return true
+	}
+	name, _, _ := strings.Cut(strings.TrimPrefix(filepath.ToSlash(key), "/"), "/")
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return int(h.Sum32()%uint32(p.Count)) == p.Index-1
+}
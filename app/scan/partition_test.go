@@ -0,0 +1,57 @@
+package scan
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParsePartition 测试分区参数的解析
+func TestParsePartition(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		want    *Partition
+		wantErr bool
+	}{
+		{name: "不分区", input: "", want: nil},
+		{name: "第一个分区", input: "1/4", want: &Partition{Index: 1, Count: 4}},
+		{name: "最后一个分区", input: " 4 / 4 ", want: &Partition{Index: 4, Count: 4}},
+		{name: "缺少分区数", input: "2", wantErr: true},
+		{name: "序号从1开始", input: "0/4", wantErr: true},
+		{name: "序号超出分区数", input: "5/4", wantErr: true},
+		{name: "非数字", input: "a/4", wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p, err := ParsePartition(c.input)
+			if c.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.want, p)
+		})
+	}
+}
+
+// TestPartitionContains 测试各分区互不重叠且覆盖所有条目，子目录跟随其第一级目录
+func TestPartitionContains(t *testing.T) {
+	partitions := []*Partition{{Index: 1, Count: 3}, {Index: 2, Count: 3}, {Index: 3, Count: 3}}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("/dir%d", i)
+		owners := 0
+		for _, p := range partitions {
+			if p.Contains(key) {
+				owners++
+				assert.True(t, p.Contains(key+"/sub/file.txt"), key)
+			}
+		}
+		assert.Equal(t, 1, owners, key)
+	}
+
+	var none *Partition
+	assert.True(t, none.Contains("/dir1"))
+	assert.True(t, (&Partition{Index: 1, Count: 1}).Contains("/dir1"))
+}
@@ -0,0 +1,104 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"terrasync/db"
+	"terrasync/log"
+	"terrasync/object"
+	"time"
+)
+
+// maxPruneEntries limits the entries held while checking whether a directory is
+// unchanged, larger directories are always descended
+const maxPruneEntries = 100000
+
+// dirState is the mtime and the number of entries of a directory in the baseline
+type dirState struct {
+	mtime   time.Time
+	entries int64
+}
+
+// DirBaseline holds the directories of the previous scan of a job. An incremental
+// scan doesn't descend into a directory whose mtime and number of entries are
+// unchanged, the directory is assumed to be unchanged down to its leaves.
+//
+// The mtime of a directory only changes when an entry is added, removed or
+// renamed in the directory itself, so pruning misses files modified in place and
+// changes below unchanged subdirectories. It suits mostly static archives where
+// such changes don't happen, and needs storages with reliable directory mtimes.
+type DirBaseline struct {
+	dirs map[string]dirState
+}
+
+// LoadDirBaseline loads the directories of the file_entries table of a job
+func LoadDirBaseline(ctx context.Context, dbInstance *db.DB) (*DirBaseline, error) {
+	rows, err := (*dbInstance).Query(ctx, "SELECT path, mtime, is_dir FROM file_entries")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load directory baseline: %w", err)
+	}
+	defer rows.Close()
+
+	mtimes := make(map[string]time.Time)
+	entries := make(map[string]int64)
+	for rows.Next() {
+		var key string
+		var mtime time.Time
+		var isDir bool
+		if err := rows.Scan(&key, &mtime, &isDir); err != nil {
+			return nil, fmt.Errorf("failed to read directory baseline: %w", err)
+		}
+		if isDir {
+			mtimes[key] = mtime
+		}
+		entries[path.Dir(key)]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read directory baseline: %w", err)
+	}
+
+	baseline := &DirBaseline{dirs: make(map[string]dirState, len(mtimes))}
+	for dir, mtime := range mtimes {
+		baseline.dirs[dir] = dirState{mtime: mtime, entries: entries[dir]}
+	}
+	log.Infof("Loaded baseline of %d directories", len(baseline.dirs))
+	return baseline, nil
+}
+
+// unchanged reports whether dir had the same mtime in the baseline, and returns
+// the number of entries it had. A nil baseline has no directories.
+func (b *DirBaseline) unchanged(dir string, mtime time.Time) (int64, bool) {
+	if b == nil || mtime.IsZero() {
+		return 0, false
+	}
+	state, ok := b.dirs[dir]
+	if !ok || !state.mtime.Equal(mtime) || state.entries > maxPruneEntries {
+		return 0, false
+	}
+	return state.entries, true
+}
+
+// loadPruneBaseline loads the baseline of an incremental scan pruning unchanged
+// directories, nil when pruning is disabled or can't be trusted for this scan
+func loadPruneBaseline(ctx context.Context, scanConfig ScanConfig, storage object.Storage) (*DirBaseline, error) {
+	if !scanConfig.PruneUnchanged || !scanConfig.IncrementalScan {
+		return nil, nil
+	}
+	if !object.ReliableDirMTime(storage) {
+		log.Warnf("Storage %s has no reliable directory mtimes, unchanged directories are not pruned", scanConfig.Path)
+		return nil, nil
+	}
+	// 基线只保存通过过滤及处理器的条目，其条目数无法与列举到的条目数比较
+	if len(scanConfig.Match) > 0 || len(scanConfig.Exclude) > 0 || scanConfig.Pipeline.Len() > 0 {
+		log.Warnf("Unchanged directories are not pruned in scans with match or exclude filters or processors")
+		return nil, nil
+	}
+
+	dbInstance, err := NewDB(scanConfig.DbType, scanConfig.JobDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database instance: %w", err)
+	}
+	defer (*dbInstance).Close()
+	return LoadDirBaseline(ctx, dbInstance)
+}
@@ -0,0 +1,74 @@
+package scan
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// dirMTimeStorage 模拟目录mtime可靠的存储
+type dirMTimeStorage struct {
+	object.Storage
+}
+
+func (s dirMTimeStorage) ReliableDirMTime() bool {
+	return true
+}
+
+// TestPruneUnchangedDirs 测试增量扫描不再深入mtime及条目数未变的目录
+func TestPruneUnchangedDirs(t *testing.T) {
+	ctx := context.Background()
+	mem, err := object.CreateStorage("mem://prune-test")
+	assert.NoError(t, err)
+	for _, key := range []string{"/2020/a.txt", "/2020/b.txt", "/2021/c.txt", "/live/d.txt", "/old/e.txt", "/old/f.txt"} {
+		assert.NoError(t, mem.Put(key, strings.NewReader("data")))
+	}
+	storage := dirMTimeStorage{mem}
+
+	// 上次扫描的结果作为基线
+	jobDir := t.TempDir()
+	dbInstance, err := InitDatabase(ctx, "sqlite", jobDir)
+	assert.NoError(t, err)
+	var entries []object.FileInfo
+	for fi := range ListAll(ctx, storage, ListOptions{Concurrency: 2}) {
+		entries = append(entries, fi)
+	}
+	assert.NoError(t, (*dbInstance).SaveEntries(ctx, entries, ""))
+	assert.NoError(t, (*dbInstance).Close())
+
+	scanConfig := ScanConfig{DbType: "sqlite", JobDir: jobDir, IncrementalScan: true, PruneUnchanged: true}
+	baseline, err := loadPruneBaseline(ctx, scanConfig, storage)
+	assert.NoError(t, err)
+	assert.Len(t, baseline.dirs, 4)
+
+	// 不支持可靠目录mtime的存储及带过滤条件的扫描不裁剪
+	disabled, err := loadPruneBaseline(ctx, scanConfig, mem)
+	assert.NoError(t, err)
+	assert.Nil(t, disabled)
+	filtered := scanConfig
+	filtered.Match = []string{"size > 1"}
+	disabled, err = loadPruneBaseline(ctx, filtered, storage)
+	assert.NoError(t, err)
+	assert.Nil(t, disabled)
+
+	assert.NoError(t, mem.Put("/2020/a.txt", strings.NewReader("modified in place")))
+	assert.NoError(t, mem.Put("/2021/new.txt", strings.NewReader("data")))
+	assert.NoError(t, mem.Delete("/old/f.txt"))
+	assert.NoError(t, mem.(object.MetadataSetter).SetMetadata("/live", object.Metadata{Perm: 0755, MTime: time.Now().Add(time.Hour)}))
+
+	stats := NewStats()
+	var keys []string
+	for fi := range ListAll(ctx, storage, ListOptions{Concurrency: 2, Stats: stats, Baseline: baseline}) {
+		keys = append(keys, fi.Key())
+	}
+	sort.Strings(keys)
+	// 只有/2020未变化，其下就地修改的文件不会被发现
+	assert.Equal(t, []string{"/2020", "/2021", "/2021/c.txt", "/2021/new.txt", "/live", "/live/d.txt", "/old", "/old/e.txt"}, keys)
+	assert.Equal(t, int64(1), stats.GetPrunedDirCount())
+}
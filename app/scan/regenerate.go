@@ -0,0 +1,164 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"terrasync/db"
+	"terrasync/log"
+	"terrasync/security"
+)
+
+// RegenerateConfig selects the reports rebuilt from a finished scan job
+type RegenerateConfig struct {
+	JobDir  string
+	LogPath string
+	CSV     bool // 从任务数据库重新生成CSV报告及统计汇总CSV
+	HTML    bool // 从任务摘要生成HTML报告
+	JSON    bool // 从任务数据库重新生成NDJSON报告
+	Quiet   bool // 不在控制台打印扫描统计
+
+	// CSVDelimiter is the field delimiter of the CSV reports, 0 for the comma
+	CSVDelimiter rune
+
+	// SignKey re-creates and signs the report manifest when set
+	SignKey *security.SigningKey
+}
+
+// RegenerateResult holds the paths of the reports written
+type RegenerateResult struct {
+	Summary  *JobSummary
+	CsvPath  string
+	HtmlPath string
+	JSONPath string
+	Manifest string
+}
+
+// Regenerate rebuilds the reports of a finished full scan from the summary and
+// database saved in its job directory, without scanning the storage again
+func Regenerate(ctx context.Context, config RegenerateConfig) (*RegenerateResult, error) {
+	summary, err := LoadJobSummary(config.JobDir)
+	if err != nil {
+		return nil, err
+	}
+	result := &RegenerateResult{Summary: summary}
+
+	if config.CSV {
+		result.CsvPath = filepath.Join(config.JobDir, CSVReportName)
+		if err := regenerateCSV(ctx, config.JobDir, summary, result.CsvPath, config.CSVDelimiter); err != nil {
+			return result, err
+		}
+		if err := writeSummaryCSV(filepath.Join(config.JobDir, SummaryCSVName), config.CSVDelimiter, summary); err != nil {
+			return result, err
+		}
+		if err := writeExtensionsCSV(filepath.Join(config.JobDir, ExtensionsCSVName), config.CSVDelimiter, summary); err != nil {
+			return result, err
+		}
+		log.Infof("Regenerated CSV report %s", result.CsvPath)
+	}
+	if config.HTML {
+		result.HtmlPath = filepath.Join(config.JobDir, HTMLReportName)
+		if err := regenerateHTML(ctx, config.JobDir, summary, result.HtmlPath); err != nil {
+			return result, err
+		}
+		log.Infof("Regenerated HTML report %s", result.HtmlPath)
+	}
+	if config.JSON {
+		result.JSONPath = filepath.Join(config.JobDir, JSONReportName)
+		if err := regenerateJSON(ctx, config.JobDir, summary, result.JSONPath); err != nil {
+			return result, err
+		}
+		log.Infof("Regenerated JSON report %s", result.JSONPath)
+	}
+	if config.SignKey != nil {
+		if result.Manifest, err = SignReports(config.JobDir, config.SignKey); err != nil {
+			return result, err
+		}
+		log.Infof("Signed report manifest %s with key %s", result.Manifest, config.SignKey.ID)
+	}
+
+	if !config.Quiet {
+		var jobErr error
+		if summary.Error != "" {
+			jobErr = errors.New(summary.Error)
+		}
+		GenerateConsoleReportSummary(ReportConfig{
+			AppVersion: summary.AppVersion,
+			CmdLine:    summary.CmdLine,
+			CsvPath:    result.CsvPath,
+			HtmlPath:   result.HtmlPath,
+			JSONPath:   result.JSONPath,
+			Manifest:   result.Manifest,
+			JobID:      summary.JobID,
+			LogPath:    config.LogPath,
+			StartTime:  summary.StartTime,
+			EndTime:    summary.EndTime,
+			CryptoMode: summary.CryptoMode,
+		}, summary.Stats.Stats(), summary, jobErr)
+	}
+	return result, nil
+}
+
+// regenerateHTML writes the HTML report with the file details of the job database
+func regenerateHTML(ctx context.Context, jobDir string, summary *JobSummary, path string) error {
+	dbInstance, err := NewDB(summary.DbType, jobDir)
+	if err != nil {
+		return fmt.Errorf("failed to open job database: %w", err)
+	}
+	defer (*dbInstance).Close()
+
+	return writeHTMLReport(ctx, path, summary, dbInstance)
+}
+
+// regenerateCSV writes the entries saved in the job database as a CSV report
+func regenerateCSV(ctx context.Context, jobDir string, summary *JobSummary, path string, delimiter rune) error {
+	dbInstance, err := NewDB(summary.DbType, jobDir)
+	if err != nil {
+		return fmt.Errorf("failed to open job database: %w", err)
+	}
+	defer (*dbInstance).Close()
+
+	report, err := newCSVReport(path, delimiter)
+	if err != nil {
+		return err
+	}
+	err = (*dbInstance).ListEntries(ctx, "", func(entry db.FileInfoData) error {
+		return report.WriteEntry(filepath.Join(summary.Path, entry.Key), entry)
+	})
+	if cerr := report.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to regenerate CSV report: %w", err)
+	}
+	return nil
+}
+
+// regenerateJSON writes the entries saved in the job database and the job
+// summary as an NDJSON report
+func regenerateJSON(ctx context.Context, jobDir string, summary *JobSummary, path string) error {
+	dbInstance, err := NewDB(summary.DbType, jobDir)
+	if err != nil {
+		return fmt.Errorf("failed to open job database: %w", err)
+	}
+	defer (*dbInstance).Close()
+
+	report, err := newJSONReport(path)
+	if err != nil {
+		return err
+	}
+	err = (*dbInstance).ListEntries(ctx, "", func(entry db.FileInfoData) error {
+		return report.WriteEntry(filepath.Join(summary.Path, entry.Key), entry)
+	})
+	if err == nil {
+		err = report.WriteSummary(summary)
+	}
+	if cerr := report.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to regenerate JSON report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package scan
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/log"
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func init() {
+	log.Log = zap.NewNop().Sugar()
+}
+
+// TestStatsSnapshot 测试统计信息快照可以还原
+func TestStatsSnapshot(t *testing.T) {
+	stats := NewStats()
+	stats.SetHugeDirThreshold(2)
+	stats.RecordDirEntries("/big", 5)
+	stats.RecordProcessed(&MockFileInfo{key: "/skipped"}, false)
+	file := &MockFileInfo{key: "/a/b.txt", _size: 100}
+	file.On("IsRegular").Return(true)
+	file.On("IsSymlink").Return(false)
+	stats.Update(file)
+
+	restored := stats.Snapshot().Stats()
+	assert.Equal(t, stats.Snapshot(), restored.Snapshot())
+	assert.Equal(t, int64(1), restored.GetFileCount())
+	assert.Equal(t, []string{"/big"}, restored.GetHugeDirs())
+	assert.Equal(t, int64(1), restored.GetSkippedCount())
+}
+
+// offlineFileInfo 是数据离线的存根文件
+type offlineFileInfo struct {
+	*MockFileInfo
+}
+
+func (f offlineFileInfo) Stub() bool {
+	return true
+}
+
+// TestStatsStubs 测试统计离线存根文件并在快照中还原
+func TestStatsStubs(t *testing.T) {
+	stats := NewStats()
+	for key, stub := range map[string]bool{"/a/online.txt": false, "/a/stub.bin": true} {
+		file := &MockFileInfo{key: key, _size: 1 << 20}
+		file.On("IsRegular").Return(true)
+		file.On("IsSymlink").Return(false)
+		if stub {
+			stats.Update(offlineFileInfo{file})
+		} else {
+			stats.Update(file)
+		}
+	}
+	assert.Equal(t, int64(2), stats.GetFileCount())
+	assert.Equal(t, int64(1), stats.GetStubCount())
+	assert.Equal(t, int64(1<<20), stats.GetStubBytes())
+
+	restored := stats.Snapshot().Stats()
+	assert.Equal(t, int64(1), restored.GetStubCount())
+	assert.Equal(t, int64(1<<20), restored.GetStubBytes())
+}
+
+// TestStatsEmptyEntries 测试统计空目录及0字节文件并在快照中还原
+func TestStatsEmptyEntries(t *testing.T) {
+	stats := NewStats()
+	stats.RecordDirEntries("/a", 2)
+	stats.RecordDirEntries("/a/empty", 0)
+	for key, size := range map[string]int64{"/a/data.txt": 10, "/a/zero.txt": 0} {
+		file := &MockFileInfo{key: key, _size: size}
+		file.On("IsRegular").Return(true)
+		file.On("IsSymlink").Return(false)
+		stats.Update(file)
+	}
+	assert.Equal(t, int64(1), stats.GetEmptyDirCount())
+	assert.Equal(t, int64(1), stats.GetEmptyFileCount())
+
+	restored := stats.Snapshot().Stats()
+	assert.Equal(t, int64(1), restored.GetEmptyDirCount())
+	assert.Equal(t, int64(1), restored.GetEmptyFileCount())
+}
+
+// TestRegenerate 测试从任务目录重新生成CSV和HTML报告
+func TestRegenerate(t *testing.T) {
+	ctx := context.Background()
+	jobDir := t.TempDir()
+
+	storage, err := object.CreateStorage("mem://regenerate-test")
+	assert.NoError(t, err)
+	assert.NoError(t, storage.Put("/dir/a.txt", strings.NewReader("hello")))
+	var entries []object.FileInfo
+	for _, key := range []string{"/dir", "/dir/a.txt"} {
+		fi, err := storage.Head(key)
+		assert.NoError(t, err)
+		entries = append(entries, fi)
+	}
+
+	dbInstance, err := InitDatabase(ctx, "sqlite", jobDir)
+	assert.NoError(t, err)
+	assert.NoError(t, (*dbInstance).SaveEntries(ctx, entries, ""))
+	assert.NoError(t, (*dbInstance).Close())
+
+	stats := NewStats()
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, SaveJobSummary(jobDir, JobSummary{
+		JobID:     "Job_test_scan",
+		Path:      "/mnt",
+		DbType:    "sqlite",
+		StartTime: start,
+		EndTime:   start.Add(time.Minute),
+		Error:     "1 database batches failed",
+		Stats:     stats.Snapshot(),
+	}))
+
+	result, err := Regenerate(ctx, RegenerateConfig{JobDir: jobDir, CSV: true, HTML: true, JSON: true, Quiet: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "Job_test_scan", result.Summary.JobID)
+
+	f, err := os.Open(result.CsvPath)
+	assert.NoError(t, err)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, []string{filepath.Join("/mnt", "/dir"), "dir"}, records[1][:2])
+	assert.Equal(t, []string{filepath.Join("/mnt", "/dir/a.txt"), "file", ".txt", "5", "5 B"}, records[2][:5])
+	_, err = os.Stat(filepath.Join(jobDir, SummaryCSVName))
+	assert.NoError(t, err)
+
+	html, err := os.ReadFile(result.HtmlPath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(html), "Job_test_scan")
+	assert.Contains(t, string(html), "1 database batches failed")
+
+	// NDJSON报告每个条目一行，最后是摘要记录
+	data, err := os.ReadFile(result.JSONPath)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 3)
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, map[string]interface{}{"type": "file", "path": filepath.Join("/mnt", "/dir/a.txt"), "ext": ".txt", "size": 5.0},
+		map[string]interface{}{"type": entry["type"], "path": entry["path"], "ext": entry["ext"], "size": entry["size"]})
+	var last map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[2]), &last))
+	assert.Equal(t, "summary", last["type"])
+	assert.Equal(t, "failed", last["status"])
+	assert.Equal(t, "Job_test_scan", last["job_id"])
+	assert.Equal(t, 60.0, last["elapsed_sec"])
+
+	// 没有摘要的任务目录不能重新生成
+	_, err = Regenerate(ctx, RegenerateConfig{JobDir: t.TempDir()})
+	assert.Error(t, err)
+}
@@ -1,9 +1,15 @@
 package scan
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"terrasync/db"
+	"terrasync/i18n"
 	"terrasync/log"
+	"terrasync/pkg/report"
+	"terrasync/pkg/stats"
+	"terrasync/security"
 	"time"
 )
 
@@ -12,61 +18,213 @@ type KafkaConfig struct {
 	Host        string
 	Port        int
 	Topic       string
+	Topics      map[EventType]TopicConfig // 各事件类型的topic，见ParseKafkaTopics
 	Concurrency int
+	TLS         bool
+	Overflow    OverflowStrategy // 发送队列满时的处理: block, drop 或 spool
 }
 
 type ReportConfig struct {
 	AppVersion  string
 	CmdLine     string
 	CsvReport   bool
+	CsvPath     string // CSV报告路径，由扫描任务设置
+	HtmlPath    string // HTML报告路径，由扫描任务设置
 	HtmlReport  bool
 	KafkaConfig KafkaConfig
+	Webhook     WebhookConfig
+	ClickHouse  ClickHouseConfig
 	JobID       string
 	LogPath     string
 	StartTime   time.Time
+	EndTime     time.Time // 为空时按当前时间计算总耗时
+	CryptoMode  string
 	Quiet       bool
+	// SampleOutput limits the "Found" lines printed, or logged when quiet, for the scanned entries
+	SampleOutput SampleOutput
+	// ProgressLine prints a progress line every progress interval, only logged when quiet
+	ProgressLine bool
+
+	// SignKey signs the manifest of the reports, Manifest is its path set by the scan job
+	SignKey  *security.SigningKey
+	Manifest string
+
+	// CsvDelimiter is the field delimiter of the CSV reports, 0 for the comma
+	CsvDelimiter rune
+
+	// JSONReport writes the entries and the summary as NDJSON, JSONPath is set by
+	// the scan job. Output json prints the summary as a JSON object instead of text.
+	JSONReport bool
+	JSONPath   string
+	Output     string
 }
 
 func GenerateConsoleReportTitle(reportConfig ReportConfig) {
 	// Print stats in console
-	fmt.Printf("terrasync %s; (c) 2025 LenovoNetapp, Inc.\n\n", reportConfig.AppVersion)
+	if reportConfig.Output != OutputJSON {
+		fmt.Printf("terrasync %s; (c) 2025 LenovoNetapp, Inc.\n\n", reportConfig.AppVersion)
+	}
 	// print stats into log
 	log.Infof("terrasync %s; (c) 2025 LenovoNetapp, Inc.\n\n", reportConfig.AppVersion)
 
 }
 
-// printToConsoleAndLog 同时输出到控制台和日志
-func printToConsoleAndLog(format string, args ...interface{}) {
-	fmt.Printf(format, args...)
-	log.Infof(format, args...)
+// printNotice prints a notice of the scan to the console and the log, only to
+// the log with --output json so that stdout is a single JSON object
+func printNotice(reportConfig ReportConfig, format string, args ...interface{}) {
+	if reportConfig.Output == OutputJSON {
+		log.Infof(format, args...)
+		return
+	}
+	console.Printf(format, args...)
 }
 
-func GenerateConsoleReportSummary(reportConfig ReportConfig, stats Stats, dbInstance *db.DB) {
-	totalTime := time.Since(reportConfig.StartTime)
+// printProgress prints the periodic progress line of the scan, only to the
+// log when quiet or with --output json
+func printProgress(reportConfig ReportConfig, r stats.ProgressRecord) {
+	if r.Done {
+		return
+	}
+	elapsed := time.Duration(r.ElapsedSec * float64(time.Second)).Round(time.Second)
+	line := i18n.Sprintf("Progress: %d files, %d dirs, %s seen, %.0f files/s, elapsed %v",
+		r.Files, r.Dirs, FormatFileSize(r.Bytes), r.FilesPerSec, elapsed)
+	if r.ETASec != nil {
+		line += i18n.Sprintf(", ETA %v", time.Duration(*r.ETASec)*time.Second)
+	}
+	if reportConfig.Quiet {
+		log.Info(line)
+		return
+	}
+	printNotice(reportConfig, "%s\n", line)
+}
 
-	// Get unique extension count with error handling
-	extCount, err := (*dbInstance).GetUniqueExtCount()
-	if err != nil {
-		log.Errorf("Failed to get file type count: %v\n", err)
-		extCount = 0 // Set default value in case of error
+// console prints the scan reports to stdout and the log
+var console = report.NewPrinter(os.Stdout)
+
+// GenerateConsoleReportSummary prints the scan summary, jobErr is reported as the job status
+func GenerateConsoleReportSummary(reportConfig ReportConfig, stats *Stats, summary *JobSummary, jobErr error) {
+	endTime := reportConfig.EndTime
+	if endTime.IsZero() {
+		endTime = time.Now()
 	}
+	totalTime := endTime.Sub(reportConfig.StartTime)
 
 	// 打印空行
 	fmt.Println()
 
 	// 同时输出到控制台和日志
-	printToConsoleAndLog("==================================================================\n")
-	printToConsoleAndLog("                          Scan Statistics                         \n")
-	printToConsoleAndLog("==================================================================\n\n")
+	console.Title("Scan Statistics")
 
-	printToConsoleAndLog("  Command    :    %s\n", reportConfig.CmdLine)
-	printToConsoleAndLog("  Total time :    %s\n", totalTime.Round(time.Second))
-	printToConsoleAndLog("  Job ID     :    %s\n", reportConfig.JobID)
-	printToConsoleAndLog("  Log Path   :    %s\n", reportConfig.LogPath)
+	console.Header("Command", reportConfig.CmdLine)
+	console.Header("Start time", i18n.FormatTime(reportConfig.StartTime))
+	console.Header("Total time", totalTime.Round(time.Second))
+	console.Header("Job ID", reportConfig.JobID)
+	console.Header("Log Path", reportConfig.LogPath)
+	if reportConfig.CsvPath != "" {
+		console.Header("CSV Report", reportConfig.CsvPath)
+	}
+	if reportConfig.HtmlPath != "" {
+		console.Header("HTML Report", reportConfig.HtmlPath)
+	}
+	if reportConfig.JSONPath != "" {
+		console.Header("JSON Report", reportConfig.JSONPath)
+	}
+	if reportConfig.Manifest != "" {
+		console.Header("Manifest", reportConfig.Manifest)
+	}
+	console.Header("Crypto mode", reportConfig.CryptoMode)
+	console.Status(jobErr)
 
 	stats.Print()
 
-	printToConsoleAndLog("  File type:        %30d\n", extCount)
+	console.Field("File type", summary.FileTypes)
+	printExtensions(summary.Extensions)
+
+	console.Summary(summaryPairs(reportConfig, stats, summary.FileTypes, totalTime, jobErr), jobErr)
+}
+
+// summaryPairs returns the key=value pairs of the SUMMARY line printed after the report
+func summaryPairs(reportConfig ReportConfig, stats *Stats, fileTypes int, totalTime time.Duration, jobErr error) []report.Pair {
+	return []report.Pair{
+		{Key: "job", Value: reportConfig.JobID},
+		{Key: "status", Value: report.StatusOf(jobErr)},
+		{Key: "files", Value: stats.GetFileCount()},
+		{Key: "dirs", Value: stats.GetDirCount()},
+		{Key: "bytes", Value: stats.GetTotalSize()},
+		{Key: "skipped", Value: stats.GetSkippedCount()},
+		{Key: "file_types", Value: fileTypes},
+		{Key: "max_depth", Value: stats.GetMaxDirDepth()},
+		{Key: "max_dir_entries", Value: stats.GetMaxDirEntries()},
+		{Key: "huge_dirs", Value: stats.GetHugeDirCount()},
+		{Key: "elapsed_sec", Value: int64(totalTime.Round(time.Second).Seconds())},
+	}
+}
 
-	printToConsoleAndLog("\n=================================================================\n")
+// fileTypeCount returns the number of distinct extensions saved in the job database
+func fileTypeCount(ctx context.Context, dbInstance *db.DB) int {
+	extCount, err := (*dbInstance).GetUniqueExtCount(ctx)
+	if err != nil {
+		log.Errorf("Failed to get file type count: %v\n", err)
+		return 0
+	}
+	return extCount
+}
+
+// topExtensions is the number of extensions of the breakdown in the reports,
+// the remaining extensions are summed up in one row
+const topExtensions = 20
+
+// extensionUsage returns the files and bytes per extension grouped by the job
+// database, the largest topExtensions first and the others in one row
+func extensionUsage(ctx context.Context, dbInstance *db.DB) ([]ExtensionUsage, error) {
+	stats, err := (*dbInstance).GetExtStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	usage := make([]ExtensionUsage, 0, min(len(stats), topExtensions+1))
+	for i, st := range stats {
+		if i < topExtensions {
+			usage = append(usage, ExtensionUsage{Ext: st.Ext, Files: st.Files, Bytes: st.Bytes})
+			continue
+		}
+		if i == topExtensions {
+			usage = append(usage, ExtensionUsage{Ext: "*"})
+		}
+		usage[topExtensions].Files += st.Files
+		usage[topExtensions].Bytes += st.Bytes
+	}
+	return usage, nil
+}
+
+// extensionBreakdown returns the extension breakdown saved in the job summary
+func extensionBreakdown(ctx context.Context, dbInstance *db.DB) []ExtensionUsage {
+	usage, err := extensionUsage(ctx, dbInstance)
+	if err != nil {
+		log.Errorf("Failed to get extension breakdown: %v", err)
+		return nil
+	}
+	return usage
+}
+
+// extensionLabel returns the translated label of an extension of the breakdown
+func extensionLabel(ext string) string {
+	switch ext {
+	case "*":
+		return i18n.T("Other")
+	case "":
+		return i18n.T("(none)")
+	}
+	return ext
+}
+
+// printExtensions prints the extension breakdown of the summary
+func printExtensions(extensions []ExtensionUsage) {
+	if len(extensions) == 0 {
+		return
+	}
+	console.Section("Extensions")
+	console.Printf("  %s %12s %12s %12s\n", i18n.Pad(i18n.T("Extension"), report.LabelWidth(20)), i18n.T("Files"), i18n.T("Total"), i18n.T("Avg"))
+	for _, usage := range extensions {
+		console.Printf("  %s %12d %12s %12s\n", i18n.Pad(extensionLabel(usage.Ext), report.LabelWidth(20)), usage.Files, FormatFileSize(usage.Bytes), FormatFileSize(usage.AvgSize()))
+	}
 }
@@ -0,0 +1,33 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"terrasync/pkg/report"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSummaryLine 测试供脚本解析的摘要行
+func TestSummaryLine(t *testing.T) {
+	stats := NewStats()
+	stats.Counters().AddFile(100)
+	stats.Counters().AddFile(23)
+	stats.Counters().AddDir()
+
+	line := report.SummaryLine(summaryPairs(ReportConfig{JobID: "Job_1_scan"}, stats, 2, 90*time.Second, nil), nil)
+	assert.Equal(t, "SUMMARY job=Job_1_scan status=succeeded files=2 dirs=1 bytes=123 skipped=0 file_types=2 max_depth=0 max_dir_entries=0 huge_dirs=0 elapsed_sec=90", line)
+
+	jobErr := errors.New(`open "/mnt": permission denied`)
+	line = report.SummaryLine(summaryPairs(ReportConfig{}, stats, 2, time.Second, jobErr), jobErr)
+	assert.True(t, strings.HasPrefix(line, `SUMMARY job="" status=failed `))
+	assert.True(t, strings.HasSuffix(line, ` error="open \"/mnt\": permission denied"`))
+
+	jobErr = fmt.Errorf("interrupted after 3 entries: %w", context.Canceled)
+	line = report.SummaryLine(summaryPairs(ReportConfig{}, stats, 2, time.Second, jobErr), jobErr)
+	assert.True(t, strings.HasPrefix(line, `SUMMARY job="" status=interrupted `))
+}
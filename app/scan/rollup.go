@@ -0,0 +1,295 @@
+package scan
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"os"
+	"strconv"
+	"terrasync/db"
+	"terrasync/i18n"
+	"terrasync/pkg/report"
+	"time"
+)
+
+// sizeBuckets are the upper bounds of the file size histogram, the last bucket is open
+var sizeBuckets = []struct {
+	label string
+	max   int64
+}{
+	{"< 4 KiB", 4 << 10},
+	{"4 KiB - 64 KiB", 64 << 10},
+	{"64 KiB - 1 MiB", 1 << 20},
+	{"1 MiB - 16 MiB", 16 << 20},
+	{"16 MiB - 256 MiB", 256 << 20},
+	{"256 MiB - 4 GiB", 4 << 30},
+	{">= 4 GiB", -1},
+}
+
+// ageBuckets are the upper bounds of the modification age histogram, measured
+// from the end of each scan so that shares scanned on different days compare
+var ageBuckets = []struct {
+	label string
+	max   time.Duration
+}{
+	{"< 30 days", 30 * 24 * time.Hour},
+	{"30 - 90 days", 90 * 24 * time.Hour},
+	{"90 days - 1 year", 365 * 24 * time.Hour},
+	{"1 - 3 years", 3 * 365 * 24 * time.Hour},
+	{">= 3 years", -1},
+}
+
+// Histogram counts files and bytes per bucket
+type Histogram struct {
+	Labels []string
+	Files  []int64
+	Bytes  []int64
+}
+
+func newHistogram(labels []string) Histogram {
+	return Histogram{Labels: labels, Files: make([]int64, len(labels)), Bytes: make([]int64, len(labels))}
+}
+
+func (h *Histogram) add(bucket int, size int64) {
+	h.Files[bucket]++
+	h.Bytes[bucket] += size
+}
+
+// ShareSummary is one scan job in a rollup
+type ShareSummary struct {
+	JobID     string
+	Path      string
+	Files     int64
+	Dirs      int64
+	Bytes     int64
+	StartTime time.Time
+	Error     string
+}
+
+// Rollup combines several share-level scans into one project-level summary
+type Rollup struct {
+	Shares []ShareSummary
+	Files  int64
+	Dirs   int64
+	Bytes  int64
+	Failed int // 失败的扫描任务数
+	Sizes  Histogram
+	Ages   Histogram
+	// Categories sums the files per category of their extension, such as
+	// documents or VM images, more actionable than thousands of extensions
+	Categories Histogram
+}
+
+// BuildRollup loads the summaries of the given job directories and builds the
+// combined histograms from their databases
+func BuildRollup(ctx context.Context, jobDirs []string) (*Rollup, error) {
+	rollup := &Rollup{}
+	sizeLabels := make([]string, len(sizeBuckets))
+	for i, b := range sizeBuckets {
+		sizeLabels[i] = b.label
+	}
+	ageLabels := make([]string, len(ageBuckets))
+	for i, b := range ageBuckets {
+		ageLabels[i] = b.label
+	}
+	rollup.Sizes, rollup.Ages = newHistogram(sizeLabels), newHistogram(ageLabels)
+	rollup.Categories = newCategoryHistogram()
+
+	for _, jobDir := range jobDirs {
+		summary, err := LoadJobSummary(jobDir)
+		if err != nil {
+			return nil, err
+		}
+		rollup.Shares = append(rollup.Shares, ShareSummary{
+			JobID:     summary.JobID,
+			Path:      summary.Path,
+			Files:     summary.Stats.FileCount,
+			Dirs:      summary.Stats.DirCount,
+			Bytes:     summary.Stats.TotalSize,
+			StartTime: summary.StartTime,
+			Error:     summary.Error,
+		})
+		rollup.Files += summary.Stats.FileCount
+		rollup.Dirs += summary.Stats.DirCount
+		rollup.Bytes += summary.Stats.TotalSize
+		if summary.Error != "" {
+			rollup.Failed++
+		}
+
+		if err := rollup.addHistograms(ctx, jobDir, summary); err != nil {
+			return nil, fmt.Errorf("job %s: %w", summary.JobID, err)
+		}
+	}
+	return rollup, nil
+}
+
+// addHistograms adds the files saved in a job database to the histograms
+func (r *Rollup) addHistograms(ctx context.Context, jobDir string, summary *JobSummary) error {
+	dbInstance, err := NewDB(summary.DbType, jobDir)
+	if err != nil {
+		return fmt.Errorf("failed to open job database: %w", err)
+	}
+	defer (*dbInstance).Close()
+
+	return (*dbInstance).ListEntries(ctx, "", func(entry db.FileInfoData) error {
+		if entry.IsDir {
+			return nil
+		}
+		for i, b := range sizeBuckets {
+			if b.max < 0 || entry.Size < b.max {
+				r.Sizes.add(i, entry.Size)
+				break
+			}
+		}
+		age := summary.EndTime.Sub(entry.MTime)
+		for i, b := range ageBuckets {
+			if b.max < 0 || age < b.max {
+				r.Ages.add(i, entry.Size)
+				break
+			}
+		}
+		r.Categories.add(categoryOf(entry.Ext), entry.Size)
+		return nil
+	})
+}
+
+// Print prints the rollup to the console and the log
+func (r *Rollup) Print() {
+	fmt.Println()
+	console.Title("Rollup Statistics")
+
+	console.Field("Jobs", len(r.Shares))
+	console.Field("Failed jobs", r.Failed)
+	console.Field("Files", r.Files)
+	console.Field("Directories", r.Dirs)
+	console.Field("Total", FormatFileSize(r.Bytes))
+
+	console.Section("Shares")
+	console.Printf("  %s %12s %12s %12s\n", i18n.Pad(i18n.T("Path"), report.LabelWidth(30)), i18n.T("Files"), i18n.T("Directories"), i18n.T("Total"))
+	for _, share := range r.Shares {
+		path := share.Path
+		if share.Error != "" {
+			path += " (!)"
+		}
+		console.Printf("  %s %12d %12d %12s\n", i18n.Pad(path, report.LabelWidth(30)), share.Files, share.Dirs, FormatFileSize(share.Bytes))
+	}
+
+	r.printHistogram("File Size", r.Sizes)
+	r.printHistogram("File Age", r.Ages)
+	r.printHistogram("File Categories", r.Categories)
+
+	console.End()
+}
+
+func (r *Rollup) printHistogram(title string, h Histogram) {
+	console.Section(title)
+	for i, label := range h.Labels {
+		console.Printf("  %s %12d %12s  %5.1f%%\n", i18n.Pad(i18n.T(label), report.LabelWidth(30)), h.Files[i], FormatFileSize(h.Bytes[i]), percent(h.Files[i], r.Files))
+	}
+}
+
+func percent(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
+
+// WriteCSV writes the per-share table as CSV, raw and human-readable columns side by side
+func (r *Rollup) WriteCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create rollup report: %w", err)
+	}
+	w := csv.NewWriter(f)
+	_ = w.Write([]string{"job_id", "path", "files", "dirs", "bytes", "bytes_human", "start_time", "status"})
+	for _, share := range r.Shares {
+		status := "succeeded"
+		if share.Error != "" {
+			status = "failed: " + share.Error
+		}
+		_ = w.Write([]string{share.JobID, share.Path,
+			strconv.FormatInt(share.Files, 10), strconv.FormatInt(share.Dirs, 10),
+			strconv.FormatInt(share.Bytes, 10), FormatFileSize(share.Bytes),
+			i18n.FormatTime(share.StartTime), status})
+	}
+	_ = w.Write([]string{"total", "", strconv.FormatInt(r.Files, 10), strconv.FormatInt(r.Dirs, 10),
+		strconv.FormatInt(r.Bytes, 10), FormatFileSize(r.Bytes), "", ""})
+	w.Flush()
+	err = w.Error()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write rollup report: %w", err)
+	}
+	return nil
+}
+
+var rollupHTML = template.Must(template.New("rollup").Funcs(htmlFuncs).Funcs(template.FuncMap{
+	"percent": percent,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>terrasync rollup</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; min-width: 30em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: left; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+th { background: #f2f2f2; }
+.failed { color: #b00; }
+.bar { background: #4a90d9; height: 0.8em; }
+</style>
+</head>
+<body>
+<h1>{{t "Rollup Statistics"}}</h1>
+<table>
+<tr><th>{{t "Jobs"}}</th><td class="num">{{len .Shares}}</td></tr>
+<tr><th>{{t "Failed jobs"}}</th><td class="num">{{.Failed}}</td></tr>
+<tr><th>{{t "Files"}}</th><td class="num">{{.Files}}</td></tr>
+<tr><th>{{t "Directories"}}</th><td class="num">{{.Dirs}}</td></tr>
+<tr><th>{{t "Total"}}</th><td class="num">{{size .Bytes}}</td></tr>
+</table>
+
+<h2>{{t "Shares"}}</h2>
+<table>
+<tr><th>{{t "Job ID"}}</th><th>{{t "Path"}}</th><th>{{t "Files"}}</th><th>{{t "Directories"}}</th><th>{{t "Total"}}</th><th>{{t "Start time"}}</th><th>{{t "Status"}}</th></tr>
+{{- range .Shares}}
+<tr><td>{{.JobID}}</td><td>{{.Path}}</td><td class="num">{{.Files}}</td><td class="num">{{.Dirs}}</td><td class="num">{{size .Bytes}}</td><td>{{time .StartTime}}</td>{{if .Error}}<td class="failed">{{.Error}}</td>{{else}}<td>{{t "Succeeded"}}</td>{{end}}</tr>
+{{- end}}
+</table>
+{{- $files := .Files}}
+{{- range $title, $h := .Histograms}}
+
+<h2>{{t $title}}</h2>
+<table>
+{{- range $i, $label := $h.Labels}}
+<tr><th>{{t $label}}</th><td class="num">{{index $h.Files $i}}</td><td class="num">{{size (index $h.Bytes $i)}}</td><td style="width:12em"><div class="bar" style="width:{{printf "%.1f" (percent (index $h.Files $i) $files)}}%"></div></td></tr>
+{{- end}}
+</table>
+{{- end}}
+</body>
+</html>
+`))
+
+// WriteHTML renders the rollup as a standalone HTML page
+func (r *Rollup) WriteHTML(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create rollup report: %w", err)
+	}
+	err = rollupHTML.Execute(f, struct {
+		*Rollup
+		Histograms map[string]Histogram
+	}{r, map[string]Histogram{"File Age": r.Ages, "File Categories": r.Categories, "File Size": r.Sizes}})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write rollup report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package scan
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// saveRollupJob 保存一个包含给定大小文件的扫描任务
+func saveRollupJob(t *testing.T, name string, sizes []int, jobErr string) string {
+	ctx := context.Background()
+	jobDir := t.TempDir()
+
+	storage, err := object.CreateStorage("mem://rollup-test-" + name)
+	assert.NoError(t, err)
+	stats := NewStats()
+	var entries []object.FileInfo
+	for i, size := range sizes {
+		key := fmt.Sprintf("/f%d", i)
+		assert.NoError(t, storage.Put(key, strings.NewReader(strings.Repeat("x", size))))
+		fi, err := storage.Head(key)
+		assert.NoError(t, err)
+		entries = append(entries, fi)
+		stats.Update(fi)
+	}
+
+	dbInstance, err := InitDatabase(ctx, "sqlite", jobDir)
+	assert.NoError(t, err)
+	assert.NoError(t, (*dbInstance).SaveEntries(ctx, entries, ""))
+	assert.NoError(t, (*dbInstance).Close())
+
+	assert.NoError(t, SaveJobSummary(jobDir, JobSummary{
+		JobID:     "Job_" + name + "_scan",
+		Path:      "/mnt/" + name,
+		DbType:    "sqlite",
+		StartTime: time.Now().UTC(),
+		EndTime:   time.Now().UTC(),
+		Error:     jobErr,
+		Stats:     stats.Snapshot(),
+	}))
+	return jobDir
+}
+
+// TestBuildRollup 测试多个扫描任务的汇总
+func TestBuildRollup(t *testing.T) {
+	share1 := saveRollupJob(t, "share1", []int{10, 5000}, "")
+	share2 := saveRollupJob(t, "share2", []int{100000}, "1 database batches failed")
+
+	rollup, err := BuildRollup(context.Background(), []string{share1, share2})
+	assert.NoError(t, err)
+	assert.Len(t, rollup.Shares, 2)
+	assert.Equal(t, int64(3), rollup.Files)
+	assert.Equal(t, int64(105010), rollup.Bytes)
+	assert.Equal(t, 1, rollup.Failed)
+	assert.Equal(t, "/mnt/share1", rollup.Shares[0].Path)
+
+	// 直方图合并了所有任务的文件
+	assert.Equal(t, []int64{1, 1, 1, 0, 0, 0, 0}, rollup.Sizes.Files)
+	assert.Equal(t, int64(5000), rollup.Sizes.Bytes[1])
+	assert.Equal(t, int64(3), rollup.Ages.Files[0])
+	assert.Equal(t, int64(3), rollup.Categories.Files[otherCategory])
+
+	rollup.Print()
+
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "rollup.csv")
+	assert.NoError(t, rollup.WriteCSV(csvPath))
+	f, err := os.Open(csvPath)
+	assert.NoError(t, err)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 4)
+	assert.Equal(t, []string{"Job_share1_scan", "/mnt/share1", "2", "0", "5010"}, records[1][:5])
+	assert.Equal(t, "failed: 1 database batches failed", records[2][7])
+	assert.Equal(t, []string{"total", "", "3"}, records[3][:3])
+
+	htmlPath := filepath.Join(dir, "rollup.html")
+	assert.NoError(t, rollup.WriteHTML(htmlPath))
+	html, err := os.ReadFile(htmlPath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(html), "/mnt/share2")
+	assert.Contains(t, string(html), "64 KiB - 1 MiB")
+
+	// 缺少摘要的任务不能汇总
+	_, err = BuildRollup(context.Background(), []string{share1, t.TempDir()})
+	assert.Error(t, err)
+}
@@ -0,0 +1,81 @@
+package scan
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SampleOutput limits the "Found" lines printed for the scanned entries, so the
+// console of a huge scan stays informative without printing every path
+type SampleOutput struct {
+	Every  int64 // 每第N个条目打印一行，<=1打印全部
+	PerSec int   // 每秒最多打印的行数，0不限制
+	Off    bool  // 不打印任何条目
+}
+
+// ParseSampleOutput parses N (every Nth entry), N/s (at most N lines per
+// second) or 0 (no entry), empty prints every entry
+func ParseSampleOutput(s string) (SampleOutput, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return SampleOutput{}, nil
+	}
+	number, perSec := strings.CutSuffix(s, "/s")
+	n, err := strconv.ParseInt(strings.TrimSpace(number), 10, 32)
+	if err != nil || n < 0 {
+		return SampleOutput{}, fmt.Errorf("invalid sample output %q, expect N (every Nth file), N/s (lines per second) or 0", s)
+	}
+	switch {
+	case n == 0:
+		return SampleOutput{Off: true}, nil
+	case perSec:
+		return SampleOutput{PerSec: int(n)}, nil
+	default:
+		return SampleOutput{Every: n}, nil
+	}
+}
+
+func (o SampleOutput) String() string {
+	switch {
+	case o.Off:
+		return "0"
+	case o.PerSec > 0:
+		return fmt.Sprintf("%d/s", o.PerSec)
+	case o.Every > 1:
+		return strconv.FormatInt(o.Every, 10)
+	}
+	return "1"
+}
+
+// outputSampler decides which entries are printed, it is used by the single
+// goroutine reading the scanned entries
+type outputSampler struct {
+	SampleOutput
+	seen    int64
+	printed int64
+	second  time.Time // 当前计数的一秒的开始
+	lines   int       // 当前一秒内已打印的行数
+}
+
+// Allow counts an entry and reports whether it is printed
+func (s *outputSampler) Allow(now time.Time) bool {
+	s.seen++
+	switch {
+	case s.Off:
+		return false
+	case s.PerSec > 0:
+		if now.Sub(s.second) >= time.Second {
+			s.second, s.lines = now, 0
+		}
+		if s.lines >= s.PerSec {
+			return false
+		}
+		s.lines++
+	case s.Every > 1 && (s.seen-1)%s.Every != 0:
+		return false
+	}
+	s.printed++
+	return true
+}
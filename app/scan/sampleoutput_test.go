@@ -0,0 +1,60 @@
+package scan
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseSampleOutput 测试解析--sample-output
+func TestParseSampleOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    SampleOutput
+		wantErr bool
+	}{
+		{"默认打印全部", "", SampleOutput{}, false},
+		{"每个条目", "1", SampleOutput{Every: 1}, false},
+		{"每第N个", "1000", SampleOutput{Every: 1000}, false},
+		{"每秒N行", "20/s", SampleOutput{PerSec: 20}, false},
+		{"不打印", "0", SampleOutput{Off: true}, false},
+		{"负数", "-1", SampleOutput{}, true},
+		{"无效单位", "20/m", SampleOutput{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSampleOutput(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestOutputSampler 测试按间隔及每秒行数抽样打印
+func TestOutputSampler(t *testing.T) {
+	start := time.Now()
+	count := func(s *outputSampler, n int, step time.Duration) int {
+		printed := 0
+		for i := 0; i < n; i++ {
+			if s.Allow(start.Add(time.Duration(i) * step)) {
+				printed++
+			}
+		}
+		return printed
+	}
+
+	assert.Equal(t, 10, count(&outputSampler{}, 10, 0))
+	assert.Equal(t, 4, count(&outputSampler{SampleOutput: SampleOutput{Every: 3}}, 10, 0))
+	assert.Equal(t, 0, count(&outputSampler{SampleOutput: SampleOutput{Off: true}}, 10, 0))
+	// 3秒内每10ms一个条目，每秒最多5行
+	s := &outputSampler{SampleOutput: SampleOutput{PerSec: 5}}
+	assert.Equal(t, 15, count(s, 300, 10*time.Millisecond))
+	assert.Equal(t, int64(300), s.seen)
+	assert.Equal(t, int64(15), s.printed)
+}
@@ -1,24 +1,31 @@
 package scan
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
-	"strings"
 	"sync"
 	"sync/atomic"
+	"terrasync/changelist"
 	"terrasync/db"
+	"terrasync/heartbeat"
+	"terrasync/i18n"
 	"terrasync/log"
 	"terrasync/object"
+	"terrasync/pkg/stats"
+	"terrasync/processor"
+	"terrasync/security"
+	"terrasync/sharedset"
+	"terrasync/tuner"
 	"time"
 
 	"github.com/bits-and-blooms/bloom/v3"
-	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
 )
 
-const (
-	listQueueLen    = 8192
-	listDirQueueLen = 1024
-)
+const listQueueLen = 8192
 
 // ScanConfig 扫描配置选项
 type ScanConfig struct {
@@ -32,9 +39,43 @@ type ScanConfig struct {
 	Match           []string
 	Exclude         []string
 	Timeout         time.Duration // 扫描超时时间
+	// HugeDirThreshold 单个目录条目数超过该值时在报告中告警，<=0 使用默认值
+	HugeDirThreshold int64
+	AutoTune         bool                 // 根据吞吐和延迟自动调整并发数
+	AutoTuneMin      int                  // 自动调整的最小并发数，<=0 使用存储类型默认值
+	AutoTuneMax      int                  // 自动调整的最大并发数，<=0 使用存储类型默认值
+	Pipeline         *processor.Pipeline  // 用户自定义处理器(跳过/变换/路由)
+	Heartbeat        heartbeat.Config     // 周期心跳及卡顿检测
+	TwoPhase         bool                 // 先完整列举并保存快照，再处理冻结的列举结果
+	CompressSample   float64              // 采样估算压缩率的文件比例(0~1]，0表示不采样
+	Partition        *Partition           // 分布式扫描中本节点负责的分区，nil表示扫描整个目录树
+	ReadOnly         bool                 // 在存储层拒绝对扫描目录的任何写入及删除
+	Resume           bool                 // 从中断的两阶段扫描的列举快照继续，跳过已送达各sink的条目
+	Visited          sharedset.Set        // 分布式扫描中各节点共享的已列举目录，可为nil
+	PruneUnchanged   bool                 // 增量扫描不再深入mtime及条目数与上次扫描相同的目录
+	ChangeList       changelist.Source    // 增量扫描从厂商的变更列表读取变化的条目，不遍历目录树，可为nil
+	Progress         stats.ProgressConfig // 周期输出的机器可读进度记录，Writer为nil时不输出
+	Top              int                  // 报告最大的N个文件及文件数、字节数最多的N个目录，0表示不排名
+}
+
+// ListOptions 列举选项
+type ListOptions struct {
+	Concurrency int                          // 并发worker数量
+	Depth       int                          // 最大深度，<=0 表示不限制
+	Match       *ConditionFilter             // 匹配条件，可为nil
+	Exclude     *ConditionFilter             // 排除条件，可为nil
+	Stats       *Stats                       // 记录目录条目数，可为nil
+	Controller  *tuner.Controller            // 自动调整并发，可为nil
+	Pipeline    *processor.Pipeline          // 在过滤之后执行的处理器，可为nil
+	Monitor     *heartbeat.Monitor           // 记录列举进度并检测卡住的目录，可为nil
+	Partition   *Partition                   // 只列举根目录下属于该分区的条目，可为nil
+	OnError     func(path string, err error) // 目录或条目扫描失败时调用(错误已记录日志)，可为nil
+	Visited     sharedset.Set                // 分布式运行中各节点共享的已列举目录，其他节点列举过的子目录被跳过，可为nil
+	Baseline    *DirBaseline                 // 上次扫描的目录，mtime及条目数未变的目录不再深入，可为nil
 }
 
-func Start(scanConfig ScanConfig, reportConfig ReportConfig) error {
+// Start 执行扫描任务，任何数据库错误都会导致任务失败并返回错误
+func Start(ctx context.Context, scanConfig ScanConfig, reportConfig ReportConfig) error {
 	// 设置默认并发数和超时时间
 	if scanConfig.Concurrency <= 0 {
 		scanConfig.Concurrency = 5
@@ -45,6 +86,10 @@ func Start(scanConfig ScanConfig, reportConfig ReportConfig) error {
 		return fmt.Errorf("failed to create storage: %w", err)
 	}
 	defer storage.Close()
+	if scanConfig.ReadOnly {
+		storage = object.ReadOnly(storage)
+		log.Infof("Storage %s opened read-only", scanConfig.Path)
+	}
 
 	// Create match conditions filter
 	matchConditions, err := NewConditionFilter(scanConfig.Match)
@@ -59,118 +104,394 @@ func Start(scanConfig ScanConfig, reportConfig ReportConfig) error {
 	}
 
 	GenerateConsoleReportTitle(reportConfig)
+	if reportConfig.CryptoMode == "" {
+		reportConfig.CryptoMode = security.Mode()
+	}
+
+	progressConfig := scanConfig.Progress
+	if reportConfig.ProgressLine {
+		progressConfig.Print = func(r stats.ProgressRecord) { printProgress(reportConfig, r) }
+	}
+
+	// 创建统计信息实例
+	stats := NewStats()
+	stats.SetHugeDirThreshold(scanConfig.HugeDirThreshold)
+	stats.SetTop(scanConfig.Top)
+	progress := stats.StartProgress(progressConfig)
+	defer progress.Stop()
+
+	// 自动调整并发数时，按存储类型选择默认调整参数
+	var controller *tuner.Controller
+	if scanConfig.AutoTune {
+		tuneConfig := tuner.DefaultConfig(object.StorageType(scanConfig.Path))
+		if scanConfig.AutoTuneMin > 0 {
+			tuneConfig.Min = scanConfig.AutoTuneMin
+		}
+		if scanConfig.AutoTuneMax > 0 {
+			tuneConfig.Max = scanConfig.AutoTuneMax
+		}
+		controller = tuner.NewController(tuneConfig)
+		controller.Start()
+		defer controller.Stop()
+	}
+
+	// 心跳写入日志和任务数据库，列举卡住时告警
+	monitor, stopMonitor, err := startMonitor(scanConfig, storage)
+	if err != nil {
+		return err
+	}
+	defer stopMonitor()
+
+	// 全量扫描按事件类型发送到Kafka，Kafka不可用时只记录日志，扫描继续
+	var publisher *EventPublisher
+	if !scanConfig.IncrementalScan {
+		if publisher, err = NewEventPublisher(reportConfig.KafkaConfig, reportConfig.JobID); err != nil {
+			log.Errorf("%v", err)
+		}
+		defer publisher.Close()
+	}
+
+	// 继续中断的扫描时处理之前冻结的列举结果，不再列举
+	if scanConfig.Resume {
+		scannedChan, err := ResumeListing(ctx, scanConfig, storage)
+		if err != nil {
+			return err
+		}
+		if err := ProcessFilesForFullScan(ctx, scanConfig, scannedChan, reportConfig, stats, publisher); err != nil {
+			return fmt.Errorf("failed to process files: %w", err)
+		}
+		return nil
+	}
+
+	// 增量扫描从变更列表读取变化的条目，不遍历目录树
+	var cursor *changeCursor
+	if scanConfig.ChangeList != nil && scanConfig.IncrementalScan {
+		var since bool
+		if cursor, since, err = positionChangeList(ctx, scanConfig); err != nil {
+			return err
+		}
+		if since {
+			scannedChan, errc := ChangeListing(ctx, scanConfig.ChangeList, storage, ListOptions{Match: matchConditions, Exclude: excludeConditions})
+			if _, _, err := ProcessFilesForIncrementalScan(ctx, scanConfig, scannedChan, reportConfig); err != nil {
+				return fmt.Errorf("failed to process files: %w", err)
+			}
+			if err := <-errc; err != nil {
+				return fmt.Errorf("failed to read change list: %w", err)
+			}
+			return cursor.save(ctx, scanConfig)
+		}
+		// 没有上次运行的位置时遍历目录树，下次从本次开始时的位置读取
+		printNotice(reportConfig, i18n.T("No change list position of %s saved by a previous run, walking the tree\n"), cursor.name)
+	}
+
+	baseline, err := loadPruneBaseline(ctx, scanConfig, storage)
+	if err != nil {
+		return err
+	}
 
 	// 开始扫描并应用过滤
-	scannedChan := ListAll(storage, scanConfig.Concurrency, scanConfig.Depth, matchConditions, excludeConditions)
+	scannedChan := ListAll(ctx, storage, ListOptions{
+		Concurrency: scanConfig.Concurrency,
+		Depth:       scanConfig.Depth,
+		Match:       matchConditions,
+		Exclude:     excludeConditions,
+		Stats:       stats,
+		Controller:  controller,
+		Pipeline:    scanConfig.Pipeline,
+		Monitor:     monitor,
+		Partition:   scanConfig.Partition,
+		OnError:     publisher.PublishError,
+		Visited:     scanConfig.Visited,
+		Baseline:    baseline,
+	})
+	if scanConfig.TwoPhase {
+		if scannedChan, err = SnapshotListing(ctx, scanConfig, storage, scannedChan); err != nil {
+			return fmt.Errorf("failed to snapshot listing: %w", err)
+		}
+	}
 
 	if scanConfig.IncrementalScan {
 		// 增量扫描场景,处理文件统计信息
-		ProcessFilesForIncrementalScan(scanConfig, scannedChan, reportConfig)
+		if _, _, err := ProcessFilesForIncrementalScan(ctx, scanConfig, scannedChan, reportConfig); err != nil {
+			return fmt.Errorf("failed to process files: %w", err)
+		}
+		if pruned := stats.GetPrunedDirCount(); pruned > 0 {
+			printNotice(reportConfig, i18n.T("Pruned %d directories unchanged since the last scan, files modified in place below them are not detected\n"), pruned)
+		}
 	} else {
 		// 全量扫描场景,处理文件统计信息
-		if err := ProcessFilesForFullScan(scanConfig, scannedChan, reportConfig); err != nil {
+		if err := ProcessFilesForFullScan(ctx, scanConfig, scannedChan, reportConfig, stats, publisher); err != nil {
 			return fmt.Errorf("failed to process files: %w", err)
 		}
 	}
 
-	return nil
+	return cursor.save(ctx, scanConfig)
 }
 
-// ListAll recursively lists all files and directories in the given storage starting
-// with the specified concurrency level
-// ListAll recursively lists all files and directories in the given storage starting
-// with the specified concurrency level and depth limit
-func ListAll(storage object.Storage, concurrency int, depth int, matchConditions, excludeConditions *ConditionFilter) <-chan object.FileInfo {
-	// 定义包含路径和深度信息的结构体
+// ListAll recursively lists all files and directories in the given storage, see
+// Listing. Errors of directories and entries are logged and reported to
+// opts.OnError, the traversal skips them.
+func ListAll(ctx context.Context, storage object.Storage, opts ListOptions) <-chan object.FileInfo {
+	results, _ := Listing(ctx, storage, opts)
+	return results
+}
 
-	type dirInfo struct {
-		path  string
-		depth int
-	}
+// Listing recursively lists all files and directories in the given storage starting
+// with the specified concurrency level and depth limit.
+// Subdirectories are pushed to an unbounded queue as soon as they are discovered and
+// listed by a fixed pool of workers, so neither wide nor deep trees block a worker or
+// grow the number of goroutines.
+// When a controller is set, the number of directories listed concurrently follows
+// its limit and concurrency only sets a floor for the number of workers.
+// Entries passing the filters go through the processor pipeline, which may skip,
+// rename or route them. When a monitor is set, every directory listing is tracked as
+// an operation, and a listing aborted by the monitor after stalling is logged and skipped.
+// When a baseline is set, a directory whose mtime and number of entries are unchanged
+// is listed but none of its entries are returned or descended into.
+// Cancelling ctx stops the traversal and closes the returned channel. The error
+// channel receives, once the traversal ended, the error of ctx when it was cancelled,
+// a *ListError when directories or entries failed and were skipped, or nil.
+func Listing(ctx context.Context, storage object.Storage, opts ListOptions) (<-chan object.FileInfo, <-chan error) {
+	concurrency, depth := opts.Concurrency, opts.Depth
+	matchConditions, excludeConditions := opts.Match, opts.Exclude
+	stats, controller := opts.Stats, opts.Controller
 
-	dirs := make(chan dirInfo, listDirQueueLen)
 	results := make(chan object.FileInfo, listQueueLen)
-	var wg sync.WaitGroup
-	var pending int64
+	errc := make(chan error, 1)
+	queue := newDirQueue()
+	g, ctx := errgroup.WithContext(ctx)
+	stop := context.AfterFunc(ctx, queue.wake)
+	var visitedSkips, prunedDirs atomic.Int64
+
+	// scanError logs an error of a directory or entry and reports it to OnError
+	var failed atomic.Int64
+	var firstErr *ListError
+	var firstOnce sync.Once
+	scanError := func(path string, err error) {
+		log.Errorf("Scan error: %v", err)
+		if opts.OnError != nil {
+			opts.OnError(path, err)
+		}
+		failed.Add(1)
+		firstOnce.Do(func() { firstErr = &ListError{Path: path, Err: err} })
+	}
 
-	// list processes a single directory, sending files to results and subdirectories to dirs
+	// list processes a single directory, sending files to results and subdirectories to the queue
 	// currentDepth is the depth of the current directory relative to the root
-	list := func(dir string, currentDepth int) error {
-		// 检查深度限制
-		if depth > 0 && currentDepth > depth {
+	list := func(dir string, mtime time.Time, currentDepth int) error {
+		// 检查深度限制和任务取消
+		if (depth > 0 && currentDepth > depth) || ctx.Err() != nil {
 			return nil
 		}
+		// 根目录由每个节点按分区列举，子目录只由第一个领取它的节点列举
+		if opts.Visited != nil && currentDepth > 1 {
+			if claimed, _ := opts.Visited.Add(ctx, dir); !claimed {
+				visitedSkips.Add(1)
+				log.Debugf("Skipping %s, listed by another node", dir)
+				return nil
+			}
+		}
 
-		queue, err := storage.List(dir)
+		op := opts.Monitor.Begin("Listing " + dir)
+		defer opts.Monitor.End(op)
+
+		listStart := time.Now()
+		entriesChan, err := listDir(storage, dir, op)
+		if err == nil && entriesChan == nil {
+			err = fmt.Errorf("no listing returned for %s", dir)
+		}
 		if err != nil {
+			if controller != nil {
+				controller.Observe(0, time.Since(listStart), err)
+			}
 			return fmt.Errorf("storage list failed: %w", err)
 		}
+		listLatency := time.Since(listStart)
 
-		var subdirs []dirInfo
-		for o := range queue {
+		// 本目录中(不含子目录)扫描到的文件数及字节数
+		var dirFiles, dirBytes int64
+		defer func() {
+			if stats != nil && dirFiles > 0 {
+				stats.RecordDirUsage(dir, dirFiles, dirBytes)
+			}
+		}()
+
+		// handle filters an entry, sends it to results and queues its subdirectory,
+		// false when the job is cancelled
+		handle := func(o object.FileInfo) bool {
+			// 根目录下属于其他分区的条目由其他节点扫描
+			if currentDepth == 1 && !opts.Partition.Contains(o.Key()) {
+				return true
+			}
 			// Apply match and exclude filters
 			// 当matchConditions为空时默认匹配，excludeConditions为空时默认不匹配
-			matchOk := len(matchConditions.conditions) == 0 || matchConditions.IsSatisfied(o)
-			excludeOk := len(excludeConditions.conditions) > 0 && excludeConditions.IsSatisfied(o)
+			matchOk := matchConditions.Empty() || matchConditions.IsSatisfied(o)
+			excludeOk := !excludeConditions.Empty() && excludeConditions.IsSatisfied(o)
 			if matchOk && !excludeOk {
-				results <- o
+				// 用户处理器可以跳过、重命名或路由条目，跳过的目录仍然会被遍历
+				processed, keep, err := opts.Pipeline.Apply(o)
+				if err != nil {
+					scanError(o.Key(), err)
+				}
+				if stats != nil {
+					stats.RecordProcessed(processed, keep)
+				}
+				if keep {
+					select {
+					case results <- processed:
+					case <-ctx.Done():
+						return false
+					}
+					if !processed.IsDir() {
+						dirFiles++
+						dirBytes += processed.Size()
+					}
+				}
 			}
-			if o.IsDir() {
-				subdirs = append(subdirs, dirInfo{path: o.Key(), depth: currentDepth + 1})
+			if o.IsDir() && (depth <= 0 || currentDepth+1 <= depth) {
+				// 当前目录尚未完成，队列中的目录数不会在此之前归零
+				queue.push(dirTask{path: o.Key(), mtime: o.MTime(), depth: currentDepth + 1})
 			}
+			return true
 		}
 
-		// Add subdirectories to the queue
-		if len(subdirs) > 0 {
-			atomic.AddInt64(&pending, int64(len(subdirs)))
+		// drain reads the remaining entries in the background to release the
+		// listing goroutine of the storage
+		drain := func() {
 			go func() {
-				for _, d := range subdirs {
-					dirs <- d
+				for range entriesChan {
 				}
 			}()
 		}
 
+		// mtime与基线相同的目录先缓存条目，条目数也相同时整个目录不再深入
+		baselineEntries, prunable := opts.Baseline.unchanged(dir, mtime)
+		var held []object.FileInfo
+
+		var entries int64
+		for {
+			var o object.FileInfo
+			var ok bool
+			select {
+			case o, ok = <-entriesChan:
+			case <-op.Aborted():
+				drain()
+				return fmt.Errorf("listing %s aborted: %w", dir, errStalled)
+			case <-ctx.Done():
+				drain()
+				return ctx.Err()
+			}
+			if !ok {
+				break
+			}
+			entries++
+			if o.IsDir() {
+				opts.Monitor.Progress(op, 1, 0)
+			} else {
+				opts.Monitor.Progress(op, 1, o.Size())
+			}
+			batch := []object.FileInfo{o}
+			if prunable {
+				if entries <= baselineEntries {
+					held = append(held, o)
+					continue
+				}
+				// 条目比基线多，目录已变化
+				prunable = false
+				batch, held = append(held, o), nil
+			}
+			for _, o := range batch {
+				if !handle(o) {
+					drain()
+					return ctx.Err()
+				}
+			}
+		}
+
+		if stats != nil {
+			stats.RecordDirEntries(dir, entries)
+		}
+		if controller != nil {
+			controller.Observe(entries, listLatency, nil)
+		}
+
+		if prunable && entries == baselineEntries {
+			prunedDirs.Add(1)
+			if stats != nil {
+				stats.RecordPrunedDir()
+			}
+			log.Debugf("Pruning %s, unchanged since the baseline", dir)
+			return nil
+		}
+		// 条目比基线少，目录已变化
+		for _, o := range held {
+			if !handle(o) {
+				return nil
+			}
+		}
+
 		return nil
 	}
 
-	// worker processes directories from the dirs channel
-	worker := func() {
-		defer wg.Done()
-		for dirInfo := range dirs {
-			if err := list(dirInfo.path, dirInfo.depth); err != nil {
-				log.Errorf("Scan error: %v", err)
+	// worker lists the directories of the queue until all of them are listed
+	worker := func() error {
+		for {
+			d, ok := queue.pop(ctx)
+			if !ok {
+				return ctx.Err()
 			}
-
-			// Decrement pending count and close dirs channel if all done
-			if atomic.AddInt64(&pending, -1) == 0 {
-				close(dirs)
+			if controller != nil {
+				controller.Acquire()
+			}
+			err := list(d.path, d.mtime, d.depth)
+			// 任务取消不是列举错误，由Wait返回
+			cancelled := err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err())
+			if err != nil && !cancelled {
+				scanError(d.path, err)
+			}
+			if controller != nil {
+				controller.Release()
+			}
+			queue.done()
+			if cancelled {
+				return err
 			}
 		}
 	}
 
-	// Start worker goroutines
-	for i := 0; i < concurrency; i++ {
-		wg.Add(1)
-		go worker()
+	if controller != nil && controller.Max() > concurrency {
+		concurrency = controller.Max()
+	}
+	queue.push(dirTask{path: "/", depth: 1})
+	for i := 0; i < max(concurrency, 1); i++ {
+		g.Go(worker)
 	}
 
-	// Add the initial directory to the queue
-	atomic.AddInt64(&pending, 1)
-	dirs <- dirInfo{path: "/", depth: 1}
-
-	// Start a goroutine to close channels when done
+	// Close the results once every worker returned
 	go func() {
-		wg.Wait()
+		err := g.Wait()
+		stop()
+		if skips := visitedSkips.Load(); skips > 0 {
+			log.Infof("Skipped %d directories already listed by other nodes", skips)
+		}
+		if pruned := prunedDirs.Load(); pruned > 0 {
+			log.Infof("Pruned %d directories unchanged since the baseline", pruned)
+		}
+		if err == nil && firstErr != nil {
+			firstErr.Failed = failed.Load()
+			err = firstErr
+		}
+		errc <- err
 		close(results)
 	}()
 
-	return results
+	return results, errc
 }
 
-// ProcessFilesForFullScan 处理文件统计信息并分发到数据库和Kafka
-func ProcessFilesForFullScan(scanConfig ScanConfig, scannedChan <-chan object.FileInfo, reportConfig ReportConfig) error {
+// ProcessFilesForFullScan 处理文件统计信息并分发到数据库和Kafka，publisher为nil时不发送Kafka
+func ProcessFilesForFullScan(ctx context.Context, scanConfig ScanConfig, scannedChan <-chan object.FileInfo, reportConfig ReportConfig, stats *Stats, publisher *EventPublisher) error {
 	// Initialize database
-	dbInstance, err := InitDatabase(scanConfig.DbType, scanConfig.JobDir)
+	dbInstance, err := InitDatabase(ctx, scanConfig.DbType, scanConfig.JobDir)
 	if err != nil {
 		log.Errorf("Failed to initialize database: %v", err)
 		return fmt.Errorf("failed to initialize database: %w", err)
@@ -184,155 +505,294 @@ func ProcessFilesForFullScan(scanConfig ScanConfig, scannedChan <-chan object.Fi
 		}
 	}()
 
-	// 初始化Kafka生产者
-	var kafkaProducer *KafkaProducer
-	if reportConfig.KafkaConfig.Enabled {
-		kafkaProducer, err = InitKafkaProducer(reportConfig.KafkaConfig)
+	// 中断时列举停止，已扫描的条目仍写入各sink，摘要照常保存
+	flushCtx := context.WithoutCancel(ctx)
+
+	// 数据库和Kafka各自有队列，数据库不能丢失条目，队列满时阻塞扫描
+	sinks := []SinkConfig{{
+		Sink:       &dbSink{db: dbInstance},
+		QueueLen:   scanConfig.DBBatchSize,
+		MaxBatch:   scanConfig.DBBatchSize,
+		MaxLatency: dbFlushInterval,
+		Overflow:   OverflowBlock,
+	}}
+	if publisher.Enabled(EventFiles) || publisher.Enabled(EventDirectories) {
+		sinks = append(sinks, SinkConfig{
+			Sink:      &kafkaSink{publisher: publisher},
+			QueueLen:  reportConfig.KafkaConfig.Concurrency,
+			Workers:   reportConfig.KafkaConfig.Concurrency,
+			Overflow:  reportConfig.KafkaConfig.Overflow,
+			SpoolPath: filepath.Join(scanConfig.JobDir, "kafka.spool"),
+		})
+	}
+	if reportConfig.Webhook.Enabled() {
+		webhook := newWebhookSink(reportConfig.Webhook, reportConfig.JobID)
+		sinks = append(sinks, webhook.sinkConfig(filepath.Join(scanConfig.JobDir, "webhook.spool")))
+	}
+
+	// ClickHouse不可用时只记录日志，扫描继续
+	if reportConfig.ClickHouse.Enabled() {
+		if clickHouse, err := newClickHouseSink(ctx, reportConfig.ClickHouse, reportConfig.JobID); err != nil {
+			log.Errorf("%v", err)
+		} else {
+			sinks = append(sinks, clickHouse.sinkConfig())
+		}
+	}
+
+	// 两阶段扫描的条目顺序固定，记录各sink的送达高水位，中断后可以继续
+	tracked := scanConfig.TwoPhase || scanConfig.Resume
+	if scanConfig.Resume {
+		deliveries, err := (*dbInstance).ListDeliveries(ctx)
 		if err != nil {
-			kafkaProducer = nil
+			return err
 		}
-		if kafkaProducer != nil {
-			defer kafkaProducer.Close()
+		for i := range sinks {
+			sinks[i].Delivered = deliveries[sinks[i].Sink.Name()]
 		}
 	}
-
-	// 创建统计信息实例
-	stats := NewStats()
-
-	// 创建两个通道: 一个给数据库，一个给Kafka
-	// 数据库批量处理相关变量
-	batchSize := scanConfig.DBBatchSize
-	dbChan := make(chan object.FileInfo, batchSize)
-
-	var kafkaChan chan object.FileInfo
-	if kafkaProducer != nil {
-		kafkaChan = make(chan object.FileInfo, reportConfig.KafkaConfig.Concurrency)
+	dispatcher, err := NewDispatcher(flushCtx, sinks...)
+	if err != nil {
+		return err
+	}
+	if tracked {
+		if err := dispatcher.TrackDelivery(*dbInstance, deliveryCheckpointInterval); err != nil {
+			dispatcher.Close()
+			return err
+		}
 	}
 
-	// 启动数据库批量处理goroutine
-	var dbWg sync.WaitGroup
-	dbWg.Add(1)
-	go func() {
-		defer dbWg.Done()
-		var buffer []object.FileInfo
-		var totalSaved int // 统计总共保存的记录数
-		for fileInfo := range dbChan {
-			buffer = append(buffer, fileInfo)
-			bufferLen := len(buffer)
-			if bufferLen >= batchSize {
-				startTime := time.Now()
-				if err := (*dbInstance).SaveEntries(buffer, ""); err != nil {
-					log.Errorf("Failed to save batch: %v", err)
-				} else {
-					log.Debugf("Saved batch of %d entries in %v", bufferLen, time.Since(startTime))
-					totalSaved += bufferLen
-				}
-				buffer = make([]object.FileInfo, 0, batchSize)
-			}
+	// CSV报告写在任务目录中，写入失败不影响扫描
+	var csvWriter *csvReport
+	if reportConfig.CsvReport {
+		reportConfig.CsvPath = filepath.Join(scanConfig.JobDir, CSVReportName)
+		if csvWriter, err = newCSVReport(reportConfig.CsvPath, reportConfig.CsvDelimiter); err != nil {
+			log.Errorf("%v", err)
+			reportConfig.CsvPath = ""
 		}
-		// 处理剩余数据
-		bufferLen := len(buffer)
-		if bufferLen > 0 {
-			if err := (*dbInstance).SaveEntries(buffer, ""); err != nil {
-				log.Errorf("Failed to save final batch: %v", err)
-			} else {
-				log.Debugf("Saved final batch of %d entries", bufferLen)
-				totalSaved += bufferLen
-			}
+	}
+
+	// NDJSON报告同样写在任务目录中，扫描结束时追加摘要记录
+	var jsonWriter *jsonReport
+	if reportConfig.JSONReport {
+		reportConfig.JSONPath = filepath.Join(scanConfig.JobDir, JSONReportName)
+		if jsonWriter, err = newJSONReport(reportConfig.JSONPath); err != nil {
+			log.Errorf("%v", err)
+			reportConfig.JSONPath = ""
 		}
-		// 记录总共保存的记录数
-		log.Infof("Successfully saved total %d entries to database", totalSaved)
-	}()
+	}
 
-	// Kafka处理相关变量
-	var kafkaWg sync.WaitGroup
-
-	// 启动Kafka消费者goroutine
-	if kafkaProducer != nil {
-		kafkaWorkerPool := make(chan struct{}, reportConfig.KafkaConfig.Concurrency)
-		kafkaWg.Add(1)
-		go func() {
-			defer kafkaWg.Done()
-			for fileInfo := range kafkaChan {
-				kafkaWg.Add(1)
-				kafkaWorkerPool <- struct{}{}
-
-				go func(fi object.FileInfo) {
-					defer kafkaWg.Done()
-					defer func() { <-kafkaWorkerPool }()
-
-					kafkaStartTime := time.Now()
-					if err := kafkaProducer.SendMessage(reportConfig.KafkaConfig.Topic, fi); err != nil {
-						log.Errorf("Kafka error: %v", err)
-					} else {
-						log.Debugf("Sent message to Kafka topic %s in %v", reportConfig.KafkaConfig.Topic, time.Since(kafkaStartTime))
-					}
-				}(fileInfo)
-			}
-		}()
+	// 采样文件内容估算压缩率，读取失败只记录日志
+	var estimator *CompressionEstimator
+	if scanConfig.CompressSample > 0 {
+		if estimator, err = NewCompressionEstimator(scanConfig.CompressSample, scanConfig.Concurrency); err != nil {
+			log.Errorf("%v", err)
+		}
 	}
 
-	// 从fileChan读取数据并分发到两个通道
+	// 从fileChan读取数据并分发到各个sink
 	var fileWg sync.WaitGroup
 	fileWg.Add(1)
 	go func() {
 		defer fileWg.Done()
+		found := outputSampler{SampleOutput: reportConfig.SampleOutput}
 		for fileInfo := range scannedChan {
-			// 打印文件路径
+			// 按--sample-output打印文件路径
 			fileePath := filepath.Join(scanConfig.Path, fileInfo.Key())
-			if reportConfig.Quiet {
-				log.Infof("Found: %s\n", fileePath)
-			} else {
-				fmt.Printf("Found: %s\n", fileePath)
+			if found.Allow(time.Now()) {
+				if reportConfig.Quiet || reportConfig.Output == OutputJSON {
+					log.Infof("Found: %s\n", fileePath)
+				} else {
+					fmt.Print(i18n.Sprintf("Found: %s\n", fileePath))
+				}
 			}
-
-			// 分发到两个通道
-			dbChan <- fileInfo
-			if kafkaProducer != nil && reportConfig.KafkaConfig.Topic != "" {
-				kafkaChan <- fileInfo
+			if csvWriter != nil {
+				if err := csvWriter.Write(fileePath, fileInfo); err != nil {
+					log.Errorf("Failed to write CSV report: %v", err)
+				}
+			}
+			if jsonWriter != nil {
+				if err := jsonWriter.Write(fileePath, fileInfo); err != nil {
+					log.Errorf("Failed to write JSON report: %v", err)
+				}
 			}
 
+			dispatcher.Dispatch(fileInfo)
+
 			// 更新统计信息
 			stats.Update(fileInfo)
+			estimator.Add(fileInfo)
 		}
-
-		// 关闭通道，通知消费者goroutine结束
-		close(dbChan)
-		if kafkaProducer != nil {
-			close(kafkaChan)
+		if found.printed < found.seen {
+			log.Infof("Printed %d of %d found entries (sample output %s)", found.printed, found.seen, found.SampleOutput)
 		}
 	}()
 
-	// 等待所有goroutine完成
+	// 等待分发结束并写入队列中剩余的条目
 	fileWg.Wait()
-	dbWg.Wait()
-	if kafkaProducer != nil {
-		kafkaWg.Wait()
+	sinkStats := dispatcher.Close()
+	for _, st := range sinkStats {
+		log.Infof("Sink %s", st)
+	}
+	// 断开期间缓存的写入在保存摘要和状态前重放，重放失败时任务失败
+	flushErr := db.Flush(flushCtx, *dbInstance)
+	log.Infof("Successfully saved total %d entries to database", sinkStats[0].Written)
+
+	if estimator != nil {
+		stats.SetCompression(estimator.Close())
+	}
+	// 等待过文件描述符预算说明NOFILE限制偏低，并发受到了限制
+	if fds := object.CurrentFDBudget().Stats(); fds.Budget > 0 {
+		log.Infof("File descriptors: %s", fds)
+		if fds.Waits > 0 {
+			log.Warnf("%d opens waited for the file descriptor budget, raise the NOFILE limit (ulimit -n) to scan at full concurrency", fds.Waits)
+		}
+	}
+	var statCache *object.StatCacheStats
+	if cache := object.CurrentStatCache(); cache != nil {
+		st := cache.Stats()
+		statCache = &st
+		log.Infof("Stat cache: %s", st)
 	}
 
-	GenerateConsoleReportSummary(reportConfig, *stats, dbInstance)
+	// Kafka写入失败只记录日志，数据库保存失败时任务失败
+	var jobErr error
+	if st := sinkStats[0]; st.Err != nil {
+		jobErr = fmt.Errorf("%d database batches failed: %w", st.FailedBatches, st.Err)
+	} else if flushErr != nil {
+		jobErr = fmt.Errorf("failed to save pending database writes: %w", flushErr)
+	} else if ctx.Err() != nil {
+		jobErr = fmt.Errorf("interrupted after %d entries: %w", st.Written, ctx.Err())
+	}
+	// 继续的扫描重放高水位之后已保存的批次，同一路径可能保存了多行
+	if jobErr == nil && scanConfig.Resume {
+		if n, err := (*dbInstance).CountDuplicatePaths(flushCtx, ""); err != nil {
+			log.Warnf("%v", err)
+		} else if n > 0 {
+			log.Warnf("%d entries were saved more than once, incremental scans report their changes once per copy", n)
+		}
+	}
+	if csvWriter != nil {
+		if err := csvWriter.Close(); err != nil {
+			log.Errorf("Failed to close CSV report: %v", err)
+		}
+	}
 
-	return nil
+	reportConfig.EndTime = time.Now()
+	summary := JobSummary{
+		JobID:      reportConfig.JobID,
+		AppVersion: reportConfig.AppVersion,
+		CmdLine:    reportConfig.CmdLine,
+		Path:       scanConfig.Path,
+		Match:      scanConfig.Match,
+		Exclude:    scanConfig.Exclude,
+		Depth:      scanConfig.Depth,
+		DbType:     scanConfig.DbType,
+		CryptoMode: reportConfig.CryptoMode,
+		StartTime:  reportConfig.StartTime.UTC(),
+		EndTime:    reportConfig.EndTime.UTC(),
+		FileTypes:  fileTypeCount(flushCtx, dbInstance),
+		Extensions: extensionBreakdown(flushCtx, dbInstance),
+		Stats:      stats.Snapshot(),
+		Partition:  scanConfig.Partition,
+		ReadOnly:   scanConfig.ReadOnly,
+		StatCache:  statCache,
+	}
+	if jobErr != nil {
+		summary.Error = jobErr.Error()
+		summary.Interrupted = errors.Is(jobErr, context.Canceled)
+	}
+	// 保存任务摘要，以便之后用report命令重新生成报告
+	if err := SaveJobSummary(scanConfig.JobDir, summary); err != nil {
+		log.Errorf("%v", err)
+	}
+	if err := publisher.PublishSummary(&summary); err != nil {
+		log.Errorf("%v", err)
+	}
+	if reportConfig.CsvPath != "" {
+		if err := writeSummaryCSV(filepath.Join(scanConfig.JobDir, SummaryCSVName), reportConfig.CsvDelimiter, &summary); err != nil {
+			log.Errorf("%v", err)
+		}
+		if err := writeExtensionsCSV(filepath.Join(scanConfig.JobDir, ExtensionsCSVName), reportConfig.CsvDelimiter, &summary); err != nil {
+			log.Errorf("%v", err)
+		}
+	}
+	if jsonWriter != nil {
+		if err := jsonWriter.WriteSummary(&summary); err != nil {
+			log.Errorf("Failed to write JSON report: %v", err)
+		}
+		if err := jsonWriter.Close(); err != nil {
+			log.Errorf("Failed to close JSON report: %v", err)
+		}
+	}
+	if reportConfig.HtmlReport {
+		htmlPath := filepath.Join(scanConfig.JobDir, HTMLReportName)
+		if err := writeHTMLReport(flushCtx, htmlPath, &summary, dbInstance); err != nil {
+			log.Errorf("%v", err)
+		} else {
+			reportConfig.HtmlPath = htmlPath
+		}
+	}
+	if reportConfig.SignKey != nil {
+		if manifest, err := SignReports(scanConfig.JobDir, reportConfig.SignKey); err != nil {
+			log.Errorf("%v", err)
+		} else {
+			reportConfig.Manifest = manifest
+		}
+	}
+
+	if reportConfig.Output == OutputJSON {
+		if err := writeJSONSummary(os.Stdout, reportConfig, &summary); err != nil {
+			log.Errorf("%v", err)
+		}
+	} else {
+		GenerateConsoleReportSummary(reportConfig, stats, &summary, jobErr)
+	}
+
+	return jobErr
 }
 
 // ProcessFilesForIncrementalScan 处理文件统计信息并分发到数据库和Kafka
 // ProcessFilesForIncrementalScan 处理增量扫描的文件统计信息并分发到数据库
-func ProcessFilesForIncrementalScan(scanConfig ScanConfig, scannedChan <-chan object.FileInfo, reportConfig ReportConfig) (<-chan db.FileInfoData, <-chan db.FileInfoData, error) {
+func ProcessFilesForIncrementalScan(ctx context.Context, scanConfig ScanConfig, scannedChan <-chan object.FileInfo, reportConfig ReportConfig) (<-chan db.FileInfoData, <-chan db.FileInfoData, error) {
 	dbInstance, err := NewDB(scanConfig.DbType, scanConfig.JobDir)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create database instance: %w", err)
 	}
+	defer (*dbInstance).Close()
 
-	bloomNewFiles, candidateChan := bloomPreFilter(scannedChan, dbInstance)
+	bloomNewFiles, candidateChan, err := bloomPreFilter(ctx, scannedChan, dbInstance)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	tempTableName := "temp_files_" + strings.Replace(uuid.New().String(), "-", "_", -1)
+	tempTableName := newTempTableName(time.Now())
 
-	(*dbInstance).CreateTable(tempTableName)
-	loadCandidatesToTemp(candidateChan, dbInstance, tempTableName, scanConfig)
+	if err := (*dbInstance).CreateTable(ctx, tempTableName); err != nil {
+		return nil, nil, err
+	}
+	// 中断时也删除临时表，被强制结束的任务留下的临时表由cleanup命令删除
+	defer func() {
+		if err := (*dbInstance).DropTable(context.WithoutCancel(ctx), tempTableName); err != nil {
+			log.Warnf("Failed to drop temporary table: %v", err)
+		}
+	}()
+	if err := loadCandidatesToTemp(ctx, candidateChan, dbInstance, tempTableName, scanConfig); err != nil {
+		return nil, nil, err
+	}
+	// 中断的扫描只列举了部分条目，不能与上次扫描比较
+	if err := ctx.Err(); err != nil {
+		return nil, nil, fmt.Errorf("interrupted: %w", err)
+	}
 
 	// 阶段3：联合查询识别变更
-	exactNewFiles := (*dbInstance).QueryExactNewFiles(tempTableName)
-	changedFiles := (*dbInstance).QueryChangedFiles(tempTableName)
+	exactNewFiles, err := (*dbInstance).QueryExactNewFiles(ctx, tempTableName)
+	if err != nil {
+		return nil, nil, err
+	}
+	changedFiles, err := (*dbInstance).QueryChangedFiles(ctx, tempTableName)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// 创建通道
 	newFileChan := make(chan db.FileInfoData, len(bloomNewFiles)+len(exactNewFiles))
@@ -359,21 +819,28 @@ func ProcessFilesForIncrementalScan(scanConfig ScanConfig, scannedChan <-chan ob
 // bloomPreFilter 使用布隆过滤器预筛选文件路径
 // scannedChan: 扫描到的文件通道
 // dbInstance: 数据库实例
-// 返回值: [确认的新文件列表, 需要进一步验证的候选文件通道]
-func bloomPreFilter(scannedChan <-chan object.FileInfo, dbInstance *db.DB) ([]object.FileInfo, chan object.FileInfo) {
+// 返回值: [确认的新文件列表, 需要进一步验证的候选文件通道, 错误]
+func bloomPreFilter(ctx context.Context, scannedChan <-chan object.FileInfo, dbInstance *db.DB) ([]object.FileInfo, chan object.FileInfo, error) {
 	// 初始化布隆过滤器 (1亿数据，0.1%误判率约需143MB内存)
 	filter := bloom.NewWithEstimates(1e8, 0.001)
 
 	// 预热过滤器：加载数据库现有路径
-	rows, _ := (*dbInstance).Query("SELECT path FROM file_entries")
+	rows, err := (*dbInstance).Query(ctx, "SELECT path FROM file_entries")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load existing paths: %w", err)
+	}
 	for rows.Next() {
 		var path string
-		rows.Scan(&path)
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to read existing path: %w", err)
+		}
 		filter.AddString(path)
 	}
 	// 检查遍历过程中是否有错误
 	if err := rows.Err(); err != nil {
-		log.Errorf("Error reading rows: %v", err)
+		rows.Close()
+		return nil, nil, fmt.Errorf("error reading rows: %w", err)
 	}
 	rows.Close()
 
@@ -415,7 +882,7 @@ func bloomPreFilter(scannedChan <-chan object.FileInfo, dbInstance *db.DB) ([]ob
 	wg.Wait()
 	collectWg.Wait()
 
-	return newFiles, candidateChan
+	return newFiles, candidateChan, nil
 }
 
 // loadCandidatesToTemp 将候选文件加载到临时表中
@@ -424,7 +891,9 @@ func bloomPreFilter(scannedChan <-chan object.FileInfo, dbInstance *db.DB) ([]ob
 // dbInstance: 数据库实例
 // tableName: 临时表名称
 // scanConfig: 扫描配置
-func loadCandidatesToTemp(candidateChan <-chan object.FileInfo, dbInstance *db.DB, tableName string, scanConfig ScanConfig) {
+// 返回第一个保存失败的错误，剩余候选文件仍会被读取完
+func loadCandidatesToTemp(ctx context.Context, candidateChan <-chan object.FileInfo, dbInstance *db.DB, tableName string, scanConfig ScanConfig) error {
+	var saveErr error
 	var buffer []object.FileInfo
 	var totalSaved int // 统计总共保存的记录数
 	for fileInfo := range candidateChan {
@@ -432,8 +901,11 @@ func loadCandidatesToTemp(candidateChan <-chan object.FileInfo, dbInstance *db.D
 		bufferLen := len(buffer)
 		if bufferLen >= scanConfig.DBBatchSize {
 			startTime := time.Now()
-			if err := (*dbInstance).SaveEntries(buffer, tableName); err != nil {
+			if err := (*dbInstance).SaveEntries(ctx, buffer, tableName); err != nil {
 				log.Errorf("Failed to save batch: %v", err)
+				if saveErr == nil {
+					saveErr = err
+				}
 			} else {
 				log.Debugf("Saved batch of %d entries in %v", bufferLen, time.Since(startTime))
 				totalSaved += bufferLen
@@ -444,8 +916,11 @@ func loadCandidatesToTemp(candidateChan <-chan object.FileInfo, dbInstance *db.D
 	// 处理剩余数据
 	bufferLen := len(buffer)
 	if bufferLen > 0 {
-		if err := (*dbInstance).SaveEntries(buffer, tableName); err != nil {
+		if err := (*dbInstance).SaveEntries(ctx, buffer, tableName); err != nil {
 			log.Errorf("Failed to save final batch: %v", err)
+			if saveErr == nil {
+				saveErr = err
+			}
 		} else {
 			log.Debugf("Saved final batch of %d entries", bufferLen)
 			totalSaved += bufferLen
@@ -454,4 +929,5 @@ func loadCandidatesToTemp(candidateChan <-chan object.FileInfo, dbInstance *db.D
 	// 记录总共保存的记录数
 	log.Infof("Successfully saved total %d entries to database", totalSaved)
 
+	return saveErr
 }
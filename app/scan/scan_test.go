@@ -0,0 +1,60 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/db"
+	"terrasync/log"
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// TestProcessFilesInterrupted 测试中断的全量扫描仍保存已扫描的条目，
+// 摘要标记为中断并返回context.Canceled
+func TestProcessFilesInterrupted(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	jobDir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 读取条目在数据库初始化之后，发送完条目时中断
+	storage, err := object.CreateStorage("mem://test-process-files-interrupted-" + strconv.FormatInt(time.Now().UnixNano(), 36))
+	assert.NoError(t, err)
+	scanned := make(chan object.FileInfo)
+	go func() {
+		defer close(scanned)
+		for _, key := range []string{"/a.txt", "/b.txt"} {
+			assert.NoError(t, storage.Put(key, strings.NewReader("0123456789")))
+			fileInfo, err := storage.Head(key)
+			assert.NoError(t, err)
+			scanned <- fileInfo
+		}
+		cancel()
+	}()
+
+	scanConfig := ScanConfig{DbType: "sqlite", JobDir: jobDir, DBBatchSize: 100}
+	err = ProcessFilesForFullScan(ctx, scanConfig, scanned, ReportConfig{Quiet: true, StartTime: time.Now()}, NewStats(), nil)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	summary, err := LoadJobSummary(jobDir)
+	assert.NoError(t, err)
+	assert.True(t, summary.Interrupted)
+	assert.Equal(t, int64(2), summary.Stats.FileCount)
+
+	dbInstance, err := NewDB("sqlite", jobDir)
+	assert.NoError(t, err)
+	defer (*dbInstance).Close()
+	var keys []string
+	assert.NoError(t, (*dbInstance).ListEntries(context.Background(), "", func(entry db.FileInfoData) error {
+		keys = append(keys, entry.Key)
+		return nil
+	}))
+	assert.Equal(t, []string{"/a.txt", "/b.txt"}, keys)
+}
@@ -0,0 +1,131 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"terrasync/db"
+	"terrasync/log"
+	"terrasync/object"
+	"time"
+)
+
+// SnapshotTable is the job database table holding the frozen listing of a two-phase scan
+const SnapshotTable = "listing"
+
+// DeliveryTable is the job database table holding the high-water marks of the sinks
+const DeliveryTable = "deliveries"
+
+// SnapshotListing saves every entry read from entries in the snapshot table of the
+// job database and only then returns them, read back from the table.
+// Processing the frozen listing instead of the live walk keeps statistics and
+// migration plans consistent with one point in time: files created or deleted
+// while the tree is walked can no longer skew the later phase. The snapshot of a
+// previous run of the same job is replaced.
+func SnapshotListing(ctx context.Context, scanConfig ScanConfig, storage object.Storage, entries <-chan object.FileInfo) (<-chan object.FileInfo, error) {
+	dbInstance, err := NewDB(scanConfig.DbType, scanConfig.JobDir)
+	if err != nil {
+		return nil, err
+	}
+	// 送达高水位是按旧快照的顺序记录的
+	for _, table := range []string{SnapshotTable, DeliveryTable} {
+		if err := (*dbInstance).DropTable(ctx, table); err != nil {
+			(*dbInstance).Close()
+			return nil, err
+		}
+	}
+	if err := (*dbInstance).CreateTable(ctx, SnapshotTable); err != nil {
+		(*dbInstance).Close()
+		return nil, err
+	}
+
+	// 阶段1：完整列举并保存
+	startTime := time.Now()
+	if err := loadCandidatesToTemp(ctx, entries, dbInstance, SnapshotTable, scanConfig); err != nil {
+		(*dbInstance).Close()
+		return nil, fmt.Errorf("failed to save listing snapshot: %w", err)
+	}
+	log.Infof("Listing snapshot taken in %v", time.Since(startTime))
+
+	return readSnapshot(ctx, dbInstance, storage), nil
+}
+
+// ResumeListing returns the listing snapshot of an interrupted two-phase scan
+// of the job, in the order it was first processed. The scan is resumable once
+// the snapshot was complete and the delivery of its entries started.
+func ResumeListing(ctx context.Context, scanConfig ScanConfig, storage object.Storage) (<-chan object.FileInfo, error) {
+	dbInstance, err := NewDB(scanConfig.DbType, scanConfig.JobDir)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := (*dbInstance).ListDeliveries(ctx)
+	if err != nil {
+		(*dbInstance).Close()
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		(*dbInstance).Close()
+		return nil, fmt.Errorf("nothing to resume in %s: only two-phase scans interrupted after the listing snapshot can be resumed", scanConfig.JobDir)
+	}
+	log.Infof("Resuming from listing snapshot, delivered entries: %v", deliveries)
+	return readSnapshot(ctx, dbInstance, storage), nil
+}
+
+// readSnapshot reads the frozen listing in the order it was saved and closes the database
+func readSnapshot(ctx context.Context, dbInstance *db.DB, storage object.Storage) <-chan object.FileInfo {
+	results := make(chan object.FileInfo, listQueueLen)
+	go func() {
+		defer close(results)
+		defer (*dbInstance).Close()
+		err := (*dbInstance).ListEntries(ctx, SnapshotTable, func(data db.FileInfoData) error {
+			select {
+			case results <- &snapshotEntry{data: data, storage: storage}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Errorf("Failed to read listing snapshot: %v", err)
+		}
+	}()
+	return results
+}
+
+// snapshotEntry is an entry of the listing snapshot.
+// Metadata comes from the snapshot, data is read from the storage when needed.
+type snapshotEntry struct {
+	data    db.FileInfoData
+	storage object.Storage
+}
+
+func (e *snapshotEntry) Key() string       { return e.data.Key }
+func (e *snapshotEntry) Size() int64       { return e.data.Size }
+func (e *snapshotEntry) MTime() time.Time  { return e.data.MTime }
+func (e *snapshotEntry) CTime() time.Time  { return e.data.CTime }
+func (e *snapshotEntry) ATime() time.Time  { return e.data.ATime }
+func (e *snapshotEntry) Perm() os.FileMode { return os.FileMode(e.data.Perm) }
+func (e *snapshotEntry) IsDir() bool       { return e.data.IsDir }
+func (e *snapshotEntry) IsSymlink() bool   { return e.data.IsSymlink }
+func (e *snapshotEntry) IsRegular() bool   { return e.data.IsRegular }
+func (e *snapshotEntry) IsSticky() bool    { return e.Perm()&os.ModeSticky != 0 }
+
+// Attrs returns the attributes captured by the listing
+func (e *snapshotEntry) Attrs() (object.FileAttrs, error) { return e.data.Attrs, nil }
+
+// Get reads the file as it is now, which may differ from the snapshot
+func (e *snapshotEntry) Get(offset, limit int64) (io.ReadCloser, error) {
+	fi, err := e.storage.Head(e.data.Key)
+	if err != nil {
+		return nil, err
+	}
+	if fi == nil {
+		return nil, fmt.Errorf("%s: %w", e.data.Key, os.ErrNotExist)
+	}
+	return fi.Get(offset, limit)
+}
+
+func (e *snapshotEntry) Delete() error {
+	return e.storage.Delete(e.data.Key)
+}
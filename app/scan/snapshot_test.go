@@ -0,0 +1,110 @@
+package scan
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSnapshotListing 测试两阶段扫描处理冻结的列举结果
+func TestSnapshotListing(t *testing.T) {
+	ctx := context.Background()
+	storage, err := object.CreateStorage("mem://snapshot-test")
+	assert.NoError(t, err)
+	for _, key := range []string{"/a/1.txt", "/a/2.txt", "/b.txt"} {
+		assert.NoError(t, storage.Put(key, strings.NewReader("data")))
+	}
+	assert.NoError(t, storage.(object.MetadataSetter).SetMetadata("/b.txt", object.Metadata{Perm: 0644, Attrs: object.AttrReadOnly | object.AttrArchive, AttrsKnown: true}))
+
+	scanConfig := ScanConfig{DbType: "sqlite", JobDir: t.TempDir(), DBBatchSize: 2}
+	entries, err := SnapshotListing(ctx, scanConfig, storage, ListAll(ctx, storage, ListOptions{Concurrency: 2}))
+	assert.NoError(t, err)
+
+	// 列举完成后创建和删除的文件不影响第二阶段
+	assert.NoError(t, storage.Put("/c.txt", strings.NewReader("new")))
+	assert.NoError(t, storage.Delete("/a/2.txt"))
+
+	var keys []string
+	for fi := range entries {
+		keys = append(keys, fi.Key())
+		if fi.Key() == "/b.txt" {
+			assert.Equal(t, int64(4), fi.Size())
+			assert.True(t, fi.IsRegular())
+			// 文件属性随列举结果保存
+			attrs, err := fi.(object.AttrsProvider).Attrs()
+			assert.NoError(t, err)
+			assert.Equal(t, object.AttrReadOnly|object.AttrArchive, attrs)
+			in, err := fi.Get(0, -1)
+			assert.NoError(t, err)
+			data, _ := io.ReadAll(in)
+			in.Close()
+			assert.Equal(t, "data", string(data))
+		}
+		if fi.Key() == "/a/2.txt" {
+			_, err := fi.Get(0, -1)
+			assert.Error(t, err)
+		}
+	}
+	sort.Strings(keys)
+	assert.Equal(t, []string{"/a", "/a/1.txt", "/a/2.txt", "/b.txt"}, keys)
+
+	// 再次运行时替换上一次的快照
+	entries, err = SnapshotListing(ctx, scanConfig, storage, ListAll(ctx, storage, ListOptions{Concurrency: 2}))
+	assert.NoError(t, err)
+	count := 0
+	for range entries {
+		count++
+	}
+	assert.Equal(t, 4, count)
+}
+
+// TestResumeListing 测试继续中断的两阶段扫描时按相同顺序读出快照
+func TestResumeListing(t *testing.T) {
+	ctx := context.Background()
+	storage, err := object.CreateStorage("mem://resume-test")
+	assert.NoError(t, err)
+	for _, key := range []string{"/a/1.txt", "/a/2.txt", "/b.txt"} {
+		assert.NoError(t, storage.Put(key, strings.NewReader("data")))
+	}
+	scanConfig := ScanConfig{DbType: "sqlite", JobDir: t.TempDir(), DBBatchSize: 2}
+	entries, err := SnapshotListing(ctx, scanConfig, storage, ListAll(ctx, storage, ListOptions{Concurrency: 2}))
+	assert.NoError(t, err)
+	var first []string
+	for fi := range entries {
+		first = append(first, fi.Key())
+	}
+
+	// 没有送达记录时快照可能不完整，不能继续
+	_, err = ResumeListing(ctx, scanConfig, storage)
+	assert.Error(t, err)
+
+	dbInstance, err := NewDB(scanConfig.DbType, scanConfig.JobDir)
+	assert.NoError(t, err)
+	assert.NoError(t, (*dbInstance).SaveDelivery(ctx, "database", 2))
+	deliveries, err := (*dbInstance).ListDeliveries(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"database": 2}, deliveries)
+	assert.NoError(t, (*dbInstance).Close())
+
+	entries, err = ResumeListing(ctx, scanConfig, storage)
+	assert.NoError(t, err)
+	var resumed []string
+	for fi := range entries {
+		resumed = append(resumed, fi.Key())
+	}
+	assert.Equal(t, first, resumed)
+
+	// 重新列举时清除旧快照的送达记录
+	entries, err = SnapshotListing(ctx, scanConfig, storage, ListAll(ctx, storage, ListOptions{Concurrency: 2}))
+	assert.NoError(t, err)
+	for range entries {
+	}
+	_, err = ResumeListing(ctx, scanConfig, storage)
+	assert.Error(t, err)
+}
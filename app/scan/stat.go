@@ -2,27 +2,76 @@ package scan
 
 import (
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"terrasync/i18n"
+	"terrasync/log"
 	"terrasync/object"
+	"terrasync/pkg/report"
+	"terrasync/pkg/stats"
+	"terrasync/processor"
+	"time"
 )
 
-// Stats stores scan statistics
+const (
+	// DefaultHugeDirThreshold is the number of entries above which a directory is reported as pathological
+	DefaultHugeDirThreshold = 1000000
+	// maxHugeDirsRecorded limits how many huge directory paths are kept for the report
+	maxHugeDirsRecorded = 10
+)
+
+// Stats stores scan statistics.
+// The file, directory, byte and skip counters are the shared stats.Stats also
+// used by migrate, the rest are specific to the scan report.
 type Stats struct {
-	fileCount        int64
-	dirCount         int64
-	totalSize        int64
+	counters         *stats.Stats
 	totalSymlink     int64
 	totalRegularFile int64
-	totalNameLength  int64 // 总文件名长度
-	maxNameLength    int   // 最大文件名长度
-	totalDirDepth    int64 // 总目录深度
-	maxDirDepth      int   // 最大目录深度
+	totalNameLength  int64        // 总文件名长度
+	maxNameLength    atomic.Int64 // 最大文件名长度
+	totalDirDepth    int64        // 总目录深度
+	maxDirDepth      atomic.Int64 // 最大目录深度
+	maxDirEntries    int64        // 单个目录最大条目数
+	hugeDirCount     int64        // 条目数超过阈值的目录数量
+	hugeDirThreshold int64        // 超大目录阈值
+	prunedDirs       int64        // 增量扫描中未变化而不再深入的目录数
+	hugeDirs         *hugeDirList
+	routes           *routeCounter
+	compression      *CompressionStats // 压缩率估算，未启用采样时为nil
+	idle             *idleCounter      // 按未使用时长统计的文件数和字节数
+
+	stubCount int64 // 数据离线的存根文件数(HSM/归档分层)
+	stubBytes int64
+
+	emptyDirCount  int64 // 列举时没有任何条目的目录数
+	emptyFileCount int64 // 0字节的普通文件数
+
+	top *topCounter // 最大文件及文件最多的目录排名，未启用时为nil
+}
+
+// hugeDirList records the paths of directories exceeding the huge directory threshold
+type hugeDirList struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+// routeCounter counts entries routed to each destination by the processor pipeline
+type routeCounter struct {
+	mu     sync.Mutex
+	counts map[string]int64
 }
 
 // NewStats creates a new stats instance
 func NewStats() *Stats {
-	return &Stats{}
+	return &Stats{
+		counters:         stats.New(),
+		hugeDirThreshold: DefaultHugeDirThreshold,
+		hugeDirs:         &hugeDirList{},
+		routes:           &routeCounter{counts: make(map[string]int64)},
+		idle:             newIdleCounter(time.Now()),
+	}
 }
 
 // Update updates statistics based on file information
@@ -31,7 +80,7 @@ func (s *Stats) Update(fileInfo object.FileInfo) {
 	key := fileInfo.Key()
 
 	if fileInfo.IsDir() {
-		atomic.AddInt64(&s.dirCount, 1)
+		s.counters.AddDir()
 	} else {
 		// Use filepath to get filename and calculate length
 		name := filepath.Base(key)
@@ -45,23 +94,25 @@ func (s *Stats) Update(fileInfo object.FileInfo) {
 			depth = 0
 		}
 
-		atomic.AddInt64(&s.fileCount, 1)
-		atomic.AddInt64(&s.totalSize, fileInfo.Size())
+		s.counters.AddFile(fileInfo.Size())
 		atomic.AddInt64(&s.totalNameLength, int64(nameLength)) // Accumulate total filename length
 		atomic.AddInt64(&s.totalDirDepth, int64(depth))        // Accumulate total directory depth
 
-		// Update maximum filename length
-		if nameLength > s.maxNameLength {
-			s.maxNameLength = nameLength
-		}
-
-		// Update maximum directory depth
-		if depth > s.maxDirDepth {
-			s.maxDirDepth = depth
-		}
+		// Update maximum filename length and directory depth
+		stats.Max(&s.maxNameLength, int64(nameLength))
+		stats.Max(&s.maxDirDepth, int64(depth))
 
 		if fileInfo.IsRegular() {
 			atomic.AddInt64(&s.totalRegularFile, 1)
+			s.idle.add(fileInfo)
+			if fileInfo.Size() == 0 {
+				atomic.AddInt64(&s.emptyFileCount, 1)
+			}
+			s.top.addFile(TopEntry{Path: key, Bytes: fileInfo.Size()})
+			if object.IsStub(fileInfo) {
+				atomic.AddInt64(&s.stubCount, 1)
+				atomic.AddInt64(&s.stubBytes, fileInfo.Size())
+			}
 		}
 	}
 	// Count symlinks and regular files
@@ -70,19 +121,160 @@ func (s *Stats) Update(fileInfo object.FileInfo) {
 	}
 }
 
+// SetHugeDirThreshold overrides the huge directory threshold, values <= 0 keep the default
+func (s *Stats) SetHugeDirThreshold(threshold int64) {
+	if threshold > 0 {
+		s.hugeDirThreshold = threshold
+	}
+}
+
+// RecordDirEntries records the number of entries listed in a single directory and
+// flags the directory when it is empty or exceeds the huge directory threshold
+func (s *Stats) RecordDirEntries(dir string, entries int64) {
+	if entries == 0 {
+		atomic.AddInt64(&s.emptyDirCount, 1)
+	}
+	for {
+		current := atomic.LoadInt64(&s.maxDirEntries)
+		if entries <= current || atomic.CompareAndSwapInt64(&s.maxDirEntries, current, entries) {
+			break
+		}
+	}
+
+	if s.hugeDirThreshold <= 0 || entries < s.hugeDirThreshold {
+		return
+	}
+
+	atomic.AddInt64(&s.hugeDirCount, 1)
+	log.Warnf("Huge directory detected: %s contains %d entries", dir, entries)
+
+	if s.hugeDirs == nil {
+		return
+	}
+	s.hugeDirs.mu.Lock()
+	if len(s.hugeDirs.paths) < maxHugeDirsRecorded {
+		s.hugeDirs.paths = append(s.hugeDirs.paths, dir)
+	}
+	s.hugeDirs.mu.Unlock()
+}
+
+// SetTop ranks the n largest files and the n directories with the most files
+// and bytes, n <= 0 ranks nothing
+func (s *Stats) SetTop(n int) {
+	if n > 0 {
+		s.top = newTopCounter(n)
+	}
+}
+
+// RecordDirUsage records the number and size of the files scanned directly in
+// a directory, its subdirectories excluded
+func (s *Stats) RecordDirUsage(dir string, files, bytes int64) {
+	s.top.addDir(TopEntry{Path: dir, Files: files, Bytes: bytes})
+}
+
+// GetTop returns the largest files and the directories with the most files
+// and bytes, largest first
+func (s *Stats) GetTop() (files, dirsByFiles, dirsByBytes []TopEntry) {
+	return s.top.lists()
+}
+
+// GetMaxDirEntries returns the maximum number of entries found in a single directory
+func (s *Stats) GetMaxDirEntries() int64 {
+	return atomic.LoadInt64(&s.maxDirEntries)
+}
+
+// GetHugeDirCount returns the number of directories exceeding the huge directory threshold
+func (s *Stats) GetHugeDirCount() int64 {
+	return atomic.LoadInt64(&s.hugeDirCount)
+}
+
+// GetHugeDirs returns the recorded huge directory paths
+func (s *Stats) GetHugeDirs() []string {
+	if s.hugeDirs == nil {
+		return nil
+	}
+	s.hugeDirs.mu.Lock()
+	defer s.hugeDirs.mu.Unlock()
+	return append([]string(nil), s.hugeDirs.paths...)
+}
+
+// RecordPrunedDir records a directory not descended because it is unchanged since the baseline
+func (s *Stats) RecordPrunedDir() {
+	atomic.AddInt64(&s.prunedDirs, 1)
+}
+
+// GetPrunedDirCount returns the number of directories pruned by an incremental scan
+func (s *Stats) GetPrunedDirCount() int64 {
+	return atomic.LoadInt64(&s.prunedDirs)
+}
+
+// RecordProcessed records the outcome of the processor pipeline for one entry
+func (s *Stats) RecordProcessed(fileInfo object.FileInfo, keep bool) {
+	if !keep {
+		s.counters.AddSkipped(0)
+		return
+	}
+	routed, ok := fileInfo.(processor.Routed)
+	if !ok || routed.Destination() == "" || s.routes == nil {
+		return
+	}
+	s.routes.mu.Lock()
+	s.routes.counts[routed.Destination()]++
+	s.routes.mu.Unlock()
+}
+
+// SetCompression sets the compression estimate printed with the statistics
+func (s *Stats) SetCompression(c *CompressionStats) {
+	s.compression = c
+}
+
+// GetCompression returns the compression estimate, nil if files were not sampled
+func (s *Stats) GetCompression() *CompressionStats {
+	return s.compression
+}
+
+// Counters returns the shared counters, e.g. for periodic progress snapshots
+func (s *Stats) Counters() *stats.Stats {
+	return s.counters
+}
+
+// StartProgress starts the progress records of the counters, nil without a writer
+func (s *Stats) StartProgress(cfg stats.ProgressConfig) *stats.Progress {
+	return stats.StartProgress(cfg, s.counters)
+}
+
+// GetSkippedCount returns the number of entries skipped by processors
+func (s *Stats) GetSkippedCount() int64 {
+	return s.counters.Snapshot().Skipped
+}
+
+// GetRoutes returns the number of entries routed to each destination
+func (s *Stats) GetRoutes() map[string]int64 {
+	routes := make(map[string]int64)
+	if s.routes == nil {
+		return routes
+	}
+	s.routes.mu.Lock()
+	defer s.routes.mu.Unlock()
+	for dest, count := range s.routes.counts {
+		routes[dest] = count
+	}
+	return routes
+}
+
 // GetFileCount returns the number of files
 func (s *Stats) GetFileCount() int64 {
-	return atomic.LoadInt64(&s.fileCount)
+	return s.counters.Snapshot().Files
 }
 
 // GetDirCount returns the number of directories
 func (s *Stats) GetDirCount() int64 {
-	return atomic.LoadInt64(&s.dirCount)
+	return s.counters.Snapshot().Dirs
 }
 
 // GetTotalSize returns the total size
 func (s *Stats) GetTotalSize() int64 {
-	return atomic.LoadInt64(&s.totalSize)
+	return s.counters.Snapshot().Bytes
 }
 
 // GetTotalSymlink returns the number of symlinks
@@ -95,9 +287,29 @@ func (s *Stats) GetTotalRegularFile() int64 {
 	return atomic.LoadInt64(&s.totalRegularFile)
 }
 
+// GetStubCount returns the number of offline stubs
+func (s *Stats) GetStubCount() int64 {
+	return atomic.LoadInt64(&s.stubCount)
+}
+
+// GetStubBytes returns the size of the offline stubs, the data they stand for
+func (s *Stats) GetStubBytes() int64 {
+	return atomic.LoadInt64(&s.stubBytes)
+}
+
+// GetEmptyDirCount returns the number of directories listed without any entry
+func (s *Stats) GetEmptyDirCount() int64 {
+	return atomic.LoadInt64(&s.emptyDirCount)
+}
+
+// GetEmptyFileCount returns the number of zero-byte regular files
+func (s *Stats) GetEmptyFileCount() int64 {
+	return atomic.LoadInt64(&s.emptyFileCount)
+}
+
 // GetAvgNameLength returns the average filename length
 func (s *Stats) GetAvgNameLength() int {
-	count := atomic.LoadInt64(&s.fileCount)
+	count := s.GetFileCount()
 	if count == 0 {
 		return 0
 	}
@@ -106,12 +318,12 @@ func (s *Stats) GetAvgNameLength() int {
 
 // GetMaxNameLength returns the maximum filename length
 func (s *Stats) GetMaxNameLength() int {
-	return s.maxNameLength
+	return int(s.maxNameLength.Load())
 }
 
 // GetAvgDirDepth returns the average directory depth
 func (s *Stats) GetAvgDirDepth() int {
-	count := atomic.LoadInt64(&s.fileCount)
+	count := s.GetFileCount()
 	if count == 0 {
 		return 0
 	}
@@ -120,44 +332,117 @@ func (s *Stats) GetAvgDirDepth() int {
 
 // GetMaxDirDepth returns the maximum directory depth
 func (s *Stats) GetMaxDirDepth() int {
-	return s.maxDirDepth
+	return int(s.maxDirDepth.Load())
 }
 
 // Print prints the statistics
 func (s *Stats) Print() {
-	// Print another separator
-	printToConsoleAndLog("\n------------------------- Sanned Count -------------------------\n\n")
+	console.Section("Scanned Count")
 
 	// File count statistics
 	fileCount := s.GetFileCount()
 	dirCount := s.GetDirCount()
-	printToConsoleAndLog("  Total:            %30d\n", fileCount+dirCount)
-	printToConsoleAndLog("  Files:            %30d\n", fileCount)
-	printToConsoleAndLog("  Directories:      %30d\n", dirCount)
+	console.Field("Total", fileCount+dirCount)
+	console.Field("Files", fileCount)
+	console.Field("Directories", dirCount)
 
-	// Print another separator
-	printToConsoleAndLog("\n--------------------------- Capacity ---------------------------\n\n")
+	console.Section("Capacity")
 
 	// Format total size using the utility function
 	totalSize := s.GetTotalSize()
-	averageSizeBytes := totalSize / int64(fileCount)
-	printToConsoleAndLog("  Total:            %30s\n", FormatFileSize(totalSize))
-	printToConsoleAndLog("  Average:          %30s\n", FormatFileSize(averageSizeBytes))
+	var averageSizeBytes int64
+	if fileCount > 0 {
+		averageSizeBytes = totalSize / fileCount
+	}
+	console.Field("Total", FormatFileSize(totalSize))
+	console.Field("Average", FormatFileSize(averageSizeBytes))
 
-	// Print another separator
-	printToConsoleAndLog("\n------------------------ Filename Length ------------------------\n\n")
+	// Offline stubs are only printed when the source is archive tiered
+	if stubCount := s.GetStubCount(); stubCount > 0 {
+		console.Section("Offline Stubs")
+		console.Field("Files", stubCount)
+		console.Field("Total", FormatFileSize(s.GetStubBytes()))
+	}
+
+	// Empty entries are listed by the report empty command
+	if emptyDirs, emptyFiles := s.GetEmptyDirCount(), s.GetEmptyFileCount(); emptyDirs > 0 || emptyFiles > 0 {
+		console.Section("Empty Entries")
+		console.Field("Directories", emptyDirs)
+		console.Field("Zero-byte files", emptyFiles)
+	}
+
+	console.Section("Filename Length")
 
 	// Filename length statistics
-	printToConsoleAndLog("  Avg:              %30d\n", s.GetAvgNameLength())
-	printToConsoleAndLog("  Max:              %30d\n", s.GetMaxNameLength())
+	console.Field("Avg", s.GetAvgNameLength())
+	console.Field("Max", s.GetMaxNameLength())
 
-	// Print another separator
-	printToConsoleAndLog("\n------------------------ Directory Depth ------------------------\n\n")
+	console.Section("Directory Depth")
 
 	// Directory depth statistics
-	printToConsoleAndLog("  Avg:              %30d\n", s.GetAvgDirDepth())
-	printToConsoleAndLog("  Max:              %30d\n", s.GetMaxDirDepth())
+	console.Field("Avg", s.GetAvgDirDepth())
+	console.Field("Max", s.GetMaxDirDepth())
+
+	console.Section("Directory Size")
+
+	// Directory entry statistics, huge directories are listed as warnings
+	hugeDirCount := s.GetHugeDirCount()
+	console.Field("Max entries", s.GetMaxDirEntries())
+	console.Field("Huge dirs", hugeDirCount)
+	if hugeDirCount > 0 {
+		console.Printf("\n  %s\n", i18n.Sprintf("WARNING: %d directories contain more than %d entries", hugeDirCount, s.hugeDirThreshold))
+		for _, dir := range s.GetHugeDirs() {
+			console.Printf("    %s\n", dir)
+		}
+		if hugeDirCount > maxHugeDirsRecorded {
+			console.Printf("    %s\n", i18n.Sprintf("... (%d more, see log)", hugeDirCount-maxHugeDirsRecorded))
+		}
+	}
+
+	s.printTop()
+
+	// Processor statistics are only printed when a pipeline changed something
+	skipped := s.GetSkippedCount()
+	routes := s.GetRoutes()
+	if skipped > 0 || len(routes) > 0 {
+		console.Section("Processors")
+		console.Field("Skipped", skipped)
+		destinations := make([]string, 0, len(routes))
+		for dest := range routes {
+			destinations = append(destinations, dest)
+		}
+		sort.Strings(destinations)
+		for _, dest := range destinations {
+			console.Printf("  %s %*d\n", i18n.Pad(i18n.Sprintf("Routed to %s", dest)+":", 30), report.FieldWidth(2+30+1), routes[dest])
+		}
+	}
+
+	if s.compression != nil {
+		s.compression.Print()
+	}
+
+	s.printLifecycleSuggestions()
 
 	// Print final separator
-	printToConsoleAndLog("\n-------------------------------------------------------------\n\n")
+	console.EndSection()
+}
+
+// printTop prints the top-N rankings when they are enabled
+func (s *Stats) printTop() {
+	if s.top == nil {
+		return
+	}
+	files, dirsByFiles, dirsByBytes := s.GetTop()
+	console.Section("Largest Files")
+	for _, e := range files {
+		console.Printf("  %10s  %s\n", FormatFileSize(e.Bytes), e.Path)
+	}
+	console.Section("Directories by Files")
+	for _, e := range dirsByFiles {
+		console.Printf("  %10d  %s\n", e.Files, e.Path)
+	}
+	console.Section("Directories by Size")
+	for _, e := range dirsByBytes {
+		console.Printf("  %10s  %s\n", FormatFileSize(e.Bytes), e.Path)
+	}
 }
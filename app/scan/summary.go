@@ -0,0 +1,179 @@
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"terrasync/object"
+	"terrasync/pkg/stats"
+	"time"
+)
+
+// JobSummaryName is the file in the job directory holding everything needed to
+// rebuild the reports of a scan without scanning again
+const JobSummaryName = "summary.json"
+
+// StatsSnapshot is the serializable form of Stats
+type StatsSnapshot struct {
+	FileCount        int64             `json:"file_count"`
+	DirCount         int64             `json:"dir_count"`
+	TotalSize        int64             `json:"total_size"`
+	TotalSymlink     int64             `json:"total_symlink"`
+	TotalRegularFile int64             `json:"total_regular_file"`
+	TotalNameLength  int64             `json:"total_name_length"`
+	MaxNameLength    int               `json:"max_name_length"`
+	TotalDirDepth    int64             `json:"total_dir_depth"`
+	MaxDirDepth      int               `json:"max_dir_depth"`
+	MaxDirEntries    int64             `json:"max_dir_entries"`
+	HugeDirCount     int64             `json:"huge_dir_count"`
+	HugeDirThreshold int64             `json:"huge_dir_threshold"`
+	HugeDirs         []string          `json:"huge_dirs,omitempty"`
+	SkippedCount     int64             `json:"skipped_count"`
+	Routes           map[string]int64  `json:"routes,omitempty"`
+	Compression      *CompressionStats `json:"compression,omitempty"`
+	IdleFiles        []int64           `json:"idle_files,omitempty"` // 按lifecycleTiers统计的未使用文件数
+	IdleBytes        []int64           `json:"idle_bytes,omitempty"`
+	StubCount        int64             `json:"stub_count,omitempty"` // 数据离线的存根文件数
+	StubBytes        int64             `json:"stub_bytes,omitempty"`
+	EmptyDirCount    int64             `json:"empty_dir_count,omitempty"`  // 没有任何条目的目录数
+	EmptyFileCount   int64             `json:"empty_file_count,omitempty"` // 0字节的普通文件数
+	TopN             int               `json:"top_n,omitempty"`            // 排名的条目数，0表示未排名
+	TopFiles         []TopEntry        `json:"top_files,omitempty"`
+	TopDirsByFiles   []TopEntry        `json:"top_dirs_by_files,omitempty"`
+	TopDirsByBytes   []TopEntry        `json:"top_dirs_by_bytes,omitempty"`
+}
+
+// Snapshot returns a copy of the statistics that can be saved as JSON
+func (s *Stats) Snapshot() StatsSnapshot {
+	var topN int
+	if s.top != nil {
+		topN = s.top.n
+	}
+	topFiles, topDirsByFiles, topDirsByBytes := s.GetTop()
+	return StatsSnapshot{
+		FileCount:        s.GetFileCount(),
+		DirCount:         s.GetDirCount(),
+		TotalSize:        s.GetTotalSize(),
+		TotalSymlink:     s.GetTotalSymlink(),
+		TotalRegularFile: s.GetTotalRegularFile(),
+		TotalNameLength:  atomic.LoadInt64(&s.totalNameLength),
+		MaxNameLength:    s.GetMaxNameLength(),
+		TotalDirDepth:    atomic.LoadInt64(&s.totalDirDepth),
+		MaxDirDepth:      s.GetMaxDirDepth(),
+		MaxDirEntries:    s.GetMaxDirEntries(),
+		HugeDirCount:     s.GetHugeDirCount(),
+		HugeDirThreshold: s.hugeDirThreshold,
+		HugeDirs:         s.GetHugeDirs(),
+		SkippedCount:     s.GetSkippedCount(),
+		Routes:           s.GetRoutes(),
+		Compression:      s.GetCompression(),
+		IdleFiles:        loadInt64s(s.idle.files),
+		IdleBytes:        loadInt64s(s.idle.bytes),
+		StubCount:        s.GetStubCount(),
+		StubBytes:        s.GetStubBytes(),
+		EmptyDirCount:    s.GetEmptyDirCount(),
+		EmptyFileCount:   s.GetEmptyFileCount(),
+		TopN:             topN,
+		TopFiles:         topFiles,
+		TopDirsByFiles:   topDirsByFiles,
+		TopDirsByBytes:   topDirsByBytes,
+	}
+}
+
+// Stats rebuilds the statistics from the snapshot
+func (snap StatsSnapshot) Stats() *Stats {
+	s := NewStats()
+	s.counters.Restore(stats.Snapshot{Files: snap.FileCount, Dirs: snap.DirCount, Bytes: snap.TotalSize, Skipped: snap.SkippedCount})
+	s.totalSymlink = snap.TotalSymlink
+	s.totalRegularFile = snap.TotalRegularFile
+	s.totalNameLength = snap.TotalNameLength
+	s.maxNameLength.Store(int64(snap.MaxNameLength))
+	s.totalDirDepth = snap.TotalDirDepth
+	s.maxDirDepth.Store(int64(snap.MaxDirDepth))
+	s.maxDirEntries = snap.MaxDirEntries
+	s.hugeDirCount = snap.HugeDirCount
+	s.SetHugeDirThreshold(snap.HugeDirThreshold)
+	s.hugeDirs.paths = append(s.hugeDirs.paths, snap.HugeDirs...)
+	for dest, count := range snap.Routes {
+		s.routes.counts[dest] = count
+	}
+	s.compression = snap.Compression
+	copy(s.idle.files, snap.IdleFiles)
+	copy(s.idle.bytes, snap.IdleBytes)
+	s.stubCount = snap.StubCount
+	s.stubBytes = snap.StubBytes
+	s.emptyDirCount = snap.EmptyDirCount
+	s.emptyFileCount = snap.EmptyFileCount
+	s.SetTop(snap.TopN)
+	s.top.restore(snap.TopFiles, snap.TopDirsByFiles, snap.TopDirsByBytes)
+	return s
+}
+
+// loadInt64s returns a copy of counters updated atomically
+func loadInt64s(counters []int64) []int64 {
+	values := make([]int64, len(counters))
+	for i := range counters {
+		values[i] = atomic.LoadInt64(&counters[i])
+	}
+	return values
+}
+
+// JobSummary is the persisted outcome of a scan job
+type JobSummary struct {
+	JobID       string           `json:"job_id"`
+	AppVersion  string           `json:"app_version"`
+	CmdLine     string           `json:"cmd_line"`
+	Path        string           `json:"path"`
+	Match       []string         `json:"match,omitempty"`
+	Exclude     []string         `json:"exclude,omitempty"`
+	Depth       int              `json:"depth,omitempty"`
+	DbType      string           `json:"db_type"`
+	CryptoMode  string           `json:"crypto_mode"`
+	StartTime   time.Time        `json:"start_time"` // UTC
+	EndTime     time.Time        `json:"end_time"`
+	Error       string           `json:"error,omitempty"`       // 任务失败的原因，为空表示成功
+	Interrupted bool             `json:"interrupted,omitempty"` // 被信号中断，统计只包含中断前扫描的条目
+	FileTypes   int              `json:"file_types"`
+	Extensions  []ExtensionUsage `json:"extensions,omitempty"` // 按容量降序的扩展名，其余扩展名合为*
+	Stats       StatsSnapshot    `json:"stats"`
+	Partition   *Partition       `json:"partition,omitempty"`  // 分布式扫描中本任务扫描的分区
+	Partitions  []string         `json:"partitions,omitempty"` // 合并任务时被合并的分区任务ID
+	ReadOnly    bool             `json:"read_only,omitempty"`  // 扫描目录以只读方式打开(--assert-readonly)
+	// 启用limits.stat_cache时stat缓存的命中情况
+	StatCache *object.StatCacheStats `json:"stat_cache,omitempty"`
+}
+
+// SaveJobSummary writes the summary to the job directory
+func SaveJobSummary(jobDir string, summary JobSummary) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode job summary: %w", err)
+	}
+	// 先写临时文件再重命名，避免任务中断时留下不完整的摘要
+	path := filepath.Join(jobDir, JobSummaryName)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to save job summary: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to save job summary: %w", err)
+	}
+	return nil
+}
+
+// LoadJobSummary reads the summary saved in the job directory
+func LoadJobSummary(jobDir string) (*JobSummary, error) {
+	data, err := os.ReadFile(filepath.Join(jobDir, JobSummaryName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no job summary in %s, reports can only be regenerated for full scans run with this version", jobDir)
+		}
+		return nil, fmt.Errorf("failed to read job summary: %w", err)
+	}
+	var summary JobSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("failed to decode job summary: %w", err)
+	}
+	return &summary, nil
+}
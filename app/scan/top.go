@@ -0,0 +1,149 @@
+package scan
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+)
+
+// TopEntry is a file or a directory of the top-N rankings of a scan
+type TopEntry struct {
+	Path  string `json:"path"`
+	Files int64  `json:"files,omitempty"` // 目录中(不含子目录)的文件数
+	Bytes int64  `json:"bytes"`
+}
+
+func entryBytes(e TopEntry) int64 { return e.Bytes }
+func entryFiles(e TopEntry) int64 { return e.Files }
+
+// topList keeps the n entries with the largest value in a min-heap, so an
+// entry is compared with the smallest one kept only
+type topList struct {
+	n       int
+	value   func(TopEntry) int64
+	entries []TopEntry
+}
+
+// less orders the entries by value, then by reverse path so equal values
+// keep the first paths
+func (l *topList) less(a, b TopEntry) bool {
+	if va, vb := l.value(a), l.value(b); va != vb {
+		return va < vb
+	}
+	return a.Path > b.Path
+}
+
+func (l *topList) Len() int           { return len(l.entries) }
+func (l *topList) Less(i, j int) bool { return l.less(l.entries[i], l.entries[j]) }
+func (l *topList) Swap(i, j int)      { l.entries[i], l.entries[j] = l.entries[j], l.entries[i] }
+func (l *topList) Push(x any)         { l.entries = append(l.entries, x.(TopEntry)) }
+func (l *topList) Pop() any {
+	last := l.entries[len(l.entries)-1]
+	l.entries = l.entries[:len(l.entries)-1]
+	return last
+}
+
+func (l *topList) offer(e TopEntry) {
+	if l.n <= 0 || l.value(e) <= 0 {
+		return
+	}
+	if len(l.entries) < l.n {
+		heap.Push(l, e)
+		return
+	}
+	if l.less(l.entries[0], e) {
+		l.entries[0] = e
+		heap.Fix(l, 0)
+	}
+}
+
+// sorted returns the entries kept, largest first
+func (l *topList) sorted() []TopEntry {
+	if len(l.entries) == 0 {
+		return nil
+	}
+	sorted := append([]TopEntry(nil), l.entries...)
+	sort.Slice(sorted, func(i, j int) bool { return l.less(sorted[j], sorted[i]) })
+	return sorted
+}
+
+// topCounter ranks the largest files and the directories holding the most
+// files and bytes, with a memory bounded by n whatever the size of the tree
+type topCounter struct {
+	mu          sync.Mutex
+	n           int
+	files       *topList
+	dirsByFiles *topList
+	dirsByBytes *topList
+}
+
+func newTopCounter(n int) *topCounter {
+	return &topCounter{
+		n:           n,
+		files:       &topList{n: n, value: entryBytes},
+		dirsByFiles: &topList{n: n, value: entryFiles},
+		dirsByBytes: &topList{n: n, value: entryBytes},
+	}
+}
+
+func (t *topCounter) addFile(e TopEntry) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.files.offer(e)
+}
+
+func (t *topCounter) addDir(e TopEntry) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dirsByFiles.offer(e)
+	t.dirsByBytes.offer(e)
+}
+
+// restore fills the rankings with the lists of a snapshot
+func (t *topCounter) restore(files, dirsByFiles, dirsByBytes []TopEntry) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, r := range []struct {
+		list    *topList
+		entries []TopEntry
+	}{{t.files, files}, {t.dirsByFiles, dirsByFiles}, {t.dirsByBytes, dirsByBytes}} {
+		for _, e := range r.entries {
+			r.list.offer(e)
+		}
+	}
+}
+
+// lists returns the rankings, largest first
+func (t *topCounter) lists() (files, dirsByFiles, dirsByBytes []TopEntry) {
+	if t == nil {
+		return nil, nil, nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.files.sorted(), t.dirsByFiles.sorted(), t.dirsByBytes.sorted()
+}
+
+// mergeTopEntries ranks the entries of two rankings of disjoint parts of a tree,
+// summing up an entry in both such as the root directory listed by every
+// partition of a distributed scan
+func mergeTopEntries(n int, value func(TopEntry) int64, a, b []TopEntry) []TopEntry {
+	merged := make(map[string]TopEntry, len(a)+len(b))
+	for _, e := range append(append([]TopEntry(nil), a...), b...) {
+		m := merged[e.Path]
+		merged[e.Path] = TopEntry{Path: e.Path, Files: m.Files + e.Files, Bytes: m.Bytes + e.Bytes}
+	}
+	l := &topList{n: n, value: value}
+	for _, e := range merged {
+		l.offer(e)
+	}
+	return l.sorted()
+}
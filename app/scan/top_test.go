@@ -0,0 +1,97 @@
+package scan
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTopList 测试有界堆只保留值最大的N个条目
+func TestTopList(t *testing.T) {
+	tests := []struct {
+		name    string
+		n       int
+		entries []TopEntry
+		want    []TopEntry
+	}{
+		{
+			name:    "少于N个",
+			n:       3,
+			entries: []TopEntry{{Path: "/a", Bytes: 1}, {Path: "/b", Bytes: 5}},
+			want:    []TopEntry{{Path: "/b", Bytes: 5}, {Path: "/a", Bytes: 1}},
+		},
+		{
+			name: "超过N个保留最大的",
+			n:    2,
+			entries: []TopEntry{
+				{Path: "/a", Bytes: 3}, {Path: "/b", Bytes: 9}, {Path: "/c", Bytes: 1}, {Path: "/d", Bytes: 7}, {Path: "/e", Bytes: 2},
+			},
+			want: []TopEntry{{Path: "/b", Bytes: 9}, {Path: "/d", Bytes: 7}},
+		},
+		{
+			name:    "相同大小保留路径靠前的",
+			n:       2,
+			entries: []TopEntry{{Path: "/c", Bytes: 4}, {Path: "/b", Bytes: 4}, {Path: "/a", Bytes: 4}},
+			want:    []TopEntry{{Path: "/a", Bytes: 4}, {Path: "/b", Bytes: 4}},
+		},
+		{
+			name:    "忽略0字节",
+			n:       2,
+			entries: []TopEntry{{Path: "/a"}, {Path: "/b", Bytes: 1}},
+			want:    []TopEntry{{Path: "/b", Bytes: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &topList{n: tt.n, value: entryBytes}
+			for _, e := range tt.entries {
+				l.offer(e)
+			}
+			assert.Equal(t, tt.want, l.sorted())
+		})
+	}
+}
+
+// TestListingTop 测试扫描时排名最大的文件及文件最多、容量最大的目录，并在快照及合并中保留
+func TestListingTop(t *testing.T) {
+	storage, err := object.CreateStorage("mem://top-test")
+	assert.NoError(t, err)
+	for key, size := range map[string]int{
+		"/logs/1.log":   1,
+		"/logs/2.log":   1,
+		"/logs/3.log":   1,
+		"/video/a.mp4":  500,
+		"/video/b.mp4":  300,
+		"/docs/doc.txt": 50,
+	} {
+		assert.NoError(t, storage.Put(key, strings.NewReader(strings.Repeat("x", size))))
+	}
+
+	stats := NewStats()
+	stats.SetTop(2)
+	for fi := range ListAll(context.Background(), storage, ListOptions{Concurrency: 2, Stats: stats}) {
+		stats.Update(fi)
+	}
+	files, dirsByFiles, dirsByBytes := stats.GetTop()
+	assert.Equal(t, []TopEntry{{Path: "/video/a.mp4", Bytes: 500}, {Path: "/video/b.mp4", Bytes: 300}}, files)
+	assert.Equal(t, []TopEntry{{Path: "/logs", Files: 3, Bytes: 3}, {Path: "/video", Files: 2, Bytes: 800}}, dirsByFiles)
+	assert.Equal(t, []TopEntry{{Path: "/video", Files: 2, Bytes: 800}, {Path: "/docs", Files: 1, Bytes: 50}}, dirsByBytes)
+
+	snap := stats.Snapshot()
+	assert.Equal(t, snap, snap.Stats().Snapshot())
+
+	// 合并另一部分的排名，两部分都有的目录相加
+	snap.Merge(StatsSnapshot{
+		TopN:           2,
+		TopFiles:       []TopEntry{{Path: "/big.iso", Bytes: 400}},
+		TopDirsByFiles: []TopEntry{{Path: "/logs", Files: 2, Bytes: 2}},
+		TopDirsByBytes: []TopEntry{{Path: "/logs", Files: 2, Bytes: 2}},
+	})
+	assert.Equal(t, []TopEntry{{Path: "/video/a.mp4", Bytes: 500}, {Path: "/big.iso", Bytes: 400}}, snap.TopFiles)
+	assert.Equal(t, []TopEntry{{Path: "/logs", Files: 5, Bytes: 5}, {Path: "/video", Files: 2, Bytes: 800}}, snap.TopDirsByFiles)
+	assert.Equal(t, []TopEntry{{Path: "/video", Files: 2, Bytes: 800}, {Path: "/docs", Files: 1, Bytes: 50}}, snap.TopDirsByBytes)
+}
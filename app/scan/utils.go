@@ -1,6 +1,7 @@
 package scan
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -8,21 +9,19 @@ import (
 	"terrasync/db"
 	"terrasync/log"
 	"time"
+
+	"github.com/google/uuid"
 )
 
-// parseConditions parses and cleans condition lists
+// ParseConditions returns the condition list of a filter expression for
+// NewConditionFilter, empty when the expression is blank. The expression is
+// parsed as a whole, so "and", "or", "not" and parentheses combine conditions
+// and quoted values keep their spaces and case.
 func ParseConditions(input string) []string {
-	// Split conditions using "and"
-	conditions := strings.Split(strings.ToLower(input), "and")
-	// Clean whitespace around each condition and filter empty conditions
-	filteredConditions := []string{}
-	for _, cond := range conditions {
-		trimmed := strings.TrimSpace(cond)
-		if trimmed != "" {
-			filteredConditions = append(filteredConditions, trimmed)
-		}
+	if trimmed := strings.TrimSpace(input); trimmed != "" {
+		return []string{trimmed}
 	}
-	return filteredConditions
+	return []string{}
 }
 
 // NewDB open the database connection
@@ -44,14 +43,15 @@ func NewDB(dbType, jobsDir string) (*db.DB, error) {
 }
 
 // InitDatabase initializes the database connection
-func InitDatabase(dbType, jobsDir string) (*db.DB, error) {
+func InitDatabase(ctx context.Context, dbType, jobsDir string) (*db.DB, error) {
 	dbInstance, err := NewDB(dbType, jobsDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create database instance: %w", err)
 	}
 
-	if err := (*dbInstance).CreateTable("file_entries"); err != nil {
-		log.Errorf("failed to initialize database: %w", err)
+	if err := (*dbInstance).CreateTable(ctx, "file_entries"); err != nil {
+		log.Errorf("failed to initialize database: %v", err)
+		(*dbInstance).Close()
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 
@@ -76,7 +76,7 @@ func InitKafkaProducer(kafkaConfig KafkaConfig) (*KafkaProducer, error) {
 
 	// 创建Kafka生产者
 	startTime := time.Now()
-	producer, err := NewKafkaProducer(brokers)
+	producer, err := NewKafkaProducer(brokers, kafkaConfig.TLS)
 	if err != nil {
 		log.Errorf("Failed to create Kafka producer after %v: %v", time.Since(startTime), err)
 		return nil, fmt.Errorf("failed to create Kafka producer: %w", err)
@@ -109,3 +109,101 @@ func FormatFileSize(bytes int64) string {
 		return fmt.Sprintf("%d B", bytes)
 	}
 }
+
+// TempTablePrefix is the name prefix of the tables comparing an incremental scan with the previous one
+const TempTablePrefix = "temp_files_"
+
+// tempTableTimeLayout 是临时表名中的创建时间(UTC)，任何数据库类型都能据此判断临时表是否属于正在运行的扫描
+const tempTableTimeLayout = "20060102150405"
+
+// newTempTableName returns a unique temporary table name holding its creation time
+func newTempTableName(now time.Time) string {
+	return TempTablePrefix + now.UTC().Format(tempTableTimeLayout) + "_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// tempTableCreated returns the creation time in the name of a temporary table,
+// false for the names of earlier versions holding only a UUID
+func tempTableCreated(name string) (time.Time, bool) {
+	stamp, _, ok := strings.Cut(strings.TrimPrefix(name, TempTablePrefix), "_")
+	if !ok || len(stamp) != len(tempTableTimeLayout) {
+		return time.Time{}, false
+	}
+	created, err := time.Parse(tempTableTimeLayout, stamp)
+	return created, err == nil
+}
+
+// jobModTime returns the latest modification time of the job directory and its files
+func jobModTime(jobDir string) time.Time {
+	var latest time.Time
+	if info, err := os.Stat(jobDir); err == nil {
+		latest = info.ModTime()
+	}
+	entries, _ := os.ReadDir(jobDir)
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// DropTempTables drops the temporary tables left in the job database by
+// interrupted incremental scans and returns their names. Tables created after
+// before may belong to a running scan and are kept; the names of earlier
+// versions hold no creation time, those tables are kept while the job directory
+// was modified after before. With dryRun the tables are only listed.
+func DropTempTables(ctx context.Context, dbType, jobDir string, before time.Time, dryRun bool) ([]string, error) {
+	dbInstance, err := NewDB(dbType, jobDir)
+	if err != nil {
+		return nil, err
+	}
+	defer (*dbInstance).Close()
+
+	listTables := "SELECT name FROM sqlite_master WHERE type = 'table' AND name LIKE ?"
+	switch dbType {
+	case "postgres":
+		listTables = "SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_name LIKE $1"
+	case "mysql":
+		listTables = "SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name LIKE ?"
+	}
+	rows, err := (*dbInstance).Query(ctx, listTables, TempTablePrefix+"%")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables of %s: %w", jobDir, err)
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to list tables of %s: %w", jobDir, err)
+		}
+		created, ok := tempTableCreated(name)
+		if !ok {
+			created = jobModTime(jobDir)
+		}
+		if created.After(before) {
+			continue
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if dryRun || len(tables) == 0 {
+		return tables, nil
+	}
+
+	for _, name := range tables {
+		if err := (*dbInstance).DropTable(ctx, name); err != nil {
+			return nil, err
+		}
+	}
+	// 删除表不会缩小SQLite数据库文件，服务器自行回收空间
+	if dbType == "postgres" || dbType == "mysql" {
+		return tables, nil
+	}
+	vacuum, err := (*dbInstance).Query(ctx, "VACUUM")
+	if err != nil {
+		return tables, fmt.Errorf("failed to vacuum %s: %w", jobDir, err)
+	}
+	vacuum.Close()
+	return tables, nil
+}
@@ -0,0 +1,221 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"terrasync/log"
+	"terrasync/object"
+	"terrasync/security"
+	"time"
+)
+
+const (
+	defaultWebhookBatchSize  = 500
+	defaultWebhookMaxLatency = 5 * time.Second
+	defaultWebhookRetries    = 3
+	defaultWebhookRetryWait  = time.Second
+	defaultWebhookTimeout    = 30 * time.Second
+)
+
+// WebhookConfig configures the webhook sink posting NDJSON batches of scan
+// events, for services that can't consume Kafka. Zero values use the defaults.
+type WebhookConfig struct {
+	URL        string           // 接收事件的URL，为空表示不启用
+	AuthHeader string           // 每个请求附带的头，格式为"Name: value"，例如"Authorization: Bearer <token>"
+	BatchSize  int              // 每个POST的事件数
+	MaxLatency time.Duration    // 未满的批次等待超过该时间时发送
+	Retries    int              // 失败的POST按指数退避重试的次数，<0表示不重试
+	RetryWait  time.Duration    // 第一次重试前的等待时间，之后每次加倍
+	Timeout    time.Duration    // 单个POST的超时
+	Overflow   OverflowStrategy // 发送队列满时的处理: block, drop 或 spool
+}
+
+// Enabled reports whether a webhook URL is configured
+func (c WebhookConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+// Validate checks the URL and the auth header
+func (c WebhookConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook url %q, expect http(s)://host/path", c.URL)
+	}
+	if c.AuthHeader != "" {
+		if name, _, ok := strings.Cut(c.AuthHeader, ":"); !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("invalid webhook auth header, expect \"Name: value\"")
+		}
+	}
+	return nil
+}
+
+// withDefaults fills in the defaults of the zero values
+func (c WebhookConfig) withDefaults() WebhookConfig {
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultWebhookBatchSize
+	}
+	if c.MaxLatency <= 0 {
+		c.MaxLatency = defaultWebhookMaxLatency
+	}
+	if c.Retries == 0 {
+		c.Retries = defaultWebhookRetries
+	}
+	if c.RetryWait <= 0 {
+		c.RetryWait = defaultWebhookRetryWait
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultWebhookTimeout
+	}
+	return c
+}
+
+// webhookEvent is a line of the NDJSON body
+type webhookEvent struct {
+	JobID string    `json:"job_id"`
+	Event EventType `json:"event"`
+	Path  string    `json:"path"`
+	Size  int64     `json:"size"`
+	Mode  string    `json:"mode"`
+	MTime time.Time `json:"mtime"`
+	CTime time.Time `json:"ctime"`
+	ATime time.Time `json:"atime"`
+}
+
+// webhookSink posts the scanned entries as NDJSON, one event per line
+type webhookSink struct {
+	config WebhookConfig
+	jobID  string
+	poster *httpPoster
+	header http.Header
+}
+
+// newWebhookSink creates the sink of a validated webhook config
+func newWebhookSink(config WebhookConfig, jobID string) *webhookSink {
+	config = config.withDefaults()
+	s := &webhookSink{
+		config: config,
+		jobID:  jobID,
+		poster: newHTTPPoster(config.Timeout, config.Retries, config.RetryWait),
+		header: http.Header{"Content-Type": {"application/x-ndjson"}},
+	}
+	if name, value, ok := strings.Cut(config.AuthHeader, ":"); ok {
+		s.header.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return s
+}
+
+// sinkConfig returns the dispatcher config of the sink
+func (s *webhookSink) sinkConfig(spoolPath string) SinkConfig {
+	return SinkConfig{
+		Sink:       s,
+		QueueLen:   s.config.BatchSize,
+		MaxBatch:   s.config.BatchSize,
+		MaxLatency: s.config.MaxLatency,
+		Overflow:   s.config.Overflow,
+		SpoolPath:  spoolPath,
+	}
+}
+
+func (s *webhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *webhookSink) Write(ctx context.Context, batch []object.FileInfo) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, fileInfo := range batch {
+		event := EventFiles
+		if fileInfo.IsDir() {
+			event = EventDirectories
+		}
+		if err := enc.Encode(webhookEvent{
+			JobID: s.jobID,
+			Event: event,
+			Path:  fileInfo.Key(),
+			Size:  fileInfo.Size(),
+			Mode:  fileInfo.Perm().String(),
+			MTime: fileInfo.MTime().UTC(),
+			CTime: fileInfo.CTime().UTC(),
+			ATime: fileInfo.ATime().UTC(),
+		}); err != nil {
+			return fmt.Errorf("failed to encode webhook event: %w", err)
+		}
+	}
+
+	return s.poster.post(ctx, s.config.URL, s.header, body.Bytes(), fmt.Sprintf("webhook post of %d events", len(batch)))
+}
+
+// httpPoster posts request bodies, retrying failures that may be temporary
+type httpPoster struct {
+	client    *http.Client
+	retries   int
+	retryWait time.Duration
+}
+
+func newHTTPPoster(timeout time.Duration, retries int, retryWait time.Duration) *httpPoster {
+	// TLS配置遵循当前加密模式(FIPS模式下限制协议版本和密码套件)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = security.TLSConfig()
+	return &httpPoster{
+		client:    &http.Client{Transport: transport, Timeout: timeout},
+		retries:   retries,
+		retryWait: retryWait,
+	}
+}
+
+// post sends body to target, network errors, 5xx and 429 are retried with exponential backoff
+func (p *httpPoster) post(ctx context.Context, target string, header http.Header, body []byte, desc string) error {
+	wait := p.retryWait
+	for attempt := 0; ; attempt++ {
+		retry, err := p.postOnce(ctx, target, header, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= p.retries {
+			return err
+		}
+		log.Warnf("%s failed, retry in %v: %v", desc, wait, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// postOnce sends a body once, retry reports whether the failure may be temporary
+func (p *httpPoster) postOnce(ctx context.Context, target string, header http.Header, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("failed to post to %s: %w", req.URL.Redacted(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%s returned %s: %s", req.URL.Redacted(), resp.Status, strings.TrimSpace(string(msg)))
+	// 服务端错误及限流可以重试，其他客户端错误重试也不会成功
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, err
+}
@@ -0,0 +1,111 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// webhookServer 记录收到的事件，前failures个请求返回status
+type webhookServer struct {
+	mu       sync.Mutex
+	requests int
+	failures int
+	status   int
+	auth     []string
+	events   []webhookEvent
+}
+
+func (s *webhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	s.auth = append(s.auth, r.Header.Get("Authorization"))
+	if s.requests <= s.failures {
+		http.Error(w, "unavailable", s.status)
+		return
+	}
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var event webhookEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err == nil {
+			s.events = append(s.events, event)
+		}
+	}
+}
+
+// TestWebhookSink 测试按批次发送NDJSON事件及失败重试
+func TestWebhookSink(t *testing.T) {
+	entries := dispatchEntries(t, 5)
+	tests := []struct {
+		name     string
+		failures int
+		status   int
+		requests int
+		events   int
+		wantErr  bool
+	}{
+		{"按批次发送", 0, 0, 3, 5, false},
+		{"服务端错误后重试", 2, http.StatusServiceUnavailable, 5, 5, false},
+		{"客户端错误不重试", 1, http.StatusBadRequest, 3, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &webhookServer{failures: tt.failures, status: tt.status}
+			ts := httptest.NewServer(server)
+			defer ts.Close()
+
+			config := WebhookConfig{URL: ts.URL, AuthHeader: "Authorization: Bearer secret", BatchSize: 2, RetryWait: time.Millisecond}
+			assert.NoError(t, config.Validate())
+			webhook := newWebhookSink(config, "job")
+			dispatcher, err := NewDispatcher(context.Background(), webhook.sinkConfig(""))
+			assert.NoError(t, err)
+			for _, fileInfo := range entries {
+				dispatcher.Dispatch(fileInfo)
+			}
+			stats := dispatcher.Close()
+
+			assert.Equal(t, tt.requests, server.requests)
+			assert.Len(t, server.events, tt.events)
+			assert.Equal(t, tt.wantErr, stats[0].Err != nil)
+			assert.Equal(t, "Bearer secret", server.auth[0])
+			if len(server.events) > 0 {
+				assert.Equal(t, "job", server.events[0].JobID)
+				assert.Equal(t, EventFiles, server.events[0].Event)
+				assert.Equal(t, int64(4), server.events[0].Size)
+			}
+		})
+	}
+}
+
+// TestWebhookConfigValidate 测试webhook配置的校验
+func TestWebhookConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  WebhookConfig
+		wantErr bool
+	}{
+		{"未启用", WebhookConfig{}, false},
+		{"HTTPS及认证头", WebhookConfig{URL: "https://cmdb.example.com/events", AuthHeader: "X-Api-Key: abc"}, false},
+		{"不支持的协议", WebhookConfig{URL: "ftp://cmdb.example.com/events"}, true},
+		{"没有主机", WebhookConfig{URL: "http:///events"}, true},
+		{"认证头缺少名称", WebhookConfig{URL: "https://cmdb.example.com", AuthHeader: "Bearer abc"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
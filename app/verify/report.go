@@ -0,0 +1,78 @@
+package verify
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"terrasync/i18n"
+	"terrasync/pkg/report"
+	"terrasync/security"
+	"time"
+)
+
+// console prints the verification report to stdout and the log
+var console = report.NewPrinter(os.Stdout)
+
+// PrintReport prints the verification summary, jobErr is reported as the job status
+func PrintReport(config VerifyConfig, result *Result, jobErr error) {
+	fmt.Println()
+	console.Title("Verify Statistics")
+
+	console.Header("Command", config.CmdLine)
+	console.Header("Start time", i18n.FormatTime(config.StartTime))
+	totalTime := time.Since(config.StartTime)
+	console.Header("Total time", totalTime.Round(time.Second))
+	console.Header("Source", config.Source)
+	console.Header("Destination", config.Destination)
+	if config.ReportPath != "" {
+		console.Header("Report", config.ReportPath)
+	}
+	if config.Checksum != "" {
+		console.Header("Checksum", config.Checksum)
+	}
+	console.Header("Crypto mode", security.Mode())
+	console.Status(jobErr)
+
+	if result == nil {
+		console.Summary(summaryPairs(nil, totalTime, jobErr), jobErr)
+		return
+	}
+
+	if config.Attrs {
+		console.Section("Attributes")
+	} else {
+		console.Section("Comparison")
+	}
+	console.Field("Checked", result.Checked)
+	console.Field("In sync", result.InSync)
+	console.Field("Drifted", result.Drifted)
+	console.Field("Extra", result.Extra)
+
+	if len(result.Fields) > 0 {
+		console.Section("Drift")
+		fields := make([]string, 0, len(result.Fields))
+		for field := range result.Fields {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			console.Printf("  %-18s%*d\n", field+":", report.FieldWidth(2+18), result.Fields[field])
+		}
+	}
+	console.Summary(summaryPairs(result, totalTime, jobErr), jobErr)
+}
+
+// summaryPairs returns the key=value pairs of the SUMMARY line printed after the
+// report, only the status when the verification did not start
+func summaryPairs(result *Result, totalTime time.Duration, jobErr error) []report.Pair {
+	pairs := []report.Pair{{Key: "status", Value: report.StatusOf(jobErr)}}
+	if result != nil {
+		pairs = append(pairs,
+			report.Pair{Key: "checked", Value: result.Checked},
+			report.Pair{Key: "in_sync", Value: result.InSync},
+			report.Pair{Key: "drifted", Value: result.Drifted},
+			report.Pair{Key: "extra", Value: result.Extra},
+		)
+	}
+	return append(pairs, report.Pair{Key: "elapsed_sec", Value: int64(totalTime.Round(time.Second).Seconds())})
+}
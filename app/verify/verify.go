@@ -0,0 +1,390 @@
+package verify
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"terrasync/app/migrate"
+	"terrasync/app/scan"
+	"terrasync/i18n"
+	"terrasync/log"
+	"terrasync/object"
+	"terrasync/security"
+	"time"
+)
+
+// VerifyConfig 校验配置选项
+type VerifyConfig struct {
+	Source      string
+	Destination string
+	Concurrency int      // 列举源目录及查询目标的并发数
+	Depth       int      // 校验深度，0表示所有子目录
+	Match       []string // 只校验匹配的文件
+	Exclude     []string // 不校验匹配的文件
+	Attrs       bool     // 比较全部元数据(权限、所有者、ACL)，否则只比较大小和修改时间
+	Checksum    string   // 同时比较文件内容的哈希算法(md5、sha256)，为空不读取文件内容
+	ReportPath  string   // 差异报告(CSV)的保存路径，为空不生成
+	CmdLine     string
+	StartTime   time.Time
+}
+
+// FieldDrift is one attribute differing between source and destination.
+// Sizes and times are kept raw (bytes, Unix seconds) with a readable form beside them.
+type FieldDrift struct {
+	Field            string // missing, extra, type, size, mtime, checksum, perm, owner, acl
+	Source           string
+	Destination      string
+	SourceHuman      string // 可读形式，为空时与Source相同
+	DestinationHuman string
+}
+
+// Result holds the outcome of a verification
+type Result struct {
+	Checked int64
+	InSync  int64
+	Drifted int64
+	Extra   int64            // 目标端有而源端没有的条目数
+	Fields  map[string]int64 // 每种差异的文件数
+}
+
+// Start compares every source entry with the destination entry of the same key
+// and reports drift: entries missing from the destination, differing in type,
+// size or modification time, or with config.Attrs in any metadata. With
+// config.Checksum the contents of files of the same size are hashed on both
+// sides too. The destination is then listed for extra entries that are not in
+// the source.
+func Start(ctx context.Context, config VerifyConfig) (*Result, error) {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 5
+	}
+	if config.Checksum != "" {
+		if _, err := security.NewHash(config.Checksum); err != nil {
+			return nil, err
+		}
+	}
+	compare := compareQuick
+	if config.Attrs {
+		compare = compareAttrs
+	}
+
+	srcStorage, err := object.CreateStorage(config.Source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create source storage: %w", err)
+	}
+	defer srcStorage.Close()
+	dstStorage, err := object.CreateStorage(config.Destination)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create destination storage: %w", err)
+	}
+	defer dstStorage.Close()
+
+	matchConditions, err := scan.NewConditionFilter(config.Match)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create match conditions: %w", err)
+	}
+	excludeConditions, err := scan.NewConditionFilter(config.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exclude conditions: %w", err)
+	}
+
+	report, err := newDriftReport(config.ReportPath)
+	if err != nil {
+		return nil, err
+	}
+	defer report.Close()
+
+	result := &Result{Fields: make(map[string]int64)}
+	var fieldsMu sync.Mutex
+	record := func(key string, drifts []FieldDrift) {
+		fieldsMu.Lock()
+		for _, d := range drifts {
+			result.Fields[d.Field]++
+		}
+		fieldsMu.Unlock()
+		report.Write(key, drifts)
+	}
+	opts := scan.ListOptions{
+		Concurrency: config.Concurrency,
+		Depth:       config.Depth,
+		Match:       matchConditions,
+		Exclude:     excludeConditions,
+	}
+
+	// 多个worker并发查询目标端元数据
+	files := scan.ListAll(ctx, srcStorage, opts)
+	var wg sync.WaitGroup
+	for i := 0; i < config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for src := range files {
+				drifts, err := compare(src, dstStorage)
+				if err == nil && len(drifts) == 0 && config.Checksum != "" && src.IsRegular() {
+					drifts, err = compareChecksum(src, dstStorage, config.Checksum)
+				}
+				if err != nil {
+					log.Errorf("Verify %s error: %v", src.Key(), err)
+					drifts = []FieldDrift{{Field: "error", Source: err.Error()}}
+				}
+
+				atomic.AddInt64(&result.Checked, 1)
+				if len(drifts) == 0 {
+					atomic.AddInt64(&result.InSync, 1)
+					continue
+				}
+				atomic.AddInt64(&result.Drifted, 1)
+				record(src.Key(), drifts)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 列举目标端，找出源端没有的条目
+	if ctx.Err() == nil {
+		extras := scan.ListAll(ctx, dstStorage, opts)
+		for i := 0; i < config.Concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for dst := range extras {
+					if dst.Key() == migrate.MarkerKey {
+						continue
+					}
+					srcInfo, err := srcStorage.Head(dst.Key())
+					if err != nil {
+						log.Errorf("Verify %s error: %v", dst.Key(), err)
+						continue
+					}
+					if srcInfo == nil {
+						atomic.AddInt64(&result.Extra, 1)
+						record(dst.Key(), []FieldDrift{{Field: "extra", Destination: fileType(dst)}})
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	if err := report.Close(); err != nil {
+		return result, fmt.Errorf("failed to write drift report: %w", err)
+	}
+	return result, ctx.Err()
+}
+
+// compareBasic compares the presence, type and size of a source entry with its
+// destination, done is set when the entries cannot be compared further
+func compareBasic(src, dstInfo object.FileInfo) (drifts []FieldDrift, done bool) {
+	if dstInfo == nil {
+		return []FieldDrift{{Field: "missing", Source: fileType(src)}}, true
+	}
+	if src.IsDir() != dstInfo.IsDir() || src.IsSymlink() != dstInfo.IsSymlink() {
+		return []FieldDrift{{Field: "type", Source: fileType(src), Destination: fileType(dstInfo)}}, true
+	}
+	// 目录大小在不同文件系统上没有可比性
+	if !src.IsDir() && src.Size() != dstInfo.Size() {
+		drifts = append(drifts, FieldDrift{
+			Field:            "size",
+			Source:           fmt.Sprint(src.Size()),
+			Destination:      fmt.Sprint(dstInfo.Size()),
+			SourceHuman:      scan.FormatFileSize(src.Size()),
+			DestinationHuman: scan.FormatFileSize(dstInfo.Size()),
+		})
+	}
+	return drifts, false
+}
+
+// compareQuick compares the type, size and modification time of a source entry with its destination
+func compareQuick(src object.FileInfo, dst object.Storage) ([]FieldDrift, error) {
+	dstInfo, err := dst.Head(src.Key())
+	if err != nil {
+		return nil, err
+	}
+	drifts, done := compareBasic(src, dstInfo)
+	if done {
+		return drifts, nil
+	}
+	// 目录的修改时间随其中的条目变化，符号链接的时间通常不被保留；不同存储的时间精度不同，按秒比较
+	// S3等对象存储的修改时间是上传时间，不比较
+	if src.IsRegular() && object.KeepsMTime(dst) && src.MTime().Unix() != dstInfo.MTime().Unix() {
+		drifts = append(drifts, FieldDrift{
+			Field:            "mtime",
+			Source:           fmt.Sprint(src.MTime().Unix()),
+			Destination:      fmt.Sprint(dstInfo.MTime().Unix()),
+			SourceHuman:      i18n.FormatTime(src.MTime()),
+			DestinationHuman: i18n.FormatTime(dstInfo.MTime()),
+		})
+	}
+	return drifts, nil
+}
+
+// compareChecksum compares the digests of the contents of a source file and its destination
+func compareChecksum(src object.FileInfo, dst object.Storage, algorithm string) ([]FieldDrift, error) {
+	dstInfo, err := dst.Head(src.Key())
+	if err != nil {
+		return nil, err
+	}
+	if dstInfo == nil {
+		return []FieldDrift{{Field: "missing", Source: fileType(src)}}, nil
+	}
+	srcSum, err := checksum(src, algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source: %w", err)
+	}
+	dstSum, err := checksum(dstInfo, algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read destination: %w", err)
+	}
+	if srcSum != dstSum {
+		return []FieldDrift{{Field: "checksum", Source: srcSum, Destination: dstSum}}, nil
+	}
+	return nil, nil
+}
+
+// checksum returns the hex digest of the contents of a file
+func checksum(fileInfo object.FileInfo, algorithm string) (string, error) {
+	h, err := security.NewHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	in, err := fileInfo.Get(0, -1)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	buf := object.GetBuffer()
+	defer object.PutBuffer(buf)
+	if _, err := io.CopyBuffer(h, in, *buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// compareAttrs compares the metadata of a source entry with its destination
+func compareAttrs(src object.FileInfo, dst object.Storage) ([]FieldDrift, error) {
+	dstInfo, err := dst.Head(src.Key())
+	if err != nil {
+		return nil, err
+	}
+	drifts, done := compareBasic(src, dstInfo)
+	if done {
+		return drifts, nil
+	}
+
+	srcMeta, err := object.MetadataOf(src)
+	if err != nil {
+		return nil, err
+	}
+	dstMeta, err := object.MetadataOf(dstInfo)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range srcMeta.Diff(dstMeta) {
+		d := FieldDrift{Field: field}
+		switch field {
+		case "perm":
+			d.Source, d.Destination = srcMeta.Perm.String(), dstMeta.Perm.String()
+		case "owner":
+			d.Source, d.Destination = fmt.Sprintf("%d:%d", srcMeta.UID, srcMeta.GID), fmt.Sprintf("%d:%d", dstMeta.UID, dstMeta.GID)
+		case "acl":
+			d.Source, d.Destination = aclHash(srcMeta.ACL), aclHash(dstMeta.ACL)
+		case "mtime":
+			d.Source, d.Destination = fmt.Sprint(srcMeta.MTime.Unix()), fmt.Sprint(dstMeta.MTime.Unix())
+			d.SourceHuman, d.DestinationHuman = i18n.FormatTime(srcMeta.MTime), i18n.FormatTime(dstMeta.MTime)
+		}
+		drifts = append(drifts, d)
+	}
+	return drifts, nil
+}
+
+func fileType(fileInfo object.FileInfo) string {
+	switch {
+	case fileInfo.IsDir():
+		return "dir"
+	case fileInfo.IsSymlink():
+		return "symlink"
+	default:
+		return "file"
+	}
+}
+
+// aclHash returns a short digest of the ACLs, so that large ACLs fit in the report
+func aclHash(acl map[string][]byte) string {
+	if len(acl) == 0 {
+		return "none"
+	}
+	h, err := security.NewHash("sha256")
+	if err != nil {
+		return "unknown"
+	}
+	names := make([]string, 0, len(acl))
+	for name := range acl {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h.Write([]byte(name))
+		h.Write(acl[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// driftReport writes drifted entries as CSV, one row per field
+type driftReport struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *csv.Writer
+}
+
+func newDriftReport(path string) (*driftReport, error) {
+	r := &driftReport{}
+	if path == "" {
+		return r, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create drift report: %w", err)
+	}
+	r.file = f
+	r.writer = csv.NewWriter(f)
+	_ = r.writer.Write([]string{"key", "field", "source", "destination", "source_human", "destination_human"})
+	return r, nil
+}
+
+// Write records the drifts of one entry
+func (r *driftReport) Write(key string, drifts []FieldDrift) {
+	if r.writer == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range drifts {
+		sourceHuman, destinationHuman := d.SourceHuman, d.DestinationHuman
+		if sourceHuman == "" && destinationHuman == "" {
+			sourceHuman, destinationHuman = d.Source, d.Destination
+		}
+		_ = r.writer.Write([]string{key, d.Field, d.Source, d.Destination, sourceHuman, destinationHuman})
+	}
+}
+
+// Close flushes the report, it can be called more than once
+func (r *driftReport) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	r.writer.Flush()
+	err := r.writer.Error()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	r.file = nil
+	r.writer = nil
+	return err
+}
@@ -0,0 +1,160 @@
+package verify
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"terrasync/log"
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func init() {
+	log.Log = zap.NewNop().Sugar()
+}
+
+// TestCompareAttrs 测试源和目标的元数据比较
+func TestCompareAttrs(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	mtime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	write := func(dir, name, data string) {
+		p := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(p, []byte(data), 0644))
+		assert.NoError(t, os.Chtimes(p, mtime, mtime))
+	}
+	write(srcDir, "same.txt", "abc")
+	write(dstDir, "same.txt", "abc")
+	write(srcDir, "size.txt", "abc")
+	write(dstDir, "size.txt", "abcd")
+	write(srcDir, "missing.txt", "abc")
+	write(srcDir, "type", "abc")
+	assert.NoError(t, os.Mkdir(filepath.Join(dstDir, "type"), 0755))
+
+	src, err := object.CreateStorage(srcDir)
+	assert.NoError(t, err)
+	dst, err := object.CreateStorage(dstDir)
+	assert.NoError(t, err)
+
+	cases := []struct {
+		name   string
+		key    string
+		fields []string
+	}{
+		{name: "一致", key: "/same.txt"},
+		{name: "大小不同", key: "/size.txt", fields: []string{"size"}},
+		{name: "目标缺失", key: "/missing.txt", fields: []string{"missing"}},
+		{name: "类型不同", key: "/type", fields: []string{"type"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			srcInfo, err := src.Head(c.key)
+			assert.NoError(t, err)
+			drifts, err := compareAttrs(srcInfo, dst)
+			assert.NoError(t, err)
+			var fields []string
+			for _, d := range drifts {
+				fields = append(fields, d.Field)
+			}
+			sort.Strings(fields)
+			assert.Equal(t, c.fields, fields)
+		})
+	}
+}
+
+// TestStart 测试比较两棵树：缺失、多余、修改时间及内容不同的文件
+func TestStart(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	mtime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	write := func(dir, name, data string, mtime time.Time) {
+		p := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		assert.NoError(t, os.WriteFile(p, []byte(data), 0644))
+		assert.NoError(t, os.Chtimes(p, mtime, mtime))
+	}
+	write(srcDir, "a/same.txt", "abc", mtime)
+	write(dstDir, "a/same.txt", "abc", mtime)
+	write(srcDir, "a/content.txt", "abc", mtime)
+	write(dstDir, "a/content.txt", "xyz", mtime)
+	write(srcDir, "mtime.txt", "abc", mtime)
+	write(dstDir, "mtime.txt", "abc", mtime.Add(time.Hour))
+	write(srcDir, "missing.txt", "abc", mtime)
+	write(dstDir, "extra.txt", "abc", mtime)
+
+	cases := []struct {
+		name     string
+		checksum string
+		drifted  int64
+		fields   map[string]int64
+	}{
+		{name: "比较大小和修改时间", drifted: 2, fields: map[string]int64{"mtime": 1, "missing": 1, "extra": 1}},
+		{name: "比较校验和", checksum: "sha256", drifted: 3, fields: map[string]int64{"mtime": 1, "missing": 1, "extra": 1, "checksum": 1}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := Start(context.Background(), VerifyConfig{
+				Source:      srcDir,
+				Destination: dstDir,
+				Checksum:    c.checksum,
+				ReportPath:  filepath.Join(t.TempDir(), "verify.csv"),
+			})
+			assert.NoError(t, err)
+			// 源端的a目录及4个文件
+			assert.Equal(t, int64(5), result.Checked)
+			assert.Equal(t, c.drifted, result.Drifted)
+			assert.Equal(t, int64(1), result.Extra)
+			assert.Equal(t, c.fields, result.Fields)
+		})
+	}
+
+	_, err := Start(context.Background(), VerifyConfig{Source: srcDir, Destination: dstDir, Checksum: "crc"})
+	assert.Error(t, err)
+}
+
+// uploadTimeStorage 模拟修改时间为上传时间的对象存储
+type uploadTimeStorage struct {
+	object.Storage
+}
+
+func (uploadTimeStorage) MTimeIsUploadTime() bool {
+	return true
+}
+
+// TestCompareQuickUploadTime 测试目标的修改时间为上传时间时不比较修改时间
+func TestCompareQuickUploadTime(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	mtime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	for dir, mtime := range map[string]time.Time{srcDir: mtime, dstDir: mtime.Add(time.Hour)} {
+		p := filepath.Join(dir, "a.txt")
+		assert.NoError(t, os.WriteFile(p, []byte("abc"), 0644))
+		assert.NoError(t, os.Chtimes(p, mtime, mtime))
+	}
+	src, err := object.CreateStorage(srcDir)
+	assert.NoError(t, err)
+	dst, err := object.CreateStorage(dstDir)
+	assert.NoError(t, err)
+	srcInfo, err := src.Head("/a.txt")
+	assert.NoError(t, err)
+
+	drifts, err := compareQuick(srcInfo, dst)
+	assert.NoError(t, err)
+	assert.Len(t, drifts, 1)
+	// 只读包装转发该能力
+	drifts, err = compareQuick(srcInfo, object.ReadOnly(uploadTimeStorage{dst}))
+	assert.NoError(t, err)
+	assert.Empty(t, drifts)
+}
+
+// TestACLHash 测试ACL摘要与顺序无关
+func TestACLHash(t *testing.T) {
+	assert.Equal(t, "none", aclHash(nil))
+	a := aclHash(map[string][]byte{"x": []byte("1"), "y": []byte("2")})
+	b := aclHash(map[string][]byte{"y": []byte("2"), "x": []byte("1")})
+	assert.Equal(t, a, b)
+	assert.Len(t, a, 16)
+}
@@ -0,0 +1,198 @@
+//go:build linux
+
+package watch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+
+	"terrasync/changelist"
+
+	"golang.org/x/sys/unix"
+)
+
+// fanotifyMask selects the directory entry and modification events, FAN_ONDIR
+// reports them for directories as well
+const fanotifyMask = unix.FAN_CREATE | unix.FAN_DELETE | unix.FAN_MOVED_FROM | unix.FAN_MOVED_TO |
+	unix.FAN_MODIFY | unix.FAN_ATTRIB | unix.FAN_ONDIR
+
+// maxCachedDirs bounds the cache of directory handles resolved to paths
+const maxCachedDirs = 64 * 1024
+
+// fanotifyWatcher reads the events of the whole filesystem of the root with
+// fanotify (Linux 5.9+, CAP_SYS_ADMIN and CAP_DAC_READ_SEARCH). Events name the
+// file handle of the parent directory and the entry name, the handle is opened
+// with open_by_handle_at to find the directory path.
+type fanotifyWatcher struct {
+	fd      int
+	mountFd int               // 根目录，open_by_handle_at所在的文件系统
+	dirs    map[string]string // 目录句柄 -> 路径
+	buf     []byte
+}
+
+func newWatcher(root string) (watcher, error) {
+	fd, err := unix.FanotifyInit(unix.FAN_CLASS_NOTIF|unix.FAN_CLOEXEC|unix.FAN_NONBLOCK|unix.FAN_REPORT_DFID_NAME,
+		unix.O_RDONLY|unix.O_LARGEFILE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize fanotify (Linux 5.9 and root privileges are required): %w", err)
+	}
+	if err := unix.FanotifyMark(fd, unix.FAN_MARK_ADD|unix.FAN_MARK_FILESYSTEM, fanotifyMask, unix.AT_FDCWD, root); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to watch the filesystem of %s: %w", root, err)
+	}
+	mountFd, err := unix.Open(root, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to open %s: %w", root, err)
+	}
+	return &fanotifyWatcher{fd: fd, mountFd: mountFd, dirs: make(map[string]string), buf: make([]byte, 256*1024)}, nil
+}
+
+func (w *fanotifyWatcher) read(timeout time.Duration) ([]Event, error) {
+	fds := []unix.PollFd{{Fd: int32(w.fd), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, int(timeout/time.Millisecond))
+	if errors.Is(err, unix.EINTR) || n == 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	n, err = unix.Read(w.fd, w.buf)
+	if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	events, err := parseFanotifyEvents(w.buf[:n], w.dirPath)
+	for _, event := range events {
+		if event.Dir && event.Op != changelist.JournalCreate && event.Op != changelist.JournalModify {
+			w.forget()
+			break
+		}
+	}
+	return events, err
+}
+
+// dirPath returns the current path of a directory handle, the cached paths are
+// dropped by read when a directory was moved or deleted
+func (w *fanotifyWatcher) dirPath(handleType int32, handle []byte) (string, error) {
+	key := strconv.Itoa(int(handleType)) + ":" + string(handle)
+	if p, ok := w.dirs[key]; ok {
+		return p, nil
+	}
+	fd, err := unix.OpenByHandleAt(w.mountFd, unix.NewFileHandle(handleType, handle), unix.O_PATH|unix.O_CLOEXEC)
+	if err != nil {
+		return "", err
+	}
+	defer unix.Close(fd)
+	p, err := readlink("/proc/self/fd/" + strconv.Itoa(fd))
+	if err != nil {
+		return "", err
+	}
+	if len(w.dirs) >= maxCachedDirs {
+		clear(w.dirs)
+	}
+	w.dirs[key] = p
+	return p, nil
+}
+
+// forget drops the cached directory paths after a directory was moved or deleted
+func (w *fanotifyWatcher) forget() {
+	clear(w.dirs)
+}
+
+func readlink(p string) (string, error) {
+	buf := make([]byte, unix.PathMax)
+	n, err := unix.Readlink(p, buf)
+	if err != nil {
+		return "", err
+	}
+	return string(buf[:n]), nil
+}
+
+func (w *fanotifyWatcher) Close() error {
+	unix.Close(w.mountFd)
+	return unix.Close(w.fd)
+}
+
+// fanotifyOps maps the bits of an event mask to journal ops, in the order the
+// changes of a merged event happened
+var fanotifyOps = []struct {
+	mask uint64
+	op   string
+}{
+	{unix.FAN_MOVED_FROM, changelist.JournalMoveFrom},
+	{unix.FAN_CREATE, changelist.JournalCreate},
+	{unix.FAN_MOVED_TO, changelist.JournalMoveTo},
+	{unix.FAN_MODIFY | unix.FAN_ATTRIB, changelist.JournalModify},
+	{unix.FAN_DELETE, changelist.JournalDelete},
+}
+
+// parseFanotifyEvents returns the events of a buffer read from a fanotify
+// descriptor initialized with FAN_REPORT_DFID_NAME, resolve returns the path of
+// a directory handle. Events of directories that no longer exist are dropped,
+// their entries are gone as well.
+func parseFanotifyEvents(buf []byte, resolve func(handleType int32, handle []byte) (string, error)) ([]Event, error) {
+	ne := binary.NativeEndian
+	var events []Event
+	for len(buf) >= unix.FAN_EVENT_METADATA_LEN {
+		eventLen, metaLen := int(ne.Uint32(buf)), int(ne.Uint16(buf[6:]))
+		if buf[4] != unix.FANOTIFY_METADATA_VERSION {
+			return nil, fmt.Errorf("unsupported fanotify metadata version %d", buf[4])
+		}
+		if eventLen < metaLen || eventLen > len(buf) {
+			return nil, fmt.Errorf("invalid fanotify event length %d", eventLen)
+		}
+		mask := ne.Uint64(buf[8:])
+		if fd := int32(ne.Uint32(buf[16:])); fd >= 0 {
+			unix.Close(int(fd))
+		}
+		info := buf[metaLen:eventLen]
+		buf = buf[eventLen:]
+		if mask&unix.FAN_Q_OVERFLOW != 0 {
+			events = append(events, Event{Op: changelist.JournalOverflow})
+			continue
+		}
+
+		// 信息记录：类型、填充、长度，fsid，file_handle，以NUL结尾的名称
+		for len(info) >= 4 {
+			infoType, infoLen := info[0], int(ne.Uint16(info[2:]))
+			if infoLen < 4 || infoLen > len(info) {
+				return nil, fmt.Errorf("invalid fanotify info length %d", infoLen)
+			}
+			record := info[:infoLen]
+			info = info[infoLen:]
+			if infoType != unix.FAN_EVENT_INFO_TYPE_DFID_NAME || len(record) < 20 {
+				continue
+			}
+			handleLen, handleType := int(ne.Uint32(record[12:])), int32(ne.Uint32(record[16:]))
+			if 20+handleLen > len(record) {
+				return nil, fmt.Errorf("invalid fanotify file handle length %d", handleLen)
+			}
+			name := record[20+handleLen:]
+			for i, c := range name {
+				if c == 0 {
+					name = name[:i]
+					break
+				}
+			}
+			dir, err := resolve(handleType, record[20:20+handleLen])
+			if err != nil {
+				// 目录已被删除，其下的条目也已不存在
+				continue
+			}
+			p := path.Join(dir, string(name))
+			for _, bit := range fanotifyOps {
+				if mask&bit.mask != 0 {
+					events = append(events, Event{Op: bit.op, Path: p, Dir: mask&unix.FAN_ONDIR != 0})
+				}
+			}
+		}
+	}
+	return events, nil
+}
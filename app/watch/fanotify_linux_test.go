@@ -0,0 +1,69 @@
+//go:build linux
+
+package watch
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"terrasync/changelist"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+// TestParseFanotifyEvents 测试解析FAN_REPORT_DFID_NAME的事件
+func TestParseFanotifyEvents(t *testing.T) {
+	ne := binary.NativeEndian
+	// event 编码一个事件，目录句柄为handle，名称为name
+	event := func(mask uint64, handle byte, name string) []byte {
+		info := make([]byte, 20+8, 20+8+len(name)+1)
+		info[0] = unix.FAN_EVENT_INFO_TYPE_DFID_NAME
+		ne.PutUint32(info[12:], 8)
+		ne.PutUint32(info[16:], 1)
+		info[20] = handle
+		info = append(append(info, name...), 0)
+		for len(info)%4 != 0 {
+			info = append(info, 0)
+		}
+		ne.PutUint16(info[2:], uint16(len(info)))
+
+		buf := make([]byte, unix.FAN_EVENT_METADATA_LEN, unix.FAN_EVENT_METADATA_LEN+len(info))
+		ne.PutUint32(buf, uint32(unix.FAN_EVENT_METADATA_LEN+len(info)))
+		buf[4] = unix.FANOTIFY_METADATA_VERSION
+		ne.PutUint16(buf[6:], unix.FAN_EVENT_METADATA_LEN)
+		ne.PutUint64(buf[8:], mask)
+		ne.PutUint32(buf[16:], uint32(0xffffffff))
+		return append(buf, info...)
+	}
+	dirs := map[byte]string{1: "/data", 2: "/"}
+	resolve := func(handleType int32, handle []byte) (string, error) {
+		if p, ok := dirs[handle[0]]; ok {
+			return p, nil
+		}
+		return "", errors.New("stale file handle")
+	}
+
+	var buf []byte
+	buf = append(buf, event(unix.FAN_CREATE|unix.FAN_MODIFY, 1, "报告.txt")...)
+	buf = append(buf, event(unix.FAN_MOVED_TO|unix.FAN_ONDIR, 2, "data")...)
+	buf = append(buf, event(unix.FAN_DELETE, 9, "gone.txt")...)
+	buf = append(buf, event(unix.FAN_ATTRIB|unix.FAN_ONDIR, 1, ".")...)
+	overflow := event(unix.FAN_Q_OVERFLOW, 0, "")[:unix.FAN_EVENT_METADATA_LEN]
+	ne.PutUint32(overflow, unix.FAN_EVENT_METADATA_LEN)
+	buf = append(buf, overflow...)
+
+	events, err := parseFanotifyEvents(buf, resolve)
+	assert.NoError(t, err)
+	assert.Equal(t, []Event{
+		{Op: changelist.JournalCreate, Path: "/data/报告.txt"},
+		{Op: changelist.JournalModify, Path: "/data/报告.txt"},
+		{Op: changelist.JournalMoveTo, Path: "/data", Dir: true},
+		{Op: changelist.JournalModify, Path: "/data", Dir: true},
+		{Op: changelist.JournalOverflow},
+	}, events)
+
+	_, err = parseFanotifyEvents(buf[:30], resolve)
+	assert.ErrorContains(t, err, "invalid fanotify event length")
+}
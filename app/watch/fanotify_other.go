@@ -0,0 +1,12 @@
+//go:build !linux
+
+package watch
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func newWatcher(root string) (watcher, error) {
+	return nil, fmt.Errorf("the change journal collector requires fanotify, which is not available on %s", runtime.GOOS)
+}
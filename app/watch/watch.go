@@ -0,0 +1,134 @@
+// Package watch collects the file changes of a local filesystem into a change
+// journal while no scan is running, so the next incremental scan reads the
+// changed paths with journal:// instead of walking the whole tree.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"terrasync/changelist"
+	"terrasync/log"
+	"time"
+)
+
+// Config describes the directory watched by the collector
+type Config struct {
+	Root          string        // 采集变化的目录，绝对路径
+	Journal       string        // 变化日志文件
+	FlushInterval time.Duration // 写入日志的间隔，重复的修改在间隔内合并
+}
+
+// Event is a file change reported by the kernel, Op is one of the journal ops
+type Event struct {
+	Op   string
+	Path string // 绝对路径
+	Dir  bool
+}
+
+// watcher reads the file change events of the filesystem of a directory, with
+// fanotify on Linux
+type watcher interface {
+	// read waits up to timeout for events, returning none when there are none
+	read(timeout time.Duration) ([]Event, error)
+	Close() error
+}
+
+// Result counts what the collector recorded
+type Result struct {
+	Events   int64 // 记录的事件数
+	Merged   int64 // 在写入间隔内合并的重复修改
+	Overflow int64 // 内核事件队列溢出次数
+}
+
+// Run records the changes below config.Root into config.Journal until ctx is
+// cancelled
+func Run(ctx context.Context, config Config) (*Result, error) {
+	root, err := filepath.Abs(config.Root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid directory %s: %w", config.Root, err)
+	}
+	config.Root = root
+	w, err := newWatcher(root)
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+	return collect(ctx, config, w)
+}
+
+// collect appends the events of w below config.Root to the journal
+func collect(ctx context.Context, config Config, w watcher) (*Result, error) {
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	journal, err := changelist.OpenJournalWriter(config.Journal)
+	if err != nil {
+		return nil, err
+	}
+	// 出错时关闭，正常结束时由下面的Close返回写入的错误
+	defer journal.Close()
+	log.Infof("Recording changes of %s into %s", config.Root, config.Journal)
+
+	root := filepath.ToSlash(config.Root)
+	result := &Result{}
+	// 上次写入之后已记录修改的路径，频繁写入的文件在间隔内只记录一次
+	modified := make(map[string]struct{})
+	flushed := time.Now()
+	for ctx.Err() == nil {
+		events, err := w.read(config.FlushInterval)
+		if err != nil {
+			return result, fmt.Errorf("failed to read file change events: %w", err)
+		}
+		now := time.Now().UTC()
+		for _, event := range events {
+			if event.Op == changelist.JournalOverflow {
+				// 丢失的变化未知，下次扫描遍历目录树
+				log.Warnf("The file change event queue of %s overflowed, the next scan walks the tree", config.Root)
+				result.Overflow++
+				if err := journal.Append(changelist.JournalRecord{Time: now, Op: changelist.JournalOverflow}); err != nil {
+					return result, err
+				}
+				continue
+			}
+			p := filepath.ToSlash(event.Path)
+			if !within(root, p) {
+				continue
+			}
+			if event.Op == changelist.JournalModify {
+				if _, ok := modified[p]; ok {
+					result.Merged++
+					continue
+				}
+				modified[p] = struct{}{}
+			} else {
+				// 之后的修改可能属于同一路径上的新文件
+				delete(modified, p)
+			}
+			if err := journal.Append(changelist.JournalRecord{Time: now, Op: event.Op, Path: event.Path, Dir: event.Dir}); err != nil {
+				return result, err
+			}
+			result.Events++
+		}
+		if time.Since(flushed) >= config.FlushInterval {
+			if err := journal.Flush(); err != nil {
+				return result, err
+			}
+			clear(modified)
+			flushed = time.Now()
+		}
+	}
+	if err := journal.Close(); err != nil {
+		return result, err
+	}
+	log.Infof("Recorded %d changes of %s, merged %d repeated modifications, %d overflows", result.Events, config.Root, result.Merged, result.Overflow)
+	return result, nil
+}
+
+// within reports whether p is root or below it, both with slashes
+func within(root, p string) bool {
+	root, p = path.Clean(root), path.Clean(p)
+	return p == root || root == "/" || strings.HasPrefix(p, root+"/")
+}
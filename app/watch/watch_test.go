@@ -0,0 +1,104 @@
+package watch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"terrasync/changelist"
+	"terrasync/log"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// fakeWatcher 依次返回每批事件，读完后取消采集
+type fakeWatcher struct {
+	batches [][]Event
+	cancel  context.CancelFunc
+}
+
+func (w *fakeWatcher) read(timeout time.Duration) ([]Event, error) {
+	if len(w.batches) == 0 {
+		w.cancel()
+		return nil, nil
+	}
+	events := w.batches[0]
+	w.batches = w.batches[1:]
+	return events, nil
+}
+
+func (w *fakeWatcher) Close() error {
+	return nil
+}
+
+// TestCollect 测试把根目录下的事件写入变化日志并合并重复的修改
+func TestCollect(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	dir := t.TempDir()
+	journal := filepath.Join(dir, "data.journal")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &fakeWatcher{cancel: cancel, batches: [][]Event{
+		{
+			{Op: changelist.JournalCreate, Path: "/data/a.txt"},
+			{Op: changelist.JournalModify, Path: "/data/a.txt"},
+			{Op: changelist.JournalModify, Path: "/data/a.txt"},
+			{Op: changelist.JournalModify, Path: "/database/b.txt"},
+		},
+		{
+			{Op: changelist.JournalOverflow},
+			{Op: changelist.JournalMoveTo, Path: "/data/dir", Dir: true},
+			{Op: changelist.JournalDelete, Path: "/data/a.txt"},
+			{Op: changelist.JournalModify, Path: "/data/a.txt"},
+		},
+	}}
+
+	result, err := collect(ctx, Config{Root: "/data", Journal: journal, FlushInterval: time.Hour}, w)
+	assert.NoError(t, err)
+	assert.Equal(t, &Result{Events: 5, Merged: 1, Overflow: 1}, result)
+
+	f, err := os.Open(journal)
+	assert.NoError(t, err)
+	defer f.Close()
+	var records []changelist.JournalRecord
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		var record changelist.JournalRecord
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		record.Time, record.ID = time.Time{}, ""
+		records = append(records, record)
+	}
+	assert.Equal(t, []changelist.JournalRecord{
+		{Op: changelist.JournalStart},
+		{Op: changelist.JournalCreate, Path: "/data/a.txt"},
+		{Op: changelist.JournalModify, Path: "/data/a.txt"},
+		{Op: changelist.JournalOverflow},
+		{Op: changelist.JournalMoveTo, Path: "/data/dir", Dir: true},
+		{Op: changelist.JournalDelete, Path: "/data/a.txt"},
+		{Op: changelist.JournalModify, Path: "/data/a.txt"},
+	}, records)
+}
+
+// TestWithin 测试判断路径是否在根目录下
+func TestWithin(t *testing.T) {
+	tests := []struct {
+		name string
+		root string
+		path string
+		want bool
+	}{
+		{"根目录本身", "/data", "/data", true},
+		{"子目录", "/data/", "/data/a/b", true},
+		{"前缀相同的目录", "/data", "/database", false},
+		{"文件系统根目录", "/", "/etc/hosts", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, within(tt.root, tt.path))
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// Package audit records decisions auditors need to review, such as the safety
+// interlock of destructive migrations, in an append-only JSON lines file kept
+// apart from the rotated application log.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"sync"
+	"terrasync/log"
+	"time"
+)
+
+// Event is one audited decision
+type Event struct {
+	Time     time.Time      `json:"time"` // UTC
+	User     string         `json:"user"`
+	Host     string         `json:"host"`
+	PID      int            `json:"pid"`
+	Command  string         `json:"command,omitempty"`
+	Action   string         `json:"action"`   // 被审计的操作，如migrate.interlock
+	Decision string         `json:"decision"` // 操作的结果，如forced、confirmed、refused
+	Details  map[string]any `json:"details,omitempty"`
+}
+
+var (
+	mu   sync.Mutex
+	path string
+)
+
+// SetPath sets the audit log file, empty only writes events to the application log
+func SetPath(p string) {
+	mu.Lock()
+	defer mu.Unlock()
+	path = p
+}
+
+// Path returns the audit log file
+func Path() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return path
+}
+
+// Record appends an event to the audit log, filling in the time, user, host and
+// process. The event is also written to the application log.
+func Record(event Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Time = event.Time.UTC()
+	if event.User == "" {
+		if u, err := user.Current(); err == nil {
+			event.User = u.Username
+		}
+	}
+	if event.Host == "" {
+		event.Host, _ = os.Hostname()
+	}
+	if event.PID == 0 {
+		event.PID = os.Getpid()
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+	log.Infof("Audit: %s", data)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	// 每个事件一次写入，多个进程同时追加也不会交错
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"terrasync/log"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// TestRecord 测试审计事件追加到审计日志，每行一个JSON事件
+func TestRecord(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	SetPath(path)
+	defer SetPath("")
+
+	assert.NoError(t, Record(Event{Action: "migrate.interlock", Decision: "forced", Details: map[string]any{"at_risk": 1500}}))
+	assert.NoError(t, Record(Event{Action: "migrate.interlock", Decision: "refused"}))
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event Event
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	assert.Len(t, events, 2)
+	assert.Equal(t, "forced", events[0].Decision)
+	assert.Equal(t, 1500.0, events[0].Details["at_risk"])
+	assert.Equal(t, os.Getpid(), events[1].PID)
+	assert.False(t, events[1].Time.IsZero())
+	assert.NotEmpty(t, events[1].Host)
+
+	// 没有设置审计日志时只写入应用日志
+	SetPath("")
+	assert.NoError(t, Record(Event{Action: "migrate.interlock", Decision: "confirmed"}))
+}
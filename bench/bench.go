@@ -0,0 +1,246 @@
+// Package bench runs reproducible end-to-end benchmarks of terrasync on in-memory
+// synthetic data, so that the throughput of releases can be compared without
+// depending on the disks or network of the machine running them.
+//
+// The scan benchmark walks a seeded mem:// tree through the same scan API as the
+// CLI. The migrate benchmark lists a seeded mem:// source and copies every entry
+// with a worker pool through Storage.Get/Put into a mem:// destination that only
+// keeps metadata. Results are written as JSON for comparison between runs.
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	appscan "terrasync/app/scan"
+	"terrasync/object"
+	"terrasync/pkg/scan"
+)
+
+// Benchmark names used in the results
+const (
+	NameScan    = "scan"
+	NameMigrate = "migrate"
+)
+
+// Config 基准测试配置
+// The same configuration and seed always produce the same data.
+type Config struct {
+	ScanFiles       int   `json:"scan_files"`        // 扫描测试的文件数，0表示跳过
+	MigrateBytes    int64 `json:"migrate_bytes"`     // 迁移测试的数据总量，0表示跳过
+	MigrateFileSize int64 `json:"migrate_file_size"` // 迁移测试的平均文件大小
+	Depth           int   `json:"depth"`             // 合成目录树的深度
+	Concurrency     int   `json:"concurrency"`       // 列举及复制的并发数
+	Seed            int64 `json:"seed"`
+}
+
+// Result is the outcome of one benchmark
+type Result struct {
+	Name          string  `json:"name"`
+	Entries       int64   `json:"entries"`
+	Bytes         int64   `json:"bytes"`
+	Seconds       float64 `json:"seconds"`
+	EntriesPerSec float64 `json:"entries_per_sec"`
+	MBPerSec      float64 `json:"mb_per_sec"`
+	Error         string  `json:"error,omitempty"`
+}
+
+// Report holds the results of a run and the environment they were measured in
+type Report struct {
+	Version   string    `json:"version"`
+	GoVersion string    `json:"go_version"`
+	OS        string    `json:"os"`
+	Arch      string    `json:"arch"`
+	CPUs      int       `json:"cpus"`
+	Config    Config    `json:"config"`
+	StartTime time.Time `json:"start_time"`
+	Results   []Result  `json:"results"`
+}
+
+// runID keeps the mem:// stores of different runs in the same process apart
+var runID int64
+
+// Validate checks the configuration and fills in defaults
+func (c *Config) Validate() error {
+	if c.ScanFiles < 0 || c.MigrateBytes < 0 {
+		return fmt.Errorf("scan files and migrate bytes must not be negative")
+	}
+	if c.ScanFiles == 0 && c.MigrateBytes == 0 {
+		return fmt.Errorf("nothing to benchmark, set scan files or migrate bytes")
+	}
+	if c.MigrateBytes > 0 && c.MigrateFileSize <= 0 {
+		return fmt.Errorf("migrate file size must be positive")
+	}
+	if c.Depth <= 0 {
+		c.Depth = 3
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = runtime.NumCPU()
+	}
+	return nil
+}
+
+// Run runs the configured benchmarks one after another.
+// A failed benchmark is recorded in its result and does not stop the others.
+func Run(ctx context.Context, version string, cfg Config) (*Report, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	report := &Report{
+		Version:   version,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		CPUs:      runtime.NumCPU(),
+		Config:    cfg,
+		StartTime: time.Now(),
+	}
+
+	id := atomic.AddInt64(&runID, 1)
+	if cfg.ScanFiles > 0 {
+		report.Results = append(report.Results, benchScan(ctx, cfg, id))
+	}
+	if cfg.MigrateBytes > 0 && ctx.Err() == nil {
+		report.Results = append(report.Results, benchMigrate(ctx, cfg, id))
+	}
+	return report, ctx.Err()
+}
+
+// benchScan walks a seeded tree of cfg.ScanFiles empty files
+func benchScan(ctx context.Context, cfg Config, id int64) Result {
+	uri := fmt.Sprintf("mem://bench-scan-%d?files=%d&depth=%d&seed=%d", id, cfg.ScanFiles, cfg.Depth, cfg.Seed)
+	// 预置数据不计入耗时
+	if err := seed(uri); err != nil {
+		return Result{Name: NameScan, Error: err.Error()}
+	}
+
+	summary, err := scan.Run(ctx, scan.Options{Path: uri, Concurrency: cfg.Concurrency})
+	if err != nil {
+		return Result{Name: NameScan, Error: err.Error()}
+	}
+	return newResult(NameScan, summary.Files+summary.Dirs, summary.TotalSize, summary.Duration)
+}
+
+// benchMigrate copies about cfg.MigrateBytes of seeded data to a discarding destination
+func benchMigrate(ctx context.Context, cfg Config, id int64) Result {
+	files := max(cfg.MigrateBytes/cfg.MigrateFileSize, 1)
+	srcURI := fmt.Sprintf("mem://bench-src-%d?files=%d&depth=%d&size=%d&seed=%d", id, files, cfg.Depth, cfg.MigrateFileSize, cfg.Seed)
+	if err := seed(srcURI); err != nil {
+		return Result{Name: NameMigrate, Error: err.Error()}
+	}
+	src, err := object.CreateStorage(srcURI)
+	if err != nil {
+		return Result{Name: NameMigrate, Error: err.Error()}
+	}
+	defer src.Close()
+	dst, err := object.CreateStorage(fmt.Sprintf("mem://bench-dst-%d?discard=true", id))
+	if err != nil {
+		return Result{Name: NameMigrate, Error: err.Error()}
+	}
+	defer dst.Close()
+
+	startTime := time.Now()
+	entries, bytes, err := copyAll(ctx, src, dst, cfg.Concurrency)
+	result := newResult(NameMigrate, entries, bytes, time.Since(startTime))
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// copyAll lists src and copies every entry to the same key in dst
+func copyAll(ctx context.Context, src, dst object.Storage, concurrency int) (int64, int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var entries, bytes int64
+	var firstErr error
+	var errOnce sync.Once
+	var wg sync.WaitGroup
+
+	queue := appscan.ListAll(ctx, src, appscan.ListOptions{Concurrency: concurrency})
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fileInfo := range queue {
+				n, err := copyEntry(dst, fileInfo)
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				atomic.AddInt64(&entries, 1)
+				atomic.AddInt64(&bytes, n)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return entries, bytes, firstErr
+	}
+	return entries, bytes, ctx.Err()
+}
+
+// copyEntry copies one file or creates one directory, returning the bytes copied
+func copyEntry(dst object.Storage, fileInfo object.FileInfo) (int64, error) {
+	if fileInfo.IsDir() {
+		return 0, dst.Put(fileInfo.Key()+"/", nil)
+	}
+	in, err := fileInfo.Get(0, -1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", fileInfo.Key(), err)
+	}
+	defer in.Close()
+	counter := &countingReader{r: in}
+	if err := dst.Put(fileInfo.Key(), counter); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %w", fileInfo.Key(), err)
+	}
+	return counter.n, nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// seed opens a mem:// store once so that its synthetic tree is generated before timing starts
+func seed(uri string) error {
+	storage, err := object.CreateStorage(uri)
+	if err != nil {
+		return fmt.Errorf("failed to seed %s: %w", uri, err)
+	}
+	return storage.Close()
+}
+
+func newResult(name string, entries, bytes int64, duration time.Duration) Result {
+	result := Result{Name: name, Entries: entries, Bytes: bytes, Seconds: duration.Seconds()}
+	if seconds := duration.Seconds(); seconds > 0 {
+		result.EntriesPerSec = float64(entries) / seconds
+		result.MBPerSec = float64(bytes) / (1 << 20) / seconds
+	}
+	return result
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
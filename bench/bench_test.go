@@ -0,0 +1,96 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"terrasync/log"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func init() {
+	log.Log = zap.NewNop().Sugar()
+}
+
+func TestRun(t *testing.T) {
+	report, err := Run(context.Background(), "test", Config{
+		ScanFiles:       2000,
+		MigrateBytes:    1 << 20,
+		MigrateFileSize: 4096,
+		Concurrency:     4,
+		Seed:            1,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, report.Results, 2)
+
+	scanResult, migrateResult := report.Results[0], report.Results[1]
+	assert.Equal(t, NameScan, scanResult.Name)
+	assert.Empty(t, scanResult.Error)
+	// 文件数加上目录数
+	assert.Greater(t, scanResult.Entries, int64(2000))
+	assert.Equal(t, NameMigrate, migrateResult.Name)
+	assert.Empty(t, migrateResult.Error)
+	assert.Greater(t, migrateResult.Entries, int64(256))
+	assert.InDelta(t, 1<<20, migrateResult.Bytes, 1<<18)
+
+	var buf bytes.Buffer
+	assert.NoError(t, report.WriteJSON(&buf))
+	var decoded Report
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, report.Results, decoded.Results)
+	assert.Equal(t, 2000, decoded.Config.ScanFiles)
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{name: "仅扫描", config: Config{ScanFiles: 10}},
+		{name: "无测试项", config: Config{}, wantErr: true},
+		{name: "负数文件数", config: Config{ScanFiles: -1}, wantErr: true},
+		{name: "迁移缺少文件大小", config: Config{MigrateBytes: 1024}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Positive(t, tt.config.Concurrency)
+		})
+	}
+}
+
+// BenchmarkScan 可通过 go test -bench . ./bench 运行
+func BenchmarkScan(b *testing.B) {
+	benchmarkRun(b, Config{ScanFiles: 100000, Seed: 1}, NameScan)
+}
+
+func BenchmarkMigrate(b *testing.B) {
+	benchmarkRun(b, Config{MigrateBytes: 1 << 30, MigrateFileSize: 1 << 20, Seed: 1}, NameMigrate)
+}
+
+func benchmarkRun(b *testing.B, cfg Config, name string) {
+	for i := 0; i < b.N; i++ {
+		report, err := Run(context.Background(), "bench", cfg)
+		if err != nil {
+			b.Fatal(err)
+		}
+		result := report.Results[0]
+		if result.Error != "" {
+			b.Fatal(result.Error)
+		}
+		b.ReportMetric(result.EntriesPerSec, "entries/s")
+		if name == NameMigrate {
+			b.ReportMetric(result.MBPerSec, "MB/s")
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package changelist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"terrasync/security"
+	"time"
+)
+
+// ChangeType is the kind of change reported for an entry
+type ChangeType string
+
+const (
+	Added    ChangeType = "added"
+	Modified ChangeType = "modified"
+	Deleted  ChangeType = "deleted"
+	// Listed entries exist but the platform doesn't know whether they changed,
+	// e.g. the objects of an S3 inventory report. The incremental scan compares
+	// them with the previous scan like the entries of a tree walk.
+	Listed ChangeType = "listed"
+)
+
+// Change is an entry reported by a change list. The metadata is the state after
+// the change, it is unknown for deleted entries.
+type Change struct {
+	Type  ChangeType
+	Key   string // 相对扫描根目录的路径，以/开头
+	IsDir bool
+	Size  int64
+	MTime time.Time
+	CTime time.Time
+	ATime time.Time
+	Perm  os.FileMode
+}
+
+// Source enumerates the changes of a platform between two points in time, such
+// as two snapshots, so an incremental run doesn't have to walk the tree
+type Source interface {
+	// Changes calls fn for every change and stops at the first error of fn
+	Changes(ctx context.Context, fn func(Change) error) error
+	Close() error
+}
+
+// ErrCursorExpired is returned by Cursor.Since when the changes after the saved
+// position are no longer available, e.g. the journal wrapped or was recreated
+var ErrCursorExpired = errors.New("change list position expired")
+
+// Cursor is implemented by change lists read from the position the previous run
+// stopped at, such as the NTFS USN journal, instead of between two points named
+// in the URI. Incremental scans save the position in the job database.
+type Cursor interface {
+	// CursorName returns the name the position is saved under, such as the volume
+	CursorName() string
+	// Position returns the current end of the change list, where the next run starts
+	Position(ctx context.Context) (string, error)
+	// Since sets the position saved by the previous run, Changes reports the changes after it
+	Since(position string) error
+}
+
+// Config holds the credentials of the vendor REST APIs
+type Config struct {
+	User     string        // 基本认证的用户名
+	Password string        // 基本认证的密码
+	Timeout  time.Duration // 单个请求的超时，<=0使用默认值
+}
+
+const defaultTimeout = time.Minute
+
+// Factory opens the change list of a parsed URI
+type Factory func(u *url.URL, config Config) (Source, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]Factory{
+		"snapdiff":     openSnapDiff,
+		"isilon":       openIsilon,
+		"s3-inventory": openS3Inventory,
+		"gpfs-list":    openGPFSList,
+		"lfs-find":     openLFSFind,
+		"usn":          openUSN,
+		"journal":      openJournal,
+	}
+)
+
+// Register adds a change list type for the URI scheme, replacing an existing one
+func Register(scheme string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[scheme] = factory
+}
+
+// Schemes returns the registered URI schemes in order
+func Schemes() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	schemes := make([]string, 0, len(factories))
+	for scheme := range factories {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Open opens the change list of uri, the scheme selects the vendor:
+//
+//	snapdiff://cluster/<volume-uuid>?base=<snapshot>&diff=<snapshot>  NetApp ONTAP SnapDiff REST
+//	isilon://cluster:8080/<changelist-id>?root=/ifs/data/share          Isilon/PowerScale changelist
+//	s3-inventory:///path/to/manifest.json?prefix=share/                 S3 inventory report
+//	gpfs-list:///path/to/list.files?root=/gpfs/fs1/share                GPFS mmapplypolicy file list
+//	lfs-find:///path/to/list?root=/lustre/share                         Lustre lfs find --printf output
+//	usn:///C:/data/share                                                NTFS USN journal of a Windows volume
+//	journal:///var/lib/terrasync/data.journal?root=/data                change journal of the watch collector
+func Open(uri string, config Config) (Source, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid changelist uri %s: %w", uri, err)
+	}
+	factoriesMu.RLock()
+	factory, ok := factories[u.Scheme]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported changelist type %q, expect one of %s", u.Scheme, strings.Join(Schemes(), ", "))
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	return factory(u, config)
+}
+
+// relativeKey returns the key of an absolute platform path below root, false
+// when the path is outside of root
+func relativeKey(root, p string) (string, bool) {
+	root = path.Join("/", root)
+	p = path.Join("/", p)
+	if root == "/" {
+		return p, true
+	}
+	if p == root {
+		return "/", true
+	}
+	if !strings.HasPrefix(p, root+"/") {
+		return "", false
+	}
+	return strings.TrimPrefix(p, root), true
+}
+
+// restClient reads the paged JSON collections of a vendor REST API
+type restClient struct {
+	base   *url.URL
+	config Config
+	client *http.Client
+}
+
+// newRESTClient returns a client of the https API of u, or http with tls=false
+func newRESTClient(u *url.URL, config Config) (*restClient, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid changelist uri %s: missing host", u.Redacted())
+	}
+	scheme := "https"
+	if u.Query().Get("tls") == "false" {
+		scheme = "http"
+	}
+	// TLS配置遵循当前加密模式(FIPS模式下限制协议版本和密码套件)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = security.TLSConfig()
+	return &restClient{
+		base:   &url.URL{Scheme: scheme, Host: u.Host},
+		config: config,
+		client: &http.Client{Transport: transport, Timeout: config.Timeout},
+	}, nil
+}
+
+// get decodes the JSON response of ref, a path with an optional query such as
+// the link to the next page, into v
+func (c *restClient) get(ctx context.Context, ref string, v any) error {
+	rel, err := url.Parse(ref)
+	if err != nil {
+		return fmt.Errorf("invalid api reference %q: %w", ref, err)
+	}
+	target := c.base.ResolveReference(rel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.config.User != "" {
+		req.SetBasicAuth(c.config.User, c.config.Password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %w", target.Redacted(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", target.Redacted(), resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response of %s: %w", target.Redacted(), err)
+	}
+	return nil
+}
+
+func (c *restClient) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,535 @@
+package changelist
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"terrasync/log"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// collect reads all changes of the change list uri
+func collect(t *testing.T, uri string, config Config) ([]Change, error) {
+	source, err := Open(uri, config)
+	if !assert.NoError(t, err) {
+		return nil, err
+	}
+	defer source.Close()
+	var changes []Change
+	err = source.Changes(context.Background(), func(change Change) error {
+		changes = append(changes, change)
+		return nil
+	})
+	return changes, err
+}
+
+// TestOpen 测试变更列表URI的校验
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name string
+		uri  string
+		err  string
+	}{
+		{"不支持的类型", "gpfs://cluster/fs", "unsupported changelist type"},
+		{"SnapDiff缺少快照", "snapdiff://cluster/vol-uuid?base=a", "invalid snapdiff uri"},
+		{"SnapDiff缺少卷", "snapdiff://cluster/?base=a&diff=b", "invalid snapdiff uri"},
+		{"Isilon缺少主机", "isilon:///12", "missing host"},
+		{"Isilon缺少变更列表", "isilon://cluster:8080/", "invalid isilon uri"},
+		{"S3清单缺少路径", "s3-inventory://", "invalid s3-inventory uri"},
+		{"GPFS列表缺少路径", "gpfs-list://", "invalid gpfs-list uri"},
+		{"不支持的字段", "lfs-find:///tmp/list?fields=size,owner,path", "unsupported field"},
+		{"重复的字段", "lfs-find:///tmp/list?fields=size,size,path", "duplicate field"},
+		{"lfs find字段缺少路径", "lfs-find:///tmp/list?fields=size", "have no path"},
+		{"GPFS路径不在字段中", "gpfs-list:///tmp/list?fields=size,path", "follows ' -- '"},
+		{"USN日志缺少盘符", "usn:///data/share", "missing drive letter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Open(tt.uri, Config{})
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+// TestSnapDiff 测试按页读取ONTAP SnapDiff记录
+func TestSnapDiff(t *testing.T) {
+	mtime := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		if user != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "/api/storage/volumes/vol-uuid/snapdiff", r.URL.Path)
+		page := map[string]any{}
+		if r.URL.Query().Get("page") == "" {
+			assert.Equal(t, "daily.0", r.URL.Query().Get("base"))
+			assert.Equal(t, "daily.1", r.URL.Query().Get("diff"))
+			page["records"] = []map[string]any{
+				{"path": "/share/a.txt", "change_type": "create", "type": "file", "size": 10, "modified_time": mtime, "unix_permissions": 644},
+				{"path": "/other/b.txt", "change_type": "modify", "type": "file"},
+			}
+			page["_links"] = map[string]any{"next": map[string]string{"href": "/api/storage/volumes/vol-uuid/snapdiff?page=2"}}
+		} else {
+			page["records"] = []map[string]any{
+				{"path": "/share/dir", "change_type": "modify", "type": "directory", "unix_permissions": 755},
+				{"path": "/share/old.txt", "change_type": "delete", "type": "file"},
+			}
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+	uri := "snapdiff://" + strings.TrimPrefix(server.URL, "http://") + "/vol-uuid?base=daily.0&diff=daily.1&root=/share&tls=false"
+
+	changes, err := collect(t, uri, Config{User: "admin", Password: "secret"})
+	assert.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Type: Added, Key: "/a.txt", Size: 10, MTime: mtime, Perm: 0644},
+		{Type: Modified, Key: "/dir", IsDir: true, Perm: os.ModeDir | 0755},
+		{Type: Deleted, Key: "/old.txt"},
+	}, changes)
+
+	_, err = collect(t, uri, Config{User: "admin", Password: "wrong"})
+	assert.ErrorContains(t, err, "401")
+}
+
+// TestIsilon 测试按resume令牌读取OneFS变更列表
+func TestIsilon(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/platform/1/snapshot/changelists/12_34/lins", r.URL.Path)
+		page := map[string]any{}
+		if r.URL.Query().Get("resume") == "" {
+			assert.Equal(t, "1000", r.URL.Query().Get("limit"))
+			page["lins"] = []map[string]any{
+				{"path": "/ifs/data/share/new", "change_types": []string{"ENTRY_ADDED"}, "type": "directory", "mode": "0755", "mtime_val": 1700000000},
+			}
+			page["resume"] = "token1"
+		} else {
+			page["lins"] = []map[string]any{
+				{"path": "/ifs/data/share/new/f.txt", "change_types": []string{"ENTRY_MODIFIED"}, "type": "regular", "size": 5, "mode": "0600"},
+				{"path": "/ifs/data/share/gone.txt", "change_types": []string{"ENTRY_REMOVED"}, "type": "regular"},
+				{"path": "/ifs/data/elsewhere.txt", "change_types": []string{"ENTRY_ADDED"}, "type": "regular"},
+			}
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+
+	changes, err := collect(t, "isilon://"+strings.TrimPrefix(server.URL, "http://")+"/12_34?root=/ifs/data/share&tls=false", Config{})
+	assert.NoError(t, err)
+	assert.Len(t, changes, 3)
+	assert.Equal(t, Change{Type: Added, Key: "/new", IsDir: true, Perm: os.ModeDir | 0755, MTime: time.Unix(1700000000, 0), CTime: time.Unix(0, 0), ATime: time.Unix(0, 0)}, changes[0])
+	assert.Equal(t, Modified, changes[1].Type)
+	assert.Equal(t, "/new/f.txt", changes[1].Key)
+	assert.Equal(t, os.FileMode(0600), changes[1].Perm)
+	assert.Equal(t, Deleted, changes[2].Type)
+}
+
+// TestS3Inventory 测试读取S3清单报告的当前版本对象
+func TestS3Inventory(t *testing.T) {
+	dir := t.TempDir()
+	manifestDir := filepath.Join(dir, "bucket", "config", "2026-10-14T01-00Z")
+	dataDir := filepath.Join(dir, "bucket", "config", "data")
+	assert.NoError(t, os.MkdirAll(manifestDir, 0755))
+	assert.NoError(t, os.MkdirAll(dataDir, 0755))
+
+	f, err := os.Create(filepath.Join(dataDir, "part1.csv.gz"))
+	assert.NoError(t, err)
+	zw := gzip.NewWriter(f)
+	_, _ = zw.Write([]byte(`"bucket","share/a+b.txt","true","false","12","2026-10-01T08:00:00.000Z"
+"bucket","share/old.txt","false","false","3","2026-01-01T08:00:00.000Z"
+"bucket","share/deleted.txt","true","true","","2026-01-01T08:00:00.000Z"
+"bucket","share/folder/","true","false","0","2026-01-01T08:00:00.000Z"
+"bucket","other/c.txt","true","false","1","2026-01-01T08:00:00.000Z"
+`))
+	assert.NoError(t, zw.Close())
+	assert.NoError(t, f.Close())
+
+	manifest := `{"sourceBucket":"bucket","fileFormat":"CSV","fileSchema":"Bucket, Key, IsLatest, IsDeleteMarker, Size, LastModifiedDate",` +
+		`"files":[{"key":"inventory/bucket/config/data/part1.csv.gz"}]}`
+	manifestPath := filepath.Join(manifestDir, "manifest.json")
+	assert.NoError(t, os.WriteFile(manifestPath, []byte(manifest), 0644))
+
+	changes, err := collect(t, "s3-inventory://"+filepath.ToSlash(manifestPath)+"?prefix=share/", Config{})
+	assert.NoError(t, err)
+	mtime := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	assert.Equal(t, []Change{{Type: Listed, Key: "/a b.txt", Size: 12, MTime: mtime, CTime: mtime, ATime: mtime, Perm: 0644}}, changes)
+	bucket, err := S3InventoryBucket(manifestPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "bucket", bucket)
+
+	// 只支持CSV格式
+	assert.NoError(t, os.WriteFile(manifestPath, []byte(`{"fileFormat":"Parquet","fileSchema":"Key"}`), 0644))
+	_, err = collect(t, "s3-inventory://"+filepath.ToSlash(manifestPath), Config{})
+	assert.ErrorContains(t, err, "only CSV")
+}
+
+// TestPolicyList 测试读取GPFS mmapplypolicy及Lustre lfs find生成的文件列表
+func TestPolicyList(t *testing.T) {
+	mtime := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	local := time.Date(2026, 10, 1, 8, 0, 0, 500000000, time.Local)
+	tests := []struct {
+		name  string
+		list  string
+		query string
+		uri   string
+		want  []Change
+		err   string
+	}{
+		{
+			name:  "GPFS列表",
+			uri:   "gpfs-list",
+			query: "?root=/gpfs/fs1/share&escape=true",
+			list: "40 1 0  12|2026-10-01 08:00:00.5|2026-10-01 08:00:00.5|2026-10-01 08:00:00.5|-rw-r-----  -- /gpfs/fs1/share/a%20b.txt\n" +
+				"41 1 0  4096|2026-10-01 08:00:00.5|2026-10-01 08:00:00.5|2026-10-01 08:00:00.5|drwxr-xr-x  -- /gpfs/fs1/share/dir\n" +
+				"42 1 0  1|2026-10-01 08:00:00.5|2026-10-01 08:00:00.5|2026-10-01 08:00:00.5|-rw-r--r--  -- /gpfs/fs1/other/c.txt\n",
+			want: []Change{
+				{Type: Listed, Key: "/a b.txt", Size: 12, MTime: local, ATime: local, CTime: local, Perm: 0640},
+				{Type: Listed, Key: "/dir", IsDir: true, MTime: local, ATime: local, CTime: local, Perm: os.ModeDir | 0755},
+			},
+		},
+		{
+			name: "GPFS列表只有路径",
+			uri:  "gpfs-list",
+			list: "40 1 0   -- /gpfs/fs1/x.txt\n",
+			want: []Change{{Type: Listed, Key: "/gpfs/fs1/x.txt", Perm: 0644}},
+		},
+		{
+			name:  "lfs find输出",
+			uri:   "lfs-find",
+			query: "?root=/lustre/share",
+			list: "12|1790841600.0|1790841600|1790841600|640|f|/lustre/share/a|b.txt\n" +
+				"4096|1790841600|1790841600|1790841600|755|d|/lustre/share/dir\n" +
+				"0|1790841600|1790841600|1790841600|777|l|/lustre/share/link\n",
+			want: []Change{
+				{Type: Listed, Key: "/a|b.txt", Size: 12, MTime: mtime, ATime: mtime, CTime: mtime, Perm: 0640},
+				{Type: Listed, Key: "/dir", IsDir: true, MTime: mtime, ATime: mtime, CTime: mtime, Perm: os.ModeDir | 0755},
+				{Type: Listed, Key: "/link", MTime: mtime, ATime: mtime, CTime: mtime, Perm: os.ModeSymlink | 0777},
+			},
+		},
+		{
+			name:  "自定义字段及分隔符",
+			uri:   "lfs-find",
+			query: "?fields=path,-,size,mode&delim=%2C",
+			list:  "/x/y.dat,ignored,7,100600\n",
+			want:  []Change{{Type: Listed, Key: "/x/y.dat", Size: 7, Perm: 0600}},
+		},
+		{
+			name: "字段数不符",
+			uri:  "lfs-find",
+			list: "12|1790841600\n",
+			err:  "line 1",
+		},
+		{
+			name: "无效的大小",
+			uri:  "gpfs-list",
+			list: "40 1 0  x|2026-10-01 08:00:00|2026-10-01 08:00:00|2026-10-01 08:00:00|-rw-r--r--  -- /a\n",
+			err:  "invalid size",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "list")
+			assert.NoError(t, os.WriteFile(path, []byte(tt.list), 0644))
+			changes, err := collect(t, tt.uri+"://"+filepath.ToSlash(path)+tt.query, Config{})
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, changes)
+		})
+	}
+}
+
+// fakeVolume 是内存中的卷及其USN日志
+type fakeVolume struct {
+	journal uint64
+	first   int64
+	records []usnRecord
+	paths   map[byte]string // 目录ID(第一个字节) -> 当前路径
+	files   map[string]Change
+}
+
+func (v *fakeVolume) query() (uint64, int64, int64, error) {
+	next := v.first
+	if len(v.records) > 0 {
+		next = v.records[len(v.records)-1].USN + 1
+	}
+	return v.journal, v.first, next, nil
+}
+
+func (v *fakeVolume) read(ctx context.Context, journalID uint64, start, end int64, fn func(usnRecord) error) error {
+	for _, rec := range v.records {
+		if rec.USN >= start && rec.USN < end {
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *fakeVolume) path(id [16]byte) (string, error) {
+	if p, ok := v.paths[id[0]]; ok {
+		return p, nil
+	}
+	return "", errors.New("file not found")
+}
+
+func (v *fakeVolume) stat(p string) (Change, error) {
+	if change, ok := v.files[p]; ok {
+		return change, nil
+	}
+	return Change{}, os.ErrNotExist
+}
+
+func (v *fakeVolume) walk(p string, fn func(p string, change Change) error) error {
+	for name, change := range v.files {
+		if strings.HasPrefix(name, p+"/") {
+			if err := fn(name, change); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *fakeVolume) Close() error {
+	return nil
+}
+
+// TestUSN 测试从上次运行的位置读取NTFS USN日志
+func TestUSN(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	id := func(b byte) [16]byte { return [16]byte{b} }
+	mtime := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	volume := &fakeVolume{
+		journal: 7,
+		first:   100,
+		paths:   map[byte]string{1: "/", 2: "/share", 3: "/share/dir", 4: "/share/moved"},
+		files: map[string]Change{
+			"/share/new.txt":     {Size: 3, MTime: mtime, Perm: 0644},
+			"/share/dir/mod.txt": {Size: 5, MTime: mtime, Perm: 0644},
+			"/share/renamed.txt": {Size: 1, MTime: mtime, Perm: 0644},
+			"/share/moved":       {IsDir: true, MTime: mtime, Perm: os.ModeDir | 0755},
+			"/share/moved/x.txt": {Size: 2, MTime: mtime, Perm: 0644},
+			"/other.txt":         {Size: 1, MTime: mtime, Perm: 0644},
+		},
+		records: []usnRecord{
+			{USN: 90, ID: id(20), Parent: id(2), Name: "old.txt", Reason: 0x2},
+			{USN: 100, ID: id(10), Parent: id(2), Name: "new.txt", Reason: usnReasonFileCreate},
+			{USN: 101, ID: id(10), Parent: id(2), Name: "new.txt", Reason: 0x2 | 0x80000000},
+			{USN: 102, ID: id(11), Parent: id(3), Name: "mod.txt", Reason: 0x1},
+			{USN: 103, ID: id(12), Parent: id(2), Name: "gone.txt", Reason: usnReasonFileDelete},
+			{USN: 104, ID: id(13), Parent: id(2), Name: "tmp.txt", Reason: usnReasonFileCreate},
+			{USN: 105, ID: id(13), Parent: id(2), Name: "tmp.txt", Reason: usnReasonFileDelete},
+			{USN: 106, ID: id(14), Parent: id(2), Name: "before.txt", Reason: usnReasonRenameOldName},
+			{USN: 107, ID: id(14), Parent: id(2), Name: "renamed.txt", Reason: 0x2000},
+			{USN: 108, ID: id(4), Parent: id(3), Name: "sub", Reason: usnReasonRenameOldName},
+			{USN: 109, ID: id(4), Parent: id(2), Name: "moved", Reason: 0x2000},
+			{USN: 110, ID: id(15), Parent: id(1), Name: "other.txt", Reason: 0x1},
+			{USN: 111, ID: id(16), Parent: id(9), Name: "lost.txt", Reason: 0x1},
+		},
+	}
+	s := &usnSource{volume: volume, name: "D:", root: "/share"}
+	assert.Equal(t, "usn:D:", s.CursorName())
+	position, err := s.Position(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "7:112", position)
+
+	// 日志重建或已覆盖上次的位置
+	assert.ErrorIs(t, s.Since("6:100"), ErrCursorExpired)
+	assert.ErrorIs(t, s.Since("7:50"), ErrCursorExpired)
+	assert.ErrorContains(t, s.Since("7"), "invalid usn position")
+	assert.ErrorContains(t, s.Changes(context.Background(), func(Change) error { return nil }), "no position")
+
+	assert.NoError(t, s.Since("7:100"))
+	var changes []Change
+	assert.NoError(t, s.Changes(context.Background(), func(change Change) error {
+		changes = append(changes, change)
+		return nil
+	}))
+	assert.Equal(t, []Change{
+		{Type: Deleted, Key: "/before.txt"},
+		{Type: Modified, Key: "/dir/mod.txt", Size: 5, MTime: mtime, Perm: 0644},
+		{Type: Deleted, Key: "/dir/sub"},
+		{Type: Deleted, Key: "/gone.txt"},
+		{Type: Added, Key: "/moved", IsDir: true, MTime: mtime, Perm: os.ModeDir | 0755},
+		{Type: Added, Key: "/moved/x.txt", Size: 2, MTime: mtime, Perm: 0644},
+		{Type: Added, Key: "/new.txt", Size: 3, MTime: mtime, Perm: 0644},
+		{Type: Added, Key: "/renamed.txt", Size: 1, MTime: mtime, Perm: 0644},
+	}, changes)
+
+	name, root, err := parseUSNPath("/c:/Data/Share/")
+	assert.NoError(t, err)
+	assert.Equal(t, "C:", name)
+	assert.Equal(t, "/Data/Share", root)
+}
+
+// TestParseUSNRecords 测试解析FSCTL_READ_USN_JOURNAL返回的V2及V3记录
+func TestParseUSNRecords(t *testing.T) {
+	le := binary.LittleEndian
+	// record 按USN_RECORD_V2/V3的布局编码一条记录
+	record := func(major uint16, id, parent byte, usn int64, reason uint32, name string) []byte {
+		idSize := 8
+		if major == 3 {
+			idSize = 16
+		}
+		offset := 8 + 2*idSize
+		nameOffset := offset + 36
+		encoded := utf16.Encode([]rune(name))
+		length := (nameOffset + 2*len(encoded) + 7) &^ 7
+		buf := make([]byte, length)
+		le.PutUint32(buf, uint32(length))
+		le.PutUint16(buf[4:], major)
+		buf[8] = id
+		buf[8+idSize] = parent
+		le.PutUint64(buf[offset:], uint64(usn))
+		le.PutUint32(buf[offset+16:], reason)
+		le.PutUint32(buf[offset+28:], 0x20)
+		le.PutUint16(buf[offset+32:], uint16(2*len(encoded)))
+		le.PutUint16(buf[offset+34:], uint16(nameOffset))
+		for i, c := range encoded {
+			le.PutUint16(buf[nameOffset+2*i:], c)
+		}
+		return buf
+	}
+
+	buf := append(record(2, 1, 5, 100, usnReasonFileCreate, "报告.txt"), record(3, 2, 5, 101, usnReasonFileDelete, "a b")...)
+	var records []usnRecord
+	assert.NoError(t, parseUSNRecords(buf, func(rec usnRecord) error {
+		records = append(records, rec)
+		return nil
+	}))
+	assert.Equal(t, []usnRecord{
+		{ID: [16]byte{1}, Parent: [16]byte{5}, USN: 100, Reason: usnReasonFileCreate, Attributes: 0x20, Name: "报告.txt"},
+		{ID: [16]byte{2}, Parent: [16]byte{5}, USN: 101, Reason: usnReasonFileDelete, Attributes: 0x20, Name: "a b"},
+	}, records)
+
+	assert.ErrorContains(t, parseUSNRecords(record(4, 1, 5, 100, 0, "x"), func(usnRecord) error { return nil }), "unsupported usn record version")
+	assert.ErrorContains(t, parseUSNRecords(buf[:30], func(usnRecord) error { return nil }), "invalid usn record length")
+}
+
+// TestJournal 测试读取watch采集器写入的变化日志
+func TestJournal(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "share")
+	journalPath := filepath.Join(dir, "journal", "share.journal")
+	for _, name := range []string{"new.txt", "mod.txt", "renamed.txt", "recreated.txt", "moved/x.txt"} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		assert.NoError(t, os.WriteFile(p, []byte(name), 0644))
+	}
+	// stat 返回路径当前的元数据
+	stat := func(changeType ChangeType, key string) Change {
+		info, err := os.Lstat(filepath.Join(root, filepath.FromSlash(key)))
+		assert.NoError(t, err)
+		change := journalFileChange(info)
+		change.Type, change.Key = changeType, key
+		return change
+	}
+	record := func(op, name string, isDir bool) JournalRecord {
+		return JournalRecord{Time: time.Now().UTC(), Op: op, Path: filepath.Join(root, filepath.FromSlash(name)), Dir: isDir}
+	}
+
+	w, err := OpenJournalWriter(journalPath)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Append(record(JournalModify, "old.txt", false)))
+	assert.NoError(t, w.Flush())
+	source, err := Open("journal://"+filepath.ToSlash(journalPath)+"?root="+filepath.ToSlash(root), Config{})
+	assert.NoError(t, err)
+	s := source.(*journalSource)
+	assert.Equal(t, "journal:"+filepath.ToSlash(journalPath), s.CursorName())
+	previous, err := s.Position(context.Background())
+	assert.NoError(t, err)
+
+	assert.NoError(t, w.Append(
+		record(JournalCreate, "new.txt", false),
+		record(JournalModify, "mod.txt", false),
+		record(JournalModify, "mod.txt", false),
+		record(JournalDelete, "gone.txt", false),
+		record(JournalCreate, "tmp.txt", false),
+		record(JournalDelete, "tmp.txt", false),
+		record(JournalMoveFrom, "before.txt", false),
+		record(JournalMoveTo, "renamed.txt", false),
+		record(JournalMoveFrom, "sub", true),
+		record(JournalMoveTo, "moved", true),
+		record(JournalDelete, "recreated.txt", false),
+		record(JournalCreate, "recreated.txt", false),
+		JournalRecord{Op: JournalModify, Path: filepath.Join(dir, "other.txt")},
+	))
+	assert.NoError(t, w.Close())
+	// 采集器正在写入的最后一行不读取
+	f, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_APPEND, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"op":"create","path":"/share/partial`)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	s = &journalSource{path: journalPath, root: filepath.ToSlash(root)}
+	current, err := s.Position(context.Background())
+	assert.NoError(t, err)
+	assert.NotEqual(t, previous, current)
+
+	// 日志重建或被截断
+	id, _, _ := strings.Cut(previous, ":")
+	assert.ErrorIs(t, s.Since("0123456789abcdef:10"), ErrCursorExpired)
+	assert.ErrorIs(t, s.Since(id+":999999"), ErrCursorExpired)
+	assert.ErrorContains(t, s.Since(id), "invalid journal position")
+	assert.ErrorContains(t, s.Changes(context.Background(), func(Change) error { return nil }), "no position")
+
+	assert.NoError(t, s.Since(previous))
+	var changes []Change
+	assert.NoError(t, s.Changes(context.Background(), func(change Change) error {
+		changes = append(changes, change)
+		return nil
+	}))
+	assert.Equal(t, []Change{
+		{Type: Deleted, Key: "/before.txt"},
+		{Type: Deleted, Key: "/gone.txt"},
+		stat(Modified, "/mod.txt"),
+		stat(Added, "/moved"),
+		stat(Added, "/moved/x.txt"),
+		stat(Added, "/new.txt"),
+		stat(Modified, "/recreated.txt"),
+		stat(Added, "/renamed.txt"),
+		{Type: Deleted, Key: "/sub"},
+	}, changes)
+
+	// 采集器写入一半时退出、重启或丢失事件之后的变化不完整
+	for _, expired := range []string{"invalid record", "restarted", "lost events"} {
+		w, err := OpenJournalWriter(journalPath)
+		assert.NoError(t, err)
+		s := &journalSource{path: journalPath, root: root}
+		if expired == "lost events" {
+			current, err = s.Position(context.Background())
+			assert.NoError(t, err)
+			assert.NoError(t, w.Append(JournalRecord{Op: JournalOverflow}))
+		}
+		assert.NoError(t, w.Close())
+		_, err = s.Position(context.Background())
+		assert.NoError(t, err)
+		err = s.Since(current)
+		assert.ErrorIs(t, err, ErrCursorExpired)
+		assert.ErrorContains(t, err, expired)
+		current, err = s.Position(context.Background())
+		assert.NoError(t, err)
+	}
+
+	_, err = (&journalSource{path: filepath.Join(dir, "missing.journal")}).Position(context.Background())
+	assert.ErrorContains(t, err, "is the watch collector running")
+}
@@ -0,0 +1,402 @@
+package changelist
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operations of the records of a change journal
+const (
+	JournalStart    = "start"    // 采集器启动，停止期间的变化没有记录
+	JournalOverflow = "overflow" // 内核事件队列溢出，部分变化没有记录
+	JournalCreate   = "create"
+	JournalModify   = "modify" // 内容或属性变化
+	JournalDelete   = "delete"
+	JournalMoveFrom = "move_from" // 重命名或移走前的路径
+	JournalMoveTo   = "move_to"   // 重命名或移入后的路径
+)
+
+// JournalRecord is a line of a change journal, recorded as a JSON object
+type JournalRecord struct {
+	Time time.Time `json:"time"`
+	Op   string    `json:"op"`
+	Path string    `json:"path,omitempty"` // 绝对路径
+	Dir  bool      `json:"dir,omitempty"`
+	ID   string    `json:"id,omitempty"` // start记录的日志ID，日志文件被重建时变化
+}
+
+// JournalWriter appends the file change events recorded by a collector to a
+// change journal, which the next incremental scan reads with journal:// from the
+// position saved by the previous run. Every start of the collector is recorded,
+// a scan finding a start or overflow record after its position walks the tree.
+type JournalWriter struct {
+	mu   sync.Mutex
+	file *os.File
+	buf  *bufio.Writer
+	id   string
+}
+
+// OpenJournalWriter opens the change journal at path for appending, creating it
+// with a new journal id when it doesn't exist, and records the start
+func OpenJournalWriter(path string) (*JournalWriter, error) {
+	id, err := readJournalID(path)
+	if errors.Is(err, os.ErrNotExist) {
+		var b [8]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, fmt.Errorf("failed to create journal id: %w", err)
+		}
+		id = hex.EncodeToString(b[:])
+	} else if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	// 采集器异常退出时可能留下不完整的行
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			f.Write([]byte{'\n'})
+		}
+	}
+	w := &JournalWriter{file: f, buf: bufio.NewWriterSize(f, 256*1024), id: id}
+	if err := w.Append(JournalRecord{Time: time.Now().UTC(), Op: JournalStart, ID: id}); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Append buffers records, they are written by Flush
+func (w *JournalWriter) Append(records ...JournalRecord) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode journal record: %w", err)
+		}
+		w.buf.Write(data)
+		if err := w.buf.WriteByte('\n'); err != nil {
+			return fmt.Errorf("failed to write journal: %w", err)
+		}
+	}
+	return nil
+}
+
+// Flush writes the buffered records and syncs the journal to disk
+func (w *JournalWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.buf.Flush(); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	return nil
+}
+
+// Close flushes and closes the journal
+func (w *JournalWriter) Close() error {
+	err := w.Flush()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// readJournalID returns the id of the start record on the first line of a journal
+func readJournalID(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err == io.EOF && len(line) == 0 {
+		return "", fmt.Errorf("journal %s is empty: %w", path, os.ErrNotExist)
+	}
+	var record JournalRecord
+	if err := json.Unmarshal(line, &record); err != nil || record.Op != JournalStart || record.ID == "" {
+		return "", fmt.Errorf("%s is not a change journal", path)
+	}
+	return record.ID, nil
+}
+
+// journalSource reads the changes recorded by the collector of the watch
+// command from the position saved by the previous run up to the end of the
+// journal when the run started. Only the changed paths are recorded, their
+// metadata is read from the filesystem.
+type journalSource struct {
+	path  string
+	root  string // 扫描根目录的绝对路径
+	id    string // Position读取的日志ID
+	start int64  // 上次运行保存的偏移
+	end   int64  // Position时最后一个完整行的末尾
+	since bool
+}
+
+// openJournal opens journal:///var/lib/terrasync/data.journal?root=/data
+func openJournal(u *url.URL, config Config) (Source, error) {
+	if u.Path == "" {
+		return nil, fmt.Errorf("invalid journal uri %s, expect journal:///path/to/journal?root=/data", u.Redacted())
+	}
+	return &journalSource{path: filepath.FromSlash(u.Path), root: u.Query().Get("root")}, nil
+}
+
+// CursorName returns the name the journal position is saved under
+func (s *journalSource) CursorName() string {
+	return "journal:" + filepath.ToSlash(s.path)
+}
+
+// Position returns the id of the journal and the offset of its end as id:offset
+func (s *journalSource) Position(ctx context.Context) (string, error) {
+	id, err := readJournalID(s.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read change journal, is the watch collector running: %w", err)
+	}
+	end, err := journalEnd(s.path)
+	if err != nil {
+		return "", err
+	}
+	s.id, s.end = id, end
+	return fmt.Sprintf("%s:%d", id, end), nil
+}
+
+// journalEnd returns the offset after the last complete line, the collector may
+// be writing the last line
+func journalEnd(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat journal: %w", err)
+	}
+	// 一行最长为4096字节的路径加上其他字段
+	offset := max(info.Size()-64*1024, 0)
+	tail := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return 0, fmt.Errorf("failed to read journal: %w", err)
+	}
+	return offset + int64(bytes.LastIndexByte(tail, '\n')+1), nil
+}
+
+// Since sets the position saved by the previous run. The changes since are
+// incomplete when the journal was recreated or truncated, or when the collector
+// was restarted or lost events after the position.
+func (s *journalSource) Since(position string) error {
+	id, offset, ok := strings.Cut(position, ":")
+	start, err := strconv.ParseInt(offset, 10, 64)
+	if !ok || err != nil {
+		return fmt.Errorf("invalid journal position %q, expect id:offset", position)
+	}
+	if s.id == "" {
+		if _, err := s.Position(context.Background()); err != nil {
+			return err
+		}
+	}
+	if id != s.id || start > s.end {
+		return fmt.Errorf("%w: the change journal %s was recreated since the previous run", ErrCursorExpired, s.path)
+	}
+	s.start = start
+	var gap string
+	if err := s.read(context.Background(), func(record JournalRecord) error {
+		if record.Op == JournalStart || record.Op == JournalOverflow {
+			gap = record.Op
+			return io.EOF
+		}
+		return nil
+	}); err != nil && err != io.EOF {
+		return err
+	}
+	switch gap {
+	case JournalStart:
+		return fmt.Errorf("%w: the collector of %s was restarted since the previous run, changes while it was stopped are unknown", ErrCursorExpired, s.path)
+	case JournalOverflow:
+		return fmt.Errorf("%w: the collector of %s lost events since the previous run", ErrCursorExpired, s.path)
+	}
+	s.since = true
+	return nil
+}
+
+// read calls fn for the records from the saved position to the end
+func (s *journalSource) read(ctx context.Context, fn func(JournalRecord) error) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(io.NewSectionReader(f, s.start, s.end-s.start))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	offset := s.start
+	for line := 1; scanner.Scan(); line++ {
+		if line%10000 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		var record JournalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// 采集器写入一半时退出，之后的变化不完整
+			return fmt.Errorf("%w: invalid record in journal %s at offset %d: %v", ErrCursorExpired, s.path, offset, err)
+		}
+		offset += int64(len(scanner.Bytes())) + 1
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read journal %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// journalChange accumulates the records of a path
+type journalChange struct {
+	created bool // 在两次运行之间创建或移入
+	deleted bool // 最后被删除或移走
+	moved   bool // 目录移入，其下的条目都是新的路径
+}
+
+func (s *journalSource) Changes(ctx context.Context, fn func(Change) error) error {
+	if !s.since {
+		return fmt.Errorf("the change journal %s has no position of a previous run", s.path)
+	}
+
+	// 按路径合并记录，同一个文件多次修改只报告一次
+	paths := make(map[string]*journalChange)
+	err := s.read(ctx, func(record JournalRecord) error {
+		if record.Path == "" {
+			return nil
+		}
+		p := path.Clean(filepath.ToSlash(record.Path))
+		c := paths[p]
+		if c == nil {
+			c = &journalChange{}
+			paths[p] = c
+		}
+		switch record.Op {
+		case JournalCreate, JournalMoveTo:
+			// 删除后重新创建的路径是修改
+			if !c.deleted {
+				c.created = true
+			}
+			c.deleted = false
+			c.moved = c.moved || (record.Op == JournalMoveTo && record.Dir)
+		case JournalDelete, JournalMoveFrom:
+			c.deleted = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	changes := make(map[string]Change)
+	add := func(change Change) {
+		// 同一路径上被删除的旧条目不覆盖新条目
+		if prev, ok := changes[change.Key]; ok && change.Type == Deleted && prev.Type != Deleted {
+			return
+		}
+		changes[change.Key] = change
+	}
+	for p, c := range paths {
+		key, ok := relativeKey(s.root, p)
+		if !ok || key == "/" {
+			continue
+		}
+		if c.deleted {
+			// 两次运行之间创建又删除的临时文件
+			if !c.created {
+				add(Change{Type: Deleted, Key: key})
+			}
+			continue
+		}
+		info, err := os.Lstat(filepath.FromSlash(p))
+		if err != nil {
+			// 之后被删除而事件还未写入日志
+			continue
+		}
+		change := journalFileChange(info)
+		change.Type, change.Key = Modified, key
+		if c.created {
+			change.Type = Added
+		}
+		add(change)
+
+		if c.moved && info.IsDir() {
+			if err := filepath.WalkDir(filepath.FromSlash(p), func(name string, d fs.DirEntry, err error) error {
+				if err != nil || name == filepath.FromSlash(p) {
+					return err
+				}
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				if key, ok := relativeKey(s.root, filepath.ToSlash(name)); ok {
+					change := journalFileChange(info)
+					change.Type, change.Key = Added, key
+					add(change)
+				}
+				return ctx.Err()
+			}); err != nil {
+				return fmt.Errorf("failed to list moved directory %s: %w", p, err)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := fn(changes[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// journalFileChange returns the metadata of a file as a change, ctime and atime
+// are the mtime like the local storage reports them
+func journalFileChange(info os.FileInfo) Change {
+	change := Change{MTime: info.ModTime(), CTime: info.ModTime(), ATime: info.ModTime(), Perm: info.Mode(), IsDir: info.IsDir()}
+	if !change.IsDir {
+		change.Size = info.Size()
+	}
+	return change
+}
+
+func (s *journalSource) Close() error {
+	return nil
+}
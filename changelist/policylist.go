@@ -0,0 +1,294 @@
+package changelist
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fields of a line of a policy list
+const (
+	FieldPath  = "path"
+	FieldSize  = "size"
+	FieldMTime = "mtime"
+	FieldATime = "atime"
+	FieldCTime = "ctime"
+	FieldMode  = "mode"
+	FieldType  = "type"
+	FieldSkip  = "-" // 忽略的字段
+)
+
+// Default fields of the policy lists, matching the rules shown in the readme
+var (
+	defaultGPFSFields = []string{FieldSize, FieldMTime, FieldATime, FieldCTime, FieldMode}
+	defaultLFSFields  = []string{FieldSize, FieldMTime, FieldATime, FieldCTime, FieldMode, FieldType, FieldPath}
+)
+
+// policyListSource reads a file list produced by the policy engine of an HPC
+// filesystem, such as GPFS mmapplypolicy or Lustre lfs find, with one entry per
+// line and the attributes in delimited fields. The lists name every file, not
+// the changes, so the entries are Listed.
+type policyListSource struct {
+	path   string
+	root   string
+	delim  string
+	fields []string
+	gpfs   bool // GPFS的行以"inode gen snapid SHOW -- 路径"的格式输出
+	escape bool // 路径经过URL编码(GPFS规则的ESCAPE '%')
+}
+
+// openGPFSList opens gpfs-list:///path/to/list.files[?root=/gpfs/fs1&fields=size,mtime&delim=|&escape=true]
+func openGPFSList(u *url.URL, config Config) (Source, error) {
+	return openPolicyList(u, true, defaultGPFSFields)
+}
+
+// openLFSFind opens lfs-find:///path/to/list[?root=/lustre/share&fields=size,path&delim=|]
+func openLFSFind(u *url.URL, config Config) (Source, error) {
+	return openPolicyList(u, false, defaultLFSFields)
+}
+
+func openPolicyList(u *url.URL, gpfs bool, fields []string) (Source, error) {
+	if u.Path == "" {
+		return nil, fmt.Errorf("invalid %s uri %s, expect %s:///path/to/list", u.Scheme, u.Redacted(), u.Scheme)
+	}
+	query := u.Query()
+	s := &policyListSource{path: filepath.FromSlash(u.Path), root: query.Get("root"), delim: "|", fields: append([]string(nil), fields...), gpfs: gpfs}
+	if delim := query.Get("delim"); delim != "" {
+		s.delim = delim
+	}
+	if list := query.Get("fields"); list != "" {
+		s.fields = strings.Split(list, ",")
+	}
+	seen := make(map[string]bool)
+	for i, field := range s.fields {
+		field = strings.ToLower(strings.TrimSpace(field))
+		switch field {
+		case FieldPath, FieldSize, FieldMTime, FieldATime, FieldCTime, FieldMode, FieldType:
+			if seen[field] {
+				return nil, fmt.Errorf("duplicate field %q in %s", field, u.Redacted())
+			}
+			seen[field] = true
+		case FieldSkip:
+		default:
+			return nil, fmt.Errorf("unsupported field %q, expect path, size, mtime, atime, ctime, mode, type or -", field)
+		}
+		s.fields[i] = field
+	}
+	// GPFS的路径在" -- "之后，不在SHOW的字段中
+	if gpfs && seen[FieldPath] {
+		return nil, fmt.Errorf("the path of a gpfs list follows ' -- ', remove it from the fields")
+	}
+	if !gpfs && !seen[FieldPath] {
+		return nil, fmt.Errorf("fields of %s have no path", u.Redacted())
+	}
+	if escape := query.Get("escape"); escape != "" {
+		var err error
+		if s.escape, err = strconv.ParseBool(escape); err != nil {
+			return nil, fmt.Errorf("invalid escape %q in %s: %w", escape, u.Redacted(), err)
+		}
+	}
+	return s, nil
+}
+
+func (s *policyListSource) Changes(ctx context.Context, fn func(Change) error) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to open file list: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	// 路径最长4096字节，SHOW的字段可能很多
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if line%10000 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		change, ok, err := s.parse(text)
+		if err != nil {
+			return fmt.Errorf("invalid line %d of %s: %w", line, s.path, err)
+		}
+		if !ok {
+			continue
+		}
+		if err := fn(change); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read file list %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// parse returns the entry of a line, false when it is outside of the root
+func (s *policyListSource) parse(line string) (Change, bool, error) {
+	attrs, name := line, ""
+	if s.gpfs {
+		i := strings.Index(line, " -- ")
+		if i < 0 {
+			return Change{}, false, fmt.Errorf("missing ' -- ' before the path")
+		}
+		attrs, name = line[:i], line[i+len(" -- "):]
+		// 去掉开头的inode、generation及snapshot ID
+		for i := 0; i < 3; i++ {
+			attrs = strings.TrimLeft(attrs, " ")
+			end := strings.IndexByte(attrs, ' ')
+			if attrs == "" || (end < 0 && i < 2) {
+				return Change{}, false, fmt.Errorf("missing inode, generation and snapshot id")
+			}
+			if end < 0 {
+				end = len(attrs)
+			}
+			attrs = attrs[end:]
+		}
+		attrs = strings.TrimSpace(attrs)
+	}
+
+	values := strings.Split(attrs, s.delim)
+	// 路径是最后一个字段时可以包含分隔符
+	if !s.gpfs && len(values) > len(s.fields) && s.fields[len(s.fields)-1] == FieldPath {
+		values = append(values[:len(s.fields)-1], strings.Join(values[len(s.fields)-1:], s.delim))
+	}
+	if s.gpfs && attrs == "" {
+		values = nil
+	}
+	if len(values) != len(s.fields) && !(s.gpfs && len(values) == 0) {
+		return Change{}, false, fmt.Errorf("%d fields, expect %d (%s)", len(values), len(s.fields), strings.Join(s.fields, s.delim))
+	}
+
+	change := Change{Type: Listed, Perm: 0644}
+	var err error
+	for i, value := range values {
+		value = strings.TrimSpace(value)
+		switch s.fields[i] {
+		case FieldPath:
+			name = value
+		case FieldSize:
+			if change.Size, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return Change{}, false, fmt.Errorf("invalid size %q", value)
+			}
+		case FieldMTime:
+			change.MTime, err = parseListTime(value)
+		case FieldATime:
+			change.ATime, err = parseListTime(value)
+		case FieldCTime:
+			change.CTime, err = parseListTime(value)
+		case FieldMode:
+			change.Perm, err = parseListMode(value)
+			change.IsDir = change.Perm.IsDir()
+		case FieldType:
+			switch value {
+			case "d":
+				change.IsDir = true
+				change.Perm |= os.ModeDir
+			case "l":
+				change.Perm |= os.ModeSymlink
+			case "f":
+			default:
+				return Change{}, false, fmt.Errorf("unsupported type %q, expect f, d or l", value)
+			}
+		}
+		if err != nil {
+			return Change{}, false, err
+		}
+	}
+	if s.escape {
+		if name, err = url.PathUnescape(name); err != nil {
+			return Change{}, false, fmt.Errorf("invalid escaped path %q: %w", name, err)
+		}
+	}
+	if name == "" {
+		return Change{}, false, fmt.Errorf("empty path")
+	}
+	key, ok := relativeKey(s.root, name)
+	if !ok || key == "/" {
+		return Change{}, false, nil
+	}
+	change.Key = key
+	if change.IsDir {
+		change.Size = 0
+	}
+	return change, true, nil
+}
+
+// parseListTime parses a time in seconds since the epoch, optionally with a
+// fraction (lfs find %T@), or in the format of GPFS policy attributes
+// (2026-10-01 08:00:00.123456), which is the local time of the cluster
+func parseListTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		sec := int64(seconds)
+		return time.Unix(sec, int64((seconds-float64(sec))*1e9)).UTC(), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999", time.RFC3339Nano} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expect seconds since the epoch or 2006-01-02 15:04:05", value)
+}
+
+// parseListMode parses a mode in octal, with the file type bits of stat(2) or
+// only the permissions (lfs find %m), or in the symbolic form of GPFS MODE
+// (drwxr-xr-x)
+func parseListMode(value string) (os.FileMode, error) {
+	if len(value) == 10 && strings.ContainsRune("-dlpscb", rune(value[0])) {
+		var perm os.FileMode
+		for i, c := range value[1:] {
+			if c != '-' {
+				perm |= 1 << (8 - i)
+			}
+		}
+		switch value[0] {
+		case 'd':
+			perm |= os.ModeDir
+		case 'l':
+			perm |= os.ModeSymlink
+		case 'p':
+			perm |= os.ModeNamedPipe
+		case 's':
+			perm |= os.ModeSocket
+		case 'c':
+			perm |= os.ModeDevice | os.ModeCharDevice
+		case 'b':
+			perm |= os.ModeDevice
+		}
+		return perm, nil
+	}
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid mode %q, expect octal or drwxr-xr-x", value)
+	}
+	perm := os.FileMode(mode & 0777)
+	switch mode & 0170000 {
+	case 0040000:
+		perm |= os.ModeDir
+	case 0120000:
+		perm |= os.ModeSymlink
+	case 0010000:
+		perm |= os.ModeNamedPipe
+	case 0140000:
+		perm |= os.ModeSocket
+	case 0020000:
+		perm |= os.ModeDevice | os.ModeCharDevice
+	case 0060000:
+		perm |= os.ModeDevice
+	}
+	return perm, nil
+}
+
+func (s *policyListSource) Close() error {
+	return nil
+}
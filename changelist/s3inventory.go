@@ -0,0 +1,172 @@
+package changelist
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// s3InventorySource reads the objects of an S3 inventory report copied to the
+// local filesystem. An inventory lists every object, not the changes, so the
+// objects are Listed entries compared with the previous scan. Only the CSV
+// format is supported.
+type s3InventorySource struct {
+	manifest string
+	root     string
+	prefix   string
+}
+
+// s3InventoryManifest is the manifest.json of a report
+type s3InventoryManifest struct {
+	SourceBucket string `json:"sourceBucket"`
+	FileFormat   string `json:"fileFormat"`
+	FileSchema   string `json:"fileSchema"`
+	Files        []struct {
+		Key string `json:"key"`
+	} `json:"files"`
+}
+
+// openS3Inventory opens s3-inventory:///path/to/manifest.json[?prefix=share/&root=/mirror]
+func openS3Inventory(u *url.URL, config Config) (Source, error) {
+	if u.Path == "" {
+		return nil, fmt.Errorf("invalid s3-inventory uri %s, expect s3-inventory:///path/to/manifest.json", u.Redacted())
+	}
+	query := u.Query()
+	return &s3InventorySource{manifest: filepath.FromSlash(u.Path), root: query.Get("root"), prefix: query.Get("prefix")}, nil
+}
+
+// readS3InventoryManifest reads the manifest.json of a report
+func readS3InventoryManifest(name string) (*s3InventoryManifest, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory manifest: %w", err)
+	}
+	var manifest s3InventoryManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse inventory manifest %s: %w", name, err)
+	}
+	return &manifest, nil
+}
+
+// S3InventoryBucket returns the bucket listed by the S3 inventory report of the
+// manifest.json at name
+func S3InventoryBucket(name string) (string, error) {
+	manifest, err := readS3InventoryManifest(name)
+	if err != nil {
+		return "", err
+	}
+	if manifest.SourceBucket == "" {
+		return "", fmt.Errorf("inventory manifest %s has no sourceBucket", name)
+	}
+	return manifest.SourceBucket, nil
+}
+
+// dataFile returns the local path of a data file of the report. Without a root,
+// the report is expected in the layout of the destination bucket, where the data
+// files are in the data directory next to the dated directory of the manifest.
+func (s *s3InventorySource) dataFile(key string) string {
+	if s.root != "" {
+		return filepath.Join(s.root, filepath.FromSlash(key))
+	}
+	return filepath.Join(filepath.Dir(s.manifest), "..", "data", path.Base(key))
+}
+
+func (s *s3InventorySource) Changes(ctx context.Context, fn func(Change) error) error {
+	manifest, err := readS3InventoryManifest(s.manifest)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(manifest.FileFormat, "CSV") {
+		return fmt.Errorf("unsupported inventory format %q, only CSV is supported", manifest.FileFormat)
+	}
+	columns := make(map[string]int)
+	for i, name := range strings.Split(manifest.FileSchema, ",") {
+		columns[strings.TrimSpace(name)] = i
+	}
+	if _, ok := columns["Key"]; !ok {
+		return fmt.Errorf("inventory schema %q has no Key column", manifest.FileSchema)
+	}
+
+	for _, file := range manifest.Files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.readDataFile(s.dataFile(file.Key), columns, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readDataFile reads the objects of a gzip compressed CSV data file
+func (s *s3InventorySource) readDataFile(name string, columns map[string]int, fn func(Change) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open inventory data file: %w", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read inventory data file %s: %w", name, err)
+	}
+	defer zr.Close()
+
+	r := csv.NewReader(zr)
+	r.FieldsPerRecord = -1
+	column := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read inventory data file %s: %w", name, err)
+		}
+		// 只统计对象的当前版本
+		if column(record, "IsLatest") == "false" || column(record, "IsDeleteMarker") == "true" {
+			continue
+		}
+		// 清单中的key经过URL编码
+		key, err := url.QueryUnescape(column(record, "Key"))
+		if err != nil {
+			return fmt.Errorf("invalid key %q in inventory data file %s: %w", column(record, "Key"), name, err)
+		}
+		if !strings.HasPrefix(key, s.prefix) || strings.HasSuffix(key, "/") {
+			continue
+		}
+		change := Change{Type: Listed, Key: "/" + strings.TrimPrefix(strings.TrimPrefix(key, s.prefix), "/"), Perm: 0644}
+		if size := column(record, "Size"); size != "" {
+			if change.Size, err = strconv.ParseInt(size, 10, 64); err != nil {
+				return fmt.Errorf("invalid size of %s in inventory data file %s: %w", key, name, err)
+			}
+		}
+		if modified := column(record, "LastModifiedDate"); modified != "" {
+			if change.MTime, err = time.Parse(time.RFC3339, modified); err != nil {
+				return fmt.Errorf("invalid last modified date of %s in inventory data file %s: %w", key, name, err)
+			}
+			change.CTime, change.ATime = change.MTime, change.MTime
+		}
+		if err := fn(change); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *s3InventorySource) Close() error {
+	return nil
+}
@@ -0,0 +1,337 @@
+package changelist
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"terrasync/log"
+)
+
+// Reasons of the records of the NTFS change journal, see USN_RECORD_V2
+const (
+	usnReasonFileCreate    = 0x00000100
+	usnReasonFileDelete    = 0x00000200
+	usnReasonRenameOldName = 0x00001000
+)
+
+// usnRecord is a record of the change journal of a volume
+type usnRecord struct {
+	ID         [16]byte // 文件ID，NTFS的64位文件引用号补齐为128位(ReFS为128位)
+	Parent     [16]byte // 父目录的文件ID
+	USN        int64
+	Reason     uint32
+	Attributes uint32
+	Name       string
+}
+
+// usnVolume reads the change journal of an NTFS volume, with DeviceIoControl on
+// Windows. Paths are relative to the volume with slashes, such as /data/share.
+type usnVolume interface {
+	// query returns the id of the journal and its first and next USN
+	query() (journalID uint64, first, next int64, err error)
+	// read calls fn for the records of the journal from start up to end
+	read(ctx context.Context, journalID uint64, start, end int64, fn func(usnRecord) error) error
+	// path returns the current path of the file id
+	path(id [16]byte) (string, error)
+	// stat returns the current metadata of the path, without Type and Key
+	stat(p string) (Change, error)
+	// walk calls fn for every entry below the directory p
+	walk(p string, fn func(p string, change Change) error) error
+	Close() error
+}
+
+// usnSource reads the changes of a directory of a Windows volume from the NTFS
+// USN journal, from the position saved by the previous run up to the end of the
+// journal when the run started. The journal only names the changed files, so
+// their paths are resolved by file id and their metadata read from the volume.
+type usnSource struct {
+	volume  usnVolume
+	name    string // 卷，如C:
+	root    string // 扫描根目录相对卷的路径，如/data/share
+	journal uint64 // Position查询的日志ID
+	start   int64  // 上次运行保存的USN
+	end     int64  // Position查询的日志末尾
+	since   bool   // 已设置上次运行的位置
+}
+
+// openUSN opens usn:///C:/data/share, the directory of the volume to report changes of
+func openUSN(u *url.URL, config Config) (Source, error) {
+	name, root, err := parseUSNPath(u.Path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid usn uri %s, expect usn:///C:/path/to/share: %w", u.Redacted(), err)
+	}
+	volume, err := openUSNVolume(name)
+	if err != nil {
+		return nil, err
+	}
+	return &usnSource{volume: volume, name: name, root: root}, nil
+}
+
+// parseUSNPath splits the path of a usn uri into the volume and the directory
+func parseUSNPath(p string) (string, string, error) {
+	p = strings.TrimPrefix(strings.ReplaceAll(p, `\`, "/"), "/")
+	if len(p) < 2 || p[1] != ':' || !(p[0] >= 'A' && p[0] <= 'Z' || p[0] >= 'a' && p[0] <= 'z') {
+		return "", "", fmt.Errorf("missing drive letter")
+	}
+	return strings.ToUpper(p[:2]), path.Join("/", p[2:]), nil
+}
+
+// CursorName returns the name the journal position is saved under, one per volume
+func (s *usnSource) CursorName() string {
+	return "usn:" + s.name
+}
+
+// Position returns the id of the journal and its next USN as journal:usn
+func (s *usnSource) Position(ctx context.Context) (string, error) {
+	journal, _, next, err := s.volume.query()
+	if err != nil {
+		return "", err
+	}
+	s.journal, s.end = journal, next
+	return fmt.Sprintf("%d:%d", journal, next), nil
+}
+
+// Since sets the position saved by the previous run, the changes since are no
+// longer available when the journal was recreated or has wrapped
+func (s *usnSource) Since(position string) error {
+	id, usn, ok := strings.Cut(position, ":")
+	journal, err := strconv.ParseUint(id, 10, 64)
+	if !ok || err != nil {
+		return fmt.Errorf("invalid usn position %q, expect journal:usn", position)
+	}
+	start, err := strconv.ParseInt(usn, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid usn position %q, expect journal:usn", position)
+	}
+	current, first, _, err := s.volume.query()
+	if err != nil {
+		return err
+	}
+	if current != journal {
+		return fmt.Errorf("%w: the usn journal of %s was recreated since the previous run", ErrCursorExpired, s.name)
+	}
+	if start < first {
+		return fmt.Errorf("%w: the usn journal of %s no longer holds the changes since the previous run (usn %d, first %d)", ErrCursorExpired, s.name, start, first)
+	}
+	s.start, s.since = start, true
+	return nil
+}
+
+// usnChange accumulates the records of a file read from the journal
+type usnChange struct {
+	last      usnRecord // 最后一条记录，文件当前的名称和父目录
+	reasons   uint32    // 所有记录的原因
+	oldParent [16]byte  // 第一次重命名前的父目录
+	oldName   string    // 第一次重命名前的名称
+	renamed   bool
+}
+
+func (s *usnSource) Changes(ctx context.Context, fn func(Change) error) error {
+	if !s.since {
+		return fmt.Errorf("the usn journal of %s has no position of a previous run", s.name)
+	}
+	if s.end == 0 {
+		if _, err := s.Position(ctx); err != nil {
+			return err
+		}
+	}
+
+	// 按文件ID合并读取到的记录，同一个文件多次修改只报告一次
+	files := make(map[[16]byte]*usnChange)
+	err := s.volume.read(ctx, s.journal, s.start, s.end, func(rec usnRecord) error {
+		c := files[rec.ID]
+		if c == nil {
+			c = &usnChange{}
+			files[rec.ID] = c
+		}
+		c.reasons |= rec.Reason
+		if rec.Reason&usnReasonRenameOldName != 0 {
+			if !c.renamed {
+				c.oldParent, c.oldName, c.renamed = rec.Parent, rec.Name, true
+			}
+			if c.last.Name != "" {
+				return nil
+			}
+		}
+		c.last = rec
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read usn journal of %s: %w", s.name, err)
+	}
+
+	changes := make(map[string]Change)
+	add := func(change Change) {
+		// 同一路径上被删除的旧文件不覆盖新文件
+		if prev, ok := changes[change.Key]; ok && change.Type == Deleted && prev.Type != Deleted {
+			return
+		}
+		changes[change.Key] = change
+	}
+	dirs := make(map[[16]byte]string)
+	var unresolved int
+	for _, c := range files {
+		created := c.reasons&usnReasonFileCreate != 0
+		if c.last.Reason&usnReasonFileDelete != 0 {
+			// 两次运行之间创建又删除的临时文件
+			if created {
+				continue
+			}
+			parent, name := c.last.Parent, c.last.Name
+			if c.renamed {
+				parent, name = c.oldParent, c.oldName
+			}
+			if key, ok := s.key(dirs, parent, name); ok {
+				add(Change{Type: Deleted, Key: key})
+			} else {
+				unresolved++
+			}
+			continue
+		}
+
+		dir, err := s.dirPath(dirs, c.last.Parent)
+		if err != nil {
+			// 父目录在读取日志之后被删除
+			log.Warnf("Failed to resolve the directory of %s in the usn journal of %s: %v", c.last.Name, s.name, err)
+			unresolved++
+			continue
+		}
+		p := path.Join(dir, c.last.Name)
+		key, inRoot := relativeKey(s.root, p)
+		if c.renamed && !created {
+			if oldKey, ok := s.key(dirs, c.oldParent, c.oldName); ok && oldKey != key {
+				add(Change{Type: Deleted, Key: oldKey})
+			}
+		}
+		if !inRoot || key == "/" {
+			continue
+		}
+		change, err := s.volume.stat(p)
+		if err != nil {
+			log.Warnf("Failed to stat %s%s: %v", s.name, p, err)
+			continue
+		}
+		change.Type, change.Key = Modified, key
+		if created || c.renamed {
+			change.Type = Added
+		}
+		add(change)
+
+		// 重命名或移入的目录下的条目都是新的路径
+		if c.renamed && change.IsDir {
+			if err := s.volume.walk(p, func(p string, change Change) error {
+				if key, ok := relativeKey(s.root, p); ok {
+					change.Type, change.Key = Added, key
+					add(change)
+				}
+				return ctx.Err()
+			}); err != nil {
+				return fmt.Errorf("failed to list renamed directory %s%s: %w", s.name, p, err)
+			}
+		}
+	}
+	if unresolved > 0 {
+		log.Warnf("%d changes of the usn journal of %s could not be resolved to a path", unresolved, s.name)
+	}
+
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := fn(changes[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dirPath returns the current path of the directory id relative to the volume
+func (s *usnSource) dirPath(dirs map[[16]byte]string, id [16]byte) (string, error) {
+	if p, ok := dirs[id]; ok {
+		return p, nil
+	}
+	p, err := s.volume.path(id)
+	if err != nil {
+		return "", err
+	}
+	p = path.Join("/", p)
+	dirs[id] = p
+	return p, nil
+}
+
+// key returns the key of name in the directory id, false when the directory no
+// longer exists or is outside of the root
+func (s *usnSource) key(dirs map[[16]byte]string, parent [16]byte, name string) (string, bool) {
+	dir, err := s.dirPath(dirs, parent)
+	if err != nil {
+		return "", false
+	}
+	key, ok := relativeKey(s.root, path.Join(dir, name))
+	return key, ok && key != "/"
+}
+
+func (s *usnSource) Close() error {
+	return s.volume.Close()
+}
+
+// parseUSNRecords calls fn for the USN_RECORD_V2 and USN_RECORD_V3 records of a
+// buffer returned by FSCTL_READ_USN_JOURNAL, after its leading next USN
+func parseUSNRecords(buf []byte, fn func(usnRecord) error) error {
+	le := binary.LittleEndian
+	for len(buf) >= 8 {
+		length := int(le.Uint32(buf))
+		if length < 8 || length > len(buf) {
+			return fmt.Errorf("invalid usn record length %d", length)
+		}
+		record := buf[:length]
+		buf = buf[length:]
+
+		var rec usnRecord
+		var offset int // USN字段的偏移
+		switch major := le.Uint16(record[4:]); major {
+		case 2:
+			if length < 60 {
+				return fmt.Errorf("invalid usn record length %d", length)
+			}
+			le.PutUint64(rec.ID[:], le.Uint64(record[8:]))
+			le.PutUint64(rec.Parent[:], le.Uint64(record[16:]))
+			offset = 24
+		case 3:
+			if length < 76 {
+				return fmt.Errorf("invalid usn record length %d", length)
+			}
+			copy(rec.ID[:], record[8:24])
+			copy(rec.Parent[:], record[24:40])
+			offset = 40
+		default:
+			return fmt.Errorf("unsupported usn record version %d", major)
+		}
+		rec.USN = int64(le.Uint64(record[offset:]))
+		// 跳过TimeStamp
+		rec.Reason = le.Uint32(record[offset+16:])
+		// 跳过SourceInfo和SecurityId
+		rec.Attributes = le.Uint32(record[offset+28:])
+		nameLength, nameOffset := int(le.Uint16(record[offset+32:])), int(le.Uint16(record[offset+34:]))
+		if nameOffset+nameLength > length {
+			return fmt.Errorf("invalid usn record file name")
+		}
+		name := make([]uint16, nameLength/2)
+		for i := range name {
+			name[i] = le.Uint16(record[nameOffset+2*i:])
+		}
+		rec.Name = string(utf16.Decode(name))
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package changelist
+
+import "fmt"
+
+// openUSNVolume fails, the USN journal is a feature of NTFS volumes on Windows
+func openUSNVolume(name string) (usnVolume, error) {
+	return nil, fmt.Errorf("the usn journal of %s can only be read on Windows", name)
+}
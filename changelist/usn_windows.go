@@ -0,0 +1,190 @@
+//go:build windows
+
+package changelist
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Control codes and flags of the change journal, not defined by x/sys/windows
+const (
+	fsctlQueryUSNJournal = 0x000900f4
+	fsctlReadUSNJournal  = 0x000900bb
+	extendedFileIDType   = 2   // FILE_ID_DESCRIPTOR.Type，128位文件ID
+	volumeNameNone       = 0x4 // GetFinalPathNameByHandle返回相对卷的路径
+	usnReadBufferSize    = 1 << 20
+)
+
+var procOpenFileByID = windows.NewLazySystemDLL("kernel32.dll").NewProc("OpenFileById")
+
+// usnJournalData is USN_JOURNAL_DATA_V0
+type usnJournalData struct {
+	UsnJournalID    uint64
+	FirstUsn        int64
+	NextUsn         int64
+	LowestValidUsn  int64
+	MaxUsn          int64
+	MaximumSize     uint64
+	AllocationDelta uint64
+}
+
+// readUSNJournalData is READ_USN_JOURNAL_DATA_V1
+type readUSNJournalData struct {
+	StartUsn          int64
+	ReasonMask        uint32
+	ReturnOnlyOnClose uint32
+	Timeout           uint64
+	BytesToWaitFor    uint64
+	UsnJournalID      uint64
+	MinMajorVersion   uint16
+	MaxMajorVersion   uint16
+}
+
+// fileIDDescriptor is FILE_ID_DESCRIPTOR with an extended file id
+type fileIDDescriptor struct {
+	Size uint32
+	Type uint32
+	ID   [16]byte
+}
+
+// windowsVolume reads the change journal of a volume opened as \\.\C:, which
+// requires administrator rights
+type windowsVolume struct {
+	name   string
+	handle windows.Handle
+}
+
+// openUSNVolume opens the volume C: to read its change journal
+func openUSNVolume(name string) (usnVolume, error) {
+	p, err := windows.UTF16PtrFromString(`\\.\` + name)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := windows.CreateFile(p, windows.GENERIC_READ, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE,
+		nil, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open volume %s (administrator rights are required): %w", name, err)
+	}
+	return &windowsVolume{name: name, handle: handle}, nil
+}
+
+func (v *windowsVolume) query() (uint64, int64, int64, error) {
+	var data usnJournalData
+	var n uint32
+	if err := windows.DeviceIoControl(v.handle, fsctlQueryUSNJournal, nil, 0,
+		(*byte)(unsafe.Pointer(&data)), uint32(unsafe.Sizeof(data)), &n, nil); err != nil {
+		if errors.Is(err, windows.ERROR_JOURNAL_NOT_ACTIVE) {
+			return 0, 0, 0, fmt.Errorf("the usn journal of %s is not active, create it with fsutil usn createjournal: %w", v.name, err)
+		}
+		return 0, 0, 0, fmt.Errorf("failed to query usn journal of %s: %w", v.name, err)
+	}
+	return data.UsnJournalID, data.FirstUsn, data.NextUsn, nil
+}
+
+func (v *windowsVolume) read(ctx context.Context, journalID uint64, start, end int64, fn func(usnRecord) error) error {
+	buf := make([]byte, usnReadBufferSize)
+	in := readUSNJournalData{StartUsn: start, ReasonMask: 0xffffffff, UsnJournalID: journalID, MinMajorVersion: 2, MaxMajorVersion: 3}
+	for in.StartUsn < end {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var n uint32
+		if err := windows.DeviceIoControl(v.handle, fsctlReadUSNJournal, (*byte)(unsafe.Pointer(&in)), uint32(unsafe.Sizeof(in)),
+			&buf[0], uint32(len(buf)), &n, nil); err != nil {
+			if errors.Is(err, windows.ERROR_JOURNAL_ENTRY_DELETED) {
+				return fmt.Errorf("%w: %v", ErrCursorExpired, err)
+			}
+			return err
+		}
+		if n <= 8 {
+			return nil
+		}
+		next := int64(binary.LittleEndian.Uint64(buf))
+		// 只读取到开始扫描时的日志末尾，之后的变化由下次运行读取
+		if err := parseUSNRecords(buf[8:n], func(rec usnRecord) error {
+			if rec.USN >= end {
+				return nil
+			}
+			return fn(rec)
+		}); err != nil {
+			return err
+		}
+		if next <= in.StartUsn {
+			return nil
+		}
+		in.StartUsn = next
+	}
+	return nil
+}
+
+func (v *windowsVolume) path(id [16]byte) (string, error) {
+	desc := fileIDDescriptor{Type: extendedFileIDType, ID: id}
+	desc.Size = uint32(unsafe.Sizeof(desc))
+	r, _, err := procOpenFileByID.Call(uintptr(v.handle), uintptr(unsafe.Pointer(&desc)), 0,
+		uintptr(windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE), 0,
+		uintptr(windows.FILE_FLAG_BACKUP_SEMANTICS))
+	handle := windows.Handle(r)
+	if handle == windows.InvalidHandle {
+		return "", fmt.Errorf("failed to open file id: %w", err)
+	}
+	defer windows.CloseHandle(handle)
+
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	n, err := windows.GetFinalPathNameByHandle(handle, &buf[0], uint32(len(buf)), volumeNameNone)
+	if err != nil {
+		return "", fmt.Errorf("failed to get path of file id: %w", err)
+	}
+	return filepath.ToSlash(windows.UTF16ToString(buf[:n])), nil
+}
+
+func (v *windowsVolume) stat(p string) (Change, error) {
+	info, err := os.Lstat(v.name + filepath.FromSlash(p))
+	if err != nil {
+		return Change{}, err
+	}
+	return fileChange(info), nil
+}
+
+func (v *windowsVolume) walk(p string, fn func(p string, change Change) error) error {
+	root := v.name + filepath.FromSlash(p)
+	return filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || name == root {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(strings.TrimPrefix(name, v.name)), fileChange(info))
+	})
+}
+
+// fileChange returns the metadata of a file as a change, the creation time of
+// Windows files is reported as CTime like the local storage does
+func fileChange(info os.FileInfo) Change {
+	change := Change{Size: info.Size(), MTime: info.ModTime(), Perm: info.Mode(), IsDir: info.IsDir()}
+	if data, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		change.CTime = time.Unix(0, data.CreationTime.Nanoseconds())
+		change.ATime = time.Unix(0, data.LastAccessTime.Nanoseconds())
+	}
+	if change.IsDir {
+		change.Size = 0
+	}
+	return change
+}
+
+func (v *windowsVolume) Close() error {
+	return windows.CloseHandle(v.handle)
+}
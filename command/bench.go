@@ -0,0 +1,90 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"terrasync/app/gen"
+	"terrasync/app/scan"
+	"terrasync/bench"
+	"terrasync/i18n"
+	"terrasync/pkg/units"
+
+	"github.com/spf13/cobra"
+)
+
+// NewBenchCommand creates the end-to-end benchmark command
+func NewBenchCommand(AppVersion string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Run reproducible end-to-end throughput benchmarks",
+		Long:  "Benchmark scan and migrate throughput on seeded in-memory data, independent of the local disks and network. Results are written as JSON so that releases can be compared.",
+		Example: `  Scan one million files and migrate 100GB, writing the results to a file:
+    terrasync bench --scan-files 1M --migrate-size 100G --output bench.json
+
+  Quick scan-only run:
+    terrasync bench --scan-files 100K --migrate-size 0`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filesFlag, _ := cmd.Flags().GetString("scan-files")
+			migrateFlag, _ := cmd.Flags().GetString("migrate-size")
+			fileSizeFlag, _ := cmd.Flags().GetString("file-size")
+			output, _ := cmd.Flags().GetString("output")
+
+			scanFiles, err := gen.ParseCount(filesFlag)
+			if err != nil {
+				return err
+			}
+			migrateBytes, err := units.ParseSize(migrateFlag)
+			if err != nil {
+				return fmt.Errorf("invalid migrate size: %w", err)
+			}
+			fileSize, err := units.ParseSize(fileSizeFlag)
+			if err != nil {
+				return fmt.Errorf("invalid file size: %w", err)
+			}
+
+			benchConfig := bench.Config{ScanFiles: scanFiles, MigrateBytes: migrateBytes, MigrateFileSize: fileSize}
+			benchConfig.Depth, _ = cmd.Flags().GetInt("depth")
+			benchConfig.Concurrency, _ = cmd.Flags().GetInt("concurrency")
+			benchConfig.Seed, _ = cmd.Flags().GetInt64("seed")
+
+			report, err := bench.Run(cmd.Context(), AppVersion, benchConfig)
+			if report == nil {
+				return err
+			}
+			for _, result := range report.Results {
+				if result.Error != "" {
+					fmt.Fprint(os.Stderr, i18n.Sprintf("%s failed: %s\n", i18n.Pad(result.Name, 8), result.Error))
+					continue
+				}
+				fmt.Fprint(os.Stderr, i18n.Sprintf("%s %d entries, %s in %.2fs: %.0f entries/s, %.1f MB/s\n",
+					i18n.Pad(result.Name, 8), result.Entries, scan.FormatFileSize(result.Bytes),
+					result.Seconds, result.EntriesPerSec, result.MBPerSec))
+			}
+
+			out := os.Stdout
+			if output != "" && output != "-" {
+				f, err := os.Create(output)
+				if err != nil {
+					return fmt.Errorf("failed to create output file: %w", err)
+				}
+				defer f.Close()
+				out = f
+			}
+			if err := report.WriteJSON(out); err != nil {
+				return fmt.Errorf("failed to write results: %w", err)
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringP("scan-files", "", "1M", "Number of files in the scan benchmark, 0 skips it")
+	cmd.Flags().StringP("migrate-size", "", "100G", "Amount of data in the migrate benchmark, 0 skips it")
+	cmd.Flags().StringP("file-size", "", "1M", "Mean file size in the migrate benchmark")
+	cmd.Flags().IntP("depth", "d", 3, "Depth of the synthetic trees")
+	cmd.Flags().IntP("concurrency", "", 0, "Listing and copy concurrency, 0 uses the number of CPUs")
+	cmd.Flags().Int64P("seed", "", 1, "Random seed, the same seed produces the same data")
+	cmd.Flags().StringP("output", "o", "", "Write the JSON results to a file instead of stdout")
+
+	return cmd
+}
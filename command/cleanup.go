@@ -0,0 +1,95 @@
+package command
+
+import (
+	"fmt"
+	"path/filepath"
+	"terrasync/app/cleanup"
+	"terrasync/app/scan"
+	"terrasync/i18n"
+	"terrasync/pkg/units"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// NewCleanupCommand creates the cleanup command
+func NewCleanupCommand(AppVersion string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cleanup <destination>",
+		Short: "Remove what interrupted jobs left behind",
+		Long:  "Abort abandoned multipart uploads (S3) and remove leftover .terrasync.tmp files on the destination, and drop the temporary tables interrupted incremental scans left in the job databases, reporting the reclaimed space. With --empty-dirs the directories left empty on the destination, such as those of a migration whose exclusion filters skipped all their files, are removed as well.",
+		Example: `  Show what would be removed:
+    terrasync cleanup --dry-run s3://bucket/prefix
+
+  Remove leftovers older than a week:
+    terrasync cleanup --older-than 1w /mnt/dst
+
+  Remove the directories a filtered migration left empty, keeping those empty on the source:
+    terrasync cleanup --empty-dirs --source /mnt/src /mnt/dst`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			goexeDir, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			olderThanFlag, _ := cmd.Flags().GetString("older-than")
+			olderThan, err := units.ParseDuration(olderThanFlag)
+			if err != nil {
+				return fmt.Errorf("invalid older than: %w", err)
+			}
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			emptyDirs, _ := cmd.Flags().GetBool("empty-dirs")
+			source, _ := cmd.Flags().GetString("source")
+			if source != "" && !emptyDirs {
+				return fmt.Errorf("--source requires --empty-dirs")
+			}
+			config := cleanup.Config{
+				Destination: args[0],
+				OlderThan:   olderThan,
+				Concurrency: viper.GetInt("scan.concurrency"),
+				DryRun:      dryRun,
+				EmptyDirs:   emptyDirs,
+				Source:      source,
+			}
+			if jobTables, _ := cmd.Flags().GetBool("job-tables"); jobTables {
+				config.JobsDir = filepath.Join(goexeDir, "jobs")
+			}
+
+			result, err := cleanup.Run(cmd.Context(), config)
+			if result != nil {
+				printCleanup(result, config)
+			}
+			if err != nil {
+				return err
+			}
+			if result.Failed > 0 {
+				return fmt.Errorf("failed to remove %d leftovers, see the log", result.Failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("older-than", "", "24h", "Only remove leftovers older than this, so running jobs are not affected")
+	cmd.Flags().BoolP("dry-run", "", false, "Only report what would be removed")
+	cmd.Flags().BoolP("job-tables", "", true, "Also drop the temporary tables of interrupted scans in the job databases")
+	cmd.Flags().BoolP("empty-dirs", "", false, "Also remove the empty directories of the destination")
+	cmd.Flags().StringP("source", "", "", "Source of the migration, with --empty-dirs the directories also empty on the source are kept")
+
+	return cmd
+}
+
+func printCleanup(result *cleanup.Result, config cleanup.Config) {
+	if config.DryRun {
+		fmt.Print(i18n.T("Dry run, nothing was removed\n"))
+	}
+	if result.UploadsKnown {
+		fmt.Print(i18n.Sprintf("Multipart uploads aborted: %d, %s\n", result.Uploads.Count, scan.FormatFileSize(result.Uploads.Bytes)))
+	}
+	fmt.Print(i18n.Sprintf("Temporary files removed:   %d, %s\n", result.TempFiles.Count, scan.FormatFileSize(result.TempFiles.Bytes)))
+	fmt.Print(i18n.Sprintf("Temporary tables dropped:  %d in %d jobs, %s\n", result.TempTables.Count, result.Jobs, scan.FormatFileSize(result.TempTables.Bytes)))
+	if config.EmptyDirs {
+		fmt.Print(i18n.Sprintf("Empty directories removed: %d\n", result.EmptyDirs.Count))
+	}
+	fmt.Print(i18n.Sprintf("Reclaimed: %s\n", scan.FormatFileSize(result.ReclaimedSize)))
+}
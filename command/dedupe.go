@@ -0,0 +1,117 @@
+package command
+
+import (
+	"fmt"
+	"terrasync/app/scan"
+	"terrasync/i18n"
+	"terrasync/object"
+	"terrasync/pkg/units"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// NewDedupeCommand creates the command finding the files with identical contents
+func NewDedupeCommand(AppVersion string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dedupe <path>",
+		Short: "Find the files with identical contents",
+		Long:  "List the files below the path, group them by size and hash the contents of the files sharing their size with another one, then report the sets of identical files with the space reclaimable by keeping one of each. --sample reads only three blocks of the larger files, much faster on large media but the files reported are then only probably identical. Files are never modified.",
+		Example: `  Find the duplicate files of a share with xxHash:
+    terrasync dedupe /mnt/share
+
+  Compare SHA-256 digests of samples of the files of at least 1 MiB, and export all the sets:
+    terrasync dedupe --hash sha256 --sample 1M --min-size 1M --csv duplicates.csv /mnt/share
+
+  Save the duplicate sets into the database of the scan job of the same path:
+    terrasync dedupe --job nightly /mnt/share`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			goexeDir, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			minSize, _ := cmd.Flags().GetString("min-size")
+			minBytes, err := units.ParseSize(minSize)
+			if err != nil {
+				return fmt.Errorf("invalid min size: %w", err)
+			}
+			sampleFlag, _ := cmd.Flags().GetString("sample")
+			sample, err := units.ParseSize(sampleFlag)
+			if err != nil {
+				return fmt.Errorf("invalid sample size: %w", err)
+			}
+			hashName, _ := cmd.Flags().GetString("hash")
+			depth, _ := cmd.Flags().GetInt("depth")
+			top, _ := cmd.Flags().GetInt("top")
+			matchExpr, _ := cmd.Flags().GetString("match")
+			excludeExpr, _ := cmd.Flags().GetString("exclude")
+			match, err := scan.NewConditionFilter(scan.ParseConditions(matchExpr))
+			if err != nil {
+				return fmt.Errorf("failed to create match conditions: %w", err)
+			}
+			exclude, err := scan.NewConditionFilter(scan.ParseConditions(excludeExpr))
+			if err != nil {
+				return fmt.Errorf("failed to create exclude conditions: %w", err)
+			}
+			jobDir := ""
+			if jobID, _ := cmd.Flags().GetString("job"); jobID != "" {
+				if jobDir, err = scanJobDir(goexeDir, jobID); err != nil {
+					return err
+				}
+			}
+
+			storage, err := object.CreateStorage(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to create storage: %w", err)
+			}
+			defer storage.Close()
+
+			duplicates, err := scan.FindDuplicateFiles(cmd.Context(), storage, args[0], scan.DuplicateFileOptions{
+				Concurrency: viper.GetInt("scan.concurrency"),
+				Depth:       depth,
+				Match:       match,
+				Exclude:     exclude,
+				MinBytes:    minBytes,
+				Hash:        hashName,
+				Sample:      sample,
+				Top:         top,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to find duplicate files: %w", err)
+			}
+			duplicates.Print()
+
+			if csvPath, _ := cmd.Flags().GetString("csv"); csvPath != "" {
+				if err := duplicates.WriteCSV(csvPath); err != nil {
+					return err
+				}
+				fmt.Printf("%s: %s\n", i18n.T("CSV Report"), csvPath)
+			}
+			if jobDir != "" {
+				if err := duplicates.SaveToJob(cmd.Context(), jobDir); err != nil {
+					return fmt.Errorf("failed to save duplicate files: %w", err)
+				}
+				fmt.Print(i18n.Sprintf("Duplicate files saved into the job database: %s\n", jobDir))
+			}
+			if duplicates.Errors > 0 {
+				return fmt.Errorf("failed to read %d files, see the log", duplicates.Errors)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("hash", "", "xxhash", "Hash of the file contents (xxhash, md5, sha1 or sha256), sha256 or sha384 in FIPS mode")
+	cmd.Flags().StringP("sample", "", "0", "Only hash the first, middle and last blocks of this size of larger files, 0 hashes whole files")
+	cmd.Flags().StringP("min-size", "", "1", "Don't compare files smaller than this size")
+	cmd.Flags().IntP("depth", "d", 0, "Set maximum depth")
+	cmd.Flags().StringP("match", "m", "", "Compare only files matching the given expression")
+	cmd.Flags().StringP("exclude", "e", "", "Exclude files using the given expression")
+	cmd.Flags().IntP("top", "", 20, "Number of sets listed, by reclaimable space, 0 lists all")
+	cmd.Flags().StringP("csv", "", "", "Write every duplicate file to this CSV file")
+	cmd.Flags().StringP("job", "", "", "Save the duplicate sets into the duplicate_files table of this scan job")
+
+	return cmd
+}
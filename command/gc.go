@@ -0,0 +1,130 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"terrasync/app/gc"
+	"terrasync/app/scan"
+	"terrasync/i18n"
+	"terrasync/log"
+	"terrasync/pkg/units"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// NewGCCommand creates the command removing the artifacts past their retention
+func NewGCCommand(AppVersion string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Remove old job directories, exports and logs",
+		Long:  "Remove the artifacts older than their retention (retention in config.yaml) from the directory of the executable: the job directories, the reports migrate and verify write next to the executable (failures_, reconcile_, rewrite_, stubs_, acceptance_ and verify_ files) and the rotated log files, reporting the reclaimed space. The most recent retention.keep_jobs jobs are always kept. The long-running watch command does the same every retention.interval.",
+		Example: `  Show what would be removed:
+    terrasync gc --dry-run
+
+  Remove the job directories older than two weeks, keeping the last 5 jobs:
+    terrasync gc --jobs 2w --keep-jobs 5`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			goexeDir, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			for key, flag := range map[string]string{
+				"retention.jobs":      "jobs",
+				"retention.keep_jobs": "keep-jobs",
+				"retention.exports":   "exports",
+				"retention.logs":      "logs",
+			} {
+				if err := viper.BindPFlag(key, cmd.Flags().Lookup(flag)); err != nil {
+					return fmt.Errorf("failed to bind flag %s: %w", flag, err)
+				}
+			}
+			config, err := retentionConfig(goexeDir)
+			if err != nil {
+				return err
+			}
+			config.DryRun, _ = cmd.Flags().GetBool("dry-run")
+
+			result, err := gc.Run(cmd.Context(), config)
+			if result != nil {
+				printGC(result, config)
+			}
+			if err != nil {
+				return err
+			}
+			if result.Failed > 0 {
+				return fmt.Errorf("failed to remove %d artifacts, see the log", result.Failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("jobs", "", "30d", "Remove the job directories not modified within this, 0 keeps them")
+	cmd.Flags().IntP("keep-jobs", "", 10, "Always keep this many of the most recent jobs")
+	cmd.Flags().StringP("exports", "", "30d", "Remove the reports written next to the executable older than this, 0 keeps them")
+	cmd.Flags().StringP("logs", "", "30d", "Remove the rotated log files older than this, 0 keeps them")
+	cmd.Flags().BoolP("dry-run", "", false, "Only report what would be removed")
+
+	return cmd
+}
+
+// retentionConfig reads the retention of the artifacts of goexeDir from the
+// configuration
+func retentionConfig(goexeDir string) (gc.Config, error) {
+	config := gc.Config{Dir: goexeDir, KeepJobs: viper.GetInt("retention.keep_jobs")}
+	for _, d := range []struct {
+		key string
+		dst *time.Duration
+	}{
+		{"retention.jobs", &config.Jobs},
+		{"retention.exports", &config.Exports},
+		{"retention.logs", &config.Logs},
+	} {
+		value := viper.GetString(d.key)
+		if value == "" {
+			continue
+		}
+		duration, err := units.ParseDuration(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid %s: %w", d.key, err)
+		}
+		*d.dst = duration
+	}
+	return config, nil
+}
+
+// startHousekeeping runs terrasync gc every retention.interval in the
+// background of a long-running command, until ctx is cancelled
+func startHousekeeping(ctx context.Context, goexeDir string) error {
+	value := viper.GetString("retention.interval")
+	if value == "" {
+		return nil
+	}
+	interval, err := units.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid retention.interval: %w", err)
+	}
+	if interval <= 0 {
+		return nil
+	}
+	config, err := retentionConfig(goexeDir)
+	if err != nil {
+		return err
+	}
+	log.Infof("Housekeeping every %s", interval)
+	go gc.Schedule(ctx, config, interval)
+	return nil
+}
+
+func printGC(result *gc.Result, config gc.Config) {
+	if config.DryRun {
+		fmt.Print(i18n.T("Dry run, nothing was removed\n"))
+	}
+	fmt.Print(i18n.Sprintf("Job directories removed: %d, %s\n", result.Jobs.Count, scan.FormatFileSize(result.Jobs.Bytes)))
+	fmt.Print(i18n.Sprintf("Exports removed:         %d, %s\n", result.Exports.Count, scan.FormatFileSize(result.Exports.Bytes)))
+	fmt.Print(i18n.Sprintf("Rotated logs removed:    %d, %s\n", result.Logs.Count, scan.FormatFileSize(result.Logs.Bytes)))
+	fmt.Print(i18n.Sprintf("Reclaimed: %s\n", scan.FormatFileSize(result.ReclaimedSize)))
+}
@@ -0,0 +1,229 @@
+package command
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"terrasync/app/scan"
+	"terrasync/changelist"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// NewImportCommand creates the command loading listings of other tools as scan jobs
+func NewImportCommand(AppVersion string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import listings produced by the storage platform as scan jobs",
+		Long:  "Load a listing of the storage produced by the platform itself into the database of a new scan job, so it can be reported and used as the baseline of incremental scans without listing the storage.",
+	}
+
+	gpfs := newPolicyListImportCommand(AppVersion, "gpfs-list",
+		"Import a GPFS mmapplypolicy file list as a scan job",
+		"Load the file list written by a LIST rule of mmapplypolicy -I defer into a new scan job. Each line holds the inode, generation and snapshot id, the SHOW attributes separated by --delim and the path after ' -- '. The attributes default to size|mtime|atime|ctime|mode.",
+		`  Import the list of the share fileset as job share:
+    mmapplypolicy /gpfs/fs1/share -P list.pol -I defer -f /tmp/share
+    terrasync import gpfs-list --id share --root /gpfs/fs1/share /tmp/share.list.files`)
+	gpfs.Flags().BoolP("escape", "", false, "Paths are URL encoded by an ESCAPE '%' clause of the policy")
+	lfs := newPolicyListImportCommand(AppVersion, "lfs-find",
+		"Import a Lustre lfs find listing as a scan job",
+		"Load the output of lfs find --printf into a new scan job, one entry per line with the fields separated by --delim. The fields default to size|mtime|atime|ctime|mode|type|path, the path may contain the delimiter when it is the last field.",
+		`  Import a listing of the share directory as job share:
+    lfs find /lustre/share --printf '%s|%T@|%A@|%C@|%m|%y|%p\n' > /tmp/share.list
+    terrasync import lfs-find --id share --root /lustre/share /tmp/share.list`)
+	cmd.AddCommand(newS3InventoryImportCommand(AppVersion), gpfs, lfs)
+
+	return cmd
+}
+
+// newS3InventoryImportCommand creates the command importing an S3 inventory report
+func newS3InventoryImportCommand(AppVersion string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "s3-inventory <manifest.json>",
+		Short: "Import an S3 inventory report as a scan job",
+		Long:  "Load the objects of an S3 inventory report (CSV format, copied to the local filesystem) into a new scan job, so bucket-to-bucket migrations can be planned without issuing LIST calls on billions of objects. Later scans with the same --id compare the bucket with the report.",
+		Example: `  Import the daily inventory of the share/ prefix of bucket as job bucket:
+    terrasync import s3-inventory --id bucket --prefix share/ /data/inventory/bucket/config/2026-10-14T01-00Z/manifest.json
+
+  Compare the bucket with the inventory later:
+    terrasync scan --id bucket s3://bucket/share`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			goexeDir, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			manifest, err := filepath.Abs(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid manifest path %s: %w", args[0], err)
+			}
+			jobID, err := importJobID(cmd)
+			if err != nil {
+				return err
+			}
+			prefix, _ := cmd.Flags().GetString("prefix")
+			root, _ := cmd.Flags().GetString("root")
+			path, _ := cmd.Flags().GetString("path")
+			if path == "" {
+				bucket, err := changelist.S3InventoryBucket(manifest)
+				if err != nil {
+					return err
+				}
+				path = "s3://" + bucket + "/" + strings.Trim(prefix, "/")
+			}
+
+			query := url.Values{}
+			if prefix != "" {
+				query.Set("prefix", prefix)
+			}
+			if root != "" {
+				query.Set("root", root)
+			}
+			uri := (&url.URL{Scheme: "s3-inventory", Path: filepath.ToSlash(manifest), RawQuery: query.Encode()}).String()
+			source, err := changelist.Open(uri, changelist.Config{})
+			if err != nil {
+				return err
+			}
+			defer source.Close()
+
+			return importListing(cmd, AppVersion, goexeDir, jobID, path, source)
+		},
+	}
+
+	cmd.Flags().StringP("id", "", "", "Job id of the imported scan job, later scans with this id are incremental")
+	cmd.Flags().StringP("prefix", "", "", "Import only the objects below this key prefix, keys are made relative to it")
+	cmd.Flags().StringP("root", "", "", "Local directory the report was copied to in the layout of its bucket (default: next to the manifest)")
+	cmd.Flags().StringP("path", "", "", "Storage path the job is recorded for (default: s3://<sourceBucket>/<prefix>)")
+	addImportFlags(cmd)
+
+	return cmd
+}
+
+// newPolicyListImportCommand creates the command importing the file list of the
+// policy engine of an HPC filesystem, scheme is the change list type reading it
+func newPolicyListImportCommand(AppVersion, scheme, short, long, example string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          scheme + " <list>",
+		Short:        short,
+		Long:         long,
+		Example:      example,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			goexeDir, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			list, err := filepath.Abs(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid list path %s: %w", args[0], err)
+			}
+			jobID, err := importJobID(cmd)
+			if err != nil {
+				return err
+			}
+			root, _ := cmd.Flags().GetString("root")
+			path, _ := cmd.Flags().GetString("path")
+			if path == "" {
+				path = root
+			}
+			if path == "" {
+				return fmt.Errorf("no storage path given for the imported job, use --root or --path")
+			}
+
+			query := url.Values{}
+			for _, flag := range []string{"root", "fields", "delim"} {
+				if value, _ := cmd.Flags().GetString(flag); value != "" {
+					query.Set(flag, value)
+				}
+			}
+			if cmd.Flags().Lookup("escape") != nil {
+				if escape, _ := cmd.Flags().GetBool("escape"); escape {
+					query.Set("escape", "true")
+				}
+			}
+			uri := (&url.URL{Scheme: scheme, Path: filepath.ToSlash(list), RawQuery: query.Encode()}).String()
+			source, err := changelist.Open(uri, changelist.Config{})
+			if err != nil {
+				return err
+			}
+			defer source.Close()
+
+			return importListing(cmd, AppVersion, goexeDir, jobID, path, source)
+		},
+	}
+
+	cmd.Flags().StringP("id", "", "", "Job id of the imported scan job, later scans with this id are incremental")
+	cmd.Flags().StringP("root", "", "", "Directory the listed paths are made relative to, paths outside of it are ignored")
+	cmd.Flags().StringP("path", "", "", "Storage path the job is recorded for (default: --root)")
+	cmd.Flags().StringP("fields", "", "", "Comma separated fields of each line (path, size, mtime, atime, ctime, mode, type or - to ignore a field)")
+	cmd.Flags().StringP("delim", "", "|", "Delimiter of the fields")
+	addImportFlags(cmd)
+
+	return cmd
+}
+
+// addImportFlags adds the filter and report flags shared by the import commands
+func addImportFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("match", "m", "", "Import only entries matching the given expression")
+	cmd.Flags().StringP("exclude", "e", "", "Exclude entries using the given expression")
+	cmd.Flags().BoolP("csv", "", false, "Create CSV report")
+	cmd.Flags().BoolP("html", "", false, "Create HTML report")
+}
+
+// importJobID returns the scan job ID of the --id flag
+func importJobID(cmd *cobra.Command) (string, error) {
+	jobID, _ := cmd.Flags().GetString("id")
+	if jobID == "" {
+		return "", fmt.Errorf("no job id given for the imported job, use --id")
+	}
+	if !strings.HasPrefix(jobID, "Job_") {
+		jobID = fmt.Sprintf("Job_%s_scan", jobID)
+	}
+	return jobID, nil
+}
+
+// importListing saves the entries of source as the scan job jobID of path and
+// prints its report
+func importListing(cmd *cobra.Command, AppVersion, goexeDir, jobID, path string, source changelist.Source) error {
+	matchExpr, _ := cmd.Flags().GetString("match")
+	excludeExpr, _ := cmd.Flags().GetString("exclude")
+	csvReport, _ := cmd.Flags().GetBool("csv")
+	htmlReport, _ := cmd.Flags().GetBool("html")
+
+	delimiter, err := csvDelimiter()
+	if err != nil {
+		return err
+	}
+	jobDir := filepath.Join(goexeDir, "jobs", jobID)
+	if _, err := scan.ImportListing(cmd.Context(), scan.ImportConfig{
+		Source:      source,
+		JobDir:      jobDir,
+		JobID:       jobID,
+		AppVersion:  AppVersion,
+		CmdLine:     buildCommandLine(cmd, cmd.Flags().Args()),
+		Path:        path,
+		DbType:      selectDBType(jobDir, 0),
+		DBBatchSize: viper.GetInt("database.batch_size"),
+		Match:       scan.ParseConditions(matchExpr),
+		Exclude:     scan.ParseConditions(excludeExpr),
+	}); err != nil {
+		return fmt.Errorf("failed to import listing: %w", err)
+	}
+
+	if _, err := scan.Regenerate(cmd.Context(), scan.RegenerateConfig{
+		JobDir:       jobDir,
+		LogPath:      filepath.Join(goexeDir, "terrasync.log"),
+		CSV:          csvReport,
+		HTML:         htmlReport,
+		CSVDelimiter: delimiter,
+	}); err != nil {
+		return fmt.Errorf("failed to generate reports: %w", err)
+	}
+	return nil
+}
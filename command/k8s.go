@@ -0,0 +1,189 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"terrasync/i18n"
+	"terrasync/k8s"
+	"terrasync/pkg/units"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// NewK8sCommand creates the commands running the workers of a distributed scan as Kubernetes Jobs
+func NewK8sCommand(AppVersion string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "k8s",
+		Short: "Run the workers of a distributed scan as Kubernetes Jobs",
+		Long:  "Create one Kubernetes Job per partition of a distributed scan and track them. The Jobs are applied and queried with kubectl, using the cluster and credentials of its current context.",
+	}
+	cmd.PersistentFlags().StringP("namespace", "n", "", "Namespace of the Jobs (default: namespace of the kubectl context)")
+	cmd.PersistentFlags().StringP("context", "", "", "kubectl context to use")
+	cmd.PersistentFlags().StringP("kubectl", "", "kubectl", "Path of the kubectl executable")
+
+	cmd.AddCommand(newK8sJobsCommand(AppVersion), newK8sStatusCommand())
+	return cmd
+}
+
+// kubectl returns the kubectl runner configured by the persistent flags
+func kubectl(cmd *cobra.Command) k8s.Kubectl {
+	path, _ := cmd.Flags().GetString("kubectl")
+	namespace, _ := cmd.Flags().GetString("namespace")
+	kubeContext, _ := cmd.Flags().GetString("context")
+	return k8s.Kubectl{Path: path, Namespace: namespace, Context: kubeContext}
+}
+
+// newK8sJobsCommand creates the command emitting or applying the worker Jobs
+func newK8sJobsCommand(AppVersion string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "jobs -- <scan args>",
+		Short: "Emit or apply one Job per partition of a distributed scan",
+		Long:  "Build a batch/v1 Job for each of n partitions. Each worker runs terrasync scan --partition i/n --id <name>-p<i> with the given scan arguments, reading config.yaml from a Secret and writing its job directory to the jobs volume. The Jobs are printed as JSON for kubectl apply -f, or applied directly with --apply.",
+		Example: `  Scan a share mounted from a PVC with four workers:
+    terrasync k8s jobs --name nightly --shards 4 --config-secret terrasync-config \
+      --jobs-claim terrasync-jobs --mount share:/mnt/share:ro --apply -- --html /mnt/share
+
+  Write the Jobs to a file to review them first:
+    terrasync k8s jobs --name nightly --shards 4 --config-secret terrasync-config -- /mnt/share > jobs.json`,
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config := k8s.JobConfig{Args: args}
+			config.Name, _ = cmd.Flags().GetString("name")
+			config.Namespace, _ = cmd.Flags().GetString("namespace")
+			config.Image, _ = cmd.Flags().GetString("image")
+			if config.Image == "" {
+				config.Image = "terrasync:" + AppVersion
+			}
+			config.InstallDir, _ = cmd.Flags().GetString("install-dir")
+			config.ConfigSecret, _ = cmd.Flags().GetString("config-secret")
+			config.JobsClaim, _ = cmd.Flags().GetString("jobs-claim")
+			config.Shards, _ = cmd.Flags().GetInt("shards")
+			config.ServiceAccount, _ = cmd.Flags().GetString("service-account")
+			config.BackoffLimit, _ = cmd.Flags().GetInt("backoff-limit")
+			mounts, _ := cmd.Flags().GetStringArray("mount")
+			for _, s := range mounts {
+				m, err := k8s.ParseMount(s)
+				if err != nil {
+					return err
+				}
+				config.Mounts = append(config.Mounts, m)
+			}
+
+			jobs, err := k8s.BuildJobs(config)
+			if err != nil {
+				return err
+			}
+			if apply, _ := cmd.Flags().GetBool("apply"); !apply {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(jobs)
+			}
+			out, err := kubectl(cmd).Apply(cmd.Context(), jobs)
+			fmt.Print(out)
+			if err != nil {
+				return err
+			}
+			fmt.Print(i18n.Sprintf("Track the workers with: terrasync k8s status %s\n", config.Name))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("name", "", "", "Name of the run, the shards scan into the jobs <name>-p1 to <name>-p<n>")
+	cmd.Flags().IntP("shards", "", 1, "Number of partitions, one Job each")
+	cmd.Flags().StringP("image", "", "", "Image containing terrasync (default: terrasync:<version>)")
+	cmd.Flags().StringP("install-dir", "", "/opt/terrasync", "Directory of terrasync in the image")
+	cmd.Flags().StringP("config-secret", "", "", "Secret holding config.yaml")
+	cmd.Flags().StringP("jobs-claim", "", "", "PVC mounted as the jobs directory, so the partitions can be merged (default: emptyDir)")
+	cmd.Flags().StringArrayP("mount", "", nil, "PVC mounted into every worker as claim:/path[:ro], can be repeated")
+	cmd.Flags().StringP("service-account", "", "", "Service account of the worker pods")
+	cmd.Flags().IntP("backoff-limit", "", 0, "Times a failed worker pod is retried")
+	cmd.Flags().BoolP("apply", "", false, "Apply the Jobs with kubectl instead of printing them")
+
+	return cmd
+}
+
+// newK8sStatusCommand creates the command tracking the worker Jobs of a run
+func newK8sStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status <name>",
+		Short: "Print the state of the worker Jobs of a run",
+		Long:  "Query the Jobs of a run and print the state of each shard. With --watch the state is printed again until all shards succeeded or one failed. The command fails when a shard failed.",
+		Example: `  Wait for the workers of the nightly run:
+    terrasync k8s status nightly --watch`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			watch, _ := cmd.Flags().GetBool("watch")
+			intervalFlag, _ := cmd.Flags().GetString("interval")
+			interval, err := units.ParseDuration(intervalFlag)
+			if err != nil {
+				return fmt.Errorf("invalid interval: %w", err)
+			}
+			client := kubectl(cmd)
+
+			for {
+				status, err := client.Status(cmd.Context(), name)
+				if err != nil {
+					return err
+				}
+				printRunStatus(status)
+
+				switch state := status.State(); {
+				case state == k8s.StateFailed:
+					return fmt.Errorf("run %s failed", name)
+				case state == k8s.StateSucceeded:
+					ids := make([]string, 0, len(status.Jobs))
+					for _, job := range status.Jobs {
+						ids = append(ids, k8s.ShardID(name, job.Shard))
+					}
+					fmt.Print(i18n.Sprintf("All shards succeeded, merge them with: terrasync report merge --jobs %s --id %s\n", strings.Join(ids, ","), name))
+					return nil
+				case !watch:
+					return nil
+				}
+
+				select {
+				case <-cmd.Context().Done():
+					return cmd.Context().Err()
+				case <-time.After(interval):
+				}
+			}
+		},
+	}
+
+	cmd.Flags().BoolP("watch", "w", false, "Keep printing the state until the run succeeded or failed")
+	cmd.Flags().StringP("interval", "", "10s", "Interval between queries with --watch")
+
+	return cmd
+}
+
+// printRunStatus prints one line per shard of a run
+func printRunStatus(status k8s.RunStatus) {
+	fmt.Print(i18n.Sprintf("Run %s: %s, %d shards\n", status.Name, i18n.T(status.State()), status.Shards))
+	for _, job := range status.Jobs {
+		elapsed := ""
+		if !job.Start.IsZero() {
+			end := job.Completion
+			if end.IsZero() {
+				end = time.Now()
+			}
+			elapsed = end.Sub(job.Start).Round(time.Second).String()
+		}
+		fmt.Printf("  %-4d %-30s %s %8s", job.Shard, job.Job, i18n.Pad(i18n.T(job.State), 10), elapsed)
+		if job.Failed > 0 {
+			fmt.Print(i18n.Sprintf(" (%d failed pods)", job.Failed))
+		}
+		if job.Message != "" {
+			fmt.Printf(" %s", job.Message)
+		}
+		fmt.Println()
+	}
+	for _, shard := range status.Missing {
+		fmt.Print(i18n.Sprintf("  %-4d no Job found\n", shard))
+	}
+}
@@ -1,8 +1,20 @@
 package command
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"terrasync/app/migrate"
+	"terrasync/app/plan"
+	"terrasync/app/scan"
+	"terrasync/i18n"
+	"terrasync/log"
 	"terrasync/object"
+	"terrasync/pkg/units"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -14,40 +26,520 @@ func NewMigrateCommand(AppVersion string) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "migrate <source> <destination>",
 		Short: "Migrate data from source to destination",
-		Long:  "Migrate data from source to destination, supporting multiple storage types, such as CIFS, NFS, S3.",
+		Long:  "Migrate data from source to destination: local paths (including mounted NFS and SMB shares), mem:// and tar streams (-). The nfs://, smb:// and s3:// backends are not implemented yet and are refused.",
 		Args:  cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			src := args[0]
 			dst := args[1]
+			// 未实现的存储类型会静默丢弃写入，在创建任务目录前拒绝
+			for _, uri := range args {
+				if err := object.CheckImplemented(uri); err != nil {
+					return err
+				}
+			}
+
+			goexeDir, err := loadConfig()
+			if err != nil {
+				return err
+			}
 
 			// 从Viper获取配置，命令行参数优先级更高
-			overwrite := viper.GetBool("migrate.overwrite")
-			threads := viper.GetInt("migrate.concurrency")
+			for key, flag := range map[string]string{
+				"migrate.overwrite":            "overwrite",
+				"migrate.metadata_only":        "metadata-only",
+				"migrate.preserve":             "preserve",
+				"migrate.concurrency":          "concurrency",
+				"migrate.list_concurrency":     "list-concurrency",
+				"migrate.copy_concurrency":     "copy-concurrency",
+				"migrate.large_file_streams":   "large-file-streams",
+				"migrate.large_file_threshold": "large-file-threshold",
+				"migrate.dest_template":        "dest-template",
+				"migrate.dest_prefix":          "dest-prefix",
+				"migrate.strip_components":     "strip-components",
+				"migrate.flatten":              "flatten",
+				"migrate.flatten_collision":    "flatten-collision",
+				"migrate.preflight":            "preflight",
+				"migrate.capacity_headroom":    "capacity-headroom",
+				"migrate.file_timeout":         "file-timeout",
+				"migrate.stall_timeout":        "stall-timeout",
+				"migrate.transfer_retries":     "transfer-retries",
+				"migrate.changed_retries":      "changed-retries",
+				"migrate.max_file_size":        "max-file-size",
+				"migrate.max_depth":            "max-depth",
+				"migrate.tag_temperature":      "tag-temperature",
+				"migrate.temperature_basis":    "temperature-basis",
+				"migrate.warm_after":           "warm-after",
+				"migrate.cold_after":           "cold-after",
+				"migrate.propagate_deletes":    "propagate-deletes",
+				"migrate.interlock_threshold":  "interlock-threshold",
+				"migrate.duplicate_run":        "duplicate-run",
+				"migrate.duplicate_window":     "duplicate-window",
+				"migrate.reconcile":            "reconcile",
+				"migrate.prewarm":              "prewarm",
+				"migrate.stub_policy":          "stub-policy",
+				"migrate.acceptance_sample":    "acceptance-sample",
+				"migrate.acceptance_checksum":  "acceptance-checksum",
+			} {
+				if err := viper.BindPFlag(key, cmd.Flags().Lookup(flag)); err != nil {
+					return fmt.Errorf("failed to bind flag %s: %w", flag, err)
+				}
+			}
+
+			// Rewrite rules may contain commas, so the flag is not bound through viper
+			rewrite := viper.GetStringSlice("migrate.rewrite")
+			if cmd.Flags().Changed("rewrite") {
+				rewrite, _ = cmd.Flags().GetStringArray("rewrite")
+			}
+			rewriteReport, _ := cmd.Flags().GetString("rewrite-report")
+			if len(rewrite) > 0 && rewriteReport == "" {
+				rewriteReport = filepath.Join(goexeDir, fmt.Sprintf("rewrite_%s.csv", time.Now().Format("2006-01-02_15.04.05")))
+			}
+
+			failureLedger, _ := cmd.Flags().GetString("failures")
+			if failureLedger == "" {
+				failureLedger = filepath.Join(goexeDir, fmt.Sprintf("failures_%s.csv", time.Now().Format("2006-01-02_15.04.05")))
+			}
+
+			collision, err := migrate.ParseCollisionPolicy(viper.GetString("migrate.flatten_collision"))
+			if err != nil {
+				return err
+			}
+
+			preflight, err := migrate.ParsePreflightAction(viper.GetString("migrate.preflight"))
+			if err != nil {
+				return err
+			}
+
+			temperatureBasis, err := migrate.ParseTemperatureBasis(viper.GetString("migrate.temperature_basis"))
+			if err != nil {
+				return err
+			}
+
+			largeFileThreshold, err := units.ParseSize(viper.GetString("migrate.large_file_threshold"))
+			if err != nil {
+				return fmt.Errorf("invalid large file threshold: %w", err)
+			}
+
+			maxFileSize, err := units.ParseSize(viper.GetString("migrate.max_file_size"))
+			if err != nil {
+				return fmt.Errorf("invalid max file size: %w", err)
+			}
+
+			fileTimeout, err := configDuration("migrate.file_timeout")
+			if err != nil {
+				return err
+			}
+			stallTimeout, err := configDuration("migrate.stall_timeout")
+			if err != nil {
+				return err
+			}
+			warmAfter, err := configDuration("migrate.warm_after")
+			if err != nil {
+				return err
+			}
+			coldAfter, err := configDuration("migrate.cold_after")
+			if err != nil {
+				return err
+			}
+			duplicateRun, err := migrate.ParseDuplicateRunAction(viper.GetString("migrate.duplicate_run"))
+			if err != nil {
+				return err
+			}
+			duplicateWindow, err := configDuration("migrate.duplicate_window")
+			if err != nil {
+				return err
+			}
+			jobID, _ := cmd.Flags().GetString("id")
+			resumeID, _ := cmd.Flags().GetString("resume")
+			if resumeID != "" {
+				if jobID != "" && jobID != resumeID {
+					return fmt.Errorf("--resume %s conflicts with --id %s", resumeID, jobID)
+				}
+				jobID = resumeID
+			}
+			reconcile, err := migrate.ParseReconcileAction(viper.GetString("migrate.reconcile"))
+			if err != nil {
+				return err
+			}
+			reconcileReport, _ := cmd.Flags().GetString("reconcile-report")
+			if reconcile != migrate.ReconcileOff && reconcileReport == "" {
+				reconcileReport = filepath.Join(goexeDir, fmt.Sprintf("reconcile_%s.csv", time.Now().Format("2006-01-02_15.04.05")))
+			}
+
+			preserve, err := migrate.ParsePreserve(viper.GetString("migrate.preserve"))
+			if err != nil {
+				return err
+			}
+
+			stubPolicy, err := migrate.ParseStubPolicy(viper.GetString("migrate.stub_policy"))
+			if err != nil {
+				return err
+			}
+			stubReport, _ := cmd.Flags().GetString("stub-report")
+			if viper.GetBool("migrate.prewarm") && stubReport == "" {
+				stubReport = filepath.Join(goexeDir, fmt.Sprintf("stubs_%s.csv", time.Now().Format("2006-01-02_15.04.05")))
+			}
+
+			planJob, _ := cmd.Flags().GetString("plan")
+			wave, _ := cmd.Flags().GetInt("wave")
+			waveManifest, planWave, err := planWaveManifest(goexeDir, planJob, wave, src)
+			if err != nil {
+				return err
+			}
+			// 每个迁移波次都生成验收清单
+			acceptance, _ := cmd.Flags().GetBool("acceptance")
+			acceptance = acceptance || planWave != nil
+
+			matchExpr, _ := cmd.Flags().GetString("match")
+			excludeExpr, _ := cmd.Flags().GetString("exclude")
+
+			migrateConfig := migrate.MigrateConfig{
+				Source:             src,
+				Destination:        dst,
+				Match:              scan.ParseConditions(matchExpr),
+				Exclude:            scan.ParseConditions(excludeExpr),
+				Overwrite:          viper.GetBool("migrate.overwrite"),
+				MetadataOnly:       viper.GetBool("migrate.metadata_only"),
+				Preserve:           preserve,
+				ListConcurrency:    viper.GetInt("migrate.list_concurrency"),
+				CopyConcurrency:    viper.GetInt("migrate.copy_concurrency"),
+				LargeFileStreams:   viper.GetInt("migrate.large_file_streams"),
+				LargeFileThreshold: largeFileThreshold,
+				DestTemplate:       viper.GetString("migrate.dest_template"),
+				Rewrite:            rewrite,
+				RewriteReport:      rewriteReport,
+				KeyTransform: migrate.KeyTransform{
+					DestPrefix:      viper.GetString("migrate.dest_prefix"),
+					StripComponents: viper.GetInt("migrate.strip_components"),
+					Flatten:         viper.GetBool("migrate.flatten"),
+					Collision:       collision,
+				},
+				Preflight:        preflight,
+				CapacityHeadroom: viper.GetInt("migrate.capacity_headroom"),
+				FileTimeout:      fileTimeout,
+				StallTimeout:     stallTimeout,
+				TransferRetries:  viper.GetInt("migrate.transfer_retries"),
+				FailureLedger:    failureLedger,
+				ChangedRetries:   viper.GetInt("migrate.changed_retries"),
+				MaxFileSize:      maxFileSize,
+				MaxDepth:         viper.GetInt("migrate.max_depth"),
+				TagTemperature:   viper.GetBool("migrate.tag_temperature"),
+				TemperatureBasis: temperatureBasis,
+				WarmAfter:        warmAfter,
+				ColdAfter:        coldAfter,
+
+				PropagateDeletes:   viper.GetBool("migrate.propagate_deletes"),
+				InterlockThreshold: viper.GetInt64("migrate.interlock_threshold"),
+
+				JobID:           jobID,
+				DuplicateRun:    duplicateRun,
+				DuplicateWindow: duplicateWindow,
+				Resume:          resumeID != "",
+
+				Reconcile:       reconcile,
+				ReconcileReport: reconcileReport,
 
-			fmt.Print(overwrite)
-			fmt.Print(threads)
+				Prewarm:    viper.GetBool("migrate.prewarm"),
+				StubReport: stubReport,
+				StubPolicy: stubPolicy,
+
+				WaveManifest: waveManifest,
+
+				CmdLine:   buildCommandLine(cmd, args),
+				StartTime: time.Now(),
+			}
+			migrateConfig.Force, _ = cmd.Flags().GetBool("force")
+			if acceptance {
+				migrateConfig.AcceptanceSample = viper.GetInt("migrate.acceptance_sample")
+			}
+			// --concurrency is kept for compatibility and applies to small file copies
+			if migrateConfig.CopyConcurrency <= 0 {
+				migrateConfig.CopyConcurrency = viper.GetInt("migrate.concurrency")
+			}
+			migrateConfig.ApplyDefaults()
+			if migrateConfig.Checkpoint, err = migrationCheckpoint(goexeDir, &migrateConfig); err != nil {
+				return err
+			}
+			if err := migrateConfig.Validate(); err != nil {
+				return err
+			}
+			if migrateConfig.Progress, err = progressConfig(cmd, "migrate", migrateConfig.JobID); err != nil {
+				return err
+			}
+			// 每个拷贝同时打开源文件和目标文件
+			warnFDBudget(migrateConfig.ListConcurrency + 2*(migrateConfig.CopyConcurrency+migrateConfig.LargeFileStreams))
+			log.Infof("Migrate %s to %s with %s", src, dst, migrateConfig.String())
 
 			srcStorage, err := object.CreateStorage(src)
 			if err != nil {
 				return fmt.Errorf("failed to create source storage: %w", err)
 			}
 			defer srcStorage.Close()
+			if readOnly, _ := cmd.Flags().GetBool("assert-readonly"); readOnly {
+				srcStorage = object.ReadOnly(srcStorage)
+				log.Infof("Source storage %s opened read-only", src)
+			}
 
 			dstStorage, err := object.CreateStorage(dst)
 			if err != nil {
 				return fmt.Errorf("failed to create destination storage: %w", err)
 			}
 			defer dstStorage.Close()
+			if _, ok := dstStorage.(object.Tagger); migrateConfig.TagTemperature && !ok {
+				return fmt.Errorf("temperature tags require a destination supporting object tags: %s", dst)
+			}
 
-			// TODO: Implement actual data migration logic
+			report, err := migrate.Preflight(cmd.Context(), &migrateConfig, srcStorage, dstStorage)
+			if migrateConfig.Preflight != migrate.PreflightOff {
+				// stdout may carry a tar stream, so the report goes to stderr
+				fmt.Fprint(os.Stderr, i18n.Sprintf("Capacity preflight: %s\n", report))
+			}
+			if err != nil {
+				return err
+			}
+			// 进度按预检统计的源端大小估算剩余时间
+			migrateConfig.Progress.TotalEntries, migrateConfig.Progress.TotalBytes = report.RequiredEntries, report.Required
 
+			interlock, err := migrate.CheckInterlock(cmd.Context(), &migrateConfig, dstStorage, confirmInterlock(dst), buildCommandLine(cmd, args))
+			if interlock.Decision != migrate.InterlockNotRequired {
+				log.Infof("Safety interlock: %s", interlock)
+			}
+			if err != nil {
+				return err
+			}
+
+			if _, err := migrate.ClaimDestination(cmd.Context(), &migrateConfig, dstStorage, time.Now()); err != nil {
+				return err
+			}
+
+			if err := prewarmSource(cmd.Context(), &migrateConfig, srcStorage); err != nil {
+				return err
+			}
+
+			result, err := migrate.Migrate(cmd.Context(), &migrateConfig, srcStorage, dstStorage)
+			migrate.PrintReport(&migrateConfig, result, err)
+			if err != nil {
+				return fmt.Errorf("failed to migrate job %s, continue it with --resume %s: %w", migrateConfig.JobID, migrateConfig.JobID, err)
+			}
+
+			err = reconcileMigration(cmd.Context(), &migrateConfig, srcStorage, dstStorage)
+			logStatCache()
+			if err != nil {
+				return err
+			}
+			if acceptance {
+				if err := writeAcceptance(cmd.Context(), goexeDir, &migrateConfig, result, planWave, srcStorage, dstStorage); err != nil {
+					return err
+				}
+			}
+			if result.Stats.Errors > 0 {
+				return fmt.Errorf("%d entries failed to migrate, see %s, retry them with --resume %s", result.Stats.Errors, migrateConfig.FailureLedger, migrateConfig.JobID)
+			}
 			return nil
 		},
 	}
 
 	// Add command line flags
 	cmd.Flags().BoolP("overwrite", "", false, "Overwrite the existing files in destination storage")
-	cmd.Flags().IntP("concurrency", "", 5, "Concurrency threads for migration")
+	cmd.Flags().BoolP("metadata-only", "", false, "Only re-apply owner, permissions, ACLs and times to already copied files")
+	cmd.Flags().StringP("preserve", "", "all", "Metadata restored on the copied files and directories: a list of times, perms (with ACLs and attributes) and owner (as root), all or none")
+	cmd.Flags().StringP("match", "m", "", "Migrate only entries matching the given expression")
+	cmd.Flags().StringP("exclude", "e", "", "Exclude entries using the given expression")
+	cmd.Flags().BoolP("assert-readonly", "", false, "Refuse any write or delete on the source storage")
+	cmd.Flags().IntP("concurrency", "", 5, "Concurrency threads for migration (deprecated, use --copy-concurrency)")
+	cmd.Flags().IntP("list-concurrency", "", 0, "Concurrency threads for listing and stat of source files")
+	cmd.Flags().IntP("copy-concurrency", "", 0, "Concurrency threads for copying small files")
+	cmd.Flags().IntP("large-file-streams", "", 0, "Parallel streams used to copy a single large file")
+	cmd.Flags().StringP("large-file-threshold", "", "64M", "Files larger than this size are copied with parallel streams")
+	cmd.Flags().StringArrayP("rewrite", "", nil, "Rewrite destination paths with 'regex=>replacement', can be repeated")
+	cmd.Flags().StringP("dest-template", "", "", "Destination key template evaluated per file, e.g. '{{.Ext}}/{{.MTime.Year}}/{{.Key}}'")
+	cmd.Flags().StringP("dest-prefix", "", "", "Prefix added to every destination key")
+	cmd.Flags().IntP("strip-components", "", 0, "Strip N leading path components from source keys")
+	cmd.Flags().BoolP("flatten", "", false, "Copy all files into a single destination directory")
+	cmd.Flags().StringP("flatten-collision", "", "rename", "Policy for duplicate names when flattening (rename, skip, overwrite, fail)")
+	cmd.Flags().StringP("preflight", "", "abort", "Action when the destination lacks capacity for the source (abort, warn, off)")
+	cmd.Flags().IntP("capacity-headroom", "", 5, "Percentage of the destination capacity kept free by the preflight check")
+	cmd.Flags().StringP("file-timeout", "", "0", "Cancel and requeue a single file transfer running longer than this, e.g. 2h, 0 disables the limit")
+	cmd.Flags().StringP("stall-timeout", "", "5m", "Cancel and requeue a file transfer moving no data for this long")
+	cmd.Flags().IntP("transfer-retries", "", 2, "Times a timed out or stalled transfer is requeued before it is recorded as failed")
+	cmd.Flags().IntP("changed-retries", "", 2, "Times a file whose size or mtime changed during the copy is copied again before it is flagged as unstable")
+	cmd.Flags().StringP("max-file-size", "", "0", "Skip and report files larger than this size, e.g. 50G, 0 disables the limit")
+	cmd.Flags().IntP("max-depth", "", 0, "Skip and report entries nested deeper than this many levels, 0 disables the limit")
+	cmd.Flags().BoolP("tag-temperature", "", false, "Tag migrated objects with their temperature (hot/warm/cold) and last access/modification dates")
+	cmd.Flags().StringP("temperature-basis", "", "atime", "Time the temperature is computed from (atime, mtime)")
+	cmd.Flags().StringP("warm-after", "", "30d", "Files unused for this long are tagged warm, e.g. 4w")
+	cmd.Flags().StringP("cold-after", "", "180d", "Files unused for this long are tagged cold")
+	cmd.Flags().BoolP("propagate-deletes", "", false, "Delete destination entries that no longer exist in the source")
+	cmd.Flags().BoolP("force", "", false, "Proceed when overwrite with propagate-deletes may affect more files than the interlock threshold")
+	cmd.Flags().Int64P("interlock-threshold", "", 1000, "Files at risk above which overwrite with propagate-deletes needs --force or a typed confirmation")
+	cmd.Flags().StringP("id", "", "", "Job id written to the destination marker (default: derived from the source and destination)")
+	cmd.Flags().StringP("resume", "", "", "Resume the interrupted migration job with this id, skipping the files it completed and retrying the failed ones")
+	cmd.Flags().StringP("duplicate-run", "", "abort", "Action when another job wrote the destination within the duplicate window (abort, warn, off)")
+	cmd.Flags().StringP("duplicate-window", "", "24h", "Destination markers updated within this time belong to a job that may still be running")
+	cmd.Flags().StringP("reconcile", "", "warn", "Compare file counts and bytes per top-level directory after the migration (warn, fail, off)")
+	cmd.Flags().StringP("reconcile-report", "", "", "CSV file with the reconciled counts (default: reconcile_<time>.csv next to the executable)")
+	cmd.Flags().BoolP("prewarm", "", false, "Read offline stubs of archive-tiered sources before the copy to recall them")
+	cmd.Flags().StringP("stub-policy", "", "recall", "How offline stubs of archive-tiered sources are copied (recall, skip, copy-stub)")
+	cmd.Flags().StringP("stub-report", "", "", "CSV file listing the offline stubs found by --prewarm (default: stubs_<time>.csv next to the executable)")
+	addProgressFlags(cmd)
+	cmd.Flags().StringP("failures", "", "", "CSV file recording the files that failed (default: failures_<time>.csv next to the executable)")
+	cmd.Flags().StringP("plan", "", "", "Scan job id whose plan (see terrasync plan) holds the wave to migrate")
+	cmd.Flags().IntP("wave", "", 0, "Migrate only the entries of this wave of the --plan job")
+	cmd.Flags().BoolP("acceptance", "", false, "Write an acceptance checklist (CSV and HTML) after the migration, always on with --plan")
+	cmd.Flags().IntP("acceptance-sample", "", 100, "Copied files picked at random whose checksums are compared for the acceptance checklist")
+	cmd.Flags().StringP("acceptance-checksum", "", "sha256", "Checksum algorithm comparing the sampled files (md5, sha1, sha256, sha384, sha512)")
+	cmd.Flags().StringP("rewrite-report", "", "", "CSV file recording every rewritten path (default: rewrite_<time>.csv next to the executable)")
+
+	cmd.AddCommand(newQueueCommand())
 
 	return cmd
 }
+
+// migrationCheckpoint returns the path of the database saving the transfer
+// state of the migration job, creating the job directory unless resuming
+func migrationCheckpoint(goexeDir string, config *migrate.MigrateConfig) (string, error) {
+	jobDir := filepath.Join(goexeDir, "jobs", fmt.Sprintf("Job_%s_migrate", config.JobID))
+	if config.Resume {
+		if _, err := os.Stat(jobDir); err != nil {
+			return "", fmt.Errorf("failed to find job %s to resume: %w", config.JobID, err)
+		}
+	} else if err := os.MkdirAll(jobDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create job directory: %w", err)
+	}
+	return filepath.Join(jobDir, "checkpoint.db"), nil
+}
+
+// prewarmSource recalls the offline stubs of the source ahead of the copy and
+// reports them separately
+func prewarmSource(ctx context.Context, config *migrate.MigrateConfig, src object.Storage) error {
+	// tar流只能读取一次
+	if !config.Prewarm || object.StorageType(config.Source) == "stream" {
+		return nil
+	}
+	start := time.Now()
+	result, err := migrate.Prewarm(ctx, config, src)
+	if err != nil {
+		return fmt.Errorf("failed to pre-read source: %w", err)
+	}
+	if config.StubReport != "" {
+		f, err := os.Create(config.StubReport)
+		if err != nil {
+			return fmt.Errorf("failed to create stub report: %w", err)
+		}
+		err = result.Write(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write stub report: %w", err)
+		}
+	}
+
+	// stdout may carry a tar stream, so the result goes to stderr
+	offline := result.Offline()
+	fmt.Fprint(os.Stderr, i18n.Sprintf("Pre-read: %d files, %s; offline stubs: %d, %s; still offline: %d\n",
+		result.Files, scan.FormatFileSize(result.Bytes), len(result.Stubs), scan.FormatFileSize(result.StubBytes()), len(offline)))
+	log.Infof("Pre-read %d files, %d bytes in %v: %d offline stubs, %d bytes, %d still offline",
+		result.Files, result.Bytes, time.Since(start), len(result.Stubs), result.StubBytes(), len(offline))
+	if len(result.Stubs) > 0 && config.StubReport != "" {
+		fmt.Fprint(os.Stderr, i18n.Sprintf("Stub report: %s\n", config.StubReport))
+	}
+	return nil
+}
+
+// reconcileMigration compares the files and bytes of source and destination per
+// top-level directory and reports the discrepancies
+func reconcileMigration(ctx context.Context, config *migrate.MigrateConfig, src, dst object.Storage) error {
+	// tar流只能读取一次
+	if config.Reconcile == migrate.ReconcileOff || object.StorageType(config.Source) == "stream" || object.StorageType(config.Destination) == "stream" {
+		return nil
+	}
+	// 源端还包含其他波次的条目，全部波次完成后再比较
+	if config.WaveManifest != "" {
+		log.Infof("Reconcile skipped for wave %s, the source holds the entries of other waves", config.WaveManifest)
+		return nil
+	}
+	result, err := migrate.Reconcile(ctx, config, src, dst)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile source and destination: %w", err)
+	}
+	if config.ReconcileReport != "" {
+		f, err := os.Create(config.ReconcileReport)
+		if err != nil {
+			return fmt.Errorf("failed to create reconcile report: %w", err)
+		}
+		err = result.Write(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write reconcile report: %w", err)
+		}
+	}
+
+	// stdout may carry a tar stream, so the result goes to stderr
+	total := result.Total
+	fmt.Fprint(os.Stderr, i18n.Sprintf("Reconciliation: source %d files, %s; destination %d files, %s\n",
+		total.SrcFiles, scan.FormatFileSize(total.SrcBytes), total.DstFiles, scan.FormatFileSize(total.DstBytes)))
+	log.Infof("Reconciliation: source %d files, %d bytes; destination %d files, %d bytes", total.SrcFiles, total.SrcBytes, total.DstFiles, total.DstBytes)
+	diff := result.Discrepancies()
+	for _, c := range diff {
+		fmt.Fprint(os.Stderr, i18n.Sprintf("  %s: source %d files, %s; destination %d files, %s\n",
+			c.Dir, c.SrcFiles, scan.FormatFileSize(c.SrcBytes), c.DstFiles, scan.FormatFileSize(c.DstBytes)))
+		log.Warnf("Reconciliation discrepancy in %s: source %d files, %d bytes; destination %d files, %d bytes", c.Dir, c.SrcFiles, c.SrcBytes, c.DstFiles, c.DstBytes)
+	}
+	if config.ReconcileReport != "" {
+		fmt.Fprint(os.Stderr, i18n.Sprintf("Reconcile report: %s\n", config.ReconcileReport))
+	}
+	if len(diff) > 0 && config.Reconcile == migrate.ReconcileFail {
+		return fmt.Errorf("reconciliation found %d discrepancies between source and destination", len(diff))
+	}
+	return nil
+}
+
+// writeAcceptance compares the checksums of the sampled files and writes the
+// acceptance checklist of the migration as CSV and HTML, next to the wave
+// manifest for a wave of a plan and next to the executable otherwise
+func writeAcceptance(ctx context.Context, goexeDir string, config *migrate.MigrateConfig, result *migrate.Result, wave *plan.Wave, src, dst object.Storage) error {
+	checks, err := migrate.CheckSample(ctx, result.Sample, src, dst, viper.GetString("migrate.acceptance_checksum"))
+	if err != nil {
+		return fmt.Errorf("failed to compare checksums of the sampled files: %w", err)
+	}
+	var name string
+	var planned *migrate.PlannedCounts
+	base := filepath.Join(goexeDir, fmt.Sprintf("acceptance_%s", time.Now().Format("2006-01-02_15.04.05")))
+	if wave != nil {
+		name = fmt.Sprintf("%d %s", wave.Number, wave.Name)
+		planned = &migrate.PlannedCounts{Files: wave.Files, Dirs: wave.Dirs, Bytes: wave.Bytes}
+		base = strings.TrimSuffix(config.WaveManifest, filepath.Ext(config.WaveManifest)) + "_acceptance"
+	}
+	checklist, err := migrate.NewChecklist(config, result, name, planned, checks)
+	if err != nil {
+		return fmt.Errorf("failed to build acceptance checklist: %w", err)
+	}
+	for _, out := range []struct {
+		path  string
+		write func(io.Writer) error
+	}{{base + ".csv", checklist.Write}, {base + ".html", checklist.WriteHTML}} {
+		f, err := os.Create(out.path)
+		if err != nil {
+			return fmt.Errorf("failed to create acceptance checklist: %w", err)
+		}
+		err = out.write(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write acceptance checklist: %w", err)
+		}
+	}
+
+	// stdout may carry a tar stream, so the result goes to stderr
+	status := i18n.T("ready")
+	if !checklist.Ready {
+		status = i18n.T("not ready")
+	}
+	fmt.Fprint(os.Stderr, i18n.Sprintf("Acceptance checklist: %s, %s (%s)\n", base+".csv", base+".html", status))
+	log.Infof("Acceptance checklist %s: %d sampled files compared, ready: %v", base+".csv", len(checks), checklist.Ready)
+	return nil
+}
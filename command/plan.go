@@ -0,0 +1,130 @@
+package command
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"terrasync/app/plan"
+	"terrasync/app/scan"
+	"terrasync/i18n"
+	"terrasync/log"
+	"terrasync/pkg/units"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// NewPlanCommand creates the plan command
+func NewPlanCommand(AppVersion string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plan <scan job id>",
+		Short: "Split a scan job into migration waves",
+		Long:  "Split the entries of a scan job into migration waves by filter rules, by top-level (department) directory, size class or age, writing a manifest and a transfer estimate per wave to the plan directory of the job. Each wave is then migrated with migrate --plan <scan job id> --wave N.",
+		Example: `  One wave per department directory, smallest first:
+    terrasync plan 20240101120000 --by dir
+
+  A pilot wave, then the rest by age:
+    terrasync plan 20240101120000 --wave "pilot=path like /finance/%" --by age
+
+  Migrate the first wave:
+    terrasync migrate --plan 20240101120000 --wave 1 /mnt/src /mnt/dst`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			goexeDir, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			jobDir, err := scanJobDir(goexeDir, args[0])
+			if err != nil {
+				return err
+			}
+			throughputFlag, _ := cmd.Flags().GetString("throughput")
+			throughput, err := units.ParseSize(throughputFlag)
+			if err != nil {
+				return fmt.Errorf("invalid throughput: %w", err)
+			}
+			rules, _ := cmd.Flags().GetStringArray("wave")
+			by, _ := cmd.Flags().GetString("by")
+			filesPerSec, _ := cmd.Flags().GetInt64("files-per-sec")
+
+			p, err := plan.Create(cmd.Context(), plan.Config{
+				JobDir:      jobDir,
+				Rules:       rules,
+				By:          by,
+				Throughput:  throughput,
+				FilesPerSec: filesPerSec,
+			})
+			if err != nil {
+				return err
+			}
+			printPlan(p, filepath.Join(jobDir, plan.DirName))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayP("wave", "", nil, "Wave rule 'name=filter' or 'filter', e.g. \"pilot=path like /hr/%\"; repeatable, an entry belongs to the first wave it matches")
+	cmd.Flags().StringP("by", "", "", "Add waves after the rules: dir (one per top-level directory, smallest first), size (large, medium, small) or age (cold, warm, hot)")
+	cmd.Flags().StringP("throughput", "", "100M", "Bytes per second used to estimate the transfer time of a wave")
+	cmd.Flags().Int64P("files-per-sec", "", plan.DefaultFilesPerSec, "Entries created per second used to estimate the transfer time of a wave")
+
+	return cmd
+}
+
+// planWaveManifest returns the manifest and the wave n of the plan of a scan
+// job, empty without a plan
+func planWaveManifest(goexeDir, jobID string, n int, src string) (string, *plan.Wave, error) {
+	if jobID == "" {
+		if n != 0 {
+			return "", nil, fmt.Errorf("--wave requires --plan <scan job id>")
+		}
+		return "", nil, nil
+	}
+	if n <= 0 {
+		return "", nil, fmt.Errorf("--plan requires --wave N")
+	}
+	jobDir, err := scanJobDir(goexeDir, jobID)
+	if err != nil {
+		return "", nil, err
+	}
+	p, err := plan.Load(jobDir)
+	if err != nil {
+		return "", nil, err
+	}
+	w, err := p.Wave(n)
+	if err != nil {
+		return "", nil, err
+	}
+	manifest := plan.ManifestPath(jobDir, w)
+	if manifest == "" {
+		return "", nil, fmt.Errorf("wave %d (%s) of job %s has no entries", w.Number, w.Name, p.JobID)
+	}
+	if filepath.Clean(p.Source) != filepath.Clean(src) {
+		log.Warnf("Wave %d of job %s was planned for source %s, migrating from %s", w.Number, p.JobID, p.Source, src)
+	}
+	log.Infof("Migrate wave %d (%s) of job %s: %d files, %d dirs, %d bytes, rule: %s", w.Number, w.Name, p.JobID, w.Files, w.Dirs, w.Bytes, w.Rule)
+	return manifest, w, nil
+}
+
+// printPlan prints one line per wave
+func printPlan(p *plan.Plan, dir string) {
+	fmt.Print(i18n.Sprintf("Plan of job %s (%s): %d waves\n", p.JobID, p.Source, len(p.Waves)))
+	fmt.Printf("  %-4s %s %s %s %s %s  %s\n", "#", i18n.Pad(i18n.T("Wave"), 16), padLeft(i18n.T("Files"), 10),
+		padLeft(i18n.T("Directories"), 8), padLeft(i18n.T("Size"), 10), padLeft(i18n.T("Estimate"), 10), i18n.T("Rule"))
+	var total time.Duration
+	for _, w := range p.Waves {
+		total += w.Estimate()
+		fmt.Printf("  %-4d %s %10d %8d %10s %10s  %s\n", w.Number, i18n.Pad(w.Name, 16), w.Files, w.Dirs,
+			scan.FormatFileSize(w.Bytes), w.Estimate().String(), w.Rule)
+	}
+	fmt.Print(i18n.Sprintf("Estimated total: %s\n", total))
+	fmt.Print(i18n.Sprintf("Plan: %s\n", dir))
+}
+
+// padLeft right-aligns s in width columns
+func padLeft(s string, width int) string {
+	if n := width - i18n.Width(s); n > 0 {
+		return strings.Repeat(" ", n) + s
+	}
+	return s
+}
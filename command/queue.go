@@ -0,0 +1,144 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"terrasync/app/migrate"
+	"terrasync/app/scan"
+	"terrasync/i18n"
+	"terrasync/object"
+
+	"github.com/spf13/cobra"
+)
+
+// newQueueCommand creates the commands managing the work queue of a migration
+// distributed over several worker hosts
+func newQueueCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "queue",
+		Short: "Manage the work queue of a migration distributed over several hosts",
+		Long:  "A distributed migration shares a work queue database, e.g. on a share mounted by all worker hosts. The coordinator adds the top-level entries of the source as work items, each worker claims one item at a time with a lease, items of a worker that stops are handed off once the lease expires, and the failures of all workers are collected in the queue.",
+	}
+	cmd.PersistentFlags().StringP("queue", "", "", "Work queue database shared by the workers")
+	_ = cmd.MarkPersistentFlagRequired("queue")
+
+	cmd.AddCommand(newQueueAddCommand(), newQueueStatusCommand(), newQueueFailuresCommand())
+	return cmd
+}
+
+// openQueue opens the work queue given with --queue
+func openQueue(cmd *cobra.Command) (*migrate.WorkQueue, error) {
+	path, _ := cmd.Flags().GetString("queue")
+	return migrate.OpenWorkQueue(cmd.Context(), path)
+}
+
+// newQueueAddCommand creates the command adding the top-level entries of the source to the queue
+func newQueueAddCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add <source>",
+		Short: "Add the top-level entries of the source as work items",
+		Example: `  Queue the top-level directories of the share:
+    terrasync migrate queue add --queue /mnt/shared/nightly.queue /mnt/share`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			q, err := openQueue(cmd)
+			if err != nil {
+				return err
+			}
+			defer q.Close()
+
+			src, err := object.CreateStorage(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to create source storage: %w", err)
+			}
+			defer src.Close()
+
+			var keys []string
+			for fileInfo := range scan.ListAll(cmd.Context(), src, scan.ListOptions{Concurrency: 1, Depth: 1}) {
+				keys = append(keys, fileInfo.Key())
+			}
+			if err := cmd.Context().Err(); err != nil {
+				return err
+			}
+			added, err := q.Enqueue(cmd.Context(), keys)
+			if err != nil {
+				return err
+			}
+			fmt.Print(i18n.Sprintf("Added %d work items, %d already queued\n", added, len(keys)-added))
+			return nil
+		},
+	}
+	return cmd
+}
+
+// newQueueStatusCommand creates the command printing the progress of all workers
+func newQueueStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "status",
+		Short:        "Print the progress of the work items and workers",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			q, err := openQueue(cmd)
+			if err != nil {
+				return err
+			}
+			defer q.Close()
+
+			status, err := q.Status(cmd.Context())
+			if err != nil {
+				return err
+			}
+			fmt.Print(i18n.Sprintf("Items: %d pending, %d claimed (%d lease expired), %d done, %d failed\n",
+				status.Pending, status.Claimed, status.Expired, status.Done, status.Failed))
+			fmt.Print(i18n.Sprintf("Migrated: %d files, %s, %d failed files\n", status.Files, scan.FormatFileSize(status.Bytes), status.Failures))
+			for _, w := range status.Workers {
+				fmt.Print(i18n.Sprintf("  %s: %d claimed, %d done, %d files, %s\n", w.Name, w.Claimed, w.Done, w.Files, scan.FormatFileSize(w.Bytes)))
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+// newQueueFailuresCommand creates the command exporting the failures of all workers
+func newQueueFailuresCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "failures",
+		Short: "Export the failed files of all workers as CSV",
+		Example: `  Write the failures of all workers to failures.csv:
+    terrasync migrate queue failures --queue /mnt/shared/nightly.queue --csv failures.csv`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			q, err := openQueue(cmd)
+			if err != nil {
+				return err
+			}
+			defer q.Close()
+
+			out := os.Stdout
+			if path, _ := cmd.Flags().GetString("csv"); path != "" {
+				if out, err = os.Create(path); err != nil {
+					return fmt.Errorf("failed to create %s: %w", path, err)
+				}
+				defer out.Close()
+			}
+			if err := q.WriteFailures(cmd.Context(), out); err != nil {
+				return err
+			}
+
+			failed, err := q.FailedItems(cmd.Context())
+			if err != nil {
+				return err
+			}
+			for key, msg := range failed {
+				fmt.Fprint(os.Stderr, i18n.Sprintf("Work item %s failed on every attempt: %s\n", key, msg))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringP("csv", "", "", "Write the failures to this file instead of stdout")
+	return cmd
+}
@@ -0,0 +1,443 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"terrasync/app/scan"
+	"terrasync/i18n"
+	"terrasync/pkg/units"
+	"terrasync/security"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// NewReportCommand creates the command regenerating the reports of a finished scan
+func NewReportCommand(AppVersion string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report <jobID>",
+		Short: "Regenerate the reports of a finished scan job",
+		Long:  "Rebuild the console summary and produce CSV or HTML reports of a finished full scan from its saved summary and database, without scanning again.",
+		Example: `  Print the summary of a job again:
+    terrasync report Job_2025-01-02_10.00.00.000000_scan
+
+  Produce HTML and CSV reports for a job started with --id nightly:
+    terrasync report nightly --html --csv`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			goexeDir, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			jobDir, err := scanJobDir(goexeDir, args[0])
+			if err != nil {
+				return err
+			}
+
+			csvReport, _ := cmd.Flags().GetBool("csv")
+			htmlReport, _ := cmd.Flags().GetBool("html")
+			jsonReport, _ := cmd.Flags().GetBool("json")
+			quiet, _ := cmd.Flags().GetBool("quiet")
+			signKey, err := loadSigningKey(cmd)
+			if err != nil {
+				return err
+			}
+			delimiter, err := csvDelimiter()
+			if err != nil {
+				return err
+			}
+
+			result, err := scan.Regenerate(cmd.Context(), scan.RegenerateConfig{
+				JobDir:       jobDir,
+				LogPath:      filepath.Join(goexeDir, "terrasync.log"),
+				CSV:          csvReport,
+				HTML:         htmlReport,
+				JSON:         jsonReport,
+				Quiet:        quiet,
+				SignKey:      signKey,
+				CSVDelimiter: delimiter,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to regenerate reports: %w", err)
+			}
+			if quiet {
+				for _, path := range []string{result.CsvPath, result.HtmlPath, result.JSONPath, result.Manifest} {
+					if path != "" {
+						fmt.Println(path)
+					}
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolP("csv", "", false, "Regenerate the CSV report from the job database")
+	cmd.Flags().BoolP("html", "", false, "Generate the HTML report")
+	cmd.Flags().BoolP("json", "", false, "Regenerate the NDJSON report from the job database")
+	cmd.Flags().BoolP("quiet", "q", false, "Only print the paths of the generated reports")
+	cmd.Flags().StringP("sign-key", "", "", "Sign a checksum manifest of the reports with this operator key (see report keygen)")
+
+	cmd.AddCommand(newRollupCommand(), newDuplicatesCommand(), newEmptyCommand(), newMergeCommand(AppVersion), newKeygenCommand(), newVerifyReportCommand())
+
+	return cmd
+}
+
+// newRollupCommand creates the command combining several scan jobs into one summary
+func newRollupCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rollup",
+		Short: "Combine several scan jobs into one project-level summary",
+		Long:  "Aggregate the saved summaries and databases of several full scans, typically one per share, into total files and bytes, a per-share table and combined file size and age histograms.",
+		Example: `  Roll up the nightly scans of three shares:
+    terrasync report rollup --jobs share1,share2,share3
+
+  Also write the rollup as CSV and HTML:
+    terrasync report rollup --jobs share1,share2 --csv rollup.csv --html rollup.html`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			goexeDir, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			jobs, _ := cmd.Flags().GetStringSlice("jobs")
+			if len(jobs) == 0 {
+				return fmt.Errorf("no jobs given, use --jobs job1,job2,...")
+			}
+			jobDirs := make([]string, 0, len(jobs))
+			for _, job := range jobs {
+				jobDir, err := scanJobDir(goexeDir, strings.TrimSpace(job))
+				if err != nil {
+					return err
+				}
+				jobDirs = append(jobDirs, jobDir)
+			}
+
+			rollup, err := scan.BuildRollup(cmd.Context(), jobDirs)
+			if err != nil {
+				return fmt.Errorf("failed to build rollup: %w", err)
+			}
+			rollup.Print()
+
+			if csvPath, _ := cmd.Flags().GetString("csv"); csvPath != "" {
+				if err := rollup.WriteCSV(csvPath); err != nil {
+					return err
+				}
+				fmt.Printf("%s: %s\n", i18n.T("CSV Report"), csvPath)
+			}
+			if htmlPath, _ := cmd.Flags().GetString("html"); htmlPath != "" {
+				if err := rollup.WriteHTML(htmlPath); err != nil {
+					return err
+				}
+				fmt.Printf("%s: %s\n", i18n.T("HTML Report"), htmlPath)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceP("jobs", "", nil, "Comma separated IDs of the scan jobs to combine")
+	cmd.Flags().StringP("csv", "", "", "Write the per-share table to this CSV file")
+	cmd.Flags().StringP("html", "", "", "Write the rollup to this HTML file")
+
+	return cmd
+}
+
+// newDuplicatesCommand creates the command reporting the identical subtrees of a scan job
+func newDuplicatesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "duplicates <jobID>",
+		Short: "Find the directories with identical subtrees in a scan job",
+		Long:  "Compare the subtrees of the directories saved in a full scan by the names and sizes of their files and subdirectories at every level, and report the groups of identical copies with the space reclaimable by keeping one of each. File contents are not read.",
+		Example: `  List the ten largest duplicated project copies of at least 1 GiB:
+    terrasync report duplicates nightly --min-size 1G --top 10
+
+  Write all the duplicate directories to a CSV file:
+    terrasync report duplicates nightly --top 0 --csv duplicates.csv`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			goexeDir, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			jobDir, err := scanJobDir(goexeDir, args[0])
+			if err != nil {
+				return err
+			}
+
+			minSize, _ := cmd.Flags().GetString("min-size")
+			minBytes, err := units.ParseSize(minSize)
+			if err != nil {
+				return fmt.Errorf("invalid min size: %w", err)
+			}
+			minFiles, _ := cmd.Flags().GetInt64("min-files")
+			top, _ := cmd.Flags().GetInt("top")
+
+			duplicates, err := scan.FindDuplicateDirs(cmd.Context(), jobDir, scan.DuplicateDirOptions{
+				MinBytes: minBytes,
+				MinFiles: minFiles,
+				Top:      top,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to find duplicate directories: %w", err)
+			}
+			duplicates.Print()
+
+			if csvPath, _ := cmd.Flags().GetString("csv"); csvPath != "" {
+				if err := duplicates.WriteCSV(csvPath); err != nil {
+					return err
+				}
+				fmt.Printf("%s: %s\n", i18n.T("CSV Report"), csvPath)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("min-size", "", "1M", "Don't report duplicate directories smaller than this size")
+	cmd.Flags().Int64P("min-files", "", 1, "Don't report duplicate directories with fewer files")
+	cmd.Flags().IntP("top", "", 20, "Number of groups listed, by reclaimable space, 0 lists all")
+	cmd.Flags().StringP("csv", "", "", "Write the duplicate directories to this CSV file")
+
+	return cmd
+}
+
+// newEmptyCommand creates the command listing the empty directories and zero-byte files of a scan job
+func newEmptyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "empty <jobID>",
+		Short: "List the empty directories and zero-byte files of a scan job",
+		Long:  "Count the directories saved in a full scan without any entry under them and the zero-byte regular files, print the first of each and export the complete lists to CSV. Directories holding only entries excluded by the scan filters are reported as empty.",
+		Example: `  Print the counts and the first 20 entries of each kind:
+    terrasync report empty nightly
+
+  Export all the empty entries to a CSV file:
+    terrasync report empty nightly --csv empty.csv`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			goexeDir, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			jobDir, err := scanJobDir(goexeDir, args[0])
+			if err != nil {
+				return err
+			}
+
+			top, _ := cmd.Flags().GetInt("top")
+			empty, err := scan.FindEmptyEntries(cmd.Context(), jobDir, top)
+			if err != nil {
+				return fmt.Errorf("failed to find empty entries: %w", err)
+			}
+			empty.Print()
+
+			if csvPath, _ := cmd.Flags().GetString("csv"); csvPath != "" {
+				if err := empty.WriteCSV(csvPath); err != nil {
+					return err
+				}
+				fmt.Printf("%s: %s\n", i18n.T("CSV Report"), csvPath)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntP("top", "", 20, "Number of entries of each kind printed, 0 prints all")
+	cmd.Flags().StringP("csv", "", "", "Write all the empty directories and zero-byte files to this CSV file")
+
+	return cmd
+}
+
+// newMergeCommand creates the command combining the partition jobs of a distributed scan into one job
+func newMergeCommand(AppVersion string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "merge",
+		Short: "Merge the partition jobs of a distributed scan into one job and report",
+		Long:  "Combine the jobs of the worker nodes that each scanned one partition (scan --partition i/n) into a new scan job with a single database and summary, then print its report like that of a scan run on one host. The job directories of the workers must be copied under jobs/ first.",
+		Example: `  Merge the four partitions of the nightly scan into job nightly:
+    terrasync report merge --jobs nightly-p1,nightly-p2,nightly-p3,nightly-p4 --id nightly --html --csv`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmdLine := buildCommandLine(cmd, args)
+			goexeDir, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			jobs, _ := cmd.Flags().GetStringSlice("jobs")
+			if len(jobs) == 0 {
+				return fmt.Errorf("no jobs given, use --jobs job1,job2,...")
+			}
+			jobDirs := make([]string, 0, len(jobs))
+			for _, job := range jobs {
+				jobDir, err := scanJobDir(goexeDir, strings.TrimSpace(job))
+				if err != nil {
+					return err
+				}
+				jobDirs = append(jobDirs, jobDir)
+			}
+			jobID, _ := cmd.Flags().GetString("id")
+			if jobID == "" {
+				return fmt.Errorf("no job id given for the merged job, use --id")
+			}
+			if !strings.HasPrefix(jobID, "Job_") {
+				jobID = fmt.Sprintf("Job_%s_scan", jobID)
+			}
+
+			csvReport, _ := cmd.Flags().GetBool("csv")
+			htmlReport, _ := cmd.Flags().GetBool("html")
+			signKey, err := loadSigningKey(cmd)
+			if err != nil {
+				return err
+			}
+
+			jobDir := filepath.Join(goexeDir, "jobs", jobID)
+			if _, err := scan.MergePartitions(cmd.Context(), scan.MergeConfig{
+				JobDirs:     jobDirs,
+				JobDir:      jobDir,
+				JobID:       jobID,
+				AppVersion:  AppVersion,
+				CmdLine:     cmdLine,
+				DBBatchSize: viper.GetInt("database.batch_size"),
+			}); err != nil {
+				return fmt.Errorf("failed to merge partitions: %w", err)
+			}
+
+			delimiter, err := csvDelimiter()
+			if err != nil {
+				return err
+			}
+			if _, err := scan.Regenerate(cmd.Context(), scan.RegenerateConfig{
+				JobDir:       jobDir,
+				LogPath:      filepath.Join(goexeDir, "terrasync.log"),
+				CSV:          csvReport,
+				HTML:         htmlReport,
+				SignKey:      signKey,
+				CSVDelimiter: delimiter,
+			}); err != nil {
+				return fmt.Errorf("failed to generate reports: %w", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceP("jobs", "", nil, "Comma separated IDs of the partition jobs, one per partition")
+	cmd.Flags().StringP("id", "", "", "Job id of the merged scan job")
+	cmd.Flags().BoolP("csv", "", false, "Create CSV report")
+	cmd.Flags().BoolP("html", "", false, "Create HTML report")
+	cmd.Flags().StringP("sign-key", "", "", "Sign a checksum manifest of the reports with this operator key (see report keygen)")
+
+	return cmd
+}
+
+// newKeygenCommand creates the command generating an operator key pair for signing reports
+func newKeygenCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keygen",
+		Short: "Generate an operator key pair for signing reports",
+		Long:  "Create an Ed25519 key pair. The secret key signs the report manifests with --sign-key, the public key is handed to customers to check them with report verify.",
+		Example: `  Create operator.key and operator.pub:
+    terrasync report keygen --key operator.key --pubkey operator.pub`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keyPath, _ := cmd.Flags().GetString("key")
+			pubPath, _ := cmd.Flags().GetString("pubkey")
+			// 不覆盖已有的密钥，否则之前签名的报告无法再校验
+			for _, path := range []string{keyPath, pubPath} {
+				if _, err := os.Stat(path); err == nil {
+					return fmt.Errorf("%s already exists", path)
+				}
+			}
+			pub, err := security.GenerateSigningKey(keyPath, pubPath)
+			if err != nil {
+				return err
+			}
+			fmt.Print(i18n.Sprintf("Generated key %s: secret key %s, public key %s\n", pub.ID, keyPath, pubPath))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("key", "", "terrasync.key", "Secret key file to create")
+	cmd.Flags().StringP("pubkey", "", "terrasync.pub", "Public key file to create")
+
+	return cmd
+}
+
+// newVerifyReportCommand creates the command checking the signed manifest of a job's reports
+func newVerifyReportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify <jobID|dir>",
+		Short: "Check the signature and checksums of signed reports",
+		Long:  "Verify the signature of the report manifest with the operator's public key and the checksums of the reports it lists. The reports are given as a job ID or as a directory, e.g. the artifacts handed to a customer.",
+		Example: `  Check the reports of a job:
+    terrasync report verify nightly --pubkey operator.pub
+
+  Check delivered artifacts:
+    terrasync report verify ./signoff --pubkey operator.pub`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pubPath, _ := cmd.Flags().GetString("pubkey")
+			if pubPath == "" {
+				return fmt.Errorf("no public key given, use --pubkey")
+			}
+			pub, err := security.LoadVerifyKey(pubPath)
+			if err != nil {
+				return err
+			}
+
+			dir := args[0]
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				goexeDir, err := loadConfig()
+				if err != nil {
+					return err
+				}
+				if dir, err = scanJobDir(goexeDir, args[0]); err != nil {
+					return err
+				}
+			}
+
+			verified, err := scan.VerifyReports(dir, pub)
+			for _, name := range verified {
+				fmt.Print(i18n.Sprintf("OK: %s\n", name))
+			}
+			if err != nil {
+				return fmt.Errorf("verification failed: %w", err)
+			}
+			fmt.Print(i18n.Sprintf("Reports signed by key %s are intact\n", pub.ID))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("pubkey", "", "", "Public key of the operator who signed the reports")
+
+	return cmd
+}
+
+// loadSigningKey loads the operator key given with --sign-key, nil when not set
+func loadSigningKey(cmd *cobra.Command) (*security.SigningKey, error) {
+	path, _ := cmd.Flags().GetString("sign-key")
+	if path == "" {
+		return nil, nil
+	}
+	return security.LoadSigningKey(path)
+}
+
+// scanJobDir resolves a scan job ID to its job directory.
+// 与scan --id相同，既接受完整的任务ID也接受用户指定的ID
+func scanJobDir(goexeDir, jobID string) (string, error) {
+	if !strings.HasPrefix(jobID, "Job_") {
+		jobID = fmt.Sprintf("Job_%s_scan", jobID)
+	}
+	jobDir := filepath.Join(goexeDir, "jobs", jobID)
+	if _, err := os.Stat(jobDir); err != nil {
+		return "", fmt.Errorf("job %s not found: %w", jobID, err)
+	}
+	return jobDir, nil
+}
@@ -10,6 +10,9 @@ import (
 	"github.com/spf13/viper"
 
 	"terrasync/app/scan"
+	"terrasync/changelist"
+	"terrasync/heartbeat"
+	"terrasync/sharedset"
 )
 
 func NewScanCommand(AppVersion string) *cobra.Command {
@@ -27,8 +30,11 @@ func NewScanCommand(AppVersion string) *cobra.Command {
 	Scan up to depth of 4. Depth -1 lists all subdirectories:
 	  terrasync scan --depth 4 <scanPath>
 	
-	Create HTML or CSV report (not yet implemented):
-	 terrasync scan --html <scanPath>
+	Create HTML or CSV report in the job directory:
+	 terrasync scan --html --csv <scanPath>
+
+	Write an NDJSON report and print the summary as JSON for a CI pipeline:
+	  terrasync scan --json --output json <scanPath>
 	
 	Print a report to the console for files matching criteria:
 	  terrasync scan --stats --match 'owner=="root" and size>100M' <scanPath>
@@ -36,6 +42,24 @@ func NewScanCommand(AppVersion string) *cobra.Command {
 	List regular files with "ntap" in the name and modified in the last half hour:
 	  terrasync -l --match 'modified<0.5 and "ntap" in name and type==file' <scanPath>
 
+	Scan the second of four partitions of the root entries on one of four nodes:
+	  terrasync scan --partition 2/4 --id nightly-p2 <scanPath>
+
+	Scan overlapping trees on two nodes, each directory listed by only one of them:
+	  terrasync scan --shared-set nightly-2026-10-15 --id nightly-a <scanPath>
+
+	Resume an interrupted two-phase scan without sending delivered entries to Kafka again:
+	  terrasync scan --two-phase --resume --id nightly <scanPath>
+
+	Update the scan of a volume from the SnapDiff of two snapshots instead of walking the tree:
+	  terrasync scan --id vol1 --changelist 'snapdiff://cluster1/<volume-uuid>?base=daily.0&diff=daily.1' <scanPath>
+
+	Update the scan of a Windows share from the NTFS USN journal since the previous run:
+	  terrasync scan --id share --changelist usn:///D:/share D:\share
+
+	Update the scan of a Linux directory from the journal recorded by terrasync watch:
+	  terrasync scan --id data --changelist 'journal:///var/lib/terrasync/data.journal?root=/data' /data
+
 	Exclude files modified less than half an hour ago:
 	 terrasync scan -exclude "type==file and modified<0.5" <scanPath>
 	
@@ -46,23 +70,32 @@ func NewScanCommand(AppVersion string) *cobra.Command {
 			// Build full command line string
 			cmdLine := buildCommandLine(cmd, args)
 
-			// Get executable path and directory
-			goexe, err := os.Executable()
-			if err != nil {
-				return fmt.Errorf("failed to get executable path: %w", err)
-			}
-			goexeDir := filepath.Dir(goexe)
-
 			// Read database type from config file
-			viper.SetConfigName("config")
-			viper.SetConfigType("yaml")
-			viper.AddConfigPath(goexeDir)
-			if err = viper.ReadInConfig(); err != nil {
-				return fmt.Errorf("error reading config file: %w", err)
+			goexeDir, err := loadConfig()
+			if err != nil {
+				return err
 			}
 
 			concurrency := viper.GetInt("scan.concurrency")
-			dbType := viper.GetString("database.type")
+			hugeDirThreshold := viper.GetInt64("scan.huge_dir_threshold")
+			autoTune := viper.GetBool("scan.autotune")
+			autoTuneMin := viper.GetInt("scan.autotune_min")
+			autoTuneMax := viper.GetInt("scan.autotune_max")
+			heartbeatInterval, err := configDuration("scan.heartbeat_interval")
+			if err != nil {
+				return err
+			}
+			stallTimeout, err := configDuration("scan.stall_timeout")
+			if err != nil {
+				return err
+			}
+			heartbeatConfig := heartbeat.Config{
+				Interval:     heartbeatInterval,
+				StallTimeout: stallTimeout,
+				AbortStalled: viper.GetBool("scan.abort_stalled"),
+			}
+			// 每个列举worker打开一个目录，压缩率采样还会打开文件
+			warnFDBudget(2 * max(concurrency, autoTuneMax))
 			dbBatchSize := viper.GetInt("database.batch_size")
 
 			// Read Kafka configuration
@@ -71,6 +104,27 @@ func NewScanCommand(AppVersion string) *cobra.Command {
 			kafkaHost := viper.GetString("kafka.host")
 			kafkaPort := viper.GetInt("kafka.port")
 			kafkaConcurrency := viper.GetInt("kafka.concurrency")
+			kafkaTLS := viper.GetBool("kafka.tls")
+			kafkaOverflow, err := scan.ParseOverflowStrategy(viper.GetString("kafka.overflow"))
+			if err != nil {
+				return err
+			}
+			var topicConfigs map[string]scan.TopicConfig
+			if err := viper.UnmarshalKey("kafka.topics", &topicConfigs); err != nil {
+				return fmt.Errorf("error reading kafka topics: %w", err)
+			}
+			kafkaTopics, err := scan.ParseKafkaTopics(topicConfigs, kafkaTopic)
+			if err != nil {
+				return err
+			}
+			webhook, err := webhookConfig()
+			if err != nil {
+				return err
+			}
+			clickHouse, err := clickHouseConfig()
+			if err != nil {
+				return err
+			}
 
 			scanID, _ := cmd.Flags().GetString("id")
 			depth, _ := cmd.Flags().GetInt("depth")
@@ -78,7 +132,49 @@ func NewScanCommand(AppVersion string) *cobra.Command {
 			excludeExpr, _ := cmd.Flags().GetString("exclude")
 			csvReport, _ := cmd.Flags().GetBool("csv")
 			htmlReport, _ := cmd.Flags().GetBool("html")
+			jsonReport, _ := cmd.Flags().GetBool("json")
+			outputFlag, _ := cmd.Flags().GetString("output")
+			output, err := scan.ParseOutput(outputFlag)
+			if err != nil {
+				return err
+			}
 			quiet, _ := cmd.Flags().GetBool("quiet")
+			noProgress, _ := cmd.Flags().GetBool("no-progress")
+			sampleOutputFlag := viper.GetString("scan.sample_output")
+			if cmd.Flags().Changed("sample-output") {
+				sampleOutputFlag, _ = cmd.Flags().GetString("sample-output")
+			}
+			sampleOutput, err := scan.ParseSampleOutput(sampleOutputFlag)
+			if err != nil {
+				return err
+			}
+			twoPhase, _ := cmd.Flags().GetBool("two-phase")
+			readOnly, _ := cmd.Flags().GetBool("assert-readonly")
+			resume, _ := cmd.Flags().GetBool("resume")
+			pruneUnchanged, _ := cmd.Flags().GetBool("prune-unchanged")
+			changeListURI, _ := cmd.Flags().GetString("changelist")
+			sharedSet, _ := cmd.Flags().GetString("shared-set")
+			top, _ := cmd.Flags().GetInt("top")
+			compressSample := viper.GetFloat64("scan.compress_sample")
+			if cmd.Flags().Changed("compress-sample") {
+				compressSample, _ = cmd.Flags().GetFloat64("compress-sample")
+			}
+			if compressSample < 0 || compressSample > 1 {
+				return fmt.Errorf("compress sample must be between 0 and 1: %v", compressSample)
+			}
+			signKey, err := loadSigningKey(cmd)
+			if err != nil {
+				return err
+			}
+			delimiter, err := csvDelimiter()
+			if err != nil {
+				return err
+			}
+			partitionFlag, _ := cmd.Flags().GetString("partition")
+			partition, err := scan.ParsePartition(partitionFlag)
+			if err != nil {
+				return err
+			}
 
 			var jobID string
 			if scanID == "" {
@@ -87,25 +183,94 @@ func NewScanCommand(AppVersion string) *cobra.Command {
 			} else {
 				jobID = fmt.Sprintf("Job_%s_scan", scanID)
 			}
+			// 继续中断的扫描需要之前运行的任务目录
+			if resume {
+				if scanID == "" {
+					return fmt.Errorf("--resume requires the --id of the interrupted scan")
+				}
+				if _, err := os.Stat(filepath.Join(goexeDir, "jobs", jobID)); err != nil {
+					return fmt.Errorf("failed to find job %s to resume: %w", jobID, err)
+				}
+			}
+			// 变更列表只包含变化的条目，需要上次扫描的结果作为基线
+			if changeListURI != "" {
+				if scanID == "" || resume {
+					return fmt.Errorf("--changelist requires the --id of a previous scan to update incrementally")
+				}
+				if _, err := os.Stat(filepath.Join(goexeDir, "jobs", jobID)); err != nil {
+					return fmt.Errorf("failed to find job %s to update from the change list: %w", jobID, err)
+				}
+			}
 			// Set up job directory
 			jobsDir, incrementalScan, err := isIncrementalScan(jobID, goexeDir)
 			if err != nil {
 				return err
 			}
+			estimated, _ := cmd.Flags().GetInt64("estimated-entries")
+			dbType := selectDBType(jobsDir, estimated)
 
 			scanPath := args[0]
 
+			progress, err := progressConfig(cmd, "scan", jobID)
+			if err != nil {
+				return err
+			}
+			// 预计条目数来自--estimated-entries或同一任务上次扫描的摘要
+			if estimated > 0 {
+				progress.TotalEntries = estimated
+			} else if previous, _ := scan.LoadJobSummary(jobsDir); previous != nil {
+				progress.TotalEntries = previous.Stats.FileCount + previous.Stats.DirCount
+				progress.TotalBytes = previous.Stats.TotalSize
+			}
+
+			pipeline, err := buildPipeline()
+			if err != nil {
+				return err
+			}
+
 			// 创建扫描配置结构体
 			scanConfig := scan.ScanConfig{
-				IncrementalScan: incrementalScan,
-				JobDir:          jobsDir,
-				DBBatchSize:     dbBatchSize,
-				DbType:          dbType,
-				Path:            scanPath,
-				Concurrency:     concurrency,
-				Depth:           depth,
-				Match:           scan.ParseConditions(matchExpr),
-				Exclude:         scan.ParseConditions(excludeExpr),
+				IncrementalScan:  incrementalScan && !resume,
+				JobDir:           jobsDir,
+				DBBatchSize:      dbBatchSize,
+				DbType:           dbType,
+				Path:             scanPath,
+				Concurrency:      concurrency,
+				Depth:            depth,
+				Match:            scan.ParseConditions(matchExpr),
+				Exclude:          scan.ParseConditions(excludeExpr),
+				HugeDirThreshold: hugeDirThreshold,
+				AutoTune:         autoTune,
+				AutoTuneMin:      autoTuneMin,
+				AutoTuneMax:      autoTuneMax,
+				Pipeline:         pipeline,
+				Heartbeat:        heartbeatConfig,
+				TwoPhase:         twoPhase || viper.GetBool("scan.two_phase"),
+				CompressSample:   compressSample,
+				Partition:        partition,
+				ReadOnly:         readOnly,
+				Resume:           resume,
+				PruneUnchanged:   pruneUnchanged || viper.GetBool("scan.prune_unchanged_dirs"),
+				Progress:         progress,
+				Top:              top,
+			}
+			if changeListURI != "" {
+				listConfig, err := changeListConfig()
+				if err != nil {
+					return err
+				}
+				if scanConfig.ChangeList, err = changelist.Open(changeListURI, listConfig); err != nil {
+					return err
+				}
+				defer scanConfig.ChangeList.Close()
+			}
+			if sharedSet != "" {
+				stateConfig, err := sharedStateConfig()
+				if err != nil {
+					return err
+				}
+				scanConfig.Visited = sharedset.Open(cmd.Context(), stateConfig, "scan:"+sharedSet)
+				defer scanConfig.Visited.Close()
 			}
 
 			reportConfig := scan.ReportConfig{
@@ -119,14 +284,25 @@ func NewScanCommand(AppVersion string) *cobra.Command {
 				KafkaConfig: scan.KafkaConfig{
 					Enabled:     kafkaEnabled,
 					Topic:       kafkaTopic,
+					Topics:      kafkaTopics,
 					Host:        kafkaHost,
 					Port:        kafkaPort,
 					Concurrency: kafkaConcurrency,
+					TLS:         kafkaTLS,
+					Overflow:    kafkaOverflow,
 				},
-				Quiet: quiet,
+				Webhook:      webhook,
+				ClickHouse:   clickHouse,
+				Quiet:        quiet,
+				SampleOutput: sampleOutput,
+				ProgressLine: !noProgress,
+				SignKey:      signKey,
+				CsvDelimiter: delimiter,
+				JSONReport:   jsonReport,
+				Output:       output,
 			}
 
-			if err := scan.Start(scanConfig, reportConfig); err != nil {
+			if err := scan.Start(cmd.Context(), scanConfig, reportConfig); err != nil {
 				return fmt.Errorf("failed to scan: %w", err)
 			}
 
@@ -141,7 +317,68 @@ func NewScanCommand(AppVersion string) *cobra.Command {
 	cmd.Flags().StringP("exclude", "e", "", "Exclude files using the given expression")
 	cmd.Flags().BoolP("csv", "", false, "Create CSV report")
 	cmd.Flags().BoolP("html", "", false, "Create HTML report")
+	cmd.Flags().BoolP("json", "", false, "Create NDJSON report, one record per entry and a final summary record")
+	cmd.Flags().StringP("output", "o", "text", "Console output of the summary: text or json (one JSON object, progress goes to the log)")
 	cmd.Flags().BoolP("quiet", "q", false, "no output in the console, but in the log.")
+	cmd.Flags().BoolP("no-progress", "", false, "Don't print the periodic progress line (files/s, dirs, bytes, elapsed), e.g. for scripts")
+	addProgressFlags(cmd)
+	cmd.Flags().StringP("sample-output", "", "1", "Print every Nth found file (N), at most N lines per second (N/s) or none (0)")
+	cmd.Flags().Float64P("compress-sample", "", 0, "Fraction of files (0-1) whose contents are sampled to estimate zstd compression savings")
+	cmd.Flags().StringP("sign-key", "", "", "Sign a checksum manifest of the reports with this operator key (see report keygen)")
+	cmd.Flags().StringP("partition", "", "", "Only scan partition i/n of the root entries, for a scan distributed over n nodes (see report merge)")
+	cmd.Flags().BoolP("two-phase", "", false, "List the whole tree into a snapshot first, then process the frozen listing")
+	cmd.Flags().BoolP("assert-readonly", "", false, "Refuse any write or delete on the scanned storage")
+	cmd.Flags().StringP("shared-set", "", "", "Name of the set of listed directories shared by the nodes of a distributed scan (see shared_state), nodes skip directories listed by another node")
+	cmd.Flags().BoolP("prune-unchanged", "", false, "In an incremental scan, don't descend into directories whose mtime and number of entries are unchanged (misses files modified in place)")
+	cmd.Flags().StringP("changelist", "", "", "Read the changes of an incremental scan from a vendor change list instead of walking the tree (snapdiff://, isilon://, s3-inventory://, gpfs-list://, lfs-find://, usn:// or journal://)")
+	cmd.Flags().BoolP("resume", "", false, "Resume an interrupted two-phase scan of --id, skipping the entries already delivered to the database and Kafka")
+	cmd.Flags().IntP("top", "", 0, "Report the N largest files and the N directories with the most files and bytes, 0 reports none")
+	cmd.Flags().Int64P("estimated-entries", "", 0, "Expected number of files and directories (e.g. used inodes from df -i), selects the database when database.type is auto; incremental scans use the previous run")
 
 	return cmd
 }
+
+// webhookConfig reads the webhook section of config.yaml
+func webhookConfig() (scan.WebhookConfig, error) {
+	config := scan.WebhookConfig{
+		URL:        viper.GetString("webhook.url"),
+		AuthHeader: viper.GetString("webhook.auth_header"),
+		BatchSize:  viper.GetInt("webhook.batch_size"),
+		Retries:    viper.GetInt("webhook.retries"),
+	}
+	var err error
+	if config.MaxLatency, err = configDuration("webhook.max_latency"); err != nil {
+		return config, err
+	}
+	if config.RetryWait, err = configDuration("webhook.retry_wait"); err != nil {
+		return config, err
+	}
+	if config.Timeout, err = configDuration("webhook.timeout"); err != nil {
+		return config, err
+	}
+	if config.Overflow, err = scan.ParseOverflowStrategy(viper.GetString("webhook.overflow")); err != nil {
+		return config, err
+	}
+	return config, config.Validate()
+}
+
+// clickHouseConfig reads the clickhouse section of config.yaml
+func clickHouseConfig() (scan.ClickHouseConfig, error) {
+	config := scan.ClickHouseConfig{
+		URL:       viper.GetString("clickhouse.url"),
+		Database:  viper.GetString("clickhouse.database"),
+		Table:     viper.GetString("clickhouse.table"),
+		User:      viper.GetString("clickhouse.user"),
+		Password:  viper.GetString("clickhouse.password"),
+		BatchSize: viper.GetInt("clickhouse.batch_size"),
+		Retries:   viper.GetInt("clickhouse.retries"),
+	}
+	var err error
+	if config.MaxLatency, err = configDuration("clickhouse.max_latency"); err != nil {
+		return config, err
+	}
+	if config.Timeout, err = configDuration("clickhouse.timeout"); err != nil {
+		return config, err
+	}
+	return config, config.Validate()
+}
@@ -1,16 +1,244 @@
 package command
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"terrasync/app/migrate"
+	"terrasync/app/scan"
+	"terrasync/audit"
+	"terrasync/changelist"
+	"terrasync/db"
+	"terrasync/i18n"
 	"terrasync/log"
+	"terrasync/object"
+	"terrasync/pkg/stats"
+	"terrasync/pkg/units"
+	"terrasync/processor"
+	"terrasync/sharedset"
+	"time"
 
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 )
 
+// loadConfig reads config.yaml located next to the executable
+func loadConfig() (string, error) {
+	// Get executable path and directory
+	goexe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to get executable path: %w", err)
+	}
+	goexeDir := filepath.Dir(goexe)
+
+	viper.SetConfigName("config")
+	viper.SetConfigType("yaml")
+	viper.AddConfigPath(goexeDir)
+	if err = viper.ReadInConfig(); err != nil {
+		return "", fmt.Errorf("error reading config file: %w", err)
+	}
+
+	// Register per-storage profiles (credentials etc.) matched by URI prefix
+	var profiles map[string]object.Profile
+	if err = viper.UnmarshalKey("storage.profiles", &profiles); err != nil {
+		return "", fmt.Errorf("error reading storage profiles: %w", err)
+	}
+	object.SetProfiles(profiles)
+
+	if err = setupFDBudget(); err != nil {
+		return "", err
+	}
+	if err = setupStatCache(); err != nil {
+		return "", err
+	}
+
+	if err = db.SetPathHash(viper.GetString("database.path_hash")); err != nil {
+		return "", fmt.Errorf("invalid database.path_hash: %w", err)
+	}
+
+	if err = scan.SetCategories(viper.GetStringMapStringSlice("categories")); err != nil {
+		return "", fmt.Errorf("invalid categories: %w", err)
+	}
+
+	db.SetPostgresDSN(viper.GetString("database.dsn"))
+	db.SetMySQLDSN(viper.GetString("database.dsn"))
+
+	auditPath := viper.GetString("audit.path")
+	if auditPath == "" {
+		auditPath = filepath.Join(goexeDir, "audit.log")
+	}
+	audit.SetPath(auditPath)
+
+	return goexeDir, nil
+}
+
+// setupFDBudget limits the file descriptors of the local storage to
+// limits.fd_budget, 0 sizes the budget from the NOFILE limit and -1 disables it
+func setupFDBudget() error {
+	n := viper.GetInt("limits.fd_budget")
+	switch {
+	case n < 0:
+		object.SetFDBudget(nil)
+	case n > 0:
+		object.SetFDBudget(object.NewFDBudget(n))
+	default:
+		budget, limit, err := object.AutoFDBudget()
+		if err != nil {
+			return err
+		}
+		object.SetFDBudget(budget)
+		if budget != nil {
+			log.Infof("NOFILE limit %d, file descriptor budget %d", limit, budget.Stats().Budget)
+		}
+	}
+	return nil
+}
+
+// setupStatCache enables the stat cache of the local storage with
+// limits.stat_cache entries kept for limits.stat_cache_ttl, 0 entries disables it
+func setupStatCache() error {
+	ttl, err := configDuration("limits.stat_cache_ttl")
+	if err != nil {
+		return err
+	}
+	n := viper.GetInt("limits.stat_cache")
+	if n <= 0 {
+		object.SetStatCache(nil)
+		return nil
+	}
+	if ttl <= 0 {
+		ttl = 10 * time.Minute
+	}
+	object.SetStatCache(object.NewStatCache(n, ttl))
+	return nil
+}
+
+// logStatCache logs the hit rate of the stat cache at the end of a command
+func logStatCache() {
+	if cache := object.CurrentStatCache(); cache != nil {
+		log.Infof("Stat cache: %s", cache.Stats())
+	}
+}
+
+// warnFDBudget warns when the file descriptor budget is smaller than the
+// descriptors the configured concurrency may keep open
+func warnFDBudget(opens int) {
+	budget := object.CurrentFDBudget().Stats().Budget
+	if budget == 0 || opens <= budget {
+		return
+	}
+	log.Warnf("Concurrency may open %d files at once but the file descriptor budget is %d, opens will queue", opens, budget)
+	fmt.Fprint(os.Stderr, i18n.Sprintf("Warning: the concurrency may open %d files at once but only %d file descriptors are available, raise the NOFILE limit (ulimit -n)\n", opens, budget))
+}
+
+// selectDBType resolves database.type for a job directory and warns before a
+// job whose estimated entries exceed database.auto_threshold is stored in SQLite
+func selectDBType(jobDir string, estimated int64) string {
+	dbType := viper.GetString("database.type")
+	threshold := viper.GetInt64("database.auto_threshold")
+	choice := scan.SelectDB(dbType, jobDir, estimated, threshold)
+	if dbType == db.TypeAuto {
+		log.Infof("Selected database %s for %d estimated entries (%s)", choice.Type, choice.Estimated, choice.Source)
+	}
+	if choice.Oversized {
+		log.Warnf("About %d entries are expected (%s) but SQLite is used, which slows down beyond %d entries", choice.Estimated, choice.Source, threshold)
+		fmt.Fprint(os.Stderr, i18n.Sprintf("Warning: about %d entries are expected (%s) but SQLite is used, which slows down beyond %d entries; set database.type: postgres or mysql with database.dsn, or split the scan with --partition and report merge\n",
+			choice.Estimated, i18n.T(choice.Source), threshold))
+	}
+	return choice.Type
+}
+
+// configDuration reads a duration option such as 30m, 7d or 4w, an unset option is 0
+func configDuration(key string) (time.Duration, error) {
+	value := viper.GetString(key)
+	if value == "" {
+		return 0, nil
+	}
+	d, err := units.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
+
+// csvDelimiter reads the field delimiter of the CSV reports from config.yaml
+func csvDelimiter() (rune, error) {
+	return scan.ParseCSVDelimiter(viper.GetString("scan.csv_delimiter"))
+}
+
+// changeListConfig reads the changelist section of config.yaml
+func changeListConfig() (changelist.Config, error) {
+	config := changelist.Config{
+		User:     viper.GetString("changelist.user"),
+		Password: viper.GetString("changelist.password"),
+	}
+	var err error
+	if config.Timeout, err = configDuration("changelist.timeout"); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// sharedStateConfig reads the shared_state section of config.yaml
+func sharedStateConfig() (sharedset.Config, error) {
+	config := sharedset.Config{
+		Redis:    viper.GetString("shared_state.redis"),
+		Password: viper.GetString("shared_state.password"),
+		DB:       viper.GetInt("shared_state.db"),
+		TLS:      viper.GetBool("shared_state.tls"),
+	}
+	var err error
+	if config.TTL, err = configDuration("shared_state.ttl"); err != nil {
+		return config, err
+	}
+	if config.Timeout, err = configDuration("shared_state.timeout"); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// buildPipeline creates the processor pipeline from the processors section of config.yaml
+func buildPipeline() (*processor.Pipeline, error) {
+	var configs []processor.Config
+	if err := viper.UnmarshalKey("processors", &configs); err != nil {
+		return nil, fmt.Errorf("error reading processors: %w", err)
+	}
+
+	processors := make([]processor.Processor, 0, len(configs))
+	for i, cfg := range configs {
+		if cfg.Name == "" {
+			cfg.Name = fmt.Sprintf("processor-%d", i+1)
+		}
+
+		var p processor.Processor
+		switch strings.ToLower(cfg.Type) {
+		case "", "rule":
+			matcher, err := scan.NewConditionFilter(scan.ParseConditions(cfg.Match))
+			if err != nil {
+				return nil, fmt.Errorf("processor %s: invalid match expression: %w", cfg.Name, err)
+			}
+			if p, err = processor.NewRuleProcessor(cfg.Name, matcher, cfg.Action, cfg.Destination); err != nil {
+				return nil, err
+			}
+		case "plugin":
+			var err error
+			if p, err = processor.LoadPlugin(cfg.Path, cfg.Args); err != nil {
+				return nil, fmt.Errorf("processor %s: %w", cfg.Name, err)
+			}
+		default:
+			return nil, fmt.Errorf("processor %s: unsupported type %q", cfg.Name, cfg.Type)
+		}
+		log.Infof("Processor loaded: %s (%s)", p.Name(), cfg.Type)
+		processors = append(processors, p)
+	}
+
+	return processor.NewPipeline(processors...), nil
+}
+
 // isIncrementalScan checks if a job directory exists and returns true if it does
 func isIncrementalScan(jobID, exeDir string) (string, bool, error) {
 	jobsDir := filepath.Join(exeDir, "jobs", jobID)
@@ -62,3 +290,44 @@ func buildCommandLine(cmd *cobra.Command, args []string) string {
 
 	return sb.String()
 }
+
+// confirmInterlock asks the operator to type the destination before a destructive
+// migration. Without a terminal on stdin nobody can answer and --force is needed.
+func confirmInterlock(dst string) migrate.Confirm {
+	return func(report migrate.InterlockReport) (bool, error) {
+		if !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+			return false, fmt.Errorf("stdin is not a terminal")
+		}
+		fmt.Fprint(os.Stderr, i18n.Sprintf("Overwrite with propagate-deletes may overwrite or delete %d files on %s (threshold %d).\nType the destination to proceed: ", report.AtRisk, dst, report.Threshold))
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return false, fmt.Errorf("failed to read confirmation: %w", err)
+		}
+		if strings.TrimSpace(line) != dst {
+			return false, fmt.Errorf("confirmation %q does not match the destination", strings.TrimSpace(line))
+		}
+		return true, nil
+	}
+}
+
+// progressConfig reads --progress-json and --progress-interval of a scan or
+// migrate command, the progress records go to stderr
+func progressConfig(cmd *cobra.Command, command, job string) (stats.ProgressConfig, error) {
+	config := stats.ProgressConfig{Command: command, Job: job}
+	interval, _ := cmd.Flags().GetString("progress-interval")
+	d, err := units.ParseDuration(interval)
+	if err != nil {
+		return config, fmt.Errorf("invalid progress interval: %w", err)
+	}
+	config.Interval = d
+	if enabled, _ := cmd.Flags().GetBool("progress-json"); enabled {
+		config.Writer = os.Stderr
+	}
+	return config, nil
+}
+
+// addProgressFlags adds the flags of the machine readable progress stream
+func addProgressFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolP("progress-json", "", false, "Write a single-line JSON progress record (files, bytes, rate, eta) to stderr periodically")
+	cmd.Flags().StringP("progress-interval", "", "5s", "Period of the scan progress line and of the --progress-json records")
+}
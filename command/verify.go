@@ -0,0 +1,84 @@
+package command
+
+import (
+	"fmt"
+	"path/filepath"
+	"terrasync/app/scan"
+	"terrasync/app/verify"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// NewVerifyCommand creates the verify command
+func NewVerifyCommand(AppVersion string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify <source> <destination>",
+		Short: "Verify a migrated destination against its source",
+		Long:  "Compare source and destination and report missing, extra and differing entries. By default files are compared by type, size and modification time; --attrs compares all metadata (permissions, owner, ACL hash) as well and --checksum reads both sides to compare the digests of files of the same size.",
+		Example: `  Compare the trees by size and modification time after a migration:
+    terrasync verify /mnt/src /mnt/dst
+
+  Compare file contents by SHA-256 as well:
+    terrasync verify --checksum sha256 /mnt/src s3://bucket/share
+
+  Report attribute drift after a migration:
+    terrasync verify --attrs /mnt/src /mnt/dst
+
+  Write the drifted entries to a CSV file:
+    terrasync verify --attrs --report drift.csv /mnt/src /mnt/dst`,
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			goexeDir, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			depth, _ := cmd.Flags().GetInt("depth")
+			matchExpr, _ := cmd.Flags().GetString("match")
+			excludeExpr, _ := cmd.Flags().GetString("exclude")
+			attrs, _ := cmd.Flags().GetBool("attrs")
+			checksum, _ := cmd.Flags().GetString("checksum")
+			reportPath, _ := cmd.Flags().GetString("report")
+			if reportPath == "" {
+				reportPath = filepath.Join(goexeDir, fmt.Sprintf("verify_%s.csv", time.Now().Format("2006-01-02_15.04.05")))
+			}
+
+			verifyConfig := verify.VerifyConfig{
+				Source:      args[0],
+				Destination: args[1],
+				Concurrency: viper.GetInt("scan.concurrency"),
+				Depth:       depth,
+				Match:       scan.ParseConditions(matchExpr),
+				Exclude:     scan.ParseConditions(excludeExpr),
+				Attrs:       attrs,
+				Checksum:    checksum,
+				ReportPath:  reportPath,
+				CmdLine:     buildCommandLine(cmd, args),
+				StartTime:   time.Now(),
+			}
+
+			result, err := verify.Start(cmd.Context(), verifyConfig)
+			verify.PrintReport(verifyConfig, result, err)
+			logStatCache()
+			if err != nil {
+				return fmt.Errorf("failed to verify: %w", err)
+			}
+			if result.Drifted > 0 || result.Extra > 0 {
+				return fmt.Errorf("%d of %d entries drifted, %d extra entries in the destination, see %s", result.Drifted, result.Checked, result.Extra, reportPath)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolP("attrs", "", false, "Compare all metadata (permissions, owner, ACL hash), not only size and modification time")
+	cmd.Flags().StringP("checksum", "", "", "Compare file contents by digest (md5 or sha256)")
+	cmd.Flags().IntP("depth", "d", 0, "Set maximum verify depth")
+	cmd.Flags().StringP("match", "m", "", "Verify only files matching the given expression")
+	cmd.Flags().StringP("exclude", "e", "", "Exclude files using the given expression")
+	cmd.Flags().StringP("report", "", "", "CSV file listing drifted entries (default: verify_<time>.csv next to the executable)")
+
+	return cmd
+}
@@ -0,0 +1,65 @@
+package command
+
+import (
+	"fmt"
+	"path/filepath"
+	"terrasync/app/watch"
+	"terrasync/i18n"
+	"terrasync/pkg/units"
+
+	"github.com/spf13/cobra"
+)
+
+// NewWatchCommand creates the command collecting the changes of a local
+// directory into a change journal
+func NewWatchCommand(AppVersion string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "watch <directory>",
+		Short: "Record the changes of a local directory for incremental scans",
+		Long:  "Record the file changes of a local Linux filesystem with fanotify into a change journal until interrupted, so the next incremental scan reads the changed paths with --changelist journal://<journal>?root=<directory> instead of walking the tree. Run it on the source host as a service (root privileges, Linux 5.9+); a scan after the collector was restarted or lost events walks the tree once. With retention.interval set, the service also removes the job directories, exports and rotated logs past their retention, as terrasync gc does.",
+		Example: `  Record the changes of /data between the incremental scans of job data:
+    terrasync watch --journal /var/lib/terrasync/data.journal /data
+    terrasync scan --id data --changelist 'journal:///var/lib/terrasync/data.journal?root=/data' /data`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			goexeDir, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			journal, _ := cmd.Flags().GetString("journal")
+			if journal == "" {
+				return fmt.Errorf("no change journal given, use --journal")
+			}
+			journal, err = filepath.Abs(journal)
+			if err != nil {
+				return fmt.Errorf("invalid journal path: %w", err)
+			}
+			intervalFlag, _ := cmd.Flags().GetString("flush-interval")
+			interval, err := units.ParseDuration(intervalFlag)
+			if err != nil {
+				return fmt.Errorf("invalid flush interval: %w", err)
+			}
+			if err := startHousekeeping(cmd.Context(), goexeDir); err != nil {
+				return err
+			}
+
+			// SIGINT/SIGTERM取消命令的context，日志写入后退出
+			result, err := watch.Run(cmd.Context(), watch.Config{
+				Root:          args[0],
+				Journal:       journal,
+				FlushInterval: interval,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to watch %s: %w", args[0], err)
+			}
+			fmt.Print(i18n.Sprintf("Recorded %d changes into %s (%d repeated modifications merged, %d overflows)\n", result.Events, journal, result.Merged, result.Overflow))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("journal", "", "", "Change journal file the changes are appended to")
+	cmd.Flags().StringP("flush-interval", "", "1s", "Interval the journal is written at, repeated modifications of a file within it are recorded once")
+
+	return cmd
+}
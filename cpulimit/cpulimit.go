@@ -0,0 +1,257 @@
+// Package cpulimit constrains the CPU used by terrasync, so migrations running
+// next to production workloads can be capped without taskset or cgroup tooling:
+// GOMAXPROCS, the CPUs the process may run on (a CPU list or a NUMA node) and
+// the CPU quota of the cgroup of the process.
+package cpulimit
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Config is the CPU limit of the process
+type Config struct {
+	MaxProcs int    // GOMAXPROCS，0表示按CPU亲和性及cgroup配额确定
+	CPUs     string // 允许运行的CPU，如0-3,8，或node:N表示NUMA节点N的CPU，为空时不修改
+	Cgroup   bool   // 按cgroup的CPU配额(cpu.max或cfs_quota_us)限制GOMAXPROCS
+}
+
+// Result describes the limits applied
+type Result struct {
+	MaxProcs    int
+	CPUs        []int   // 设置的CPU亲和性，未设置时为nil
+	CgroupQuota float64 // cgroup配额折合的CPU数，0表示没有配额或未检测
+}
+
+func (r Result) String() string {
+	s := fmt.Sprintf("GOMAXPROCS %d", r.MaxProcs)
+	if r.CPUs != nil {
+		s += fmt.Sprintf(", affinity %s", FormatCPUList(r.CPUs))
+	}
+	if r.CgroupQuota > 0 {
+		s += fmt.Sprintf(", cgroup quota %.2f CPUs", r.CgroupQuota)
+	}
+	return s
+}
+
+// Apply sets the CPU affinity and GOMAXPROCS of the process. It should be
+// called early, threads started later inherit the affinity.
+func Apply(c Config) (Result, error) {
+	var result Result
+	procs := runtime.GOMAXPROCS(0)
+
+	if c.CPUs != "" {
+		cpus, err := resolveCPUs(c.CPUs)
+		if err != nil {
+			return result, err
+		}
+		if err := setAffinity(cpus); err != nil {
+			return result, fmt.Errorf("failed to set CPU affinity %s: %w", c.CPUs, err)
+		}
+		result.CPUs = cpus
+		// runtime.NumCPU只在启动时读取亲和性
+		procs = min(procs, len(cpus))
+	}
+
+	if c.Cgroup {
+		quota, err := cgroupQuota()
+		if err != nil {
+			return result, fmt.Errorf("failed to read cgroup CPU quota: %w", err)
+		}
+		if quota > 0 {
+			result.CgroupQuota = quota
+			procs = min(procs, max(int(math.Ceil(quota)), 1))
+		}
+	}
+
+	if c.MaxProcs < 0 {
+		return result, fmt.Errorf("invalid max procs %d", c.MaxProcs)
+	}
+	if c.MaxProcs > 0 {
+		procs = c.MaxProcs
+	}
+	runtime.GOMAXPROCS(procs)
+	result.MaxProcs = procs
+	return result, nil
+}
+
+// resolveCPUs returns the CPUs of a CPU list or of node:N
+func resolveCPUs(s string) ([]int, error) {
+	if node, ok := strings.CutPrefix(s, "node:"); ok {
+		n, err := strconv.Atoi(node)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid NUMA node %q", node)
+		}
+		return nodeCPUs(n)
+	}
+	return ParseCPUList(s)
+}
+
+// ParseCPUList parses a CPU list such as 0-3,8,10-11 as used by taskset and
+// /sys/devices/system/node/nodeN/cpulist
+func ParseCPUList(s string) ([]int, error) {
+	seen := make(map[int]bool)
+	var cpus []int
+	for _, part := range strings.Split(strings.TrimSpace(s), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid CPU list %q", s)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(hi); err != nil || last < first {
+				return nil, fmt.Errorf("invalid CPU list %q", s)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			if !seen[cpu] {
+				seen[cpu] = true
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("empty CPU list %q", s)
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
+// FormatCPUList formats sorted CPUs as a CPU list with ranges
+func FormatCPUList(cpus []int) string {
+	var parts []string
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", cpus[i], cpus[j]))
+		} else {
+			parts = append(parts, strconv.Itoa(cpus[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseCPUMax parses the cgroup v2 cpu.max file, "max 100000" means no quota
+func parseCPUMax(data string) (float64, error) {
+	fields := strings.Fields(data)
+	if len(fields) == 0 || fields[0] == "max" {
+		return 0, nil
+	}
+	quota, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cpu.max %q", strings.TrimSpace(data))
+	}
+	period := 100000.0
+	if len(fields) > 1 {
+		if period, err = strconv.ParseFloat(fields[1], 64); err != nil || period <= 0 {
+			return 0, fmt.Errorf("invalid cpu.max %q", strings.TrimSpace(data))
+		}
+	}
+	return quota / period, nil
+}
+
+// parseCFSQuota parses the cgroup v1 cpu.cfs_quota_us and cpu.cfs_period_us, -1 means no quota
+func parseCFSQuota(quotaData, periodData string) (float64, error) {
+	quota, err := strconv.ParseFloat(strings.TrimSpace(quotaData), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cpu.cfs_quota_us %q", strings.TrimSpace(quotaData))
+	}
+	if quota <= 0 {
+		return 0, nil
+	}
+	period, err := strconv.ParseFloat(strings.TrimSpace(periodData), 64)
+	if err != nil || period <= 0 {
+		return 0, fmt.Errorf("invalid cpu.cfs_period_us %q", strings.TrimSpace(periodData))
+	}
+	return quota / period, nil
+}
+
+// readCgroupQuota reads the CPU quota of the cgroup described by selfCgroup (the
+// content of /proc/self/cgroup) under the cgroup mount root. The quota of the
+// closest ancestor with a limit applies, as the kernel enforces the smallest.
+func readCgroupQuota(root, selfCgroup string) (float64, error) {
+	var quota float64
+	for _, line := range strings.Split(selfCgroup, "\n") {
+		// hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(strings.TrimSpace(line), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		switch {
+		case parts[0] == "0" && parts[1] == "":
+			// cgroup v2
+			for dir := parts[2]; ; dir = filepath.Dir(dir) {
+				data, err := os.ReadFile(filepath.Join(root, dir, "cpu.max"))
+				if err == nil {
+					q, err := parseCPUMax(string(data))
+					if err != nil {
+						return 0, err
+					}
+					quota = minQuota(quota, q)
+				}
+				if dir == "/" || dir == "." {
+					break
+				}
+			}
+		case containsController(parts[1], "cpu"):
+			// cgroup v1，cpu控制器挂载在cpu,cpuacct或cpu目录下
+			for _, mount := range []string{"cpu,cpuacct", "cpu"} {
+				dir := filepath.Join(root, mount, parts[2])
+				quotaData, err := os.ReadFile(filepath.Join(dir, "cpu.cfs_quota_us"))
+				if err != nil {
+					// 容器中cgroup路径常被挂载为根目录
+					dir = filepath.Join(root, mount)
+					if quotaData, err = os.ReadFile(filepath.Join(dir, "cpu.cfs_quota_us")); err != nil {
+						continue
+					}
+				}
+				periodData, err := os.ReadFile(filepath.Join(dir, "cpu.cfs_period_us"))
+				if err != nil {
+					return 0, err
+				}
+				q, err := parseCFSQuota(string(quotaData), string(periodData))
+				if err != nil {
+					return 0, err
+				}
+				quota = minQuota(quota, q)
+				break
+			}
+		}
+	}
+	return quota, nil
+}
+
+func containsController(list, controller string) bool {
+	for _, c := range strings.Split(list, ",") {
+		if c == controller {
+			return true
+		}
+	}
+	return false
+}
+
+// minQuota returns the smaller quota, 0 means no quota
+func minQuota(a, b float64) float64 {
+	if a == 0 {
+		return b
+	}
+	if b == 0 {
+		return a
+	}
+	return min(a, b)
+}
@@ -0,0 +1,52 @@
+package cpulimit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// setAffinity binds every thread of the process to the CPUs, threads created
+// later inherit the mask of the thread creating them
+func setAffinity(cpus []int) error {
+	var set unix.CPUSet
+	for _, cpu := range cpus {
+		set.Set(cpu)
+	}
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return unix.SchedSetaffinity(0, &set)
+	}
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		// 线程可能已经退出
+		if err := unix.SchedSetaffinity(tid, &set); err != nil && err != unix.ESRCH {
+			return err
+		}
+	}
+	return nil
+}
+
+// nodeCPUs returns the CPUs of a NUMA node
+func nodeCPUs(node int) ([]int, error) {
+	data, err := os.ReadFile(filepath.Join("/sys/devices/system/node", fmt.Sprintf("node%d", node), "cpulist"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CPUs of NUMA node %d: %w", node, err)
+	}
+	return ParseCPUList(string(data))
+}
+
+// cgroupQuota returns the CPU quota of the cgroup of the process in CPUs, 0 without quota
+func cgroupQuota() (float64, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return 0, err
+	}
+	return readCgroupQuota("/sys/fs/cgroup", string(data))
+}
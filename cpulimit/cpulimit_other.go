@@ -0,0 +1,20 @@
+//go:build !linux && !windows
+
+package cpulimit
+
+import "errors"
+
+// setAffinity is not supported, macOS only offers affinity hints
+func setAffinity(cpus []int) error {
+	return errors.New("CPU affinity is not supported on this platform")
+}
+
+// nodeCPUs is not supported
+func nodeCPUs(node int) ([]int, error) {
+	return nil, errors.New("NUMA node affinity is only supported on Linux")
+}
+
+// cgroupQuota returns 0 without cgroup
+func cgroupQuota() (float64, error) {
+	return 0, nil
+}
@@ -0,0 +1,108 @@
+package cpulimit
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseCPUList 测试解析CPU列表
+func TestParseCPUList(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		want    []int
+		wantErr bool
+	}{
+		{name: "单个CPU", input: "3", want: []int{3}},
+		{name: "范围及单个CPU", input: "0-3,8", want: []int{0, 1, 2, 3, 8}},
+		{name: "乱序且重复", input: "8, 2-3,3\n", want: []int{2, 3, 8}},
+		{name: "空列表", input: " ", wantErr: true},
+		{name: "范围倒置", input: "3-1", wantErr: true},
+		{name: "负数", input: "-1", wantErr: true},
+		{name: "非数字", input: "a", wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cpus, err := ParseCPUList(c.input)
+			if c.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.want, cpus)
+		})
+	}
+	assert.Equal(t, "0-3,8,10-11", FormatCPUList([]int{0, 1, 2, 3, 8, 10, 11}))
+}
+
+// TestParseQuota 测试解析cgroup v1及v2的CPU配额
+func TestParseQuota(t *testing.T) {
+	quota, err := parseCPUMax("max 100000\n")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, quota)
+	quota, err = parseCPUMax("150000 100000\n")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, quota)
+	_, err = parseCPUMax("x 100000")
+	assert.Error(t, err)
+
+	quota, err = parseCFSQuota("-1\n", "100000\n")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, quota)
+	quota, err = parseCFSQuota("400000\n", "100000\n")
+	assert.NoError(t, err)
+	assert.Equal(t, 4.0, quota)
+	_, err = parseCFSQuota("400000", "0")
+	assert.Error(t, err)
+}
+
+// TestReadCgroupQuota 测试按/proc/self/cgroup找到进程所在cgroup的配额
+func TestReadCgroupQuota(t *testing.T) {
+	write := func(path, data string) {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	}
+
+	// cgroup v2，父cgroup的配额更小
+	v2 := t.TempDir()
+	write(filepath.Join(v2, "cpu.max"), "max 100000\n")
+	write(filepath.Join(v2, "kubepods", "cpu.max"), "200000 100000\n")
+	write(filepath.Join(v2, "kubepods", "pod1", "cpu.max"), "max 100000\n")
+	quota, err := readCgroupQuota(v2, "0::/kubepods/pod1\n")
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, quota)
+
+	// cgroup v1
+	v1 := t.TempDir()
+	write(filepath.Join(v1, "cpu,cpuacct", "docker", "c1", "cpu.cfs_quota_us"), "50000\n")
+	write(filepath.Join(v1, "cpu,cpuacct", "docker", "c1", "cpu.cfs_period_us"), "100000\n")
+	quota, err = readCgroupQuota(v1, "12:memory:/docker/c1\n4:cpu,cpuacct:/docker/c1\n")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, quota)
+
+	// 没有配额
+	quota, err = readCgroupQuota(t.TempDir(), "0::/\n")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, quota)
+}
+
+// TestApply 测试设置GOMAXPROCS
+func TestApply(t *testing.T) {
+	saved := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(saved)
+
+	result, err := Apply(Config{MaxProcs: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.MaxProcs)
+	assert.Equal(t, 1, runtime.GOMAXPROCS(0))
+	assert.Equal(t, "GOMAXPROCS 1", result.String())
+
+	_, err = Apply(Config{MaxProcs: -1})
+	assert.Error(t, err)
+	_, err = Apply(Config{CPUs: "node:x"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,37 @@
+package cpulimit
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+var procSetProcessAffinityMask = windows.NewLazySystemDLL("kernel32.dll").NewProc("SetProcessAffinityMask")
+
+// setAffinity sets the affinity mask of the process, limited to the first
+// processor group of 64 CPUs
+func setAffinity(cpus []int) error {
+	var mask uintptr
+	for _, cpu := range cpus {
+		if cpu >= 64 {
+			return fmt.Errorf("CPU %d is out of the first processor group", cpu)
+		}
+		mask |= 1 << uint(cpu)
+	}
+	r, _, err := procSetProcessAffinityMask.Call(uintptr(windows.CurrentProcess()), mask)
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+// nodeCPUs is not supported on Windows
+func nodeCPUs(node int) ([]int, error) {
+	return nil, errors.New("NUMA node affinity is only supported on Linux")
+}
+
+// cgroupQuota returns 0, Windows has no cgroup
+func cgroupQuota() (float64, error) {
+	return 0, nil
+}
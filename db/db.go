@@ -1,28 +1,90 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"terrasync/object"
+	"time"
 )
 
+// HeartbeatData 任务心跳记录，记录进度增量以便事后分析长任务的卡顿
+type HeartbeatData struct {
+	Time          time.Time
+	Entries       int64
+	Bytes         int64
+	DeltaEntries  int64
+	DeltaBytes    int64
+	EntriesPerSec float64
+	InFlight      int
+	Stalled       int
+}
+
+// ExtStats 一种扩展名的文件数及总字节数
+type ExtStats struct {
+	Ext   string
+	Files int64
+	Bytes int64
+}
+
+// DuplicateFileData 重复文件组中的一个文件，同一组的文件内容相同
+type DuplicateFileData struct {
+	Group int    // 组号，从1开始
+	Hash  string // 文件内容的十六进制摘要
+	Size  int64
+	Path  string
+}
+
 // DB 定义数据库操作接口
+// 所有操作都接受context以支持取消，并返回错误由调用方决定任务状态
 type DB interface {
-	// Init 初始化数据库连接
-	CreateTable(name string) error
+	// CreateTable 创建文件信息表
+	CreateTable(ctx context.Context, name string) error
+
+	// DropTable 删除表，表不存在时不报错
+	DropTable(ctx context.Context, name string) error
 
 	// SaveEntries 批量保存多个对象到数据库
-	SaveEntries(fileInfos []object.FileInfo, tableName string) error
+	SaveEntries(ctx context.Context, fileInfos []object.FileInfo, tableName string) error
 
 	// GetUniqueExtCount 获取数据库中不重复的文件扩展名总数
-	GetUniqueExtCount() (int, error)
+	GetUniqueExtCount(ctx context.Context) (int, error)
+
+	// GetExtStats 按扩展名分组统计file_entries表中的文件数及总字节数，按总字节数降序
+	GetExtStats(ctx context.Context) ([]ExtStats, error)
+
+	// ListEntries 按保存顺序遍历表中的所有文件，表名为空时使用file_entries表
+	ListEntries(ctx context.Context, tableName string, fn func(FileInfoData) error) error
+
+	// QueryExactNewFiles 查询在临时表中但不在file_entries表中的文件
+	QueryExactNewFiles(ctx context.Context, tableName string) ([]FileInfoData, error)
+
+	// QueryChangedFiles 查询ctime/mtime与file_entries表中不同的文件
+	QueryChangedFiles(ctx context.Context, tableName string) ([]FileInfoData, error)
+
+	// CountDuplicatePaths 统计表中同一路径多保存的行数，表名为空时使用file_entries表
+	CountDuplicatePaths(ctx context.Context, tableName string) (int64, error)
+
+	// SaveHeartbeat 保存一条任务心跳到heartbeats表，表不存在时自动创建
+	SaveHeartbeat(ctx context.Context, beat HeartbeatData) error
+
+	// SaveDelivery 保存sink的送达高水位(之前的条目都已送达)到deliveries表，表不存在时自动创建
+	SaveDelivery(ctx context.Context, sink string, seq int64) error
+
+	// ListDeliveries 读取各sink的送达高水位，表不存在时返回空
+	ListDeliveries(ctx context.Context) (map[string]int64, error)
+
+	// SaveCursor 保存变更日志(如NTFS USN日志)下次读取的位置到cursors表，表不存在时自动创建
+	SaveCursor(ctx context.Context, name string, position string) error
 
-	QueryExactNewFiles(tableName string) []FileInfoData
+	// ListCursors 读取各变更日志保存的位置，表不存在时返回空
+	ListCursors(ctx context.Context) (map[string]string, error)
 
-	QueryChangedFiles(tableName string) []FileInfoData
+	// SaveDuplicates 用一次查重的结果替换duplicate_files表中的内容，表不存在时自动创建
+	SaveDuplicates(ctx context.Context, files []DuplicateFileData) error
 
 	// Close 关闭数据库连接
 	Close() error
 
 	// Query 执行SQL查询并返回结果行
-	Query(query string, args ...interface{}) (*sql.Rows, error)
+	Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 }
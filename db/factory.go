@@ -2,14 +2,37 @@ package db
 
 import (
 	"fmt"
+	"sort"
 )
 
+// TypeAuto selects the database type of a job by its estimated number of
+// entries, it is resolved before a database is opened
+const TypeAuto = "auto"
+
 // dbFactory 定义数据库工厂函数类型
 type dbFactory func(string) (DB, error)
 
 // factories 存储已注册的数据库工厂
 var factories = make(map[string]dbFactory)
 
+// available 存储需要配置才能使用的数据库类型的检查函数
+var available = make(map[string]func() bool)
+
+// setAvailable sets the check whether a registered type is configured
+func setAvailable(dbType string, check func() bool) {
+	available[dbType] = check
+}
+
+// Available reports whether a registered type can be opened with the current
+// configuration, such as a server type with its DSN set
+func Available(dbType string) bool {
+	if _, exists := factories[dbType]; !exists {
+		return false
+	}
+	check, exists := available[dbType]
+	return !exists || check()
+}
+
 // RegisterDB 注册数据库工厂
 func RegisterDB(dbType string, factory dbFactory) {
 	factories[dbType] = factory
@@ -27,6 +50,16 @@ func NewDB(dbType string, dsn string) (DB, error) {
 	return factory(dsn)
 }
 
+// Types 返回已注册的数据库类型，按名称排序
+func Types() []string {
+	types := make([]string, 0, len(factories))
+	for dbType := range factories {
+		types = append(types, dbType)
+	}
+	sort.Strings(types)
+	return types
+}
+
 // 初始化时注册内置数据库驱动
 func init() {
 	RegisterDB("sqlite", func(dsn string) (DB, error) {
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/zeebo/xxh3"
+	"modernc.org/sqlite"
+)
+
+// Algorithms of the fixed-width path hash column, set by database.path_hash
+const (
+	PathHashNone   = "none"
+	PathHashXXH128 = "xxhash128" // 16字节的XXH3-128
+)
+
+// pathHashFunc is the SQL function computing the path hash of existing rows
+const pathHashFunc = "terrasync_path_hash"
+
+var pathHashEnabled atomic.Bool
+
+func init() {
+	if err := sqlite.RegisterDeterministicScalarFunction(pathHashFunc, 1, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		path, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s expects a text path", pathHashFunc)
+		}
+		return PathHash(path), nil
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// SetPathHash selects the algorithm of the path_hash column. With a hash, file
+// tables store it next to the path and incremental scans join on its index
+// instead of comparing long path strings, the path is still compared to rule
+// out collisions. Tables of earlier scans are hashed when they are next used.
+// PostgreSQL and MySQL tables always store the hash.
+func SetPathHash(algorithm string) error {
+	switch algorithm {
+	case "", PathHashNone:
+		pathHashEnabled.Store(false)
+	case PathHashXXH128:
+		pathHashEnabled.Store(true)
+	default:
+		return fmt.Errorf("unsupported path hash %q, expect none or xxhash128", algorithm)
+	}
+	return nil
+}
+
+// PathHash returns the fixed-width hash of a path key
+func PathHash(key string) []byte {
+	// 标准的大端字节序，高64位在前，与之前保存的哈希相同
+	sum := xxh3.HashString128(key).Bytes()
+	return sum[:]
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"crypto/tls"
+	"terrasync/security"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestJobSchema 测试按任务目录命名schema
+func TestJobSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"任务目录", "/opt/terrasync/jobs/Job_archive_scan/index.db", "job_archive_scan_34b940df275cee5f"},
+		{"特殊字符", "jobs/Job_vol-1.a_scan/index.db", "job_vol_1_a_scan_b7fdf3a215a63ff7"},
+		{"数字开头", "jobs/2024/index.db", "job_2024_18371e0b3585c299"},
+		{"超长名称截断", "jobs/Job_abcdefghijabcdefghijabcdefghijabcdefghijabcdefghijabcdefghij_scan/index.db", "job_abcdefghijabcdefghijabcdefghijabcdefghijab_543d1a99740e82c3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, JobSchema(tt.path))
+			assert.LessOrEqual(t, len(JobSchema(tt.path)), 63)
+		})
+	}
+
+	// 转换为标识符后相同的任务目录不共用表
+	seen := make(map[string]string)
+	for _, dir := range []string{"Job_vol-1_scan", "Job_vol_1_scan", "Job_vol.1_scan", "job_vol_1_scan", "JOB_VOL_1_SCAN"} {
+		schema := JobSchema("jobs/" + dir + "/index.db")
+		assert.NotContains(t, seen, schema, dir)
+		seen[schema] = dir
+	}
+}
+
+// TestNewPostgresDBInvalidDSN 测试连接前拒绝无效的DSN
+func TestNewPostgresDBInvalidDSN(t *testing.T) {
+	_, err := NewPostgresDB("postgres://scan@db.example.com/terrasync?sslmode=sometimes", "job_1")
+	assert.ErrorContains(t, err, "invalid postgres DSN")
+}
+
+// TestPostgresConfig 测试默认校验证书，不允许自动回退到明文连接
+func TestPostgresConfig(t *testing.T) {
+	defer security.SetFIPS(false)
+	t.Setenv("PGSSLMODE", "")
+
+	cfg, err := postgresConfig("postgres://scan@db.example.com/terrasync")
+	assert.NoError(t, err)
+	assert.Equal(t, "db.example.com", cfg.Host)
+	if assert.NotNil(t, cfg.TLSConfig) {
+		assert.Equal(t, "db.example.com", cfg.TLSConfig.ServerName)
+		assert.False(t, cfg.TLSConfig.InsecureSkipVerify)
+		assert.Equal(t, uint16(tls.VersionTLS12), cfg.TLSConfig.MinVersion)
+	}
+	assert.Empty(t, cfg.Fallbacks)
+
+	// FIPS模式下所有主机的TLS配置都限制为TLS 1.2
+	security.SetFIPS(true)
+	cfg, err = postgresConfig("postgresql://scan@db1.example.com,db2.example.com/terrasync?sslmode=require")
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg.TLSConfig) && assert.Len(t, cfg.Fallbacks, 1) {
+		for _, tlsConfig := range []*tls.Config{cfg.TLSConfig, cfg.Fallbacks[0].TLSConfig} {
+			assert.True(t, tlsConfig.InsecureSkipVerify)
+			assert.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MaxVersion)
+			assert.Equal(t, security.TLSConfig().CipherSuites, tlsConfig.CipherSuites)
+		}
+	}
+	security.SetFIPS(false)
+
+	// 明确指定disable时使用明文连接
+	cfg, err = postgresConfig("postgres://scan@db.example.com/terrasync?sslmode=disable")
+	assert.NoError(t, err)
+	assert.Nil(t, cfg.TLSConfig)
+	assert.Empty(t, cfg.Fallbacks)
+
+	for _, dsn := range []string{
+		"mysql://db/terrasync",
+		"host=db dbname=terrasync",
+		"postgres://db/terrasync?sslmode=prefer",
+		"postgres://db/terrasync?sslmode=allow",
+		"postgres://db/terrasync?sslmode=sometimes",
+	} {
+		_, err := postgresConfig(dsn)
+		assert.Error(t, err, dsn)
+	}
+
+	// PGSSLMODE与DSN中的sslmode相同地检查
+	t.Setenv("PGSSLMODE", "prefer")
+	_, err = postgresConfig("postgres://db/terrasync")
+	assert.ErrorContains(t, err, "falls back to plaintext")
+	cfg, err = postgresConfig("postgres://db/terrasync?sslmode=verify-full")
+	assert.NoError(t, err)
+	assert.NotNil(t, cfg.TLSConfig)
+}
@@ -0,0 +1,472 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"terrasync/log"
+	"terrasync/object"
+	"time"
+)
+
+// Pinger is implemented by databases whose connection can be checked
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Flusher is implemented by databases that buffer writes
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Flush waits until the buffered writes of d are saved, it returns the error of
+// a write that couldn't be saved
+func Flush(ctx context.Context, d DB) error {
+	if flusher, ok := d.(Flusher); ok {
+		return flusher.Flush(ctx)
+	}
+	return nil
+}
+
+// ReconnectConfig tunes the health checks and reconnects of server databases
+type ReconnectConfig struct {
+	HealthInterval time.Duration // 健康检查(ping)的间隔
+	PingTimeout    time.Duration // 单次ping的超时
+	MinBackoff     time.Duration // 重连的初始间隔，每次失败后加倍
+	MaxBackoff     time.Duration // 重连的最大间隔
+	MaxOutage      time.Duration // 断开超过该时间后操作返回错误
+	MaxPending     int           // 断开期间缓存的最大写入批次数
+}
+
+// DefaultReconnectConfig rides out database restarts and failovers of a few minutes
+var DefaultReconnectConfig = ReconnectConfig{
+	HealthInterval: 30 * time.Second,
+	PingTimeout:    5 * time.Second,
+	MinBackoff:     time.Second,
+	MaxBackoff:     30 * time.Second,
+	MaxOutage:      5 * time.Minute,
+	MaxPending:     1000,
+}
+
+// RegisterServerDB registers the factory of a server database type (such as
+// Postgres or MySQL). Its connections are health checked and reconnected, so a
+// database blip doesn't fail a scan running for hours.
+func RegisterServerDB(dbType string, factory dbFactory) {
+	RegisterDB(dbType, func(dsn string) (DB, error) {
+		return NewResilientDB(func() (DB, error) { return factory(dsn) }, DefaultReconnectConfig)
+	})
+}
+
+// IsConnectionError reports whether err means the connection to the database
+// was lost, as opposed to an error of the statement
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.As(err, &netErr)
+}
+
+// resilientDB reconnects a server database with backoff when a health check or
+// an operation fails with a connection error. Writes during the outage are kept
+// in order and replayed after the reconnect, reads wait for the reconnect. An
+// outage longer than MaxOutage, or more than MaxPending pending writes, fails
+// the operations as without the wrapper. A pending write failing its replay was
+// already reported as saved, so it fails all later operations and the writes
+// pending after it are dropped, a delivery mark among them doesn't move past
+// the lost batch.
+type resilientDB struct {
+	open   func() (DB, error)
+	config ReconnectConfig
+
+	mu        sync.Mutex
+	inner     DB            // 连接正常且缓存的写入已重放时非nil
+	up        chan struct{} // 重连成功后关闭
+	downSince time.Time
+	lastErr   error
+	pending   []func(ctx context.Context, db DB) error
+	failed    error // 缓存的写入重放失败后非nil，之后的操作都返回该错误
+
+	wake chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewResilientDB opens a database with open and keeps it connected
+func NewResilientDB(open func() (DB, error), config ReconnectConfig) (DB, error) {
+	inner, err := open()
+	if err != nil {
+		return nil, err
+	}
+	r := &resilientDB{
+		open:   open,
+		config: config,
+		inner:  inner,
+		up:     make(chan struct{}),
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	close(r.up)
+	r.wg.Add(1)
+	go r.run()
+	return r, nil
+}
+
+// run checks the health of the connection and reconnects after failures
+func (r *resilientDB) run() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.config.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.healthCheck()
+		case <-r.wake:
+		}
+		if !r.connected() {
+			r.reconnect()
+		}
+	}
+}
+
+func (r *resilientDB) connected() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.inner != nil
+}
+
+// healthCheck pings the database, a failed ping drops the connection
+func (r *resilientDB) healthCheck() {
+	r.mu.Lock()
+	inner := r.inner
+	r.mu.Unlock()
+	pinger, ok := inner.(Pinger)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), r.config.PingTimeout)
+	defer cancel()
+	if err := pinger.Ping(ctx); err != nil {
+		r.disconnect(inner, fmt.Errorf("health check failed: %w", err))
+	}
+}
+
+// disconnect drops a failed connection, unless another caller already did
+func (r *resilientDB) disconnect(inner DB, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.inner != inner || inner == nil {
+		return
+	}
+	log.Warnf("Lost database connection, reconnecting: %v", err)
+	r.inner = nil
+	r.up = make(chan struct{})
+	r.downSince = time.Now()
+	r.lastErr = err
+	_ = inner.Close()
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// reconnect opens a new connection with exponential backoff and replays the
+// pending writes before the connection is used again
+func (r *resilientDB) reconnect() {
+	backoff := r.config.MinBackoff
+	for attempt := 1; ; attempt++ {
+		inner, err := r.open()
+		if err == nil {
+			var replayed int
+			if replayed, err = r.replay(inner); err == nil {
+				r.mu.Lock()
+				log.Infof("Database reconnected after %v and %d attempts, replayed %d pending batches",
+					time.Since(r.downSince).Round(time.Millisecond), attempt, replayed)
+				r.inner = inner
+				close(r.up)
+				r.mu.Unlock()
+				return
+			}
+			_ = inner.Close()
+		}
+		r.mu.Lock()
+		r.lastErr = err
+		r.mu.Unlock()
+		log.Warnf("Database reconnect attempt %d failed, retry in %v: %v", attempt, backoff, err)
+
+		select {
+		case <-r.done:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, r.config.MaxBackoff)
+	}
+}
+
+// replay writes the pending batches in order, a batch failing with a connection
+// error stays pending
+func (r *resilientDB) replay(inner DB) (int, error) {
+	replayed := 0
+	for {
+		r.mu.Lock()
+		if len(r.pending) == 0 {
+			r.mu.Unlock()
+			return replayed, nil
+		}
+		write := r.pending[0]
+		r.mu.Unlock()
+
+		if err := write(context.Background(), inner); err != nil {
+			if IsConnectionError(err) {
+				return replayed, err
+			}
+			// 语句本身的错误重试也不会成功，之后的写入依赖该批次，全部丢弃
+			r.mu.Lock()
+			r.failed = fmt.Errorf("failed to replay pending database write, %d later writes dropped: %w", len(r.pending)-1, err)
+			r.pending = nil
+			r.mu.Unlock()
+			log.Errorf("%v", r.failed)
+			return replayed, nil
+		}
+		r.mu.Lock()
+		r.pending = r.pending[1:]
+		r.mu.Unlock()
+		replayed++
+	}
+}
+
+// unavailable returns the error of an outage exceeding MaxOutage, nil otherwise
+func (r *resilientDB) unavailable() error {
+	if outage := time.Since(r.downSince); outage > r.config.MaxOutage {
+		return fmt.Errorf("database unavailable for %v: %w", outage.Round(time.Second), r.lastErr)
+	}
+	return nil
+}
+
+// write runs a write on the connection, or keeps it pending during an outage
+func (r *resilientDB) write(ctx context.Context, fn func(ctx context.Context, db DB) error) error {
+	for {
+		r.mu.Lock()
+		if r.failed != nil {
+			defer r.mu.Unlock()
+			return r.failed
+		}
+		inner := r.inner
+		if inner == nil {
+			defer r.mu.Unlock()
+			if err := r.unavailable(); err != nil {
+				return err
+			}
+			if len(r.pending) >= r.config.MaxPending {
+				return fmt.Errorf("database unavailable with %d pending batches: %w", len(r.pending), r.lastErr)
+			}
+			r.pending = append(r.pending, fn)
+			return nil
+		}
+		r.mu.Unlock()
+
+		err := fn(ctx, inner)
+		if !IsConnectionError(err) {
+			return err
+		}
+		r.disconnect(inner, err)
+	}
+}
+
+// read runs a read once connected, waiting for a reconnect during an outage
+func (r *resilientDB) read(ctx context.Context, fn func(db DB) error) error {
+	for {
+		r.mu.Lock()
+		inner, up, failed := r.inner, r.up, r.failed
+		r.mu.Unlock()
+
+		if failed != nil {
+			return failed
+		}
+		if inner != nil {
+			err := fn(inner)
+			if !IsConnectionError(err) {
+				return err
+			}
+			r.disconnect(inner, err)
+			continue
+		}
+		r.mu.Lock()
+		err := r.unavailable()
+		r.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		select {
+		case <-up:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.config.MinBackoff):
+		}
+	}
+}
+
+func (r *resilientDB) CreateTable(ctx context.Context, name string) error {
+	return r.read(ctx, func(db DB) error { return db.CreateTable(ctx, name) })
+}
+
+func (r *resilientDB) DropTable(ctx context.Context, name string) error {
+	return r.read(ctx, func(db DB) error { return db.DropTable(ctx, name) })
+}
+
+func (r *resilientDB) SaveEntries(ctx context.Context, fileInfos []object.FileInfo, tableName string) error {
+	// 调用方会复用批次的切片
+	batch := append([]object.FileInfo(nil), fileInfos...)
+	return r.write(ctx, func(ctx context.Context, db DB) error { return db.SaveEntries(ctx, batch, tableName) })
+}
+
+func (r *resilientDB) GetUniqueExtCount(ctx context.Context) (count int, err error) {
+	err = r.read(ctx, func(db DB) error {
+		count, err = db.GetUniqueExtCount(ctx)
+		return err
+	})
+	return count, err
+}
+
+func (r *resilientDB) GetExtStats(ctx context.Context) (stats []ExtStats, err error) {
+	err = r.read(ctx, func(db DB) error {
+		stats, err = db.GetExtStats(ctx)
+		return err
+	})
+	return stats, err
+}
+
+// ListEntries is not retried once entries were passed to fn, they would be passed twice
+func (r *resilientDB) ListEntries(ctx context.Context, tableName string, fn func(FileInfoData) error) error {
+	listed := false
+	return r.read(ctx, func(db DB) error {
+		err := db.ListEntries(ctx, tableName, func(entry FileInfoData) error {
+			listed = true
+			return fn(entry)
+		})
+		if listed && IsConnectionError(err) {
+			return fmt.Errorf("connection lost while listing entries: %v", err)
+		}
+		return err
+	})
+}
+
+func (r *resilientDB) QueryExactNewFiles(ctx context.Context, tableName string) (files []FileInfoData, err error) {
+	err = r.read(ctx, func(db DB) error {
+		files, err = db.QueryExactNewFiles(ctx, tableName)
+		return err
+	})
+	return files, err
+}
+
+func (r *resilientDB) QueryChangedFiles(ctx context.Context, tableName string) (files []FileInfoData, err error) {
+	err = r.read(ctx, func(db DB) error {
+		files, err = db.QueryChangedFiles(ctx, tableName)
+		return err
+	})
+	return files, err
+}
+
+func (r *resilientDB) CountDuplicatePaths(ctx context.Context, tableName string) (count int64, err error) {
+	err = r.read(ctx, func(db DB) error {
+		count, err = db.CountDuplicatePaths(ctx, tableName)
+		return err
+	})
+	return count, err
+}
+
+func (r *resilientDB) SaveHeartbeat(ctx context.Context, beat HeartbeatData) error {
+	return r.write(ctx, func(ctx context.Context, db DB) error { return db.SaveHeartbeat(ctx, beat) })
+}
+
+func (r *resilientDB) SaveDelivery(ctx context.Context, sink string, seq int64) error {
+	return r.write(ctx, func(ctx context.Context, db DB) error { return db.SaveDelivery(ctx, sink, seq) })
+}
+
+func (r *resilientDB) ListDeliveries(ctx context.Context) (deliveries map[string]int64, err error) {
+	err = r.read(ctx, func(db DB) error {
+		deliveries, err = db.ListDeliveries(ctx)
+		return err
+	})
+	return deliveries, err
+}
+
+func (r *resilientDB) SaveCursor(ctx context.Context, name string, position string) error {
+	return r.write(ctx, func(ctx context.Context, db DB) error { return db.SaveCursor(ctx, name, position) })
+}
+
+func (r *resilientDB) ListCursors(ctx context.Context) (cursors map[string]string, err error) {
+	err = r.read(ctx, func(db DB) error {
+		cursors, err = db.ListCursors(ctx)
+		return err
+	})
+	return cursors, err
+}
+
+func (r *resilientDB) SaveDuplicates(ctx context.Context, files []DuplicateFileData) error {
+	return r.write(ctx, func(ctx context.Context, db DB) error { return db.SaveDuplicates(ctx, files) })
+}
+
+func (r *resilientDB) Query(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, err error) {
+	err = r.read(ctx, func(db DB) error {
+		rows, err = db.Query(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+// Flush waits until the pending writes are replayed, it fails when a replay
+// failed or the outage exceeds MaxOutage
+func (r *resilientDB) Flush(ctx context.Context) error {
+	return r.read(ctx, func(db DB) error { return nil })
+}
+
+// Ping checks the current connection, it fails during an outage
+func (r *resilientDB) Ping(ctx context.Context) error {
+	r.mu.Lock()
+	inner, err := r.inner, r.lastErr
+	r.mu.Unlock()
+	if inner == nil {
+		return fmt.Errorf("database disconnected: %w", err)
+	}
+	pinger, ok := inner.(Pinger)
+	if !ok {
+		return nil
+	}
+	if err := pinger.Ping(ctx); err != nil {
+		r.disconnect(inner, err)
+		return err
+	}
+	return nil
+}
+
+// Close stops the reconnects, pending writes still waiting for the database are
+// lost, call Flush before to wait for them
+func (r *resilientDB) Close() error {
+	close(r.done)
+	r.wg.Wait()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.failed
+	if err == nil && len(r.pending) > 0 {
+		err = fmt.Errorf("database closed with %d pending batches not saved: %w", len(r.pending), r.lastErr)
+	}
+	if r.inner != nil {
+		if cerr := r.inner.Close(); err == nil {
+			err = cerr
+		}
+		r.inner = nil
+	}
+	return err
+}
@@ -0,0 +1,202 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"terrasync/log"
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// flakyServer 模拟可以断开的数据库服务器，记录保存的批次
+type flakyServer struct {
+	mu      sync.Mutex
+	down    bool
+	opened  int
+	batches []string
+}
+
+func (s *flakyServer) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+func (s *flakyServer) open() (DB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return nil, driver.ErrBadConn
+	}
+	s.opened++
+	return &flakyConn{server: s}, nil
+}
+
+// flakyConn 是到flakyServer的一个连接，服务器断开后所有操作返回连接错误
+type flakyConn struct {
+	DB
+	server *flakyServer
+}
+
+func (c *flakyConn) check() error {
+	if c.server.down {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+func (c *flakyConn) SaveEntries(ctx context.Context, fileInfos []object.FileInfo, tableName string) error {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	if err := c.check(); err != nil {
+		return err
+	}
+	c.server.batches = append(c.server.batches, tableName)
+	return nil
+}
+
+func (c *flakyConn) GetUniqueExtCount(ctx context.Context) (int, error) {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	return len(c.server.batches), c.check()
+}
+
+func (c *flakyConn) Ping(ctx context.Context) error {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	return c.check()
+}
+
+func (c *flakyConn) Close() error {
+	return nil
+}
+
+var testReconnectConfig = ReconnectConfig{
+	HealthInterval: 10 * time.Millisecond,
+	PingTimeout:    10 * time.Millisecond,
+	MinBackoff:     5 * time.Millisecond,
+	MaxBackoff:     20 * time.Millisecond,
+	MaxOutage:      time.Second,
+	MaxPending:     2,
+}
+
+// TestResilientDBReconnect 测试断开期间缓存写入，重连后按顺序重放
+func TestResilientDBReconnect(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	server := &flakyServer{}
+	db, err := NewResilientDB(server.open, testReconnectConfig)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	assert.NoError(t, db.SaveEntries(ctx, nil, "1"))
+	server.setDown(true)
+	assert.NoError(t, db.SaveEntries(ctx, nil, "2"))
+	assert.NoError(t, db.SaveEntries(ctx, nil, "3"))
+	// 缓存的批次超过上限
+	assert.ErrorContains(t, db.SaveEntries(ctx, nil, "4"), "2 pending batches")
+	assert.Error(t, db.(Pinger).Ping(ctx))
+
+	time.AfterFunc(50*time.Millisecond, func() { server.setDown(false) })
+	// 读取等待重连，此时缓存的批次已重放
+	count, err := db.GetUniqueExtCount(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, []string{"1", "2", "3"}, server.batches)
+	assert.Equal(t, 2, server.opened)
+	assert.NoError(t, db.Close())
+}
+
+// TestResilientDBHealthCheck 测试健康检查发现断开并在恢复后重连
+func TestResilientDBHealthCheck(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	server := &flakyServer{}
+	db, err := NewResilientDB(server.open, testReconnectConfig)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	r := db.(*resilientDB)
+	server.setDown(true)
+	assert.Eventually(t, func() bool { return !r.connected() }, time.Second, 5*time.Millisecond)
+	assert.Error(t, r.Ping(context.Background()))
+	server.setDown(false)
+	assert.Eventually(t, r.connected, time.Second, 5*time.Millisecond)
+	assert.NoError(t, r.Ping(context.Background()))
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, 2, server.opened)
+}
+
+// TestResilientDBOutage 测试断开超过时限后操作返回错误，关闭时报告未保存的批次
+func TestResilientDBOutage(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	server := &flakyServer{}
+	config := testReconnectConfig
+	config.MaxOutage = 30 * time.Millisecond
+	db, err := NewResilientDB(server.open, config)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	server.setDown(true)
+	assert.NoError(t, db.SaveEntries(ctx, nil, "1"))
+	_, err = db.GetUniqueExtCount(ctx)
+	assert.ErrorContains(t, err, "database unavailable")
+	assert.ErrorContains(t, db.SaveEntries(ctx, nil, "2"), "database unavailable")
+	assert.ErrorContains(t, db.Close(), "1 pending batches not saved")
+}
+
+// failingConn 的保存在指定表名上返回语句错误
+type failingConn struct {
+	*flakyConn
+	table string
+}
+
+func (c *failingConn) SaveEntries(ctx context.Context, fileInfos []object.FileInfo, tableName string) error {
+	if tableName == c.table {
+		return errors.New("duplicate key")
+	}
+	return c.flakyConn.SaveEntries(ctx, fileInfos, tableName)
+}
+
+func (c *failingConn) SaveDelivery(ctx context.Context, sink string, seq int64) error {
+	return c.flakyConn.SaveEntries(ctx, nil, fmt.Sprintf("%s@%d", sink, seq))
+}
+
+// TestResilientDBReplayFailure 测试重放失败时丢弃之后的写入，交付高水位不越过丢失的批次，之后的操作和Flush返回错误
+func TestResilientDBReplayFailure(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	server := &flakyServer{}
+	config := testReconnectConfig
+	config.MaxPending = 10
+	db, err := NewResilientDB(func() (DB, error) {
+		conn, err := server.open()
+		if err != nil {
+			return nil, err
+		}
+		return &failingConn{flakyConn: conn.(*flakyConn), table: "2"}, nil
+	}, config)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	server.setDown(true)
+	assert.NoError(t, db.SaveEntries(ctx, nil, "1"))
+	assert.NoError(t, db.SaveDelivery(ctx, "db", 1))
+	assert.NoError(t, db.SaveEntries(ctx, nil, "2"))
+	assert.NoError(t, db.SaveEntries(ctx, nil, "3"))
+	assert.NoError(t, db.SaveDelivery(ctx, "db", 3))
+	server.setDown(false)
+
+	assert.ErrorContains(t, Flush(ctx, db), "duplicate key")
+	assert.Equal(t, []string{"1", "db@1"}, server.batches)
+	assert.ErrorContains(t, db.SaveEntries(ctx, nil, "4"), "2 later writes dropped")
+	_, err = db.GetUniqueExtCount(ctx)
+	assert.Error(t, err)
+	assert.ErrorContains(t, db.Close(), "failed to replay pending database write")
+}
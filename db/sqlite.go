@@ -1,10 +1,10 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"path/filepath"
-	"terrasync/log"
 	"terrasync/object"
 	"time"
 
@@ -15,8 +15,8 @@ import (
 // sqlQuery: SQL查询语句
 // args: 查询参数
 // 返回: 文件信息列表和错误
-func (s *SQLiteDB) queryFileInfos(sqlQuery string, args ...interface{}) ([]FileInfoData, error) {
-	rows, err := s.db.Query(sqlQuery, args...)
+func (s *SQLiteDB) queryFileInfos(ctx context.Context, sqlQuery string, args ...interface{}) ([]FileInfoData, error) {
+	rows, err := s.db.QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -24,32 +24,10 @@ func (s *SQLiteDB) queryFileInfos(sqlQuery string, args ...interface{}) ([]FileI
 
 	var results []FileInfoData
 	for rows.Next() {
-		var path string
-		var size int64
-		var ext string
-		var ctime, mtime, atime time.Time
-		var perm int
-		var isSymlink, isDir, isRegular bool
-
-		err := rows.Scan(&path, &size, &ext, &ctime, &mtime, &atime, &perm, &isSymlink, &isDir, &isRegular)
+		fileInfo, err := scanFileInfo(rows)
 		if err != nil {
-			log.Errorf("failed to scan file row: %w", err)
-			continue
+			return nil, err
 		}
-
-		fileInfo := FileInfoData{
-			Key:       path,
-			Size:      size,
-			Ext:       ext,
-			CTime:     ctime,
-			MTime:     mtime,
-			ATime:     atime,
-			Perm:      perm,
-			IsSymlink: isSymlink,
-			IsDir:     isDir,
-			IsRegular: isRegular,
-		}
-
 		results = append(results, fileInfo)
 	}
 
@@ -60,6 +38,22 @@ func (s *SQLiteDB) queryFileInfos(sqlQuery string, args ...interface{}) ([]FileI
 	return results, nil
 }
 
+// scanFileInfo 读取一行文件信息，列的顺序为
+// path, size, ext, ctime, mtime, atime, perm, is_symlink, is_dir, is_regular_file, attrs
+func scanFileInfo(rows *sql.Rows) (FileInfoData, error) {
+	var fileInfo FileInfoData
+	var attrs sql.NullString
+	err := rows.Scan(&fileInfo.Key, &fileInfo.Size, &fileInfo.Ext, &fileInfo.CTime, &fileInfo.MTime, &fileInfo.ATime,
+		&fileInfo.Perm, &fileInfo.IsSymlink, &fileInfo.IsDir, &fileInfo.IsRegular, &attrs)
+	if err != nil {
+		return FileInfoData{}, fmt.Errorf("failed to scan file row: %w", err)
+	}
+	if fileInfo.Attrs, err = object.ParseFileAttrs(attrs.String); err != nil {
+		return FileInfoData{}, fmt.Errorf("failed to scan file row %s: %w", fileInfo.Key, err)
+	}
+	return fileInfo, nil
+}
+
 // SQLiteDB SQLite数据库实现
 type SQLiteDB struct {
 	db   *sql.DB
@@ -78,6 +72,7 @@ type FileInfoData struct {
 	IsSymlink bool
 	IsDir     bool
 	IsRegular bool
+	Attrs     object.FileAttrs // chattr标志及Windows文件属性
 }
 
 // ProcessFileInfo 处理文件信息，提取公共逻辑
@@ -92,15 +87,21 @@ func ProcessFileInfo(fileInfo object.FileInfo) FileInfoData {
 		ext = filepath.Ext(key)
 	}
 
-	// 提取其他属性
+	// 提取其他属性，时间统一以UTC保存，报告按--tz显示
 	size := fileInfo.Size()
-	mtime := fileInfo.MTime()
-	atime := fileInfo.ATime()
-	ctime := fileInfo.CTime()
+	mtime := fileInfo.MTime().UTC()
+	atime := fileInfo.ATime().UTC()
+	ctime := fileInfo.CTime().UTC()
 	isSymlink := fileInfo.IsSymlink()
 	perm := fileInfo.Perm()
 	isRegular := fileInfo.IsRegular()
 
+	// 读取失败的属性按无属性保存
+	var attrs object.FileAttrs
+	if provider, ok := fileInfo.(object.AttrsProvider); ok {
+		attrs, _ = provider.Attrs()
+	}
+
 	return FileInfoData{
 		Key:       key,
 		Size:      size,
@@ -112,6 +113,7 @@ func ProcessFileInfo(fileInfo object.FileInfo) FileInfoData {
 		IsSymlink: isSymlink,
 		IsDir:     isDir,
 		IsRegular: isRegular,
+		Attrs:     attrs,
 	}
 }
 
@@ -119,7 +121,8 @@ func ProcessFileInfo(fileInfo object.FileInfo) FileInfoData {
 func NewSQLiteDB(path string) (*SQLiteDB, error) {
 	sqldb := &SQLiteDB{path: path}
 	var err error
-	sqldb.db, err = sql.Open("sqlite", path)
+	// 心跳与批量保存并发写入同一数据库，等待锁释放而不是立即返回SQLITE_BUSY
+	sqldb.db, err = sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)")
 	if err != nil {
 		return nil, err
 	}
@@ -127,8 +130,8 @@ func NewSQLiteDB(path string) (*SQLiteDB, error) {
 	return sqldb, nil
 }
 
-// Init 初始化SQLite数据库连接
-func (s *SQLiteDB) CreateTable(name string) error {
+// CreateTable 创建文件信息表
+func (s *SQLiteDB) CreateTable(ctx context.Context, name string) error {
 	// 创建表结构
 	createTableSQL := fmt.Sprintf(`
 CREATE TABLE IF NOT EXISTS %s (
@@ -142,19 +145,86 @@ CREATE TABLE IF NOT EXISTS %s (
 	perm INTEGER,
 	is_symlink INTEGER,
 	is_dir INTEGER,
-	is_regular_file INTEGER
+	is_regular_file INTEGER,
+	attrs TEXT,
+	path_hash BLOB
 );`, name)
-	_, err := s.db.Exec(createTableSQL)
-	return err
+	if _, err := s.db.ExecContext(ctx, createTableSQL); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", name, err)
+	}
+	return s.addMissingColumns(ctx, name)
+}
+
+// addMissingColumns adds the columns introduced after a table was created, so
+// incremental scans keep working on the databases of older versions
+func (s *SQLiteDB) addMissingColumns(ctx context.Context, name string) error {
+	rows, err := s.db.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", name)
+	if err != nil {
+		return fmt.Errorf("failed to read columns of %s: %w", name, err)
+	}
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read columns of %s: %w", name, err)
+		}
+		columns[column] = true
+	}
+	rows.Close()
+
+	if !columns["attrs"] {
+		if _, err := s.db.ExecContext(ctx, "ALTER TABLE "+name+" ADD COLUMN attrs TEXT"); err != nil {
+			return fmt.Errorf("failed to add column attrs to %s: %w", name, err)
+		}
+	}
+	if !columns["path_hash"] {
+		if _, err := s.db.ExecContext(ctx, "ALTER TABLE "+name+" ADD COLUMN path_hash BLOB"); err != nil {
+			return fmt.Errorf("failed to add column path_hash to %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// indexPathHash indexes the path hashes of a table, adding the column to the
+// tables of older versions and hashing the rows saved by earlier scans or while
+// the path hash was disabled
+func (s *SQLiteDB) indexPathHash(ctx context.Context, name string) error {
+	if err := s.addMissingColumns(ctx, name); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_path_hash ON %s (path_hash)", name, name)); err != nil {
+		return fmt.Errorf("failed to index path hashes of %s: %w", name, err)
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET path_hash = %s(path) WHERE path_hash IS NULL", name, pathHashFunc)); err != nil {
+		return fmt.Errorf("failed to hash paths of %s: %w", name, err)
+	}
+	return nil
+}
+
+// pathJoin returns the join condition of the tables t and f on the path
+func pathJoin() string {
+	if pathHashEnabled.Load() {
+		return "t.path_hash = f.path_hash AND t.path = f.path"
+	}
+	return "t.path = f.path"
+}
+
+// DropTable 删除表
+func (s *SQLiteDB) DropTable(ctx context.Context, name string) error {
+	if _, err := s.db.ExecContext(ctx, "DROP TABLE IF EXISTS "+name); err != nil {
+		return fmt.Errorf("failed to drop table %s: %w", name, err)
+	}
+	return nil
 }
 
 // Query 执行SQL查询并返回结果行
-func (s *SQLiteDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
-	return s.db.Query(query, args...)
+func (s *SQLiteDB) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return s.db.QueryContext(ctx, query, args...)
 }
 
 // SaveEntries 批量保存多个文件信息到数据库
-func (s *SQLiteDB) SaveEntries(fileInfos []object.FileInfo, tableName string) error {
+func (s *SQLiteDB) SaveEntries(ctx context.Context, fileInfos []object.FileInfo, tableName string) error {
 	if len(fileInfos) == 0 {
 		return nil
 	}
@@ -164,74 +234,325 @@ func (s *SQLiteDB) SaveEntries(fileInfos []object.FileInfo, tableName string) er
 		tableName = "file_entries"
 	}
 
-	// 准备批量插入语句
+	// 准备批量插入语句，启用路径哈希时同时保存path_hash
+	hashed := pathHashEnabled.Load()
+	columns, values := "", "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	if hashed {
+		columns, values = ", path_hash", "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	}
 	query := `INSERT INTO ` + tableName + ` (
-	path, size, ext, ctime, mtime, atime, perm, is_symlink, is_dir, is_regular_file
+	path, size, ext, ctime, mtime, atime, perm, is_symlink, is_dir, is_regular_file, attrs` + columns + `
 	) VALUES `
 
 	// 构建参数和值部分
-	params := make([]interface{}, 0, len(fileInfos)*10)
+	params := make([]interface{}, 0, len(fileInfos)*12)
 	for i, fileInfo := range fileInfos {
 		if i > 0 {
 			query += ","
 		}
-		query += "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+		query += values
 
 		// 调用公共函数处理文件信息
 		fileData := ProcessFileInfo(fileInfo)
 
 		params = append(params,
-			fileData.Key, fileData.Size, fileData.Ext, fileData.CTime, fileData.MTime, fileData.ATime, fileData.Perm, fileData.IsSymlink, fileData.IsDir, fileData.IsRegular)
+			fileData.Key, fileData.Size, fileData.Ext, fileData.CTime, fileData.MTime, fileData.ATime, fileData.Perm, fileData.IsSymlink, fileData.IsDir, fileData.IsRegular, fileData.Attrs.String())
+		if hashed {
+			params = append(params, PathHash(fileData.Key))
+		}
 	}
 
 	// 执行批量插入
-	_, err := s.db.Exec(query, params...)
-	return err
+	if _, err := s.db.ExecContext(ctx, query, params...); err != nil {
+		return fmt.Errorf("failed to insert %d entries into %s: %w", len(fileInfos), tableName, err)
+	}
+	return nil
 }
 
 // GetUniqueExtCount 获取数据库中不重复的文件扩展名总数
-func (s *SQLiteDB) GetUniqueExtCount() (int, error) {
+func (s *SQLiteDB) GetUniqueExtCount(ctx context.Context) (int, error) {
 	var count int
-	err := s.db.QueryRow("SELECT COUNT(DISTINCT ext) FROM file_entries").Scan(&count)
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(DISTINCT ext) FROM file_entries").Scan(&count)
 	if err != nil {
-		return 0, err
+		return 0, fmt.Errorf("failed to count file types: %w", err)
 	}
 	return count, nil
 }
 
-func (s *SQLiteDB) QueryExactNewFiles(tableName string) []FileInfoData {
+// GetExtStats 按扩展名分组统计file_entries表中的文件数及总字节数，按总字节数降序
+func (s *SQLiteDB) GetExtStats(ctx context.Context) ([]ExtStats, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT ext, COUNT(*), COALESCE(SUM(size), 0) AS bytes
+        FROM file_entries WHERE NOT is_dir
+        GROUP BY ext ORDER BY bytes DESC, ext`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query extension stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []ExtStats
+	for rows.Next() {
+		var st ExtStats
+		if err := rows.Scan(&st.Ext, &st.Files, &st.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to read extension stats: %w", err)
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
+
+// ListEntries 按保存顺序遍历表中的所有文件，fn返回错误时停止遍历并返回该错误
+func (s *SQLiteDB) ListEntries(ctx context.Context, tableName string, fn func(FileInfoData) error) error {
+	if tableName == "" {
+		tableName = "file_entries"
+	}
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT path, size, ext, ctime, mtime, atime, perm, is_symlink, is_dir, is_regular_file, attrs
+        FROM `+tableName+` ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		fileInfo, err := scanFileInfo(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(fileInfo); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return nil
+}
+
+// QueryExactNewFiles 查询在临时表中但不在file_entries表中的文件
+func (s *SQLiteDB) QueryExactNewFiles(ctx context.Context, tableName string) ([]FileInfoData, error) {
+	if pathHashEnabled.Load() {
+		if err := s.indexPathHash(ctx, "file_entries"); err != nil {
+			return nil, err
+		}
+	}
 	// 构建SQL查询，查找在临时表中但不在file_entries表中的文件
 	sqlQuery := fmt.Sprintf(`
-        SELECT t.path, t.size, t.ext, t.ctime, t.mtime, t.atime, t.perm, t.is_symlink, t.is_dir, t.is_regular_file
+        SELECT t.path, t.size, t.ext, t.ctime, t.mtime, t.atime, t.perm, t.is_symlink, t.is_dir, t.is_regular_file, t.attrs
         FROM %s t
-        LEFT JOIN file_entries f ON t.path = f.path
-        WHERE f.path IS NULL`, tableName)
+        LEFT JOIN file_entries f ON %s
+        WHERE f.path IS NULL`, tableName, pathJoin())
 
-	results, err := s.queryFileInfos(sqlQuery)
+	results, err := s.queryFileInfos(ctx, sqlQuery)
 	if err != nil {
-		log.Errorf("Failed to query exact new files: %v", err)
-		return []FileInfoData{}
+		return nil, fmt.Errorf("failed to query exact new files: %w", err)
 	}
 
-	return results
+	return results, nil
 }
 
-func (s *SQLiteDB) QueryChangedFiles(tableName string) []FileInfoData {
+// QueryChangedFiles 查询ctime/mtime与file_entries表中不同的文件
+func (s *SQLiteDB) QueryChangedFiles(ctx context.Context, tableName string) ([]FileInfoData, error) {
+	if pathHashEnabled.Load() {
+		if err := s.indexPathHash(ctx, "file_entries"); err != nil {
+			return nil, err
+		}
+	}
 	// 查询变更文件：存在于file_entries表中且ctime/mtime与临时表中不同的文件
 	sqlQuery := fmt.Sprintf(`
-        SELECT t.path, t.size, t.ext, t.ctime, t.mtime, t.atime, t.perm, t.is_symlink, t.is_dir, t.is_regular_file 
+        SELECT t.path, t.size, t.ext, t.ctime, t.mtime, t.atime, t.perm, t.is_symlink, t.is_dir, t.is_regular_file, t.attrs 
         FROM %s t
-        JOIN file_entries f ON t.path = f.path
+        JOIN file_entries f ON %s
         WHERE t.ctime != f.ctime 
-           OR t.mtime != f.mtime`, tableName)
+           OR t.mtime != f.mtime`, tableName, pathJoin())
+
+	results, err := s.queryFileInfos(ctx, sqlQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changed files: %w", err)
+	}
+
+	return results, nil
+}
+
+// CountDuplicatePaths 统计表中同一路径多保存的行数，启用路径哈希时按哈希分组
+func (s *SQLiteDB) CountDuplicatePaths(ctx context.Context, tableName string) (int64, error) {
+	if tableName == "" {
+		tableName = "file_entries"
+	}
+	group := "path"
+	if pathHashEnabled.Load() {
+		if err := s.indexPathHash(ctx, tableName); err != nil {
+			return 0, err
+		}
+		group = "path_hash, path"
+	}
+	var count int64
+	if err := s.db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT COALESCE(SUM(n - 1), 0) FROM (SELECT COUNT(*) AS n FROM %s GROUP BY %s HAVING COUNT(*) > 1)", tableName, group),
+	).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count duplicate paths in %s: %w", tableName, err)
+	}
+	return count, nil
+}
+
+// SaveHeartbeat 保存一条任务心跳到heartbeats表
+func (s *SQLiteDB) SaveHeartbeat(ctx context.Context, beat HeartbeatData) error {
+	if _, err := s.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS heartbeats (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	time DATETIME,
+	entries INTEGER,
+	bytes INTEGER,
+	delta_entries INTEGER,
+	delta_bytes INTEGER,
+	entries_per_sec REAL,
+	in_flight INTEGER,
+	stalled INTEGER
+);`); err != nil {
+		return fmt.Errorf("failed to create table heartbeats: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO heartbeats (
+	time, entries, bytes, delta_entries, delta_bytes, entries_per_sec, in_flight, stalled
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		beat.Time.UTC(), beat.Entries, beat.Bytes, beat.DeltaEntries, beat.DeltaBytes, beat.EntriesPerSec, beat.InFlight, beat.Stalled); err != nil {
+		return fmt.Errorf("failed to save heartbeat: %w", err)
+	}
+	return nil
+}
+
+// createDeliveries 创建deliveries表，每个sink一行
+func (s *SQLiteDB) createDeliveries(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS deliveries (
+	sink TEXT PRIMARY KEY,
+	seq INTEGER,
+	time DATETIME
+);`); err != nil {
+		return fmt.Errorf("failed to create table deliveries: %w", err)
+	}
+	return nil
+}
+
+// SaveDelivery 保存sink的送达高水位
+func (s *SQLiteDB) SaveDelivery(ctx context.Context, sink string, seq int64) error {
+	if err := s.createDeliveries(ctx); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO deliveries (sink, seq, time) VALUES (?, ?, ?)
+	ON CONFLICT(sink) DO UPDATE SET seq = excluded.seq, time = excluded.time`,
+		sink, seq, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to save delivery of %s: %w", sink, err)
+	}
+	return nil
+}
+
+// ListDeliveries 读取各sink的送达高水位
+func (s *SQLiteDB) ListDeliveries(ctx context.Context) (map[string]int64, error) {
+	if err := s.createDeliveries(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, "SELECT sink, seq FROM deliveries")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deliveries: %w", err)
+	}
+	defer rows.Close()
+	deliveries := make(map[string]int64)
+	for rows.Next() {
+		var sink string
+		var seq int64
+		if err := rows.Scan(&sink, &seq); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery: %w", err)
+		}
+		deliveries[sink] = seq
+	}
+	return deliveries, rows.Err()
+}
+
+// createCursors 创建cursors表，每个变更日志(如卷)一行
+func (s *SQLiteDB) createCursors(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS cursors (
+	name TEXT PRIMARY KEY,
+	position TEXT,
+	time DATETIME
+);`); err != nil {
+		return fmt.Errorf("failed to create table cursors: %w", err)
+	}
+	return nil
+}
 
-	results, err := s.queryFileInfos(sqlQuery)
+// SaveCursor 保存变更日志下次读取的位置
+func (s *SQLiteDB) SaveCursor(ctx context.Context, name string, position string) error {
+	if err := s.createCursors(ctx); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO cursors (name, position, time) VALUES (?, ?, ?)
+	ON CONFLICT(name) DO UPDATE SET position = excluded.position, time = excluded.time`,
+		name, position, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to save cursor of %s: %w", name, err)
+	}
+	return nil
+}
+
+// ListCursors 读取各变更日志保存的位置
+func (s *SQLiteDB) ListCursors(ctx context.Context) (map[string]string, error) {
+	if err := s.createCursors(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, "SELECT name, position FROM cursors")
 	if err != nil {
-		log.Errorf("failed to query changed files: %v", err)
-		return []FileInfoData{}
+		return nil, fmt.Errorf("failed to query cursors: %w", err)
 	}
+	defer rows.Close()
+	cursors := make(map[string]string)
+	for rows.Next() {
+		var name, position string
+		if err := rows.Scan(&name, &position); err != nil {
+			return nil, fmt.Errorf("failed to scan cursor: %w", err)
+		}
+		cursors[name] = position
+	}
+	return cursors, rows.Err()
+}
+
+// SaveDuplicates 用一次查重的结果替换duplicate_files表中的内容
+func (s *SQLiteDB) SaveDuplicates(ctx context.Context, files []DuplicateFileData) error {
+	if _, err := s.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS duplicate_files (
+	group_id INTEGER,
+	hash TEXT,
+	size INTEGER,
+	path TEXT
+)`); err != nil {
+		return fmt.Errorf("failed to create table duplicate_files: %w", err)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to save duplicate files: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "DELETE FROM duplicate_files"); err != nil {
+		return fmt.Errorf("failed to clear duplicate files: %w", err)
+	}
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO duplicate_files (group_id, hash, size, path) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("failed to save duplicate files: %w", err)
+	}
+	defer stmt.Close()
+	for _, file := range files {
+		if _, err := stmt.ExecContext(ctx, file.Group, file.Hash, file.Size, file.Path); err != nil {
+			return fmt.Errorf("failed to save duplicate file %s: %w", file.Path, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save duplicate files: %w", err)
+	}
+	return nil
+}
 
-	return results
+// Ping 检查数据库连接
+func (s *SQLiteDB) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
 }
 
 // Close 关闭数据库连接
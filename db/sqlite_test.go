@@ -0,0 +1,244 @@
+package db
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// entry 是保存到文件表的最小文件信息
+type entry struct {
+	object.FileInfo
+	key   string
+	mtime time.Time
+}
+
+func (e entry) Key() string       { return e.key }
+func (e entry) Size() int64       { return 1 }
+func (e entry) MTime() time.Time  { return e.mtime }
+func (e entry) CTime() time.Time  { return e.mtime }
+func (e entry) ATime() time.Time  { return e.mtime }
+func (e entry) Perm() os.FileMode { return 0644 }
+func (e entry) IsDir() bool       { return false }
+func (e entry) IsSymlink() bool   { return false }
+func (e entry) IsRegular() bool   { return true }
+
+// keys 返回查询结果的路径
+func keys(files []FileInfoData) []string {
+	var result []string
+	for _, file := range files {
+		result = append(result, file.Key)
+	}
+	return result
+}
+
+// TestPathHash 测试按路径哈希联合查询新增及变化的文件，之前未保存哈希的行在查询时补上
+func TestPathHash(t *testing.T) {
+	defer SetPathHash(PathHashNone)
+	ctx := context.Background()
+	old := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	now := old.Add(time.Hour)
+
+	for _, algorithm := range []string{PathHashNone, PathHashXXH128} {
+		t.Run(algorithm, func(t *testing.T) {
+			s, err := NewSQLiteDB(filepath.Join(t.TempDir(), "index.db"))
+			assert.NoError(t, err)
+			defer s.Close()
+
+			// 上次扫描未启用路径哈希，表由没有path_hash列的旧版本创建
+			assert.NoError(t, SetPathHash(PathHashNone))
+			assert.NoError(t, s.CreateTable(ctx, "file_entries"))
+			_, err = s.db.ExecContext(ctx, "ALTER TABLE file_entries DROP COLUMN path_hash")
+			assert.NoError(t, err)
+			assert.NoError(t, s.SaveEntries(ctx, []object.FileInfo{
+				entry{key: "/same.txt", mtime: old},
+				entry{key: "/changed.txt", mtime: old},
+				entry{key: "/deleted.txt", mtime: old},
+			}, ""))
+
+			assert.NoError(t, SetPathHash(algorithm))
+			assert.NoError(t, s.CreateTable(ctx, "tmp_scan"))
+			assert.NoError(t, s.SaveEntries(ctx, []object.FileInfo{
+				entry{key: "/same.txt", mtime: old},
+				entry{key: "/changed.txt", mtime: now},
+				entry{key: "/new.txt", mtime: now},
+			}, "tmp_scan"))
+
+			newFiles, err := s.QueryExactNewFiles(ctx, "tmp_scan")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"/new.txt"}, keys(newFiles))
+			changedFiles, err := s.QueryChangedFiles(ctx, "tmp_scan")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"/changed.txt"}, keys(changedFiles))
+
+			if algorithm == PathHashNone {
+				return
+			}
+			var unhashed int
+			assert.NoError(t, s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM file_entries WHERE path_hash IS NULL").Scan(&unhashed))
+			assert.Equal(t, 0, unhashed)
+			var hash []byte
+			assert.NoError(t, s.db.QueryRowContext(ctx, "SELECT path_hash FROM file_entries WHERE path = '/same.txt'").Scan(&hash))
+			assert.Equal(t, "070cb673521690c176fdc80427e9ecac", hex.EncodeToString(hash))
+			var plan strings.Builder
+			rows, err := s.Query(ctx, "EXPLAIN QUERY PLAN SELECT t.path FROM tmp_scan t LEFT JOIN file_entries f ON "+pathJoin()+" WHERE f.path IS NULL")
+			assert.NoError(t, err)
+			for rows.Next() {
+				var id, parent, unused int
+				var detail string
+				assert.NoError(t, rows.Scan(&id, &parent, &unused, &detail))
+				plan.WriteString(detail + "\n")
+			}
+			rows.Close()
+			assert.Contains(t, plan.String(), "idx_file_entries_path_hash")
+		})
+	}
+
+	assert.ErrorContains(t, SetPathHash("xxh3"), "unsupported path hash")
+	assert.Len(t, PathHash("/a"), 16)
+	// XXH3-128的参考值，已保存的path_hash列依赖它不变
+	assert.Equal(t, "3c85f2677ca9d34a18971bdcb320cd58", hex.EncodeToString(PathHash("/home/alice/projects/report.txt")))
+}
+
+// TestCountDuplicatePaths 测试统计同一路径多保存的行数
+func TestCountDuplicatePaths(t *testing.T) {
+	defer SetPathHash(PathHashNone)
+	ctx := context.Background()
+
+	for _, algorithm := range []string{PathHashNone, PathHashXXH128} {
+		t.Run(algorithm, func(t *testing.T) {
+			assert.NoError(t, SetPathHash(algorithm))
+			s, err := NewSQLiteDB(filepath.Join(t.TempDir(), "index.db"))
+			assert.NoError(t, err)
+			defer s.Close()
+
+			assert.NoError(t, s.CreateTable(ctx, "file_entries"))
+			count, err := s.CountDuplicatePaths(ctx, "")
+			assert.NoError(t, err)
+			assert.Equal(t, int64(0), count)
+
+			// 重放的批次再次保存了/a.txt和/b.txt，/a.txt保存了三次
+			assert.NoError(t, s.SaveEntries(ctx, []object.FileInfo{entry{key: "/a.txt"}, entry{key: "/b.txt"}, entry{key: "/c.txt"}}, ""))
+			assert.NoError(t, s.SaveEntries(ctx, []object.FileInfo{entry{key: "/a.txt"}, entry{key: "/b.txt"}}, ""))
+			assert.NoError(t, s.SaveEntries(ctx, []object.FileInfo{entry{key: "/a.txt"}}, ""))
+			count, err = s.CountDuplicatePaths(ctx, "file_entries")
+			assert.NoError(t, err)
+			assert.Equal(t, int64(3), count)
+		})
+	}
+}
+
+// sizedEntry 是指定大小的文件或目录
+type sizedEntry struct {
+	entry
+	size int64
+	dir  bool
+}
+
+func (e sizedEntry) Size() int64     { return e.size }
+func (e sizedEntry) IsDir() bool     { return e.dir }
+func (e sizedEntry) IsRegular() bool { return !e.dir }
+
+// TestGetExtStats 测试按扩展名分组统计文件数及字节数，目录不计入，容量相同时按扩展名排序
+func TestGetExtStats(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewSQLiteDB(filepath.Join(t.TempDir(), "index.db"))
+	assert.NoError(t, err)
+	defer s.Close()
+
+	assert.NoError(t, s.CreateTable(ctx, "file_entries"))
+	assert.NoError(t, s.SaveEntries(ctx, []object.FileInfo{
+		sizedEntry{entry: entry{key: "/docs"}, dir: true},
+		sizedEntry{entry: entry{key: "/docs/a.pdf"}, size: 300},
+		sizedEntry{entry: entry{key: "/docs/b.pdf"}, size: 100},
+		sizedEntry{entry: entry{key: "/docs/c.txt"}, size: 10},
+		sizedEntry{entry: entry{key: "/docs/README"}, size: 400},
+	}, ""))
+
+	stats, err := s.GetExtStats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []ExtStats{
+		{Ext: "", Files: 1, Bytes: 400},
+		{Ext: ".pdf", Files: 2, Bytes: 400},
+		{Ext: ".txt", Files: 1, Bytes: 10},
+	}, stats)
+}
+
+// TestSaveDuplicates 测试保存查重结果，再次保存时替换之前的结果
+func TestSaveDuplicates(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewSQLiteDB(filepath.Join(t.TempDir(), "index.db"))
+	assert.NoError(t, err)
+	defer s.Close()
+
+	assert.NoError(t, s.SaveDuplicates(ctx, []DuplicateFileData{
+		{Group: 1, Hash: "aa", Size: 10, Path: "/a/x"},
+		{Group: 1, Hash: "aa", Size: 10, Path: "/b/x"},
+	}))
+	files := []DuplicateFileData{
+		{Group: 1, Hash: "bb", Size: 20, Path: "/a/y"},
+		{Group: 1, Hash: "bb", Size: 20, Path: "/c/y"},
+		{Group: 1, Hash: "bb", Size: 20, Path: "/d/y"},
+	}
+	assert.NoError(t, s.SaveDuplicates(ctx, files))
+
+	rows, err := s.Query(ctx, "SELECT group_id, hash, size, path FROM duplicate_files ORDER BY path")
+	assert.NoError(t, err)
+	defer rows.Close()
+	var saved []DuplicateFileData
+	for rows.Next() {
+		var file DuplicateFileData
+		assert.NoError(t, rows.Scan(&file.Group, &file.Hash, &file.Size, &file.Path))
+		saved = append(saved, file)
+	}
+	assert.NoError(t, rows.Err())
+	assert.Equal(t, files, saved)
+}
+
+// BenchmarkQueryExactNewFiles 比较按路径字符串及路径哈希联合查询的耗时，
+// 路径为较长的深层目录，TERRASYNC_BENCH_ROWS调整行数
+func BenchmarkQueryExactNewFiles(b *testing.B) {
+	defer SetPathHash(PathHashNone)
+	ctx := context.Background()
+	rows := 100000
+	if n, err := fmt.Sscan(os.Getenv("TERRASYNC_BENCH_ROWS"), &rows); n != 1 || err != nil {
+		rows = 100000
+	}
+	prefix := "/projects/" + strings.Repeat("department/team/subproject/", 6)
+
+	for _, algorithm := range []string{PathHashNone, PathHashXXH128} {
+		b.Run(algorithm, func(b *testing.B) {
+			assert.NoError(b, SetPathHash(algorithm))
+			s, err := NewSQLiteDB(filepath.Join(b.TempDir(), "index.db"))
+			assert.NoError(b, err)
+			defer s.Close()
+			assert.NoError(b, s.CreateTable(ctx, "file_entries"))
+			assert.NoError(b, s.CreateTable(ctx, "tmp_scan"))
+			batch := make([]object.FileInfo, 0, 1000)
+			for i := 0; i < rows; i++ {
+				key := fmt.Sprintf("%s%08d/file.dat", prefix, i)
+				batch = append(batch, entry{key: key})
+				if len(batch) == cap(batch) || i == rows-1 {
+					assert.NoError(b, s.SaveEntries(ctx, batch, "file_entries"))
+					assert.NoError(b, s.SaveEntries(ctx, batch, "tmp_scan"))
+					batch = batch[:0]
+				}
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				files, err := s.QueryExactNewFiles(ctx, "tmp_scan")
+				assert.NoError(b, err)
+				assert.Empty(b, files)
+			}
+		})
+	}
+}
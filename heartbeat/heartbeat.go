@@ -0,0 +1,223 @@
+package heartbeat
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"terrasync/log"
+	"time"
+)
+
+// Config holds the heartbeat and stall detection parameters
+type Config struct {
+	Interval     time.Duration // 心跳周期，<=0 表示不启用
+	StallTimeout time.Duration // 超过该时间没有进展视为卡住，<=0 表示不检测
+	AbortStalled bool          // 中止卡住的操作(如挂起的NFS挂载上的列举)，任务继续处理其他目录
+}
+
+// Beat is the progress reported by one heartbeat
+type Beat struct {
+	Time          time.Time
+	Entries       int64 // 累计处理的条目数
+	Bytes         int64 // 累计处理的字节数
+	DeltaEntries  int64 // 本周期处理的条目数
+	DeltaBytes    int64 // 本周期处理的字节数
+	EntriesPerSec float64
+	InFlight      int           // 正在进行的操作数
+	Stalled       int           // 卡住的操作数
+	Idle          time.Duration // 距上次进展的时间
+}
+
+// Op is one tracked operation, e.g. the listing of a directory
+type Op struct {
+	name       string
+	start      time.Time
+	lastActive atomic.Int64 // UnixNano
+	warned     bool         // 由monitor goroutine访问
+	abortOnce  sync.Once
+	aborted    chan struct{}
+}
+
+// Monitor emits periodic heartbeats with progress deltas and detects operations
+// that make no progress, which usually means a hung network mount
+type Monitor struct {
+	cfg  Config
+	sink func(Beat)
+
+	entries atomic.Int64
+	bytes   atomic.Int64
+
+	mu  sync.Mutex
+	ops map[*Op]struct{}
+
+	last         Beat
+	lastProgress time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewMonitor creates a monitor calling sink with every heartbeat, sink may be nil
+func NewMonitor(cfg Config, sink func(Beat)) *Monitor {
+	return &Monitor{
+		cfg:          cfg,
+		sink:         sink,
+		ops:          make(map[*Op]struct{}),
+		lastProgress: time.Now(),
+	}
+}
+
+// Start launches the heartbeat loop
+func (m *Monitor) Start() {
+	if m == nil || m.cfg.Interval <= 0 {
+		return
+	}
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	m.last.Time = time.Now()
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case now := <-ticker.C:
+				m.beat(now)
+			}
+		}
+	}()
+}
+
+// Stop stops the heartbeat loop and emits a final heartbeat
+func (m *Monitor) Stop() {
+	if m == nil || m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+	m.stop = nil
+	m.beat(time.Now())
+}
+
+// Add records progress that is not tied to a tracked operation
+func (m *Monitor) Add(entries, bytes int64) {
+	if m == nil {
+		return
+	}
+	m.entries.Add(entries)
+	m.bytes.Add(bytes)
+}
+
+// Begin starts tracking an operation, End must be called when it finishes
+func (m *Monitor) Begin(name string) *Op {
+	if m == nil {
+		return nil
+	}
+	now := time.Now()
+	op := &Op{name: name, start: now, aborted: make(chan struct{})}
+	op.lastActive.Store(now.UnixNano())
+	m.mu.Lock()
+	m.ops[op] = struct{}{}
+	m.mu.Unlock()
+	return op
+}
+
+// End stops tracking the operation
+func (m *Monitor) End(op *Op) {
+	if m == nil || op == nil {
+		return
+	}
+	m.mu.Lock()
+	delete(m.ops, op)
+	m.mu.Unlock()
+}
+
+// Progress records progress made by the operation
+func (m *Monitor) Progress(op *Op, entries, bytes int64) {
+	if m == nil {
+		return
+	}
+	m.Add(entries, bytes)
+	if op != nil {
+		op.lastActive.Store(time.Now().UnixNano())
+	}
+}
+
+// Aborted returns a channel closed when the operation is aborted after stalling.
+// A nil operation is never aborted.
+func (op *Op) Aborted() <-chan struct{} {
+	if op == nil {
+		return nil
+	}
+	return op.aborted
+}
+
+// Last returns the most recent heartbeat
+func (m *Monitor) Last() Beat {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+// beat emits one heartbeat and checks for stalled operations
+func (m *Monitor) beat(now time.Time) {
+	entries, bytes := m.entries.Load(), m.bytes.Load()
+
+	m.mu.Lock()
+	prev := m.last
+	beat := Beat{
+		Time:         now,
+		Entries:      entries,
+		Bytes:        bytes,
+		DeltaEntries: entries - prev.Entries,
+		DeltaBytes:   bytes - prev.Bytes,
+		InFlight:     len(m.ops),
+	}
+	if elapsed := now.Sub(prev.Time).Seconds(); elapsed > 0 {
+		beat.EntriesPerSec = float64(beat.DeltaEntries) / elapsed
+	}
+	if beat.DeltaEntries > 0 || beat.DeltaBytes > 0 {
+		m.lastProgress = now
+	}
+	beat.Idle = now.Sub(m.lastProgress)
+
+	// 单个操作长时间没有进展时告警，其他worker的进展不能掩盖挂起的目录
+	var stalled []*Op
+	if m.cfg.StallTimeout > 0 {
+		for op := range m.ops {
+			if now.Sub(time.Unix(0, op.lastActive.Load())) >= m.cfg.StallTimeout {
+				stalled = append(stalled, op)
+			}
+		}
+	}
+	beat.Stalled = len(stalled)
+	m.last = beat
+	m.mu.Unlock()
+
+	log.Infof("Heartbeat: %d entries (+%d, %.0f/s), %d bytes (+%d), %d in flight, %d stalled",
+		beat.Entries, beat.DeltaEntries, beat.EntriesPerSec, beat.Bytes, beat.DeltaBytes, beat.InFlight, beat.Stalled)
+	if m.cfg.StallTimeout > 0 && beat.Idle >= m.cfg.StallTimeout && beat.InFlight > 0 {
+		log.Warnf("No progress for %v with %d operations in flight, the storage may be hung", beat.Idle.Round(time.Second), beat.InFlight)
+	}
+
+	sort.Slice(stalled, func(i, j int) bool { return stalled[i].start.Before(stalled[j].start) })
+	for _, op := range stalled {
+		idle := now.Sub(time.Unix(0, op.lastActive.Load())).Round(time.Second)
+		if !op.warned {
+			op.warned = true
+			log.Warnf("%s made no progress for %v (started %v ago)", op.name, idle, now.Sub(op.start).Round(time.Second))
+		}
+		if m.cfg.AbortStalled {
+			op.abortOnce.Do(func() {
+				log.Errorf("Aborting %s after no progress for %v", op.name, idle)
+				close(op.aborted)
+			})
+		}
+	}
+
+	if m.sink != nil {
+		m.sink(beat)
+	}
+}
@@ -0,0 +1,97 @@
+package heartbeat
+
+import (
+	"testing"
+	"time"
+
+	"terrasync/log"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func init() {
+	log.Log = zap.NewNop().Sugar()
+}
+
+// TestBeatDelta 测试心跳记录累计值和周期增量
+func TestBeatDelta(t *testing.T) {
+	var beats []Beat
+	m := NewMonitor(Config{Interval: time.Second}, func(b Beat) { beats = append(beats, b) })
+	start := time.Now()
+	m.last.Time = start
+
+	m.Add(10, 1000)
+	m.beat(start.Add(time.Second))
+	m.Add(5, 500)
+	m.beat(start.Add(2 * time.Second))
+
+	assert.Len(t, beats, 2)
+	assert.Equal(t, int64(10), beats[0].DeltaEntries)
+	assert.Equal(t, 10.0, beats[0].EntriesPerSec)
+	assert.Equal(t, int64(15), beats[1].Entries)
+	assert.Equal(t, int64(5), beats[1].DeltaEntries)
+	assert.Equal(t, int64(500), beats[1].DeltaBytes)
+	assert.Equal(t, beats[1], m.Last())
+}
+
+// TestStall 测试没有进展的操作被检测为卡住，并按配置中止
+func TestStall(t *testing.T) {
+	tests := []struct {
+		name    string
+		abort   bool
+		aborted bool
+	}{
+		{"只告警", false, false},
+		{"告警并中止", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMonitor(Config{Interval: time.Second, StallTimeout: time.Minute, AbortStalled: tt.abort}, nil)
+			hung := m.Begin("Listing /hung")
+			busy := m.Begin("Listing /busy")
+			now := time.Now()
+
+			// busy在超时前有进展
+			m.Progress(busy, 1, 0)
+			busy.lastActive.Store(now.Add(30 * time.Second).UnixNano())
+			m.beat(now.Add(30 * time.Second))
+			assert.Equal(t, 0, m.Last().Stalled)
+			assert.Equal(t, 2, m.Last().InFlight)
+
+			m.beat(now.Add(time.Minute + time.Second))
+			assert.Equal(t, 1, m.Last().Stalled)
+
+			select {
+			case <-hung.Aborted():
+				assert.True(t, tt.aborted)
+			default:
+				assert.False(t, tt.aborted)
+			}
+			select {
+			case <-busy.Aborted():
+				t.Fatal("busy operation aborted")
+			default:
+			}
+
+			// 结束的操作不再跟踪
+			m.End(hung)
+			m.End(busy)
+			m.beat(now.Add(2 * time.Minute))
+			assert.Equal(t, 0, m.Last().InFlight)
+			assert.Equal(t, 0, m.Last().Stalled)
+		})
+	}
+}
+
+// TestNilMonitor 测试未启用心跳时的nil监控器
+func TestNilMonitor(t *testing.T) {
+	var m *Monitor
+	m.Start()
+	op := m.Begin("Listing /")
+	m.Progress(op, 1, 1)
+	m.End(op)
+	m.Stop()
+	assert.Nil(t, op.Aborted())
+}
@@ -0,0 +1,99 @@
+// Package i18n translates console output and reports and renders their times
+// in the configured timezone.
+//
+// Messages are looked up by their English text, so the default language and
+// messages without a translation are printed as written. Log files and error
+// messages stay in English so that they can be searched and compared.
+package i18n
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+// Supported languages
+const (
+	English = "en"
+	Chinese = "zh-CN"
+)
+
+// catalogs maps a language to its translations, keyed by the English message
+var catalogs = map[string]map[string]string{
+	Chinese: zhCN,
+}
+
+var current atomic.Value
+
+// SetLang sets the language of console output for the whole process
+func SetLang(lang string) error {
+	switch strings.ToLower(strings.ReplaceAll(lang, "_", "-")) {
+	case "", "en", "en-us":
+		current.Store(English)
+	case "zh", "zh-cn":
+		current.Store(Chinese)
+	default:
+		return fmt.Errorf("unsupported language %q, expect %s or %s", lang, English, Chinese)
+	}
+	return nil
+}
+
+// Lang returns the active language
+func Lang() string {
+	if lang, ok := current.Load().(string); ok {
+		return lang
+	}
+	return English
+}
+
+// T translates a message into the active language
+func T(msg string) string {
+	if translated, ok := catalogs[Lang()][msg]; ok {
+		return translated
+	}
+	return msg
+}
+
+// Sprintf translates format and formats it with args
+func Sprintf(format string, args ...interface{}) string {
+	return fmt.Sprintf(T(format), args...)
+}
+
+// Width returns the number of terminal columns taken by s, CJK characters take two
+func Width(s string) int {
+	width := 0
+	for _, r := range s {
+		width++
+		if isWide(r) {
+			width++
+		}
+	}
+	return width
+}
+
+// Pad appends spaces to s until it takes width columns, so that translated
+// labels keep report columns aligned
+func Pad(s string, width int) string {
+	if n := width - Width(s); n > 0 {
+		return s + strings.Repeat(" ", n)
+	}
+	return s
+}
+
+// Center pads s on both sides with fill until it takes width columns
+func Center(s string, width int, fill string) string {
+	n := width - Width(s)
+	if n <= 0 {
+		return s
+	}
+	return strings.Repeat(fill, n/2) + s + strings.Repeat(fill, n-n/2)
+}
+
+// isWide reports whether r is displayed in two columns
+func isWide(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hangul, unicode.Hiragana, unicode.Katakana) ||
+		(r >= 0x3000 && r <= 0x303f) || // CJK标点
+		(r >= 0xff00 && r <= 0xff60) || // 全角字符
+		(r >= 0xffe0 && r <= 0xffe6)
+}
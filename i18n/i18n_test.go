@@ -0,0 +1,103 @@
+package i18n
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetLang(t *testing.T) {
+	defer SetLang(English)
+
+	tests := []struct {
+		name    string
+		lang    string
+		want    string
+		wantErr bool
+	}{
+		{name: "默认英文", lang: "", want: English},
+		{name: "英文", lang: "en-US", want: English},
+		{name: "简体中文", lang: "zh-CN", want: Chinese},
+		{name: "下划线及小写", lang: "zh_cn", want: Chinese},
+		{name: "不支持的语言", lang: "fr", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetLang(English)
+			err := SetLang(tt.lang)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Equal(t, English, Lang())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, Lang())
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	defer SetLang(English)
+
+	assert.Equal(t, "Files", T("Files"))
+	assert.Equal(t, "Failed (disk full)", Sprintf("Failed (%v)", "disk full"))
+
+	assert.NoError(t, SetLang(Chinese))
+	assert.Equal(t, "文件", T("Files"))
+	assert.Equal(t, "失败 (disk full)", Sprintf("Failed (%v)", "disk full"))
+	// 没有翻译的消息原样输出
+	assert.Equal(t, "untranslated", T("untranslated"))
+}
+
+func TestCatalogFormats(t *testing.T) {
+	// 翻译必须保留原文的格式化参数，否则输出会错位
+	for lang, catalog := range catalogs {
+		for msg, translated := range catalog {
+			assert.Equal(t, verbs(msg), verbs(translated), "%s: %q", lang, msg)
+		}
+	}
+}
+
+// verbs returns the formatting verbs of a message in order
+func verbs(s string) []string {
+	var result []string
+	for i := 0; i < len(s)-1; i++ {
+		if s[i] != '%' {
+			continue
+		}
+		j := i + 1
+		for j < len(s) && (s[j] == '.' || s[j] == '-' || (s[j] >= '0' && s[j] <= '9')) {
+			j++
+		}
+		if j < len(s) {
+			result = append(result, s[i:j+1])
+		}
+		i = j
+	}
+	return result
+}
+
+func TestPad(t *testing.T) {
+	assert.Equal(t, 4, Width("文件"))
+	assert.Equal(t, "Files:    ", Pad("Files:", 10))
+	assert.Equal(t, "文件:     ", Pad("文件:", 10))
+	assert.Equal(t, "too long", Pad("too long", 3))
+	assert.Equal(t, "-- 容量 --", Center(" 容量 ", 10, "-"))
+}
+
+func TestFormatTime(t *testing.T) {
+	defer SetTimezone("Local")
+
+	ts := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	assert.NoError(t, SetTimezone("UTC"))
+	assert.Equal(t, "2024-03-01T12:30:00Z", FormatTime(ts))
+	assert.NoError(t, SetTimezone("Asia/Shanghai"))
+	assert.Equal(t, "2024-03-01T20:30:00+08:00", FormatTime(ts))
+	// 不同时区的时间渲染到同一时区
+	assert.Equal(t, "2024-03-01T20:30:00+08:00", FormatTime(ts.In(time.FixedZone("EST", -5*3600))))
+	assert.Equal(t, "", FormatTime(time.Time{}))
+
+	assert.Error(t, SetTimezone("Mars/Olympus"))
+	assert.Equal(t, "Asia/Shanghai", Timezone().String())
+}
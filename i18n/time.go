@@ -0,0 +1,51 @@
+package i18n
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	// 嵌入时区数据库，Windows上没有系统时区数据
+	_ "time/tzdata"
+)
+
+// timezone is the location report times are rendered in, nil means local time
+var timezone atomic.Pointer[time.Location]
+
+// SetTimezone sets the timezone of times in console output and reports:
+// UTC, Local (the default) or an IANA name such as Asia/Shanghai.
+// Times are always stored in UTC, this only changes how they are rendered.
+func SetTimezone(name string) error {
+	switch strings.ToLower(name) {
+	case "", "local":
+		timezone.Store(time.Local)
+		return nil
+	case "utc", "z":
+		timezone.Store(time.UTC)
+		return nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return fmt.Errorf("unsupported timezone %q, expect UTC, Local or an IANA name such as Asia/Shanghai", name)
+	}
+	timezone.Store(loc)
+	return nil
+}
+
+// Timezone returns the timezone times are rendered in
+func Timezone() *time.Location {
+	if loc := timezone.Load(); loc != nil {
+		return loc
+	}
+	return time.Local
+}
+
+// FormatTime renders t in the configured timezone as RFC 3339, which includes
+// the UTC offset so that readers in other timezones can compare the values
+func FormatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(Timezone()).Format(time.RFC3339)
+}
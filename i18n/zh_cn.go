@@ -0,0 +1,275 @@
+package i18n
+
+// zhCN 简体中文翻译
+var zhCN = map[string]string{
+	// 扫描报告
+	"Scan Statistics":      "扫描统计",
+	"Command":              "命令",
+	"Total time":           "总耗时",
+	"Start time":           "开始时间",
+	"Job ID":               "任务ID",
+	"Log Path":             "日志路径",
+	"CSV Report":           "CSV报告",
+	"HTML Report":          "HTML报告",
+	"JSON Report":          "JSON报告",
+	"Crypto mode":          "加密模式",
+	"Status":               "状态",
+	"Succeeded":            "成功",
+	"Failed (%v)":          "失败 (%v)",
+	"Scanned Count":        "扫描数量",
+	"Total":                "合计",
+	"Files":                "文件",
+	"Directories":          "目录",
+	"Capacity":             "容量",
+	"Average":              "平均",
+	"Filename Length":      "文件名长度",
+	"Directory Depth":      "目录深度",
+	"Directory Size":       "目录大小",
+	"Avg":                  "平均",
+	"Max":                  "最大",
+	"Max entries":          "最大条目数",
+	"Huge dirs":            "超大目录",
+	"Processors":           "处理器",
+	"Skipped":              "已跳过",
+	"Routed to %s":         "路由到%s",
+	"File type":            "文件类型",
+	"Compression Estimate": "压缩率估算",
+	"Sampled files":        "采样文件数",
+	"Sampled":              "采样数据量",
+	"Ratio":                "压缩比",
+	"Expected savings":     "预计节省",
+	"Savings":              "节省",
+	"Extension":            "扩展名",
+	"Directory":            "目录",
+	"(none)":               "(无)",
+	"Found: %s\n":          "发现: %s\n",
+	"WARNING: %d directories contain more than %d entries": "警告: %d个目录包含超过%d个条目",
+	"... (%d more, see log)":                               "... (另有%d个，见日志)",
+
+	// 生命周期规则建议
+	"Lifecycle Suggestions": "生命周期规则建议",
+	"%.0f%% of bytes untouched >%s (%d files, %s) → transition to %s": "%.0f%%的数据超过%s未使用(%d个文件，%s) → 转换到%s",
+
+	// 汇总报告
+	"Rollup Statistics": "汇总统计",
+	"Jobs":              "任务数",
+	"Failed jobs":       "失败任务数",
+	"Shares":            "共享",
+	"Path":              "路径",
+	"File Size":         "文件大小",
+	"File Age":          "文件修改时间",
+	"< 4 KiB":           "小于4 KiB",
+	">= 4 GiB":          "4 GiB及以上",
+	"< 30 days":         "30天以内",
+	"30 - 90 days":      "30至90天",
+	"90 days - 1 year":  "90天至1年",
+	"1 - 3 years":       "1至3年",
+	">= 3 years":        "3年以上",
+
+	// 校验报告
+	"Verify Statistics": "校验统计",
+	"Source":            "源",
+	"Destination":       "目标",
+	"Report":            "报告",
+	"Attributes":        "属性",
+	"Checked":           "已检查",
+	"In sync":           "一致",
+	"Drifted":           "存在差异",
+	"Drift":             "差异",
+	"Comparison":        "比较",
+	"Checksum":          "校验和",
+	"Extra":             "目标端多余",
+
+	// 迁移
+	"Capacity preflight: %s\n": "容量预检: %s\n",
+
+	// 测试数据生成及基准测试
+	"Generated %d files (%d symlinks, %d sparse) in %d directories, %s in %s\n": "已生成%d个文件(%d个符号链接，%d个稀疏文件)，共%d个目录，%s，耗时%s\n",
+	"%s failed: %s\n": "%s 失败: %s\n",
+	"%s %d entries, %s in %.2fs: %.0f entries/s, %.1f MB/s\n": "%s %d个条目，%s，耗时%.2f秒: %.0f条目/秒，%.1f MB/秒\n",
+
+	// 报告签名
+	"Manifest": "报告清单",
+	"Generated key %s: secret key %s, public key %s\n": "已生成密钥%s: 私钥%s，公钥%s\n",
+	"OK: %s\n":                              "通过: %s\n",
+	"Reports signed by key %s are intact\n": "由密钥%s签名的报告未被修改\n",
+
+	// 分布式迁移
+	"Added %d work items, %d already queued\n":                               "已添加%d个工作项，%d个已在队列中\n",
+	"Items: %d pending, %d claimed (%d lease expired), %d done, %d failed\n": "工作项: %d个等待，%d个已领取(%d个租约过期)，%d个完成，%d个失败\n",
+	"Migrated: %d files, %s, %d failed files\n":                              "已迁移: %d个文件，%s，%d个文件失败\n",
+	"  %s: %d claimed, %d done, %d files, %s\n":                              "  %s: %d个已领取，%d个完成，%d个文件，%s\n",
+	"Work item %s failed on every attempt: %s\n":                             "工作项%s多次尝试均失败: %s\n",
+
+	// Kubernetes任务
+	"Track the workers with: terrasync k8s status %s\n":                                 "使用以下命令跟踪工作节点: terrasync k8s status %s\n",
+	"All shards succeeded, merge them with: terrasync report merge --jobs %s --id %s\n": "所有分区均已成功，使用以下命令合并: terrasync report merge --jobs %s --id %s\n",
+	"Run %s: %s, %d shards\n":                                                           "运行%s: %s，%d个分区\n",
+	"Pending":                                                                           "等待",
+	"Running":                                                                           "运行中",
+	"Failed":                                                                            "失败",
+	" (%d failed pods)":                                                                 " (%d个Pod失败)",
+	"  %-4d no Job found\n":                                                             "  %-4d 未找到Job\n",
+
+	// 文件描述符
+	"Warning: the concurrency may open %d files at once but only %d file descriptors are available, raise the NOFILE limit (ulimit -n)\n": "警告: 并发可能同时打开%d个文件，但只有%d个文件描述符可用，请提高NOFILE限制(ulimit -n)\n",
+
+	// 只读模式
+	"Source access": "源访问方式",
+	"Read-only":     "只读",
+
+	// 安全联锁
+	"Overwrite with propagate-deletes may overwrite or delete %d files on %s (threshold %d).\nType the destination to proceed: ": "覆盖并同步删除可能会覆盖或删除%d个文件，目标: %s(阈值%d)。\n输入目标路径以继续: ",
+
+	// 迁移核对
+	"Reconciliation: source %d files, %s; destination %d files, %s\n": "核对: 源端%d个文件，%s；目标端%d个文件，%s\n",
+	"  %s: source %d files, %s; destination %d files, %s\n":           "  %s: 源端%d个文件，%s；目标端%d个文件，%s\n",
+	"Reconcile report: %s\n":                                          "核对报告: %s\n",
+
+	// 清理残留
+	"Dry run, nothing was removed\n":                 "试运行，未删除任何内容\n",
+	"Multipart uploads aborted: %d, %s\n":            "已中止的分片上传: %d个，%s\n",
+	"Temporary files removed:   %d, %s\n":            "已删除的临时文件: %d个，%s\n",
+	"Temporary tables dropped:  %d in %d jobs, %s\n": "已删除的临时表:   %d个(%d个任务)，%s\n",
+	"Reclaimed: %s\n":                                "回收空间: %s\n",
+
+	// 预读存根
+	"Pre-read: %d files, %s; offline stubs: %d, %s; still offline: %d\n": "预读: %d个文件，%s；离线存根: %d个，%s；仍离线: %d个\n",
+	"Stub report: %s\n": "存根报告: %s\n",
+
+	// 离线存根
+	"Offline Stubs": "离线存根",
+
+	// 增量扫描
+	"Pruned %d directories unchanged since the last scan, files modified in place below them are not detected\n": "%d个目录自上次扫描后未变化而未深入，其下就地修改的文件不会被检测到\n",
+
+	// 迁移报告
+	"Migrate Statistics": "迁移统计",
+	"Failures":           "失败记录",
+	"Rewrite report":     "重写报告",
+	"Transfer":           "传输",
+	"Copied":             "已拷贝",
+	"Copied size":        "已拷贝数据量",
+	"Throughput":         "吞吐量",
+	"Skipped size":       "跳过数据量",
+	"Metadata updated":   "已更新元数据",
+	"Rewritten paths":    "重写路径数",
+	"Deleted":            "已删除",
+	"Completed before":   "之前已完成",
+
+	// 变更日志
+	"No change list position of %s saved by a previous run, walking the tree\n": "%s没有上次运行保存的变更日志位置，遍历目录树\n",
+
+	// 变化采集
+	"Recorded %d changes into %s (%d repeated modifications merged, %d overflows)\n": "已记录%d个变化到%s(合并%d次重复修改，溢出%d次)\n",
+
+	// HTML报告
+	"Extensions":    "扩展名分布",
+	"Other":         "其他",
+	"Largest Files": "最大的文件",
+	"Size":          "大小",
+	"Modified":      "修改时间",
+
+	// 数据库选择
+	"Warning: about %d entries are expected (%s) but SQLite is used, which slows down beyond %d entries; set database.type: postgres or mysql with database.dsn, or split the scan with --partition and report merge\n": "警告: 预计约%d个条目(%s)，但使用的是SQLite，超过%d个条目后会变慢；可以配置database.type: postgres或mysql及database.dsn，或用--partition分区扫描再用report merge合并\n",
+	"previous job": "上次运行",
+	"estimate":     "预估",
+
+	// 迁移波次
+	"Plan of job %s (%s): %d waves\n": "任务%s(%s)的迁移计划: %d个波次\n",
+	"Wave":                            "波次",
+	"Estimate":                        "预计耗时",
+	"Rule":                            "规则",
+	"Estimated total: %s\n":           "预计总耗时: %s\n",
+	"Plan: %s\n":                      "计划: %s\n",
+
+	// 中断
+	"\nInterrupted, finishing pending writes. Press Ctrl-C again to exit immediately\n": "\n已中断，正在写入未完成的批次。再次按Ctrl-C立即退出\n",
+	"Interrupted (partial results)": "已中断(部分结果)",
+
+	// 验收清单
+	"Acceptance Checklist":                "验收清单",
+	"End time":                            "结束时间",
+	"Cutover readiness":                   "切换就绪",
+	"ready":                               "就绪",
+	"not ready":                           "未就绪",
+	"Counts":                              "数量",
+	"Checksum Sample":                     "校验和抽检",
+	"Errors":                              "错误",
+	"Sign-off":                            "签字确认",
+	"Item":                                "检查项",
+	"Expected":                            "预期",
+	"Actual":                              "实际",
+	"Result":                              "结果",
+	"Role":                                "角色",
+	"Name":                                "姓名",
+	"Date":                                "日期",
+	"Signature":                           "签名",
+	"Bytes":                               "字节数",
+	"Already at destination":              "目标端已存在",
+	"Completed by previous runs":          "之前的运行已完成",
+	"Deleted from destination":            "从目标端删除",
+	"Files sampled":                       "抽检文件数",
+	"Checksum mismatches":                 "校验和不一致",
+	"Unresolved errors":                   "未解决的错误",
+	"pass":                                "通过",
+	"fail":                                "失败",
+	"review":                              "待确认",
+	"Migration engineer":                  "迁移工程师",
+	"Share owner":                         "共享负责人",
+	"Project manager":                     "项目经理",
+	"Acceptance checklist: %s, %s (%s)\n": "验收清单: %s, %s(%s)\n",
+
+	// 扫描进度行
+	"Progress: %d files, %d dirs, %s seen, %.0f files/s, elapsed %v": "进度: %d个文件, %d个目录, 已扫描%s, %.0f文件/秒, 已用时%v",
+	", ETA %v": ", 预计剩余%v",
+
+	// 文件分类
+	"File Categories": "文件分类",
+	"Category":        "分类",
+	"Documents":       "文档",
+	"Media":           "媒体",
+	"Code":            "代码",
+	"VM images":       "虚拟机镜像",
+	"Databases":       "数据库",
+	"Archives":        "压缩包",
+
+	// 重复目录
+	"Duplicate Directories": "重复目录",
+	"Duplicate groups":      "重复目录组",
+	"Reclaimable":           "可回收空间",
+	"Group %d":              "第%d组",
+	"%d copies of %d files, %s each, %s reclaimable": "%d份相同的副本, 各有%d个文件共%s, 可回收%s",
+	"%d more groups not shown":                       "另有%d组未列出",
+
+	// 空目录及空文件
+	"Empty Entries":                     "空目录及空文件",
+	"Zero-byte files":                   "0字节文件",
+	"Empty Directories":                 "空目录",
+	"Zero-byte Files":                   "0字节文件",
+	"... (%d more, see the CSV export)": "... (另有%d个，见CSV导出)",
+	"Empty directories removed: %d\n":   "已删除的空目录:   %d个\n",
+
+	// 文件及目录排名
+	"Directories by Files": "文件最多的目录",
+	"Directories by Size":  "容量最大的目录",
+
+	// 过期文件清理
+	"Job directories removed: %d, %s\n": "已删除的任务目录: %d个，%s\n",
+	"Exports removed:         %d, %s\n": "已删除的导出报告: %d个，%s\n",
+	"Rotated logs removed:    %d, %s\n": "已删除的轮转日志: %d个，%s\n",
+
+	// 重复文件
+	"Duplicate Files":                    "重复文件",
+	"Hash":                               "哈希算法",
+	"%s (sampled, 3 x %s)":               "%s (抽样, 3 x %s)",
+	"Same-size files":                    "大小相同的文件",
+	"Read":                               "读取",
+	"Read errors":                        "读取失败",
+	"Duplicate sets":                     "重复文件组",
+	"Duplicate files":                    "重复文件",
+	"Set %d":                             "第%d组",
+	"%d copies, %s each, %s reclaimable": "%d份相同的文件, 各%s, 可回收%s",
+	"%d more sets not shown, see the CSV export":        "另有%d组未列出，见CSV导出",
+	"Duplicate files saved into the job database: %s\n": "重复文件已保存到任务数据库: %s\n",
+}
@@ -0,0 +1,180 @@
+// Package k8s runs the workers of a distributed scan as Kubernetes Jobs. It
+// builds one batch/v1 Job per partition and tracks them through kubectl, so
+// no Kubernetes client library is needed and the cluster credentials are those
+// of the kubectl context in use.
+package k8s
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Labels identifying the Jobs of a run
+const (
+	LabelName   = "app.kubernetes.io/name"
+	LabelRun    = "terrasync.io/run"
+	LabelShard  = "terrasync.io/shard"
+	LabelShards = "terrasync.io/shards"
+)
+
+// Mount is a PersistentVolumeClaim mounted into every worker, e.g. the share to scan
+type Mount struct {
+	Claim     string
+	MountPath string
+	ReadOnly  bool
+}
+
+// ParseMount parses claim:/path, a trailing :ro mounts the claim read-only
+func ParseMount(s string) (Mount, error) {
+	m := Mount{}
+	if rest, ok := strings.CutSuffix(s, ":ro"); ok {
+		s, m.ReadOnly = rest, true
+	}
+	claim, mountPath, ok := strings.Cut(s, ":")
+	if !ok || claim == "" || !path.IsAbs(mountPath) {
+		return Mount{}, fmt.Errorf("invalid mount %q, expected claim:/path[:ro]", s)
+	}
+	m.Claim, m.MountPath = claim, mountPath
+	return m, nil
+}
+
+// JobConfig describes the worker Jobs of one distributed scan
+type JobConfig struct {
+	Name           string   // 运行名称，也是各分区任务ID的前缀
+	Namespace      string   // 为空时使用kubectl上下文的命名空间
+	Image          string   // 包含terrasync的镜像
+	InstallDir     string   // 镜像中terrasync所在目录，config.yaml和jobs/在该目录下
+	ConfigSecret   string   // 包含config.yaml的Secret
+	JobsClaim      string   // 挂载为jobs/目录的PVC，合并分区任务时需要读取，为空时使用emptyDir
+	Shards         int      // 分区数，每个分区一个Job
+	Args           []string // scan命令的参数(不含--partition和--id)
+	Mounts         []Mount
+	ServiceAccount string
+	BackoffLimit   int // Job失败后的重试次数
+}
+
+// ShardID is the scan job ID of a shard of the run name, as given to report merge
+func ShardID(name string, shard int) string {
+	return fmt.Sprintf("%s-p%d", name, shard)
+}
+
+// Validate checks the configuration before Jobs are built
+func (c JobConfig) Validate() error {
+	// Job名称及标签值需要符合DNS-1123
+	if !dnsLabel(c.Name) || len(c.Name) > 40 {
+		return fmt.Errorf("invalid name %q, must be at most 40 lowercase letters, digits and '-'", c.Name)
+	}
+	if c.Image == "" {
+		return fmt.Errorf("no image given")
+	}
+	if c.ConfigSecret == "" {
+		return fmt.Errorf("no config secret given")
+	}
+	if c.Shards < 1 {
+		return fmt.Errorf("invalid number of shards %d", c.Shards)
+	}
+	if len(c.Args) == 0 {
+		return fmt.Errorf("no scan path given")
+	}
+	for _, arg := range c.Args {
+		if arg == "--partition" || strings.HasPrefix(arg, "--partition=") || arg == "--id" || strings.HasPrefix(arg, "--id=") {
+			return fmt.Errorf("%s is set per shard and cannot be given", arg)
+		}
+	}
+	return nil
+}
+
+func dnsLabel(s string) bool {
+	if s == "" || s[0] == '-' || s[len(s)-1] == '-' {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// Object is a Kubernetes object in the JSON accepted by kubectl apply
+type Object = map[string]any
+
+// BuildJobs returns a List with one Job per shard, each scanning its partition
+// of the namespace into the job ID ShardID(c.Name, i)
+func BuildJobs(c JobConfig) (Object, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	if c.InstallDir == "" {
+		c.InstallDir = "/opt/terrasync"
+	}
+
+	items := make([]any, 0, c.Shards)
+	for shard := 1; shard <= c.Shards; shard++ {
+		items = append(items, buildJob(c, shard))
+	}
+	return Object{"apiVersion": "v1", "kind": "List", "items": items}, nil
+}
+
+func buildJob(c JobConfig, shard int) Object {
+	labels := Object{
+		LabelName:   "terrasync",
+		LabelRun:    c.Name,
+		LabelShard:  strconv.Itoa(shard),
+		LabelShards: strconv.Itoa(c.Shards),
+	}
+	args := append([]string{"scan", "--partition", fmt.Sprintf("%d/%d", shard, c.Shards), "--id", ShardID(c.Name, shard)}, c.Args...)
+
+	jobsVolume := Object{"name": "jobs", "emptyDir": Object{}}
+	if c.JobsClaim != "" {
+		jobsVolume = Object{"name": "jobs", "persistentVolumeClaim": Object{"claimName": c.JobsClaim}}
+	}
+	volumes := []any{
+		Object{"name": "config", "secret": Object{"secretName": c.ConfigSecret}},
+		jobsVolume,
+	}
+	mounts := []any{
+		// 只挂载config.yaml，不遮盖镜像中的terrasync
+		Object{"name": "config", "mountPath": path.Join(c.InstallDir, "config.yaml"), "subPath": "config.yaml", "readOnly": true},
+		Object{"name": "jobs", "mountPath": path.Join(c.InstallDir, "jobs")},
+	}
+	for i, m := range c.Mounts {
+		name := fmt.Sprintf("data-%d", i)
+		volumes = append(volumes, Object{"name": name, "persistentVolumeClaim": Object{"claimName": m.Claim, "readOnly": m.ReadOnly}})
+		mounts = append(mounts, Object{"name": name, "mountPath": m.MountPath, "readOnly": m.ReadOnly})
+	}
+
+	podSpec := Object{
+		"restartPolicy": "Never",
+		"containers": []any{Object{
+			"name":         "terrasync",
+			"image":        c.Image,
+			"command":      []string{path.Join(c.InstallDir, "terrasync")},
+			"args":         args,
+			"volumeMounts": mounts,
+		}},
+		"volumes": volumes,
+	}
+	if c.ServiceAccount != "" {
+		podSpec["serviceAccountName"] = c.ServiceAccount
+	}
+
+	metadata := Object{"name": fmt.Sprintf("%s-%d-of-%d", c.Name, shard, c.Shards), "labels": labels}
+	if c.Namespace != "" {
+		metadata["namespace"] = c.Namespace
+	}
+	return Object{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata":   metadata,
+		"spec": Object{
+			"backoffLimit": c.BackoffLimit,
+			"template": Object{
+				"metadata": Object{"labels": labels},
+				"spec":     podSpec,
+			},
+		},
+	}
+}
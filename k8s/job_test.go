@@ -0,0 +1,137 @@
+package k8s
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBuildJobs 测试每个分区生成一个Job，参数中包含分区及任务ID
+func TestBuildJobs(t *testing.T) {
+	share, err := ParseMount("share:/mnt/share:ro")
+	assert.NoError(t, err)
+	assert.Equal(t, Mount{Claim: "share", MountPath: "/mnt/share", ReadOnly: true}, share)
+
+	list, err := BuildJobs(JobConfig{
+		Name:         "nightly",
+		Namespace:    "storage",
+		Image:        "registry.example.com/terrasync:3.0.0",
+		ConfigSecret: "terrasync-config",
+		JobsClaim:    "terrasync-jobs",
+		Shards:       3,
+		Args:         []string{"--html", "/mnt/share"},
+		Mounts:       []Mount{share},
+	})
+	assert.NoError(t, err)
+
+	// 按kubectl读取的JSON检查
+	data, err := json.Marshal(list)
+	assert.NoError(t, err)
+	var decoded struct {
+		Kind  string `json:"kind"`
+		Items []struct {
+			Metadata struct {
+				Name      string            `json:"name"`
+				Namespace string            `json:"namespace"`
+				Labels    map[string]string `json:"labels"`
+			} `json:"metadata"`
+			Spec struct {
+				Template struct {
+					Spec struct {
+						RestartPolicy string `json:"restartPolicy"`
+						Containers    []struct {
+							Command      []string `json:"command"`
+							Args         []string `json:"args"`
+							VolumeMounts []struct {
+								MountPath string `json:"mountPath"`
+							} `json:"volumeMounts"`
+						} `json:"containers"`
+					} `json:"spec"`
+				} `json:"template"`
+			} `json:"spec"`
+		} `json:"items"`
+	}
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "List", decoded.Kind)
+	assert.Len(t, decoded.Items, 3)
+
+	job := decoded.Items[1]
+	assert.Equal(t, "nightly-2-of-3", job.Metadata.Name)
+	assert.Equal(t, "storage", job.Metadata.Namespace)
+	assert.Equal(t, map[string]string{LabelName: "terrasync", LabelRun: "nightly", LabelShard: "2", LabelShards: "3"}, job.Metadata.Labels)
+	pod := job.Spec.Template.Spec
+	assert.Equal(t, "Never", pod.RestartPolicy)
+	assert.Equal(t, []string{"/opt/terrasync/terrasync"}, pod.Containers[0].Command)
+	assert.Equal(t, []string{"scan", "--partition", "2/3", "--id", "nightly-p2", "--html", "/mnt/share"}, pod.Containers[0].Args)
+	var mountPaths []string
+	for _, m := range pod.Containers[0].VolumeMounts {
+		mountPaths = append(mountPaths, m.MountPath)
+	}
+	assert.Equal(t, []string{"/opt/terrasync/config.yaml", "/opt/terrasync/jobs", "/mnt/share"}, mountPaths)
+}
+
+// TestBuildJobsInvalid 测试无效的Job配置
+func TestBuildJobsInvalid(t *testing.T) {
+	valid := JobConfig{Name: "nightly", Image: "terrasync", ConfigSecret: "config", Shards: 2, Args: []string{"/mnt/share"}}
+	_, err := BuildJobs(valid)
+	assert.NoError(t, err)
+
+	cases := []struct {
+		name   string
+		modify func(c *JobConfig)
+	}{
+		{name: "名称包含大写字母", modify: func(c *JobConfig) { c.Name = "Nightly" }},
+		{name: "没有Secret", modify: func(c *JobConfig) { c.ConfigSecret = "" }},
+		{name: "分区数为0", modify: func(c *JobConfig) { c.Shards = 0 }},
+		{name: "没有扫描路径", modify: func(c *JobConfig) { c.Args = nil }},
+		{name: "指定了分区", modify: func(c *JobConfig) { c.Args = []string{"--partition=1/2", "/mnt/share"} }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := valid
+			c.modify(&config)
+			_, err := BuildJobs(config)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err = ParseMount("share")
+	assert.Error(t, err)
+	_, err = ParseMount("share:relative")
+	assert.Error(t, err)
+}
+
+// TestParseJobList 测试从kubectl get jobs的输出得到各分区的状态
+func TestParseJobList(t *testing.T) {
+	data := []byte(`{"items": [
+	{"metadata": {"name": "nightly-2-of-3", "labels": {"terrasync.io/run": "nightly", "terrasync.io/shard": "2", "terrasync.io/shards": "3"}},
+	 "status": {"active": 1, "startTime": "2026-10-15T01:00:00Z"}},
+	{"metadata": {"name": "nightly-1-of-3", "labels": {"terrasync.io/run": "nightly", "terrasync.io/shard": "1", "terrasync.io/shards": "3"}},
+	 "status": {"succeeded": 1, "startTime": "2026-10-15T01:00:00Z", "completionTime": "2026-10-15T01:20:00Z",
+	            "conditions": [{"type": "Complete", "status": "True"}]}},
+	{"metadata": {"name": "other-1-of-1", "labels": {"terrasync.io/run": "other", "terrasync.io/shard": "1"}}, "status": {}}
+]}`)
+	status, err := ParseJobList("nightly", data)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, status.Shards)
+	assert.Len(t, status.Jobs, 2)
+	assert.Equal(t, StateSucceeded, status.Jobs[0].State)
+	assert.Equal(t, 20*60.0, status.Jobs[0].Completion.Sub(status.Jobs[0].Start).Seconds())
+	assert.Equal(t, StateRunning, status.Jobs[1].State)
+	assert.Equal(t, []int{3}, status.Missing)
+	assert.Equal(t, StateRunning, status.State())
+
+	// 一个分区失败
+	status.Jobs[1].State = StateFailed
+	assert.Equal(t, StateFailed, status.State())
+	// 所有分区成功
+	status.Jobs[1].State = StateSucceeded
+	status.Jobs = append(status.Jobs, ShardStatus{Shard: 3, State: StateSucceeded})
+	status.Missing = nil
+	assert.Equal(t, StateSucceeded, status.State())
+
+	empty, err := ParseJobList("nightly", []byte(`{"items": []}`))
+	assert.NoError(t, err)
+	assert.Equal(t, StatePending, empty.State())
+}
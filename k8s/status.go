@@ -0,0 +1,198 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Shard states
+const (
+	StatePending   = "Pending"
+	StateRunning   = "Running"
+	StateSucceeded = "Succeeded"
+	StateFailed    = "Failed"
+)
+
+// Kubectl runs kubectl against the cluster of its current or given context
+type Kubectl struct {
+	Path      string // kubectl可执行文件，为空时从PATH中查找
+	Namespace string
+	Context   string
+}
+
+func (k Kubectl) run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	kubectl := k.Path
+	if kubectl == "" {
+		kubectl = "kubectl"
+	}
+	verb := args[0]
+	if k.Context != "" {
+		args = append([]string{"--context", k.Context}, args...)
+	}
+	if k.Namespace != "" {
+		args = append([]string{"--namespace", k.Namespace}, args...)
+	}
+	cmd := exec.CommandContext(ctx, kubectl, args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if msg := strings.TrimSpace(stderr.String()); err != nil && msg != "" {
+		return nil, fmt.Errorf("kubectl %s failed: %w: %s", verb, err, msg)
+	} else if err != nil {
+		return nil, fmt.Errorf("kubectl %s failed: %w", verb, err)
+	}
+	return out, nil
+}
+
+// Apply creates or updates the objects with kubectl apply and returns its output
+func (k Kubectl) Apply(ctx context.Context, obj Object) (string, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode objects: %w", err)
+	}
+	out, err := k.run(ctx, data, "apply", "-f", "-")
+	return string(out), err
+}
+
+// Status returns the state of the Jobs of a run
+func (k Kubectl) Status(ctx context.Context, name string) (RunStatus, error) {
+	out, err := k.run(ctx, nil, "get", "jobs", "-l", LabelRun+"="+name, "-o", "json")
+	if err != nil {
+		return RunStatus{}, err
+	}
+	return ParseJobList(name, out)
+}
+
+// ShardStatus is the state of the Job of one shard
+type ShardStatus struct {
+	Shard      int
+	Job        string
+	State      string
+	Active     int
+	Succeeded  int
+	Failed     int // 失败的Pod数，包括已重试的
+	Start      time.Time
+	Completion time.Time
+	Message    string // Job失败的原因
+}
+
+// RunStatus is the state of all Jobs of a run
+type RunStatus struct {
+	Name    string
+	Shards  int // 运行的分区数，没有Job时为0
+	Jobs    []ShardStatus
+	Missing []int // 没有对应Job的分区，例如Job已被删除
+}
+
+// State is Failed when a shard failed, Succeeded when all shards succeeded,
+// Pending when no Job exists and Running otherwise
+func (s RunStatus) State() string {
+	if len(s.Jobs) == 0 {
+		return StatePending
+	}
+	succeeded := 0
+	for _, job := range s.Jobs {
+		switch job.State {
+		case StateFailed:
+			return StateFailed
+		case StateSucceeded:
+			succeeded++
+		}
+	}
+	if succeeded == s.Shards && len(s.Missing) == 0 {
+		return StateSucceeded
+	}
+	return StateRunning
+}
+
+// jobList is the part of a batch/v1 JobList read by ParseJobList
+type jobList struct {
+	Items []struct {
+		Metadata struct {
+			Name   string            `json:"name"`
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+		Status struct {
+			Active         int        `json:"active"`
+			Succeeded      int        `json:"succeeded"`
+			Failed         int        `json:"failed"`
+			StartTime      *time.Time `json:"startTime"`
+			CompletionTime *time.Time `json:"completionTime"`
+			Conditions     []struct {
+				Type    string `json:"type"`
+				Status  string `json:"status"`
+				Reason  string `json:"reason"`
+				Message string `json:"message"`
+			} `json:"conditions"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// ParseJobList reads the state of the Jobs of a run from kubectl get jobs -o json
+func ParseJobList(name string, data []byte) (RunStatus, error) {
+	var list jobList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return RunStatus{}, fmt.Errorf("failed to parse job list: %w", err)
+	}
+
+	status := RunStatus{Name: name}
+	seen := make(map[int]bool)
+	for _, item := range list.Items {
+		labels := item.Metadata.Labels
+		shard, err := strconv.Atoi(labels[LabelShard])
+		if err != nil || labels[LabelRun] != name {
+			continue
+		}
+		if shards, err := strconv.Atoi(labels[LabelShards]); err == nil {
+			status.Shards = max(status.Shards, shards)
+		}
+		job := ShardStatus{
+			Shard:     shard,
+			Job:       item.Metadata.Name,
+			State:     StatePending,
+			Active:    item.Status.Active,
+			Succeeded: item.Status.Succeeded,
+			Failed:    item.Status.Failed,
+		}
+		if item.Status.StartTime != nil {
+			job.Start = *item.Status.StartTime
+		}
+		if item.Status.CompletionTime != nil {
+			job.Completion = *item.Status.CompletionTime
+		}
+		if job.Active > 0 {
+			job.State = StateRunning
+		}
+		for _, c := range item.Status.Conditions {
+			if c.Status != "True" {
+				continue
+			}
+			switch c.Type {
+			case "Complete":
+				job.State = StateSucceeded
+			case "Failed":
+				job.State = StateFailed
+				job.Message = strings.TrimSpace(c.Reason + ": " + c.Message)
+			}
+		}
+		seen[shard] = true
+		status.Jobs = append(status.Jobs, job)
+	}
+	sort.Slice(status.Jobs, func(i, j int) bool { return status.Jobs[i].Shard < status.Jobs[j].Shard })
+	for shard := 1; shard <= status.Shards; shard++ {
+		if !seen[shard] {
+			status.Missing = append(status.Missing, shard)
+		}
+	}
+	return status, nil
+}
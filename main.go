@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 
 	"terrasync/command"
+	"terrasync/cpulimit"
+	"terrasync/i18n"
 	"terrasync/log"
+	"terrasync/pkg/report"
+	"terrasync/security"
 
 	"github.com/spf13/cobra"
 )
@@ -17,6 +24,9 @@ const (
 	AppName    = "terrasync"
 )
 
+// optionalFeatures set up the root command in files guarded by build tags, e.g. main_faultinject.go
+var optionalFeatures []func(rootCmd *cobra.Command)
+
 // initLogger initializes the logging system
 func initLogger(loglevel string) error {
 	// Initialize logger configuration
@@ -58,6 +68,37 @@ func initLogger(loglevel string) error {
 	return nil
 }
 
+// applyCPULimit constrains the CPUs used by the process, so migrations can run
+// next to production workloads without taskset or container limits
+func applyCPULimit(cmd *cobra.Command) error {
+	maxProcs, _ := cmd.Flags().GetInt("max-procs")
+	cpus, _ := cmd.Flags().GetString("cpus")
+	cgroup, _ := cmd.Flags().GetBool("cgroup-cpu")
+	if maxProcs == 0 && cpus == "" && !cgroup {
+		return nil
+	}
+	result, err := cpulimit.Apply(cpulimit.Config{MaxProcs: maxProcs, CPUs: cpus, Cgroup: cgroup})
+	if err != nil {
+		return err
+	}
+	log.Infof("CPU limit: %s", result)
+	return nil
+}
+
+// shutdownContext returns a context cancelled by the first SIGINT or SIGTERM, so
+// the running command stops listing, flushes its pending batches and prints a
+// partial summary. A second signal terminates the process right away.
+func shutdownContext() context.Context {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+		log.Warnf("Interrupted, stopping after the pending writes are flushed")
+		fmt.Fprint(os.Stderr, i18n.T("\nInterrupted, finishing pending writes. Press Ctrl-C again to exit immediately\n"))
+	}()
+	return ctx
+}
+
 func main() {
 	// Create root command
 	rootCmd := &cobra.Command{
@@ -66,14 +107,42 @@ func main() {
 		Long:    `Terrasync - A powerful tool for synchronizing and migrating data between different storage systems.`,
 		Version: AppVersion,
 		Args:    cobra.MinimumNArgs(1),
+		// Console output and reports are translated and rendered in the selected
+		// timezone, logs stay in English
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			lang, _ := cmd.Flags().GetString("lang")
+			if err := i18n.SetLang(lang); err != nil {
+				return err
+			}
+			tz, _ := cmd.Flags().GetString("tz")
+			if err := i18n.SetTimezone(tz); err != nil {
+				return err
+			}
+			width, _ := cmd.Flags().GetInt("report-width")
+			if err := report.SetWidth(width); err != nil {
+				return err
+			}
+			return applyCPULimit(cmd)
+		},
 	}
 
 	// Add global parameters
 	rootCmd.PersistentFlags().StringP("loglevel", "l", "info", "file log level (debug, info)")
+	rootCmd.PersistentFlags().BoolP("fips", "", false, "Restrict hashing and TLS to FIPS 140-2 approved algorithms")
+	rootCmd.PersistentFlags().StringP("lang", "", i18n.English, "Language of console output and reports (en, zh-CN)")
+	rootCmd.PersistentFlags().StringP("tz", "", "Local", "Timezone of times in reports (UTC, Local or an IANA name such as Asia/Shanghai)")
+	rootCmd.PersistentFlags().IntP("report-width", "", 0, "Width of the console report, 0 adapts it to the terminal")
+	rootCmd.PersistentFlags().IntP("max-procs", "", 0, "Maximum number of CPUs executing Go code simultaneously (GOMAXPROCS), 0 derives it from --cpus and --cgroup-cpu")
+	rootCmd.PersistentFlags().StringP("cpus", "", "", "CPUs the process may run on, as a list such as 0-3,8 or node:N for the CPUs of NUMA node N (Linux)")
+	rootCmd.PersistentFlags().BoolP("cgroup-cpu", "", false, "Limit GOMAXPROCS to the CPU quota of the cgroup of the process (Linux containers)")
+	for _, setup := range optionalFeatures {
+		setup(rootCmd)
+	}
 
 	// Parse command line parameters to get log level
 	rootCmd.ParseFlags(os.Args)
 	loglevel, _ := rootCmd.PersistentFlags().GetString("loglevel")
+	fips, _ := rootCmd.PersistentFlags().GetBool("fips")
 
 	// Initialize logging system
 	if err := initLogger(loglevel); err != nil {
@@ -81,14 +150,29 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Enable FIPS mode before any hash or TLS configuration is created
+	security.SetFIPS(fips)
+	log.Infof("Crypto mode: %s", security.Mode())
+
 	// Set subcommands
 	scanCmd := command.NewScanCommand(AppVersion)
 	migrateCmd := command.NewMigrateCommand(AppVersion)
-
-	rootCmd.AddCommand(scanCmd, migrateCmd)
+	verifyCmd := command.NewVerifyCommand(AppVersion)
+	genCmd := command.NewGenCommand(AppVersion)
+	benchCmd := command.NewBenchCommand(AppVersion)
+	reportCmd := command.NewReportCommand(AppVersion)
+	k8sCmd := command.NewK8sCommand(AppVersion)
+	cleanupCmd := command.NewCleanupCommand(AppVersion)
+	importCmd := command.NewImportCommand(AppVersion)
+	watchCmd := command.NewWatchCommand(AppVersion)
+	planCmd := command.NewPlanCommand(AppVersion)
+	gcCmd := command.NewGCCommand(AppVersion)
+	dedupeCmd := command.NewDedupeCommand(AppVersion)
+
+	rootCmd.AddCommand(scanCmd, migrateCmd, verifyCmd, genCmd, benchCmd, reportCmd, k8sCmd, cleanupCmd, importCmd, watchCmd, planCmd, gcCmd, dedupeCmd)
 
 	// Execute command
-	if err := rootCmd.Execute(); err != nil {
+	if err := rootCmd.ExecuteContext(shutdownContext()); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
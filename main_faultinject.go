@@ -0,0 +1,38 @@
+//go:build faultinject
+
+package main
+
+import (
+	"terrasync/object"
+
+	"github.com/spf13/cobra"
+)
+
+// Fault injection flags for QA builds: go build -tags faultinject .
+func init() {
+	optionalFeatures = append(optionalFeatures, func(rootCmd *cobra.Command) {
+		flags := rootCmd.PersistentFlags()
+		flags.Float64("fault-rate", 0, "Probability (0-1) that a storage operation fails with an injected error")
+		flags.Duration("fault-latency", 0, "Maximum random latency added to every storage operation")
+		flags.StringSlice("fault-ops", nil, "Operations to inject faults into: list, head, get, put, delete (default all)")
+		flags.Int64("fault-seed", 0, "Random seed to reproduce a fault sequence (default: current time)")
+
+		previous := rootCmd.PersistentPreRunE
+		rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+			if previous != nil {
+				if err := previous(cmd, args); err != nil {
+					return err
+				}
+			}
+			rate, _ := cmd.Flags().GetFloat64("fault-rate")
+			latency, _ := cmd.Flags().GetDuration("fault-latency")
+			if rate <= 0 && latency <= 0 {
+				return nil
+			}
+			ops, _ := cmd.Flags().GetStringSlice("fault-ops")
+			seed, _ := cmd.Flags().GetInt64("fault-seed")
+			object.EnableFaultInjection(object.FaultConfig{Rate: rate, Latency: latency, Ops: ops, Seed: seed})
+			return nil
+		}
+	})
+}
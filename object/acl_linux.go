@@ -0,0 +1,50 @@
+//go:build linux
+
+package object
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// aclXattrs are the extended attributes holding POSIX ACLs
+var aclXattrs = []string{"system.posix_acl_access", "system.posix_acl_default"}
+
+// readACL reads the POSIX ACLs of a file, files without ACLs return an empty map
+func readACL(name string) (map[string][]byte, error) {
+	acl := make(map[string][]byte)
+	for _, attr := range aclXattrs {
+		size, err := unix.Lgetxattr(name, attr, nil)
+		if errors.Is(err, unix.ENODATA) || errors.Is(err, unix.ENOTSUP) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, size)
+		if size, err = unix.Lgetxattr(name, attr, value); err != nil {
+			return nil, err
+		}
+		acl[attr] = value[:size]
+	}
+	return acl, nil
+}
+
+// writeACL replaces the POSIX ACLs of a file
+func writeACL(name string, acl map[string][]byte) error {
+	for _, attr := range aclXattrs {
+		value, ok := acl[attr]
+		if !ok {
+			err := unix.Lremovexattr(name, attr)
+			if err != nil && !errors.Is(err, unix.ENODATA) && !errors.Is(err, unix.ENOTSUP) {
+				return err
+			}
+			continue
+		}
+		if err := unix.Lsetxattr(name, attr, value, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
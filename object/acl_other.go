@@ -0,0 +1,13 @@
+//go:build !linux
+
+package object
+
+// readACL is only supported on Linux, nil means the ACLs are unknown
+func readACL(name string) (map[string][]byte, error) {
+	return nil, nil
+}
+
+// writeACL is only supported on Linux
+func writeACL(name string, acl map[string][]byte) error {
+	return nil
+}
@@ -0,0 +1,86 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FileAttrs are the file flags beyond permissions: chattr flags on Linux and
+// file attributes on Windows
+type FileAttrs uint32
+
+const (
+	AttrImmutable  FileAttrs = 1 << iota // chattr +i
+	AttrAppendOnly                       // chattr +a
+	AttrNoDump                           // chattr +d
+	AttrReadOnly                         // Windows
+	AttrHidden                           // Windows
+	AttrSystem                           // Windows
+	AttrArchive                          // Windows
+	AttrSparse                           // 稀疏文件
+)
+
+// attrNames are the names of the attributes, in the order they are printed
+var attrNames = []struct {
+	attr FileAttrs
+	name string
+}{
+	{AttrImmutable, "immutable"},
+	{AttrAppendOnly, "append-only"},
+	{AttrNoDump, "nodump"},
+	{AttrReadOnly, "readonly"},
+	{AttrHidden, "hidden"},
+	{AttrSystem, "system"},
+	{AttrArchive, "archive"},
+	{AttrSparse, "sparse"},
+}
+
+// String returns the comma separated attribute names, empty if none is set
+func (a FileAttrs) String() string {
+	var names []string
+	for _, n := range attrNames {
+		if a&n.attr != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// ParseFileAttrs parses attributes formatted by FileAttrs.String
+func ParseFileAttrs(s string) (FileAttrs, error) {
+	var attrs FileAttrs
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, n := range attrNames {
+			if n.name == name {
+				attrs |= n.attr
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown file attribute %q", name)
+		}
+	}
+	return attrs, nil
+}
+
+// AttrsProvider is implemented by files whose attributes can be read
+type AttrsProvider interface {
+	Attrs() (FileAttrs, error)
+}
+
+// AttrsNotPreservedError is returned by SetMetadata when the destination cannot
+// keep some attributes, after all other metadata has been applied
+type AttrsNotPreservedError struct {
+	Key   string
+	Attrs FileAttrs
+}
+
+func (e *AttrsNotPreservedError) Error() string {
+	return fmt.Sprintf("%s: attributes not preserved: %s", e.Key, e.Attrs)
+}
@@ -0,0 +1,190 @@
+//go:build linux
+
+package object
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// inode flags of FS_IOC_GETFLAGS, see linux/fs.h
+const (
+	fsImmutableFl = 0x00000010
+	fsAppendFl    = 0x00000020
+	fsNodumpFl    = 0x00000040
+)
+
+// holeBlockSize is the granularity zero ranges are punched out of sparse files
+const holeBlockSize = 4096
+
+// readAttrs reads the chattr flags of regular files and directories, filesystems
+// without inode flags (NFS, SMB mounts) only report sparseness
+func readAttrs(name string, info os.FileInfo) (FileAttrs, error) {
+	var attrs FileAttrs
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && info.Mode().IsRegular() && stat.Blocks*512 < info.Size() {
+		attrs |= AttrSparse
+	}
+	if !info.Mode().IsRegular() && !info.IsDir() {
+		return attrs, nil
+	}
+
+	flags, err := getFlags(name)
+	if err != nil {
+		if errors.Is(err, unix.ENOTTY) || errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EINVAL) {
+			return attrs, nil
+		}
+		return attrs, err
+	}
+	if flags&fsImmutableFl != 0 {
+		attrs |= AttrImmutable
+	}
+	if flags&fsAppendFl != 0 {
+		attrs |= AttrAppendOnly
+	}
+	if flags&fsNodumpFl != 0 {
+		attrs |= AttrNoDump
+	}
+	return attrs, nil
+}
+
+// unlockAttrs clears the immutable and append-only flags, which would refuse the
+// other metadata changes
+func unlockAttrs(name string) error {
+	flags, err := getFlags(name)
+	if err != nil || flags&(fsImmutableFl|fsAppendFl) == 0 {
+		// 不支持inode标志的文件系统上没有需要清除的标志
+		return nil
+	}
+	return setFlags(name, flags&^(fsImmutableFl|fsAppendFl))
+}
+
+// writeAttrs applies the chattr flags and sparseness of attrs and returns the
+// attributes Linux cannot keep
+func writeAttrs(name string, attrs FileAttrs) (FileAttrs, error) {
+	lost := attrs & (AttrReadOnly | AttrHidden | AttrSystem | AttrArchive)
+
+	if attrs&AttrSparse != 0 {
+		sparse, err := digHoles(name)
+		if err != nil {
+			return lost, err
+		}
+		if !sparse {
+			lost |= AttrSparse
+		}
+	}
+
+	want := attrs & (AttrImmutable | AttrAppendOnly | AttrNoDump)
+	flags, err := getFlags(name)
+	if err != nil {
+		if errors.Is(err, unix.ENOTTY) || errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EINVAL) {
+			return lost | want, nil
+		}
+		return lost, err
+	}
+	newFlags := flags &^ (fsImmutableFl | fsAppendFl | fsNodumpFl)
+	if want&AttrImmutable != 0 {
+		newFlags |= fsImmutableFl
+	}
+	if want&AttrAppendOnly != 0 {
+		newFlags |= fsAppendFl
+	}
+	if want&AttrNoDump != 0 {
+		newFlags |= fsNodumpFl
+	}
+	if newFlags == flags {
+		return lost, nil
+	}
+	if err := setFlags(name, newFlags); err != nil {
+		// 设置immutable和append-only需要CAP_LINUX_IMMUTABLE
+		if errors.Is(err, unix.EPERM) || errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP) {
+			return lost | want, nil
+		}
+		return lost, err
+	}
+	return lost, nil
+}
+
+func openForFlags(name string) (int, error) {
+	return unix.Open(name, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+}
+
+func getFlags(name string) (uint32, error) {
+	fd, err := openForFlags(name)
+	if err != nil {
+		return 0, err
+	}
+	defer unix.Close(fd)
+	return unix.IoctlGetUint32(fd, unix.FS_IOC_GETFLAGS)
+}
+
+func setFlags(name string, flags uint32) error {
+	fd, err := openForFlags(name)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	return unix.IoctlSetPointerInt(fd, unix.FS_IOC_SETFLAGS, int(flags))
+}
+
+// digHoles punches the zero blocks out of a copied file, like fallocate --dig-holes.
+// It returns false if the filesystem does not support holes.
+func digHoles(name string) (bool, error) {
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	zero := make([]byte, holeBlockSize)
+	buf := make([]byte, holeBlockSize)
+	var offset, holeStart, holeLen int64
+	punch := func() error {
+		if holeLen == 0 {
+			return nil
+		}
+		err := unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_PUNCH_HOLE|unix.FALLOC_FL_KEEP_SIZE, holeStart, holeLen)
+		holeLen = 0
+		return err
+	}
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n == holeBlockSize && bytes.Equal(buf, zero) {
+			if holeLen == 0 {
+				holeStart = offset
+			}
+			holeLen += holeBlockSize
+		} else if perr := punch(); perr != nil {
+			return false, ignoreUnsupported(perr)
+		}
+		offset += int64(n)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	if err := punch(); err != nil {
+		return false, ignoreUnsupported(err)
+	}
+	return true, nil
+}
+
+// ignoreUnsupported drops the error of filesystems without hole punching
+func ignoreUnsupported(err error) error {
+	if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOSYS) {
+		return nil
+	}
+	return err
+}
+
+// fileTimes returns the modification time as creation and access times, like the listing always did
+func fileTimes(info os.FileInfo) (ctime, atime time.Time) {
+	return info.ModTime(), info.ModTime()
+}
@@ -0,0 +1,83 @@
+//go:build linux
+
+package object
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLocalAttrs 测试本地文件的稀疏属性及Linux无法保留的Windows属性
+func TestLocalAttrs(t *testing.T) {
+	dir := t.TempDir()
+	// 源文件只有空洞，目标文件是写满0的普通文件
+	assert.NoError(t, os.Truncate(createFile(t, filepath.Join(dir, "src.img"), nil), 1<<20))
+	createFile(t, filepath.Join(dir, "dst.img"), make([]byte, 1<<20))
+
+	storage, err := CreateStorage(dir)
+	assert.NoError(t, err)
+	src, err := storage.Head("/src.img")
+	assert.NoError(t, err)
+	meta, err := MetadataOf(src)
+	assert.NoError(t, err)
+	assert.True(t, meta.AttrsKnown)
+	assert.Equal(t, AttrSparse, meta.Attrs&AttrSparse)
+
+	// 恢复稀疏属性，Windows属性被报告为无法保留
+	meta.Attrs |= AttrHidden | AttrArchive
+	err = storage.(MetadataSetter).SetMetadata("/dst.img", meta)
+	var lost *AttrsNotPreservedError
+	assert.True(t, errors.As(err, &lost))
+	assert.Equal(t, AttrHidden|AttrArchive, lost.Attrs)
+	assert.Equal(t, "/dst.img", lost.Key)
+
+	info, err := os.Stat(filepath.Join(dir, "dst.img"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1<<20), info.Size())
+	assert.Less(t, info.Sys().(*syscall.Stat_t).Blocks*512, info.Size())
+}
+
+// TestLocalImmutable 测试immutable标志的保存和恢复，目标为immutable时仍可修改其他元数据
+func TestLocalImmutable(t *testing.T) {
+	dir := t.TempDir()
+	name := createFile(t, filepath.Join(dir, "a.txt"), []byte("a"))
+	if _, err := writeAttrs(name, AttrImmutable); err != nil {
+		t.Skipf("inode flags not supported: %v", err)
+	}
+	attrs, err := readAttrs(name, lstat(t, name))
+	assert.NoError(t, err)
+	if attrs&AttrImmutable == 0 {
+		t.Skip("setting the immutable flag requires CAP_LINUX_IMMUTABLE")
+	}
+	defer unlockAttrs(name)
+
+	storage, err := CreateStorage(dir)
+	assert.NoError(t, err)
+	setter := storage.(MetadataSetter)
+	assert.NoError(t, setter.SetMetadata("/a.txt", Metadata{Perm: 0600, UID: -1, GID: -1, Attrs: AttrImmutable | AttrNoDump, AttrsKnown: true}))
+	attrs, err = readAttrs(name, lstat(t, name))
+	assert.NoError(t, err)
+	assert.Equal(t, AttrImmutable|AttrNoDump, attrs)
+	assert.Equal(t, os.FileMode(0600), lstat(t, name).Mode().Perm())
+
+	assert.NoError(t, setter.SetMetadata("/a.txt", Metadata{Perm: 0644, UID: -1, GID: -1, AttrsKnown: true}))
+	attrs, err = readAttrs(name, lstat(t, name))
+	assert.NoError(t, err)
+	assert.Zero(t, attrs)
+}
+
+func createFile(t *testing.T, name string, data []byte) string {
+	assert.NoError(t, os.WriteFile(name, data, 0644))
+	return name
+}
+
+func lstat(t *testing.T, name string) os.FileInfo {
+	info, err := os.Lstat(name)
+	assert.NoError(t, err)
+	return info
+}
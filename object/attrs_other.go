@@ -0,0 +1,28 @@
+//go:build !linux && !windows
+
+package object
+
+import (
+	"os"
+	"time"
+)
+
+// readAttrs is only supported on Linux and Windows
+func readAttrs(name string, info os.FileInfo) (FileAttrs, error) {
+	return 0, nil
+}
+
+// unlockAttrs is only supported on Linux and Windows
+func unlockAttrs(name string) error {
+	return nil
+}
+
+// writeAttrs is only supported on Linux and Windows, all attributes are lost
+func writeAttrs(name string, attrs FileAttrs) (FileAttrs, error) {
+	return attrs, nil
+}
+
+// fileTimes returns the modification time as creation and access times, like the listing always did
+func fileTimes(info os.FileInfo) (ctime, atime time.Time) {
+	return info.ModTime(), info.ModTime()
+}
@@ -0,0 +1,61 @@
+package object
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFileAttrsString 测试文件属性的格式化和解析
+func TestFileAttrsString(t *testing.T) {
+	cases := []struct {
+		name  string
+		attrs FileAttrs
+		want  string
+	}{
+		{name: "无属性", attrs: 0, want: ""},
+		{name: "chattr标志", attrs: AttrImmutable | AttrAppendOnly, want: "immutable,append-only"},
+		{name: "Windows属性", attrs: AttrHidden | AttrSystem | AttrArchive | AttrReadOnly, want: "readonly,hidden,system,archive"},
+		{name: "稀疏文件", attrs: AttrSparse | AttrNoDump, want: "nodump,sparse"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.want, c.attrs.String())
+			attrs, err := ParseFileAttrs(c.want)
+			assert.NoError(t, err)
+			assert.Equal(t, c.attrs, attrs)
+		})
+	}
+
+	_, err := ParseFileAttrs("immutable,compressed")
+	assert.Error(t, err)
+}
+
+// TestMemAttrs 测试内存存储保存文件属性，未知属性不修改目标
+func TestMemAttrs(t *testing.T) {
+	storage, err := CreateStorage("mem://attrs-test")
+	assert.NoError(t, err)
+	assert.NoError(t, storage.Put("/a.txt", strings.NewReader("a")))
+	setter := storage.(MetadataSetter)
+
+	assert.NoError(t, setter.SetMetadata("/a.txt", Metadata{Perm: 0644, Attrs: AttrHidden | AttrSparse, AttrsKnown: true}))
+	fi, err := storage.Head("/a.txt")
+	assert.NoError(t, err)
+	meta, err := MetadataOf(fi)
+	assert.NoError(t, err)
+	assert.True(t, meta.AttrsKnown)
+	assert.Equal(t, AttrHidden|AttrSparse, meta.Attrs)
+
+	assert.NoError(t, setter.SetMetadata("/a.txt", Metadata{Perm: 0644}))
+	fi, err = storage.Head("/a.txt")
+	assert.NoError(t, err)
+	attrs, err := fi.(AttrsProvider).Attrs()
+	assert.NoError(t, err)
+	assert.Equal(t, AttrHidden|AttrSparse, attrs)
+
+	// 零值的时间不修改目标
+	assert.Equal(t, fi.MTime(), meta.MTime)
+	assert.Equal(t, []string{"attrs"}, meta.Diff(Metadata{Perm: 0644, MTime: meta.MTime, AttrsKnown: true}))
+	assert.Empty(t, meta.Diff(Metadata{Perm: 0644, MTime: meta.MTime}))
+}
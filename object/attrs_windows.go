@@ -0,0 +1,91 @@
+//go:build windows
+
+package object
+
+import (
+	"os"
+	"syscall"
+	"terrasync/log"
+	"time"
+)
+
+// fileAttributeSparseFile is FILE_ATTRIBUTE_SPARSE_FILE, missing from syscall
+const fileAttributeSparseFile = 0x00000200
+
+// windowsAttrs maps the file attributes kept by SetMetadata
+var windowsAttrs = []struct {
+	attr FileAttrs
+	win  uint32
+}{
+	{AttrReadOnly, syscall.FILE_ATTRIBUTE_READONLY},
+	{AttrHidden, syscall.FILE_ATTRIBUTE_HIDDEN},
+	{AttrSystem, syscall.FILE_ATTRIBUTE_SYSTEM},
+	{AttrArchive, syscall.FILE_ATTRIBUTE_ARCHIVE},
+}
+
+// readAttrs reads the file attributes returned with the directory listing
+func readAttrs(name string, info os.FileInfo) (FileAttrs, error) {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return 0, nil
+	}
+	var attrs FileAttrs
+	for _, a := range windowsAttrs {
+		if data.FileAttributes&a.win != 0 {
+			attrs |= a.attr
+		}
+	}
+	if data.FileAttributes&fileAttributeSparseFile != 0 {
+		attrs |= AttrSparse
+	}
+	return attrs, nil
+}
+
+// unlockAttrs clears the readonly attribute, which would refuse the other metadata changes
+func unlockAttrs(name string) error {
+	p, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	current, err := syscall.GetFileAttributes(p)
+	if err != nil || current&syscall.FILE_ATTRIBUTE_READONLY == 0 {
+		return err
+	}
+	return syscall.SetFileAttributes(p, current&^syscall.FILE_ATTRIBUTE_READONLY)
+}
+
+// writeAttrs applies the file attributes of attrs and returns the attributes
+// Windows cannot keep. Sparse files are not recreated.
+func writeAttrs(name string, attrs FileAttrs) (FileAttrs, error) {
+	lost := attrs & (AttrImmutable | AttrAppendOnly | AttrNoDump | AttrSparse)
+
+	p, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return lost, err
+	}
+	current, err := syscall.GetFileAttributes(p)
+	if err != nil {
+		return lost, err
+	}
+	want := current
+	for _, a := range windowsAttrs {
+		want &^= a.win
+		if attrs&a.attr != 0 {
+			want |= a.win
+		}
+	}
+	if want == current {
+		return lost, nil
+	}
+	return lost, syscall.SetFileAttributes(p, want)
+}
+
+// fileTimes returns the creation and last access times returned with the directory listing
+func fileTimes(info os.FileInfo) (ctime, atime time.Time) {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		log.Warnf("failed to get system info for file: %s", info.Name())
+		return info.ModTime(), info.ModTime()
+	}
+	return time.Unix(0, data.CreationTime.Nanoseconds()), time.Unix(0, data.LastAccessTime.Nanoseconds())
+}
@@ -0,0 +1,472 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"terrasync/log"
+	"time"
+)
+
+const (
+	// authRefreshWindow renews temporary credentials this long before they expire
+	authRefreshWindow = 5 * time.Minute
+	// metadataTimeout bounds the requests to the instance metadata services
+	metadataTimeout = 5 * time.Second
+
+	defaultIMDSEndpoint   = "http://169.254.169.254"
+	defaultAssumeDuration = time.Hour
+	emptyPayloadSHA256    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// Credentials are the AWS keys the S3 backend signs its requests with (SigV4)
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expires         time.Time // 为零时不过期
+}
+
+// expired reports whether the credentials must be renewed at now
+func (c Credentials) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !now.Before(c.Expires.Add(-authRefreshWindow))
+}
+
+// AuthProvider supplies the credentials of a cloud backend. Backends ask for
+// them before every request, so rotated keys and renewed role sessions are
+// picked up by long jobs without a restart.
+type AuthProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// AuthConfig selects the credential provider of a storage profile
+type AuthConfig struct {
+	// Type is static, env, imds (EC2 instance role) or assume_role (STS).
+	// Empty uses env.
+	Type string `mapstructure:"type"`
+
+	// static
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key"`
+	SessionToken    string `mapstructure:"session_token"`
+
+	// assume_role: the role is assumed with the credentials of Source (static, env or imds)
+	RoleARN     string        `mapstructure:"role_arn"`
+	SessionName string        `mapstructure:"session_name"` // 默认terrasync
+	ExternalID  string        `mapstructure:"external_id"`
+	Duration    time.Duration `mapstructure:"duration"` // 会话时长，默认1h
+	Region      string        `mapstructure:"region"`   // STS区域，为空使用全局端点
+	Source      string        `mapstructure:"source"`   // 默认env
+
+	// Endpoint overrides the metadata service or STS endpoint
+	Endpoint string `mapstructure:"endpoint"`
+}
+
+// NewAuthProvider creates the provider of cfg, client sends the STS requests
+func NewAuthProvider(cfg AuthConfig, client *http.Client) (AuthProvider, error) {
+	metadata := &http.Client{Timeout: metadataTimeout, Transport: &http.Transport{Proxy: nil}}
+	switch strings.ToLower(cfg.Type) {
+	case "static":
+		if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+			return nil, fmt.Errorf("static credentials require access_key_id and secret_access_key")
+		}
+		return staticProvider{Credentials{AccessKeyID: cfg.AccessKeyID, SecretAccessKey: cfg.SecretAccessKey, SessionToken: cfg.SessionToken}}, nil
+	case "", "env":
+		return envProvider{}, nil
+	case "imds":
+		return newCachedProvider("imds", (&imdsProvider{endpoint: valueOr(cfg.Endpoint, defaultIMDSEndpoint), client: metadata}).fetch), nil
+	case "assume_role":
+		if cfg.RoleARN == "" {
+			return nil, fmt.Errorf("assume_role requires role_arn")
+		}
+		if strings.EqualFold(cfg.Source, "assume_role") {
+			return nil, fmt.Errorf("the source of assume_role cannot be assume_role")
+		}
+		source := cfg
+		source.Type, source.Endpoint = cfg.Source, ""
+		sourceProvider, err := NewAuthProvider(source, client)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source of assume_role: %w", err)
+		}
+		p := &assumeRoleProvider{cfg: cfg, source: sourceProvider, client: client}
+		return newCachedProvider("assume_role "+cfg.RoleARN, p.fetch), nil
+	default:
+		return nil, fmt.Errorf("unsupported auth type %s, expect static, env, imds or assume_role", cfg.Type)
+	}
+}
+
+func valueOr(value, def string) string {
+	if value != "" {
+		return value
+	}
+	return def
+}
+
+// staticProvider returns the keys of the configuration
+type staticProvider struct {
+	creds Credentials
+}
+
+func (p staticProvider) Credentials(ctx context.Context) (Credentials, error) {
+	return p.creds, nil
+}
+
+// envProvider reads AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN on every call
+type envProvider struct{}
+
+func (envProvider) Credentials(ctx context.Context) (Credentials, error) {
+	creds := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are not set")
+	}
+	return creds, nil
+}
+
+// cachedProvider keeps the temporary credentials of fetch until they are
+// about to expire. When renewing fails while the cached credentials are still
+// valid, they are used and renewing is tried again on the next call.
+type cachedProvider struct {
+	name  string
+	fetch func(ctx context.Context) (Credentials, error)
+	now   func() time.Time
+
+	mu    sync.Mutex
+	creds Credentials
+	valid bool
+}
+
+func newCachedProvider(name string, fetch func(ctx context.Context) (Credentials, error)) *cachedProvider {
+	return &cachedProvider{name: name, fetch: fetch, now: time.Now}
+}
+
+func (p *cachedProvider) Credentials(ctx context.Context) (Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	if p.valid && !p.creds.expired(now) {
+		return p.creds, nil
+	}
+	creds, err := p.fetch(ctx)
+	if err != nil {
+		if p.valid && now.Before(p.creds.Expires) {
+			log.Warnf("Failed to renew the %s credentials, using the current ones until %s: %v", p.name, p.creds.Expires.Format(time.RFC3339), err)
+			return p.creds, nil
+		}
+		return Credentials{}, fmt.Errorf("failed to get %s credentials: %w", p.name, err)
+	}
+	log.Infof("Got %s credentials, expiring %s", p.name, creds.Expires.Format(time.RFC3339))
+	p.creds, p.valid = creds, true
+	return creds, nil
+}
+
+// invalidate drops the cached credentials when they are still used, the
+// service rejected them as expired before the provider renewed them
+func (p *cachedProvider) invalidate(used Credentials) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.valid && sameCredentials(p.creds, used) {
+		p.valid = false
+	}
+}
+
+// sameCredentials reports whether a and b are the same keys
+func sameCredentials(a, b Credentials) bool {
+	return a.AccessKeyID == b.AccessKeyID && a.SessionToken == b.SessionToken
+}
+
+// getJSON sends req and decodes the JSON response into v, a *string gets the
+// plain text of the response such as an IMDS token
+func getJSON(client *http.Client, req *http.Request, v any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s %s", req.Method, req.URL.Redacted(), resp.Status, strings.TrimSpace(string(body)))
+	}
+	if v == nil {
+		return nil
+	}
+	if s, ok := v.(*string); ok {
+		*s = strings.TrimSpace(string(body))
+		return nil
+	}
+	return json.Unmarshal(body, v)
+}
+
+// imdsProvider reads the credentials of the IAM role of an EC2 instance with IMDSv2
+type imdsProvider struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (p *imdsProvider) fetch(ctx context.Context) (Credentials, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, p.endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return Credentials{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	var token string
+	if err := getJSON(p.client, req, &token); err != nil {
+		return Credentials{}, err
+	}
+
+	get := func(path string, v any) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.endpoint+path, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("X-aws-ec2-metadata-token", token)
+		return getJSON(p.client, req, v)
+	}
+	var roles string
+	if err := get("/latest/meta-data/iam/security-credentials/", &roles); err != nil {
+		return Credentials{}, err
+	}
+	role, _, _ := strings.Cut(roles, "\n")
+	if role == "" {
+		return Credentials{}, fmt.Errorf("no IAM role attached to the instance")
+	}
+	var resp struct {
+		AccessKeyID     string    `json:"AccessKeyId"`
+		SecretAccessKey string    `json:"SecretAccessKey"`
+		Token           string    `json:"Token"`
+		Expiration      time.Time `json:"Expiration"`
+	}
+	if err := get("/latest/meta-data/iam/security-credentials/"+role, &resp); err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{AccessKeyID: resp.AccessKeyID, SecretAccessKey: resp.SecretAccessKey, SessionToken: resp.Token, Expires: resp.Expiration}, nil
+}
+
+// assumeRoleProvider assumes an IAM role with STS AssumeRole
+type assumeRoleProvider struct {
+	cfg    AuthConfig
+	source AuthProvider
+	client *http.Client
+}
+
+func (p *assumeRoleProvider) fetch(ctx context.Context) (Credentials, error) {
+	source, err := p.source.Credentials(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+	duration := p.cfg.Duration
+	if duration <= 0 {
+		duration = defaultAssumeDuration
+	}
+	query := url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {"2011-06-15"},
+		"RoleArn":         {p.cfg.RoleARN},
+		"RoleSessionName": {valueOr(p.cfg.SessionName, "terrasync")},
+		"DurationSeconds": {strconv.Itoa(int(duration.Seconds()))},
+	}
+	if p.cfg.ExternalID != "" {
+		query.Set("ExternalId", p.cfg.ExternalID)
+	}
+	region, endpoint := p.cfg.Region, p.cfg.Endpoint
+	if region == "" {
+		region = "us-east-1"
+		endpoint = valueOr(endpoint, "https://sts.amazonaws.com")
+	}
+	endpoint = valueOr(endpoint, "https://sts."+region+".amazonaws.com")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/?"+query.Encode(), nil)
+	if err != nil {
+		return Credentials{}, err
+	}
+	signV4(req, source, region, "sts", emptyPayloadSHA256, time.Now())
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return Credentials{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Credentials{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Credentials{}, fmt.Errorf("AssumeRole %s: %s %s", p.cfg.RoleARN, resp.Status, strings.TrimSpace(string(body)))
+	}
+	var result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleResult>Credentials"`
+	}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return Credentials{}, fmt.Errorf("invalid AssumeRole response: %w", err)
+	}
+	c := result.Credentials
+	return Credentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey, SessionToken: c.SessionToken, Expires: c.Expiration}, nil
+}
+
+// expiredCredentialMarkers are the S3/STS error codes of requests whose
+// credentials expired
+var expiredCredentialMarkers = []string{
+	"ExpiredToken",
+	"TokenRefreshRequired",
+}
+
+// credentialsExpired reports whether the service rejected a request because
+// its credentials expired. The start of the body is read and put back.
+func credentialsExpired(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return true
+	case http.StatusBadRequest, http.StatusForbidden:
+	default:
+		return false
+	}
+	prefix, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(prefix), resp.Body), resp.Body}
+	for _, marker := range expiredCredentialMarkers {
+		if bytes.Contains(prefix, []byte(marker)) {
+			return true
+		}
+	}
+	return false
+}
+
+// authDo sends req signed by sign with the credentials of auth. Credentials
+// are renewed before they expire, but the service can still reject them, a
+// role session revoked or static keys rotated for instance. The request is then
+// signed again with renewed credentials and retried once, so long jobs don't
+// fail on the expiry of their credentials. A request whose body cannot be
+// replayed (no GetBody) is not retried.
+func authDo(client *http.Client, auth AuthProvider, req *http.Request, sign func(*http.Request, Credentials)) (*http.Response, error) {
+	ctx := req.Context()
+	creds, err := auth.Credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	signed := req.Clone(ctx)
+	sign(signed, creds)
+	resp, err := client.Do(signed)
+	if err != nil || !credentialsExpired(resp) {
+		return resp, err
+	}
+
+	cached, ok := auth.(*cachedProvider)
+	if !ok || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return resp, nil
+	}
+	cached.invalidate(creds)
+	renewed, err := auth.Credentials(ctx)
+	if err != nil || sameCredentials(renewed, creds) {
+		return resp, nil
+	}
+	log.Warnf("Credentials of %s %s expired, retrying with renewed credentials", req.Method, req.URL.Redacted())
+	retry := req.Clone(ctx)
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return resp, nil
+		}
+	}
+	resp.Body.Close()
+	sign(retry, renewed)
+	return client.Do(retry)
+}
+
+// signV4 signs req with AWS Signature Version 4, payloadHash is the hex
+// SHA-256 of the body or UNSIGNED-PAYLOAD
+func signV4(req *http.Request, creds Credentials, region, service, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, sigV4Escape(key)+"="+sigV4Escape(value))
+		}
+	}
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{req.Method, path, strings.Join(pairs, "&"), canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+creds.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// sigV4Escape percent-encodes everything but the unreserved characters
+func sigV4Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
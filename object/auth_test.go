@@ -0,0 +1,224 @@
+package object
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/log"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// TestSignV4 测试AWS签名第4版的参考用例(get-vanilla及带查询参数的get-vanilla-query-order-key-case)
+func TestSignV4(t *testing.T) {
+	creds := Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	cases := []struct {
+		name      string
+		url       string
+		signature string
+	}{
+		{name: "无参数", url: "https://example.amazonaws.com/", signature: "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{name: "参数排序", url: "https://example.amazonaws.com/?Param2=value2&Param1=value1", signature: "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, c.url, nil)
+			assert.NoError(t, err)
+			signV4(req, creds, "us-east-1", "service", emptyPayloadSHA256, now)
+			assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+				"SignedHeaders=host;x-amz-date, Signature="+c.signature, req.Header.Get("Authorization"))
+		})
+	}
+}
+
+// TestCachedProvider 测试临时凭据在过期前5分钟更新，更新失败时继续使用未过期的凭据
+func TestCachedProvider(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	now := time.Now()
+	fetches := 0
+	var fetchErr error
+	p := newCachedProvider("test", func(ctx context.Context) (Credentials, error) {
+		if fetchErr != nil {
+			return Credentials{}, fetchErr
+		}
+		fetches++
+		return Credentials{AccessKeyID: fmt.Sprintf("key%d", fetches), Expires: now.Add(time.Hour)}, nil
+	})
+	p.now = func() time.Time { return now }
+
+	creds, err := p.Credentials(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "key1", creds.AccessKeyID)
+	p.now = func() time.Time { return now.Add(50 * time.Minute) }
+	creds, _ = p.Credentials(context.Background())
+	assert.Equal(t, "key1", creds.AccessKeyID, "过期前使用缓存的凭据")
+
+	p.now = func() time.Time { return now.Add(56 * time.Minute) }
+	fetchErr = fmt.Errorf("metadata service unavailable")
+	creds, err = p.Credentials(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "key1", creds.AccessKeyID, "更新失败时使用未过期的凭据")
+
+	fetchErr = nil
+	creds, _ = p.Credentials(context.Background())
+	assert.Equal(t, "key2", creds.AccessKeyID)
+
+	p.now = func() time.Time { return now.Add(2 * time.Hour) }
+	fetchErr = fmt.Errorf("metadata service unavailable")
+	_, err = p.Credentials(context.Background())
+	assert.ErrorContains(t, err, "failed to get test credentials")
+}
+
+// TestAuthProviders 测试从模拟的元数据服务及STS获取各类凭据
+func TestAuthProviders(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			_, _ = io.WriteString(w, "imds-token")
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/":
+			if r.Header.Get("X-aws-ec2-metadata-token") != "imds-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = io.WriteString(w, "migration-role\n")
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/migration-role":
+			fmt.Fprintf(w, `{"AccessKeyId":"ASIAIMDS","SecretAccessKey":"secret","Token":"session","Expiration":"%s"}`, expires.Format(time.RFC3339))
+		case r.URL.Path == "/sts/":
+			if !strings.Contains(r.Header.Get("Authorization"), "Credential=AKIASTATIC/") || r.URL.Query().Get("RoleArn") != "arn:aws:iam::123456789012:role/migrate" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprintf(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><AssumeRoleResult><Credentials>
+<AccessKeyId>ASIAROLE</AccessKeyId><SecretAccessKey>role-secret</SecretAccessKey><SessionToken>role-session</SessionToken>
+<Expiration>%s</Expiration></Credentials></AssumeRoleResult></AssumeRoleResponse>`, expires.Format(time.RFC3339))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		name     string
+		cfg      AuthConfig
+		expected Credentials
+	}{
+		{name: "静态密钥", cfg: AuthConfig{Type: "static", AccessKeyID: "AKIASTATIC", SecretAccessKey: "secret"},
+			expected: Credentials{AccessKeyID: "AKIASTATIC", SecretAccessKey: "secret"}},
+		{name: "EC2实例角色", cfg: AuthConfig{Type: "imds", Endpoint: server.URL},
+			expected: Credentials{AccessKeyID: "ASIAIMDS", SecretAccessKey: "secret", SessionToken: "session", Expires: expires}},
+		{name: "STS扮演角色", cfg: AuthConfig{Type: "assume_role", Source: "static", AccessKeyID: "AKIASTATIC", SecretAccessKey: "secret",
+			RoleARN: "arn:aws:iam::123456789012:role/migrate", Region: "eu-west-1", Endpoint: server.URL + "/sts"},
+			expected: Credentials{AccessKeyID: "ASIAROLE", SecretAccessKey: "role-secret", SessionToken: "role-session", Expires: expires}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p, err := NewAuthProvider(c.cfg, http.DefaultClient)
+			assert.NoError(t, err)
+			creds, err := p.Credentials(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, c.expected.Expires.Equal(creds.Expires))
+			creds.Expires, c.expected.Expires = time.Time{}, time.Time{}
+			assert.Equal(t, c.expected, creds)
+		})
+	}
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAENV")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+	p, err := NewAuthProvider(AuthConfig{}, http.DefaultClient)
+	assert.NoError(t, err)
+	creds, err := p.Credentials(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "AKIAENV", creds.AccessKeyID)
+
+	for _, cfg := range []AuthConfig{
+		{Type: "static"},
+		{Type: "assume_role"},
+		{Type: "assume_role", RoleARN: "arn", Source: "assume_role"},
+		{Type: "azure_msi"},
+		{Type: "kerberos"},
+	} {
+		_, err := NewAuthProvider(cfg, http.DefaultClient)
+		assert.Error(t, err, cfg.Type)
+	}
+}
+
+// TestAuthDo 测试凭据在过期前被服务拒绝时更新凭据并重试一次请求
+func TestAuthDo(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if strings.Contains(r.Header.Get("Authorization"), "Credential=ASIAOLD/") {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `<Error><Code>ExpiredToken</Code><Message>The provided token has expired.</Message></Error>`)
+			return
+		}
+		if strings.Contains(r.Header.Get("Authorization"), "Credential=AKIADENIED/") {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(w, `<Error><Code>AccessDenied</Code></Error>`)
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	keys := []string{"ASIAOLD", "ASIANEW"}
+	fetches := 0
+	auth := newCachedProvider("test", func(ctx context.Context) (Credentials, error) {
+		creds := Credentials{AccessKeyID: keys[min(fetches, len(keys)-1)], SecretAccessKey: "secret", Expires: time.Now().Add(time.Hour)}
+		fetches++
+		return creds, nil
+	})
+	sign := func(req *http.Request, creds Credentials) {
+		signV4(req, creds, "us-east-1", "s3", emptyPayloadSHA256, time.Now())
+	}
+	req, err := http.NewRequest(http.MethodPut, server.URL+"/bucket/key", strings.NewReader("payload"))
+	assert.NoError(t, err)
+	resp, err := authDo(http.DefaultClient, auth, req, sign)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ok", string(body))
+	assert.Equal(t, []string{"payload", "payload"}, bodies, "重试时重新发送请求体")
+	assert.Equal(t, 2, fetches)
+
+	// 之后的请求使用更新后的凭据
+	resp, err = authDo(http.DefaultClient, auth, req, sign)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 2, fetches)
+	assert.Len(t, bodies, 3)
+
+	// 更新后仍是原凭据，或拒绝与过期无关时不重试，返回原响应
+	keys = []string{"ASIAOLD"}
+	fetches = 0
+	auth.invalidate(Credentials{AccessKeyID: "ASIANEW", SecretAccessKey: "secret"})
+	bodies = nil
+	resp, err = authDo(http.DefaultClient, auth, req, sign)
+	assert.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, string(body), "<Code>ExpiredToken</Code>", "读取的响应体放回")
+	assert.Len(t, bodies, 1)
+
+	denied := staticProvider{Credentials{AccessKeyID: "AKIADENIED", SecretAccessKey: "secret"}}
+	req, err = http.NewRequest(http.MethodGet, server.URL+"/bucket/key", nil)
+	assert.NoError(t, err)
+	resp, err = authDo(http.DefaultClient, denied, req, sign)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
@@ -0,0 +1,35 @@
+package object
+
+import "errors"
+
+// ErrCapacityUnknown is returned by storages that cannot report their capacity
+var ErrCapacityUnknown = errors.New("capacity unknown")
+
+// Capacity is the space available on a destination
+type Capacity struct {
+	Total int64 // 总容量，0表示未知
+	Free  int64 // 当前用户可用的空间
+	Quota int64 // 配额剩余空间，0表示没有配额或未知
+
+	TotalInodes int64 // inode总数，0表示未知(对象存储、Windows)
+	FreeInodes  int64 // 可用inode数
+}
+
+// Available returns the bytes that can still be written, the smaller of free space and quota
+func (c Capacity) Available() int64 {
+	if c.Quota > 0 && c.Quota < c.Free {
+		return c.Quota
+	}
+	return c.Free
+}
+
+// CapacityProvider is implemented by storages that can report their free space
+type CapacityProvider interface {
+	Capacity() (Capacity, error)
+}
+
+// Capacity reports the free space of the filesystem holding the local directory.
+// User quotas are reflected in the space available to the caller.
+func (s *localStorage) Capacity() (Capacity, error) {
+	return diskCapacity(s.scanPath)
+}
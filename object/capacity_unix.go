@@ -0,0 +1,25 @@
+//go:build !windows
+
+package object
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// diskCapacity returns the capacity of the filesystem holding path using statfs
+func diskCapacity(path string) (Capacity, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return Capacity{}, fmt.Errorf("statfs %s fail: %v", path, err)
+	}
+	bsize := int64(st.Bsize)
+	return Capacity{
+		Total: int64(st.Blocks) * bsize,
+		Free:  int64(st.Bavail) * bsize,
+		// 部分文件系统(如btrfs)动态分配inode，Files为0表示未知
+		TotalInodes: int64(st.Files),
+		FreeInodes:  int64(st.Ffree),
+	}, nil
+}
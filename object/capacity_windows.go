@@ -0,0 +1,23 @@
+//go:build windows
+
+package object
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// diskCapacity returns the capacity of the volume holding path, the free space
+// available to the caller already accounts for disk quotas
+func diskCapacity(path string) (Capacity, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return Capacity{}, err
+	}
+	var free, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &free, &total, &totalFree); err != nil {
+		return Capacity{}, fmt.Errorf("get disk free space of %s fail: %v", path, err)
+	}
+	return Capacity{Total: int64(total), Free: int64(free)}, nil
+}
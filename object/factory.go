@@ -0,0 +1,179 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// URI 存储路径解析结果
+type URI struct {
+	Raw     string        // 原始路径
+	Scheme  string        // 存储类型，如 s3, smb, nfs, file
+	User    *url.Userinfo // 用户信息(如S3的akey:skey)
+	Host    string        // 主机(NFS/SMB服务器或S3端点)
+	Path    string        // 主机上的路径或本地路径
+	Options url.Values    // 查询参数，由各存储类型解码为自己的选项结构体
+}
+
+// storageFactory 定义存储工厂函数类型
+type storageFactory func(uri *URI) (Storage, error)
+
+var (
+	factoriesMu sync.RWMutex
+	// factories 存储已注册的存储工厂
+	factories = make(map[string]storageFactory)
+	// stubs are the built-in schemes whose backend only parses its URI so far
+	stubs = make(map[string]bool)
+)
+
+// ErrNotImplemented is returned by every operation of the storage types without
+// a working backend, instead of listing nothing and dropping writes. Their URIs
+// are still parsed, e.g. for change list scans which only read metadata.
+var ErrNotImplemented = errors.New("storage backend is not implemented")
+
+// wrapStorage decorates every storage created by CreateStorage,
+// e.g. with fault injection in builds tagged faultinject
+var wrapStorage = func(s Storage) Storage { return s }
+
+// nfsPattern matches host:/export style NFS paths
+var nfsPattern = regexp.MustCompile(`^[a-zA-Z0-9.-]+:\S+$`)
+
+// windowsDrivePattern matches local Windows paths such as C:\data or D:/data
+var windowsDrivePattern = regexp.MustCompile(`^[a-zA-Z]:[\\/]`)
+
+// RegisterStorage 注册存储工厂
+// Out-of-tree backends register themselves from an init function in a file guarded
+// by a build tag, e.g. //go:build appliance, so no change to this package is needed.
+func RegisterStorage(scheme string, factory storageFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[strings.ToLower(scheme)] = factory
+	delete(stubs, strings.ToLower(scheme))
+}
+
+// registerStub registers a built-in scheme whose backend is not implemented,
+// a backend registered later for the scheme replaces it
+func registerStub(scheme string, factory storageFactory) {
+	RegisterStorage(scheme, factory)
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	stubs[scheme] = true
+}
+
+// CheckImplemented returns ErrNotImplemented when the backend of uri is a stub,
+// so that commands writing to it refuse it before doing any work
+func CheckImplemented(uri string) error {
+	scheme := StorageType(uri)
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	if stubs[scheme] {
+		return fmt.Errorf("%w: %s storage %s", ErrNotImplemented, scheme, uri)
+	}
+	return nil
+}
+
+// ParseURI parses a storage location: scheme://[user@]host/path[?options], host:/export (NFS),
+// - (tar stream on stdin/stdout) or a local path. Options are only recognized on scheme:// URIs since local file
+// names may contain '?'.
+func ParseURI(raw string) (*URI, error) {
+	if raw == StreamURI {
+		return &URI{Raw: raw, Scheme: "stream"}, nil
+	}
+
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid storage uri %s: %w", raw, err)
+		}
+		return &URI{
+			Raw:     raw,
+			Scheme:  strings.ToLower(u.Scheme),
+			User:    u.User,
+			Host:    u.Host,
+			Path:    u.Path,
+			Options: u.Query(),
+		}, nil
+	}
+
+	if nfsPattern.MatchString(raw) && !windowsDrivePattern.MatchString(raw) {
+		host, path, _ := strings.Cut(raw, ":")
+		return &URI{Raw: raw, Scheme: "nfs", Host: host, Path: path}, nil
+	}
+
+	return &URI{Raw: raw, Scheme: "file", Path: raw}, nil
+}
+
+// Redacted returns the URI with the password of its user info replaced by "xxxxx",
+// for logs and errors which must not leak the secret key of s3://akey:skey@host/bucket
+func (u *URI) Redacted() string {
+	if u.User == nil {
+		return u.Raw
+	}
+	if parsed, err := url.Parse(u.Raw); err == nil {
+		return parsed.Redacted()
+	}
+	return u.Scheme + "://" + u.Host + u.Path
+}
+
+// StorageType returns the backend type (scheme) for the provided URI, e.g. s3, smb, nfs or file
+func StorageType(uri string) string {
+	u, err := ParseURI(uri)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme
+}
+
+// CreateStorage creates a storage instance based on the provided URI
+func CreateStorage(scanPath string) (Storage, error) {
+	uri, err := ParseURI(scanPath)
+	if err != nil {
+		return nil, err
+	}
+
+	factoriesMu.RLock()
+	factory, exists := factories[uri.Scheme]
+	factoriesMu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("unsupported storage type %s for uri: %s", uri.Scheme, scanPath)
+	}
+
+	storage, err := factory(uri)
+	if err != nil {
+		return nil, err
+	}
+	return wrapStorage(storage), nil
+}
+
+// openLocalStorage creates a local storage for an existing directory
+func openLocalStorage(uri *URI) (Storage, error) {
+	fileInfo, err := os.Stat(uri.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat uri %s: %w", uri.Raw, err)
+	}
+	if !fileInfo.IsDir() {
+		return nil, fmt.Errorf("unsupported storage type for uri: %s", uri.Raw)
+	}
+	absPath, err := filepath.Abs(uri.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	return createLocalStorage(absPath)
+}
+
+// 初始化时注册内置存储类型
+func init() {
+	RegisterStorage("file", openLocalStorage)
+	registerStub("nfs", createNfs)
+	RegisterStorage("s3", createS3)
+	registerStub("smb", createSmb)
+	registerStub("cifs", createSmb)
+	RegisterStorage("stream", createStream)
+	RegisterStorage("mem", createMem)
+}
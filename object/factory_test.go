@@ -0,0 +1,126 @@
+package object
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseURI 测试存储路径解析
+func TestParseURI(t *testing.T) {
+	cases := []struct {
+		name   string
+		raw    string
+		scheme string
+		host   string
+		path   string
+	}{{
+		name:   "本地目录",
+		raw:    "/mnt/raid0/",
+		scheme: "file",
+		path:   "/mnt/raid0/",
+	}, {
+		name:   "Windows本地目录",
+		raw:    `C:\data`,
+		scheme: "file",
+		path:   `C:\data`,
+	}, {
+		name:   "NFS共享",
+		raw:    "192.168.22.11:/srcdir",
+		scheme: "nfs",
+		host:   "192.168.22.11",
+		path:   "/srcdir",
+	}, {
+		name:   "S3桶",
+		raw:    "S3://akey:skey@192.168.22.11.bucketname/xxx",
+		scheme: "s3",
+		host:   "192.168.22.11.bucketname",
+		path:   "/xxx",
+	}, {
+		name:   "SMB共享",
+		raw:    "smb://filer01/share/dir",
+		scheme: "smb",
+		host:   "filer01",
+		path:   "/share/dir",
+	}, {
+		name:   "标准输入输出",
+		raw:    "-",
+		scheme: "stream",
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			uri, err := ParseURI(tc.raw)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.scheme, uri.Scheme)
+			assert.Equal(t, tc.host, uri.Host)
+			assert.Equal(t, tc.path, uri.Path)
+		})
+	}
+}
+
+type fakeStorage struct {
+	uri *URI
+}
+
+func (s *fakeStorage) List(dir string) (<-chan FileInfo, error) { return nil, nil }
+func (s *fakeStorage) Head(key string) (FileInfo, error)        { return nil, nil }
+func (s *fakeStorage) Put(key string, in io.Reader) error       { return nil }
+func (s *fakeStorage) Delete(key string) error                  { return nil }
+func (s *fakeStorage) Close() error                             { return nil }
+
+// TestRegisterStorage 测试注册自定义存储类型
+func TestRegisterStorage(t *testing.T) {
+	_, err := CreateStorage("appliance://box01/vol1")
+	assert.Error(t, err)
+
+	RegisterStorage("appliance", func(uri *URI) (Storage, error) {
+		return &fakeStorage{uri: uri}, nil
+	})
+	storage, err := CreateStorage("appliance://box01/vol1")
+	assert.NoError(t, err)
+	assert.Equal(t, "box01", storage.(*fakeStorage).uri.Host)
+}
+
+// TestStubStorage 测试未实现的存储类型的操作返回错误，而不是静默地不列举也不写入
+func TestStubStorage(t *testing.T) {
+	var storage Storage
+	for _, uri := range []string{"nfs://filer01/export", "192.168.22.11:/srcdir", "smb://filer01/share", "cifs://filer01/share"} {
+		storage, err := CreateStorage(uri)
+		assert.NoError(t, err, uri)
+		_, err = storage.List("/")
+		assert.ErrorIs(t, err, ErrNotImplemented, uri)
+		assert.ErrorIs(t, storage.Put("/a", strings.NewReader("a")), ErrNotImplemented, uri)
+		assert.ErrorIs(t, CheckImplemented(uri), ErrNotImplemented, uri)
+	}
+	assert.NoError(t, CheckImplemented(t.TempDir()))
+	assert.NoError(t, CheckImplemented("mem://stub-test"))
+
+	// 注册了实现后不再拒绝
+	defer registerStub("cifs", createSmb)
+	RegisterStorage("cifs", func(uri *URI) (Storage, error) {
+		return &fakeStorage{uri: uri}, nil
+	})
+	assert.NoError(t, CheckImplemented("cifs://filer01/share"))
+	storage, err := CreateStorage("cifs://filer01/share")
+	assert.NoError(t, err)
+	assert.NoError(t, storage.Put("/a", strings.NewReader("a")))
+}
+
+// TestURIRedacted 测试日志中的URI隐去密钥
+func TestURIRedacted(t *testing.T) {
+	uri, err := ParseURI("s3://AKIAEXAMPLE:secret@s3.example.com/bucket/prefix?region=us-east-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://AKIAEXAMPLE:xxxxx@s3.example.com/bucket/prefix?region=us-east-1", uri.Redacted())
+	assert.NotContains(t, uri.Redacted(), "secret")
+
+	uri, err = ParseURI("/data/vol1")
+	assert.NoError(t, err)
+	assert.Equal(t, "/data/vol1", uri.Redacted())
+
+	storage, err := CreateStorage("s3://AKIAEXAMPLE:secret@s3.example.com/bucket")
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://AKIAEXAMPLE:xxxxx@s3.example.com/bucket", storage.(*s3Storage).uri)
+}
@@ -0,0 +1,203 @@
+//go:build faultinject
+
+package object
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"sync"
+	"terrasync/log"
+	"time"
+)
+
+// ErrInjectedFault is the error returned by injected failures
+var ErrInjectedFault = errors.New("injected fault")
+
+// FaultConfig configures fault injection, only available in builds tagged faultinject
+type FaultConfig struct {
+	Rate    float64       // 每次操作失败的概率，0~1
+	Latency time.Duration // 每次操作增加的最大随机延迟
+	Ops     []string      // 注入故障的操作: list, head, get, put, delete，为空表示全部
+	Seed    int64         // 随机数种子，0使用当前时间，便于复现
+}
+
+// faultInjector decides which operations fail
+type faultInjector struct {
+	cfg FaultConfig
+	ops map[string]bool
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// EnableFaultInjection wraps every storage created afterwards with random errors and latency
+func EnableFaultInjection(cfg FaultConfig) {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	inj := &faultInjector{cfg: cfg, rnd: rand.New(rand.NewSource(seed))}
+	if len(cfg.Ops) > 0 {
+		inj.ops = make(map[string]bool)
+		for _, op := range cfg.Ops {
+			inj.ops[strings.ToLower(strings.TrimSpace(op))] = true
+		}
+	}
+	log.Warnf("Fault injection enabled: rate %.3f, latency up to %s, ops %v, seed %d", cfg.Rate, cfg.Latency, cfg.Ops, seed)
+
+	wrapStorage = func(s Storage) Storage {
+		return &faultStorage{inner: s, inj: inj}
+	}
+}
+
+// inject sleeps for a random latency and returns an error at the configured rate
+func (f *faultInjector) inject(op, key string) error {
+	if f.ops != nil && !f.ops[op] {
+		return nil
+	}
+	f.mu.Lock()
+	delay := time.Duration(0)
+	if f.cfg.Latency > 0 {
+		delay = time.Duration(f.rnd.Int63n(int64(f.cfg.Latency)))
+	}
+	fail := f.rnd.Float64() < f.cfg.Rate
+	f.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	if fail {
+		log.Debugf("Injecting %s fault on %s", op, key)
+		return fmt.Errorf("%s %s: %w", op, key, ErrInjectedFault)
+	}
+	return nil
+}
+
+// faultStorage injects faults into the operations of a storage.
+// Optional capabilities of the wrapped storage are forwarded.
+type faultStorage struct {
+	inner Storage
+	inj   *faultInjector
+}
+
+func (s *faultStorage) List(dir string) (<-chan FileInfo, error) {
+	if err := s.inj.inject("list", dir); err != nil {
+		return nil, err
+	}
+	queue, err := s.inner.List(dir)
+	if err != nil || queue == nil {
+		return queue, err
+	}
+	out := make(chan FileInfo, listQueueLen)
+	go func() {
+		defer close(out)
+		for fileInfo := range queue {
+			out <- &faultFile{FileInfo: fileInfo, inj: s.inj}
+		}
+	}()
+	return out, nil
+}
+
+func (s *faultStorage) Head(key string) (FileInfo, error) {
+	if err := s.inj.inject("head", key); err != nil {
+		return nil, err
+	}
+	fileInfo, err := s.inner.Head(key)
+	if err != nil || fileInfo == nil {
+		return fileInfo, err
+	}
+	return &faultFile{FileInfo: fileInfo, inj: s.inj}, nil
+}
+
+func (s *faultStorage) Put(key string, in io.Reader) error {
+	if err := s.inj.inject("put", key); err != nil {
+		return err
+	}
+	return s.inner.Put(key, in)
+}
+
+func (s *faultStorage) Delete(key string) error {
+	if err := s.inj.inject("delete", key); err != nil {
+		return err
+	}
+	return s.inner.Delete(key)
+}
+
+func (s *faultStorage) Close() error {
+	return s.inner.Close()
+}
+
+func (s *faultStorage) PutEntry(key string, fileInfo FileInfo) error {
+	if err := s.inj.inject("put", key); err != nil {
+		return err
+	}
+	if w, ok := s.inner.(EntryWriter); ok {
+		return w.PutEntry(key, fileInfo)
+	}
+	in, err := fileInfo.Get(0, -1)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return s.inner.Put(key, in)
+}
+
+func (s *faultStorage) SetMetadata(key string, meta Metadata) error {
+	setter, ok := s.inner.(MetadataSetter)
+	if !ok {
+		return fmt.Errorf("set metadata of %s fail: not supported by storage", key)
+	}
+	if err := s.inj.inject("put", key); err != nil {
+		return err
+	}
+	return setter.SetMetadata(key, meta)
+}
+
+func (s *faultStorage) SetTags(key string, tags map[string]string) error {
+	tagger, ok := s.inner.(Tagger)
+	if !ok {
+		return fmt.Errorf("set tags of %s fail: not supported by storage", key)
+	}
+	if err := s.inj.inject("put", key); err != nil {
+		return err
+	}
+	return tagger.SetTags(key, tags)
+}
+
+func (s *faultStorage) Capacity() (Capacity, error) {
+	if provider, ok := s.inner.(CapacityProvider); ok {
+		return provider.Capacity()
+	}
+	return Capacity{}, ErrCapacityUnknown
+}
+
+func (s *faultStorage) ReliableDirMTime() bool {
+	return ReliableDirMTime(s.inner)
+}
+
+func (s *faultStorage) MTimeIsUploadTime() bool {
+	return !KeepsMTime(s.inner)
+}
+
+func (s *faultStorage) PoolStats() PoolStats {
+	if provider, ok := s.inner.(PoolStatsProvider); ok {
+		return provider.PoolStats()
+	}
+	return PoolStats{}
+}
+
+// faultFile injects faults into reads of a file
+type faultFile struct {
+	FileInfo
+	inj *faultInjector
+}
+
+func (f *faultFile) Get(offset, limit int64) (io.ReadCloser, error) {
+	if err := f.inj.inject("get", f.Key()); err != nil {
+		return nil, err
+	}
+	return f.FileInfo.Get(offset, limit)
+}
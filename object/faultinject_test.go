@@ -0,0 +1,39 @@
+//go:build faultinject
+
+package object
+
+import (
+	"errors"
+	"testing"
+
+	"terrasync/log"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// TestFaultInjection 测试故障注入
+func TestFaultInjection(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	defer func() { wrapStorage = func(s Storage) Storage { return s } }()
+
+	dir := t.TempDir()
+
+	// 只对put注入，失败率100%
+	EnableFaultInjection(FaultConfig{Rate: 1, Ops: []string{"put"}, Seed: 1})
+	s, err := CreateStorage(dir)
+	assert.NoError(t, err)
+	err = s.Put("/a.txt", nil)
+	assert.True(t, errors.Is(err, ErrInjectedFault))
+	_, err = s.List("/")
+	assert.NoError(t, err)
+
+	// 失败率0不影响任何操作，可选能力仍然可用
+	EnableFaultInjection(FaultConfig{Rate: 0, Seed: 1})
+	s, err = CreateStorage(dir)
+	assert.NoError(t, err)
+	_, err = s.List("/")
+	assert.NoError(t, err)
+	_, ok := s.(CapacityProvider)
+	assert.True(t, ok)
+}
@@ -0,0 +1,140 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"terrasync/log"
+)
+
+// fdWaitTimeout bounds how long an open waits for the budget. A listing holds
+// the descriptor of its directory while subdirectories may be walked inline, so
+// waiting forever could deadlock; after the timeout the open goes over budget.
+var fdWaitTimeout = 30 * time.Second
+
+// FDBudget limits the file descriptors opened by the local storage, so that
+// high concurrency on large trees queues opens instead of failing with EMFILE
+// deep into a job. A nil budget does not limit anything.
+type FDBudget struct {
+	sem chan struct{}
+
+	inUse    atomic.Int64
+	peak     atomic.Int64
+	waits    atomic.Int64
+	overruns atomic.Int64
+}
+
+// FDStats are the descriptor counters of a budget
+type FDStats struct {
+	Budget   int
+	InUse    int64
+	Peak     int64
+	Waits    int64 // 等待预算的打开次数
+	Overruns int64 // 等待超时后超出预算的打开次数
+}
+
+// NewFDBudget creates a budget of n descriptors
+func NewFDBudget(n int) *FDBudget {
+	return &FDBudget{sem: make(chan struct{}, max(n, 1))}
+}
+
+// Acquire reserves a descriptor, waiting while the budget is used up, and
+// returns the function releasing it
+func (b *FDBudget) Acquire() (release func()) {
+	if b == nil {
+		return func() {}
+	}
+	select {
+	case b.sem <- struct{}{}:
+	default:
+		b.waits.Add(1)
+		timer := time.NewTimer(fdWaitTimeout)
+		select {
+		case b.sem <- struct{}{}:
+			timer.Stop()
+		case <-timer.C:
+			if b.overruns.Add(1) == 1 {
+				log.Warnf("File descriptor budget of %d exhausted for %v, opening over budget", cap(b.sem), fdWaitTimeout)
+			}
+			b.track()
+			return func() { b.inUse.Add(-1) }
+		}
+	}
+	b.track()
+	var once atomic.Bool
+	return func() {
+		if once.CompareAndSwap(false, true) {
+			b.inUse.Add(-1)
+			<-b.sem
+		}
+	}
+}
+
+func (b *FDBudget) track() {
+	n := b.inUse.Add(1)
+	for {
+		peak := b.peak.Load()
+		if n <= peak || b.peak.CompareAndSwap(peak, n) {
+			return
+		}
+	}
+}
+
+// Stats returns the current counters
+func (b *FDBudget) Stats() FDStats {
+	if b == nil {
+		return FDStats{}
+	}
+	return FDStats{
+		Budget:   cap(b.sem),
+		InUse:    b.inUse.Load(),
+		Peak:     b.peak.Load(),
+		Waits:    b.waits.Load(),
+		Overruns: b.overruns.Load(),
+	}
+}
+
+func (s FDStats) String() string {
+	return fmt.Sprintf("peak %d of budget %d, %d opens waited, %d over budget", s.Peak, s.Budget, s.Waits, s.Overruns)
+}
+
+var fdBudget atomic.Pointer[FDBudget]
+
+// SetFDBudget sets the budget used by the local storage, nil removes the limit
+func SetFDBudget(b *FDBudget) {
+	fdBudget.Store(b)
+}
+
+// CurrentFDBudget returns the budget used by the local storage, or nil
+func CurrentFDBudget() *FDBudget {
+	return fdBudget.Load()
+}
+
+// AutoFDBudget sizes a budget from the NOFILE limit of the process, keeping a
+// reserve for the database, logs, sockets and the runtime. Returns nil when the
+// platform has no descriptor limit.
+func AutoFDBudget() (*FDBudget, uint64, error) {
+	limit, err := NOFILELimit()
+	if err != nil || limit == 0 {
+		return nil, 0, err
+	}
+	reserve := max(limit/10, 64)
+	if limit <= reserve*2 {
+		return NewFDBudget(int(limit / 2)), limit, nil
+	}
+	return NewFDBudget(int(limit - reserve)), limit, nil
+}
+
+// budgetCloser releases the descriptor of a file when it is closed
+type budgetCloser struct {
+	io.ReadCloser
+	release func()
+}
+
+func (c *budgetCloser) Close() error {
+	err := c.ReadCloser.Close()
+	c.release()
+	return err
+}
@@ -0,0 +1,86 @@
+package object
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/log"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// TestFDBudget 测试预算用完时打开操作等待，释放后继续
+func TestFDBudget(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	b := NewFDBudget(2)
+	r1 := b.Acquire()
+	r2 := b.Acquire()
+
+	acquired := make(chan func())
+	go func() { acquired <- b.Acquire() }()
+	select {
+	case <-acquired:
+		t.Fatal("acquired over budget")
+	case <-time.After(50 * time.Millisecond):
+	}
+	r1()
+	r1() // 重复释放不影响计数
+	r3 := <-acquired
+
+	stats := b.Stats()
+	assert.Equal(t, FDStats{Budget: 2, InUse: 2, Peak: 2, Waits: 1}, stats)
+	r2()
+	r3()
+	assert.Equal(t, int64(0), b.Stats().InUse)
+
+	// 等待超时后超出预算
+	saved := fdWaitTimeout
+	fdWaitTimeout = 10 * time.Millisecond
+	defer func() { fdWaitTimeout = saved }()
+	r1, r2 = b.Acquire(), b.Acquire()
+	over := b.Acquire()
+	assert.Equal(t, int64(1), b.Stats().Overruns)
+	assert.Equal(t, int64(3), b.Stats().Peak)
+	over()
+	r1()
+	r2()
+	assert.Equal(t, int64(0), b.Stats().InUse)
+
+	// nil预算不限制
+	var none *FDBudget
+	none.Acquire()()
+	assert.Equal(t, FDStats{}, none.Stats())
+}
+
+// TestLocalStorageFDBudget 测试本地存储在关闭目录和文件后释放描述符
+func TestLocalStorageFDBudget(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0644))
+	b := NewFDBudget(4)
+	SetFDBudget(b)
+	defer SetFDBudget(nil)
+
+	s, err := CreateStorage(dir)
+	assert.NoError(t, err)
+	keys := listKeys(t, s, "/")
+	assert.Equal(t, []string{"/a.txt"}, keys)
+
+	fi, err := s.Head("/a.txt")
+	assert.NoError(t, err)
+	r, err := fi.Get(0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), b.Stats().InUse)
+	data, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+	assert.NoError(t, r.Close())
+
+	assert.NoError(t, s.Put("/b.txt", strings.NewReader("x")))
+	assert.Eventually(t, func() bool { return b.Stats().InUse == 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(1), b.Stats().Peak)
+}
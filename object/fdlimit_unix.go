@@ -0,0 +1,22 @@
+//go:build !windows
+
+package object
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// NOFILELimit returns the soft RLIMIT_NOFILE of the process. The Go runtime
+// already raises the soft limit to the hard limit at startup on Linux and macOS.
+func NOFILELimit() (uint64, error) {
+	var rlim unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &rlim); err != nil {
+		return 0, fmt.Errorf("getrlimit NOFILE fail: %v", err)
+	}
+	if rlim.Cur == unix.RLIM_INFINITY {
+		return 0, nil
+	}
+	return uint64(rlim.Cur), nil
+}
@@ -0,0 +1,6 @@
+package object
+
+// NOFILELimit returns 0 on Windows, handles are only limited by memory
+func NOFILELimit() (uint64, error) {
+	return 0, nil
+}
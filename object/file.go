@@ -6,9 +6,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strings"
-	"syscall"
 	"terrasync/log"
 	"time"
 )
@@ -76,7 +74,29 @@ func (o *fileObject) IsSticky() bool {
 	return o.info.Mode()&os.ModeSticky != 0
 }
 
+func (o *fileObject) Owner() (int, int, bool) {
+	return fileOwner(o.info)
+}
+
+func (o *fileObject) ACL() (map[string][]byte, error) {
+	if o.IsSymlink() {
+		return nil, nil
+	}
+	return readACL(o.fullPath())
+}
+
+// Attrs returns the chattr flags or Windows file attributes
+func (o *fileObject) Attrs() (FileAttrs, error) {
+	return readAttrs(o.fullPath(), o.info)
+}
+
+// Stub reports whether the data of the file is offline
+func (o *fileObject) Stub() bool {
+	return isStub(o.fullPath(), o.info)
+}
+
 func (o *fileObject) Delete() error {
+	CurrentStatCache().forget(o.info)
 	err := os.Remove(o.fullPath())
 	if err != nil && os.IsNotExist(err) {
 		err = nil
@@ -92,18 +112,23 @@ func (o *fileObject) Get(offset, limit int64) (io.ReadCloser, error) {
 	if o.IsDir() || offset > o.Size() {
 		return io.NopCloser(bytes.NewBuffer([]byte{})), nil
 	}
+	release := CurrentFDBudget().Acquire()
 	f, err := os.Open(o.fullPath())
 	if err != nil {
+		release()
 		return nil, fmt.Errorf("open %s fail: %v", o.Key(), err)
 	}
 
 	if limit > 0 {
-		return &SectionReaderCloser{
-			SectionReader: io.NewSectionReader(f, offset, limit),
-			Closer:        f,
+		return &budgetCloser{
+			ReadCloser: &SectionReaderCloser{
+				SectionReader: io.NewSectionReader(f, offset, limit),
+				Closer:        f,
+			},
+			release: release,
 		}, nil
 	}
-	return f, nil
+	return &budgetCloser{ReadCloser: f, release: release}, nil
 }
 
 func (s *localStorage) fullPath(key string) string {
@@ -111,14 +136,20 @@ func (s *localStorage) fullPath(key string) string {
 }
 
 func (s *localStorage) List(dir string) (<-chan FileInfo, error) {
+	release := CurrentFDBudget().Acquire()
 	fp, err := os.Open(s.fullPath(dir))
 	if err != nil {
+		release()
 		return nil, fmt.Errorf("open %s fail: %v", s.fullPath(dir), err)
 	}
 	queue := make(chan FileInfo, listQueueLen)
 	go func() {
+		defer release()
 		defer fp.Close()
 		defer close(queue)
+		if cache := CurrentStatCache(); cache != nil && s.listCached(fp, dir, queue, cache) {
+			return
+		}
 		for {
 			files, err := fp.Readdir(listDirLen)
 			if err != nil {
@@ -138,19 +169,7 @@ func (s *localStorage) List(dir string) (<-chan FileInfo, error) {
 					root: &s.scanPath,
 				}
 
-				// 判断操作系统是否为Windows
-				if runtime.GOOS == "windows" {
-					if sysInfo, ok := file.Sys().(*syscall.Win32FileAttributeData); ok {
-						// 转换Windows文件时间到time.Time
-						fileObj.ctime = time.Unix(0, sysInfo.CreationTime.Nanoseconds())
-						fileObj.atime = time.Unix(0, sysInfo.LastAccessTime.Nanoseconds())
-					} else {
-						log.Warnf("failed to get system info for file: %s", file.Name())
-					}
-				} else {
-					fileObj.ctime = file.ModTime()
-					fileObj.atime = file.ModTime()
-				}
+				fileObj.ctime, fileObj.atime = fileTimes(file)
 
 				queue <- fileObj
 			}
@@ -159,8 +178,74 @@ func (s *localStorage) List(dir string) (<-chan FileInfo, error) {
 	return queue, nil
 }
 
+// listCached lists a directory through the stat cache, stating only the entries
+// whose inode is not cached. Returns false without listing anything when the
+// platform doesn't report inodes.
+func (s *localStorage) listCached(fp *os.File, dir string, queue chan<- FileInfo, cache *StatCache) bool {
+	dirInfo, err := fp.Stat()
+	if err != nil {
+		return false
+	}
+	dirID, ok := statFileID(dirInfo)
+	if !ok {
+		return false
+	}
+	buf := make([]byte, 32<<10)
+	for {
+		entries, err := readDirInodes(fp, buf)
+		if err != nil {
+			log.Errorf("read local file fail: %v", err)
+			return true
+		}
+		if len(entries) == 0 {
+			return true
+		}
+		for _, entry := range entries {
+			if entry.name == "." || entry.name == ".." {
+				continue
+			}
+			info, ok := cache.get(fileID{dev: dirID.dev, ino: entry.ino}, entry.name)
+			if !ok {
+				// 列举后被删除的条目跳过，与Readdir相同
+				info, err = os.Lstat(filepath.Join(s.fullPath(dir), entry.name))
+				if err != nil {
+					if !os.IsNotExist(err) {
+						log.Errorf("stat local file fail: %v", err)
+					}
+					continue
+				}
+				cache.put(info)
+			}
+			queue <- &fileObject{
+				info:  info,
+				dir:   dir,
+				root:  &s.scanPath,
+				ctime: info.ModTime(),
+				atime: info.ModTime(),
+			}
+		}
+	}
+}
+
+// Head returns the file of key, or nil if it does not exist. It always stats
+// the file and refreshes the stat cache.
 func (s *localStorage) Head(key string) (FileInfo, error) {
-	return nil, nil
+	info, err := os.Lstat(s.fullPath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("stat %s fail: %v", s.fullPath(key), err)
+	}
+	CurrentStatCache().put(info)
+	// Head不需要精确的ctime/atime，统一使用mtime
+	return &fileObject{
+		info:  info,
+		dir:   filepath.Dir(filepath.Join(dirSuffix, key)),
+		root:  &s.scanPath,
+		ctime: info.ModTime(),
+		atime: info.ModTime(),
+	}, nil
 }
 
 func (s *localStorage) Get(key string) (io.ReadCloser, error) {
@@ -170,9 +255,13 @@ func (s *localStorage) Get(key string) (io.ReadCloser, error) {
 func (s *localStorage) Put(key string, in io.Reader) error {
 	p := s.fullPath(key)
 
+	// 新建的条目改变了所在目录的时间
+	defer CurrentStatCache().refresh(filepath.Dir(p))
 	if strings.HasSuffix(key, dirSuffix) || key == "" && strings.HasSuffix(s.scanPath, dirSuffix) {
 		return os.MkdirAll(p, os.FileMode(0777))
 	}
+	release := CurrentFDBudget().Acquire()
+	defer release()
 	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
 	if err != nil && os.IsNotExist(err) {
 		if err = os.MkdirAll(filepath.Dir(p), os.FileMode(0777)); err != nil {
@@ -191,14 +280,74 @@ func (s *localStorage) Put(key string, in io.Reader) error {
 		_ = f.Close()
 		return err
 	}
+	// 截断写入保留原来的inode，更新缓存的大小和时间
+	defer CurrentStatCache().refresh(p)
 	return f.Close()
 }
 
+// SetMetadata applies the owner, permissions, ACLs, times and attributes of meta to an existing file.
+// Attributes the destination cannot keep are reported with an AttrsNotPreservedError.
+func (s *localStorage) SetMetadata(key string, meta Metadata) error {
+	p := s.fullPath(key)
+	info, err := os.Lstat(p)
+	if err != nil {
+		return fmt.Errorf("stat %s fail: %v", p, err)
+	}
+	defer CurrentStatCache().refresh(p)
+	// immutable/append-only(Windows上为只读)的目标拒绝其他修改，属性最后再设置
+	if meta.AttrsKnown {
+		if err := unlockAttrs(p); err != nil {
+			return fmt.Errorf("clear attributes of %s fail: %v", p, err)
+		}
+	}
+
+	if meta.UID >= 0 || meta.GID >= 0 {
+		if err := chown(p, meta.UID, meta.GID); err != nil {
+			return fmt.Errorf("chown %s fail: %v", p, err)
+		}
+	}
+	// 符号链接的权限和时间会作用到链接目标，跳过
+	if info.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+	if !meta.KeepPerm {
+		if err := os.Chmod(p, meta.Perm); err != nil {
+			return fmt.Errorf("chmod %s fail: %v", p, err)
+		}
+	}
+	// chmod会修改ACL的mask，所以ACL在权限之后设置
+	if meta.ACL != nil {
+		if err := writeACL(p, meta.ACL); err != nil {
+			return fmt.Errorf("set acl of %s fail: %v", p, err)
+		}
+	}
+	if err := os.Chtimes(p, meta.ATime, meta.MTime); err != nil {
+		return fmt.Errorf("chtimes %s fail: %v", p, err)
+	}
+	if meta.AttrsKnown {
+		lost, err := writeAttrs(p, meta.Attrs)
+		if err != nil {
+			return fmt.Errorf("set attributes of %s fail: %v", p, err)
+		}
+		if lost != 0 {
+			return &AttrsNotPreservedError{Key: key, Attrs: lost}
+		}
+	}
+	return nil
+}
+
 func (s *localStorage) Delete(key string) error {
+	// 删除后inode可能被新文件重用
+	if cache := CurrentStatCache(); cache != nil {
+		if info, err := os.Lstat(s.fullPath(key)); err == nil {
+			cache.forget(info)
+		}
+	}
 	err := os.Remove(s.fullPath(key))
 	if err != nil && os.IsNotExist(err) {
 		err = nil
 	}
+	CurrentStatCache().refresh(filepath.Dir(s.fullPath(key)))
 	return err
 }
 
@@ -206,6 +355,11 @@ func (s *localStorage) Close() error {
 	return nil
 }
 
+// ReliableDirMTime is true, adding, removing or renaming an entry updates the mtime of its directory
+func (s *localStorage) ReliableDirMTime() bool {
+	return true
+}
+
 func createLocalStorage(scanPath string) (Storage, error) {
 	return &localStorage{scanPath: scanPath}, nil
 }
@@ -0,0 +1,194 @@
+package object
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"terrasync/log"
+	"terrasync/security"
+	"time"
+)
+
+const (
+	defaultMaxIdleConns        = 1024
+	defaultMaxIdleConnsPerHost = 256
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultKeepAlive           = 30 * time.Second
+	defaultDialTimeout         = 30 * time.Second
+)
+
+// HTTPConfig configures the HTTP client shared by HTTP-based backends (S3, Azure, GCS, WebDAV)
+type HTTPConfig struct {
+	// Proxy overrides HTTP(S)_PROXY for this profile; "direct" disables the proxy
+	Proxy string `mapstructure:"proxy"`
+	// CABundle is a PEM file with additional CAs, e.g. for TLS-intercepting proxies
+	CABundle string `mapstructure:"ca_bundle"`
+
+	// Connection pool settings, zero values use defaults tuned for high concurrency
+	MaxIdleConns        int           `mapstructure:"max_idle_conns"`
+	MaxIdleConnsPerHost int           `mapstructure:"max_idle_conns_per_host"`
+	MaxConnsPerHost     int           `mapstructure:"max_conns_per_host"` // 0表示不限制
+	IdleConnTimeout     time.Duration `mapstructure:"idle_conn_timeout"`
+	KeepAlive           time.Duration `mapstructure:"keep_alive"` // TCP keep-alive周期，负数表示禁用
+	DisableHTTP2        bool          `mapstructure:"disable_http2"`
+}
+
+// PoolStats reports connection pool usage of an HTTP-based backend
+type PoolStats struct {
+	Requests    int64 // 请求总数
+	NewConns    int64 // 新建连接数
+	ReusedConns int64 // 复用连接次数
+	OpenConns   int64 // 当前打开的连接数
+}
+
+// poolMetrics collects PoolStats for one client
+type poolMetrics struct {
+	requests atomic.Int64
+	newConns atomic.Int64
+	reused   atomic.Int64
+	open     atomic.Int64
+}
+
+// countingConn decrements the open connection count when closed
+type countingConn struct {
+	net.Conn
+	metrics *poolMetrics
+	once    sync.Once
+}
+
+func (c *countingConn) Close() error {
+	c.once.Do(func() { c.metrics.open.Add(-1) })
+	return c.Conn.Close()
+}
+
+// metricsTransport counts requests and connection reuse
+type metricsTransport struct {
+	base    *http.Transport
+	metrics *poolMetrics
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.metrics.requests.Add(1)
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				t.metrics.reused.Add(1)
+			}
+		},
+	}
+	return t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+// CloseIdleConnections lets http.Client.CloseIdleConnections reach the pool
+func (t *metricsTransport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+}
+
+// newHTTPClient creates an HTTP client honoring the environment proxy settings,
+// the profile proxy override, the custom CA bundle, the pool and network settings
+func newHTTPClient(cfg HTTPConfig, netCfg NetworkConfig) (*http.Client, error) {
+	metrics := &poolMetrics{}
+
+	keepAlive := cfg.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = defaultKeepAlive
+	}
+	dialer := &net.Dialer{
+		Timeout:   defaultDialTimeout,
+		KeepAlive: keepAlive,
+	}
+	dial, err := newDialContext(dialer, netCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dial(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			metrics.newConns.Add(1)
+			metrics.open.Add(1)
+			return &countingConn{Conn: conn, metrics: metrics}, nil
+		},
+		MaxIdleConns:          valueOrDefault(cfg.MaxIdleConns, defaultMaxIdleConns),
+		MaxIdleConnsPerHost:   valueOrDefault(cfg.MaxIdleConnsPerHost, defaultMaxIdleConnsPerHost),
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       defaultIdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     !cfg.DisableHTTP2,
+	}
+	if cfg.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	if cfg.DisableHTTP2 {
+		// A non-nil empty map disables the automatic HTTP/2 upgrade
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	switch strings.ToLower(cfg.Proxy) {
+	case "":
+		transport.Proxy = http.ProxyFromEnvironment
+	case "direct", "none":
+		transport.Proxy = nil
+	default:
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy url: %s", cfg.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+		log.Infof("Using proxy %s", proxyURL.Redacted())
+	}
+
+	tlsConfig := security.TLSConfig()
+	if cfg.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(cfg.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca bundle %s: %w", cfg.CABundle, err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in ca bundle %s", cfg.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+		log.Infof("Loaded custom CA bundle %s", cfg.CABundle)
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{Transport: &metricsTransport{base: transport, metrics: metrics}}, nil
+}
+
+// httpPoolStats returns the connection pool statistics of a client created by newHTTPClient
+func httpPoolStats(client *http.Client) PoolStats {
+	t, ok := client.Transport.(*metricsTransport)
+	if !ok {
+		return PoolStats{}
+	}
+	return PoolStats{
+		Requests:    t.metrics.requests.Load(),
+		NewConns:    t.metrics.newConns.Load(),
+		ReusedConns: t.metrics.reused.Load(),
+		OpenConns:   t.metrics.open.Load(),
+	}
+}
+
+func valueOrDefault(value, def int) int {
+	if value > 0 {
+		return value
+	}
+	return def
+}
@@ -0,0 +1,116 @@
+package object
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"terrasync/log"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// TestHTTPPoolStats 测试连接池统计：连接被复用而不是每次新建
+func TestHTTPPoolStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	client, err := newHTTPClient(HTTPConfig{Proxy: "direct"}, NetworkConfig{})
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL)
+		assert.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	stats := httpPoolStats(client)
+	assert.Equal(t, int64(3), stats.Requests)
+	assert.Equal(t, int64(1), stats.NewConns)
+	assert.Equal(t, int64(2), stats.ReusedConns)
+	assert.Equal(t, int64(1), stats.OpenConns)
+
+	client.CloseIdleConnections()
+	assert.Equal(t, int64(0), httpPoolStats(client).OpenConns)
+}
+
+// TestHTTPClientInvalidSettings 测试无效的代理和CA配置
+func TestHTTPClientInvalidSettings(t *testing.T) {
+	_, err := newHTTPClient(HTTPConfig{Proxy: "://bad"}, NetworkConfig{})
+	assert.Error(t, err)
+
+	_, err = newHTTPClient(HTTPConfig{CABundle: "/nonexistent/ca.pem"}, NetworkConfig{})
+	assert.Error(t, err)
+
+	_, err = newHTTPClient(HTTPConfig{}, NetworkConfig{BindAddress: "127.0.0.1", IPPreference: "ipv6"})
+	assert.Error(t, err)
+}
+
+// TestS3ProxyAndCA 测试S3请求使用存储配置的CA证书校验TLS端点，并经过配置的代理发送
+func TestS3ProxyAndCA(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	defer SetProfiles(nil)
+
+	// 自签名证书的端点，只有加载了CA证书才能访问
+	fake := newFakeS3("bucket", "AKIATEST")
+	tlsServer := httptest.NewTLSServer(fake)
+	defer tlsServer.Close()
+	caBundle := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NoError(t, os.WriteFile(caBundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw}), 0644))
+	uri := fmt.Sprintf("s3://AKIATEST:secret@bucket/share?endpoint=%s&path_style=true", tlsServer.URL)
+
+	SetProfiles(map[string]Profile{"s3://": {HTTP: HTTPConfig{Proxy: "direct"}}})
+	storage, err := CreateStorage(uri)
+	assert.NoError(t, err)
+	assert.ErrorContains(t, storage.Put("/a.txt", strings.NewReader("a")), "certificate")
+	storage.Close()
+
+	SetProfiles(map[string]Profile{"s3://": {HTTP: HTTPConfig{Proxy: "direct", CABundle: caBundle}}})
+	storage, err = CreateStorage(uri)
+	assert.NoError(t, err)
+	assert.NoError(t, storage.Put("/a.txt", strings.NewReader("a")))
+	assert.Equal(t, []byte("a"), fake.objects["share/a.txt"])
+	storage.Close()
+
+	// 代理转发的请求
+	plain := httptest.NewServer(fake)
+	defer plain.Close()
+	var mu sync.Mutex
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied = append(proxied, r.Method+" "+r.URL.String())
+		mu.Unlock()
+		r.RequestURI = ""
+		resp, err := http.DefaultTransport.RoundTrip(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		for name, values := range resp.Header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+	}))
+	defer proxy.Close()
+
+	SetProfiles(map[string]Profile{"s3://": {HTTP: HTTPConfig{Proxy: proxy.URL}}})
+	storage, err = CreateStorage(fmt.Sprintf("s3://AKIATEST:secret@bucket/share?endpoint=%s&path_style=true", plain.URL))
+	assert.NoError(t, err)
+	defer storage.Close()
+	assert.Equal(t, []string{"/a.txt"}, listKeys(t, storage, "/"))
+	assert.Equal(t, []string{"GET " + plain.URL + "/bucket?delimiter=%2F&list-type=2&prefix=share%2F"}, proxied)
+}
@@ -1,12 +1,8 @@
 package object
 
 import (
-	"fmt"
 	"io"
 	"os"
-	"path/filepath"
-	"regexp"
-	"strings"
 	"sync"
 	"time"
 )
@@ -36,30 +32,36 @@ type Storage interface {
 	Close() error
 }
 
-// CreateStorage creates a storage instance based on the provided URI
-func CreateStorage(scanPath string) (Storage, error) {
-	if strings.HasPrefix(scanPath, "s3://") || strings.HasPrefix(scanPath, "S3://") {
-		return createS3(scanPath)
-	}
+// PoolStatsProvider is implemented by backends that keep a connection pool
+type PoolStatsProvider interface {
+	PoolStats() PoolStats
+}
 
-	nfsPattern := `^[a-zA-Z0-9.-]+:\S+$`
-	if regexp.MustCompile(nfsPattern).MatchString(scanPath) {
-		return createNfs(scanPath)
-	}
+// DirMTimeProvider is implemented by storages whose directory mtime changes
+// whenever an entry is added to, removed from or renamed in the directory, as on
+// POSIX filesystems. Object stores have no real directories and don't implement it.
+type DirMTimeProvider interface {
+	ReliableDirMTime() bool
+}
 
-	fileInfo, err := os.Stat(scanPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to stat uri %s: %w", scanPath, err)
-	}
-	if fileInfo.IsDir() {
-		absPath, err := filepath.Abs(scanPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get absolute path: %w", err)
-		}
-		return createLocalStorage(absPath)
-	}
+// ReliableDirMTime reports whether the directory mtimes of storage can be trusted
+// to detect changes of the directory entries
+func ReliableDirMTime(storage Storage) bool {
+	provider, ok := storage.(DirMTimeProvider)
+	return ok && provider.ReliableDirMTime()
+}
+
+// UploadTimeProvider is implemented by object storages whose objects report the
+// time they were uploaded as their mtime, the mtime of the source is not kept
+type UploadTimeProvider interface {
+	MTimeIsUploadTime() bool
+}
 
-	return nil, fmt.Errorf("unsupported storage type for uri: %s", scanPath)
+// KeepsMTime reports whether the files written to storage keep the mtime of
+// their source, so that it can be compared
+func KeepsMTime(storage Storage) bool {
+	provider, ok := storage.(UploadTimeProvider)
+	return !ok || !provider.MTimeIsUploadTime()
 }
 
 // BufferPoolSize defines the size of buffers in the buffer pool
@@ -72,3 +74,13 @@ var bufPool = sync.Pool{
 		return &buf
 	},
 }
+
+// GetBuffer returns a buffer of BufferPoolSize bytes from the pool, give it back with PutBuffer
+func GetBuffer() *[]byte {
+	return bufPool.Get().(*[]byte)
+}
+
+// PutBuffer returns a buffer obtained with GetBuffer to the pool
+func PutBuffer(buf *[]byte) {
+	bufPool.Put(buf)
+}
@@ -0,0 +1,120 @@
+package object
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"terrasync/log"
+
+	"github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+)
+
+const defaultKrb5Conf = "/etc/krb5.conf"
+
+// KerberosConfig configures krb5 authentication for NFSv4 and SMB backends.
+// Either Keytab or CCache is used; when both are empty the default credential
+// cache ($KRB5CCNAME or /tmp/krb5cc_<uid>) is used.
+// TODO: the NFS and SMB backends are stubs, the session only checks that the
+// credentials can obtain a service ticket and is not used by any request yet
+type KerberosConfig struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	Principal string `mapstructure:"principal"` // user@REALM
+	Keytab    string `mapstructure:"keytab"`
+	CCache    string `mapstructure:"ccache"`
+	Krb5Conf  string `mapstructure:"krb5_conf"`
+	SPN       string `mapstructure:"spn"` // 服务主体，默认为 <service>/<host>
+}
+
+// kerberosSession holds a logged-in Kerberos client for one storage instance
+type kerberosSession struct {
+	client *client.Client
+	spn    string
+}
+
+// newKerberosSession logs in with the configured credentials and verifies that a
+// service ticket can be obtained for service/host
+func newKerberosSession(cfg KerberosConfig, service, host string) (*kerberosSession, error) {
+	krb5Path := cfg.Krb5Conf
+	if krb5Path == "" {
+		krb5Path = defaultKrb5Conf
+	}
+	krb5conf, err := config.Load(krb5Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load krb5 config %s: %w", krb5Path, err)
+	}
+
+	var cl *client.Client
+	if cfg.Keytab != "" {
+		user, realm, err := splitPrincipal(cfg.Principal, krb5conf.LibDefaults.DefaultRealm)
+		if err != nil {
+			return nil, err
+		}
+		kt, err := keytab.Load(cfg.Keytab)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load keytab %s: %w", cfg.Keytab, err)
+		}
+		cl = client.NewWithKeytab(user, realm, kt, krb5conf, client.DisablePAFXFAST(true))
+		if err := cl.Login(); err != nil {
+			return nil, fmt.Errorf("failed to log in to kerberos as %s: %w", cfg.Principal, err)
+		}
+	} else {
+		ccachePath := cfg.CCache
+		if ccachePath == "" {
+			ccachePath = defaultCCachePath()
+		}
+		ccache, err := credentials.LoadCCache(ccachePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load credential cache %s: %w", ccachePath, err)
+		}
+		cl, err = client.NewFromCCache(ccache, krb5conf, client.DisablePAFXFAST(true))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kerberos client from %s: %w", ccachePath, err)
+		}
+	}
+
+	spn := cfg.SPN
+	if spn == "" {
+		spn = service + "/" + host
+	}
+	if _, _, err := cl.GetServiceTicket(spn); err != nil {
+		cl.Destroy()
+		return nil, fmt.Errorf("failed to get service ticket for %s: %w", spn, err)
+	}
+	log.Infof("Kerberos authenticated as %s for %s", cl.Credentials.CName().PrincipalNameString(), spn)
+
+	return &kerberosSession{client: cl, spn: spn}, nil
+}
+
+// Close destroys the Kerberos client and its tickets
+func (k *kerberosSession) Close() {
+	if k != nil && k.client != nil {
+		k.client.Destroy()
+	}
+}
+
+// splitPrincipal splits user@REALM, falling back to the default realm
+func splitPrincipal(principal, defaultRealm string) (string, string, error) {
+	if principal == "" {
+		return "", "", fmt.Errorf("kerberos principal is required when using a keytab")
+	}
+	user, realm, found := strings.Cut(principal, "@")
+	if !found {
+		realm = defaultRealm
+	}
+	if realm == "" {
+		return "", "", fmt.Errorf("no realm in principal %s and no default realm configured", principal)
+	}
+	return user, realm, nil
+}
+
+// defaultCCachePath returns the credential cache used by kinit
+func defaultCCachePath() string {
+	if name := os.Getenv("KRB5CCNAME"); name != "" {
+		return strings.TrimPrefix(name, "FILE:")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("krb5cc_%d", os.Getuid()))
+}
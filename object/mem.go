@@ -0,0 +1,374 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemOptions 由 mem://name?files=1000&depth=3&size=4096&seed=1 中的参数解码
+// When files is set the store is seeded with a reproducible tree the first time it is opened.
+type MemOptions struct {
+	Files int   `uri:"files"` // 预置的文件数
+	Depth int   `uri:"depth"` // 预置目录的深度
+	Size  int64 `uri:"size"`  // 预置文件的平均大小
+	Seed  int64 `uri:"seed"`  // 预置内容的随机数种子
+	// Discard drops the content written to the store and keeps only the metadata,
+	// so that large copies can be benchmarked without holding the data in memory
+	Discard bool `uri:"discard"`
+}
+
+// memStores keeps the in-memory stores by name, so that mem://name refers to the
+// same data for the whole process, e.g. as a migrate destination and a verify source
+var (
+	memStoresMu sync.Mutex
+	memStores   = make(map[string]*memStore)
+)
+
+// memStore is a named in-memory tree
+type memStore struct {
+	mu       sync.RWMutex
+	objects  map[string]*memObject // key -> 对象
+	children map[string][]string   // 目录 -> 子条目key
+	discard  bool                  // 写入的内容只计大小不保存
+}
+
+// memObject is a file or directory in memory. Seeded files keep only their seed
+// and generate their content on read, discarded files read as zeros.
+type memObject struct {
+	key   string // 相对于存储根目录的key
+	full  string // 在memStore中的完整key
+	size  int64
+	dir   bool
+	perm  os.FileMode
+	mtime time.Time
+	atime time.Time
+	data  []byte
+	seed  int64
+	tags  map[string]string
+	attrs FileAttrs
+	store *memStore
+}
+
+func (o *memObject) Key() string {
+	return o.key
+}
+
+func (o *memObject) Size() int64 {
+	return o.size
+}
+
+func (o *memObject) MTime() time.Time {
+	return o.mtime
+}
+
+func (o *memObject) CTime() time.Time {
+	return o.mtime
+}
+
+func (o *memObject) ATime() time.Time {
+	return o.atime
+}
+
+func (o *memObject) Perm() os.FileMode {
+	return o.perm
+}
+
+func (o *memObject) IsDir() bool {
+	return o.dir
+}
+
+func (o *memObject) IsSymlink() bool {
+	return false
+}
+
+func (o *memObject) IsRegular() bool {
+	return !o.dir
+}
+
+func (o *memObject) IsSticky() bool {
+	return false
+}
+
+func (o *memObject) Get(offset, limit int64) (io.ReadCloser, error) {
+	if o.dir || offset > o.size {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	n := o.size - offset
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	if o.data != nil {
+		return io.NopCloser(bytes.NewReader(o.data[offset : offset+n])), nil
+	}
+
+	var r io.Reader = zeroReader{}
+	if o.seed != 0 {
+		// 按种子流式生成内容，跳过offset之前的部分
+		r = rand.New(rand.NewSource(o.seed))
+		if _, err := io.CopyN(io.Discard, r, offset); err != nil {
+			return nil, err
+		}
+	}
+	return io.NopCloser(io.LimitReader(r, n)), nil
+}
+
+// zeroReader reads the content of discarded files
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// Attrs returns the attributes set with SetMetadata
+func (o *memObject) Attrs() (FileAttrs, error) {
+	return o.attrs, nil
+}
+
+// Tags returns the tags set with SetTags
+func (o *memObject) Tags() map[string]string {
+	return maps.Clone(o.tags)
+}
+
+func (o *memObject) Delete() error {
+	o.store.remove(o.full)
+	return nil
+}
+
+// memStorage is a view of a memStore below root
+type memStorage struct {
+	store *memStore
+	root  string
+}
+
+func (s *memStorage) fullKey(key string) string {
+	return path.Join("/", s.root, key)
+}
+
+// view returns a copy of an object with its key relative to the storage root
+func (s *memStorage) view(o *memObject) FileInfo {
+	c := *o
+	c.key = path.Join("/", strings.TrimPrefix(o.full, path.Join("/", s.root)))
+	return &c
+}
+
+func (s *memStorage) List(dir string) (<-chan FileInfo, error) {
+	full := s.fullKey(dir)
+	s.store.mu.RLock()
+	if o, ok := s.store.objects[full]; full != "/" && (!ok || !o.dir) {
+		s.store.mu.RUnlock()
+		return nil, fmt.Errorf("open %s fail: no such directory", full)
+	}
+	entries := make([]FileInfo, 0, len(s.store.children[full]))
+	for _, key := range s.store.children[full] {
+		entries = append(entries, s.view(s.store.objects[key]))
+	}
+	s.store.mu.RUnlock()
+
+	queue := make(chan FileInfo, len(entries))
+	for _, e := range entries {
+		queue <- e
+	}
+	close(queue)
+	return queue, nil
+}
+
+func (s *memStorage) Head(key string) (FileInfo, error) {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+	if o, ok := s.store.objects[s.fullKey(key)]; ok {
+		return s.view(o), nil
+	}
+	return nil, nil
+}
+
+func (s *memStorage) Put(key string, in io.Reader) error {
+	full := s.fullKey(key)
+	if strings.HasSuffix(key, dirSuffix) {
+		s.store.mu.Lock()
+		s.store.mkdirAll(full)
+		s.store.mu.Unlock()
+		return nil
+	}
+
+	var data []byte
+	var size int64
+	if in != nil {
+		var err error
+		if s.store.discard {
+			size, err = io.Copy(io.Discard, in)
+		} else {
+			data, err = io.ReadAll(in)
+			size = int64(len(data))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	s.store.put(&memObject{full: full, size: size, perm: 0644, mtime: now, atime: now, data: data})
+	return nil
+}
+
+func (s *memStorage) Delete(key string) error {
+	s.store.remove(s.fullKey(key))
+	return nil
+}
+
+// SetMetadata applies permissions, times and attributes, owners and ACLs are not kept in memory
+func (s *memStorage) SetMetadata(key string, meta Metadata) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	o, ok := s.store.objects[s.fullKey(key)]
+	if !ok {
+		return fmt.Errorf("set metadata of %s fail: no such file", key)
+	}
+	if !meta.KeepPerm {
+		o.perm = meta.Perm
+	}
+	if !meta.MTime.IsZero() {
+		o.mtime = meta.MTime
+	}
+	if !meta.ATime.IsZero() {
+		o.atime = meta.ATime
+	}
+	if meta.AttrsKnown {
+		o.attrs = meta.Attrs
+	}
+	return nil
+}
+
+// SetTags replaces the tags of an existing file
+func (s *memStorage) SetTags(key string, tags map[string]string) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	o, ok := s.store.objects[s.fullKey(key)]
+	if !ok {
+		return fmt.Errorf("set tags of %s fail: no such file", key)
+	}
+	o.tags = maps.Clone(tags)
+	return nil
+}
+
+func (s *memStorage) Close() error {
+	return nil
+}
+
+// put adds or replaces an object, creating its parent directories
+func (m *memStore) put(o *memObject) {
+	o.store = m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	parent := path.Dir(o.full)
+	m.mkdirAll(parent)
+	if _, exists := m.objects[o.full]; !exists {
+		m.children[parent] = append(m.children[parent], o.full)
+	}
+	m.objects[o.full] = o
+}
+
+// mkdirAll creates a directory and its parents, the caller holds the lock
+func (m *memStore) mkdirAll(dir string) {
+	if dir == "/" {
+		return
+	}
+	if _, exists := m.objects[dir]; exists {
+		return
+	}
+	parent := path.Dir(dir)
+	m.mkdirAll(parent)
+	now := time.Now()
+	m.objects[dir] = &memObject{full: dir, dir: true, perm: 0755, mtime: now, atime: now, store: m}
+	m.children[parent] = append(m.children[parent], dir)
+}
+
+// remove deletes an object and everything below it
+func (m *memStore) remove(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.objects[key]; !ok {
+		return
+	}
+	m.removeTree(key)
+
+	parent := path.Dir(key)
+	siblings := m.children[parent]
+	for i, sibling := range siblings {
+		if sibling == key {
+			m.children[parent] = append(siblings[:i:i], siblings[i+1:]...)
+			break
+		}
+	}
+}
+
+// removeTree deletes key and its descendants, the caller holds the lock
+func (m *memStore) removeTree(key string) {
+	for _, child := range m.children[key] {
+		m.removeTree(child)
+	}
+	delete(m.children, key)
+	delete(m.objects, key)
+}
+
+// seed fills the store with a reproducible tree of files spread over depth levels
+func (m *memStore) seed(opts MemOptions) {
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	depth := max(opts.Depth, 1)
+	mtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < opts.Files; i++ {
+		dir := "/"
+		levels := rnd.Intn(depth + 1)
+		for level := 0; level < levels; level++ {
+			dir = path.Join(dir, fmt.Sprintf("d%d", rnd.Intn(10)))
+		}
+		size := int64(0)
+		if opts.Size > 0 {
+			size = rnd.Int63n(2 * opts.Size)
+		}
+		modified := mtime.Add(time.Duration(rnd.Int63n(int64(5 * 365 * 24 * time.Hour))))
+		m.put(&memObject{
+			full:  path.Join(dir, fmt.Sprintf("f%d.dat", i)),
+			size:  size,
+			perm:  0644,
+			mtime: modified,
+			atime: modified,
+			seed:  rnd.Int63(),
+		})
+	}
+	for _, children := range m.children {
+		sort.Strings(children)
+	}
+}
+
+// createMem opens the in-memory store named by mem://name[/root]
+func createMem(uri *URI) (Storage, error) {
+	if uri.Host == "" {
+		return nil, fmt.Errorf("invalid mem uri, expect mem://name/path: %s", uri.Raw)
+	}
+	var opts MemOptions
+	if err := DecodeOptions(uri.Options, &opts); err != nil {
+		return nil, fmt.Errorf("invalid mem uri %s: %w", uri.Raw, err)
+	}
+
+	memStoresMu.Lock()
+	store, ok := memStores[uri.Host]
+	if !ok {
+		store = &memStore{objects: make(map[string]*memObject), children: make(map[string][]string), discard: opts.Discard}
+		memStores[uri.Host] = store
+		if opts.Files > 0 {
+			store.seed(opts)
+		}
+	}
+	memStoresMu.Unlock()
+
+	return &memStorage{store: store, root: uri.Path}, nil
+}
@@ -0,0 +1,76 @@
+package object
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func listKeys(t *testing.T, s Storage, dir string) []string {
+	queue, err := s.List(dir)
+	assert.NoError(t, err)
+	var keys []string
+	for fi := range queue {
+		keys = append(keys, fi.Key())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// TestMemStorage 测试内存存储的基本操作
+func TestMemStorage(t *testing.T) {
+	s, err := CreateStorage("mem://test-basic")
+	assert.NoError(t, err)
+	assert.NoError(t, s.Put("/a/b/c.txt", strings.NewReader("hello")))
+	assert.NoError(t, s.Put("/a/d.txt", strings.NewReader("x")))
+	assert.NoError(t, s.Put("/e/", nil))
+
+	assert.Equal(t, []string{"/a", "/e"}, listKeys(t, s, "/"))
+	assert.Equal(t, []string{"/a/b", "/a/d.txt"}, listKeys(t, s, "/a"))
+
+	fi, err := s.Head("/a/b/c.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), fi.Size())
+	r, err := fi.Get(1, 3)
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(r)
+	assert.Equal(t, "ell", string(data))
+
+	// 同名的mem://指向同一份数据，子路径作为根目录
+	sub, err := CreateStorage("mem://test-basic/a")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/b", "/d.txt"}, listKeys(t, sub, "/"))
+
+	assert.NoError(t, s.Delete("/a"))
+	assert.Equal(t, []string{"/e"}, listKeys(t, s, "/"))
+	fi, err = s.Head("/a/b/c.txt")
+	assert.NoError(t, err)
+	assert.Nil(t, fi)
+}
+
+// TestMemStorageSeed 测试预置内容可复现
+func TestMemStorageSeed(t *testing.T) {
+	a, err := CreateStorage("mem://seed-a?files=50&depth=2&size=100&seed=7")
+	assert.NoError(t, err)
+	b, err := CreateStorage("mem://seed-b?files=50&depth=2&size=100&seed=7")
+	assert.NoError(t, err)
+	assert.Equal(t, listKeys(t, a, "/"), listKeys(t, b, "/"))
+
+	fa, _ := a.Head("/f0.dat")
+	if fa == nil {
+		keys := listKeys(t, a, "/")
+		fa, _ = a.Head(keys[len(keys)-1])
+	}
+	fb, _ := b.Head(fa.Key())
+	ra, _ := fa.Get(0, -1)
+	rb, _ := fb.Get(0, -1)
+	da, _ := io.ReadAll(ra)
+	db, _ := io.ReadAll(rb)
+	assert.Equal(t, da, db)
+
+	_, err = CreateStorage("mem://seed-c?color=red")
+	assert.Error(t, err)
+}
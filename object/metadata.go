@@ -0,0 +1,113 @@
+package object
+
+import (
+	"bytes"
+	"os"
+	"time"
+)
+
+// Metadata is the file metadata that can be re-applied without copying data
+type Metadata struct {
+	Perm  os.FileMode
+	MTime time.Time
+	ATime time.Time
+	UID   int               // 所有者，-1表示未知
+	GID   int               // 所属组，-1表示未知
+	ACL   map[string][]byte // ACL扩展属性(如system.posix_acl_access)，nil表示未知
+	Attrs FileAttrs         // chattr标志及Windows文件属性
+	// AttrsKnown 为false表示属性未知，SetMetadata不修改目标的属性
+	AttrsKnown bool
+	// KeepPerm 为true时SetMetadata不修改目标的权限，零值的时间同样不修改
+	KeepPerm bool
+}
+
+// OwnerProvider is implemented by files that know their owner
+type OwnerProvider interface {
+	Owner() (uid, gid int, ok bool)
+}
+
+// ACLProvider is implemented by files whose ACLs can be read
+type ACLProvider interface {
+	ACL() (map[string][]byte, error)
+}
+
+// MetadataSetter is implemented by storages that can change the metadata of an existing file
+type MetadataSetter interface {
+	SetMetadata(key string, meta Metadata) error
+}
+
+// Tagger is implemented by object storages that can tag existing objects, e.g. for lifecycle rules
+type Tagger interface {
+	SetTags(key string, tags map[string]string) error
+}
+
+// TagsProvider is implemented by objects that know their tags
+type TagsProvider interface {
+	Tags() map[string]string
+}
+
+// MetadataOf collects the metadata of a file
+func MetadataOf(fileInfo FileInfo) (Metadata, error) {
+	meta := Metadata{
+		Perm:  fileInfo.Perm(),
+		MTime: fileInfo.MTime(),
+		ATime: fileInfo.ATime(),
+		UID:   -1,
+		GID:   -1,
+	}
+	if owner, ok := fileInfo.(OwnerProvider); ok {
+		if uid, gid, ok := owner.Owner(); ok {
+			meta.UID, meta.GID = uid, gid
+		}
+	}
+	if provider, ok := fileInfo.(ACLProvider); ok {
+		acl, err := provider.ACL()
+		if err != nil {
+			return meta, err
+		}
+		meta.ACL = acl
+	}
+	if provider, ok := fileInfo.(AttrsProvider); ok {
+		attrs, err := provider.Attrs()
+		if err != nil {
+			return meta, err
+		}
+		meta.Attrs, meta.AttrsKnown = attrs, true
+	}
+	return meta, nil
+}
+
+// Diff returns the names of the fields of target that differ from m.
+// Unknown owners, ACLs and attributes are ignored and times are compared at second precision,
+// since many filesystems don't keep nanoseconds.
+func (m Metadata) Diff(target Metadata) []string {
+	var diff []string
+	if m.Perm != target.Perm {
+		diff = append(diff, "perm")
+	}
+	if (m.UID >= 0 && target.UID >= 0 && m.UID != target.UID) || (m.GID >= 0 && target.GID >= 0 && m.GID != target.GID) {
+		diff = append(diff, "owner")
+	}
+	if m.ACL != nil && target.ACL != nil && !equalACL(m.ACL, target.ACL) {
+		diff = append(diff, "acl")
+	}
+	if m.AttrsKnown && target.AttrsKnown && m.Attrs != target.Attrs {
+		diff = append(diff, "attrs")
+	}
+	if !m.MTime.Truncate(time.Second).Equal(target.MTime.Truncate(time.Second)) {
+		diff = append(diff, "mtime")
+	}
+	return diff
+}
+
+func equalACL(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, value := range a {
+		if other, ok := b[name]; !ok || !bytes.Equal(value, other) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,19 @@
+package object
+
+import "time"
+
+// IncompleteUpload is a multipart upload that was never completed or aborted,
+// e.g. after the migration was killed. Its parts are billed until it is aborted.
+type IncompleteUpload struct {
+	Key       string
+	UploadID  string
+	Initiated time.Time
+	Size      int64 // 已上传分片的字节数
+}
+
+// UploadAborter is implemented by object storages keeping the parts of
+// interrupted multipart uploads
+type UploadAborter interface {
+	ListIncompleteUploads() ([]IncompleteUpload, error)
+	AbortUpload(upload IncompleteUpload) error
+}
@@ -0,0 +1,105 @@
+package object
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"terrasync/log"
+)
+
+// NetworkConfig selects the local address and IP family used for outgoing connections,
+// so migration traffic can be pinned to a dedicated replication network
+type NetworkConfig struct {
+	BindAddress   string `mapstructure:"bind_address"`   // 本地源IP地址
+	BindInterface string `mapstructure:"bind_interface"` // 本地网卡名称，使用其第一个匹配的地址
+	IPPreference  string `mapstructure:"ip_preference"`  // ipv4, ipv6 或为空(不限制)
+}
+
+// dialFunc is the signature of net.Dialer.DialContext
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// newDialContext wraps the dialer so connections use the configured source address and IP family
+func newDialContext(dialer *net.Dialer, cfg NetworkConfig) (dialFunc, error) {
+	pref := strings.ToLower(cfg.IPPreference)
+	if pref != "" && pref != "ipv4" && pref != "ipv6" {
+		return nil, fmt.Errorf("invalid ip preference %s, expect ipv4 or ipv6", cfg.IPPreference)
+	}
+
+	localIP, err := resolveLocalIP(cfg, pref)
+	if err != nil {
+		return nil, err
+	}
+	if localIP != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: localIP}
+		// A bound source address also fixes the IP family
+		if localIP.To4() != nil {
+			pref = "ipv4"
+		} else {
+			pref = "ipv6"
+		}
+		log.Infof("Binding outgoing connections to %s", localIP)
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if network == "tcp" {
+			switch pref {
+			case "ipv4":
+				network = "tcp4"
+			case "ipv6":
+				network = "tcp6"
+			}
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}, nil
+}
+
+// resolveLocalIP returns the source IP from the bind address or interface, nil if not configured
+func resolveLocalIP(cfg NetworkConfig, pref string) (net.IP, error) {
+	if cfg.BindAddress != "" {
+		ip := net.ParseIP(cfg.BindAddress)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid bind address: %s", cfg.BindAddress)
+		}
+		if !matchFamily(ip, pref) {
+			return nil, fmt.Errorf("bind address %s does not match ip preference %s", ip, pref)
+		}
+		return ip, nil
+	}
+
+	if cfg.BindInterface == "" {
+		return nil, nil
+	}
+	iface, err := net.InterfaceByName(cfg.BindInterface)
+	if err != nil {
+		return nil, fmt.Errorf("find interface %s fail: %v", cfg.BindInterface, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("get addresses of interface %s fail: %v", cfg.BindInterface, err)
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		// Link-local addresses need a zone and are useless for routed replication traffic
+		if !ok || ipNet.IP.IsLinkLocalUnicast() || !matchFamily(ipNet.IP, pref) {
+			continue
+		}
+		return ipNet.IP, nil
+	}
+	if pref != "" {
+		return nil, fmt.Errorf("no usable %s address on interface %s", pref, cfg.BindInterface)
+	}
+	return nil, fmt.Errorf("no usable address on interface %s", cfg.BindInterface)
+}
+
+// matchFamily reports whether ip belongs to the preferred IP family
+func matchFamily(ip net.IP, pref string) bool {
+	switch pref {
+	case "ipv4":
+		return ip.To4() != nil
+	case "ipv6":
+		return ip.To4() == nil
+	default:
+		return true
+	}
+}
@@ -1,38 +1,72 @@
 package object
 
 import (
+	"fmt"
 	"io"
+	"strings"
 )
 
+// NFSOptions 由 nfs://host/export?vers=4.1&sec=krb5 中的参数解码
+type NFSOptions struct {
+	Version string `uri:"vers"` // NFS协议版本，如 3, 4, 4.1
+	Sec     string `uri:"sec"`  // 安全模式: sys, krb5, krb5i, krb5p
+	Port    int    `uri:"port"`
+}
+
 type nfsStorage struct {
 	scanPath string
+	host     string
+	export   string
+	opts     NFSOptions
+	krb      *kerberosSession
 }
 
 func (s *nfsStorage) List(dir string) (<-chan FileInfo, error) {
-	return nil, nil
+	return nil, ErrNotImplemented
 }
 
 func (s *nfsStorage) Head(key string) (FileInfo, error) {
-	return nil, nil
+	return nil, ErrNotImplemented
 }
 
 func (s *nfsStorage) Get(key string) (io.ReadCloser, error) {
-	return nil, nil
+	return nil, ErrNotImplemented
 }
 
 func (s *nfsStorage) Put(key string, in io.Reader) error {
-	return nil
+	return ErrNotImplemented
 }
 
 func (s *nfsStorage) Delete(key string) error {
-	return nil
+	return ErrNotImplemented
 }
 
 func (s *nfsStorage) Close() error {
+	s.krb.Close()
 	return nil
 }
 
-// TODO:
-func createNfs(scanPath string) (Storage, error) {
-	return &nfsStorage{scanPath: scanPath}, nil
+// ReliableDirMTime is true, the server updates directory mtimes like a local filesystem
+func (s *nfsStorage) ReliableDirMTime() bool {
+	return true
+}
+
+// TODO: the backend is a stub, every operation returns ErrNotImplemented
+func createNfs(uri *URI) (Storage, error) {
+	s := &nfsStorage{scanPath: uri.Raw, host: uri.Host, export: uri.Path}
+	if err := DecodeOptions(uri.Options, &s.opts); err != nil {
+		return nil, fmt.Errorf("invalid nfs uri %s: %w", uri.Raw, err)
+	}
+
+	// NFSv4 with sec=krb5: authenticate with the profile's principal before mounting
+	profile := ProfileFor(uri.Raw)
+	if profile.Kerberos.Enabled || strings.HasPrefix(s.opts.Sec, "krb5") {
+		krb, err := newKerberosSession(profile.Kerberos, "nfs", s.host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to authenticate with kerberos for %s: %w", uri.Raw, err)
+		}
+		s.krb = krb
+	}
+
+	return s, nil
 }
@@ -0,0 +1,66 @@
+package object
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DecodeOptions decodes URI query parameters into an options struct whose fields are
+// tagged with `uri:"name"`. Supported field kinds are string, bool and integers.
+// Unknown parameters are rejected so typos in a long URI do not go unnoticed.
+func DecodeOptions(values url.Values, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("options destination must be a pointer to struct")
+	}
+	v = v.Elem()
+	t := v.Type()
+
+	fields := make(map[string]reflect.Value, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("uri"); tag != "" {
+			fields[tag] = v.Field(i)
+		}
+	}
+
+	for key, vals := range values {
+		field, ok := fields[strings.ToLower(key)]
+		if !ok {
+			return fmt.Errorf("unknown option %q, supported: %s", key, strings.Join(optionNames(fields), ", "))
+		}
+		value := vals[len(vals)-1]
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid value %q for option %s: %v", value, key, err)
+			}
+			field.SetBool(b)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid value %q for option %s: %v", value, key, err)
+			}
+			field.SetInt(n)
+		default:
+			return fmt.Errorf("unsupported type %s for option %s", field.Kind(), key)
+		}
+	}
+
+	return nil
+}
+
+func optionNames(fields map[string]reflect.Value) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,39 @@
+package object
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestURIOptions 测试存储路径中的参数解析
+func TestURIOptions(t *testing.T) {
+	uri, err := ParseURI("s3://bucket/prefix?region=us-east-1&sse=aws:kms&path_style=true")
+	assert.NoError(t, err)
+	assert.Equal(t, "/prefix", uri.Path)
+
+	var opts S3Options
+	assert.NoError(t, DecodeOptions(uri.Options, &opts))
+	assert.Equal(t, "us-east-1", opts.Region)
+	assert.Equal(t, "aws:kms", opts.SSE)
+	assert.True(t, opts.PathStyle)
+
+	uri, err = ParseURI("nfs://filer01/export?vers=4.1&sec=krb5p")
+	assert.NoError(t, err)
+	var nfsOpts NFSOptions
+	assert.NoError(t, DecodeOptions(uri.Options, &nfsOpts))
+	assert.Equal(t, "4.1", nfsOpts.Version)
+	assert.Equal(t, "krb5p", nfsOpts.Sec)
+}
+
+// TestDecodeOptionsInvalid 测试未知参数和无效值
+func TestDecodeOptionsInvalid(t *testing.T) {
+	uri, err := ParseURI("s3://bucket/prefix?regoin=us-east-1")
+	assert.NoError(t, err)
+	var opts S3Options
+	assert.Error(t, DecodeOptions(uri.Options, &opts))
+
+	uri, err = ParseURI("s3://bucket/prefix?path_style=maybe")
+	assert.NoError(t, err)
+	assert.Error(t, DecodeOptions(uri.Options, &opts))
+}
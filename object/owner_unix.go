@@ -0,0 +1,22 @@
+//go:build !windows
+
+package object
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwner returns the uid and gid of a file
+func fileOwner(info os.FileInfo) (int, int, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return -1, -1, false
+	}
+	return int(stat.Uid), int(stat.Gid), true
+}
+
+// chown changes the owner of a file without following symlinks
+func chown(name string, uid, gid int) error {
+	return os.Lchown(name, uid, gid)
+}
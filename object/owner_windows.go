@@ -0,0 +1,17 @@
+//go:build windows
+
+package object
+
+import (
+	"os"
+)
+
+// fileOwner returns the uid and gid of a file, Windows files are owned by SIDs
+func fileOwner(info os.FileInfo) (int, int, bool) {
+	return -1, -1, false
+}
+
+// chown is not supported on Windows
+func chown(name string, uid, gid int) error {
+	return nil
+}
@@ -0,0 +1,61 @@
+package object
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Profile holds per-storage settings that apply to every URI starting with Match,
+// e.g. the credentials used for a specific filer or object store endpoint
+type Profile struct {
+	Name     string         `mapstructure:"-"`
+	Match    string         `mapstructure:"match"`
+	Kerberos KerberosConfig `mapstructure:"kerberos"`
+	HTTP     HTTPConfig     `mapstructure:"http"`
+	Network  NetworkConfig  `mapstructure:"network"`
+	Auth     AuthConfig     `mapstructure:"auth"`
+}
+
+// defaultProfileName is the profile applied to every URI not matched by a more specific profile
+const defaultProfileName = "default"
+
+var (
+	profilesMu sync.RWMutex
+	profiles   []Profile
+)
+
+// SetProfiles replaces the registered storage profiles
+func SetProfiles(named map[string]Profile) {
+	list := make([]Profile, 0, len(named))
+	for name, p := range named {
+		p.Name = name
+		if p.Match == "" && name != defaultProfileName {
+			p.Match = name
+		}
+		list = append(list, p)
+	}
+	// Longest prefix first so the most specific profile wins
+	sort.Slice(list, func(i, j int) bool {
+		if len(list[i].Match) != len(list[j].Match) {
+			return len(list[i].Match) > len(list[j].Match)
+		}
+		return list[i].Name < list[j].Name
+	})
+
+	profilesMu.Lock()
+	profiles = list
+	profilesMu.Unlock()
+}
+
+// ProfileFor returns the most specific profile matching the URI, or an empty profile
+func ProfileFor(uri string) Profile {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	for _, p := range profiles {
+		if strings.HasPrefix(uri, p.Match) {
+			return p
+		}
+	}
+	return Profile{}
+}
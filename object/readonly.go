@@ -0,0 +1,161 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"terrasync/log"
+)
+
+// ErrReadOnly is returned by writes to a storage opened read-only
+var ErrReadOnly = errors.New("storage is read-only")
+
+// ReadOnly wraps a storage so that every write, delete and metadata change is
+// refused with ErrReadOnly before it reaches the backend, whatever the caller
+// does. Used for sources with --assert-readonly.
+func ReadOnly(s Storage) Storage {
+	if _, ok := s.(*readOnlyStorage); ok {
+		return s
+	}
+	return &readOnlyStorage{inner: s}
+}
+
+// IsReadOnly reports whether a storage was opened with ReadOnly
+func IsReadOnly(s Storage) bool {
+	_, ok := s.(*readOnlyStorage)
+	return ok
+}
+
+// readOnlyStorage refuses writes, read-only capabilities of the wrapped storage are forwarded
+type readOnlyStorage struct {
+	inner Storage
+}
+
+func refuse(op, key string) error {
+	log.Errorf("Refused %s of %s on read-only storage", op, key)
+	return fmt.Errorf("%s %s: %w", op, key, ErrReadOnly)
+}
+
+func (s *readOnlyStorage) List(dir string) (<-chan FileInfo, error) {
+	queue, err := s.inner.List(dir)
+	if err != nil || queue == nil {
+		return queue, err
+	}
+	out := make(chan FileInfo, listQueueLen)
+	go func() {
+		defer close(out)
+		for fileInfo := range queue {
+			out <- readOnlyFileOf(fileInfo)
+		}
+	}()
+	return out, nil
+}
+
+func (s *readOnlyStorage) Head(key string) (FileInfo, error) {
+	fileInfo, err := s.inner.Head(key)
+	if err != nil || fileInfo == nil {
+		return fileInfo, err
+	}
+	return readOnlyFileOf(fileInfo), nil
+}
+
+func (s *readOnlyStorage) Put(key string, in io.Reader) error {
+	return refuse("put", key)
+}
+
+func (s *readOnlyStorage) Delete(key string) error {
+	return refuse("delete", key)
+}
+
+func (s *readOnlyStorage) Close() error {
+	return s.inner.Close()
+}
+
+func (s *readOnlyStorage) PutEntry(key string, fileInfo FileInfo) error {
+	return refuse("put", key)
+}
+
+func (s *readOnlyStorage) SetMetadata(key string, meta Metadata) error {
+	return refuse("set metadata", key)
+}
+
+func (s *readOnlyStorage) SetTags(key string, tags map[string]string) error {
+	return refuse("set tags", key)
+}
+
+func (s *readOnlyStorage) Capacity() (Capacity, error) {
+	if provider, ok := s.inner.(CapacityProvider); ok {
+		return provider.Capacity()
+	}
+	return Capacity{}, ErrCapacityUnknown
+}
+
+func (s *readOnlyStorage) ReliableDirMTime() bool {
+	return ReliableDirMTime(s.inner)
+}
+
+func (s *readOnlyStorage) MTimeIsUploadTime() bool {
+	return !KeepsMTime(s.inner)
+}
+
+func (s *readOnlyStorage) PoolStats() PoolStats {
+	if provider, ok := s.inner.(PoolStatsProvider); ok {
+		return provider.PoolStats()
+	}
+	return PoolStats{}
+}
+
+// readOnlyFile refuses deleting a file. Owner, ACL, tags and stubs of the wrapped file
+// are forwarded, unknown values mean the same as a file without the capability.
+type readOnlyFile struct {
+	FileInfo
+}
+
+// readOnlyAttrsFile also forwards the attributes, an AttrsProvider reports known attributes
+type readOnlyAttrsFile struct {
+	readOnlyFile
+}
+
+func readOnlyFileOf(fileInfo FileInfo) FileInfo {
+	if _, ok := fileInfo.(AttrsProvider); ok {
+		return &readOnlyAttrsFile{readOnlyFile{fileInfo}}
+	}
+	return &readOnlyFile{fileInfo}
+}
+
+func (f *readOnlyFile) Delete() error {
+	return refuse("delete", f.Key())
+}
+
+func (f *readOnlyFile) Owner() (int, int, bool) {
+	if owner, ok := f.FileInfo.(OwnerProvider); ok {
+		return owner.Owner()
+	}
+	return -1, -1, false
+}
+
+func (f *readOnlyFile) ACL() (map[string][]byte, error) {
+	if provider, ok := f.FileInfo.(ACLProvider); ok {
+		return provider.ACL()
+	}
+	return nil, nil
+}
+
+func (f *readOnlyFile) Tags() map[string]string {
+	if provider, ok := f.FileInfo.(TagsProvider); ok {
+		return provider.Tags()
+	}
+	return nil
+}
+
+func (f *readOnlyFile) Stub() bool {
+	if provider, ok := f.FileInfo.(StubProvider); ok {
+		return provider.Stub()
+	}
+	return false
+}
+
+func (f *readOnlyAttrsFile) Attrs() (FileAttrs, error) {
+	return f.FileInfo.(AttrsProvider).Attrs()
+}
@@ -0,0 +1,50 @@
+package object
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"terrasync/log"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// TestReadOnlyStorage 测试只读存储拒绝所有写入，读取不受影响
+func TestReadOnlyStorage(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	inner, err := CreateStorage("mem://test-readonly")
+	assert.NoError(t, err)
+	assert.NoError(t, inner.Put("/a/b.txt", strings.NewReader("hello")))
+
+	s := ReadOnly(inner)
+	assert.True(t, IsReadOnly(s))
+	assert.False(t, IsReadOnly(inner))
+	assert.Same(t, s, ReadOnly(s))
+
+	// 读取
+	assert.Equal(t, []string{"/a"}, listKeys(t, s, "/"))
+	fi, err := s.Head("/a/b.txt")
+	assert.NoError(t, err)
+	r, err := fi.Get(0, -1)
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(r)
+	assert.Equal(t, "hello", string(data))
+	_, ok := fi.(AttrsProvider)
+	assert.True(t, ok)
+
+	// 写入
+	assert.ErrorIs(t, s.Put("/c.txt", strings.NewReader("x")), ErrReadOnly)
+	assert.ErrorIs(t, s.Delete("/a/b.txt"), ErrReadOnly)
+	assert.ErrorIs(t, fi.Delete(), ErrReadOnly)
+	assert.ErrorIs(t, s.(EntryWriter).PutEntry("/c.txt", fi), ErrReadOnly)
+	assert.ErrorIs(t, s.(MetadataSetter).SetMetadata("/a/b.txt", Metadata{}), ErrReadOnly)
+	assert.ErrorIs(t, s.(Tagger).SetTags("/a/b.txt", map[string]string{"k": "v"}), ErrReadOnly)
+
+	// 源存储没有变化
+	assert.Equal(t, []string{"/a/b.txt"}, listKeys(t, inner, "/a"))
+	fi, err = inner.Head("/c.txt")
+	assert.NoError(t, err)
+	assert.Nil(t, fi)
+}
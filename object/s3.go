@@ -1,38 +1,669 @@
 package object
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
 	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"terrasync/log"
+	"time"
 )
 
+const (
+	// s3PartSize is the size of the first parts of a multipart upload, smaller
+	// objects are uploaded with a single PutObject
+	s3PartSize = 16 << 20
+	// s3MaxPartSize caps the part size, which doubles every 1000 parts so that
+	// the 10000 parts of an upload hold objects of a few TB
+	s3MaxPartSize = 512 << 20
+	s3MaxParts    = 10000
+)
+
+// S3Options 由 s3://bucket/prefix?region=us-east-1&sse=aws:kms 中的参数解码
+type S3Options struct {
+	Region       string `uri:"region"`
+	Endpoint     string `uri:"endpoint"`
+	SSE          string `uri:"sse"` // 服务端加密: AES256 或 aws:kms
+	SSEKMSKeyID  string `uri:"sse_kms_key_id"`
+	StorageClass string `uri:"storage_class"`
+	PathStyle    bool   `uri:"path_style"` // 使用path-style寻址(多数私有对象存储需要)
+}
+
+// s3Storage talks the S3 REST API. Every request is signed with SigV4 through
+// authDo, so renewed credentials are picked up and a request rejected for
+// expired credentials is signed again and retried.
 type s3Storage struct {
-	uri string
+	uri      string // 已隐去密钥，可以写入日志
+	bucket   string
+	prefix   string // 桶内的前缀，不以/开头和结尾
+	opts     S3Options
+	endpoint *url.URL
+	client   *http.Client
+	auth     AuthProvider
+	now      func() time.Time
+}
+
+// s3Object is an object, or a common prefix listed as a directory
+type s3Object struct {
+	key     string
+	size    int64
+	mtime   time.Time
+	dir     bool
+	storage *s3Storage
 }
 
+func (o *s3Object) Key() string {
+	return o.key
+}
+
+func (o *s3Object) Size() int64 {
+	return o.size
+}
+
+func (o *s3Object) MTime() time.Time {
+	return o.mtime
+}
+
+func (o *s3Object) CTime() time.Time {
+	return o.mtime
+}
+
+func (o *s3Object) ATime() time.Time {
+	return o.mtime
+}
+
+func (o *s3Object) Perm() os.FileMode {
+	if o.dir {
+		return 0755
+	}
+	return 0644
+}
+
+func (o *s3Object) IsDir() bool {
+	return o.dir
+}
+
+func (o *s3Object) IsSymlink() bool {
+	return false
+}
+
+func (o *s3Object) IsRegular() bool {
+	return !o.dir
+}
+
+func (o *s3Object) IsSticky() bool {
+	return false
+}
+
+func (o *s3Object) Get(offset, limit int64) (io.ReadCloser, error) {
+	if o.dir {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	return o.storage.get(o.key, offset, limit)
+}
+
+func (o *s3Object) Delete() error {
+	return o.storage.Delete(o.key)
+}
+
+// s3Error is the error response of a request
+type s3Error struct {
+	Method  string
+	Key     string
+	Status  int
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e *s3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3 %s %s: %d %s", e.Method, e.Key, e.Status, http.StatusText(e.Status))
+	}
+	return fmt.Sprintf("s3 %s %s: %d %s: %s", e.Method, e.Key, e.Status, e.Code, e.Message)
+}
+
+func isS3NotFound(err error) bool {
+	e, ok := err.(*s3Error)
+	return ok && e.Status == http.StatusNotFound
+}
+
+// objectKey returns the key in the bucket of a storage key, a trailing / of
+// directory keys is kept
+func (s *s3Storage) objectKey(key string) string {
+	k := strings.TrimPrefix(path.Join(s.prefix, key), "/")
+	if k != "" && strings.HasSuffix(key, dirSuffix) {
+		k += dirSuffix
+	}
+	return k
+}
+
+// storageKey returns the storage key of a key in the bucket
+func (s *s3Storage) storageKey(objectKey string) string {
+	if s.prefix != "" {
+		objectKey = strings.TrimPrefix(objectKey, s.prefix+"/")
+	}
+	return path.Join("/", objectKey)
+}
+
+// objectURL returns the URL of an object, the bucket for an empty key
+func (s *s3Storage) objectURL(key string, query url.Values) *url.URL {
+	u := *s.endpoint
+	var escaped []string
+	if key != "" {
+		for _, segment := range strings.Split(key, "/") {
+			escaped = append(escaped, sigV4Escape(segment))
+		}
+	}
+	if s.opts.PathStyle {
+		u.Path = "/" + s.bucket
+		u.RawPath = "/" + sigV4Escape(s.bucket)
+		if key != "" {
+			u.Path += "/" + key
+			u.RawPath += "/" + strings.Join(escaped, "/")
+		}
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = "/" + key
+		u.RawPath = "/" + strings.Join(escaped, "/")
+	}
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+// do sends a signed request, a response with an error status is returned as
+// an *s3Error. The body is hashed into the signature, so a request retried
+// with renewed credentials sends it again.
+func (s *s3Storage) do(method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	payloadHash := emptyPayloadSHA256
+	if body != nil {
+		reader = bytes.NewReader(body)
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	req, err := http.NewRequestWithContext(context.Background(), method, s.objectURL(key, query).String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	region := s.opts.Region
+	resp, err := authDo(s.client, s.auth, req, func(req *http.Request, creds Credentials) {
+		signV4(req, creds, region, "s3", payloadHash, s.now())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("s3 %s %s: %w", method, key, err)
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+		e := &s3Error{Method: method, Key: key, Status: resp.StatusCode}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		_ = xml.Unmarshal(data, e)
+		return nil, e
+	}
+	return resp, nil
+}
+
+// doXML sends a request and decodes the XML response into v
+func (s *s3Storage) doXML(method, key string, query url.Values, header http.Header, body []byte, v any) error {
+	resp, err := s.do(method, key, query, header, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if v == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid response to s3 %s %s: %w", method, key, err)
+	}
+	return nil
+}
+
+// listObjectsResult is the response of ListObjectsV2
+type listObjectsResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+}
+
+// listObjects lists one page of the objects and common prefixes below prefix
+func (s *s3Storage) listObjects(prefix, token string, maxKeys int) (*listObjectsResult, error) {
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}, "delimiter": {"/"}}
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+	if maxKeys > 0 {
+		query.Set("max-keys", strconv.Itoa(maxKeys))
+	}
+	var result listObjectsResult
+	if err := s.doXML(http.MethodGet, "", query, nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// List lists the objects of dir, common prefixes are listed as directories. The
+// first page is listed before returning, a failure of a later page is logged.
 func (s *s3Storage) List(dir string) (<-chan FileInfo, error) {
-	return nil, nil
+	prefix := s.objectKey(dir)
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	page, err := s.listObjects(prefix, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	queue := make(chan FileInfo, listQueueLen)
+	go func() {
+		defer close(queue)
+		for {
+			for _, p := range page.CommonPrefixes {
+				queue <- &s3Object{key: s.storageKey(strings.TrimSuffix(p.Prefix, "/")), dir: true, storage: s}
+			}
+			for _, c := range page.Contents {
+				// 目录标记对象
+				if strings.HasSuffix(c.Key, "/") {
+					continue
+				}
+				queue <- &s3Object{key: s.storageKey(c.Key), size: c.Size, mtime: c.LastModified, storage: s}
+			}
+			if !page.IsTruncated || page.NextContinuationToken == "" {
+				return
+			}
+			if page, err = s.listObjects(prefix, page.NextContinuationToken, 0); err != nil {
+				log.Errorf("Failed to list %s: %v", s.storageKey(prefix), err)
+				return
+			}
+		}
+	}()
+	return queue, nil
 }
 
+// Head returns the object of key, a directory if only objects below key exist,
+// or nil if neither exists
 func (s *s3Storage) Head(key string) (FileInfo, error) {
-	return nil, nil
+	objectKey := strings.TrimSuffix(s.objectKey(key), "/")
+	if objectKey == s.prefix {
+		return &s3Object{key: "/", dir: true, storage: s}, nil
+	}
+	resp, err := s.do(http.MethodHead, objectKey, nil, nil, nil)
+	if err == nil {
+		resp.Body.Close()
+		o := &s3Object{key: s.storageKey(objectKey), size: resp.ContentLength, storage: s}
+		o.mtime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+		return o, nil
+	}
+	if !isS3NotFound(err) {
+		return nil, err
+	}
+	page, err := s.listObjects(objectKey+"/", "", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(page.Contents) == 0 && len(page.CommonPrefixes) == 0 {
+		return nil, nil
+	}
+	return &s3Object{key: s.storageKey(objectKey), dir: true, storage: s}, nil
 }
 
+// Get reads the whole object of key
 func (s *s3Storage) Get(key string) (io.ReadCloser, error) {
-	return nil, nil
+	return s.get(key, 0, -1)
 }
 
+// get reads limit bytes from offset of the object of key, a limit of 0 or less
+// reads to the end
+func (s *s3Storage) get(key string, offset, limit int64) (io.ReadCloser, error) {
+	header := http.Header{}
+	if limit > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+limit-1))
+	} else if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := s.do(http.MethodGet, s.objectKey(key), nil, header, nil)
+	if err != nil {
+		if e, ok := err.(*s3Error); ok && e.Status == http.StatusRequestedRangeNotSatisfiable {
+			return io.NopCloser(bytes.NewReader(nil)), nil
+		}
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// uploadHeader returns the encryption and storage class headers of new objects
+func (s *s3Storage) uploadHeader() http.Header {
+	header := http.Header{}
+	if s.opts.SSE != "" {
+		header.Set("X-Amz-Server-Side-Encryption", s.opts.SSE)
+	}
+	if s.opts.SSEKMSKeyID != "" {
+		header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", s.opts.SSEKMSKeyID)
+	}
+	if s.opts.StorageClass != "" {
+		header.Set("X-Amz-Storage-Class", s.opts.StorageClass)
+	}
+	return header
+}
+
+// readPart reads up to size bytes of in
+func readPart(in io.Reader, size int64) ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := buf.ReadFrom(io.LimitReader(in, size))
+	return buf.Bytes(), err
+}
+
+// Put uploads in as the object of key, with a multipart upload when it is
+// larger than a part. Directories are kept as empty objects whose key ends with /.
 func (s *s3Storage) Put(key string, in io.Reader) error {
+	objectKey := s.objectKey(key)
+	if objectKey == "" {
+		return nil
+	}
+	if in == nil {
+		in = bytes.NewReader(nil)
+	}
+	part, err := readPart(in, s3PartSize)
+	if err != nil {
+		return err
+	}
+	if len(part) < s3PartSize {
+		return s.doXML(http.MethodPut, objectKey, nil, s.uploadHeader(), part, nil)
+	}
+	return s.putMultipart(objectKey, part, in)
+}
+
+// completedPart is a part of a multipart upload
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// putMultipart uploads part and the rest of in with a multipart upload, which
+// is aborted on failure
+func (s *s3Storage) putMultipart(objectKey string, part []byte, in io.Reader) (err error) {
+	var created struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := s.doXML(http.MethodPost, objectKey, url.Values{"uploads": {""}}, s.uploadHeader(), nil, &created); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if aerr := s.doXML(http.MethodDelete, objectKey, url.Values{"uploadId": {created.UploadID}}, nil, nil, nil); aerr != nil {
+				log.Warnf("Failed to abort the upload of %s, cleanup removes it later: %v", objectKey, aerr)
+			}
+		}
+	}()
+
+	var parts []completedPart
+	partSize := int64(s3PartSize)
+	for len(part) > 0 {
+		number := len(parts) + 1
+		if number > s3MaxParts {
+			return fmt.Errorf("object %s exceeds %d parts", objectKey, s3MaxParts)
+		}
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {created.UploadID}}
+		resp, err := s.do(http.MethodPut, objectKey, query, nil, part)
+		if err != nil {
+			return err
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		parts = append(parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+
+		if number%1000 == 0 {
+			partSize = min(2*partSize, s3MaxPartSize)
+		}
+		if part, err = readPart(in, partSize); err != nil {
+			return err
+		}
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	// 完成请求出错时也可能返回200，错误在响应体中
+	var result struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := s.doXML(http.MethodPost, objectKey, url.Values{"uploadId": {created.UploadID}}, nil, body, &result); err != nil {
+		return err
+	}
+	if result.XMLName.Local == "Error" {
+		return &s3Error{Method: http.MethodPost, Key: objectKey, Status: http.StatusOK, Code: result.Code, Message: result.Message}
+	}
 	return nil
 }
 
+// contentMD5 returns the Content-MD5 header some requests with a body require
+func contentMD5(body []byte) http.Header {
+	sum := md5.Sum(body)
+	return http.Header{"Content-Md5": {base64.StdEncoding.EncodeToString(sum[:])}}
+}
+
+// Delete deletes the object of key and the marker of the directory key. A
+// storage key doesn't tell which of them it is, both are deleted in one
+// DeleteObjects request. Deleting a missing object succeeds.
 func (s *s3Storage) Delete(key string) error {
+	objectKey := strings.TrimSuffix(s.objectKey(key), "/")
+	if objectKey == "" {
+		return nil
+	}
+	type object struct {
+		Key string `xml:"Key"`
+	}
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"Delete"`
+		Quiet   bool     `xml:"Quiet"`
+		Objects []object `xml:"Object"`
+	}{Quiet: true, Objects: []object{{objectKey}, {objectKey + "/"}}})
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Errors []s3Error `xml:"Error"`
+	}
+	if err := s.doXML(http.MethodPost, "", url.Values{"delete": {""}}, contentMD5(body), body, &result); err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		e := result.Errors[0]
+		return &s3Error{Method: http.MethodDelete, Key: objectKey, Status: http.StatusOK, Code: e.Code, Message: e.Message}
+	}
 	return nil
 }
 
+// s3Tag is a tag of the TagSet of PutObjectTagging
+type s3Tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// SetTags replaces the object tagging (PutObjectTagging)
+func (s *s3Storage) SetTags(key string, tags map[string]string) error {
+	tagging := struct {
+		XMLName xml.Name `xml:"Tagging"`
+		Tags    []s3Tag  `xml:"TagSet>Tag"`
+	}{}
+	for k, v := range tags {
+		tagging.Tags = append(tagging.Tags, s3Tag{Key: k, Value: v})
+	}
+	sort.Slice(tagging.Tags, func(i, j int) bool { return tagging.Tags[i].Key < tagging.Tags[j].Key })
+	body, err := xml.Marshal(tagging)
+	if err != nil {
+		return err
+	}
+	return s.doXML(http.MethodPut, s.objectKey(key), url.Values{"tagging": {""}}, contentMD5(body), body, nil)
+}
+
+// ListIncompleteUploads lists the multipart uploads under the prefix (ListMultipartUploads)
+// and sums their parts (ListParts)
+func (s *s3Storage) ListIncompleteUploads() ([]IncompleteUpload, error) {
+	prefix := s.prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+	query := url.Values{"uploads": {""}, "prefix": {prefix}}
+	var uploads []IncompleteUpload
+	for {
+		var result struct {
+			IsTruncated        bool   `xml:"IsTruncated"`
+			NextKeyMarker      string `xml:"NextKeyMarker"`
+			NextUploadIDMarker string `xml:"NextUploadIdMarker"`
+			Uploads            []struct {
+				Key       string    `xml:"Key"`
+				UploadID  string    `xml:"UploadId"`
+				Initiated time.Time `xml:"Initiated"`
+			} `xml:"Upload"`
+		}
+		if err := s.doXML(http.MethodGet, "", query, nil, nil, &result); err != nil {
+			return nil, err
+		}
+		for _, u := range result.Uploads {
+			size, err := s.uploadedSize(u.Key, u.UploadID)
+			if err != nil {
+				return nil, err
+			}
+			key := s.storageKey(u.Key)
+			if strings.HasSuffix(u.Key, "/") {
+				key += dirSuffix
+			}
+			uploads = append(uploads, IncompleteUpload{Key: key, UploadID: u.UploadID, Initiated: u.Initiated, Size: size})
+		}
+		if !result.IsTruncated {
+			return uploads, nil
+		}
+		query.Set("key-marker", result.NextKeyMarker)
+		query.Set("upload-id-marker", result.NextUploadIDMarker)
+	}
+}
+
+// uploadedSize sums the parts of a multipart upload
+func (s *s3Storage) uploadedSize(objectKey, uploadID string) (int64, error) {
+	query := url.Values{"uploadId": {uploadID}}
+	var size int64
+	for {
+		var result struct {
+			IsTruncated          bool   `xml:"IsTruncated"`
+			NextPartNumberMarker string `xml:"NextPartNumberMarker"`
+			Parts                []struct {
+				Size int64 `xml:"Size"`
+			} `xml:"Part"`
+		}
+		if err := s.doXML(http.MethodGet, objectKey, query, nil, nil, &result); err != nil {
+			return 0, err
+		}
+		for _, p := range result.Parts {
+			size += p.Size
+		}
+		if !result.IsTruncated {
+			return size, nil
+		}
+		query.Set("part-number-marker", result.NextPartNumberMarker)
+	}
+}
+
+// AbortUpload deletes the parts of a multipart upload (AbortMultipartUpload),
+// an upload completed or aborted meanwhile is not an error
+func (s *s3Storage) AbortUpload(upload IncompleteUpload) error {
+	err := s.doXML(http.MethodDelete, s.objectKey(upload.Key), url.Values{"uploadId": {upload.UploadID}}, nil, nil, nil)
+	if isS3NotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// MTimeIsUploadTime is true, the LastModified of an object is the time it was uploaded
+func (s *s3Storage) MTimeIsUploadTime() bool {
+	return true
+}
+
+// PoolStats returns the HTTP connection pool statistics
+func (s *s3Storage) PoolStats() PoolStats {
+	return httpPoolStats(s.client)
+}
+
 func (s *s3Storage) Close() error {
+	stats := s.PoolStats()
+	log.Infof("HTTP pool of %s: %d requests, %d new connections, %d reused, %d open",
+		s.uri, stats.Requests, stats.NewConns, stats.ReusedConns, stats.OpenConns)
+	s.client.CloseIdleConnections()
 	return nil
 }
 
-// TODO:
-func createS3(uri string) (Storage, error) {
-	return &s3Storage{uri: uri}, nil
+// createS3 opens s3://bucket/prefix. The keys of s3://akey:skey@bucket/prefix
+// take precedence over the auth of the storage profile.
+func createS3(uri *URI) (Storage, error) {
+	var opts S3Options
+	if err := DecodeOptions(uri.Options, &opts); err != nil {
+		return nil, fmt.Errorf("invalid s3 uri %s: %w", uri.Redacted(), err)
+	}
+	if opts.SSE != "" && opts.SSE != "AES256" && opts.SSE != "aws:kms" {
+		return nil, fmt.Errorf("invalid sse %s, expect AES256 or aws:kms", opts.SSE)
+	}
+	if uri.Host == "" {
+		return nil, fmt.Errorf("invalid s3 uri %s: missing bucket", uri.Redacted())
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	rawEndpoint := valueOr(opts.Endpoint, "https://s3."+opts.Region+".amazonaws.com")
+	if !strings.Contains(rawEndpoint, "://") {
+		rawEndpoint = "https://" + rawEndpoint
+	}
+	endpoint, err := url.Parse(rawEndpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %s", opts.Endpoint)
+	}
+	endpoint.Path, endpoint.RawPath, endpoint.RawQuery = "", "", ""
+
+	profile := ProfileFor(uri.Raw)
+	client, err := newHTTPClient(profile.HTTP, profile.Network)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http client for %s: %w", uri.Redacted(), err)
+	}
+	var auth AuthProvider
+	if secret, ok := uri.User.Password(); ok {
+		auth = staticProvider{Credentials{AccessKeyID: uri.User.Username(), SecretAccessKey: secret}}
+	} else if auth, err = NewAuthProvider(profile.Auth, client); err != nil {
+		return nil, fmt.Errorf("invalid auth of %s: %w", uri.Redacted(), err)
+	}
+	return &s3Storage{
+		uri:      uri.Redacted(),
+		bucket:   uri.Host,
+		prefix:   strings.Trim(uri.Path, "/"),
+		opts:     opts,
+		endpoint: endpoint,
+		client:   client,
+		auth:     auth,
+		now:      time.Now,
+	}, nil
 }
@@ -0,0 +1,560 @@
+package object
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"terrasync/log"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// fakeS3 模拟path-style寻址的S3桶，检查请求的签名凭据和负载哈希
+type fakeS3 struct {
+	mu       sync.Mutex
+	bucket   string
+	keyID    string // 只接受该AccessKeyID签名的请求
+	expired  string // 以该AccessKeyID签名的请求返回ExpiredToken
+	pageSize int
+	objects  map[string][]byte
+	modified time.Time
+	uploads  map[string]*fakeUpload
+	tags     map[string]map[string]string
+	requests []string
+}
+
+func newFakeS3(bucket, keyID string) *fakeS3 {
+	return &fakeS3{bucket: bucket, keyID: keyID, pageSize: 1000, objects: map[string][]byte{},
+		modified: time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC), uploads: map[string]*fakeUpload{}, tags: map[string]map[string]string{}}
+}
+
+// fakeUpload 是未完成的分片上传
+type fakeUpload struct {
+	key       string
+	initiated time.Time
+	parts     map[int][]byte
+}
+
+func (f *fakeS3) fail(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	f.requests = append(f.requests, r.Method+" "+r.URL.RequestURI())
+
+	if f.expired != "" && strings.Contains(r.Header.Get("Authorization"), "Credential="+f.expired+"/") {
+		f.fail(w, http.StatusBadRequest, "ExpiredToken")
+		return
+	}
+	if !strings.Contains(r.Header.Get("Authorization"), "Credential="+f.keyID+"/") {
+		f.fail(w, http.StatusForbidden, "InvalidAccessKeyId")
+		return
+	}
+	sum := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		f.fail(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch")
+		return
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		f.fail(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	query := r.URL.Query()
+	switch {
+	case key == "" && query.Get("list-type") == "2":
+		f.list(w, query)
+	case key == "" && r.Method == http.MethodGet && query.Has("uploads"):
+		f.listUploads(w, query)
+	case key == "" && r.Method == http.MethodPost && query.Has("delete"):
+		var request struct {
+			Objects []struct {
+				Key string `xml:"Key"`
+			} `xml:"Object"`
+		}
+		if err := xml.Unmarshal(body, &request); err != nil {
+			f.fail(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		_, _ = io.WriteString(w, "<DeleteResult>")
+		for _, o := range request.Objects {
+			if strings.HasPrefix(o.Key, "locked/") {
+				fmt.Fprintf(w, "<Error><Key>%s</Key><Code>AccessDenied</Code><Message>Access Denied</Message></Error>", o.Key)
+				continue
+			}
+			delete(f.objects, o.Key)
+		}
+		_, _ = io.WriteString(w, "</DeleteResult>")
+	case r.Method == http.MethodGet && query.Has("uploadId"):
+		upload, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		// 每页1个分片
+		marker, _ := strconv.Atoi(query.Get("part-number-marker"))
+		_, _ = io.WriteString(w, "<ListPartsResult>")
+		if part, ok := upload.parts[marker+1]; ok {
+			fmt.Fprintf(w, "<Part><PartNumber>%d</PartNumber><Size>%d</Size></Part>", marker+1, len(part))
+			if _, more := upload.parts[marker+2]; more {
+				fmt.Fprintf(w, "<IsTruncated>true</IsTruncated><NextPartNumberMarker>%d</NextPartNumberMarker>", marker+1)
+			}
+		}
+		_, _ = io.WriteString(w, "</ListPartsResult>")
+	case r.Method == http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Last-Modified", f.modified.Format(http.TimeFormat))
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if rng := r.Header.Get("Range"); rng != "" {
+			var start, end int
+			if n, _ := fmt.Sscanf(rng, "bytes=%d-%d", &start, &end); n < 2 {
+				end = len(data) - 1
+			}
+			if start >= len(data) {
+				f.fail(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+				return
+			}
+			data = data[start:min(end+1, len(data))]
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		_, _ = w.Write(data)
+	case r.Method == http.MethodPut && query.Has("tagging"):
+		if _, ok := f.objects[key]; !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		sum := md5.Sum(body)
+		if r.Header.Get("Content-Md5") != base64.StdEncoding.EncodeToString(sum[:]) {
+			f.fail(w, http.StatusBadRequest, "InvalidDigest")
+			return
+		}
+		var tagging struct {
+			Tags []s3Tag `xml:"TagSet>Tag"`
+		}
+		if err := xml.Unmarshal(body, &tagging); err != nil {
+			f.fail(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		f.tags[key] = map[string]string{}
+		for _, tag := range tagging.Tags {
+			f.tags[key][tag.Key] = tag.Value
+		}
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		id := fmt.Sprintf("upload-%d", len(f.uploads)+1)
+		f.uploads[id] = &fakeUpload{key: key, initiated: f.modified, parts: map[int][]byte{}}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", key, id)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		upload, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		upload.parts[number] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, number))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		upload, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		var complete struct {
+			Parts []completedPart `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &complete); err != nil {
+			f.fail(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		var data []byte
+		for _, p := range complete.Parts {
+			if p.ETag != fmt.Sprintf(`"etag-%d"`, p.PartNumber) {
+				f.fail(w, http.StatusBadRequest, "InvalidPart")
+				return
+			}
+			data = append(data, upload.parts[p.PartNumber]...)
+		}
+		f.objects[key] = data
+		delete(f.uploads, query.Get("uploadId"))
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><Key>%s</Key></CompleteMultipartUploadResult>", key)
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		if _, ok := f.uploads[query.Get("uploadId")]; !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.fail(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// list answers ListObjectsV2 with pageSize keys per page
+func (f *fakeS3) list(w http.ResponseWriter, query map[string][]string) {
+	get := func(name string) string {
+		if values := query[name]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	prefix, delimiter := get("prefix"), get("delimiter")
+	pageSize := f.pageSize
+	if n, err := strconv.Atoi(get("max-keys")); err == nil {
+		pageSize = n
+	}
+	var entries []string
+	seen := map[string]bool{}
+	for key := range f.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		entry := key
+		if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+			entry = key[:len(prefix)+i+1]
+		}
+		if !seen[entry] {
+			seen[entry] = true
+			entries = append(entries, entry)
+		}
+	}
+	sort.Strings(entries)
+	start, _ := strconv.Atoi(get("continuation-token"))
+	end := min(start+pageSize, len(entries))
+
+	var out bytes.Buffer
+	out.WriteString("<ListBucketResult>")
+	if end < len(entries) {
+		fmt.Fprintf(&out, "<IsTruncated>true</IsTruncated><NextContinuationToken>%d</NextContinuationToken>", end)
+	}
+	for _, entry := range entries[start:end] {
+		if strings.HasSuffix(entry, delimiter) && !strings.HasSuffix(prefix, entry) {
+			fmt.Fprintf(&out, "<CommonPrefixes><Prefix>%s</Prefix></CommonPrefixes>", entry)
+			continue
+		}
+		fmt.Fprintf(&out, "<Contents><Key>%s</Key><Size>%d</Size><LastModified>%s</LastModified></Contents>",
+			entry, len(f.objects[entry]), f.modified.Format(time.RFC3339))
+	}
+	out.WriteString("</ListBucketResult>")
+	_, _ = out.WriteTo(w)
+}
+
+// listUploads answers ListMultipartUploads with pageSize uploads per page
+func (f *fakeS3) listUploads(w http.ResponseWriter, query url.Values) {
+	var ids []string
+	for id, upload := range f.uploads {
+		if strings.HasPrefix(upload.key, query.Get("prefix")) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := f.uploads[ids[i]], f.uploads[ids[j]]
+		return a.key < b.key || a.key == b.key && ids[i] < ids[j]
+	})
+	start := 0
+	for i, id := range ids {
+		if marker := query.Get("key-marker"); marker != "" && (f.uploads[id].key < marker || f.uploads[id].key == marker && id <= query.Get("upload-id-marker")) {
+			start = i + 1
+		}
+	}
+	end := min(start+f.pageSize, len(ids))
+	_, _ = io.WriteString(w, "<ListMultipartUploadsResult>")
+	if end < len(ids) {
+		last := ids[end-1]
+		fmt.Fprintf(w, "<IsTruncated>true</IsTruncated><NextKeyMarker>%s</NextKeyMarker><NextUploadIdMarker>%s</NextUploadIdMarker>", f.uploads[last].key, last)
+	}
+	for _, id := range ids[start:end] {
+		fmt.Fprintf(w, "<Upload><Key>%s</Key><UploadId>%s</UploadId><Initiated>%s</Initiated></Upload>",
+			f.uploads[id].key, id, f.uploads[id].initiated.Format(time.RFC3339))
+	}
+	_, _ = io.WriteString(w, "</ListMultipartUploadsResult>")
+}
+
+// openFakeS3 opens the prefix of the bucket of server with the keys in the URI
+func openFakeS3(t *testing.T, server *httptest.Server, bucket, prefix string) *s3Storage {
+	storage, err := CreateStorage(fmt.Sprintf("s3://AKIATEST:secret@%s/%s?endpoint=%s&path_style=true", bucket, prefix, server.URL))
+	assert.NoError(t, err)
+	return storage.(*s3Storage)
+}
+
+// TestS3Storage 测试S3存储的列举、读取、写入和删除
+func TestS3Storage(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	fake := newFakeS3("bucket", "AKIATEST")
+	fake.pageSize = 2
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	storage := openFakeS3(t, server, "bucket", "share")
+	defer storage.Close()
+
+	assert.NoError(t, storage.Put("/a.txt", strings.NewReader("hello world")))
+	assert.NoError(t, storage.Put("/docs/", nil))
+	assert.NoError(t, storage.Put("/docs/b 1+2.txt", strings.NewReader("b")))
+	assert.NoError(t, storage.Put("/docs/sub/c.txt", strings.NewReader("c")))
+	assert.NoError(t, storage.Put("/empty.txt", nil))
+	assert.Contains(t, fake.objects, "share/docs/b 1+2.txt", "key按SigV4编码")
+	assert.Contains(t, fake.objects, "share/docs/", "目录保存为以/结尾的空对象")
+
+	// 每页2个条目，分页列举
+	assert.Equal(t, []string{"/a.txt", "/docs", "/empty.txt"}, listKeys(t, storage, "/"))
+	assert.Equal(t, []string{"/docs/b 1+2.txt", "/docs/sub"}, listKeys(t, storage, "/docs"))
+
+	fileInfo, err := storage.Head("/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), fileInfo.Size())
+	assert.True(t, fileInfo.IsRegular())
+	assert.True(t, fake.modified.Equal(fileInfo.MTime()))
+	reader, err := fileInfo.Get(6, 3)
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, "wor", string(data))
+	reader, err = fileInfo.Get(6, 0)
+	assert.NoError(t, err)
+	data, _ = io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, "world", string(data))
+
+	fileInfo, err = storage.Head("/docs/sub")
+	assert.NoError(t, err)
+	assert.True(t, fileInfo.IsDir(), "只有下层对象的前缀作为目录")
+	fileInfo, err = storage.Head("/missing")
+	assert.NoError(t, err)
+	assert.Nil(t, fileInfo)
+	fileInfo, err = storage.Head("/empty.txt")
+	assert.NoError(t, err)
+	reader, err = fileInfo.Get(0, 0)
+	assert.NoError(t, err)
+	data, _ = io.ReadAll(reader)
+	reader.Close()
+	assert.Empty(t, data)
+
+	assert.NoError(t, storage.Delete("/a.txt"))
+	assert.NotContains(t, fake.objects, "share/a.txt")
+	_, err = storage.Get("/a.txt")
+	assert.ErrorContains(t, err, "NoSuchKey")
+
+	// 其他凭据签名的请求被拒绝
+	denied, err := CreateStorage(fmt.Sprintf("s3://AKIAOTHER:secret@bucket/share?endpoint=%s&path_style=true", server.URL))
+	assert.NoError(t, err)
+	_, err = denied.List("/")
+	assert.ErrorContains(t, err, "403 InvalidAccessKeyId")
+}
+
+// TestS3SetTags 测试给已有对象打标签，覆盖原有的标签
+func TestS3SetTags(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	fake := newFakeS3("bucket", "AKIATEST")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	storage := openFakeS3(t, server, "bucket", "archive")
+	defer storage.Close()
+
+	var tagger Tagger = storage
+	assert.NoError(t, storage.Put("/report.pdf", strings.NewReader("pdf")))
+	assert.NoError(t, tagger.SetTags("/report.pdf", map[string]string{"temperature": "warm", "last-access": "2026-01-02"}))
+	assert.NoError(t, tagger.SetTags("/report.pdf", map[string]string{"temperature": "cold"}))
+	assert.Equal(t, map[string]string{"temperature": "cold"}, fake.tags["archive/report.pdf"])
+	assert.ErrorContains(t, tagger.SetTags("/missing.pdf", map[string]string{"temperature": "cold"}), "NoSuchKey")
+}
+
+// TestS3IncompleteUploads 测试列举前缀下未完成的分片上传及其已上传的字节数，并中止上传
+func TestS3IncompleteUploads(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	fake := newFakeS3("bucket", "AKIATEST")
+	fake.pageSize = 1
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	storage := openFakeS3(t, server, "bucket", "share")
+	defer storage.Close()
+
+	initiated := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	fake.uploads["u1"] = &fakeUpload{key: "share/a.bin", initiated: initiated, parts: map[int][]byte{1: make([]byte, 10), 2: make([]byte, 5)}}
+	fake.uploads["u2"] = &fakeUpload{key: "share/a.bin", initiated: initiated, parts: map[int][]byte{}}
+	fake.uploads["u3"] = &fakeUpload{key: "share/dir/b.bin", initiated: initiated, parts: map[int][]byte{1: make([]byte, 7)}}
+	fake.uploads["u4"] = &fakeUpload{key: "other/c.bin", initiated: initiated, parts: map[int][]byte{}}
+
+	var aborter UploadAborter = storage
+	uploads, err := aborter.ListIncompleteUploads()
+	assert.NoError(t, err)
+	assert.Equal(t, []IncompleteUpload{
+		{Key: "/a.bin", UploadID: "u1", Initiated: initiated, Size: 15},
+		{Key: "/a.bin", UploadID: "u2", Initiated: initiated},
+		{Key: "/dir/b.bin", UploadID: "u3", Initiated: initiated, Size: 7},
+	}, uploads)
+
+	assert.NoError(t, aborter.AbortUpload(uploads[0]))
+	assert.NotContains(t, fake.uploads, "u1")
+	assert.NoError(t, aborter.AbortUpload(uploads[0]), "已中止的上传")
+	uploads, err = aborter.ListIncompleteUploads()
+	assert.NoError(t, err)
+	assert.Len(t, uploads, 2)
+}
+
+// TestS3DeleteDir 测试删除目录时删除其标记对象，删除失败的对象返回错误
+func TestS3DeleteDir(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	fake := newFakeS3("bucket", "AKIATEST")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	storage := openFakeS3(t, server, "bucket", "")
+	defer storage.Close()
+
+	assert.NoError(t, storage.Put("/empty/", nil))
+	assert.NoError(t, storage.Put("/locked/a.txt", strings.NewReader("a")))
+	fileInfo, err := storage.Head("/empty")
+	assert.NoError(t, err)
+	assert.True(t, fileInfo.IsDir())
+	assert.NoError(t, storage.Delete("/empty"))
+	assert.NotContains(t, fake.objects, "empty/")
+	assert.ErrorContains(t, storage.Delete("/locked/a.txt"), "AccessDenied")
+	assert.Contains(t, fake.objects, "locked/a.txt")
+}
+
+// TestS3MultipartPut 测试超过分片大小的对象分片上传，失败时中止上传
+func TestS3MultipartPut(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	fake := newFakeS3("bucket", "AKIATEST")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	storage := openFakeS3(t, server, "bucket", "")
+	defer storage.Close()
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), s3PartSize/16+1)
+	assert.NoError(t, storage.Put("/big.bin", bytes.NewReader(data)))
+	assert.Equal(t, data, fake.objects["big.bin"])
+	assert.Empty(t, fake.uploads)
+
+	fake.requests = nil
+	assert.Error(t, storage.Put("/broken.bin", io.MultiReader(bytes.NewReader(data[:s3PartSize]), &failingReader{})))
+	assert.Empty(t, fake.uploads, "失败的上传被中止")
+	assert.NotContains(t, fake.objects, "broken.bin")
+	assert.Equal(t, "DELETE /bucket/broken.bin?uploadId=upload-1", fake.requests[len(fake.requests)-1])
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, fmt.Errorf("source read failed")
+}
+
+// TestS3ExpiredCredentials 测试S3请求因凭据过期被拒绝时，从实例元数据服务更新凭据后重新签名并重试
+func TestS3ExpiredCredentials(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	fake := newFakeS3("bucket", "ASIANEW")
+	fake.expired = "ASIAOLD"
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	// 会话在过期前被撤销，元数据服务之后返回新的会话
+	keys := []string{"ASIAOLD", "ASIANEW"}
+	fetches := 0
+	imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest/api/token":
+			_, _ = io.WriteString(w, "token")
+		case "/latest/meta-data/iam/security-credentials/":
+			_, _ = io.WriteString(w, "migration-role")
+		default:
+			fmt.Fprintf(w, `{"AccessKeyId":"%s","SecretAccessKey":"secret","Token":"session","Expiration":"%s"}`,
+				keys[min(fetches, len(keys)-1)], time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+			fetches++
+		}
+	}))
+	defer imds.Close()
+	SetProfiles(map[string]Profile{"s3://bucket": {Auth: AuthConfig{Type: "imds", Endpoint: imds.URL}}})
+	defer SetProfiles(nil)
+
+	storage, err := CreateStorage(fmt.Sprintf("s3://bucket/share?endpoint=%s&path_style=true", server.URL))
+	assert.NoError(t, err)
+	defer storage.Close()
+	assert.NoError(t, storage.Put("/a.txt", strings.NewReader("payload")))
+	assert.Equal(t, []byte("payload"), fake.objects["share/a.txt"], "重试时重新发送请求体")
+	assert.Equal(t, 2, fetches)
+	assert.Equal(t, []string{"PUT /bucket/share/a.txt", "PUT /bucket/share/a.txt"}, fake.requests)
+
+	// 之后的请求直接使用更新后的凭据
+	assert.Equal(t, []string{"/a.txt"}, listKeys(t, storage, "/"))
+	assert.Equal(t, 2, fetches)
+	assert.Len(t, fake.requests, 3)
+}
+
+// TestS3PoolStats 测试S3存储的请求复用连接池的连接，关闭时释放空闲连接
+func TestS3PoolStats(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	server := httptest.NewServer(newFakeS3("bucket", "AKIATEST"))
+	defer server.Close()
+	var storage Storage = openFakeS3(t, server, "bucket", "share")
+
+	assert.NoError(t, storage.Put("/a.txt", strings.NewReader("hello")))
+	fileInfo, err := storage.Head("/a.txt")
+	assert.NoError(t, err)
+	reader, err := fileInfo.Get(0, 0)
+	assert.NoError(t, err)
+	_, _ = io.Copy(io.Discard, reader)
+	reader.Close()
+	assert.NoError(t, storage.Delete("/a.txt"))
+
+	// 只读包装转发连接池指标，扫描的心跳记录它们
+	provider, ok := ReadOnly(storage).(PoolStatsProvider)
+	assert.True(t, ok)
+	stats := provider.PoolStats()
+	assert.Equal(t, PoolStats{Requests: 4, NewConns: 1, ReusedConns: 3, OpenConns: 1}, stats)
+
+	assert.NoError(t, storage.Close())
+	assert.Equal(t, int64(0), storage.(PoolStatsProvider).PoolStats().OpenConns)
+}
+
+// TestCreateS3 测试S3路径的解析和校验
+func TestCreateS3(t *testing.T) {
+	storage, err := CreateStorage("s3://bucket/a/b/?region=eu-west-1&storage_class=STANDARD_IA")
+	assert.NoError(t, err)
+	s := storage.(*s3Storage)
+	assert.Equal(t, "a/b", s.prefix)
+	assert.Equal(t, "https://bucket.s3.eu-west-1.amazonaws.com/a/b/c%20d.txt", s.objectURL(s.objectKey("/c d.txt"), nil).String())
+	assert.Equal(t, "STANDARD_IA", s.uploadHeader().Get("X-Amz-Storage-Class"))
+
+	storage, err = CreateStorage("s3://bucket?endpoint=minio.local:9000&path_style=true")
+	assert.NoError(t, err)
+	s = storage.(*s3Storage)
+	assert.Equal(t, "https://minio.local:9000/bucket?list-type=2", s.objectURL("", map[string][]string{"list-type": {"2"}}).String())
+	assert.Equal(t, "/x", s.storageKey("x"))
+
+	_, err = CreateStorage("s3:///prefix")
+	assert.ErrorContains(t, err, "missing bucket")
+	_, err = CreateStorage("s3://bucket?sse=des")
+	assert.Error(t, err)
+}
@@ -0,0 +1,169 @@
+package object
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// fileID identifies a file by device and inode, it stays the same across
+// renames and for every hard link of the file
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// StatCache is a read-through cache of the file metadata read by the local
+// storage, keyed by (dev, inode). Listing a directory reads the inodes of its
+// entries and only stats those not cached, so a tree walked again within the
+// process (the reconcile pass of migrate, a verify after it) skips the stat
+// calls. Entries expire after the TTL; writes through the storage refresh the
+// entries they change, changes made by others show up after the TTL. Head
+// always stats, it is used to detect changes. A nil cache caches nothing.
+type StatCache struct {
+	capacity int
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[fileID]statEntry
+
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+}
+
+type statEntry struct {
+	info    os.FileInfo
+	expires time.Time
+}
+
+// StatCacheStats are the counters of a stat cache
+type StatCacheStats struct {
+	Capacity  int   `json:"capacity"`
+	Entries   int   `json:"entries"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"` // 缓存满时淘汰的条目数
+}
+
+// NewStatCache creates a cache of up to capacity entries kept for ttl
+func NewStatCache(capacity int, ttl time.Duration) *StatCache {
+	return &StatCache{capacity: max(capacity, 1), ttl: ttl, entries: make(map[fileID]statEntry)}
+}
+
+// get returns the cached metadata of a file, with the name it is listed under
+func (c *StatCache) get(id fileID, name string) (os.FileInfo, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	entry, ok := c.entries[id]
+	if ok && c.ttl > 0 && time.Now().After(entry.expires) {
+		delete(c.entries, id)
+		ok = false
+	}
+	c.mu.Unlock()
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	// 硬链接或改名后的文件使用列举时的名称
+	if entry.info.Name() != name {
+		return renamedInfo{FileInfo: entry.info, name: name}, true
+	}
+	return entry.info, true
+}
+
+// put caches the metadata of a file, evicting an arbitrary entry when full
+func (c *StatCache) put(info os.FileInfo) {
+	id, ok := statFileID(info)
+	if c == nil || !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[id]; !exists && len(c.entries) >= c.capacity {
+		for old := range c.entries {
+			delete(c.entries, old)
+			c.evictions.Add(1)
+			break
+		}
+	}
+	c.entries[id] = statEntry{info: info, expires: time.Now().Add(c.ttl)}
+}
+
+// forget drops the cached metadata of a file, its inode may be reused
+func (c *StatCache) forget(info os.FileInfo) {
+	id, ok := statFileID(info)
+	if c == nil || !ok {
+		return
+	}
+	c.mu.Lock()
+	delete(c.entries, id)
+	c.mu.Unlock()
+}
+
+// refresh stats path again after a write and caches the result
+func (c *StatCache) refresh(path string) {
+	if c == nil {
+		return
+	}
+	if info, err := os.Lstat(path); err == nil {
+		c.put(info)
+	}
+}
+
+// Stats returns the current counters
+func (c *StatCache) Stats() StatCacheStats {
+	if c == nil {
+		return StatCacheStats{}
+	}
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+	return StatCacheStats{
+		Capacity:  c.capacity,
+		Entries:   entries,
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+	}
+}
+
+// HitRate returns the share of lookups served from the cache, in percent
+func (s StatCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) * 100 / float64(s.Hits+s.Misses)
+}
+
+func (s StatCacheStats) String() string {
+	return fmt.Sprintf("%d hits, %d misses (%.1f%% hit rate), %d of %d entries, %d evicted",
+		s.Hits, s.Misses, s.HitRate(), s.Entries, s.Capacity, s.Evictions)
+}
+
+// renamedInfo is cached metadata listed under another name
+type renamedInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (i renamedInfo) Name() string {
+	return i.name
+}
+
+var statCache atomic.Pointer[StatCache]
+
+// SetStatCache sets the stat cache used by the local storage, nil disables caching
+func SetStatCache(c *StatCache) {
+	statCache.Store(c)
+}
+
+// CurrentStatCache returns the stat cache used by the local storage, or nil
+func CurrentStatCache() *StatCache {
+	return statCache.Load()
+}
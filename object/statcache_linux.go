@@ -0,0 +1,49 @@
+//go:build linux
+
+package object
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"syscall"
+)
+
+// dirInode is a directory entry with the inode read from the directory
+type dirInode struct {
+	name string
+	ino  uint64
+}
+
+// statFileID returns the device and inode of a file
+func statFileID(info os.FileInfo) (fileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
+
+// readDirInodes reads the next entries of a directory with getdents64, which
+// returns the inode of each entry without a stat. No entries means the end.
+func readDirInodes(fp *os.File, buf []byte) ([]dirInode, error) {
+	n, err := syscall.ReadDirent(int(fp.Fd()), buf)
+	if err != nil {
+		return nil, err
+	}
+	// linux_dirent64: d_ino(8) d_off(8) d_reclen(2) d_type(1) d_name(以0结尾)
+	var entries []dirInode
+	for b := buf[:n]; len(b) >= 19; {
+		reclen := int(binary.NativeEndian.Uint16(b[16:18]))
+		if reclen < 19 || reclen > len(b) {
+			break
+		}
+		name := b[19:reclen]
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+		entries = append(entries, dirInode{name: string(name), ino: binary.NativeEndian.Uint64(b[:8])})
+		b = b[reclen:]
+	}
+	return entries, nil
+}
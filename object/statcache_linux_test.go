@@ -0,0 +1,90 @@
+//go:build linux
+
+package object
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// listSizes lists dir and returns the size of each entry by key
+func listSizes(t *testing.T, s Storage, dir string) map[string]int64 {
+	queue, err := s.List(dir)
+	assert.NoError(t, err)
+	sizes := make(map[string]int64)
+	for fi := range queue {
+		sizes[fi.Key()] = fi.Size()
+	}
+	return sizes
+}
+
+// TestStatCache 测试再次列举时使用缓存的元数据，改名按inode命中，写入后刷新
+func TestStatCache(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("x"), 0644))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	cache := NewStatCache(100, time.Minute)
+	SetStatCache(cache)
+	defer SetStatCache(nil)
+
+	s, err := CreateStorage(dir)
+	assert.NoError(t, err)
+	want := map[string]int64{"/a.txt": 5, "/b.txt": 1, "/sub": listSizes(t, s, "/")["/sub"]}
+	assert.Equal(t, StatCacheStats{Capacity: 100, Entries: 3, Misses: 3}, cache.Stats())
+	assert.Equal(t, want, listSizes(t, s, "/"))
+	assert.Equal(t, int64(3), cache.Stats().Hits)
+
+	// 改名的文件按inode命中，使用新的名称
+	assert.NoError(t, os.Rename(filepath.Join(dir, "b.txt"), filepath.Join(dir, "c.txt")))
+	sizes := listSizes(t, s, "/")
+	assert.Equal(t, int64(1), sizes["/c.txt"])
+	assert.NotContains(t, sizes, "/b.txt")
+
+	// 经存储写入和删除的文件更新缓存
+	assert.NoError(t, s.Put("/a.txt", strings.NewReader("hello world")))
+	assert.NoError(t, s.Delete("/c.txt"))
+	sizes = listSizes(t, s, "/")
+	assert.Equal(t, int64(11), sizes["/a.txt"])
+	keys := make([]string, 0, len(sizes))
+	for key := range sizes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	assert.Equal(t, []string{"/a.txt", "/sub"}, keys)
+	assert.Greater(t, cache.Stats().HitRate(), 50.0)
+}
+
+// TestStatCacheExpiry 测试过期及缓存满时淘汰的条目重新stat
+func TestStatCacheExpiry(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("x"), 0644))
+	s, err := CreateStorage(dir)
+	assert.NoError(t, err)
+
+	expiring := NewStatCache(100, time.Nanosecond)
+	SetStatCache(expiring)
+	defer SetStatCache(nil)
+	listSizes(t, s, "/")
+	time.Sleep(time.Millisecond)
+	listSizes(t, s, "/")
+	assert.Equal(t, StatCacheStats{Capacity: 100, Entries: 2, Misses: 4}, expiring.Stats())
+
+	small := NewStatCache(1, time.Minute)
+	SetStatCache(small)
+	listSizes(t, s, "/")
+	assert.Equal(t, StatCacheStats{Capacity: 1, Entries: 1, Misses: 2, Evictions: 1}, small.Stats())
+
+	// nil缓存不缓存任何条目
+	var none *StatCache
+	_, ok := none.get(fileID{}, "a.txt")
+	assert.False(t, ok)
+	assert.Equal(t, StatCacheStats{}, none.Stats())
+}
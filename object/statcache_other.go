@@ -0,0 +1,24 @@
+//go:build !linux
+
+package object
+
+import (
+	"errors"
+	"os"
+)
+
+// dirInode is a directory entry with the inode read from the directory
+type dirInode struct {
+	name string
+	ino  uint64
+}
+
+// statFileID is only supported on Linux, the stat cache is not used elsewhere
+func statFileID(info os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
+
+// readDirInodes is only supported on Linux
+func readDirInodes(fp *os.File, buf []byte) ([]dirInode, error) {
+	return nil, errors.ErrUnsupported
+}
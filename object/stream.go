@@ -0,0 +1,327 @@
+package object
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"terrasync/log"
+	"time"
+)
+
+// StreamURI is the storage path meaning stdin (as a source) or stdout (as a destination)
+const StreamURI = "-"
+
+// EntryWriter is implemented by destinations that need the metadata of a file
+// together with its content, e.g. a tar stream needs the size before the data
+type EntryWriter interface {
+	PutEntry(key string, fileInfo FileInfo) error
+}
+
+// streamStorage reads a tar stream from stdin as a source or writes one to stdout
+// as a destination. A tar stream cannot be read twice, so the source is listed in a
+// single pass from the root directory and regular files are spooled to a temporary
+// directory until the storage is closed.
+type streamStorage struct {
+	in  io.Reader
+	out io.Writer
+
+	readOnce sync.Once
+	spoolDir string
+	mu       sync.Mutex
+	entries  map[string]*tarEntry
+
+	writeMu sync.Mutex
+	tw      *tar.Writer
+}
+
+// tarEntry is a file read from the tar stream
+type tarEntry struct {
+	hdr   *tar.Header
+	key   string
+	spool string // 普通文件内容的临时文件
+}
+
+func (e *tarEntry) Key() string {
+	return e.key
+}
+
+func (e *tarEntry) Size() int64 {
+	return e.hdr.Size
+}
+
+func (e *tarEntry) MTime() time.Time {
+	return e.hdr.ModTime
+}
+
+func (e *tarEntry) CTime() time.Time {
+	if e.hdr.ChangeTime.IsZero() {
+		return e.hdr.ModTime
+	}
+	return e.hdr.ChangeTime
+}
+
+func (e *tarEntry) ATime() time.Time {
+	if e.hdr.AccessTime.IsZero() {
+		return e.hdr.ModTime
+	}
+	return e.hdr.AccessTime
+}
+
+func (e *tarEntry) Perm() os.FileMode {
+	return e.hdr.FileInfo().Mode().Perm()
+}
+
+func (e *tarEntry) IsDir() bool {
+	return e.hdr.Typeflag == tar.TypeDir
+}
+
+func (e *tarEntry) IsSymlink() bool {
+	return e.hdr.Typeflag == tar.TypeSymlink
+}
+
+func (e *tarEntry) IsRegular() bool {
+	return e.hdr.Typeflag == tar.TypeReg
+}
+
+func (e *tarEntry) IsSticky() bool {
+	return e.hdr.FileInfo().Mode()&os.ModeSticky != 0
+}
+
+func (e *tarEntry) Owner() (int, int, bool) {
+	return e.hdr.Uid, e.hdr.Gid, true
+}
+
+func (e *tarEntry) Get(offset, limit int64) (io.ReadCloser, error) {
+	if e.spool == "" || offset > e.Size() {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	f, err := os.Open(e.spool)
+	if err != nil {
+		return nil, fmt.Errorf("open %s fail: %v", e.key, err)
+	}
+	if limit <= 0 {
+		limit = e.Size() - offset
+	}
+	return &SectionReaderCloser{
+		SectionReader: io.NewSectionReader(f, offset, limit),
+		Closer:        f,
+	}, nil
+}
+
+func (e *tarEntry) Delete() error {
+	return fmt.Errorf("delete %s fail: tar stream is read-only", e.key)
+}
+
+// List reads the whole tar stream when the root directory is listed. All entries,
+// including those of subdirectories, are returned by this call, so listing any
+// other directory returns nothing.
+func (s *streamStorage) List(dir string) (<-chan FileInfo, error) {
+	queue := make(chan FileInfo, listQueueLen)
+	if cleanStreamKey(dir) != "/" {
+		close(queue)
+		return queue, nil
+	}
+
+	started := false
+	s.readOnce.Do(func() { started = true })
+	if !started {
+		close(queue)
+		return queue, nil
+	}
+
+	spoolDir, err := os.MkdirTemp("", "terrasync-stream-")
+	if err != nil {
+		return nil, fmt.Errorf("create spool directory fail: %v", err)
+	}
+	s.mu.Lock()
+	s.spoolDir = spoolDir
+	s.mu.Unlock()
+
+	go func() {
+		defer close(queue)
+		tr := tar.NewReader(s.in)
+		for i := 0; ; i++ {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				log.Errorf("read tar stream fail: %v", err)
+				return
+			}
+
+			entry := &tarEntry{hdr: hdr, key: cleanStreamKey(hdr.Name)}
+			if entry.key == "/" {
+				continue
+			}
+			if hdr.Typeflag == tar.TypeReg {
+				entry.spool = filepath.Join(spoolDir, fmt.Sprintf("%d", i))
+				if err := spoolFile(entry.spool, tr); err != nil {
+					log.Errorf("spool %s fail: %v", entry.key, err)
+					return
+				}
+			}
+
+			s.mu.Lock()
+			s.entries[entry.key] = entry
+			s.mu.Unlock()
+			queue <- entry
+		}
+	}()
+	return queue, nil
+}
+
+// spoolFile copies the current tar entry to a temporary file
+func spoolFile(name string, r io.Reader) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	buf := bufPool.Get().(*[]byte)
+	defer bufPool.Put(buf)
+	if _, err = io.CopyBuffer(f, r, *buf); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *streamStorage) Head(key string) (FileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[cleanStreamKey(key)]; ok {
+		return entry, nil
+	}
+	return nil, nil
+}
+
+// Put writes a file of unknown size to the tar stream. The content is spooled to
+// a temporary file first because the tar header needs the size, use PutEntry to
+// stream it directly.
+func (s *streamStorage) Put(key string, in io.Reader) error {
+	if strings.HasSuffix(key, dirSuffix) {
+		return s.writeEntry(&tar.Header{Typeflag: tar.TypeDir, Name: tarName(key) + dirSuffix, Mode: 0755, ModTime: time.Now()}, nil)
+	}
+
+	f, err := os.CreateTemp("", "terrasync-put-")
+	if err != nil {
+		return fmt.Errorf("create spool file fail: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	buf := bufPool.Get().(*[]byte)
+	size, err := io.CopyBuffer(f, in, *buf)
+	bufPool.Put(buf)
+	if err != nil {
+		return fmt.Errorf("spool %s fail: %v", key, err)
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	hdr := &tar.Header{Typeflag: tar.TypeReg, Name: tarName(key), Size: size, Mode: 0644, ModTime: time.Now()}
+	return s.writeEntry(hdr, f)
+}
+
+// PutEntry writes a file to the tar stream keeping its size, permissions and times
+func (s *streamStorage) PutEntry(key string, fileInfo FileInfo) error {
+	hdr := &tar.Header{
+		Name:       tarName(key),
+		Mode:       int64(fileInfo.Perm()),
+		ModTime:    fileInfo.MTime(),
+		AccessTime: fileInfo.ATime(),
+		ChangeTime: fileInfo.CTime(),
+		Format:     tar.FormatPAX,
+	}
+	if owner, ok := fileInfo.(OwnerProvider); ok {
+		if uid, gid, ok := owner.Owner(); ok {
+			hdr.Uid, hdr.Gid = uid, gid
+		}
+	}
+	switch {
+	case fileInfo.IsDir():
+		hdr.Typeflag = tar.TypeDir
+		hdr.Name += dirSuffix
+		return s.writeEntry(hdr, nil)
+	case fileInfo.IsRegular():
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = fileInfo.Size()
+	default:
+		// 符号链接等特殊文件无法从FileInfo获取目标，跳过
+		log.Warnf("skip non-regular file %s in tar stream", key)
+		return nil
+	}
+
+	in, err := fileInfo.Get(0, -1)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return s.writeEntry(hdr, in)
+}
+
+// writeEntry writes one header and its content, entries are serialized
+func (s *streamStorage) writeEntry(hdr *tar.Header, in io.Reader) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if s.tw == nil {
+		s.tw = tar.NewWriter(s.out)
+	}
+	if err := s.tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("write tar header %s fail: %v", hdr.Name, err)
+	}
+	if in == nil {
+		return nil
+	}
+	buf := bufPool.Get().(*[]byte)
+	defer bufPool.Put(buf)
+	if _, err := io.CopyBuffer(s.tw, in, *buf); err != nil {
+		return fmt.Errorf("write tar entry %s fail: %v", hdr.Name, err)
+	}
+	return nil
+}
+
+func (s *streamStorage) Delete(key string) error {
+	return fmt.Errorf("delete %s fail: not supported by tar stream", key)
+}
+
+// Close writes the tar trailer and removes the spooled source files
+func (s *streamStorage) Close() error {
+	var err error
+	s.writeMu.Lock()
+	if s.tw != nil {
+		err = s.tw.Close()
+	}
+	s.writeMu.Unlock()
+
+	s.mu.Lock()
+	if s.spoolDir != "" {
+		_ = os.RemoveAll(s.spoolDir)
+	}
+	s.mu.Unlock()
+	return err
+}
+
+// cleanStreamKey converts a tar member name to a storage key such as /dir/file
+func cleanStreamKey(name string) string {
+	return path.Clean("/" + filepath.ToSlash(name))
+}
+
+// tarName converts a storage key to a relative tar member name
+func tarName(key string) string {
+	return strings.TrimPrefix(cleanStreamKey(key), "/")
+}
+
+// createStream creates the stdin/stdout tar stream storage
+func createStream(uri *URI) (Storage, error) {
+	return &streamStorage{
+		in:      os.Stdin,
+		out:     os.Stdout,
+		entries: make(map[string]*tarEntry),
+	}, nil
+}
@@ -0,0 +1,61 @@
+package object
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStreamRoundTrip 测试写入tar流后再作为源读取
+func TestStreamRoundTrip(t *testing.T) {
+	var stream bytes.Buffer
+	dst := &streamStorage{out: &stream, entries: make(map[string]*tarEntry)}
+	assert.NoError(t, dst.Put("/docs/", nil))
+	assert.NoError(t, dst.Put("/docs/a.txt", strings.NewReader("hello")))
+	assert.NoError(t, dst.Put("/b.bin", strings.NewReader("0123456789")))
+	assert.NoError(t, dst.Close())
+
+	src := &streamStorage{in: &stream, entries: make(map[string]*tarEntry)}
+	defer src.Close()
+
+	queue, err := src.List("/")
+	assert.NoError(t, err)
+	contents := make(map[string]string)
+	var keys []string
+	for fi := range queue {
+		keys = append(keys, fi.Key())
+		if fi.IsDir() {
+			continue
+		}
+		r, err := fi.Get(0, -1)
+		assert.NoError(t, err)
+		data, _ := io.ReadAll(r)
+		r.Close()
+		contents[fi.Key()] = string(data)
+	}
+	sort.Strings(keys)
+	assert.Equal(t, []string{"/b.bin", "/docs", "/docs/a.txt"}, keys)
+	assert.Equal(t, "hello", contents["/docs/a.txt"])
+	assert.Equal(t, "0123456789", contents["/b.bin"])
+
+	// 分段读取
+	fi, err := src.Head("b.bin")
+	assert.NoError(t, err)
+	r, err := fi.Get(2, 3)
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(r)
+	r.Close()
+	assert.Equal(t, "234", string(data))
+
+	// 所有条目在根目录列举时返回，子目录和再次列举为空
+	for _, dir := range []string{"/docs", "/"} {
+		queue, err = src.List(dir)
+		assert.NoError(t, err)
+		_, ok := <-queue
+		assert.False(t, ok, dir)
+	}
+}
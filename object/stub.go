@@ -0,0 +1,27 @@
+package object
+
+// Windows attributes of files whose data is offline, also reported by CIFS mounts
+const (
+	fileAttributeOffline            = 0x00001000
+	fileAttributeRecallOnOpen       = 0x00040000
+	fileAttributeRecallOnDataAccess = 0x00400000
+
+	offlineAttributes = fileAttributeOffline | fileAttributeRecallOnOpen | fileAttributeRecallOnDataAccess
+)
+
+// stubMinSize is the size below which files without blocks are not stubs, small
+// files may be stored inline in the inode (ext4 inline_data)
+const stubMinSize = 4096
+
+// StubProvider is implemented by files that can tell whether their data is
+// offline, such as the stubs an HSM or archive tier leaves on a filer. Reading
+// the data of a stub triggers its recall from the archive.
+type StubProvider interface {
+	Stub() bool
+}
+
+// IsStub reports whether fileInfo is known to be an offline stub
+func IsStub(fileInfo FileInfo) bool {
+	provider, ok := fileInfo.(StubProvider)
+	return ok && fileInfo.IsRegular() && provider.Stub()
+}
@@ -0,0 +1,51 @@
+//go:build linux
+
+package object
+
+import (
+	"encoding/binary"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// cifsAttribXattr holds the Windows attributes of files on CIFS/SMB mounts
+	cifsAttribXattr = "system.cifs_attrib"
+	// lustreHSMXattr holds the HSM state of Lustre files (struct hsm_attrs)
+	lustreHSMXattr = "trusted.hsm"
+	// hsmReleased is the HS_RELEASED flag, the data is only in the archive
+	hsmReleased = 0x00000004
+)
+
+// isStub detects stubs of files allocating less than their size. The offline
+// attributes of CIFS mounts and the HSM state of Lustre decide when present,
+// otherwise released HSM files (DMF, GPFS) are recognized by keeping their size
+// without data blocks. Files sparse over their whole size look the same and are
+// reported as stubs too.
+func isStub(name string, info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || !info.Mode().IsRegular() || stat.Blocks*512 >= info.Size() {
+		return false
+	}
+	buf := make([]byte, 64)
+	if n, err := unix.Lgetxattr(name, cifsAttribXattr, buf); err == nil {
+		return cifsOffline(buf[:n])
+	}
+	if n, err := unix.Lgetxattr(name, lustreHSMXattr, buf); err == nil {
+		return lustreReleased(buf[:n])
+	}
+	return info.Size() >= stubMinSize && stat.Blocks == 0
+}
+
+// cifsOffline reports whether the Windows attributes of system.cifs_attrib mark the data offline
+func cifsOffline(data []byte) bool {
+	return len(data) >= 4 && binary.LittleEndian.Uint32(data)&offlineAttributes != 0
+}
+
+// lustreReleased reports whether the hsm_attrs of trusted.hsm have the released flag
+func lustreReleased(data []byte) bool {
+	// hsm_compat, hsm_flags, hsm_arch_id, hsm_arch_ver，小端存储
+	return len(data) >= 8 && binary.LittleEndian.Uint32(data[4:8])&hsmReleased != 0
+}
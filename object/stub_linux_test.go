@@ -0,0 +1,51 @@
+//go:build linux
+
+package object
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLocalStub 测试按数据块识别离线存根文件
+func TestLocalStub(t *testing.T) {
+	dir := t.TempDir()
+	// 没有数据块的文件与释放了数据的HSM存根相同
+	assert.NoError(t, os.Truncate(createFile(t, filepath.Join(dir, "stub.dat"), nil), 1<<20))
+	createFile(t, filepath.Join(dir, "online.dat"), make([]byte, 1<<20))
+	assert.NoError(t, os.Truncate(createFile(t, filepath.Join(dir, "tiny.dat"), nil), 100))
+
+	storage, err := CreateStorage(dir)
+	assert.NoError(t, err)
+	for key, want := range map[string]bool{"/stub.dat": true, "/online.dat": false, "/tiny.dat": false} {
+		fileInfo, err := storage.Head(key)
+		assert.NoError(t, err)
+		assert.Equal(t, want, IsStub(fileInfo), key)
+		assert.Equal(t, want, IsStub(readOnlyFileOf(fileInfo)), key)
+	}
+}
+
+// TestStubXattrs 测试CIFS离线属性及Lustre HSM状态的解析
+func TestStubXattrs(t *testing.T) {
+	cases := []struct {
+		name    string
+		check   func([]byte) bool
+		data    []byte
+		offline bool
+	}{
+		{name: "CIFS离线", check: cifsOffline, data: []byte{0x20, 0x10, 0, 0}, offline: true},
+		{name: "CIFS访问数据时回迁", check: cifsOffline, data: []byte{0, 0, 0x40, 0}, offline: true},
+		{name: "CIFS在线稀疏文件", check: cifsOffline, data: []byte{0x20, 0x02, 0, 0}, offline: false},
+		{name: "CIFS属性不完整", check: cifsOffline, data: []byte{0x10}, offline: false},
+		{name: "Lustre已释放", check: lustreReleased, data: []byte{0, 0, 0, 0, 0x0d, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0}, offline: true},
+		{name: "Lustre已归档未释放", check: lustreReleased, data: []byte{0, 0, 0, 0, 0x09, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0}, offline: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.offline, c.check(c.data))
+		})
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !windows
+
+package object
+
+import (
+	"os"
+	"syscall"
+)
+
+// isStub detects released HSM files by keeping their size without data blocks
+func isStub(name string, info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && info.Mode().IsRegular() && info.Size() >= stubMinSize && stat.Blocks == 0
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package object
+
+import (
+	"os"
+	"syscall"
+)
+
+// isStub detects stubs by their attributes, set by HSM filters and cloud tiering
+// (Azure File Sync, OneDrive files on demand)
+func isStub(name string, info os.FileInfo) bool {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	return ok && data.FileAttributes&offlineAttributes != 0
+}
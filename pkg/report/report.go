@@ -0,0 +1,176 @@
+// Package report prints the console reports of scan, migrate and verify: a
+// title, header rows, sections of right aligned statistics and a closing rule
+// followed by a SUMMARY line for scripts. The width of the report adapts to
+// the terminal, see SetWidth.
+//
+//	console := report.NewPrinter(os.Stdout)
+//	console.Title("Scan Statistics")
+//	console.Header("Job ID", jobID)
+//	console.Section("Scanned Count")
+//	console.Field("Files", files)
+//	console.Summary(pairs, jobErr)
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"terrasync/i18n"
+	"terrasync/log"
+)
+
+const (
+	DefaultWidth = 64 // 报告分隔线的默认宽度，也是自适应时的上限
+	MinWidth     = 40
+)
+
+// width is the width of the report separators, set by SetWidth
+var width = DefaultWidth
+
+// SetWidth sets the width of the console reports. A width of 0 adapts the
+// reports to the terminal (the COLUMNS environment variable or the size of the
+// terminal on stdout), narrowing them below the default on small terminals.
+func SetWidth(w int) error {
+	if w < 0 {
+		return fmt.Errorf("invalid report width: %d", w)
+	}
+	if w == 0 {
+		w = DefaultWidth
+		columns, err := strconv.Atoi(os.Getenv("COLUMNS"))
+		if err != nil || columns <= 0 {
+			columns = consoleWidth()
+		}
+		// 标题分隔线比width宽2列
+		if columns > 0 {
+			w = min(w, columns-2)
+		}
+	}
+	width = max(w, MinWidth)
+	return nil
+}
+
+// Width returns the width of the report separators
+func Width() int {
+	return width
+}
+
+// FieldWidth returns the width of the value column of a statistic row whose
+// label takes label columns, rows end 14 columns before the separators
+func FieldWidth(label int) int {
+	return max(width-14-label, 1)
+}
+
+// LabelWidth returns the width of the label column of a table that takes w
+// columns on a report of the default width, the label shrinks with the report
+func LabelWidth(w int) int {
+	return max(w-(DefaultWidth-width), 12)
+}
+
+// Printer prints a report to its output and the log
+type Printer struct {
+	out io.Writer
+}
+
+// NewPrinter returns a printer writing to out, stdout or stderr when stdout
+// carries data such as a tar stream
+func NewPrinter(out io.Writer) Printer {
+	return Printer{out: out}
+}
+
+// Printf 同时输出到控制台和日志
+func (p Printer) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(p.out, format, args...)
+	log.Infof(format, args...)
+}
+
+// Title prints the translated report title between two rules
+func (p Printer) Title(title string) {
+	rule := strings.Repeat("=", width+2)
+	p.Printf("%s\n%s\n%s\n\n", rule, i18n.Center(i18n.T(title), width+2, " "), rule)
+}
+
+// Section prints a translated section separator
+func (p Printer) Section(title string) {
+	p.Printf("\n%s\n\n", i18n.Center(" "+i18n.T(title)+" ", width, "-"))
+}
+
+// EndSection prints the rule after the last section of a group
+func (p Printer) EndSection() {
+	p.Printf("\n%s\n\n", strings.Repeat("-", width-3))
+}
+
+// Header prints a translated "label :    value" row of the report header
+func (p Printer) Header(label string, value interface{}) {
+	p.Printf("  %s:    %v\n", i18n.Pad(i18n.T(label), 11), value)
+}
+
+// Status prints the header row of the job status, jobErr is the error of the job
+func (p Printer) Status(jobErr error) {
+	if errors.Is(jobErr, context.Canceled) {
+		p.Header("Status", i18n.T("Interrupted (partial results)"))
+	} else if jobErr != nil {
+		p.Header("Status", i18n.Sprintf("Failed (%v)", jobErr))
+	} else {
+		p.Header("Status", i18n.T("Succeeded"))
+	}
+}
+
+// Field prints a translated statistic with its value right aligned
+func (p Printer) Field(label string, value interface{}) {
+	p.Printf("  %s%*v\n", i18n.Pad(i18n.T(label)+":", 18), FieldWidth(2+18), value)
+}
+
+// End prints the closing rule of the report
+func (p Printer) End() {
+	p.Printf("\n%s\n", strings.Repeat("=", width+1))
+}
+
+// Summary prints the closing rule and the SUMMARY line of the report
+func (p Printer) Summary(pairs []Pair, jobErr error) {
+	p.End()
+	// 供脚本解析的单行摘要，不翻译
+	p.Printf("%s\n", SummaryLine(pairs, jobErr))
+}
+
+// Pair is a key=value of the SUMMARY line
+type Pair struct {
+	Key   string
+	Value interface{}
+}
+
+// StatusOf returns the untranslated job status of the SUMMARY line
+func StatusOf(jobErr error) string {
+	if errors.Is(jobErr, context.Canceled) {
+		return "interrupted"
+	} else if jobErr != nil {
+		return "failed"
+	}
+	return "succeeded"
+}
+
+// SummaryLine returns the machine-parsable "SUMMARY key=value ..." line printed
+// after a report, values containing spaces, quotes or '=' are quoted. The error
+// of a failed job is appended as error=.
+func SummaryLine(pairs []Pair, jobErr error) string {
+	var b strings.Builder
+	b.WriteString("SUMMARY")
+	for _, pair := range pairs {
+		fmt.Fprintf(&b, " %s=%s", pair.Key, summaryValue(fmt.Sprint(pair.Value)))
+	}
+	if jobErr != nil {
+		fmt.Fprintf(&b, " error=%s", summaryValue(jobErr.Error()))
+	}
+	return b.String()
+}
+
+// summaryValue quotes v when it would not parse as a single key=value token
+func summaryValue(v string) string {
+	if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+		return strconv.Quote(v)
+	}
+	return v
+}
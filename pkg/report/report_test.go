@@ -0,0 +1,84 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"terrasync/log"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// TestSetWidth 测试报告宽度的设置及按终端宽度自适应
+func TestSetWidth(t *testing.T) {
+	defer SetWidth(DefaultWidth)
+
+	cases := []struct {
+		name    string
+		width   int
+		columns string
+		want    int
+	}{
+		{name: "指定宽度", width: 100, want: 100},
+		{name: "不小于最小宽度", width: 20, want: MinWidth},
+		{name: "窄终端", columns: "50", want: 48},
+		{name: "宽终端不超过默认宽度", columns: "200", want: DefaultWidth},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Setenv("COLUMNS", c.columns)
+			assert.NoError(t, SetWidth(c.width))
+			assert.Equal(t, c.want, Width())
+		})
+	}
+	assert.Equal(t, 30, FieldWidth(20))
+
+	assert.Error(t, SetWidth(-1))
+}
+
+// TestSummaryLine 测试摘要行的状态及值的引号
+func TestSummaryLine(t *testing.T) {
+	pairs := []Pair{{Key: "job", Value: "Job 1"}, {Key: "files", Value: 2}, {Key: "path", Value: ""}}
+	assert.Equal(t, `SUMMARY job="Job 1" files=2 path=""`, SummaryLine(pairs, nil))
+	assert.Equal(t, `SUMMARY files=2 error="a=b"`, SummaryLine(pairs[1:2], errors.New("a=b")))
+
+	assert.Equal(t, "succeeded", StatusOf(nil))
+	assert.Equal(t, "failed", StatusOf(errors.New("failed")))
+	assert.Equal(t, "interrupted", StatusOf(fmt.Errorf("interrupted: %w", context.Canceled)))
+}
+
+// TestPrinter 测试报告各部分的宽度随报告宽度变化，最后输出摘要行
+func TestPrinter(t *testing.T) {
+	log.Log = zap.NewNop().Sugar()
+	defer SetWidth(DefaultWidth)
+
+	for _, width := range []int{DefaultWidth, MinWidth} {
+		assert.NoError(t, SetWidth(width))
+		var out bytes.Buffer
+		console := NewPrinter(&out)
+		console.Title("Verify Statistics")
+		console.Status(nil)
+		console.Section("Comparison")
+		console.Field("Checked", 12)
+		console.Summary([]Pair{{Key: "checked", Value: 12}}, nil)
+
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		assert.Equal(t, strings.Repeat("=", width+2), lines[0])
+		assert.Contains(t, out.String(), "Status")
+		for _, line := range lines {
+			if strings.Contains(line, "Checked:") {
+				assert.Equal(t, width-14, len(line), line)
+			}
+			if strings.Contains(line, "Comparison") {
+				assert.Equal(t, width, len(line), line)
+			}
+		}
+		assert.Equal(t, strings.Repeat("=", width+1), lines[len(lines)-2])
+		assert.Equal(t, "SUMMARY checked=12", lines[len(lines)-1])
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package report
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// consoleWidth returns the number of columns of the terminal on stdout, 0 when
+// stdout is not a terminal
+func consoleWidth() int {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0
+	}
+	return int(ws.Col)
+}
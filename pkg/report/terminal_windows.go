@@ -0,0 +1,19 @@
+//go:build windows
+
+package report
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// consoleWidth returns the number of columns of the console window on stdout, 0
+// when stdout is not a console
+func consoleWidth() int {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(os.Stdout.Fd()), &info); err != nil {
+		return 0
+	}
+	return int(info.Window.Right-info.Window.Left) + 1
+}
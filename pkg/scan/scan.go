@@ -0,0 +1,149 @@
+// Package scan is the embeddable API of the terrasync scanner.
+//
+// It walks a storage tree (local path, NFS, SMB, S3 or any backend registered with
+// object.RegisterStorage), applies the same match/exclude expressions as the CLI and
+// hands every matching entry to a callback, without printing to the console or
+// writing a job database:
+//
+//	summary, err := scan.Run(ctx, scan.Options{
+//		Path:  "/mnt/share",
+//		Match: "type==file and size>100M",
+//		OnFile: func(fi object.FileInfo) error {
+//			fmt.Println(fi.Key(), fi.Size())
+//			return nil
+//		},
+//	})
+package scan
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appscan "terrasync/app/scan"
+	"terrasync/object"
+	"terrasync/processor"
+	"terrasync/tuner"
+)
+
+// Options configures a scan
+type Options struct {
+	// Path is the storage URI to scan
+	Path string
+	// Concurrency is the number of directories listed in parallel (default 5)
+	Concurrency int
+	// Depth limits the scan depth, 0 scans all subdirectories
+	Depth int
+	// Match and Exclude are filter expressions, e.g. `size>10M and type==file`
+	Match   string
+	Exclude string
+	// HugeDirThreshold flags directories with more entries, 0 uses the default
+	HugeDirThreshold int64
+	// AutoTune adjusts the listing concurrency from throughput and latency
+	AutoTune bool
+	// Pipeline runs user processors (skip/rename/route) on every matching entry
+	Pipeline *processor.Pipeline
+
+	// OnFile is called for every matching file or directory from a single goroutine.
+	// Returning an error stops the scan and Run returns that error.
+	OnFile func(fileInfo object.FileInfo) error
+}
+
+// Summary holds the statistics of a finished scan
+type Summary struct {
+	Files         int64
+	Dirs          int64
+	TotalSize     int64
+	Symlinks      int64
+	RegularFiles  int64
+	AvgNameLength int
+	MaxNameLength int
+	AvgDirDepth   int
+	MaxDirDepth   int
+	MaxDirEntries int64
+	HugeDirs      []string
+	Skipped       int64
+	Routes        map[string]int64
+	Duration      time.Duration
+}
+
+// Run scans opts.Path until the tree is exhausted, ctx is cancelled or OnFile fails.
+// The summary is returned even when the scan stops early.
+func Run(ctx context.Context, opts Options) (*Summary, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 5
+	}
+	startTime := time.Now()
+
+	storage, err := object.CreateStorage(opts.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage: %w", err)
+	}
+	defer storage.Close()
+
+	matchConditions, err := appscan.NewConditionFilter(appscan.ParseConditions(opts.Match))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create match conditions: %w", err)
+	}
+	excludeConditions, err := appscan.NewConditionFilter(appscan.ParseConditions(opts.Exclude))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exclude conditions: %w", err)
+	}
+
+	stats := appscan.NewStats()
+	stats.SetHugeDirThreshold(opts.HugeDirThreshold)
+
+	var controller *tuner.Controller
+	if opts.AutoTune {
+		controller = tuner.NewController(tuner.DefaultConfig(object.StorageType(opts.Path)))
+		controller.Start()
+		defer controller.Stop()
+	}
+
+	// The walk is cancelled as soon as the callback fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var callbackErr error
+	for fileInfo := range appscan.ListAll(ctx, storage, appscan.ListOptions{
+		Concurrency: opts.Concurrency,
+		Depth:       opts.Depth,
+		Match:       matchConditions,
+		Exclude:     excludeConditions,
+		Stats:       stats,
+		Controller:  controller,
+		Pipeline:    opts.Pipeline,
+	}) {
+		if callbackErr != nil {
+			continue
+		}
+		stats.Update(fileInfo)
+		if opts.OnFile != nil {
+			if callbackErr = opts.OnFile(fileInfo); callbackErr != nil {
+				cancel()
+			}
+		}
+	}
+
+	summary := &Summary{
+		Files:         stats.GetFileCount(),
+		Dirs:          stats.GetDirCount(),
+		TotalSize:     stats.GetTotalSize(),
+		Symlinks:      stats.GetTotalSymlink(),
+		RegularFiles:  stats.GetTotalRegularFile(),
+		AvgNameLength: stats.GetAvgNameLength(),
+		MaxNameLength: stats.GetMaxNameLength(),
+		AvgDirDepth:   stats.GetAvgDirDepth(),
+		MaxDirDepth:   stats.GetMaxDirDepth(),
+		MaxDirEntries: stats.GetMaxDirEntries(),
+		HugeDirs:      stats.GetHugeDirs(),
+		Skipped:       stats.GetSkippedCount(),
+		Routes:        stats.GetRoutes(),
+		Duration:      time.Since(startTime),
+	}
+
+	if callbackErr != nil {
+		return summary, callbackErr
+	}
+	return summary, ctx.Err()
+}
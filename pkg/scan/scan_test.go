@@ -0,0 +1,96 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"terrasync/log"
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func init() {
+	log.Log = zap.NewNop().Sugar()
+}
+
+// createTree 创建测试目录树: 3个文件(其中1个大于1K)和2个目录
+func createTree(t *testing.T) string {
+	root := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "a", "b"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "top.txt"), []byte("top"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "a", "mid.log"), make([]byte, 2048), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "a", "b", "deep.txt"), []byte("deep"), 0644))
+	return root
+}
+
+// TestRun 测试扫描结果和回调
+func TestRun(t *testing.T) {
+	root := createTree(t)
+
+	var keys []string
+	summary, err := Run(context.Background(), Options{
+		Path: root,
+		OnFile: func(fi object.FileInfo) error {
+			keys = append(keys, fi.Key())
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), summary.Files)
+	assert.Equal(t, int64(2), summary.Dirs)
+	assert.Equal(t, int64(3+2048+4), summary.TotalSize)
+	assert.Len(t, keys, 5)
+}
+
+// TestRunMatch 测试过滤表达式
+func TestRunMatch(t *testing.T) {
+	root := createTree(t)
+
+	summary, err := Run(context.Background(), Options{
+		Path:  root,
+		Match: "type==file and size>1K",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), summary.Files)
+	assert.Equal(t, int64(0), summary.Dirs)
+}
+
+// TestRunCallbackError 测试回调返回错误时停止扫描
+func TestRunCallbackError(t *testing.T) {
+	root := createTree(t)
+	stop := errors.New("stop")
+
+	calls := 0
+	_, err := Run(context.Background(), Options{
+		Path: root,
+		OnFile: func(fi object.FileInfo) error {
+			calls++
+			return stop
+		},
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+}
+
+// TestRunMem 使用内存存储演练过滤表达式
+func TestRunMem(t *testing.T) {
+	var files int
+	summary, err := Run(context.Background(), Options{
+		Path:  "mem://pkg-scan-test?files=200&depth=3&size=1000&seed=3",
+		Match: "type==file and size>1000",
+		OnFile: func(fi object.FileInfo) error {
+			files++
+			assert.Greater(t, fi.Size(), int64(1000))
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(files), summary.Files)
+	assert.Greater(t, files, 50)
+	assert.Less(t, files, 150)
+}
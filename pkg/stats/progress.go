@@ -0,0 +1,165 @@
+package stats
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+)
+
+// DefaultProgressInterval is the period of the progress records when none is set
+const DefaultProgressInterval = 5 * time.Second
+
+// ProgressConfig configures the machine readable progress stream of a job
+type ProgressConfig struct {
+	Writer       io.Writer     // 进度记录的输出(通常为stderr)，nil表示不输出
+	Interval     time.Duration // 输出周期，<=0使用默认值
+	Command      string        // scan或migrate
+	Job          string
+	TotalEntries int64 // 预计的文件及目录数，0表示未知
+	TotalBytes   int64 // 预计的文件字节数，0表示未知
+
+	// Print is called with every record, e.g. to print a console progress line, may be nil
+	Print func(ProgressRecord)
+}
+
+// ProgressRecord is one line of the progress stream. Percent and ETA are
+// computed from the bytes when their total is known, from the entries
+// otherwise, and are omitted without any total.
+type ProgressRecord struct {
+	Type          string    `json:"type"` // 固定为progress
+	Command       string    `json:"command"`
+	Job           string    `json:"job,omitempty"`
+	Time          time.Time `json:"time"`
+	ElapsedSec    float64   `json:"elapsed_sec"`
+	Files         int64     `json:"files"`
+	Dirs          int64     `json:"dirs"`
+	Bytes         int64     `json:"bytes"`
+	Copied        int64     `json:"copied"`
+	CopiedBytes   int64     `json:"copied_bytes"`
+	Skipped       int64     `json:"skipped"`
+	Errors        int64     `json:"errors"`
+	EntriesPerSec float64   `json:"entries_per_sec"` // 最近一个周期的速率
+	FilesPerSec   float64   `json:"files_per_sec"`
+	BytesPerSec   float64   `json:"bytes_per_sec"`
+	TotalEntries  int64     `json:"total_entries,omitempty"`
+	TotalBytes    int64     `json:"total_bytes,omitempty"`
+	Percent       *float64  `json:"percent,omitempty"`
+	ETASec        *float64  `json:"eta_sec,omitempty"` // 按开始以来的平均速率估算
+	Done          bool      `json:"done"`
+}
+
+// Progress writes a single-line JSON record of the statistics every interval,
+// so CI systems and wrappers can display progress without parsing the
+// console output, and passes it to the Print function of the configuration
+type Progress struct {
+	cfg   ProgressConfig
+	stats *Stats
+	enc   *json.Encoder
+	prev  Snapshot // 上一条记录的统计，由输出goroutine及之后的Stop访问
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// StartProgress starts the progress records of st, nil without a writer or
+// a print function
+func StartProgress(cfg ProgressConfig, st *Stats) *Progress {
+	if cfg.Writer == nil && cfg.Print == nil {
+		return nil
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultProgressInterval
+	}
+	p := &Progress{
+		cfg:   cfg,
+		stats: st,
+		prev:  Snapshot{Time: time.Now()},
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if cfg.Writer != nil {
+		p.enc = json.NewEncoder(cfg.Writer)
+	}
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.write(false)
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// Stop stops the periodic records and writes the final one with done set
+func (p *Progress) Stop() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	<-p.done
+	p.write(true)
+}
+
+// write writes the record of the current statistics
+func (p *Progress) write(done bool) {
+	snap := p.stats.Snapshot()
+	record := p.record(snap, snap.Delta(p.prev), done)
+	p.prev = snap
+	if p.enc != nil {
+		// 进度输出失败不影响任务
+		_ = p.enc.Encode(record)
+	}
+	if p.cfg.Print != nil {
+		p.cfg.Print(record)
+	}
+}
+
+// record builds the record of snap, delta is the change since the previous record
+func (p *Progress) record(snap, delta Snapshot, done bool) ProgressRecord {
+	r := ProgressRecord{
+		Type:          "progress",
+		Command:       p.cfg.Command,
+		Job:           p.cfg.Job,
+		Time:          snap.Time.UTC(),
+		ElapsedSec:    math.Round(snap.Elapsed.Seconds()*10) / 10,
+		Files:         snap.Files,
+		Dirs:          snap.Dirs,
+		Bytes:         snap.Bytes,
+		Copied:        snap.Copied,
+		CopiedBytes:   snap.CopiedBytes,
+		Skipped:       snap.Skipped,
+		Errors:        snap.Errors,
+		EntriesPerSec: math.Round(delta.EntriesPerSec()*10) / 10,
+		FilesPerSec:   math.Round(perSec(delta.Files, delta.Elapsed)*10) / 10,
+		BytesPerSec:   math.Round(perSec(delta.Bytes, delta.Elapsed)),
+		TotalEntries:  p.cfg.TotalEntries,
+		TotalBytes:    p.cfg.TotalBytes,
+		Done:          done,
+	}
+
+	current, total := snap.Files+snap.Dirs, p.cfg.TotalEntries
+	if p.cfg.TotalBytes > 0 {
+		current, total = snap.Bytes, p.cfg.TotalBytes
+	}
+	if total <= 0 {
+		return r
+	}
+	percent := math.Min(100, math.Round(float64(current)*1000/float64(total))/10)
+	r.Percent = &percent
+	if done {
+		return r
+	}
+	// 源端在统计后增加了条目时剩余量按0计算
+	if rate := perSec(current, snap.Elapsed); rate > 0 {
+		eta := math.Round(float64(max(total-current, 0)) / rate)
+		r.ETASec = &eta
+	}
+	return r
+}
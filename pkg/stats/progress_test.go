@@ -0,0 +1,99 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// syncBuffer 并发安全的输出缓冲
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// TestProgress 测试周期输出单行JSON进度记录，结束时输出done记录
+func TestProgress(t *testing.T) {
+	assert.Nil(t, StartProgress(ProgressConfig{}, New()))
+
+	var out syncBuffer
+	s := New()
+	p := StartProgress(ProgressConfig{Writer: &out, Interval: 10 * time.Millisecond, Command: "scan", Job: "j1"}, s)
+	s.AddFile(100)
+	s.AddDir()
+	time.Sleep(50 * time.Millisecond)
+	p.Stop()
+
+	lines := strings.Split(strings.TrimSpace(out.buf.String()), "\n")
+	assert.Greater(t, len(lines), 1)
+	var last ProgressRecord
+	assert.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &last))
+	assert.Equal(t, "progress", last.Type)
+	assert.Equal(t, "scan", last.Command)
+	assert.Equal(t, "j1", last.Job)
+	assert.Equal(t, int64(1), last.Files)
+	assert.Equal(t, int64(100), last.Bytes)
+	assert.True(t, last.Done)
+	assert.Nil(t, last.Percent)
+	assert.NotContains(t, lines[0], "eta_sec")
+
+	// 只有Print时不输出JSON，每条记录都交给Print
+	var mu sync.Mutex
+	var printed []ProgressRecord
+	p = StartProgress(ProgressConfig{Interval: 10 * time.Millisecond, Print: func(r ProgressRecord) {
+		mu.Lock()
+		defer mu.Unlock()
+		printed = append(printed, r)
+	}}, s)
+	time.Sleep(30 * time.Millisecond)
+	p.Stop()
+	assert.Greater(t, len(printed), 1)
+	assert.True(t, printed[len(printed)-1].Done)
+	assert.Equal(t, int64(1), printed[0].Files)
+}
+
+// TestProgressRecord 测试按字节数或条目数计算百分比及剩余时间
+func TestProgressRecord(t *testing.T) {
+	now := time.Now()
+	snap := Snapshot{Time: now, Elapsed: 10 * time.Second, Files: 40, Dirs: 10, Bytes: 250}
+	delta := Snapshot{Time: now, Elapsed: 2 * time.Second, Files: 8, Dirs: 2, Bytes: 100}
+
+	tests := []struct {
+		name        string
+		cfg         ProgressConfig
+		done        bool
+		wantPercent *float64
+		wantETA     *float64
+	}{
+		{"总量未知", ProgressConfig{}, false, nil, nil},
+		{"按条目数", ProgressConfig{TotalEntries: 200}, false, ptr(25), ptr(30)},
+		{"按字节数", ProgressConfig{TotalEntries: 200, TotalBytes: 1000}, false, ptr(25), ptr(30)},
+		{"超出预计", ProgressConfig{TotalEntries: 40}, false, ptr(100), ptr(0)},
+		{"结束", ProgressConfig{TotalBytes: 1000}, true, ptr(25), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := (&Progress{cfg: tt.cfg}).record(snap, delta, tt.done)
+			assert.Equal(t, tt.wantPercent, r.Percent)
+			assert.Equal(t, tt.wantETA, r.ETASec)
+			assert.Equal(t, 5.0, r.EntriesPerSec)
+			assert.Equal(t, 4.0, r.FilesPerSec)
+			assert.Equal(t, 50.0, r.BytesPerSec)
+		})
+	}
+}
+
+func ptr(f float64) *float64 {
+	return &f
+}
@@ -0,0 +1,180 @@
+// Package stats holds the counters shared by scan and migrate: entries processed,
+// bytes copied, entries skipped and errors.
+//
+// All methods are safe for concurrent use. A nil *Stats ignores updates, so
+// components such as the migrate watchdog can report into optional statistics.
+// Snapshot returns a consistent-enough copy for periodic progress lines and for
+// the final report:
+//
+//	prev := st.Snapshot()
+//	for range ticker.C {
+//		snap := st.Snapshot()
+//		log.Infof("Progress: %s", snap.Delta(prev))
+//		prev = snap
+//	}
+package stats
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Stats are the counters of a scan or migration job
+type Stats struct {
+	start        time.Time
+	files        atomic.Int64 // 处理的文件数
+	dirs         atomic.Int64 // 处理的目录数
+	bytes        atomic.Int64 // 处理的文件字节数
+	copied       atomic.Int64 // 已拷贝的文件数
+	copiedBytes  atomic.Int64 // 已拷贝的字节数
+	skipped      atomic.Int64 // 被跳过的条目数
+	skippedBytes atomic.Int64 // 被跳过的文件字节数
+	errors       atomic.Int64 // 失败的条目数
+}
+
+// New creates statistics starting now
+func New() *Stats {
+	return &Stats{start: time.Now()}
+}
+
+// AddFile counts a processed file of size bytes
+func (s *Stats) AddFile(size int64) {
+	if s == nil {
+		return
+	}
+	s.files.Add(1)
+	s.bytes.Add(size)
+}
+
+// AddDir counts a processed directory
+func (s *Stats) AddDir() {
+	if s == nil {
+		return
+	}
+	s.dirs.Add(1)
+}
+
+// AddCopied counts a file copied with size bytes
+func (s *Stats) AddCopied(size int64) {
+	if s == nil {
+		return
+	}
+	s.copied.Add(1)
+	s.copiedBytes.Add(size)
+}
+
+// AddSkipped counts a skipped entry, size is 0 for directories
+func (s *Stats) AddSkipped(size int64) {
+	if s == nil {
+		return
+	}
+	s.skipped.Add(1)
+	s.skippedBytes.Add(size)
+}
+
+// AddError counts a failed entry
+func (s *Stats) AddError() {
+	if s == nil {
+		return
+	}
+	s.errors.Add(1)
+}
+
+// Snapshot is a copy of the counters at one point in time
+type Snapshot struct {
+	Time         time.Time     `json:"time"`
+	Elapsed      time.Duration `json:"elapsed"` // 自开始(Delta中为上一快照)以来的时间
+	Files        int64         `json:"files"`
+	Dirs         int64         `json:"dirs"`
+	Bytes        int64         `json:"bytes"`
+	Copied       int64         `json:"copied"`
+	CopiedBytes  int64         `json:"copied_bytes"`
+	Skipped      int64         `json:"skipped"`
+	SkippedBytes int64         `json:"skipped_bytes"`
+	Errors       int64         `json:"errors"`
+}
+
+// Snapshot returns the current counters, the zero Snapshot for nil statistics
+func (s *Stats) Snapshot() Snapshot {
+	if s == nil {
+		return Snapshot{}
+	}
+	now := time.Now()
+	return Snapshot{
+		Time:         now,
+		Elapsed:      now.Sub(s.start),
+		Files:        s.files.Load(),
+		Dirs:         s.dirs.Load(),
+		Bytes:        s.bytes.Load(),
+		Copied:       s.copied.Load(),
+		CopiedBytes:  s.copiedBytes.Load(),
+		Skipped:      s.skipped.Load(),
+		SkippedBytes: s.skippedBytes.Load(),
+		Errors:       s.errors.Load(),
+	}
+}
+
+// Restore replaces the counters with those of snap, e.g. to rebuild a report
+// from a saved job summary
+func (s *Stats) Restore(snap Snapshot) {
+	s.start = snap.Time.Add(-snap.Elapsed)
+	s.files.Store(snap.Files)
+	s.dirs.Store(snap.Dirs)
+	s.bytes.Store(snap.Bytes)
+	s.copied.Store(snap.Copied)
+	s.copiedBytes.Store(snap.CopiedBytes)
+	s.skipped.Store(snap.Skipped)
+	s.skippedBytes.Store(snap.SkippedBytes)
+	s.errors.Store(snap.Errors)
+}
+
+// Delta returns the counters added since prev, Elapsed is the time between the snapshots
+func (snap Snapshot) Delta(prev Snapshot) Snapshot {
+	return Snapshot{
+		Time:         snap.Time,
+		Elapsed:      snap.Time.Sub(prev.Time),
+		Files:        snap.Files - prev.Files,
+		Dirs:         snap.Dirs - prev.Dirs,
+		Bytes:        snap.Bytes - prev.Bytes,
+		Copied:       snap.Copied - prev.Copied,
+		CopiedBytes:  snap.CopiedBytes - prev.CopiedBytes,
+		Skipped:      snap.Skipped - prev.Skipped,
+		SkippedBytes: snap.SkippedBytes - prev.SkippedBytes,
+		Errors:       snap.Errors - prev.Errors,
+	}
+}
+
+// EntriesPerSec returns the files and directories processed per second over Elapsed
+func (snap Snapshot) EntriesPerSec() float64 {
+	return perSec(snap.Files+snap.Dirs, snap.Elapsed)
+}
+
+// CopiedBytesPerSec returns the bytes copied per second over Elapsed
+func (snap Snapshot) CopiedBytesPerSec() float64 {
+	return perSec(snap.CopiedBytes, snap.Elapsed)
+}
+
+func perSec(n int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(n) / elapsed.Seconds()
+}
+
+// String returns a one-line description of the snapshot for progress logs
+func (snap Snapshot) String() string {
+	return fmt.Sprintf("%d files, %d dirs, %d bytes, %d copied (%d bytes), %d skipped, %d errors in %v (%.1f entries/s)",
+		snap.Files, snap.Dirs, snap.Bytes, snap.Copied, snap.CopiedBytes, snap.Skipped, snap.Errors,
+		snap.Elapsed.Round(time.Millisecond), snap.EntriesPerSec())
+}
+
+// Max raises v to n if n is larger, for maxima updated concurrently
+func Max(v *atomic.Int64, n int64) {
+	for {
+		cur := v.Load()
+		if n <= cur || v.CompareAndSwap(cur, n) {
+			return
+		}
+	}
+}
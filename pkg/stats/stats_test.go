@@ -0,0 +1,82 @@
+package stats
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStatsConcurrent 测试并发更新计数和最大值
+func TestStatsConcurrent(t *testing.T) {
+	s := New()
+	var max atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				s.AddFile(10)
+				s.AddCopied(10)
+				if j%10 == 0 {
+					s.AddDir()
+					s.AddSkipped(5)
+					s.AddError()
+				}
+				Max(&max, int64(worker*1000+j))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	snap := s.Snapshot()
+	assert.Equal(t, int64(8000), snap.Files)
+	assert.Equal(t, int64(80000), snap.Bytes)
+	assert.Equal(t, int64(8000), snap.Copied)
+	assert.Equal(t, int64(80000), snap.CopiedBytes)
+	assert.Equal(t, int64(800), snap.Dirs)
+	assert.Equal(t, int64(800), snap.Skipped)
+	assert.Equal(t, int64(4000), snap.SkippedBytes)
+	assert.Equal(t, int64(800), snap.Errors)
+	assert.Equal(t, int64(7999), max.Load())
+}
+
+// TestSnapshotDelta 测试周期进度的增量和速率
+func TestSnapshotDelta(t *testing.T) {
+	start := time.Now()
+	prev := Snapshot{Time: start, Files: 100, Dirs: 10, CopiedBytes: 1000}
+	snap := Snapshot{Time: start.Add(2 * time.Second), Files: 300, Dirs: 10, CopiedBytes: 5000, Errors: 1}
+
+	delta := snap.Delta(prev)
+	assert.Equal(t, 2*time.Second, delta.Elapsed)
+	assert.Equal(t, int64(200), delta.Files)
+	assert.Equal(t, int64(0), delta.Dirs)
+	assert.Equal(t, int64(1), delta.Errors)
+	assert.Equal(t, 100.0, delta.EntriesPerSec())
+	assert.Equal(t, 2000.0, delta.CopiedBytesPerSec())
+	assert.Contains(t, delta.String(), "200 files")
+
+	assert.Equal(t, 0.0, Snapshot{Files: 1}.EntriesPerSec())
+}
+
+// TestStatsRestore 测试从保存的快照还原计数，nil统计忽略更新
+func TestStatsRestore(t *testing.T) {
+	saved := Snapshot{Time: time.Now(), Elapsed: time.Minute, Files: 5, Bytes: 50, Skipped: 2}
+	s := New()
+	s.Restore(saved)
+	s.AddFile(10)
+	snap := s.Snapshot()
+	assert.Equal(t, int64(6), snap.Files)
+	assert.Equal(t, int64(60), snap.Bytes)
+	assert.Equal(t, int64(2), snap.Skipped)
+	assert.GreaterOrEqual(t, snap.Elapsed, time.Minute)
+
+	var none *Stats
+	none.AddFile(1)
+	none.AddCopied(1)
+	none.AddError()
+	assert.Equal(t, Snapshot{}, none.Snapshot())
+}
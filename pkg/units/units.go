@@ -0,0 +1,111 @@
+// Package units parses the sizes and durations accepted by terrasync flags,
+// config.yaml and filter expressions.
+//
+// Sizes are a number followed by an optional unit: K, M, G, T, P and their IEC
+// forms KiB, MiB, ... are powers of 1024, the SI forms KB, MB, ... are powers of
+// 1000. Durations extend Go durations with days and weeks:
+//
+//	units.ParseSize("1.5GiB")    // 1610612736
+//	units.ParseSize("50GB")      // 50000000000
+//	units.ParseDuration("4w")    // 672h
+//	units.ParseDuration("1d12h") // 36h
+package units
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Day  = 24 * time.Hour
+	Week = 7 * Day
+)
+
+// sizeUnits maps lower case size units to their multipliers
+var sizeUnits = map[string]float64{
+	"": 1, "b": 1,
+	"k": 1 << 10, "ki": 1 << 10, "kib": 1 << 10, "kb": 1e3,
+	"m": 1 << 20, "mi": 1 << 20, "mib": 1 << 20, "mb": 1e6,
+	"g": 1 << 30, "gi": 1 << 30, "gib": 1 << 30, "gb": 1e9,
+	"t": 1 << 40, "ti": 1 << 40, "tib": 1 << 40, "tb": 1e12,
+	"p": 1 << 50, "pi": 1 << 50, "pib": 1 << 50, "pb": 1e15,
+}
+
+var sizeRegex = regexp.MustCompile(`^([0-9]+(?:\.[0-9]*)?|\.[0-9]+)\s*([a-zA-Z]*)$`)
+
+// ParseSize parses a size such as 100, 10K, 2.5MiB or 50GB into bytes, units are
+// case insensitive
+func ParseSize(s string) (int64, error) {
+	matches := sizeRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if matches == nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	multiplier, ok := sizeUnits[strings.ToLower(matches[2])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit %q in %q", matches[2], s)
+	}
+	num, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+	size := num * multiplier
+	if size >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	return int64(size), nil
+}
+
+// durationUnits maps duration units to their length, units are case sensitive
+// like Go durations, so M is not mistaken for months
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  Day,
+	"w":  Week,
+}
+
+var durationRegex = regexp.MustCompile(`^([0-9]+(?:\.[0-9]*)?|\.[0-9]+)(ns|us|µs|ms|s|m|h|d|w)`)
+
+// ParseDuration parses a duration such as 30m, 12h, 7d, 4w or 1d12h. It accepts
+// everything time.ParseDuration does, a day is always 24 hours
+func ParseDuration(s string) (time.Duration, error) {
+	rest := strings.TrimSpace(s)
+	negative := strings.HasPrefix(rest, "-")
+	rest = strings.TrimLeft(rest, "+-")
+	if rest == "0" {
+		return 0, nil
+	}
+	if rest == "" {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	var total float64
+	for rest != "" {
+		matches := durationRegex.FindStringSubmatch(rest)
+		if matches == nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		num, err := strconv.ParseFloat(matches[1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %w", s, err)
+		}
+		total += num * float64(durationUnits[matches[2]])
+		rest = rest[len(matches[0]):]
+	}
+	if total >= math.MaxInt64 {
+		return 0, fmt.Errorf("duration %q is too large", s)
+	}
+	if negative {
+		total = -total
+	}
+	return time.Duration(total), nil
+}
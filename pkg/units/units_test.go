@@ -0,0 +1,84 @@
+package units
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseSize 测试解析大小字符串
+func TestParseSize(t *testing.T) {
+	cases := []struct {
+		name      string
+		sizeStr   string
+		expected  int64
+		expectErr bool
+	}{
+		{name: "纯数字", sizeStr: "100", expected: 100},
+		{name: "K单位", sizeStr: "10K", expected: 10 * 1024},
+		{name: "M单位", sizeStr: "2M", expected: 2 * 1024 * 1024},
+		{name: "G单位", sizeStr: "3G", expected: 3 * 1024 * 1024 * 1024},
+		{name: "带小数点", sizeStr: "1.5K", expected: 1536},
+		{name: "字节单位", sizeStr: "512B", expected: 512},
+		{name: "IEC单位", sizeStr: "2KiB", expected: 2048},
+		{name: "IEC单位小写", sizeStr: "1.5gib", expected: 3 << 29},
+		{name: "SI单位", sizeStr: "5MB", expected: 5000000},
+		{name: "数字和单位之间有空格", sizeStr: " 50 GB ", expected: 50000000000},
+		{name: "P单位", sizeStr: "1P", expected: 1 << 50},
+		{name: "无效单位", sizeStr: "10X", expectErr: true},
+		{name: "不完整的IEC单位", sizeStr: "10KiBs", expectErr: true},
+		{name: "负数", sizeStr: "-1K", expectErr: true},
+		{name: "空字符串", sizeStr: "", expectErr: true},
+		{name: "溢出", sizeStr: "10000000PB", expectErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			size, err := ParseSize(tc.sizeStr)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, size)
+		})
+	}
+}
+
+// TestParseDuration 测试解析带天、周单位的时间长度
+func TestParseDuration(t *testing.T) {
+	cases := []struct {
+		name      string
+		durStr    string
+		expected  time.Duration
+		expectErr bool
+	}{
+		{name: "分钟", durStr: "30m", expected: 30 * time.Minute},
+		{name: "小时", durStr: "12h", expected: 12 * time.Hour},
+		{name: "天", durStr: "7d", expected: 7 * 24 * time.Hour},
+		{name: "周", durStr: "4w", expected: 28 * 24 * time.Hour},
+		{name: "组合单位", durStr: "1d12h", expected: 36 * time.Hour},
+		{name: "小数", durStr: "1.5d", expected: 36 * time.Hour},
+		{name: "Go格式", durStr: "720h0m0s", expected: 720 * time.Hour},
+		{name: "毫秒", durStr: "250ms", expected: 250 * time.Millisecond},
+		{name: "零", durStr: "0", expected: 0},
+		{name: "负数", durStr: "-2h30m", expected: -150 * time.Minute},
+		{name: "缺少单位", durStr: "24", expectErr: true},
+		{name: "月份不支持", durStr: "1M", expectErr: true},
+		{name: "无效格式", durStr: "abc", expectErr: true},
+		{name: "空字符串", durStr: "", expectErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dur, err := ParseDuration(tc.durStr)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, dur)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package processor
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// PluginSymbol is the constructor a Go plugin must export:
+//
+//	func NewProcessor(args map[string]string) (processor.Processor, error)
+const PluginSymbol = "NewProcessor"
+
+// LoadPlugin opens a Go plugin (.so built with -buildmode=plugin) and creates its processor.
+// Go plugins are only supported on Linux and macOS.
+func LoadPlugin(path string, args map[string]string) (Processor, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open plugin %s fail: %w", path, err)
+	}
+	sym, err := p.Lookup(PluginSymbol)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s does not export %s: %w", path, PluginSymbol, err)
+	}
+	newProcessor, ok := sym.(func(map[string]string) (Processor, error))
+	if !ok {
+		return nil, fmt.Errorf("plugin %s: %s has type %T, expect func(map[string]string) (processor.Processor, error)", path, PluginSymbol, sym)
+	}
+	return newProcessor(args)
+}
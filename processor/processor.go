@@ -0,0 +1,156 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"terrasync/object"
+)
+
+// Action is the decision of a processor for one file
+type Action int
+
+const (
+	// Keep passes the file on unchanged (or with a transformed key)
+	Keep Action = iota
+	// Skip drops the file from the scan or migration
+	Skip
+	// Route sends the file to a specific destination
+	Route
+)
+
+// Decision is returned by a processor for each file
+type Decision struct {
+	Action      Action
+	Destination string // 路由目标，仅Route有效
+	Key         string // 变换后的key，为空表示保持不变
+}
+
+// Processor inspects a file and decides whether to keep, skip, transform or route it
+type Processor interface {
+	Name() string
+	Process(fileInfo object.FileInfo) (Decision, error)
+}
+
+// Matcher is implemented by filter expressions, e.g. scan.ConditionFilter
+type Matcher interface {
+	IsSatisfied(fileInfo object.FileInfo) bool
+}
+
+// Config describes one processor of the pipeline in config.yaml
+type Config struct {
+	Name        string            `mapstructure:"name"`
+	Type        string            `mapstructure:"type"`        // rule 或 plugin
+	Match       string            `mapstructure:"match"`       // rule: 过滤表达式
+	Action      string            `mapstructure:"action"`      // rule: skip 或 route
+	Destination string            `mapstructure:"destination"` // rule: 路由目标
+	Path        string            `mapstructure:"path"`        // plugin: Go插件路径(.so)
+	Args        map[string]string `mapstructure:"args"`        // plugin: 传给插件的参数
+}
+
+// Routed is implemented by files routed to a specific destination by the pipeline
+type Routed interface {
+	Destination() string
+}
+
+// processedFile overrides the key and destination of a file
+type processedFile struct {
+	object.FileInfo
+	key         string
+	destination string
+}
+
+func (f *processedFile) Key() string {
+	return f.key
+}
+
+func (f *processedFile) Destination() string {
+	return f.destination
+}
+
+// ruleProcessor applies a fixed action to files matching an expression
+type ruleProcessor struct {
+	name        string
+	matcher     Matcher
+	action      Action
+	destination string
+}
+
+// NewRuleProcessor creates a processor that skips or routes files satisfying matcher
+func NewRuleProcessor(name string, matcher Matcher, action string, destination string) (Processor, error) {
+	p := &ruleProcessor{name: name, matcher: matcher, destination: destination}
+	switch strings.ToLower(action) {
+	case "skip":
+		p.action = Skip
+	case "route":
+		if destination == "" {
+			return nil, fmt.Errorf("processor %s: route action requires a destination", name)
+		}
+		p.action = Route
+	default:
+		return nil, fmt.Errorf("processor %s: unsupported action %q, expect skip or route", name, action)
+	}
+	return p, nil
+}
+
+func (p *ruleProcessor) Name() string {
+	return p.name
+}
+
+func (p *ruleProcessor) Process(fileInfo object.FileInfo) (Decision, error) {
+	if !p.matcher.IsSatisfied(fileInfo) {
+		return Decision{Action: Keep}, nil
+	}
+	return Decision{Action: p.action, Destination: p.destination}, nil
+}
+
+// Pipeline runs processors in order.
+// A Skip stops the pipeline, the first Route wins, and key transformations are chained.
+type Pipeline struct {
+	processors []Processor
+}
+
+// NewPipeline creates a pipeline from processors
+func NewPipeline(processors ...Processor) *Pipeline {
+	return &Pipeline{processors: processors}
+}
+
+// Len returns the number of processors
+func (p *Pipeline) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.processors)
+}
+
+// Apply runs the pipeline and returns the (possibly rewritten) file, or false if it is skipped
+func (p *Pipeline) Apply(fileInfo object.FileInfo) (object.FileInfo, bool, error) {
+	if p.Len() == 0 {
+		return fileInfo, true, nil
+	}
+
+	current := fileInfo
+	key := fileInfo.Key()
+	destination := ""
+	for _, proc := range p.processors {
+		decision, err := proc.Process(current)
+		if err != nil {
+			return nil, false, fmt.Errorf("processor %s failed on %s: %w", proc.Name(), fileInfo.Key(), err)
+		}
+		if decision.Action == Skip {
+			return nil, false, nil
+		}
+		if decision.Action == Route && destination == "" {
+			destination = decision.Destination
+		}
+		// 后续处理器看到的是变换后的key
+		if decision.Key != "" && decision.Key != key {
+			key = decision.Key
+			current = &processedFile{FileInfo: fileInfo, key: key}
+		}
+	}
+
+	if key == fileInfo.Key() && destination == "" {
+		return fileInfo, true, nil
+	}
+	return &processedFile{FileInfo: fileInfo, key: key, destination: destination}, true, nil
+}
@@ -0,0 +1,153 @@
+package processor
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"terrasync/object"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockFileInfo 测试用文件信息
+type mockFileInfo struct {
+	key string
+}
+
+func (m *mockFileInfo) Key() string                                    { return m.key }
+func (m *mockFileInfo) Size() int64                                    { return 0 }
+func (m *mockFileInfo) MTime() time.Time                               { return time.Time{} }
+func (m *mockFileInfo) CTime() time.Time                               { return time.Time{} }
+func (m *mockFileInfo) ATime() time.Time                               { return time.Time{} }
+func (m *mockFileInfo) Perm() os.FileMode                              { return 0644 }
+func (m *mockFileInfo) IsDir() bool                                    { return false }
+func (m *mockFileInfo) IsSymlink() bool                                { return false }
+func (m *mockFileInfo) IsRegular() bool                                { return true }
+func (m *mockFileInfo) IsSticky() bool                                 { return false }
+func (m *mockFileInfo) Get(offset, limit int64) (io.ReadCloser, error) { return nil, nil }
+func (m *mockFileInfo) Delete() error                                  { return nil }
+
+// suffixMatcher 匹配指定后缀的key
+type suffixMatcher string
+
+func (s suffixMatcher) IsSatisfied(fileInfo object.FileInfo) bool {
+	return strings.HasSuffix(fileInfo.Key(), string(s))
+}
+
+// funcProcessor 使用函数实现的处理器
+type funcProcessor func(fileInfo object.FileInfo) (Decision, error)
+
+func (f funcProcessor) Name() string { return "func" }
+
+func (f funcProcessor) Process(fileInfo object.FileInfo) (Decision, error) {
+	return f(fileInfo)
+}
+
+func mustRule(t *testing.T, suffix, action, destination string) Processor {
+	p, err := NewRuleProcessor(suffix, suffixMatcher(suffix), action, destination)
+	assert.NoError(t, err)
+	return p
+}
+
+// TestPipelineApply 测试处理器流水线
+func TestPipelineApply(t *testing.T) {
+	upper := funcProcessor(func(fileInfo object.FileInfo) (Decision, error) {
+		return Decision{Key: strings.ToUpper(fileInfo.Key())}, nil
+	})
+	prefix := funcProcessor(func(fileInfo object.FileInfo) (Decision, error) {
+		return Decision{Key: "/new" + fileInfo.Key()}, nil
+	})
+
+	cases := []struct {
+		name        string
+		pipeline    *Pipeline
+		key         string
+		keep        bool
+		wantKey     string
+		destination string
+	}{{
+		name:     "空流水线保持不变",
+		pipeline: nil,
+		key:      "/a.txt",
+		keep:     true,
+		wantKey:  "/a.txt",
+	}, {
+		name:     "跳过匹配文件",
+		pipeline: NewPipeline(mustRule(t, ".tmp", "skip", "")),
+		key:      "/a.tmp",
+		keep:     false,
+	}, {
+		name:     "不匹配的文件保留",
+		pipeline: NewPipeline(mustRule(t, ".tmp", "skip", "")),
+		key:      "/a.txt",
+		keep:     true,
+		wantKey:  "/a.txt",
+	}, {
+		name:        "第一个路由生效",
+		pipeline:    NewPipeline(mustRule(t, ".log", "route", "s3://logs/"), mustRule(t, ".log", "route", "s3://other/")),
+		key:         "/a.log",
+		keep:        true,
+		wantKey:     "/a.log",
+		destination: "s3://logs/",
+	}, {
+		name:     "路由后仍可被跳过",
+		pipeline: NewPipeline(mustRule(t, ".log", "route", "s3://logs/"), mustRule(t, ".log", "skip", "")),
+		key:      "/a.log",
+		keep:     false,
+	}, {
+		name:     "key变换依次传递",
+		pipeline: NewPipeline(upper, prefix),
+		key:      "/a.txt",
+		keep:     true,
+		wantKey:  "/new/A.TXT",
+	}, {
+		name:        "变换后的key参与匹配",
+		pipeline:    NewPipeline(upper, mustRule(t, ".TXT", "route", "nfs://archive/")),
+		key:         "/a.txt",
+		keep:        true,
+		wantKey:     "/A.TXT",
+		destination: "nfs://archive/",
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fi, keep, err := c.pipeline.Apply(&mockFileInfo{key: c.key})
+			assert.NoError(t, err)
+			assert.Equal(t, c.keep, keep)
+			if !keep {
+				assert.Nil(t, fi)
+				return
+			}
+			assert.Equal(t, c.wantKey, fi.Key())
+			destination := ""
+			if routed, ok := fi.(Routed); ok {
+				destination = routed.Destination()
+			}
+			assert.Equal(t, c.destination, destination)
+		})
+	}
+}
+
+// TestPipelineError 测试处理器错误
+func TestPipelineError(t *testing.T) {
+	failing := funcProcessor(func(fileInfo object.FileInfo) (Decision, error) {
+		return Decision{}, errors.New("boom")
+	})
+	_, keep, err := NewPipeline(failing).Apply(&mockFileInfo{key: "/a"})
+	assert.Error(t, err)
+	assert.False(t, keep)
+}
+
+// TestNewRuleProcessor 测试规则处理器参数校验
+func TestNewRuleProcessor(t *testing.T) {
+	_, err := NewRuleProcessor("r", suffixMatcher(".x"), "route", "")
+	assert.Error(t, err)
+	_, err = NewRuleProcessor("r", suffixMatcher(".x"), "rename", "")
+	assert.Error(t, err)
+	_, err = NewRuleProcessor("r", suffixMatcher(".x"), "SKIP", "")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,64 @@
+package security
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTLSConfig 测试FIPS模式下只使用TLS 1.2及FIPS密码套件和曲线
+func TestTLSConfig(t *testing.T) {
+	defer SetFIPS(false)
+
+	SetFIPS(false)
+	cfg := TLSConfig()
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+	assert.Zero(t, cfg.MaxVersion)
+	assert.Nil(t, cfg.CipherSuites)
+	assert.Nil(t, cfg.CurvePreferences)
+
+	SetFIPS(true)
+	cfg = TLSConfig()
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MaxVersion)
+	assert.Equal(t, fipsCipherSuites, cfg.CipherSuites)
+	assert.Equal(t, []tls.CurveID{tls.CurveP256, tls.CurveP384}, cfg.CurvePreferences)
+	assert.Equal(t, ModeFIPS, Mode())
+}
+
+// TestNewHash 测试按名称创建哈希，FIPS模式下只允许sha256和sha384
+func TestNewHash(t *testing.T) {
+	defer SetFIPS(false)
+
+	sizes := map[string]int{
+		"md5":     16,
+		"SHA-1":   20,
+		"sha256":  32,
+		"sha-384": 48,
+		"sha512":  64,
+		"xxhash":  8,
+		"xxh64":   8,
+	}
+	SetFIPS(false)
+	for name, size := range sizes {
+		h, err := NewHash(name)
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, size, h.Size(), name)
+		}
+	}
+	_, err := NewHash("crc32")
+	assert.ErrorContains(t, err, "unsupported hash algorithm")
+
+	SetFIPS(true)
+	for name, size := range sizes {
+		h, err := NewHash(name)
+		if size == 32 || size == 48 {
+			if assert.NoError(t, err, name) {
+				assert.Equal(t, size, h.Size(), name)
+			}
+		} else {
+			assert.ErrorContains(t, err, "not allowed in "+ModeFIPS, name)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package security
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Operator keys and signatures are stored like minisign files: an untrusted
+// comment line followed by the base64 of the 8 byte key ID and the key or
+// signature. The key ID is the start of the SHA-256 of the public key, so a
+// signature names the key that made it.
+const (
+	keyIDSize        = 8
+	commentPrefix    = "untrusted comment: "
+	secretKeyComment = "terrasync secret key "
+	publicKeyComment = "terrasync public key "
+	signatureComment = "signature from terrasync key "
+)
+
+// SignatureFileExt is appended to the name of a signed file for its detached signature
+const SignatureFileExt = ".sig"
+
+// SigningKey is an Ed25519 operator key signing reports and manifests
+type SigningKey struct {
+	ID  string
+	key ed25519.PrivateKey
+}
+
+// VerifyKey is the public half of a SigningKey
+type VerifyKey struct {
+	ID  string
+	key ed25519.PublicKey
+}
+
+// keyID returns the ID of a public key
+func keyID(pub ed25519.PublicKey) []byte {
+	sum := sha256.Sum256(pub)
+	return sum[:keyIDSize]
+}
+
+// GenerateSigningKey creates a new operator key pair, the secret key file is
+// only readable by the owner
+func GenerateSigningKey(secretPath, publicPath string) (*VerifyKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	id := keyID(pub)
+	hexID := strings.ToUpper(hex.EncodeToString(id))
+	if err := writeKeyFile(secretPath, secretKeyComment+hexID, id, priv.Seed(), 0600); err != nil {
+		return nil, err
+	}
+	if err := writeKeyFile(publicPath, publicKeyComment+hexID, id, pub, 0644); err != nil {
+		return nil, err
+	}
+	return &VerifyKey{ID: hexID, key: pub}, nil
+}
+
+// LoadSigningKey reads a secret key written by GenerateSigningKey
+func LoadSigningKey(path string) (*SigningKey, error) {
+	id, seed, err := readKeyFile(path, secretKeyComment, ed25519.SeedSize)
+	if err != nil {
+		return nil, err
+	}
+	key := ed25519.NewKeyFromSeed(seed)
+	if !bytes.Equal(keyID(key.Public().(ed25519.PublicKey)), id) {
+		return nil, fmt.Errorf("secret key %s is corrupted: key ID mismatch", path)
+	}
+	return &SigningKey{ID: strings.ToUpper(hex.EncodeToString(id)), key: key}, nil
+}
+
+// LoadVerifyKey reads a public key written by GenerateSigningKey
+func LoadVerifyKey(path string) (*VerifyKey, error) {
+	id, pub, err := readKeyFile(path, publicKeyComment, ed25519.PublicKeySize)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(keyID(pub), id) {
+		return nil, fmt.Errorf("public key %s is corrupted: key ID mismatch", path)
+	}
+	return &VerifyKey{ID: strings.ToUpper(hex.EncodeToString(id)), key: pub}, nil
+}
+
+// SignFile writes the detached signature of path to path+SignatureFileExt
+func (k *SigningKey) SignFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	id, _ := hex.DecodeString(k.ID)
+	sigPath := path + SignatureFileExt
+	if err := writeKeyFile(sigPath, signatureComment+k.ID, id, ed25519.Sign(k.key, data), 0644); err != nil {
+		return "", err
+	}
+	return sigPath, nil
+}
+
+// VerifyFile checks the detached signature path+SignatureFileExt of path
+func (k *VerifyKey) VerifyFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	id, sig, err := readKeyFile(path+SignatureFileExt, signatureComment, ed25519.SignatureSize)
+	if err != nil {
+		return err
+	}
+	if hexID := strings.ToUpper(hex.EncodeToString(id)); hexID != k.ID {
+		return fmt.Errorf("%s is signed with key %s, not %s", path, hexID, k.ID)
+	}
+	if !ed25519.Verify(k.key, data, sig) {
+		return fmt.Errorf("invalid signature of %s, the file was modified after signing", path)
+	}
+	return nil
+}
+
+func writeKeyFile(path, comment string, id, payload []byte, perm os.FileMode) error {
+	content := fmt.Sprintf("%s%s\n%s\n", commentPrefix, comment,
+		base64.StdEncoding.EncodeToString(append(append([]byte{}, id...), payload...)))
+	if err := os.WriteFile(path, []byte(content), perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// readKeyFile returns the key ID and the payload of a key or signature file
+func readKeyFile(path, comment string, size int) ([]byte, []byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	var lines []string
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], commentPrefix+comment) {
+		return nil, nil, fmt.Errorf("%s does not start with %q", path, commentPrefix+comment)
+	}
+	raw, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(raw) != keyIDSize+size {
+		return nil, nil, fmt.Errorf("%s is corrupted", path)
+	}
+	return raw[:keyIDSize], raw[keyIDSize:], nil
+}
@@ -0,0 +1,224 @@
+package sharedset
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"terrasync/security"
+	"time"
+)
+
+// keyPrefix namespaces the sets in a Redis shared with other applications
+const keyPrefix = "terrasync:set:"
+
+// redisError is an error reply of the server, the connection is still usable
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// redisSet is a Redis set (SADD/SISMEMBER) spoken over RESP on one connection,
+// which is redialed after network errors
+type redisSet struct {
+	config Config
+	key    string
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewRedis connects to the Redis server of config and returns the set name
+func NewRedis(ctx context.Context, config Config, name string) (Set, error) {
+	if config.TTL <= 0 {
+		config.TTL = defaultTTL
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	s := &redisSet{config: config, key: keyPrefix + name}
+	if _, err := s.do(ctx, []string{"PING"}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// dial connects, authenticates and selects the database
+func (s *redisSet) dial(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: s.config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.config.Redis)
+	if err != nil {
+		return fmt.Errorf("failed to connect to redis %s: %w", s.config.Redis, err)
+	}
+	if s.config.TLS {
+		tlsConfig := security.TLSConfig()
+		if host, _, err := net.SplitHostPort(s.config.Redis); err == nil {
+			tlsConfig.ServerName = host
+		}
+		conn = tls.Client(conn, tlsConfig)
+	}
+	s.conn, s.r = conn, bufio.NewReader(conn)
+
+	var setup [][]string
+	if s.config.Password != "" {
+		setup = append(setup, []string{"AUTH", s.config.Password})
+	}
+	if s.config.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(s.config.DB)})
+	}
+	if len(setup) == 0 {
+		return nil
+	}
+	if _, err := s.roundTrip(ctx, setup); err != nil {
+		s.closeConn()
+		return fmt.Errorf("failed to set up redis connection: %w", err)
+	}
+	return nil
+}
+
+func (s *redisSet) closeConn() {
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn, s.r = nil, nil
+	}
+}
+
+// do sends the commands in one round trip and returns their replies
+func (s *redisSet) do(ctx context.Context, cmds ...[]string) ([]any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		if err := s.dial(ctx); err != nil {
+			return nil, err
+		}
+	}
+	replies, err := s.roundTrip(ctx, cmds)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		// 网络错误后连接的状态未知，下次命令重新连接
+		s.closeConn()
+	}
+	return replies, err
+}
+
+// roundTrip writes the commands and reads a reply for each of them
+func (s *redisSet) roundTrip(ctx context.Context, cmds [][]string) ([]any, error) {
+	deadline := time.Now().Add(s.config.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = s.conn.SetDeadline(deadline)
+
+	var buf strings.Builder
+	for _, cmd := range cmds {
+		fmt.Fprintf(&buf, "*%d\r\n", len(cmd))
+		for _, arg := range cmd {
+			fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if _, err := io.WriteString(s.conn, buf.String()); err != nil {
+		return nil, fmt.Errorf("failed to send redis command: %w", err)
+	}
+	replies := make([]any, 0, len(cmds))
+	var firstErr error
+	for range cmds {
+		reply, err := readReply(s.r)
+		var replyErr redisError
+		if err != nil && !errors.As(err, &replyErr) {
+			return nil, fmt.Errorf("failed to read redis reply: %w", err)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		replies = append(replies, reply)
+	}
+	return replies, firstErr
+}
+
+// readReply reads a RESP reply: simple strings, errors, integers, bulk strings and arrays
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty redis reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, 0, n)
+		for i := 0; i < n; i++ {
+			item, err := readReply(r)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("invalid redis reply %q", line)
+	}
+}
+
+// Add adds the key with SADD and refreshes the expiry of the set
+func (s *redisSet) Add(ctx context.Context, key string) (bool, error) {
+	replies, err := s.do(ctx,
+		[]string{"SADD", s.key, key},
+		[]string{"EXPIRE", s.key, strconv.FormatInt(int64(s.config.TTL/time.Second), 10)})
+	if err != nil {
+		return false, err
+	}
+	added, ok := replies[0].(int64)
+	if !ok {
+		return false, fmt.Errorf("unexpected SADD reply %v", replies[0])
+	}
+	return added == 1, nil
+}
+
+func (s *redisSet) Contains(ctx context.Context, key string) (bool, error) {
+	replies, err := s.do(ctx, []string{"SISMEMBER", s.key, key})
+	if err != nil {
+		return false, err
+	}
+	member, ok := replies[0].(int64)
+	if !ok {
+		return false, fmt.Errorf("unexpected SISMEMBER reply %v", replies[0])
+	}
+	return member == 1, nil
+}
+
+func (s *redisSet) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeConn()
+	return nil
+}
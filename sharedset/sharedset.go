@@ -0,0 +1,138 @@
+package sharedset
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"terrasync/log"
+	"time"
+)
+
+// Set is a set of keys shared by the nodes of a distributed scan or migration,
+// such as visited directories or completed work items
+type Set interface {
+	// Add adds key and reports whether it was new, i.e. whether the caller is
+	// the first node to claim it
+	Add(ctx context.Context, key string) (bool, error)
+	// Contains reports whether key was added by any node
+	Contains(ctx context.Context, key string) (bool, error)
+	Close() error
+}
+
+// Config configures the Redis server holding the shared sets
+type Config struct {
+	Redis    string        // Redis地址(host:port)，为空时集合只在本节点内共享
+	Password string        // AUTH密码
+	DB       int           // SELECT的数据库编号
+	TLS      bool          // 使用TLS连接，FIPS模式下限制协议版本和密码套件
+	TTL      time.Duration // 集合的过期时间，放弃的运行不会一直占用Redis，<=0使用默认值
+	Timeout  time.Duration // 连接及单个命令的超时，<=0使用默认值
+}
+
+const (
+	defaultTTL     = 7 * 24 * time.Hour
+	defaultTimeout = 5 * time.Second
+)
+
+// Open opens the set name. Without a Redis server, or when it is unreachable,
+// the set is local to this node: the run still works, but nodes no longer skip
+// the work of each other.
+func Open(ctx context.Context, config Config, name string) Set {
+	if config.Redis == "" {
+		return NewLocal()
+	}
+	redis, err := NewRedis(ctx, config, name)
+	if err != nil {
+		log.Warnf("Shared set %s falls back to a local set: %v", name, err)
+		return NewLocal()
+	}
+	log.Infof("Shared set %s on redis %s", name, config.Redis)
+	return &fallbackSet{remote: redis, local: NewLocal(), name: name}
+}
+
+// localSet is a set in the memory of this node
+type localSet struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+}
+
+// NewLocal returns an empty set local to this node
+func NewLocal() Set {
+	return &localSet{keys: make(map[string]struct{})}
+}
+
+func (s *localSet) Add(ctx context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[key]; ok {
+		return false, nil
+	}
+	s.keys[key] = struct{}{}
+	return true, nil
+}
+
+func (s *localSet) Contains(ctx context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.keys[key]
+	return ok, nil
+}
+
+func (s *localSet) Close() error {
+	return nil
+}
+
+// fallbackSet uses the remote set and the local set while the remote set fails,
+// so an outage of Redis only costs duplicate work across nodes. Keys are also
+// added to the local set, the node never does the same work twice.
+type fallbackSet struct {
+	remote Set
+	local  Set
+	name   string
+	down   atomic.Bool
+}
+
+// failed logs the first error of an outage
+func (s *fallbackSet) failed(err error) {
+	if !s.down.Swap(true) {
+		log.Warnf("Shared set %s unavailable, using the local set: %v", s.name, err)
+	}
+}
+
+// recovered logs the end of an outage
+func (s *fallbackSet) recovered() {
+	if s.down.Swap(false) {
+		log.Infof("Shared set %s available again", s.name)
+	}
+}
+
+func (s *fallbackSet) Add(ctx context.Context, key string) (bool, error) {
+	added, _ := s.local.Add(ctx, key)
+	if !added {
+		return false, nil
+	}
+	remoteAdded, err := s.remote.Add(ctx, key)
+	if err != nil {
+		s.failed(err)
+		return true, nil
+	}
+	s.recovered()
+	return remoteAdded, nil
+}
+
+func (s *fallbackSet) Contains(ctx context.Context, key string) (bool, error) {
+	if ok, _ := s.local.Contains(ctx, key); ok {
+		return true, nil
+	}
+	ok, err := s.remote.Contains(ctx, key)
+	if err != nil {
+		s.failed(err)
+		return false, nil
+	}
+	s.recovered()
+	return ok, nil
+}
+
+func (s *fallbackSet) Close() error {
+	return s.remote.Close()
+}
@@ -0,0 +1,209 @@
+package sharedset
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"terrasync/log"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func init() {
+	log.Log = zap.NewNop().Sugar()
+}
+
+// fakeRedis 实现测试用到的RESP命令(PING、AUTH、SELECT、SADD、SISMEMBER、EXPIRE)
+type fakeRedis struct {
+	listener net.Listener
+	password string
+
+	mu      sync.Mutex
+	sets    map[string]map[string]bool
+	expires map[string]string
+	conns   []net.Conn
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	f := &fakeRedis{listener: listener, password: password, sets: map[string]map[string]bool{}, expires: map[string]string{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.conns = append(f.conns, conn)
+			f.mu.Unlock()
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) addr() string {
+	return f.listener.Addr().String()
+}
+
+// close 关闭监听及所有连接，模拟Redis宕机
+func (f *fakeRedis) close() {
+	f.listener.Close()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, conn := range f.conns {
+		conn.Close()
+	}
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		cmd, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		var reply string
+		switch {
+		case strings.EqualFold(cmd[0], "AUTH"):
+			authed = cmd[1] == f.password
+			reply = "+OK\r\n"
+			if !authed {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case strings.EqualFold(cmd[0], "PING"):
+			reply = "+PONG\r\n"
+		case strings.EqualFold(cmd[0], "SELECT"):
+			reply = "+OK\r\n"
+		case strings.EqualFold(cmd[0], "SADD"):
+			if f.sets[cmd[1]] == nil {
+				f.sets[cmd[1]] = map[string]bool{}
+			}
+			added := 0
+			if !f.sets[cmd[1]][cmd[2]] {
+				f.sets[cmd[1]][cmd[2]] = true
+				added = 1
+			}
+			reply = fmt.Sprintf(":%d\r\n", added)
+		case strings.EqualFold(cmd[0], "SISMEMBER"):
+			member := 0
+			if f.sets[cmd[1]][cmd[2]] {
+				member = 1
+			}
+			reply = fmt.Sprintf(":%d\r\n", member)
+		case strings.EqualFold(cmd[0], "EXPIRE"):
+			f.expires[cmd[1]] = cmd[2]
+			reply = ":1\r\n"
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		f.mu.Unlock()
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	cmd := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		cmd = append(cmd, strings.TrimSuffix(arg, "\r\n"))
+	}
+	return cmd, nil
+}
+
+// TestRedisSet 测试两个节点通过Redis共享集合
+func TestRedisSet(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeRedis(t, "secret")
+	defer server.close()
+	config := Config{Redis: server.addr(), Password: "secret", DB: 2, TTL: time.Hour}
+
+	node1 := Open(ctx, config, "scan:nightly")
+	node2 := Open(ctx, config, "scan:nightly")
+	defer node1.Close()
+	defer node2.Close()
+
+	added, err := node1.Add(ctx, "/a")
+	assert.NoError(t, err)
+	assert.True(t, added)
+	added, err = node2.Add(ctx, "/a")
+	assert.NoError(t, err)
+	assert.False(t, added, "claimed by node1")
+	ok, err := node2.Contains(ctx, "/a")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = node2.Contains(ctx, "/b")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.True(t, server.sets["terrasync:set:scan:nightly"]["/a"])
+	assert.Equal(t, "3600", server.expires["terrasync:set:scan:nightly"])
+
+	// 密码错误时连接失败
+	_, err = NewRedis(ctx, Config{Redis: server.addr(), Password: "wrong"}, "scan:nightly")
+	assert.Error(t, err)
+}
+
+// TestFallback 测试Redis不可用时使用本地集合
+func TestFallback(t *testing.T) {
+	ctx := context.Background()
+
+	// 无法连接时打开本地集合
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	unreachable := listener.Addr().String()
+	listener.Close()
+	set := Open(ctx, Config{Redis: unreachable, Timeout: time.Second}, "scan:nightly")
+	_, local := set.(*localSet)
+	assert.True(t, local)
+
+	// 运行期间Redis宕机，本节点仍然不会重复领取
+	server := newFakeRedis(t, "")
+	set = Open(ctx, Config{Redis: server.addr(), Timeout: time.Second}, "scan:nightly")
+	defer set.Close()
+	added, err := set.Add(ctx, "/a")
+	assert.NoError(t, err)
+	assert.True(t, added)
+	server.close()
+
+	added, err = set.Add(ctx, "/b")
+	assert.NoError(t, err)
+	assert.True(t, added)
+	added, err = set.Add(ctx, "/b")
+	assert.NoError(t, err)
+	assert.False(t, added)
+	ok, err := set.Contains(ctx, "/a")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
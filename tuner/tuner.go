@@ -0,0 +1,217 @@
+package tuner
+
+import (
+	"sync"
+	"terrasync/log"
+	"time"
+)
+
+// Config holds the AIMD (additive increase, multiplicative decrease) tuning parameters
+type Config struct {
+	Min            int           // 最小并发数
+	Max            int           // 最大并发数
+	Initial        int           // 初始并发数
+	Interval       time.Duration // 调整周期
+	IncreaseStep   int           // 吞吐上升时每个周期增加的并发数
+	DecreaseFactor float64       // 延迟或错误率超标时的并发缩减系数
+	MaxLatency     time.Duration // 平均延迟上限，0表示不检查
+	MaxErrorRate   float64       // 错误率上限(0~1)
+}
+
+// DefaultConfig returns the tuning profile for the given storage type (file, nfs, s3, ...)
+func DefaultConfig(storageType string) Config {
+	cfg := Config{
+		Min:            1,
+		Max:            64,
+		Initial:        4,
+		Interval:       2 * time.Second,
+		IncreaseStep:   2,
+		DecreaseFactor: 0.5,
+		MaxLatency:     500 * time.Millisecond,
+		MaxErrorRate:   0.05,
+	}
+
+	switch storageType {
+	case "nfs":
+		// NFS servers degrade quickly under too many concurrent READDIR calls
+		cfg.Min = 2
+		cfg.Initial = 8
+		cfg.MaxLatency = 200 * time.Millisecond
+	case "s3":
+		// Object stores scale out, but each request has a high fixed latency
+		cfg.Min = 8
+		cfg.Max = 256
+		cfg.Initial = 32
+		cfg.IncreaseStep = 8
+		cfg.MaxLatency = 2 * time.Second
+	}
+
+	return cfg
+}
+
+// Controller is a dynamic semaphore whose limit is adjusted with AIMD based on
+// the throughput, latency and error rate observed in each interval
+type Controller struct {
+	cfg  Config
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	limit    int
+	inflight int
+
+	// 当前统计窗口
+	ops        int64
+	samples    int64
+	errs       int64
+	latencySum time.Duration
+
+	lastThroughput float64
+	minSeen        int
+	maxSeen        int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewController creates a controller, invalid parameters are replaced with defaults
+func NewController(cfg Config) *Controller {
+	if cfg.Min <= 0 {
+		cfg.Min = 1
+	}
+	if cfg.Max < cfg.Min {
+		cfg.Max = cfg.Min
+	}
+	if cfg.Initial < cfg.Min || cfg.Initial > cfg.Max {
+		cfg.Initial = cfg.Min
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 2 * time.Second
+	}
+	if cfg.IncreaseStep <= 0 {
+		cfg.IncreaseStep = 1
+	}
+	if cfg.DecreaseFactor <= 0 || cfg.DecreaseFactor >= 1 {
+		cfg.DecreaseFactor = 0.5
+	}
+
+	c := &Controller{
+		cfg:     cfg,
+		limit:   cfg.Initial,
+		minSeen: cfg.Initial,
+		maxSeen: cfg.Initial,
+	}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Start launches the periodic adjustment loop
+func (c *Controller) Start() {
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(c.cfg.Interval)
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case now := <-ticker.C:
+				c.adjust(now.Sub(last))
+				last = now
+			}
+		}
+	}()
+}
+
+// Stop stops the adjustment loop and logs the observed concurrency range
+func (c *Controller) Stop() {
+	if c.stop == nil {
+		return
+	}
+	close(c.stop)
+	<-c.done
+	c.stop = nil
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	log.Infof("Auto-tuned concurrency: final %d, range %d-%d", c.limit, c.minSeen, c.maxSeen)
+}
+
+// Acquire blocks until a concurrency slot is available
+func (c *Controller) Acquire() {
+	c.mu.Lock()
+	for c.inflight >= c.limit {
+		c.cond.Wait()
+	}
+	c.inflight++
+	c.mu.Unlock()
+}
+
+// Release returns a concurrency slot
+func (c *Controller) Release() {
+	c.mu.Lock()
+	c.inflight--
+	c.mu.Unlock()
+	c.cond.Signal()
+}
+
+// Observe records the result of one storage operation that processed ops items
+func (c *Controller) Observe(ops int64, latency time.Duration, err error) {
+	c.mu.Lock()
+	c.samples++
+	c.ops += ops
+	c.latencySum += latency
+	if err != nil {
+		c.errs++
+	}
+	c.mu.Unlock()
+}
+
+// Limit returns the current concurrency limit
+func (c *Controller) Limit() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.limit
+}
+
+// Max returns the upper bound of the concurrency limit
+func (c *Controller) Max() int {
+	return c.cfg.Max
+}
+
+// adjust applies one AIMD step using the statistics collected during elapsed
+func (c *Controller) adjust(elapsed time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ops, samples, errs, latencySum := c.ops, c.samples, c.errs, c.latencySum
+	c.ops, c.samples, c.errs, c.latencySum = 0, 0, 0, 0
+	if samples == 0 || elapsed <= 0 {
+		return
+	}
+
+	throughput := float64(ops) / elapsed.Seconds()
+	errorRate := float64(errs) / float64(samples)
+	avgLatency := latencySum / time.Duration(samples)
+
+	oldLimit := c.limit
+	switch {
+	case errorRate > c.cfg.MaxErrorRate, c.cfg.MaxLatency > 0 && avgLatency > c.cfg.MaxLatency:
+		// 延迟或错误率超标：乘性减少
+		c.limit = max(c.cfg.Min, int(float64(c.limit)*c.cfg.DecreaseFactor))
+	case throughput >= c.lastThroughput:
+		// 吞吐仍在上升：加性增加
+		c.limit = min(c.cfg.Max, c.limit+c.cfg.IncreaseStep)
+	}
+	c.lastThroughput = throughput
+
+	c.minSeen = min(c.minSeen, c.limit)
+	c.maxSeen = max(c.maxSeen, c.limit)
+	if c.limit != oldLimit {
+		log.Debugf("Concurrency adjusted %d -> %d (throughput %.1f/s, latency %v, error rate %.2f%%)",
+			oldLimit, c.limit, throughput, avgLatency, errorRate*100)
+		c.cond.Broadcast()
+	}
+}
@@ -0,0 +1,126 @@
+package tuner
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"terrasync/log"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func init() {
+	log.Log = zap.NewNop().Sugar()
+}
+
+func newTestController() *Controller {
+	return NewController(Config{
+		Min:            2,
+		Max:            10,
+		Initial:        4,
+		Interval:       time.Second,
+		IncreaseStep:   2,
+		DecreaseFactor: 0.5,
+		MaxLatency:     100 * time.Millisecond,
+		MaxErrorRate:   0.1,
+	})
+}
+
+// TestAdjustIncrease 测试吞吐上升时加性增加
+func TestAdjustIncrease(t *testing.T) {
+	c := newTestController()
+
+	c.Observe(100, 10*time.Millisecond, nil)
+	c.adjust(time.Second)
+	assert.Equal(t, 6, c.Limit())
+
+	c.Observe(200, 10*time.Millisecond, nil)
+	c.adjust(time.Second)
+	assert.Equal(t, 8, c.Limit())
+
+	// 不超过最大值
+	for i := 0; i < 5; i++ {
+		c.Observe(int64(300+i), 10*time.Millisecond, nil)
+		c.adjust(time.Second)
+	}
+	assert.Equal(t, 10, c.Limit())
+}
+
+// TestAdjustHold 测试吞吐下降但无拥塞信号时保持不变
+func TestAdjustHold(t *testing.T) {
+	c := newTestController()
+
+	c.Observe(100, 10*time.Millisecond, nil)
+	c.adjust(time.Second)
+	assert.Equal(t, 6, c.Limit())
+
+	c.Observe(50, 10*time.Millisecond, nil)
+	c.adjust(time.Second)
+	assert.Equal(t, 6, c.Limit())
+}
+
+// TestAdjustDecrease 测试延迟或错误率超标时乘性减少
+func TestAdjustDecrease(t *testing.T) {
+	cases := []struct {
+		name    string
+		latency time.Duration
+		err     error
+	}{{
+		name:    "延迟超标",
+		latency: time.Second,
+	}, {
+		name:    "错误率超标",
+		latency: time.Millisecond,
+		err:     errors.New("list failed"),
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestController()
+			c.Observe(100, tc.latency, tc.err)
+			c.adjust(time.Second)
+			assert.Equal(t, 2, c.Limit())
+
+			// 不低于最小值
+			c.Observe(100, tc.latency, tc.err)
+			c.adjust(time.Second)
+			assert.Equal(t, 2, c.Limit())
+		})
+	}
+}
+
+// TestAdjustIdle 测试无样本时不调整
+func TestAdjustIdle(t *testing.T) {
+	c := newTestController()
+	c.adjust(time.Second)
+	assert.Equal(t, 4, c.Limit())
+}
+
+// TestAcquireRelease 测试并发槽位受限制约束
+func TestAcquireRelease(t *testing.T) {
+	c := newTestController()
+	for i := 0; i < c.Limit(); i++ {
+		c.Acquire()
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		c.Acquire()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquire should block when the limit is reached")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	c.Release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("acquire should succeed after release")
+	}
+}